@@ -0,0 +1,79 @@
+// ==============================================================================
+// Order acknowledgment - answer a submission after execution, not queueing
+// ==============================================================================
+// POST /orders normally answers 202 as soon as the order is on the stream.
+// With ORDER_ACK_MODE=sync, or per request with ?wait=true (or an X-Wait: true
+// header), it instead waits up to ORDER_ACK_TIMEOUT (or ?timeout=, e.g. 500ms)
+// for the order's execution outcome and answers 200 with the OrderResponse:
+// filled, rejected, working if it rests in the book, and so on. If no outcome
+// arrives in time it falls back to the 202 with the order ID, and the order
+// still executes. ?wait=false keeps a request asynchronous when sync is the
+// default.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Order acknowledgment modes
+const (
+	ackModeAsync = "async"
+	ackModeSync  = "sync"
+)
+
+const waitHeader = "X-Wait"
+
+// maxAckTimeout caps how long a request may ask to wait
+const maxAckTimeout = 30 * time.Second
+
+// wantsSyncAck reports whether a submission should wait for its outcome
+func (e *ExecutionEngine) wantsSyncAck(r *http.Request) bool {
+	for _, v := range []string{r.URL.Query().Get("wait"), r.Header.Get(waitHeader)} {
+		if wait, err := strconv.ParseBool(v); err == nil {
+			return wait
+		}
+	}
+	return e.config.OrderAckMode == ackModeSync
+}
+
+// ackTimeout is how long a synchronous submission waits for its outcome
+func (e *ExecutionEngine) ackTimeout(r *http.Request) time.Duration {
+	timeout := e.config.OrderAckTimeout
+	if d, err := time.ParseDuration(r.URL.Query().Get("timeout")); err == nil && d > 0 {
+		timeout = d
+	}
+	if timeout > maxAckTimeout {
+		timeout = maxAckTimeout
+	}
+	return timeout
+}
+
+// awaitOutcome waits on sub for the first update of orderID past accepted,
+// reporting false if none arrives within timeout. sub must have been
+// subscribed before the order was queued so the update can't be missed.
+func (e *ExecutionEngine) awaitOutcome(ctx context.Context, sub *fillSubscription, orderID string, timeout time.Duration) (*OrderResponse, bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case update := <-sub.updates:
+			if update.OrderID == orderID && update.Status != statusAccepted {
+				return update, true
+			}
+		case <-timer.C:
+			// The update may have been dropped, or never sent for an order
+			// executed earlier under the same idempotency key
+			if response, ok := e.GetOrder(orderID); ok && response.Status != statusAccepted {
+				return response, true
+			}
+			return nil, false
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
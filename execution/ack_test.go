@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const ackTestOrder = `{"order_id":"ack-1","symbol":"AAPL","side":"buy","quantity":10,"type":"market","time_in_force":"day","idempotency_key":"key-ack-1"}`
+
+// postAndConsume posts ackTestOrder to target and, if consume is set, runs
+// the consumer until the request has been answered
+func postAndConsume(t *testing.T, engine *ExecutionEngine, target string, consume bool) *httptest.ResponseRecorder {
+	t.Helper()
+	queueBatch(t, engine) // creates the consumer group
+	handler := engine.routes()
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(ackTestOrder)))
+	}()
+	for consume {
+		select {
+		case <-done:
+			return rec
+		default:
+			engine.consumeBatch()
+		}
+	}
+	<-done
+	return rec
+}
+
+func TestSyncAckReturnsExecutionOutcome(t *testing.T) {
+	engine, _ := newTestEngine(t)
+
+	rec := postAndConsume(t, engine, "/orders?wait=true", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("sync submission: got %d, want 200", rec.Code)
+	}
+	var response OrderResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.OrderID != "ack-1" || response.Status != statusFilled || !response.FilledQuantity.Equal(dec(10)) {
+		t.Errorf("sync submission answered with %+v, want the fill", response)
+	}
+}
+
+func TestSyncAckFallsBackToAcceptedOnTimeout(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.OrderAckMode = ackModeSync
+
+	// Nothing consumes the order, so no outcome arrives in time
+	rec := postAndConsume(t, engine, "/orders?timeout=50ms", false)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("timed out sync submission: got %d, want 202", rec.Code)
+	}
+	var body map[string]string
+	json.NewDecoder(rec.Body).Decode(&body)
+	if body["order_id"] != "ack-1" || body["status"] != string(statusAccepted) {
+		t.Errorf("timed out sync submission answered with %v", body)
+	}
+}
+
+func TestAsyncAckIsTheDefault(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	if rec := postAndConsume(t, engine, "/orders", false); rec.Code != http.StatusAccepted {
+		t.Errorf("default submission: got %d, want 202", rec.Code)
+	}
+}
@@ -0,0 +1,197 @@
+// ==============================================================================
+// Good-after-time orders - hold an order until its activation time
+// ==============================================================================
+// An order with activate_at (unix ms) in the future is validated when it is
+// consumed, then held rather than executed: it is "held", doesn't match and
+// doesn't rest. Held orders live in Redis, in the "<stream>.held" sorted set
+// scored by activation time with their payloads in "<stream>.held.orders",
+// so they survive a restart.
+//
+// The activation sweeper runs every ACTIVATION_SWEEP_INTERVAL and executes
+// due orders like freshly consumed ones: limit orders match and rest, market
+// orders fill. Held orders can be cancelled until then; whichever of the
+// sweeper and a cancel removes the order from the set first wins.
+//
+// Every kind of held order (stops, trailing stops and conditionals too) is
+// held before its idempotency key is claimed, which happens when it runs. So
+// an order isn't held if its key has been used or its order ID has already
+// run: a resubmission is a duplicate, not a new order to wait on.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// holdOrder parks an order until its activation time and reports it held
+func (e *ExecutionEngine) holdOrder(order *OrderRequest) error {
+	if held, err := e.holdOrderIn(order, e.heldKey, float64(order.ActivateAt)); err != nil || !held {
+		return err
+	}
+	log.Printf("Order %s held until %s", order.OrderID, time.UnixMilli(order.ActivateAt).UTC().Format(time.RFC3339Nano))
+	return nil
+}
+
+// holdOrderIn stores a held order, indexed in index by score, and reports it
+// held. A resubmission of an order that has already run is not held, so as
+// not to overwrite its outcome; it reports whether the order was held.
+func (e *ExecutionEngine) holdOrderIn(order *OrderRequest, index string, score float64) (bool, error) {
+	if existing, ok := e.GetOrder(order.OrderID); ok && !existing.Status.canTransition(statusHeld) {
+		log.Printf("Order %s is already %s, not holding it", order.OrderID, existing.Status)
+		return false, nil
+	}
+	if order.IdempotencyKey != "" && e.idempotencyKeyUsed(e.ctx, order) {
+		log.Printf("Duplicate order detected (idempotency key: %s)", order.IdempotencyKey)
+		return false, nil
+	}
+
+	data, err := json.Marshal(order)
+	if err != nil {
+		return false, err
+	}
+	pipe := e.redisClient.TxPipeline()
+	added := pipe.HSet(e.ctx, e.heldOrdersKey, order.OrderID, data)
+	pipe.ZAdd(e.ctx, index, &redis.Z{Score: score, Member: order.OrderID})
+	if _, err := pipe.Exec(e.ctx); err != nil {
+		log.Printf("Error holding order %s: %v", order.OrderID, err)
+		return false, err
+	}
+	if added.Val() > 0 {
+		e.openOrders.add(order.AccountID, 1)
+	}
+
+	response := &OrderResponse{
+		OrderID:        order.OrderID,
+		ClientOrderID:  order.IdempotencyKey,
+		Symbol:         order.Symbol,
+		ClientSymbol:   order.ClientSymbol,
+		Status:         statusHeld,
+		AcknowledgedAt: e.now().UnixMilli(),
+		Tags:           order.Tags,
+		ParentOrderID:  order.ParentOrderID,
+	}
+	if err := e.storeResponse(response); err != nil {
+		e.unholdOrder(e.ctx, index, order.OrderID) // finished meanwhile
+		return false, nil
+	}
+	e.saveOrder(order, response)
+	e.auditState(order.AccountID, response)
+	e.publishResponse(response)
+	return true, nil
+}
+
+// unholdOrder removes an order held in index, returning its JSON payload. It
+// reports false if the order isn't held, e.g. because it was activated or
+// cancelled first.
+func (e *ExecutionEngine) unholdOrder(ctx context.Context, index string, orderID string) (string, bool, error) {
+	removed, err := e.redisClient.ZRem(ctx, index, orderID).Result()
+	if err != nil || removed == 0 {
+		return "", false, err
+	}
+	payload, err := e.redisClient.HGet(ctx, e.heldOrdersKey, orderID).Result()
+	if err != nil {
+		return "", false, err
+	}
+	if n, _ := e.redisClient.HDel(ctx, e.heldOrdersKey, orderID).Result(); n > 0 {
+		var order OrderRequest
+		if json.Unmarshal([]byte(payload), &order) == nil {
+			e.openOrders.add(order.AccountID, -1)
+		}
+	}
+	return payload, true, nil
+}
+
+// cancelHeld cancels a held order. A non-empty account must own it.
+func (e *ExecutionEngine) cancelHeld(orderID string, account string) error {
+	data, err := e.redisClient.HGet(e.ctx, e.heldOrdersKey, orderID).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return errOrderNotWorking
+	}
+	if err != nil {
+		return err
+	}
+	var order OrderRequest
+	if err := json.Unmarshal(data, &order); err != nil {
+		return err
+	}
+	if account != "" && order.AccountID != account {
+		return errNotOrderOwner
+	}
+
+	// A stop may be waiting for its activation time or for its price, a
+	// conditional order for its time or its condition
+	indexes := []string{e.heldKey, e.stopKey(order.Side, order.Symbol)}
+	if cond, err := parseCondition(order.Condition); err == nil {
+		indexes = append(indexes, e.conditionKey(cond.Op, cond.Symbol))
+	}
+	for _, index := range indexes {
+		_, ok, err := e.unholdOrder(e.ctx, index, orderID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			e.updateCachedResponse(orderID, statusCancelled, func(r *OrderResponse) {})
+			return nil
+		}
+	}
+	return errOrderNotWorking
+}
+
+// activateDue executes every held order whose activation time has passed.
+// Like a consumer batch, it waits out and is skipped while consumption is
+// paused.
+func (e *ExecutionEngine) activateDue() {
+	e.batchMu.Lock()
+	defer e.batchMu.Unlock()
+	if e.paused.Load() {
+		return
+	}
+
+	due, err := e.redisClient.ZRangeByScore(e.ctx, e.heldKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(e.now().UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		if e.ctx.Err() == nil {
+			log.Printf("Error reading held orders: %v", err)
+		}
+		return
+	}
+
+	for _, orderID := range due {
+		payload, ok, err := e.unholdOrder(e.ctx, e.heldKey, orderID)
+		if err != nil {
+			log.Printf("Error activating held order %s: %v", orderID, err)
+			continue
+		}
+		if !ok {
+			continue // cancelled meanwhile
+		}
+		log.Printf("Activating held order %s", orderID)
+		if err := e.handleMessage(redis.XMessage{ID: "held:" + orderID, Values: map[string]interface{}{"order": payload}}); err != nil {
+			log.Printf("Error activating held order %s: %v", orderID, err)
+		}
+	}
+}
+
+// activationLoop runs the activation sweeper until ctx is cancelled
+func (e *ExecutionEngine) activationLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.activateDue()
+		}
+	}
+}
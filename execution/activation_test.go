@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeldOrderExecutesOnlyAfterActivation(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	order := testOrder("gat-1")
+	order.ActivateAt = time.Now().Add(150 * time.Millisecond).UnixMilli()
+	submitToEngine(t, engine, &order)
+
+	if status := restingStatus(t, engine, "gat-1"); status != statusHeld {
+		t.Fatalf("status before activation = %s, want held", status)
+	}
+	engine.activateDue()
+	if response, _ := engine.GetOrder("gat-1"); response.Status != statusHeld || !response.FilledQuantity.IsZero() {
+		t.Fatalf("order executed before its activation time: %+v", response)
+	}
+
+	time.Sleep(time.Until(time.UnixMilli(order.ActivateAt)) + 10*time.Millisecond)
+	engine.activateDue()
+	response, _ := engine.GetOrder("gat-1")
+	if response.Status != statusFilled || !response.FilledQuantity.Equal(dec(10)) {
+		t.Fatalf("order not executed after activation: %+v", response)
+	}
+	if n := engine.redisClient.ZCard(engine.ctx, engine.heldKey).Val(); n != 0 {
+		t.Errorf("%d orders still held after activation", n)
+	}
+}
+
+func TestHeldLimitOrderDoesNotRestUntilActivated(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	order := limitOrder("gat-bid", "AAPL", "buy", 99, 10)
+	order.ActivateAt = time.Now().Add(time.Hour).UnixMilli()
+	submitToEngine(t, engine, order)
+
+	if inBook(engine, "AAPL", "gat-bid") {
+		t.Fatal("held order is resting in the book")
+	}
+	submitToEngine(t, engine, limitOrder("ask", "AAPL", "sell", 99, 10))
+	if status := restingStatus(t, engine, "ask"); status != statusWorking {
+		t.Errorf("sell matched a held order: status %s", status)
+	}
+}
+
+func TestCancelHeldOrder(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	order := testOrder("gat-2")
+	order.AccountID = "acct-1"
+	order.ActivateAt = time.Now().Add(20 * time.Millisecond).UnixMilli()
+	submitToEngine(t, engine, &order)
+
+	if _, err := engine.CancelOrder("gat-2", "acct-2"); err != errNotOrderOwner {
+		t.Fatalf("cancel by another account: got %v, want errNotOrderOwner", err)
+	}
+	response, err := engine.CancelOrder("gat-2", "acct-1")
+	if err != nil || response.Status != statusCancelled {
+		t.Fatalf("cancel held order: %+v, %v", response, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	engine.activateDue()
+	if response, _ := engine.GetOrder("gat-2"); response.Status != statusCancelled || !response.FilledQuantity.IsZero() {
+		t.Fatalf("cancelled held order was executed: %+v", response)
+	}
+}
+
+func TestResubmittedOrderIsNotHeldAgain(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	order := testOrder("gat-dup")
+	submitToEngine(t, engine, &order)
+	if response, _ := engine.GetOrder("gat-dup"); response.Status != statusFilled {
+		t.Fatalf("first submission: %+v, want filled", response)
+	}
+
+	order.ActivateAt = time.Now().Add(time.Hour).UnixMilli()
+	submitToEngine(t, engine, &order)
+	if response, _ := engine.GetOrder("gat-dup"); response.Status != statusFilled {
+		t.Errorf("resubmission overwrote the fill: %+v", response)
+	}
+	if response, ok := engine.loadStoredOrder(engine.ctx, "gat-dup"); !ok || response.Status != statusFilled {
+		t.Errorf("stored response after resubmission: %+v, want filled", response)
+	}
+	if n := engine.redisClient.ZCard(engine.ctx, engine.heldKey).Val(); n != 0 {
+		t.Errorf("%d orders held after resubmitting a filled order", n)
+	}
+
+	// A stop reusing the key is a duplicate too
+	trade(t, engine, "t1", 100)
+	stop := stopOrder("stop-dup")
+	stop.IdempotencyKey = order.IdempotencyKey
+	submitToEngine(t, engine, stop)
+	if response, ok := engine.GetOrder("stop-dup"); ok {
+		t.Errorf("stop with a used idempotency key was handled: %+v", response)
+	}
+	if n := engine.redisClient.ZCard(engine.ctx, engine.stopKey("sell", "AAPL")).Val(); n != 0 {
+		t.Errorf("%d stops waiting with a used idempotency key", n)
+	}
+}
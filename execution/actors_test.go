@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// gatedAdapter records the order each symbol's orders reach the venue in.
+// With msftDone set it also holds AAPL orders until an MSFT order has
+// executed, which only finishes if the two symbols execute in parallel.
+type gatedAdapter struct {
+	next     BrokerAdapter
+	msftDone chan struct{}
+	once     sync.Once
+	timedOut bool
+
+	mu       sync.Mutex
+	executed map[string][]string
+}
+
+func (a *gatedAdapter) Execute(ctx context.Context, order *OrderRequest) (*OrderResponse, error) {
+	a.mu.Lock()
+	a.executed[order.Symbol] = append(a.executed[order.Symbol], order.OrderID)
+	a.mu.Unlock()
+	switch {
+	case a.msftDone == nil:
+	case order.Symbol == "AAPL":
+		select {
+		case <-a.msftDone:
+		case <-time.After(2 * time.Second):
+			a.timedOut = true
+		}
+	case order.Symbol == "MSFT":
+		a.once.Do(func() { close(a.msftDone) })
+	}
+	return a.next.Execute(ctx, order)
+}
+
+// queueSymbolOrders queues market buys, each "<symbol>:<id>"
+func queueSymbolOrders(t *testing.T, engine *ExecutionEngine, orders ...string) {
+	t.Helper()
+	if err := ensureConsumerGroup(context.Background(), engine.redisClient, engine.streamName, engine.consumerGroup); err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range orders {
+		var symbol, id string
+		fmt.Sscanf(o, "%4s:%s", &symbol, &id)
+		order := testOrder(id)
+		order.Symbol = symbol
+		if err := engine.SubmitOrder(context.Background(), &order); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSymbolsExecuteInParallelAndInOrder(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.OrderTimeout = 0
+	adapter := &gatedAdapter{next: engine.broker, msftDone: make(chan struct{}), executed: map[string][]string{}}
+	engine.broker = adapter
+	queueSymbolOrders(t, engine, "AAPL:a1", "AAPL:a2", "MSFT:m1", "AAPL:a3", "MSFT:m2")
+
+	engine.consumeBatch()
+
+	if adapter.timedOut {
+		t.Error("AAPL waited for MSFT behind it in the batch: symbols ran serially")
+	}
+	for symbol, want := range map[string][]string{"AAPL": {"a1", "a2", "a3"}, "MSFT": {"m1", "m2"}} {
+		if got := adapter.executed[symbol]; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s executed %v, want stream order %v", symbol, got, want)
+		}
+		for _, id := range want {
+			if response, _ := engine.GetOrder(id); response.Status != statusFilled {
+				t.Errorf("%s: status %s, want filled", id, response.Status)
+			}
+		}
+	}
+	pending, err := engine.redisClient.XPending(engine.ctx, engine.streamName, engine.consumerGroup).Result()
+	if err != nil || pending.Count != 0 {
+		t.Errorf("%v messages left pending (%v), want every one acknowledged", pending, err)
+	}
+}
+
+func TestSerialModeExecutesTheBatchInOrder(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := DefaultConfig()
+	cfg.RedisHost, cfg.RedisPort, cfg.StreamName = mr.Host(), mr.Port(), "test-stream"
+	cfg.MatchingMode = matchingModeSerial
+	engine := NewExecutionEngineFromConfig(cfg)
+	t.Cleanup(engine.Close)
+	if engine.actors != nil {
+		t.Fatal("serial mode started symbol actors")
+	}
+	recorder := &gatedAdapter{next: engine.broker, executed: map[string][]string{}}
+	engine.broker = recorder
+	queueSymbolOrders(t, engine, "MSFT:m1", "AAPL:a1", "MSFT:m2")
+
+	engine.consumeBatch()
+
+	if got := fmt.Sprint(recorder.executed["MSFT"], recorder.executed["AAPL"]); got != "[m1 m2] [a1]" {
+		t.Errorf("executed %s", got)
+	}
+}
+
+// rendezvousAdapter holds each order until another has reached the venue too,
+// or until a short wait runs out
+type rendezvousAdapter struct {
+	next    BrokerAdapter
+	arrived chan struct{}
+}
+
+func (a rendezvousAdapter) Execute(ctx context.Context, order *OrderRequest) (*OrderResponse, error) {
+	select {
+	case a.arrived <- struct{}{}:
+	case <-a.arrived:
+	case <-time.After(200 * time.Millisecond):
+	}
+	return a.next.Execute(ctx, order)
+}
+
+func TestAccountLimitsHoldAcrossSymbolActors(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.OrderTimeout = 0
+	setRiskLimits(engine, func(l *RiskLimits) { l.MaxOpenOrdersPerAccount = 1 })
+	engine.broker = rendezvousAdapter{next: engine.broker, arrived: make(chan struct{})}
+	if err := ensureConsumerGroup(context.Background(), engine.redisClient, engine.streamName, engine.consumerGroup); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two resting bids on two actors, with room for one: the second must
+	// see the first in the book, not race it to the venue
+	for _, symbol := range []string{"AAPL", "MSFT"} {
+		order := limitOrder("bid-"+symbol, symbol, "buy", 90, 1)
+		order.AccountID = "acct-1"
+		if err := engine.SubmitOrder(context.Background(), order); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine.consumeBatch()
+
+	working, rejected := 0, 0
+	for _, id := range []string{"bid-AAPL", "bid-MSFT"} {
+		response, _ := engine.GetOrder(id)
+		switch {
+		case response.Status == statusWorking:
+			working++
+		case response.RejectReason == rejectTooManyOpenOrders:
+			rejected++
+		}
+	}
+	if working != 1 || rejected != 1 {
+		t.Errorf("%d bids working and %d rejected, want the cap of 1 kept", working, rejected)
+	}
+}
+
+// holdingAdapter records the order each symbol's orders reach the venue in,
+// holding the order hold until release is closed
+type holdingAdapter struct {
+	next    BrokerAdapter
+	hold    string
+	release chan struct{}
+
+	mu       sync.Mutex
+	executed map[string][]string
+}
+
+func (a *holdingAdapter) Execute(ctx context.Context, order *OrderRequest) (*OrderResponse, error) {
+	if order.OrderID == a.hold {
+		select {
+		case <-a.release:
+		case <-time.After(2 * time.Second):
+		}
+	}
+	a.mu.Lock()
+	a.executed[order.Symbol] = append(a.executed[order.Symbol], order.OrderID)
+	a.mu.Unlock()
+	return a.next.Execute(ctx, order)
+}
+
+func (a *holdingAdapter) executedIn(symbol string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.executed[symbol]...)
+}
+
+func TestTriggeredOrderExecutesOnItsOwnSymbolsActor(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.OrderTimeout = 0
+	submitToEngine(t, engine, limitOrder("ask", "MSFT", "sell", 60, 10))
+	conditional := testOrder("cond")
+	conditional.Condition = "MSFT > 50"
+	submitToEngine(t, engine, &conditional)
+	if status := restingStatus(t, engine, "cond"); status != statusHeld {
+		t.Fatalf("conditional order %s, want held", status)
+	}
+
+	adapter := &holdingAdapter{next: engine.broker, hold: "slow", release: make(chan struct{}), executed: map[string][]string{}}
+	engine.broker = adapter
+	queueSymbolOrders(t, engine, "AAPL:slow", "MSFT:trade")
+	done := make(chan struct{})
+	go func() {
+		engine.consumeBatch()
+		close(done)
+	}()
+
+	// MSFT trades through the condition while AAPL's actor is busy: the
+	// AAPL order it sets off waits for that actor rather than running on
+	// MSFT's
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if response, ok := engine.GetOrder("trade"); ok && response.Status == statusFilled {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := adapter.executedIn("AAPL"); len(got) != 0 {
+		t.Errorf("AAPL executed %v while its actor was busy, want nothing yet", got)
+	}
+
+	close(adapter.release)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("batch never finished")
+	}
+	if got := adapter.executedIn("AAPL"); fmt.Sprint(got) != "[slow cond]" {
+		t.Errorf("AAPL executed %v, want the triggered order after the one ahead of it", got)
+	}
+	if status := restingStatus(t, engine, "cond"); status != statusFilled {
+		t.Errorf("triggered order %s once the batch ended, want filled", status)
+	}
+}
+
+// delayedAdapter adds a fixed venue round trip to every order
+type delayedAdapter struct {
+	next  BrokerAdapter
+	delay time.Duration
+}
+
+func (a delayedAdapter) Execute(ctx context.Context, order *OrderRequest) (*OrderResponse, error) {
+	time.Sleep(a.delay)
+	return a.next.Execute(ctx, order)
+}
+
+// globalLockAdapter runs the simulator under one lock shared by every
+// symbol, as matching ran before the actors owned their books
+type globalLockAdapter struct {
+	next BrokerAdapter
+	mu   *sync.Mutex
+}
+
+func (a globalLockAdapter) Execute(ctx context.Context, order *OrderRequest) (*OrderResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.next.Execute(ctx, order)
+}
+
+// BenchmarkMultiSymbolMatching runs batches of crossing limit orders in eight
+// symbols through the consumer, processOrder and the book, for bare matching
+// and with a simulated venue round trip: serially, with per-symbol actors
+// matching under one global lock (the design before books moved into their
+// actors), and with actors owning their books. Queueing the orders isn't
+// timed.
+func BenchmarkMultiSymbolMatching(b *testing.B) {
+	symbols := []string{"AAPL", "MSFT", "GOOG", "AMZN", "TSLA", "META", "NVDA", "NFLX"}
+	designs := []struct {
+		name       string
+		mode       string
+		globalLock bool
+	}{
+		{"serial", matchingModeSerial, false},
+		{"global_lock", matchingModePerSymbol, true},
+		{"actors", matchingModePerSymbol, false},
+	}
+	for _, venue := range []time.Duration{0, 50 * time.Microsecond} {
+		for _, design := range designs {
+			b.Run(fmt.Sprintf("%s/venue=%v", design.name, venue), func(b *testing.B) {
+				mr := miniredis.RunT(b)
+				cfg := DefaultConfig()
+				cfg.RedisHost, cfg.RedisPort, cfg.StreamName = mr.Host(), mr.Port(), "bench-stream"
+				cfg.MatchingMode = design.mode
+				cfg.OrderTimeout = 0
+				engine := NewExecutionEngineFromConfig(cfg)
+				b.Cleanup(engine.Close)
+				if design.globalLock {
+					engine.broker = globalLockAdapter{next: engine.broker, mu: &sync.Mutex{}}
+				}
+				engine.broker = delayedAdapter{next: engine.broker, delay: venue}
+				if err := ensureConsumerGroup(context.Background(), engine.redisClient, engine.streamName, engine.consumerGroup); err != nil {
+					b.Fatal(err)
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					side := "buy"
+					if i%2 == 1 {
+						side = "sell"
+					}
+					for _, symbol := range symbols {
+						order := limitOrder(fmt.Sprintf("%s-%d", symbol, i), symbol, side, 100, 1)
+						if err := engine.SubmitOrder(context.Background(), order); err != nil {
+							b.Fatal(err)
+						}
+					}
+					b.StartTimer()
+					engine.consumeBatch()
+				}
+				b.ReportMetric(float64(b.N*len(symbols))/b.Elapsed().Seconds(), "orders/s")
+			})
+		}
+	}
+}
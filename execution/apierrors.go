@@ -0,0 +1,106 @@
+// ==============================================================================
+// API errors - machine-readable HTTP error responses
+// ==============================================================================
+// Every HTTP error is answered with a JSON body
+//
+//   {"error": {"code": "invalid_request", "message": "Invalid limit", "field": "limit"}}
+//
+// where code is one of the ErrorCode constants below and decides the status,
+// message is for humans and may change, and field, when present, names the
+// request parameter or body field at fault. Errors that clear with time also
+// carry retry_after_ms. Clients should branch on code.
+//
+//   invalid_request     400  malformed body or parameter
+//   unauthorized        401  missing or unknown API key
+//   forbidden           403  the key's account may not do this
+//   not_found           404  no such order, symbol or route
+//   snapshot_required   410  a book diff reaches back too far; load a snapshot
+//   method_not_allowed  405
+//   payload_too_large   413  request body over MAX_PAYLOAD_BYTES
+//   internal_error      500
+//   backpressure        503  order queue backed up; retry after Retry-After
+//   unavailable         503  a dependency (e.g. Redis) is unavailable
+//
+// Orders the engine refuses on business grounds are not errors: they are
+// answered with an OrderResponse whose status is "rejected".
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ErrorCode is a stable, machine-readable API error code
+type ErrorCode string
+
+// API error codes
+const (
+	errCodeInvalidRequest   ErrorCode = "invalid_request"
+	errCodeUnauthorized     ErrorCode = "unauthorized"
+	errCodeForbidden        ErrorCode = "forbidden"
+	errCodeNotFound         ErrorCode = "not_found"
+	errCodeSnapshotRequired ErrorCode = "snapshot_required"
+	errCodeMethodNotAllowed ErrorCode = "method_not_allowed"
+	errCodePayloadTooLarge  ErrorCode = "payload_too_large"
+	errCodeInternal         ErrorCode = "internal_error"
+	errCodeBackpressure     ErrorCode = "backpressure"
+	errCodeUnavailable      ErrorCode = "unavailable"
+)
+
+// errorStatus is the HTTP status of each error code
+var errorStatus = map[ErrorCode]int{
+	errCodeInvalidRequest:   http.StatusBadRequest,
+	errCodeUnauthorized:     http.StatusUnauthorized,
+	errCodeForbidden:        http.StatusForbidden,
+	errCodeNotFound:         http.StatusNotFound,
+	errCodeSnapshotRequired: http.StatusGone,
+	errCodeMethodNotAllowed: http.StatusMethodNotAllowed,
+	errCodePayloadTooLarge:  http.StatusRequestEntityTooLarge,
+	errCodeInternal:         http.StatusInternalServerError,
+	errCodeBackpressure:     http.StatusServiceUnavailable,
+	errCodeUnavailable:      http.StatusServiceUnavailable,
+}
+
+// APIError is the body of an HTTP error response
+type APIError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Field   string    `json:"field,omitempty"`
+
+	// Set on errors that clear with time: how long to wait before retrying
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+}
+
+// writeAPIError answers with err and its code's status
+func writeAPIError(w http.ResponseWriter, err APIError) {
+	status, ok := errorStatus[err.Code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]APIError{"error": err})
+}
+
+// writeError answers with an error code and message
+func writeError(w http.ResponseWriter, code ErrorCode, message string) {
+	writeAPIError(w, APIError{Code: code, Message: message})
+}
+
+// writeFieldError answers with an invalid_request error about field
+func writeFieldError(w http.ResponseWriter, field string, message string) {
+	writeAPIError(w, APIError{Code: errCodeInvalidRequest, Message: message, Field: field})
+}
+
+// methodNotAllowed answers a request with an unsupported method
+func methodNotAllowed(w http.ResponseWriter) {
+	writeError(w, errCodeMethodNotAllowed, "Method not allowed")
+}
+
+// notFound answers a request for something that doesn't exist
+func notFound(w http.ResponseWriter) {
+	writeError(w, errCodeNotFound, "Not found")
+}
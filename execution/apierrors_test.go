@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorResponsesCarryCodes(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.apiKeys = map[string]string{"key-1": "acct-1"}
+	engine.config.StreamBacklogLimit = 1
+	if err := ensureConsumerGroup(context.Background(), engine.redisClient, engine.streamName, engine.consumerGroup); err != nil {
+		t.Fatal(err)
+	}
+	handler := engine.routes()
+	if rec := postOrder(handler, "key-1"); rec.Code != http.StatusAccepted {
+		t.Fatalf("filling the backlog: got %d", rec.Code)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		target string
+		body   string
+		key    string
+		status int
+		code   ErrorCode
+		field  string
+	}{
+		{"bad method", http.MethodGet, "/admin/pause", "", "key-1", http.StatusMethodNotAllowed, errCodeMethodNotAllowed, ""},
+		{"malformed order", http.MethodPost, "/orders", "{", "key-1", http.StatusBadRequest, errCodeInvalidRequest, ""},
+		{"bad parameter", http.MethodGet, "/orders?limit=abc", "", "", http.StatusBadRequest, errCodeInvalidRequest, "limit"},
+		{"bad time", http.MethodGet, "/rejections?since=yesterday", "", "", http.StatusBadRequest, errCodeInvalidRequest, "since"},
+		{"unknown order", http.MethodGet, "/orders/nope", "", "", http.StatusNotFound, errCodeNotFound, ""},
+		{"unknown route", http.MethodGet, "/pnl/AAPL/extra", "", "", http.StatusNotFound, errCodeNotFound, ""},
+		{"missing key", http.MethodPost, "/orders", authTestOrder, "", http.StatusUnauthorized, errCodeUnauthorized, ""},
+		{"invalid key", http.MethodPost, "/orders", authTestOrder, "key-x", http.StatusUnauthorized, errCodeUnauthorized, ""},
+		{"other account", http.MethodPost, "/orders/cancel-all", `{"account_id":"acct-2"}`, "key-1", http.StatusForbidden, errCodeForbidden, ""},
+		{"backlog", http.MethodPost, "/orders", authTestOrder, "key-1", http.StatusServiceUnavailable, errCodeBackpressure, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.key != "" {
+				req.Header.Set(apiKeyHeader, tc.key)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Errorf("status = %d, want %d", rec.Code, tc.status)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			var body struct {
+				Error APIError `json:"error"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("error body is not JSON: %v", err)
+			}
+			if body.Error.Code != tc.code || body.Error.Field != tc.field || body.Error.Message == "" {
+				t.Errorf("error = %+v, want code %s field %q", body.Error, tc.code, tc.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// ==============================================================================
+// Lifecycle audit - append-only record of every order state change
+// ==============================================================================
+// Every step an order takes is appended to the "<stream>.audit" stream as an
+// AuditRecord: received and validated when it is consumed, routed when it is
+// sent for execution, then each state it reaches (held, working,
+// partial_fill, filled, cancelled, rejected, expired). An order whose
+// execution timeout expired is recorded as expired; a later reconciliation
+// appends its real outcome.
+//
+// Records carry a sequence number that increases by one per record across
+// the whole engine and continues from the last record after a restart.
+// Numbering and appending happen under one lock, so stream order is sequence
+// order however many orders are processed at once. The stream is never
+// trimmed, and is separate from the response stream clients consume.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shopspring/decimal"
+)
+
+// AuditEvent is one kind of lifecycle step
+type AuditEvent string
+
+// Audit events
+const (
+	auditReceived    AuditEvent = "received"
+	auditValidated   AuditEvent = "validated"
+	auditRejected    AuditEvent = "rejected"
+	auditRouted      AuditEvent = "routed"
+	auditHeld        AuditEvent = "held"
+	auditWorking     AuditEvent = "working"
+	auditPartialFill AuditEvent = "partial_fill"
+	auditFilled      AuditEvent = "filled"
+	auditCancelled   AuditEvent = "cancelled"
+	auditExpired     AuditEvent = "expired"
+)
+
+// stateAuditEvents maps the states an order can reach to their audit events
+var stateAuditEvents = map[OrderState]AuditEvent{
+	statusHeld:            auditHeld,
+	statusWorking:         auditWorking,
+	statusPartiallyFilled: auditPartialFill,
+	statusFilled:          auditFilled,
+	statusCancelled:       auditCancelled,
+	statusRejected:        auditRejected,
+	statusTimedOut:        auditExpired,
+}
+
+// AuditRecord is one entry of the lifecycle audit. Records carry the order's
+// tags (see tags.go).
+type AuditRecord struct {
+	Seq            uint64            `json:"seq"`
+	Event          AuditEvent        `json:"event"`
+	OrderID        string            `json:"order_id"`
+	AccountID      string            `json:"account_id,omitempty"`
+	Symbol         string            `json:"symbol,omitempty"`
+	FilledQuantity decimal.Decimal   `json:"filled_quantity"`
+	Detail         string            `json:"detail,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"` // the order's tags
+	Timestamp      int64             `json:"timestamp"`      // unix ms
+}
+
+// audit appends a record, numbering it. Appends are serialized so the
+// stream is in sequence order.
+func (e *ExecutionEngine) audit(record AuditRecord) {
+	e.auditMu.Lock()
+	defer e.auditMu.Unlock()
+	record.Seq = e.auditSeq + 1
+	record.Timestamp = e.now().UnixMilli()
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Error encoding %s audit of order %s: %v", record.Event, record.OrderID, err)
+		return
+	}
+	err = e.redisClient.XAdd(e.ctx, &redis.XAddArgs{
+		Stream: e.auditStreamName,
+		Values: map[string]interface{}{"record": data},
+	}).Err()
+	if err != nil {
+		log.Printf("Error auditing %s of order %s: %v", record.Event, record.OrderID, err)
+		return
+	}
+	e.auditSeq = record.Seq
+}
+
+// auditOrder records a step of order
+func (e *ExecutionEngine) auditOrder(order *OrderRequest, event AuditEvent, detail string) {
+	e.audit(AuditRecord{Event: event, OrderID: order.OrderID, AccountID: order.AccountID, Symbol: order.Symbol, Detail: detail, Tags: order.Tags})
+}
+
+// auditState records the state response has reached, if it is one the audit
+// tracks
+func (e *ExecutionEngine) auditState(accountID string, response *OrderResponse) {
+	event, ok := stateAuditEvents[response.Status]
+	if !ok {
+		return
+	}
+	e.audit(AuditRecord{
+		Event:          event,
+		OrderID:        response.OrderID,
+		AccountID:      accountID,
+		Symbol:         response.Symbol,
+		FilledQuantity: response.FilledQuantity,
+		Detail:         response.RejectReason,
+		Tags:           response.Tags,
+	})
+}
+
+// internalMessage reports whether a message was generated by the engine for
+// an order it already consumed, like an activated held order or a triggered
+// stop, rather than read from the order stream
+func internalMessage(messageID string) bool {
+	return strings.HasPrefix(messageID, "held:") || strings.HasPrefix(messageID, "stop:") || strings.HasPrefix(messageID, "cond:")
+}
+
+// RecoverAuditSequence continues numbering from the last audit record
+func (e *ExecutionEngine) RecoverAuditSequence() error {
+	entries, err := e.redisClient.XRevRangeN(e.ctx, e.auditStreamName, "+", "-", 1).Result()
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	data, _ := entries[0].Values["record"].(string)
+	var last AuditRecord
+	if err := json.Unmarshal([]byte(data), &last); err != nil {
+		return err
+	}
+	e.auditMu.Lock()
+	e.auditSeq = last.Seq
+	e.auditMu.Unlock()
+	return nil
+}
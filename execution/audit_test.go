@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// auditRecords returns the whole lifecycle audit in stream order
+func auditRecords(t *testing.T, engine *ExecutionEngine) []AuditRecord {
+	t.Helper()
+	entries, err := engine.redisClient.XRange(engine.ctx, engine.auditStreamName, "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := make([]AuditRecord, len(entries))
+	for i, entry := range entries {
+		if err := json.Unmarshal([]byte(entry.Values["record"].(string)), &records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return records
+}
+
+// auditEventsOf returns an order's audit events in order
+func auditEventsOf(records []AuditRecord, orderID string) []AuditEvent {
+	var events []AuditEvent
+	for _, record := range records {
+		if record.OrderID == orderID {
+			events = append(events, record.Event)
+		}
+	}
+	return events
+}
+
+func TestAuditRecordsFullLifecycleInOrder(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	sell := limitOrder("sell-1", "AAPL", "sell", 100, 10)
+	sell.AccountID = "acct-1"
+	submitToEngine(t, engine, sell)
+	for i, qty := range []float64{4, 6} {
+		buy := limitOrder(fmt.Sprintf("buy-%d", i), "AAPL", "buy", 100, qty)
+		buy.AccountID = "acct-2"
+		submitToEngine(t, engine, buy)
+	}
+	rejected := limitOrder("bad-1", "AAPL", "buy", 100, 1)
+	rejected.Side = "hold"
+	submitToEngine(t, engine, rejected)
+
+	records := auditRecords(t, engine)
+	want := []AuditEvent{auditReceived, auditValidated, auditRouted, auditWorking, auditPartialFill, auditFilled}
+	if got := auditEventsOf(records, "sell-1"); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("resting order's audit = %v, want %v", got, want)
+	}
+	want = []AuditEvent{auditReceived, auditRejected}
+	if got := auditEventsOf(records, "bad-1"); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("invalid order's audit = %v, want %v", got, want)
+	}
+	for i, record := range records {
+		if record.Seq != uint64(i+1) || record.Timestamp == 0 {
+			t.Fatalf("record %d: seq %d timestamp %d, want seq %d", i, record.Seq, record.Timestamp, i+1)
+		}
+	}
+}
+
+func TestAuditIsSequencedUnderConcurrentOrders(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			order := testOrder(fmt.Sprintf("order-%d", i))
+			submitToEngine(t, engine, &order)
+		}(i)
+	}
+	wg.Wait()
+
+	records := auditRecords(t, engine)
+	if len(records) != 20*4 {
+		t.Fatalf("%d audit records, want %d", len(records), 20*4)
+	}
+	for i, record := range records {
+		if record.Seq != uint64(i+1) {
+			t.Fatalf("record %d has seq %d: stream out of sequence order", i, record.Seq)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		want := []AuditEvent{auditReceived, auditValidated, auditRouted, auditFilled}
+		if got := auditEventsOf(records, fmt.Sprintf("order-%d", i)); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("order-%d audit = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestAuditSequenceContinuesAfterRestart(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	order := testOrder("before")
+	submitToEngine(t, engine, &order)
+
+	engine.auditSeq = 0
+	if err := engine.RecoverAuditSequence(); err != nil {
+		t.Fatal(err)
+	}
+	order = testOrder("after")
+	submitToEngine(t, engine, &order)
+
+	records := auditRecords(t, engine)
+	if last := records[len(records)-1]; last.Seq != uint64(len(records)) {
+		t.Errorf("last record seq %d after restart, want %d", last.Seq, len(records))
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const authTestOrder = `{"order_id":"auth-1","symbol":"AAPL","side":"buy","quantity":10,"type":"market","time_in_force":"day","account_id":"spoofed"}`
+
+func postOrder(handler http.Handler, apiKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(authTestOrder))
+	if apiKey != "" {
+		req.Header.Set(apiKeyHeader, apiKey)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	engine, mr := newTestEngine(t)
+	engine.apiKeys = map[string]string{"key-alice": "acct-alice"}
+	engine.config.APIKeysRedisKey = "test.api_keys"
+	mr.HSet("test.api_keys", "key-bob", "acct-bob")
+	handler := engine.routes()
+
+	tests := []struct {
+		name    string
+		key     string
+		code    int
+		account string
+	}{
+		{"valid configured key", "key-alice", http.StatusAccepted, "acct-alice"},
+		{"valid redis key", "key-bob", http.StatusAccepted, "acct-bob"},
+		{"missing key", "", http.StatusUnauthorized, ""},
+		{"wrong key", "key-mallory", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine.redisClient.Del(context.Background(), engine.streamName)
+
+			rec := postOrder(handler, tt.key)
+			if rec.Code != tt.code {
+				t.Fatalf("expected %d, got %d", tt.code, rec.Code)
+			}
+
+			msgs := engine.redisClient.XRange(context.Background(), engine.streamName, "-", "+").Val()
+			if tt.code != http.StatusAccepted {
+				if len(msgs) != 0 {
+					t.Fatalf("unauthenticated order was queued")
+				}
+				return
+			}
+			var order OrderRequest
+			if len(msgs) != 1 || json.Unmarshal([]byte(msgs[0].Values["order"].(string)), &order) != nil {
+				t.Fatalf("expected one queued order, got %v", msgs)
+			}
+			if order.AccountID != tt.account {
+				t.Errorf("account = %q, want %q", order.AccountID, tt.account)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthLeavesReadsOpen(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.apiKeys = map[string]string{"key-alice": "acct-alice"}
+	handler := engine.routes()
+
+	for _, path := range []string{"/health", "/metrics", "/orders"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code == http.StatusUnauthorized {
+			t.Errorf("GET %s should not require an API key", path)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/dlq/replay", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /dlq/replay without key: expected 401, got %d", rec.Code)
+	}
+}
+
+func TestAdminReadsRequireAnAPIKey(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.apiKeys = map[string]string{"key-alice": "acct-alice"}
+	engine.positions.Apply("acct-bob", "AAPL", "buy", dec(10), dec(100), dec(0))
+	handler := engine.routes()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/positions", nil))
+	if rec.Code != http.StatusUnauthorized || strings.Contains(rec.Body.String(), "acct-bob") {
+		t.Fatalf("GET /admin/positions without key: %d %s, want 401", rec.Code, rec.Body)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/positions", nil)
+	req.Header.Set(apiKeyHeader, "key-alice")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /admin/positions with a key: %d, want 200", rec.Code)
+	}
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := parseAPIKeys(" k1:acct-1, k2:acct-2 ")
+	if err != nil || keys["k1"] != "acct-1" || keys["k2"] != "acct-2" {
+		t.Fatalf("unexpected keys %v (%v)", keys, err)
+	}
+	if _, err := parseAPIKeys("k1"); err == nil {
+		t.Error("entry without account should fail")
+	}
+}
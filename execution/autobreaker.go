@@ -0,0 +1,149 @@
+// ==============================================================================
+// Auto-breaker - halt all trading when too many orders are rejected
+// ==============================================================================
+// A burst of rejections usually means something upstream is broken: a
+// strategy sending garbage, stale reference data, a misconfigured limit. With
+// AUTO_BREAKER_REJECT_RATIO set, the engine watches the share of orders
+// rejected over the outcome window (METRICS_WINDOW, see outcomes.go) and,
+// once at least AUTO_BREAKER_MIN_ORDERS orders have been seen and the share
+// exceeds the ratio, trips: every order consumed from then on is rejected
+// with "trading_halted_autobreaker", in every symbol.
+//
+// The breaker stays tripped until an operator resets it with POST
+// /admin/autobreaker/reset; only outcomes after the reset count towards
+// tripping it again. Tripping is logged as an alert, the trading_auto_halted
+// gauge is 1 while tripped, /ready reports "auto_halted" and GET
+// /admin/autobreaker shows the state. Resting orders stay in the book and can
+// be cancelled.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// rejectAutoBreaker is the reason for orders consumed while the
+// auto-breaker is tripped
+const rejectAutoBreaker = "trading_halted_autobreaker"
+
+// AutoBreakerState is the answer to GET /admin/autobreaker
+type AutoBreakerState struct {
+	Halted      bool    `json:"halted"`
+	HaltedAt    int64   `json:"halted_at,omitempty"`    // unix ms
+	RejectRatio float64 `json:"reject_ratio,omitempty"` // that tripped it
+	Threshold   float64 `json:"threshold"`
+}
+
+// autoBreaker trips on a high rejection ratio and stays tripped until reset
+type autoBreaker struct {
+	threshold float64 // 0 disables
+	minOrders int
+
+	mu       sync.Mutex
+	halted   bool
+	haltedAt time.Time
+	ratio    float64
+	resetAt  int64 // unix second from which outcomes count
+
+	gauge prometheus.Gauge
+}
+
+func newAutoBreaker(threshold float64, minOrders int, registry *prometheus.Registry) *autoBreaker {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "trading_auto_halted",
+		Help: "Whether the rejection-rate auto-breaker has halted all trading (1) or not (0)",
+	})
+	registry.MustRegister(gauge)
+	return &autoBreaker{threshold: threshold, minOrders: minOrders, gauge: gauge}
+}
+
+// isHalted reports whether the breaker is tripped. A nil breaker never is.
+func (b *autoBreaker) isHalted() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.halted
+}
+
+// state reports the breaker's state
+func (b *autoBreaker) state() AutoBreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := AutoBreakerState{Halted: b.halted, Threshold: b.threshold}
+	if b.halted {
+		state.HaltedAt = b.haltedAt.UnixMilli()
+		state.RejectRatio = b.ratio
+	}
+	return state
+}
+
+// checkAutoBreaker trips the breaker if the rejection ratio since the last
+// reset, within the outcome window, is over the threshold
+func (e *ExecutionEngine) checkAutoBreaker() {
+	b := e.autoBreaker
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.halted {
+		return
+	}
+	processed, rejected := e.outcomes.totals(b.resetAt)
+	total := processed + rejected
+	if total == 0 || total < b.minOrders {
+		return
+	}
+	ratio := float64(rejected) / float64(total)
+	if ratio <= b.threshold {
+		return
+	}
+	b.halted, b.haltedAt, b.ratio = true, e.now(), ratio
+	b.gauge.Set(1)
+	log.Printf("ALERT: auto-breaker tripped, %d of the last %d orders rejected (%.0f%%, threshold %.0f%%); all trading halted until POST /admin/autobreaker/reset",
+		rejected, total, ratio*100, b.threshold*100)
+}
+
+// ResetAutoBreaker lets trading resume after the auto-breaker tripped
+func (e *ExecutionEngine) ResetAutoBreaker() {
+	b := e.autoBreaker
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.resetAt = e.now().Unix() + 1
+	if b.halted {
+		b.halted = false
+		b.gauge.Set(0)
+		log.Printf("Auto-breaker reset, trading resumed")
+	}
+}
+
+// handleAutoBreaker serves GET /admin/autobreaker
+func (e *ExecutionEngine) handleAutoBreaker(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	json.NewEncoder(w).Encode(e.autoBreaker.state())
+}
+
+// handleAutoBreakerReset serves POST /admin/autobreaker/reset
+func (e *ExecutionEngine) handleAutoBreakerReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	e.ResetAutoBreaker()
+	json.NewEncoder(w).Encode(e.autoBreaker.state())
+}
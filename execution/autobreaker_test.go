@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAutoBreakerHaltsTradingOnRejectionRate(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.autoBreaker.threshold = 0.5
+	engine.autoBreaker.minOrders = 10
+	handler := engine.routes()
+	call := func(method, path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec.Code
+	}
+
+	for i := 0; i < 4; i++ {
+		order := testOrder(fmt.Sprintf("ok-%d", i))
+		submitToEngine(t, engine, &order)
+	}
+	// Orders in a halted symbol are rejected; the sixth takes the ratio to
+	// 6 of 10, past the threshold
+	engine.HaltSymbol("MSFT")
+	for i := 0; i < 6; i++ {
+		if engine.autoBreaker.isHalted() {
+			t.Fatalf("breaker tripped after %d of 9 orders rejected, below the minimum order count", i)
+		}
+		order := testOrder(fmt.Sprintf("halted-%d", i))
+		order.Symbol = "MSFT"
+		submitToEngine(t, engine, &order)
+	}
+	if !engine.autoBreaker.isHalted() {
+		t.Fatal("breaker did not trip at a 60% rejection rate")
+	}
+	if got := testutil.ToFloat64(engine.autoBreaker.gauge); got != 1 {
+		t.Errorf("trading_auto_halted = %v, want 1", got)
+	}
+	if code := call(http.MethodGet, "/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("/ready while auto-halted: got %d, want 503", code)
+	}
+
+	// Every symbol is halted now
+	order := testOrder("after-trip")
+	submitToEngine(t, engine, &order)
+	if response, _ := engine.GetOrder("after-trip"); response.Status != statusRejected || response.RejectReason != rejectAutoBreaker {
+		t.Fatalf("order after the trip: got %+v, want rejected with %s", response, rejectAutoBreaker)
+	}
+
+	if code := call(http.MethodPost, "/admin/autobreaker/reset"); code != http.StatusOK || engine.autoBreaker.isHalted() {
+		t.Fatalf("reset: got %d, halted=%v", code, engine.autoBreaker.isHalted())
+	}
+	order = testOrder("after-reset")
+	submitToEngine(t, engine, &order)
+	if response, _ := engine.GetOrder("after-reset"); response.Status != statusFilled {
+		t.Errorf("order after the reset: got %s, want filled", response.Status)
+	}
+	if got := testutil.ToFloat64(engine.autoBreaker.gauge); got != 0 {
+		t.Errorf("trading_auto_halted after the reset = %v, want 0", got)
+	}
+}
@@ -0,0 +1,85 @@
+// ==============================================================================
+// Backpressure - refuse new orders when the order stream is backed up
+// ==============================================================================
+// Submissions are appended with approximate MAXLEN trimming (STREAM_MAX_LEN)
+// so the stream can't grow without bound. Trimming drops the oldest entries
+// whether or not they have been consumed, so the engine also stops accepting
+// orders well before that point: when the consumer group's backlog (entries
+// not yet delivered plus delivered but unacknowledged) reaches
+// STREAM_BACKLOG_LIMIT, or when Redis is too slow to take the XADD within
+// REDIS_TIMEOUT, POST /orders answers 503 with Retry-After instead of 202.
+// Keep STREAM_BACKLOG_LIMIT well below STREAM_MAX_LEN.
+//
+// The backlog comes from XINFO GROUPS; Redis before 7.0 doesn't report the
+// undelivered part, so only unacknowledged entries count there.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// backpressureRetryAfter is the Retry-After sent with a 503
+const backpressureRetryAfter = time.Second
+
+// Reasons SubmitOrder refuses an order
+var (
+	errQueueFull = errors.New("order queue is full")
+	errQueueSlow = errors.New("order queue is not responding")
+)
+
+// streamBacklog returns how many entries of the order stream the consumer
+// group has yet to finish
+func (e *ExecutionEngine) streamBacklog(ctx context.Context) (int64, error) {
+	reply, err := e.redisClient.Do(ctx, "XINFO", "GROUPS", e.streamName).Result()
+	if err != nil {
+		return 0, err
+	}
+	groups, ok := reply.([]interface{})
+	if !ok {
+		return 0, fmt.Errorf("unexpected XINFO GROUPS reply %T", reply)
+	}
+	for _, g := range groups {
+		fields, ok := g.([]interface{})
+		if !ok {
+			continue
+		}
+		info := map[string]interface{}{}
+		for i := 0; i+1 < len(fields); i += 2 {
+			if key, ok := fields[i].(string); ok {
+				info[key] = fields[i+1]
+			}
+		}
+		if info["name"] != e.consumerGroup {
+			continue
+		}
+		pending, _ := info["pending"].(int64)
+		lag, _ := info["lag"].(int64) // nil before Redis 7 or when unknown
+		return pending + lag, nil
+	}
+	return 0, nil
+}
+
+// streamSaturated reports whether the order stream's backlog has reached the
+// configured limit. Errors reading the backlog don't block submissions.
+func (e *ExecutionEngine) streamSaturated(ctx context.Context) bool {
+	if e.config.StreamBacklogLimit <= 0 {
+		return false
+	}
+	backlog, err := e.streamBacklog(ctx)
+	if err != nil {
+		return false
+	}
+	return backlog >= e.config.StreamBacklogLimit
+}
+
+// isTimeout reports whether err is a Redis call running out of time
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
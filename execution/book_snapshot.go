@@ -0,0 +1,298 @@
+// ==============================================================================
+// Order Book Persistence - periodic snapshots plus a mutation journal
+// ==============================================================================
+// Every change to a book (order rested, resting order filled or cancelled) is
+// appended to "<stream>.book.journal". A snapshot of all books is written to
+// "<stream>.book.snapshot" on a configurable interval, recording the last
+// journal entry it covers. On startup the books are rebuilt from the snapshot
+// and any journal entries written after it. Book changes are also published
+// as events for consumers of the books (see bookevents.go).
+//
+// A mutation that can't be appended to the journal would be lost on a
+// restart before the next snapshot, so the failure is counted in
+// book_journal_failures_total and the books are snapshotted straight away,
+// and again on every tick until a snapshot succeeds, rather than after the
+// rest of BOOK_SNAPSHOT_INTERVAL.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/shopspring/decimal"
+)
+
+// Book journal operations
+const (
+	journalOpAdd    = "add"
+	journalOpFill   = "fill"
+	journalOpCancel = "cancel"
+)
+
+// bookMutation is a single journaled change to a book
+type bookMutation struct {
+	Op       string          `json:"op"`
+	Symbol   string          `json:"symbol"`
+	Order    *BookOrder      `json:"order,omitempty"`
+	OrderID  string          `json:"order_id,omitempty"`
+	Quantity decimal.Decimal `json:"quantity"`
+	EventSeq uint64          `json:"event_seq,omitempty"` // the book's last event after the mutation
+}
+
+// BookSnapshot is the serialized state of one symbol's book. Orders are
+// listed best price first and in queue order within a price.
+type BookSnapshot struct {
+	Symbol   string      `json:"symbol"`
+	Sequence uint64      `json:"sequence"`
+	Bids     []BookOrder `json:"bids"`
+	Asks     []BookOrder `json:"asks"`
+
+	EventSequence uint64 `json:"event_sequence"` // the last book event the snapshot includes
+}
+
+// engineSnapshot is the document stored under the snapshot key
+type engineSnapshot struct {
+	JournalID string         `json:"journal_id"`
+	TakenAt   int64          `json:"taken_at"`
+	Books     []BookSnapshot `json:"books"`
+}
+
+// Snapshot captures the book in priority order
+func (b *OrderBook) Snapshot() BookSnapshot {
+	snap := BookSnapshot{Symbol: b.Symbol, Sequence: b.seq, Bids: []BookOrder{}, Asks: []BookOrder{}, EventSequence: b.eventSeq}
+	for _, level := range b.bids {
+		for _, o := range level.orders {
+			snap.Bids = append(snap.Bids, *o)
+		}
+	}
+	for _, level := range b.asks {
+		for _, o := range level.orders {
+			snap.Asks = append(snap.Asks, *o)
+		}
+	}
+	return snap
+}
+
+// RestoreOrderBook rebuilds a book from a snapshot, preserving queue priority
+func RestoreOrderBook(snap BookSnapshot) *OrderBook {
+	book := NewOrderBook(snap.Symbol)
+	book.seq = snap.Sequence
+	book.eventSeq = snap.EventSequence
+	for _, orders := range [][]BookOrder{snap.Bids, snap.Asks} {
+		for i := range orders {
+			o := orders[i]
+			book.insert(&o)
+		}
+	}
+	return book
+}
+
+func newJournalFailureCounter(registry *prometheus.Registry) prometheus.Counter {
+	c := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "book_journal_failures_total",
+		Help: "Book mutations that could not be appended to the journal",
+	})
+	registry.MustRegister(c)
+	return c
+}
+
+// journal appends a mutation of book. Callers must hold the book's lock so
+// the journal order matches the order its mutations were applied.
+func (e *ExecutionEngine) journal(book *OrderBook, m bookMutation) {
+	m.EventSeq = book.eventSeq
+	defer e.publishBookEvents(book)
+
+	payload, _ := json.Marshal(m)
+	id, err := e.redisClient.XAdd(e.ctx, &redis.XAddArgs{
+		Stream: e.bookJournalStream,
+		Values: map[string]interface{}{"mutation": payload},
+	}).Result()
+	if err != nil {
+		log.Printf("Error journaling book mutation for %s, snapshotting the books: %v", m.Symbol, err)
+		if e.journalFailures != nil {
+			e.journalFailures.Inc()
+		}
+		select {
+		case e.snapshotDue <- struct{}{}:
+		default:
+		}
+		return
+	}
+	// Books journal concurrently, so the latest entry may not be the last
+	// one to return
+	e.journalMu.Lock()
+	if e.lastJournalID == "" || streamIDAfter(id, e.lastJournalID) {
+		e.lastJournalID = id
+	}
+	e.journalMu.Unlock()
+}
+
+// applyMutation replays a journaled mutation. Callers must hold lockBooks.
+func (e *ExecutionEngine) applyMutation(m bookMutation) {
+	book, ok := e.books[m.Symbol]
+	if !ok {
+		book = NewOrderBook(m.Symbol)
+		e.books[m.Symbol] = book
+	}
+	switch m.Op {
+	case journalOpAdd:
+		if m.Order == nil {
+			return
+		}
+		book.insert(m.Order)
+		if m.Order.Sequence > book.seq {
+			book.seq = m.Order.Sequence
+		}
+	case journalOpFill:
+		if order, ok := book.Get(m.OrderID); ok {
+			book.reduce(order, m.Quantity)
+		}
+	case journalOpCancel:
+		book.Cancel(m.OrderID)
+	}
+	// Replayed changes were published before the restart
+	book.events = book.events[:0]
+	if m.EventSeq > 0 {
+		book.eventSeq = m.EventSeq
+	}
+}
+
+// bookSnapshots captures every book sorted by symbol. Callers must hold
+// lockBooks.
+func (e *ExecutionEngine) bookSnapshots() []BookSnapshot {
+	snaps := make([]BookSnapshot, 0, len(e.books))
+	for _, book := range e.books {
+		snaps = append(snaps, book.Snapshot())
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Symbol < snaps[j].Symbol })
+	return snaps
+}
+
+// SnapshotBooks writes the current state of all books to Redis and trims
+// journal entries the snapshot already covers
+func (e *ExecutionEngine) SnapshotBooks(ctx context.Context) error {
+	// With every book locked no mutation is half journaled, so the books
+	// are exactly what the journal up to lastJournalID describes
+	unlock := e.lockBooks()
+	e.journalMu.Lock()
+	snap := engineSnapshot{
+		JournalID: e.lastJournalID,
+		TakenAt:   e.now().UnixMilli(),
+		Books:     e.bookSnapshots(),
+	}
+	e.journalMu.Unlock()
+	unlock()
+
+	payload, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if err := e.redisClient.Set(ctx, e.bookSnapshotKey, payload, 0).Err(); err != nil {
+		return err
+	}
+
+	if snap.JournalID != "" {
+		e.redisClient.XTrimMinID(ctx, e.bookJournalStream, snap.JournalID)
+	}
+	return nil
+}
+
+// RecoverBooks rebuilds the books from the latest snapshot plus any journal
+// entries written after it
+func (e *ExecutionEngine) RecoverBooks(ctx context.Context) error {
+	var snap engineSnapshot
+	payload, err := e.redisClient.Get(ctx, e.bookSnapshotKey).Bytes()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(payload, &snap); err != nil {
+			return err
+		}
+	}
+
+	entries, err := e.redisClient.XRange(ctx, e.bookJournalStream, "-", "+").Result()
+	if err != nil {
+		return err
+	}
+
+	unlock := e.lockBooks()
+	defer unlock()
+	e.journalMu.Lock()
+	defer e.journalMu.Unlock()
+
+	e.books = make(map[string]*OrderBook)
+	for _, bs := range snap.Books {
+		e.books[bs.Symbol] = RestoreOrderBook(bs)
+	}
+	e.lastJournalID = snap.JournalID
+
+	replayed := 0
+	for _, entry := range entries {
+		if snap.JournalID != "" && !streamIDAfter(entry.ID, snap.JournalID) {
+			continue
+		}
+		raw, _ := entry.Values["mutation"].(string)
+		var m bookMutation
+		if err := json.Unmarshal([]byte(raw), &m); err != nil {
+			log.Printf("Skipping malformed journal entry %s: %v", entry.ID, err)
+			continue
+		}
+		e.applyMutation(m)
+		e.lastJournalID = entry.ID
+		replayed++
+	}
+
+	for _, book := range e.books {
+		book.recordEvents = true
+	}
+	if err := e.recountOpenOrders(ctx); err != nil {
+		return err
+	}
+	log.Printf("Recovered %d order books (%d journal entries replayed)", len(e.books), replayed)
+	return nil
+}
+
+// snapshotLoop periodically snapshots the books, and as soon as the journal
+// misses a mutation, until ctx is cancelled
+func (e *ExecutionEngine) snapshotLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-e.snapshotDue:
+		}
+		if err := e.SnapshotBooks(ctx); err != nil {
+			log.Printf("Error snapshotting order books: %v", err)
+		}
+	}
+}
+
+// streamIDAfter reports whether stream entry ID a sorts after b
+func streamIDAfter(a, b string) bool {
+	ams, aseq := splitStreamID(a)
+	bms, bseq := splitStreamID(b)
+	if ams != bms {
+		return ams > bms
+	}
+	return aseq > bseq
+}
+
+func splitStreamID(id string) (uint64, uint64) {
+	msPart, seqPart, _ := strings.Cut(id, "-")
+	ms, _ := strconv.ParseUint(msPart, 10, 64)
+	seq, _ := strconv.ParseUint(seqPart, 10, 64)
+	return ms, seq
+}
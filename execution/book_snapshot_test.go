@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func limitOrder(id string, symbol string, side string, price float64, qty float64) *OrderRequest {
+	return &OrderRequest{
+		OrderID:     id,
+		Symbol:      symbol,
+		Side:        side,
+		Quantity:    dec(qty),
+		Type:        "limit",
+		LimitPrice:  dec(price),
+		TimeInForce: "day",
+	}
+}
+
+func snapshotOf(e *ExecutionEngine) []BookSnapshot {
+	unlock := e.lockBooks()
+	defer unlock()
+	return e.bookSnapshots()
+}
+
+// sameBooks compares snapshots by their encoding, which is canonical for
+// decimals that are equal but differently represented
+func sameBooks(a, b []BookSnapshot) bool {
+	aj, _ := json.Marshal(a)
+	bj, _ := json.Marshal(b)
+	return string(aj) == string(bj)
+}
+
+func TestBookSnapshotRecovery(t *testing.T) {
+	engine, mr := newTestEngine(t)
+	ctx := context.Background()
+
+	// Two bids at the same price to check queue order survives the round trip
+	engine.executeOrder(context.Background(), limitOrder("b1", "AAPL", "buy", 99.5, 10))
+	engine.executeOrder(context.Background(), limitOrder("b2", "AAPL", "buy", 99.5, 5))
+	engine.executeOrder(context.Background(), limitOrder("b3", "AAPL", "buy", 99.0, 7))
+	engine.executeOrder(context.Background(), limitOrder("a1", "AAPL", "sell", 101.0, 3))
+	engine.executeOrder(context.Background(), limitOrder("a2", "MSFT", "sell", 300.0, 1))
+
+	if err := engine.SnapshotBooks(ctx); err != nil {
+		t.Fatalf("SnapshotBooks: %v", err)
+	}
+
+	restored := NewExecutionEngine(mr.Host(), mr.Port(), "test-stream")
+	defer restored.redisClient.Close()
+	if err := restored.RecoverBooks(ctx); err != nil {
+		t.Fatalf("RecoverBooks: %v", err)
+	}
+
+	want := snapshotOf(engine)
+	if got := snapshotOf(restored); !sameBooks(got, want) {
+		t.Fatalf("restored book differs\n got: %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestBookRecoveryReplaysJournalAfterSnapshot(t *testing.T) {
+	engine, mr := newTestEngine(t)
+	ctx := context.Background()
+
+	engine.executeOrder(context.Background(), limitOrder("b1", "AAPL", "buy", 99.5, 10))
+	engine.executeOrder(context.Background(), limitOrder("b2", "AAPL", "buy", 99.5, 5))
+	if err := engine.SnapshotBooks(ctx); err != nil {
+		t.Fatalf("SnapshotBooks: %v", err)
+	}
+
+	// Mutations after the snapshot only exist in the journal
+	engine.executeOrder(context.Background(), limitOrder("s1", "AAPL", "sell", 99.5, 12))
+	engine.executeOrder(context.Background(), limitOrder("b4", "AAPL", "buy", 98.0, 4))
+
+	restored := NewExecutionEngine(mr.Host(), mr.Port(), "test-stream")
+	defer restored.redisClient.Close()
+	if err := restored.RecoverBooks(ctx); err != nil {
+		t.Fatalf("RecoverBooks: %v", err)
+	}
+
+	want := snapshotOf(engine)
+	got := snapshotOf(restored)
+	if !sameBooks(got, want) {
+		t.Fatalf("restored book differs\n got: %+v\nwant: %+v", got, want)
+	}
+	// b1 was fully filled, b2 partially (3 left)
+	bids := got[0].Bids
+	if len(bids) != 2 || bids[0].OrderID != "b2" || !bids[0].Quantity.Equal(dec(3)) || bids[1].OrderID != "b4" {
+		t.Errorf("unexpected bids after recovery: %+v", bids)
+	}
+}
+
+func TestFailedJournalAppendForcesASnapshot(t *testing.T) {
+	engine, mr := newTestEngine(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go engine.snapshotLoop(ctx, time.Hour)
+
+	// The journal can't take the mutation
+	mr.Set(engine.bookJournalStream, "not a stream")
+	engine.executeOrder(context.Background(), limitOrder("b1", "AAPL", "buy", 99.5, 10))
+	if got := testutil.ToFloat64(engine.journalFailures); got != 1 {
+		t.Fatalf("journal failures = %v, want 1", got)
+	}
+
+	// The snapshot doesn't wait an hour for the next tick
+	deadline := time.Now().Add(time.Second)
+	for !mr.Exists(engine.bookSnapshotKey) {
+		if time.Now().After(deadline) {
+			t.Fatal("no snapshot after the journal missed a mutation")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	mr.Del(engine.bookJournalStream)
+
+	restored := NewExecutionEngine(mr.Host(), mr.Port(), "test-stream")
+	defer restored.redisClient.Close()
+	if err := restored.RecoverBooks(context.Background()); err != nil {
+		t.Fatalf("RecoverBooks: %v", err)
+	}
+	if got, want := snapshotOf(restored), snapshotOf(engine); !sameBooks(got, want) {
+		t.Fatalf("restored book differs\n got: %+v\nwant: %+v", got, want)
+	}
+}
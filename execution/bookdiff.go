@@ -0,0 +1,137 @@
+// ==============================================================================
+// Book snapshot and diff - a live book view over plain HTTP
+// ==============================================================================
+// A UI that can't read the book event stream keeps its view with two calls:
+//
+//   GET /book/{symbol}/snapshot        every resting order, best price first,
+//                                      and event_sequence, the last book
+//                                      event the snapshot includes
+//   GET /book/{symbol}/diff?since=N    the book events after N (see
+//                                      bookevents.go) and the sequence to
+//                                      ask from next time
+//
+// Each book keeps its last BOOK_DIFF_HISTORY published events in memory. A
+// diff from further back than that, or from before a restart, can't be
+// served: it is answered 410 "snapshot_required" and the UI starts again
+// from a fresh snapshot.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// BookDiff is the answer to GET /book/{symbol}/diff
+type BookDiff struct {
+	Symbol   string      `json:"symbol"`
+	Since    uint64      `json:"since"`
+	Sequence uint64      `json:"sequence"` // the last event included; since for the next diff
+	Events   []BookEvent `json:"events"`
+}
+
+// remember keeps published events for diffs, up to limit of them
+func (b *OrderBook) remember(events []BookEvent, limit int) {
+	if limit <= 0 {
+		return
+	}
+	b.history = append(b.history, events...)
+	if excess := len(b.history) - limit; excess > 0 {
+		b.history = b.history[excess:]
+	}
+}
+
+// diff returns the events after since, or false if they are no longer all
+// held
+func (b *OrderBook) diff(since uint64) ([]BookEvent, bool) {
+	if since > b.eventSeq {
+		return nil, false
+	}
+	events := []BookEvent{}
+	if since == b.eventSeq {
+		return events, true
+	}
+	if len(b.history) == 0 || b.history[0].Seq > since+1 {
+		return nil, false
+	}
+	for _, event := range b.history {
+		if event.Seq > since {
+			events = append(events, event)
+		}
+	}
+	return events, true
+}
+
+// handleBook serves the GET /book/{symbol}/... endpoints
+func (e *ExecutionEngine) handleBook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	symbol, view, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/book/"), "/")
+	if symbol == "" {
+		notFound(w)
+		return
+	}
+	symbol = e.canonicalSymbol(symbol)
+	switch view {
+	case "features":
+		e.handleBookFeatures(w, symbol)
+	case "snapshot":
+		e.handleBookSnapshot(w, symbol)
+	case "diff":
+		e.handleBookDiff(w, r, symbol)
+	default:
+		notFound(w)
+	}
+}
+
+// handleBookSnapshot serves GET /book/{symbol}/snapshot
+func (e *ExecutionEngine) handleBookSnapshot(w http.ResponseWriter, symbol string) {
+	book, ok := e.lookupBook(symbol)
+	var snap BookSnapshot
+	if ok {
+		book.mu.Lock()
+		snap = book.Snapshot()
+		book.mu.Unlock()
+	}
+	if !ok {
+		writeError(w, errCodeNotFound, "Unknown symbol")
+		return
+	}
+	json.NewEncoder(w).Encode(snap)
+}
+
+// handleBookDiff serves GET /book/{symbol}/diff?since=N
+func (e *ExecutionEngine) handleBookDiff(w http.ResponseWriter, r *http.Request, symbol string) {
+	since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+	if err != nil {
+		writeFieldError(w, "since", "Invalid since")
+		return
+	}
+
+	book, ok := e.lookupBook(symbol)
+	var (
+		events   []BookEvent
+		held     bool
+		sequence uint64
+	)
+	if ok {
+		book.mu.Lock()
+		events, held = book.diff(since)
+		sequence = book.eventSeq
+		book.mu.Unlock()
+	}
+
+	switch {
+	case !ok:
+		writeError(w, errCodeNotFound, "Unknown symbol")
+	case !held:
+		writeError(w, errCodeSnapshotRequired, "Events since "+strconv.FormatUint(since, 10)+" are no longer held; load a fresh snapshot")
+	default:
+		json.NewEncoder(w).Encode(BookDiff{Symbol: symbol, Since: since, Sequence: sequence, Events: events})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// getBook serves a GET of a /book path, decoding a 200 answer into out
+func getBook(t *testing.T, engine *ExecutionEngine, path string, out interface{}) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	engine.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code
+}
+
+func TestBookSnapshotThenDiffs(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.BookDiffHistory = 3
+	engine.executeOrder(context.Background(), limitOrder("s1", "AAPL", "sell", 100, 5))
+	engine.executeOrder(context.Background(), limitOrder("b1", "AAPL", "buy", 99, 5))
+
+	var snap BookSnapshot
+	if code := getBook(t, engine, "/book/AAPL/snapshot", &snap); code != http.StatusOK {
+		t.Fatalf("snapshot: got %d", code)
+	}
+	if len(snap.Asks) != 1 || len(snap.Bids) != 1 || snap.EventSequence != 2 {
+		t.Fatalf("snapshot = %+v, want one bid, one ask and event sequence 2", snap)
+	}
+
+	// Nothing has changed since the snapshot
+	var diff BookDiff
+	if code := getBook(t, engine, "/book/AAPL/diff?since=2", &diff); code != http.StatusOK || len(diff.Events) != 0 || diff.Sequence != 2 {
+		t.Fatalf("empty diff: got %d %+v", code, diff)
+	}
+
+	// A trade and a new order arrive as the events after the snapshot
+	engine.executeOrder(context.Background(), limitOrder("b2", "AAPL", "buy", 100, 2))
+	engine.executeOrder(context.Background(), limitOrder("s2", "AAPL", "sell", 101, 1))
+	if code := getBook(t, engine, "/book/AAPL/diff?since=2", &diff); code != http.StatusOK {
+		t.Fatalf("diff: got %d", code)
+	}
+	if diff.Sequence != 4 || len(diff.Events) != 2 ||
+		diff.Events[0].Type != bookEventExecute || diff.Events[0].OrderID != "s1" ||
+		diff.Events[1].Type != bookEventAdd || diff.Events[1].OrderID != "s2" {
+		t.Fatalf("diff since 2 = %+v, want s1 executed then s2 added", diff)
+	}
+	if code := getBook(t, engine, "/book/AAPL/diff?since=3", &diff); code != http.StatusOK || len(diff.Events) != 1 || diff.Events[0].Seq != 4 {
+		t.Fatalf("diff since 3: got %d %+v", code, diff)
+	}
+
+	// Only the last three events are held: event 1 is gone
+	if code := getBook(t, engine, "/book/AAPL/diff?since=0", &diff); code != http.StatusGone {
+		t.Errorf("diff from beyond the history: got %d, want 410", code)
+	}
+	if code := getBook(t, engine, "/book/AAPL/diff?since=9", &diff); code != http.StatusGone {
+		t.Errorf("diff from a sequence not reached yet: got %d, want 410", code)
+	}
+	if code := getBook(t, engine, "/book/AAPL/diff", &diff); code != http.StatusBadRequest {
+		t.Errorf("diff without since: got %d, want 400", code)
+	}
+	if code := getBook(t, engine, "/book/TSLA/snapshot", &snap); code != http.StatusNotFound {
+		t.Errorf("snapshot of an unknown symbol: got %d, want 404", code)
+	}
+}
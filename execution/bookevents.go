@@ -0,0 +1,96 @@
+// ==============================================================================
+// Book events - an L3 feed of every change to the books
+// ==============================================================================
+// Consumers that keep their own copy of the books read "<stream>.book.events",
+// one entry per change to a resting order, separate from the fill responses:
+//
+//   add      an order rested (or an iceberg showed its next slice, at the
+//            back of its level); quantity is what it displays
+//   modify   a pegged order moved to a new price, at the back of its level;
+//            quantity is what it displays
+//   cancel   an order left the book without trading
+//   execute  quantity of an order traded at its price; an order executed
+//            down to nothing has left the book
+//
+// Each book numbers its events from 1 with no gaps, so a consumer that sees a
+// jump in a symbol's seq has missed events and should resync: load the book
+// from "<stream>.book.snapshot", whose books carry the event_sequence they
+// include, and apply the events after it. The numbering survives restarts:
+// every journal entry records the book's sequence after it.
+//
+// Books record their events as they change, and the engine publishes them
+// when it journals the change, still holding the book's lock, so each book's
+// events are in order on the stream.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shopspring/decimal"
+)
+
+// Book event types
+const (
+	bookEventAdd     = "add"
+	bookEventModify  = "modify"
+	bookEventCancel  = "cancel"
+	bookEventExecute = "execute"
+)
+
+// BookEvent is one change to a resting order
+type BookEvent struct {
+	Seq       uint64          `json:"seq"` // per symbol, increasing by one
+	Type      string          `json:"type"`
+	Symbol    string          `json:"symbol"`
+	OrderID   string          `json:"order_id"`
+	Side      string          `json:"side"`
+	Price     decimal.Decimal `json:"price"`
+	Quantity  decimal.Decimal `json:"quantity"`  // displayed for add and modify, traded for execute
+	Timestamp int64           `json:"timestamp"` // unix ms
+}
+
+// record numbers and keeps an event about order, if the book records events
+func (b *OrderBook) record(eventType string, order *BookOrder, quantity decimal.Decimal) {
+	if !b.recordEvents {
+		return
+	}
+	b.eventSeq++
+	b.events = append(b.events, BookEvent{
+		Seq:      b.eventSeq,
+		Type:     eventType,
+		Symbol:   b.Symbol,
+		OrderID:  order.OrderID,
+		Side:     order.Side,
+		Price:    order.Price,
+		Quantity: quantity,
+	})
+}
+
+// publishBookEvents appends the events book has recorded to the book event
+// stream. Callers must hold the book's lock.
+func (e *ExecutionEngine) publishBookEvents(book *OrderBook) {
+	if len(book.events) == 0 {
+		return
+	}
+	now := e.now().UnixMilli()
+	pipe := e.redisClient.Pipeline()
+	for i := range book.events {
+		book.events[i].Timestamp = now
+		payload, _ := json.Marshal(&book.events[i])
+		pipe.XAdd(e.ctx, &redis.XAddArgs{
+			Stream: e.bookEventStream,
+			MaxLen: e.config.StreamMaxLen,
+			Approx: true,
+			Values: map[string]interface{}{"event": payload},
+		})
+	}
+	if _, err := pipe.Exec(e.ctx); err != nil {
+		log.Printf("Error publishing %d book events for %s: %v", len(book.events), book.Symbol, err)
+	}
+	book.remember(book.events, e.config.BookDiffHistory)
+	book.events = book.events[:0]
+}
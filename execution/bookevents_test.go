@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// bookEvents reads the whole book event stream
+func bookEvents(t *testing.T, engine *ExecutionEngine) []BookEvent {
+	t.Helper()
+	entries, err := engine.redisClient.XRange(engine.ctx, engine.bookEventStream, "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make([]BookEvent, len(entries))
+	for i, entry := range entries {
+		if err := json.Unmarshal([]byte(entry.Values["event"].(string)), &events[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return events
+}
+
+func TestBookEventsFollowEveryChange(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.executeOrder(context.Background(), limitOrder("s1", "AAPL", "sell", 100, 5))
+	engine.executeOrder(context.Background(), limitOrder("s2", "AAPL", "sell", 101, 5))
+	engine.executeOrder(context.Background(), limitOrder("b1", "AAPL", "buy", 100, 3))
+	book := engine.bookFor("AAPL")
+	book.mu.Lock()
+	engine.cancelResting(book, "s2")
+	book.mu.Unlock()
+	engine.executeOrder(context.Background(), icebergOrder("ice", "sell", 102, 10, 4))
+	// Takes the rest of s1, the iceberg's slice and one of its next
+	engine.executeOrder(context.Background(), limitOrder("b2", "AAPL", "buy", 102, 7))
+	engine.executeOrder(context.Background(), limitOrder("m1", "MSFT", "buy", 300, 1))
+
+	want := []string{
+		"AAPL 1 add s1 sell 100 5",
+		"AAPL 2 add s2 sell 101 5",
+		"AAPL 3 execute s1 sell 100 3",
+		"AAPL 4 cancel s2 sell 101 0",
+		"AAPL 5 add ice sell 102 4",
+		"AAPL 6 execute s1 sell 100 2",
+		"AAPL 7 execute ice sell 102 4",
+		"AAPL 8 add ice sell 102 4",
+		"AAPL 9 execute ice sell 102 1",
+		"MSFT 1 add m1 buy 300 1",
+	}
+	events := bookEvents(t, engine)
+	if len(events) != len(want) {
+		t.Fatalf("got %d events %+v, want %d", len(events), events, len(want))
+	}
+	for i, event := range events {
+		got := fmt.Sprintf("%s %d %s %s %s %s %s", event.Symbol, event.Seq, event.Type, event.OrderID, event.Side, event.Price, event.Quantity)
+		if got != want[i] {
+			t.Errorf("event %d = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestBookEventSequenceSurvivesRecovery(t *testing.T) {
+	engine, mr := newTestEngine(t)
+	ctx := context.Background()
+	engine.executeOrder(context.Background(), limitOrder("b1", "AAPL", "buy", 99, 10))
+	if err := engine.SnapshotBooks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	engine.executeOrder(context.Background(), limitOrder("b2", "AAPL", "buy", 98, 10))
+	engine.executeOrder(context.Background(), limitOrder("s1", "AAPL", "sell", 99, 4))
+
+	restored := NewExecutionEngine(mr.Host(), mr.Port(), "test-stream")
+	defer restored.redisClient.Close()
+	if err := restored.RecoverBooks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	restored.executeOrder(context.Background(), limitOrder("b3", "AAPL", "buy", 97, 1))
+
+	events := bookEvents(t, restored)
+	for i, event := range events {
+		if event.Seq != uint64(i+1) {
+			t.Fatalf("event %d has seq %d, want %d: %+v", i, event.Seq, i+1, events)
+		}
+	}
+	if last := events[len(events)-1]; last.OrderID != "b3" || last.Seq != 4 {
+		t.Errorf("first event after recovery = %+v, want b3's add as seq 4", last)
+	}
+}
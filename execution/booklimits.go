@@ -0,0 +1,123 @@
+// ==============================================================================
+// Book limits - bound how many orders rest in memory
+// ==============================================================================
+// BOOK_MAX_ORDERS caps resting orders across all books and
+// BOOK_MAX_ORDERS_PER_SYMBOL caps each book; zero means unlimited. When an
+// order would rest in a full book, BOOK_FULL_POLICY decides what happens:
+//
+//   reject        the order doesn't rest (the default); with nothing filled
+//                 it is rejected with "book_full", otherwise its unfilled
+//                 remainder is dropped and the reason noted on the response
+//   evict_oldest  the oldest orders in the order's own book are cancelled
+//                 with "book_full" to make room. If that book is empty and
+//                 only the global limit is hit, the order is refused as above.
+//
+// The number of resting orders per book is exported as a gauge.
+// ==============================================================================
+
+package main
+
+import (
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Policies for orders that would rest in a full book
+const (
+	bookFullReject      = "reject"
+	bookFullEvictOldest = "evict_oldest"
+)
+
+// rejectBookFull is the reason on orders refused or evicted by a book limit
+const rejectBookFull = "book_full"
+
+// restingOrderCount is the number of orders resting across all books. It
+// reads their sizes without locking them, so a caller holding one book's
+// lock doesn't wait for the others.
+func (e *ExecutionEngine) restingOrderCount() int {
+	total := 0
+	for _, book := range e.allBooks() {
+		total += book.Len()
+	}
+	return total
+}
+
+// bookFull reports whether another order would exceed a book limit.
+// Callers must hold the book's lock.
+func (e *ExecutionEngine) bookFull(book *OrderBook) bool {
+	limits := e.riskLimits()
+	if limit := limits.BookMaxOrdersPerSymbol; limit > 0 && len(book.orders) >= limit {
+		return true
+	}
+	if limit := limits.BookMaxOrders; limit > 0 && e.restingOrderCount() >= limit {
+		return true
+	}
+	return false
+}
+
+// makeRoom reports whether another order may rest in book, evicting its
+// oldest orders first under the evict_oldest policy. Callers must hold the
+// book's lock.
+func (e *ExecutionEngine) makeRoom(book *OrderBook) bool {
+	for e.bookFull(book) {
+		if e.riskLimits().BookFullPolicy != bookFullEvictOldest {
+			return false
+		}
+		oldest := book.Oldest()
+		if oldest == nil {
+			return false
+		}
+		log.Printf("Book %s full, evicting order %s", book.Symbol, oldest.OrderID)
+		book.Cancel(oldest.OrderID)
+		e.journal(book, bookMutation{Op: journalOpCancel, Symbol: book.Symbol, OrderID: oldest.OrderID})
+		e.updateCachedResponse(oldest.OrderID, statusCancelled, func(r *OrderResponse) {
+			r.RejectReason = rejectBookFull
+		})
+	}
+	return true
+}
+
+// Oldest returns the earliest-arrived resting order, or nil if the book is
+// empty
+func (b *OrderBook) Oldest() *BookOrder {
+	var oldest *BookOrder
+	for _, order := range b.orders {
+		if oldest == nil || order.Sequence < oldest.Sequence {
+			oldest = order
+		}
+	}
+	return oldest
+}
+
+// bookSizeCollector exports the resting order count of each book, computed
+// at scrape time
+type bookSizeCollector struct {
+	engine  *ExecutionEngine
+	resting *prometheus.Desc
+}
+
+func newBookSizeCollector(e *ExecutionEngine) *bookSizeCollector {
+	return &bookSizeCollector{
+		engine: e,
+		resting: prometheus.NewDesc("order_book_resting_orders",
+			"Number of orders resting in the book", []string{"symbol"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *bookSizeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.resting
+}
+
+// Collect implements prometheus.Collector
+func (c *bookSizeCollector) Collect(ch chan<- prometheus.Metric) {
+	sizes := make(map[string]int)
+	for _, book := range c.engine.allBooks() {
+		sizes[c.engine.symbolLabels.label(book.Symbol)] += book.Len()
+	}
+
+	for label, size := range sizes {
+		ch <- prometheus.MustNewConstMetric(c.resting, prometheus.GaugeValue, float64(size), label)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFullBookRejectsRestingOrders(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	setRiskLimits(engine, func(l *RiskLimits) {
+		l.BookMaxOrdersPerSymbol = 2
+		l.BookMaxOrders = 3
+	})
+
+	submitToEngine(t, engine, limitOrder("bid-1", "AAPL", "buy", 99, 10))
+	submitToEngine(t, engine, limitOrder("bid-2", "AAPL", "buy", 98, 10))
+	submitToEngine(t, engine, limitOrder("bid-3", "AAPL", "buy", 97, 10))
+	if response, _ := engine.GetOrder("bid-3"); response.Status != statusRejected || response.RejectReason != rejectBookFull {
+		t.Fatalf("order past the per-symbol limit: got %+v, want book_full rejection", response)
+	}
+
+	// The global limit applies across symbols
+	submitToEngine(t, engine, limitOrder("msft-1", "MSFT", "sell", 400, 5))
+	submitToEngine(t, engine, limitOrder("msft-2", "MSFT", "sell", 401, 5))
+	if status := restingStatus(t, engine, "msft-1"); status != statusWorking {
+		t.Fatalf("msft-1 status = %s, want working", status)
+	}
+	if response, _ := engine.GetOrder("msft-2"); response.Status != statusRejected || response.RejectReason != rejectBookFull {
+		t.Fatalf("order past the global limit: got %+v, want book_full rejection", response)
+	}
+
+	// With the books at the global limit after the fill, a partial fill
+	// keeps its fill but drops the remainder
+	setRiskLimits(engine, func(l *RiskLimits) { l.BookMaxOrders = 2 })
+	submitToEngine(t, engine, limitOrder("sell-1", "AAPL", "sell", 99, 15))
+	response, _ := engine.GetOrder("sell-1")
+	if response.Status != statusPartiallyFilled || !response.FilledQuantity.Equal(dec(10)) || response.RejectReason != rejectBookFull {
+		t.Fatalf("crossing order in a full book: got %+v", response)
+	}
+	if inBook(engine, "AAPL", "sell-1") {
+		t.Error("remainder rested in a full book")
+	}
+
+	expected := `
+# HELP order_book_resting_orders Number of orders resting in the book
+# TYPE order_book_resting_orders gauge
+order_book_resting_orders{symbol="AAPL"} 1
+order_book_resting_orders{symbol="MSFT"} 1
+`
+	if err := testutil.GatherAndCompare(engine.registry, strings.NewReader(expected), "order_book_resting_orders"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFullBookEvictsOldestOrders(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	setRiskLimits(engine, func(l *RiskLimits) {
+		l.BookMaxOrdersPerSymbol = 2
+		l.BookFullPolicy = bookFullEvictOldest
+	})
+
+	submitToEngine(t, engine, limitOrder("bid-1", "AAPL", "buy", 99, 10))
+	submitToEngine(t, engine, limitOrder("ask-1", "AAPL", "sell", 101, 10))
+	submitToEngine(t, engine, limitOrder("bid-2", "AAPL", "buy", 98, 10))
+
+	if status := restingStatus(t, engine, "bid-2"); status != statusWorking {
+		t.Fatalf("new order status = %s, want working", status)
+	}
+	evicted, _ := engine.GetOrder("bid-1")
+	if evicted.Status != statusCancelled || evicted.RejectReason != rejectBookFull {
+		t.Fatalf("oldest order should be evicted, got %+v", evicted)
+	}
+	if inBook(engine, "AAPL", "bid-1") || !inBook(engine, "AAPL", "ask-1") {
+		t.Error("eviction removed the wrong order")
+	}
+}
@@ -0,0 +1,243 @@
+// ==============================================================================
+// Broker adapters - where orders are actually executed
+// ==============================================================================
+// processOrder hands each order to a BrokerAdapter. The default adapter is the
+// built-in simulator (order book plus simulated fills); real venues plug in
+// behind the same interface. Adapters that only support some order types or
+// time-in-force values say so with Capabilities, and orders they can't take
+// are rejected before being sent. Every call is bounded by ORDER_TIMEOUT: an
+// order the adapter hasn't answered in time is reported as timed_out and
+// parked on the "<stream>.reconcile" stream, because the venue may still
+// execute it.
+//
+// Adapters that can tell when they are about to execute call
+// commitExecution first. Whichever comes first, the commit or the timeout,
+// decides the order: an adapter that committed in time is waited for, and
+// one that didn't is told to leave everything as it was. The simulator
+// commits once it holds the books it trades on, so a timed out order never
+// half-trades in them.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Reconciliation reasons for orders the adapter didn't answer in time
+const (
+	reconcileReasonTimeout = "execution_timeout"
+	reconcileReasonLate    = "late_execution"
+	reconcileReasonOrphan  = "orphaned_execution" // taken over mid-execution, see reclaim.go
+	rejectBrokerError      = "broker_error"
+)
+
+// BrokerAdapter executes orders against a venue. Implementations should
+// return promptly once ctx is done, though the engine doesn't rely on it.
+type BrokerAdapter interface {
+	Execute(ctx context.Context, order *OrderRequest) (*OrderResponse, error)
+}
+
+// rejectUnsupportedOrderType is the reason for orders the adapter can't take
+const rejectUnsupportedOrderType = "unsupported_order_type"
+
+// AdapterCapabilities lists the order types and time-in-force values a venue
+// accepts. An empty list means anything goes.
+type AdapterCapabilities struct {
+	OrderTypes  []string
+	TimeInForce []string
+}
+
+// CapableAdapter is implemented by adapters that only support some order
+// types or time-in-force values. Adapters without it are assumed to accept
+// everything.
+type CapableAdapter interface {
+	Capabilities() AdapterCapabilities
+}
+
+// supports checks an order against the capabilities, returning a rejection
+// for the first unsupported field
+func (c AdapterCapabilities) supports(order *OrderRequest) *rejection {
+	if len(c.OrderTypes) > 0 && !containsString(c.OrderTypes, order.Type) {
+		return &rejection{Reason: rejectUnsupportedOrderType, Detail: fmt.Sprintf("venue does not support %s orders", order.Type)}
+	}
+	if len(c.TimeInForce) > 0 && !containsString(c.TimeInForce, order.TimeInForce) {
+		return &rejection{Reason: rejectUnsupportedOrderType, Detail: fmt.Sprintf("venue does not support time in force %s", order.TimeInForce)}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCapabilities rejects orders the broker adapter has declared it can't
+// handle, before they are sent
+func (e *ExecutionEngine) checkCapabilities(order *OrderRequest) *rejection {
+	capable, ok := e.broker.(CapableAdapter)
+	if !ok {
+		return nil
+	}
+	return capable.Capabilities().supports(order)
+}
+
+// simulatorAdapter executes orders in-process against the engine's books
+type simulatorAdapter struct {
+	engine *ExecutionEngine
+}
+
+// Execute implements BrokerAdapter
+func (a simulatorAdapter) Execute(ctx context.Context, order *OrderRequest) (*OrderResponse, error) {
+	response := a.engine.executeOrder(ctx, order)
+	if response == nil {
+		return nil, ctx.Err()
+	}
+	return response, nil
+}
+
+// Capabilities implements CapableAdapter. The simulator ignores time in
+// force, so it accepts any.
+func (a simulatorAdapter) Capabilities() AdapterCapabilities {
+	return AdapterCapabilities{OrderTypes: []string{"market", "limit", "stop", orderTypeTrailingStop, "spread"}}
+}
+
+type brokerResult struct {
+	response *OrderResponse
+	err      error
+	panicked interface{} // recovered from the adapter, if it panicked
+}
+
+// unwrap returns the adapter's answer, re-raising its panic
+func (r brokerResult) unwrap() (*OrderResponse, error) {
+	if r.panicked != nil {
+		panic(r.panicked)
+	}
+	return r.response, r.err
+}
+
+// executionGate settles the race between an adapter executing an order and
+// the timeout waiting for it: only the first of commit and abandon succeeds
+type executionGate struct {
+	state atomic.Int32
+}
+
+const (
+	gateCommitted = 1
+	gateAbandoned = 2
+)
+
+func (g *executionGate) commit() bool {
+	return g.state.CompareAndSwap(0, gateCommitted) || g.state.Load() == gateCommitted
+}
+
+func (g *executionGate) abandon() bool {
+	return g.state.CompareAndSwap(0, gateAbandoned) || g.state.Load() == gateAbandoned
+}
+
+type executionGateKey struct{}
+
+// commitExecution is called by an adapter before it changes anything for the
+// order ctx belongs to. It reports false once the order has timed out, and
+// the adapter must then return without executing it.
+func commitExecution(ctx context.Context) bool {
+	gate, ok := ctx.Value(executionGateKey{}).(*executionGate)
+	if !ok {
+		return ctx.Err() == nil
+	}
+	return gate.commit()
+}
+
+// executeWithTimeout runs the order through the broker adapter, giving up
+// after the configured per-order timeout. A timed out order gets a timed_out
+// response and is routed for reconciliation.
+func (e *ExecutionEngine) executeWithTimeout(ctx context.Context, order *OrderRequest) (*OrderResponse, error) {
+	if e.config.OrderTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.config.OrderTimeout)
+		defer cancel()
+	}
+
+	gate := &executionGate{}
+	ctx = context.WithValue(ctx, executionGateKey{}, gate)
+
+	// Buffered so a late adapter never blocks once we stop listening
+	done := make(chan brokerResult, 1)
+	go func() {
+		// A panicking adapter is re-raised on the caller's goroutine,
+		// where the consumer can isolate it to this order
+		defer func() {
+			if r := recover(); r != nil {
+				done <- brokerResult{panicked: r}
+			}
+		}()
+		start := latencyStart()
+		response, err := e.broker.Execute(ctx, order)
+		e.observeBrokerLatency(start)
+		done <- brokerResult{response: response, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.unwrap()
+	case <-ctx.Done():
+	}
+	if !gate.abandon() {
+		// The adapter committed to the order in time: see it through
+		return (<-done).unwrap()
+	}
+
+	log.Printf("Order %s timed out after %s, routing for reconciliation", order.OrderID, e.config.OrderTimeout)
+	e.ordersTimedOut.Inc()
+	e.sendToReconciliation(order, reconcileReasonTimeout, nil)
+
+	// Whatever the adapter eventually reports has to be reconciled too
+	go func() {
+		result := <-done
+		if result.panicked != nil {
+			log.Printf("Broker adapter panicked on order %s after it timed out: %v", order.OrderID, result.panicked)
+		} else if result.err == nil && result.response != nil {
+			log.Printf("Order %s executed after timing out (status %s)", order.OrderID, result.response.Status)
+			e.sendToReconciliation(order, reconcileReasonLate, result.response)
+		}
+	}()
+
+	return &OrderResponse{
+		OrderID:       order.OrderID,
+		ClientOrderID: order.IdempotencyKey,
+		Symbol:        order.Symbol,
+		Status:        statusTimedOut,
+	}, nil
+}
+
+// sendToReconciliation records an order whose venue state is uncertain
+func (e *ExecutionEngine) sendToReconciliation(order *OrderRequest, reason string, response *OrderResponse) {
+	orderJSON, _ := json.Marshal(order)
+	values := map[string]interface{}{
+		"order":     orderJSON,
+		"reason":    reason,
+		"timestamp": e.now().UnixMilli(),
+	}
+	if response != nil {
+		responseJSON, _ := json.Marshal(response)
+		values["response"] = responseJSON
+	}
+
+	_, err := e.redisClient.XAdd(e.ctx, &redis.XAddArgs{
+		Stream: e.reconcileStreamName,
+		Values: values,
+	}).Result()
+	if err != nil {
+		log.Printf("Error routing order %s for reconciliation: %v", order.OrderID, err)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// slowAdapter doesn't answer until released
+type slowAdapter struct {
+	release chan struct{}
+}
+
+func (a slowAdapter) Execute(ctx context.Context, order *OrderRequest) (*OrderResponse, error) {
+	<-a.release
+	return &OrderResponse{OrderID: order.OrderID, Symbol: order.Symbol, Status: "filled", FilledQuantity: order.Quantity}, nil
+}
+
+type failingAdapter struct{}
+
+func (failingAdapter) Execute(ctx context.Context, order *OrderRequest) (*OrderResponse, error) {
+	return nil, errors.New("venue rejected connection")
+}
+
+func TestOrderTimesOutOnSlowAdapter(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	adapter := slowAdapter{release: make(chan struct{})}
+	engine.broker = adapter
+	engine.config.OrderTimeout = 20 * time.Millisecond
+
+	order := testOrder("slow-1")
+	submitToEngine(t, engine, &order)
+
+	response, ok := engine.GetOrder("slow-1")
+	if !ok || response.Status != statusTimedOut {
+		t.Fatalf("expected timed_out response, got %+v", response)
+	}
+	if got := testutil.ToFloat64(engine.ordersTimedOut); got != 1 {
+		t.Errorf("orders_timed_out_total = %v, want 1", got)
+	}
+
+	ctx := context.Background()
+	entries := engine.redisClient.XRange(ctx, engine.reconcileStreamName, "-", "+").Val()
+	if len(entries) != 1 || entries[0].Values["reason"] != reconcileReasonTimeout {
+		t.Fatalf("expected one timeout reconciliation entry, got %v", entries)
+	}
+
+	// The venue eventually fills it; that outcome is queued for reconciliation too
+	close(adapter.release)
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		entries = engine.redisClient.XRange(ctx, engine.reconcileStreamName, "-", "+").Val()
+		if len(entries) == 2 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(entries) != 2 || entries[1].Values["reason"] != reconcileReasonLate || entries[1].Values["response"] == nil {
+		t.Fatalf("expected late execution to be reconciled, got %v", entries)
+	}
+}
+
+func TestOrderWithinTimeoutExecutesNormally(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.OrderTimeout = time.Second
+
+	order := testOrder("fast-1")
+	submitToEngine(t, engine, &order)
+
+	if response, ok := engine.GetOrder("fast-1"); !ok || response.Status != "filled" {
+		t.Fatalf("expected filled response, got %+v", response)
+	}
+	if got := testutil.ToFloat64(engine.ordersTimedOut); got != 0 {
+		t.Errorf("orders_timed_out_total = %v, want 0", got)
+	}
+}
+
+func TestBrokerErrorRejectsOrder(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.broker = failingAdapter{}
+
+	order := testOrder("err-1")
+	submitToEngine(t, engine, &order)
+
+	response, ok := engine.GetOrder("err-1")
+	if !ok || response.Status != "rejected" || response.RejectReason != rejectBrokerError {
+		t.Fatalf("expected broker_error rejection, got %+v", response)
+	}
+}
+
+// limitedAdapter is a venue without stop orders or FOK
+type limitedAdapter struct {
+	countingAdapter
+}
+
+func (a *limitedAdapter) Capabilities() AdapterCapabilities {
+	return AdapterCapabilities{
+		OrderTypes:  []string{"market", "limit"},
+		TimeInForce: []string{"day", "gtc", "ioc"},
+	}
+}
+
+func stopOrder(id string) *OrderRequest {
+	return &OrderRequest{OrderID: id, Symbol: "AAPL", Side: "sell", Quantity: dec(10), Type: "stop", StopPrice: dec(95), LimitPrice: dec(95), TimeInForce: "day"}
+}
+
+func TestUnsupportedOrderTypeRejectedBeforeSending(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	adapter := &limitedAdapter{}
+	engine.broker = adapter
+
+	submitToEngine(t, engine, stopOrder("stop-1"))
+	fok := testOrder("fok-1")
+	fok.TimeInForce = "fok"
+	submitToEngine(t, engine, &fok)
+
+	for _, id := range []string{"stop-1", "fok-1"} {
+		response, ok := engine.GetOrder(id)
+		if !ok || response.Status != "rejected" || response.RejectReason != rejectUnsupportedOrderType {
+			t.Errorf("%s: expected unsupported_order_type rejection, got %+v", id, response)
+		}
+	}
+	if got := adapter.executions.Load(); got != 0 {
+		t.Errorf("unsupported orders reached the adapter %d times", got)
+	}
+}
+
+func TestCapableAdapterAcceptsStopOrder(t *testing.T) {
+	engine, _ := newTestEngine(t)
+
+	submitToEngine(t, engine, stopOrder("stop-2"))
+
+	if response, ok := engine.GetOrder("stop-2"); !ok || response.Status == "rejected" {
+		t.Fatalf("simulator should accept stop orders, got %+v", response)
+	}
+}
+
+// committingAdapter commits to each order and then answers only after the
+// order's timeout has passed
+type committingAdapter struct{}
+
+func (committingAdapter) Execute(ctx context.Context, order *OrderRequest) (*OrderResponse, error) {
+	if !commitExecution(ctx) {
+		return nil, ctx.Err()
+	}
+	<-ctx.Done()
+	return &OrderResponse{OrderID: order.OrderID, Symbol: order.Symbol, Status: statusFilled, FilledQuantity: order.Quantity}, nil
+}
+
+func TestAdapterThatCommittedInTimeIsWaitedFor(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.broker = committingAdapter{}
+	engine.config.OrderTimeout = 10 * time.Millisecond
+
+	order := testOrder("committed")
+	submitToEngine(t, engine, &order)
+	if s := restingStatus(t, engine, "committed"); s != statusFilled {
+		t.Errorf("status %s, want filled", s)
+	}
+	if n := engine.redisClient.XLen(context.Background(), engine.reconcileStreamName).Val(); n != 0 {
+		t.Errorf("%d orders sent for reconciliation", n)
+	}
+}
+
+func TestTimeoutRacingASimulatedFill(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.latencyModel = NewNormalLatency(2*time.Millisecond, time.Millisecond, rand.New(rand.NewSource(1)))
+	engine.config.OrderTimeout = 2 * time.Millisecond
+	engine.circuit = newCircuitBreaker(1000, time.Minute, prometheus.NewRegistry())
+
+	// Each round a buy races its timeout to an ask resting in its own symbol:
+	// it either trades for both accounts or leaves the ask alone
+	var timedOut []string
+	for i := 0; i < 40; i++ {
+		symbol := fmt.Sprintf("SYM%d", i)
+		ask := limitOrder("ask-"+symbol, symbol, "sell", 100, 10)
+		ask.AccountID = "acct-maker"
+		engine.orderCache.Store(ask.OrderID, engine.executeOrder(context.Background(), ask))
+		bid := limitOrder("bid-"+symbol, symbol, "buy", 100, 10)
+		bid.AccountID = "acct-taker"
+		submitToEngine(t, engine, bid)
+
+		maker, _ := engine.positions.Get("acct-maker", symbol)
+		taker, _ := engine.positions.Get("acct-taker", symbol)
+		switch s := restingStatus(t, engine, bid.OrderID); s {
+		case statusFilled:
+			if inBook(engine, symbol, ask.OrderID) || !maker.Quantity.Equal(dec(-10)) || !taker.Quantity.Equal(dec(10)) {
+				t.Errorf("%s filled: ask resting %v, positions %s/%s", symbol, inBook(engine, symbol, ask.OrderID), maker.Quantity, taker.Quantity)
+			}
+		case statusTimedOut:
+			timedOut = append(timedOut, symbol)
+		default:
+			t.Fatalf("%s: status %s", symbol, s)
+		}
+	}
+
+	// Timed out orders never trade, however late
+	time.Sleep(20 * time.Millisecond)
+	for _, symbol := range timedOut {
+		maker, _ := engine.positions.Get("acct-maker", symbol)
+		if !inBook(engine, symbol, "ask-"+symbol) || !maker.Quantity.IsZero() {
+			t.Errorf("%s timed out but traded: ask resting %v, maker position %s", symbol, inBook(engine, symbol, "ask-"+symbol), maker.Quantity)
+		}
+	}
+	t.Logf("%d of 40 orders timed out", len(timedOut))
+}
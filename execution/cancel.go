@@ -0,0 +1,173 @@
+// ==============================================================================
+// Cancels - single orders and mass cancel
+// ==============================================================================
+// CancelOrder pulls one working order. POST /orders/cancel-all takes an
+// optional symbol and account and cancels all matching resting orders that
+// have rested the minimum time (see minrest.go). Each book is swept under its
+// lock, so no incoming order can match against a book while it is half
+// cancelled. Each cancelled order gets a "cancelled" update
+// through the fill sinks like any other.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// CancelFilter selects the orders a mass cancel applies to. Empty fields
+// match everything.
+type CancelFilter struct {
+	Symbol    string `json:"symbol,omitempty"`
+	AccountID string `json:"account_id,omitempty"`
+}
+
+// matches reports whether a resting order falls under the filter
+func (f CancelFilter) matches(order *BookOrder) bool {
+	return f.AccountID == "" || order.AccountID == f.AccountID
+}
+
+// CancelAll cancels every resting order matching filter and returns the IDs
+// of the cancelled orders
+func (e *ExecutionEngine) CancelAll(filter CancelFilter) []string {
+	var books []*OrderBook
+	for _, book := range e.allBooks() {
+		if filter.Symbol == "" || book.Symbol == filter.Symbol {
+			books = append(books, book)
+		}
+	}
+	sort.Slice(books, func(i, j int) bool { return books[i].Symbol < books[j].Symbol })
+
+	var cancelled []string
+	for _, book := range books {
+		book.mu.Lock()
+		for _, order := range book.Orders() {
+			if !filter.matches(order) || e.restTimeLeft(book.Symbol, order) > 0 {
+				continue
+			}
+			e.cancelResting(book, order.OrderID)
+			cancelled = append(cancelled, order.OrderID)
+		}
+		e.repeg(book)
+		book.mu.Unlock()
+	}
+
+	if len(cancelled) > 0 {
+		log.Printf("Mass cancel (symbol=%q account=%q) cancelled %d orders", filter.Symbol, filter.AccountID, len(cancelled))
+	}
+	return cancelled
+}
+
+// Errors returned by CancelOrder
+var (
+	errOrderNotFound   = errors.New("order not found")
+	errOrderNotWorking = errors.New("order is not working")
+	errNotOrderOwner   = errors.New("order belongs to another account")
+)
+
+// CancelOrder cancels one working order on a client's behalf. A non-empty
+// account must own it, and it must have rested the minimum time.
+func (e *ExecutionEngine) CancelOrder(orderID string, account string) (*OrderResponse, error) {
+	return e.cancelOrder(orderID, account, true)
+}
+
+// cancelOrder cancels one working order, holding back orders that haven't
+// rested the minimum time if enforceRestTime is set
+func (e *ExecutionEngine) cancelOrder(orderID string, account string, enforceRestTime bool) (*OrderResponse, error) {
+	if a := e.twaps.get(orderID); a != nil {
+		return e.cancelTWAP(a, account)
+	}
+	response, ok := e.GetOrder(orderID)
+	if !ok {
+		return nil, errOrderNotFound
+	}
+	if response.Status == statusHeld {
+		if err := e.cancelHeld(orderID, account); err != nil {
+			return nil, err
+		}
+		response, _ = e.GetOrder(orderID)
+		return response, nil
+	}
+
+	book, ok := e.lookupBook(response.Symbol)
+	if !ok {
+		return nil, errOrderNotWorking
+	}
+	book.mu.Lock()
+	resting, ok := book.Get(orderID)
+	if !ok {
+		book.mu.Unlock()
+		return nil, errOrderNotWorking
+	}
+	if account != "" && resting.AccountID != account {
+		book.mu.Unlock()
+		return nil, errNotOrderOwner
+	}
+	if wait := e.restTimeLeft(book.Symbol, resting); enforceRestTime && wait > 0 {
+		book.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s to go", errMinRestTime, wait)
+	}
+	e.cancelResting(book, orderID)
+	e.repeg(book)
+	book.mu.Unlock()
+
+	response, _ = e.GetOrder(orderID)
+	return response, nil
+}
+
+// cancelResting removes an order from book and reports it cancelled.
+// Callers must hold the book's lock.
+func (e *ExecutionEngine) cancelResting(book *OrderBook, orderID string) {
+	book.Cancel(orderID)
+	e.journal(book, bookMutation{Op: journalOpCancel, Symbol: book.Symbol, OrderID: orderID})
+	e.updateCachedResponse(orderID, statusCancelled, func(r *OrderResponse) {})
+}
+
+// Orders returns the resting orders, bids then asks, each in priority order
+func (b *OrderBook) Orders() []*BookOrder {
+	orders := make([]*BookOrder, 0, len(b.orders))
+	for _, levels := range [][]*priceLevel{b.bids, b.asks} {
+		for _, level := range levels {
+			orders = append(orders, level.orders...)
+		}
+	}
+	return orders
+}
+
+// handleCancelAll serves POST /orders/cancel-all. Callers authenticated with
+// an API key can only cancel their own account's orders.
+func (e *ExecutionEngine) handleCancelAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+
+	var filter CancelFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, errCodeInvalidRequest, "Invalid request")
+		return
+	}
+
+	if account, ok := accountFromContext(r.Context()); ok {
+		if filter.AccountID != "" && filter.AccountID != account {
+			writeError(w, errCodeForbidden, "Cannot cancel another account's orders")
+			return
+		}
+		filter.AccountID = account
+	}
+
+	cancelled := e.CancelAll(filter)
+	if cancelled == nil {
+		cancelled = []string{}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cancelled": len(cancelled),
+		"order_ids": cancelled,
+	})
+}
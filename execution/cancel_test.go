@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCancelAllOnlyCancelsFilteredOrders(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	sink := newMockSink("test", 0)
+	engine.fills = newFillDispatcher(context.Background(), []FillSink{sink}, newFillSinkMetrics(prometheus.NewRegistry()))
+
+	seed := []struct {
+		id, symbol, side, account string
+		price                     float64
+	}{
+		{"aapl-1-bid", "AAPL", "buy", "acct-1", 99},
+		{"aapl-1-ask", "AAPL", "sell", "acct-1", 101},
+		{"aapl-2-bid", "AAPL", "buy", "acct-2", 98},
+		{"msft-1-bid", "MSFT", "buy", "acct-1", 300},
+		{"msft-2-ask", "MSFT", "sell", "acct-2", 310},
+	}
+	for _, s := range seed {
+		order := limitOrder(s.id, s.symbol, s.side, s.price, 10)
+		order.AccountID = s.account
+		submitToEngine(t, engine, order)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/orders/cancel-all", strings.NewReader(`{"symbol":"AAPL","account_id":"acct-1"}`))
+	rec := httptest.NewRecorder()
+	engine.routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Cancelled int `json:"cancelled"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Cancelled != 2 {
+		t.Fatalf("expected 2 cancelled, got %+v (%v)", body, err)
+	}
+
+	cancelled := map[string]bool{"aapl-1-bid": true, "aapl-1-ask": true}
+	for _, s := range seed {
+		status := restingStatus(t, engine, s.id)
+		if cancelled[s.id] {
+			if status != "cancelled" || inBook(engine, s.symbol, s.id) {
+				t.Errorf("%s: expected cancelled and out of the book, got %s", s.id, status)
+			}
+		} else if status != "working" || !inBook(engine, s.symbol, s.id) {
+			t.Errorf("%s: expected still working, got %s", s.id, status)
+		}
+	}
+
+	engine.fills.close()
+	published := 0
+	for _, r := range sink.delivered {
+		if r.Status == "cancelled" {
+			if !cancelled[r.OrderID] {
+				t.Errorf("unexpected cancel update for %s", r.OrderID)
+			}
+			published++
+		}
+	}
+	if published != len(cancelled) {
+		t.Errorf("published %d cancel updates, want %d", published, len(cancelled))
+	}
+}
+
+func TestCancelAllScopedToAuthenticatedAccount(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.apiKeys = map[string]string{"key-1": "acct-1"}
+	handler := engine.routes()
+
+	for _, account := range []string{"acct-1", "acct-2"} {
+		order := limitOrder("bid-"+account, "AAPL", "buy", 99, 10)
+		order.AccountID = account
+		submitToEngine(t, engine, order)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/orders/cancel-all", strings.NewReader(`{"account_id":"acct-2"}`))
+	req.Header.Set(apiKeyHeader, "key-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("cancelling another account: expected 403, got %d", rec.Code)
+	}
+
+	// An empty body cancels everything the key's account owns
+	req = httptest.NewRequest(http.MethodPost, "/orders/cancel-all", nil)
+	req.Header.Set(apiKeyHeader, "key-1")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if inBook(engine, "AAPL", "bid-acct-1") || !inBook(engine, "AAPL", "bid-acct-2") {
+		t.Error("mass cancel was not scoped to the authenticated account")
+	}
+}
@@ -0,0 +1,147 @@
+// ==============================================================================
+// Circuit breaker - stop sending orders to a broker that keeps failing
+// ==============================================================================
+// Every adapter error, timeout or panic counts as a failure. After
+// BROKER_FAILURE_THRESHOLD consecutive failures the breaker opens and orders
+// are rejected straight away with "broker_unavailable" and parked in the DLQ,
+// where they can be replayed once the venue is back. After BROKER_OPEN_TIMEOUT
+// the breaker half-opens and lets a single probe order through: success closes
+// it again, failure re-opens it for another timeout.
+//
+// State is exported as broker_circuit_state (0 closed, 1 half-open, 2 open).
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// rejectBrokerUnavailable is the reason for orders refused by an open breaker
+const rejectBrokerUnavailable = "broker_unavailable"
+
+// Circuit breaker states, in the order they are exported
+const (
+	circuitClosed = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+var circuitStateNames = []string{"closed", "half_open", "open"}
+
+// circuitBreaker tracks the health of the broker adapter
+type circuitBreaker struct {
+	threshold   int
+	openTimeout time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	state    int
+	failures int       // consecutive failures while closed
+	openedAt time.Time // when the breaker last opened
+	probing  bool      // a half-open probe is in flight
+
+	stateGauge prometheus.Gauge
+}
+
+func newCircuitBreaker(threshold int, openTimeout time.Duration, registry *prometheus.Registry) *circuitBreaker {
+	b := &circuitBreaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		now:         time.Now,
+		stateGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "broker_circuit_state",
+			Help: "Broker adapter circuit breaker state: 0 closed, 1 half-open, 2 open",
+		}),
+	}
+	registry.MustRegister(b.stateGauge)
+	return b
+}
+
+// allow reports whether an order may be sent to the broker. Once the open
+// timeout has passed it half-opens and admits exactly one probe.
+func (b *circuitBreaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.setState(circuitHalfOpen)
+		b.probing = true
+		return true
+	case circuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// success records a broker call that completed
+func (b *circuitBreaker) success() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+	if b.state != circuitClosed {
+		b.setState(circuitClosed)
+	}
+}
+
+// failure records a broker call that errored or timed out
+func (b *circuitBreaker) failure() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	b.failures++
+	if b.state == circuitHalfOpen || (b.state == circuitClosed && b.failures >= b.threshold) {
+		b.openedAt = b.now()
+		b.setState(circuitOpen)
+	}
+}
+
+// executeGuarded runs an order admitted by the breaker through the broker.
+// An adapter panic counts as a failure before it is passed on, or a panicking
+// half-open probe would leave the breaker probing for good.
+func (e *ExecutionEngine) executeGuarded(ctx context.Context, order *OrderRequest) (*OrderResponse, error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e.circuit.failure()
+			panic(r)
+		}
+	}()
+	return e.executeWithTimeout(ctx, order)
+}
+
+// currentState returns the breaker state
+func (b *circuitBreaker) currentState() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// setState moves the breaker to state. Callers must hold mu.
+func (b *circuitBreaker) setState(state int) {
+	log.Printf("Broker circuit breaker %s -> %s", circuitStateNames[b.state], circuitStateNames[state])
+	b.state = state
+	b.stateGauge.Set(float64(state))
+}
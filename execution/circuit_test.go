@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func submitNext(t *testing.T, e *ExecutionEngine, id string) *OrderResponse {
+	t.Helper()
+	order := testOrder(id)
+	submitToEngine(t, e, &order)
+	response, ok := e.GetOrder(id)
+	if !ok {
+		t.Fatalf("no response for %s", id)
+	}
+	return response
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.circuit = newCircuitBreaker(3, time.Minute, prometheus.NewRegistry())
+	engine.circuit.now = clock.Now
+	simulator := engine.broker
+	engine.broker = failingAdapter{}
+
+	// Closed: failures reach the adapter until the threshold is hit
+	for i := 0; i < 3; i++ {
+		if r := submitNext(t, engine, fmt.Sprintf("fail-%d", i)); r.RejectReason != rejectBrokerError {
+			t.Fatalf("order %d: expected broker_error, got %+v", i, r)
+		}
+	}
+	if engine.circuit.currentState() != circuitOpen {
+		t.Fatalf("breaker should be open after 3 failures")
+	}
+	if got := testutil.ToFloat64(engine.circuit.stateGauge); got != circuitOpen {
+		t.Errorf("broker_circuit_state = %v, want %d", got, circuitOpen)
+	}
+
+	// Open: orders are refused without touching the adapter and dead-lettered
+	engine.broker = simulator
+	if r := submitNext(t, engine, "refused"); r.RejectReason != rejectBrokerUnavailable {
+		t.Fatalf("expected broker_unavailable, got %+v", r)
+	}
+	entries := engine.redisClient.XRange(context.Background(), engine.dlqStreamName, "-", "+").Val()
+	if len(entries) != 1 || entries[0].Values["reason"] != rejectBrokerUnavailable {
+		t.Fatalf("expected refused order in the DLQ, got %v", entries)
+	}
+
+	// Half-open: a failed probe re-opens the breaker
+	clock.advance(time.Minute)
+	engine.broker = failingAdapter{}
+	if r := submitNext(t, engine, "probe-1"); r.RejectReason != rejectBrokerError {
+		t.Fatalf("probe should reach the adapter, got %+v", r)
+	}
+	if engine.circuit.currentState() != circuitOpen {
+		t.Fatalf("failed probe should re-open the breaker")
+	}
+
+	// Half-open: a successful probe closes it
+	clock.advance(time.Minute)
+	engine.broker = simulator
+	if r := submitNext(t, engine, "probe-2"); r.Status != "filled" {
+		t.Fatalf("probe should execute, got %+v", r)
+	}
+	if engine.circuit.currentState() != circuitClosed {
+		t.Fatalf("successful probe should close the breaker")
+	}
+	if got := testutil.ToFloat64(engine.circuit.stateGauge); got != circuitClosed {
+		t.Errorf("broker_circuit_state = %v, want %d", got, circuitClosed)
+	}
+}
+
+func TestCircuitBreakerAdmitsOneProbe(t *testing.T) {
+	clock := newFakeClock()
+	breaker := newCircuitBreaker(1, time.Second, prometheus.NewRegistry())
+	breaker.now = clock.Now
+
+	breaker.failure()
+	if breaker.allow() {
+		t.Fatal("open breaker admitted an order")
+	}
+
+	clock.advance(time.Second)
+	if !breaker.allow() {
+		t.Fatal("breaker should admit a probe after the open timeout")
+	}
+	if breaker.currentState() != circuitHalfOpen {
+		t.Fatalf("state = %s, want half_open", circuitStateNames[breaker.currentState()])
+	}
+	if breaker.allow() {
+		t.Error("half-open breaker admitted a second order while probing")
+	}
+}
+
+func TestPanickingProbeReopensTheBreaker(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.circuit = newCircuitBreaker(1, time.Minute, prometheus.NewRegistry())
+	engine.circuit.now = clock.Now
+	simulator := engine.broker
+	engine.broker = failingAdapter{}
+	submitNext(t, engine, "fail")
+
+	clock.advance(time.Minute)
+	engine.broker = &panickingAdapter{poison: "probe-1"}
+	order := testOrder("probe-1")
+	payload, _ := json.Marshal(&order)
+	engine.handleMessage(redis.XMessage{ID: "0-1", Values: map[string]interface{}{"order": string(payload)}})
+	if state := engine.circuit.currentState(); state != circuitOpen {
+		t.Fatalf("state after the probe panicked = %s, want open", circuitStateNames[state])
+	}
+
+	clock.advance(time.Minute)
+	engine.broker = simulator
+	if r := submitNext(t, engine, "probe-2"); r.Status != statusFilled {
+		t.Fatalf("next probe should execute, got %+v", r)
+	}
+	if engine.circuit.currentState() != circuitClosed {
+		t.Errorf("successful probe should close the breaker")
+	}
+}
+
+func TestRiskRejectedProbeDoesNotWedgeTheBreaker(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.circuit = newCircuitBreaker(1, time.Minute, prometheus.NewRegistry())
+	engine.circuit.now = clock.Now
+	simulator := engine.broker
+	engine.broker = failingAdapter{}
+	submitNext(t, engine, "fail")
+	if engine.circuit.currentState() != circuitOpen {
+		t.Fatal("breaker should be open after the failure")
+	}
+
+	// Once the timeout passes, the first order is refused by a risk check
+	// before it reaches the broker, so it mustn't use up the probe
+	clock.advance(time.Minute)
+	engine.broker = simulator
+	setRiskLimits(engine, func(l *RiskLimits) { l.MaxOrderQuantity = dec(5) })
+	if r := submitNext(t, engine, "too-large"); r.RejectReason != rejectOrderTooLarge {
+		t.Fatalf("expected %s, got %+v", rejectOrderTooLarge, r)
+	}
+
+	setRiskLimits(engine, func(l *RiskLimits) { l.MaxOrderQuantity = dec(0) })
+	if r := submitNext(t, engine, "good"); r.Status != statusFilled {
+		t.Fatalf("order after the risk-rejected one should probe and fill, got %+v", r)
+	}
+	if engine.circuit.currentState() != circuitClosed {
+		t.Errorf("successful probe should close the breaker")
+	}
+}
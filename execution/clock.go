@@ -0,0 +1,35 @@
+// ==============================================================================
+// Clock - the engine's source of the current time
+// ==============================================================================
+// Everything that reads the time - timestamps, latency breakdowns, held order
+// activation, halts, the circuit breaker, the rejection audit TTL and the
+// metrics window - asks the engine's Clock, so tests can substitute a fake
+// one and drive time-dependent behavior to the exact millisecond. Waiting
+// (tickers, timeouts, backoff, simulated venue latency) still uses real time,
+// and so does timing latency histograms, which want a monotonic reading (see
+// latencyStart in matchlatency.go).
+// ==============================================================================
+
+package main
+
+import "time"
+
+// Clock tells the time
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the real wall clock
+type systemClock struct{}
+
+// Now implements Clock
+func (systemClock) Now() time.Time { return time.Now() }
+
+// now is the current time on the engine's clock, or the wall clock if it
+// has none
+func (e *ExecutionEngine) now() time.Time {
+	if e.clock == nil {
+		return time.Now()
+	}
+	return e.clock.Now()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced time source
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Unix(1700000000, 0)}
+}
+
+// Now implements Clock
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func TestFakeClockActivatesHeldOrderAtItsTime(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+
+	order := testOrder("gat-1")
+	order.ActivateAt = clock.Now().Add(5 * time.Second).UnixMilli()
+	submitToEngine(t, engine, &order)
+
+	clock.advance(5*time.Second - time.Millisecond)
+	engine.activateDue()
+	if status := restingStatus(t, engine, "gat-1"); status != statusHeld {
+		t.Fatalf("status a millisecond before activation = %s, want held", status)
+	}
+
+	clock.advance(time.Millisecond)
+	engine.activateDue()
+	response, _ := engine.GetOrder("gat-1")
+	if response.Status != statusFilled {
+		t.Fatalf("status at the activation time = %s, want filled", response.Status)
+	}
+	if response.AcknowledgedAt != order.ActivateAt {
+		t.Errorf("acknowledged at %d, want the simulated activation time %d", response.AcknowledgedAt, order.ActivateAt)
+	}
+}
+
+// clockAdvancingAdapter advances a fake clock as if the venue took delay to answer
+type clockAdvancingAdapter struct {
+	next  BrokerAdapter
+	clock *fakeClock
+	delay time.Duration
+}
+
+func (a clockAdvancingAdapter) Execute(ctx context.Context, order *OrderRequest) (*OrderResponse, error) {
+	a.clock.advance(a.delay)
+	return a.next.Execute(ctx, order)
+}
+
+func TestLatencyIsMeasuredOnTheEngineClock(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+	engine.broker = clockAdvancingAdapter{next: engine.broker, clock: clock, delay: 7 * time.Millisecond}
+
+	order := testOrder("slow-1")
+	submitToEngine(t, engine, &order)
+
+	response, _ := engine.GetOrder("slow-1")
+	if response.LatencyMs != 7 || response.Latency.BrokerMs != 7 || response.Latency.ValidationMs != 0 {
+		t.Errorf("latency %vms, breakdown %+v; want exactly the 7ms spent at the venue", response.LatencyMs, response.Latency)
+	}
+}
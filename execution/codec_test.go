@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var codecTestOrder = OrderRequest{
+	OrderID:        "codec-1",
+	Symbol:         "AAPL",
+	Side:           "buy",
+	Quantity:       dec(100.5),
+	Type:           "limit",
+	LimitPrice:     dec(189.125),
+	TimeInForce:    "day",
+	IdempotencyKey: "key-1",
+	Timestamp:      1700000000000,
+	AccountID:      "acct-1",
+	MinFillRatio:   0.5,
+	PostOnly:       true,
+}
+
+var codecTestResponse = OrderResponse{
+	OrderID:        "codec-1",
+	ClientOrderID:  "key-1",
+	Symbol:         "AAPL",
+	Status:         statusPartiallyFilled,
+	FilledQuantity: dec(40),
+	FilledAvgPrice: dec(189.1),
+	LatencyMs:      0.25,
+	Fills:          []BookFill{{RestingOrderID: "rest-1", RestingSequence: 7, Price: dec(189.1), Quantity: dec(40), Liquidity: liquidityTaker}},
+	Latency:        &LatencyBreakdown{QueueWaitMs: 0.1, TotalMs: 0.25},
+	Commission:     dec(0.2),
+	LiquidityFlag:  liquidityTaker,
+}
+
+// sameAsJSON compares two values by their JSON encoding, which normalises
+// decimals with different internal representations
+func sameAsJSON(t *testing.T, got, want interface{}) bool {
+	t.Helper()
+	a, _ := json.Marshal(got)
+	b, _ := json.Marshal(want)
+	return bytes.Equal(a, b)
+}
+
+// streamField looks up field in a miniredis stream entry's field/value list
+func streamField(values []string, field string) string {
+	for i := 0; i+1 < len(values); i += 2 {
+		if values[i] == field {
+			return values[i+1]
+		}
+	}
+	return ""
+}
+
+func TestPayloadCodecsRoundTrip(t *testing.T) {
+	for _, codec := range []PayloadCodec{JSONCodec{}, MsgpackCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			data, err := codec.Marshal(codecTestOrder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var order OrderRequest
+			if err := codec.Unmarshal(data, &order); err != nil {
+				t.Fatal(err)
+			}
+			if !sameAsJSON(t, order, codecTestOrder) {
+				t.Errorf("order round trip changed it:\n got %+v\nwant %+v", order, codecTestOrder)
+			}
+
+			data, err = codec.Marshal(&codecTestResponse)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var response OrderResponse
+			if err := codec.Unmarshal(data, &response); err != nil {
+				t.Fatal(err)
+			}
+			if !sameAsJSON(t, response, codecTestResponse) {
+				t.Errorf("response round trip changed it:\n got %+v\nwant %+v", response, codecTestResponse)
+			}
+		})
+	}
+
+	if _, err := newPayloadCodec("xml"); err == nil {
+		t.Error("expected an error for an unknown codec")
+	}
+}
+
+func TestMsgpackOrdersThroughStream(t *testing.T) {
+	engine, mr := newTestEngine(t)
+	engine.codec = MsgpackCodec{}
+
+	order := testOrder("packed-1")
+	if err := engine.SubmitOrder(context.Background(), &order); err != nil {
+		t.Fatal(err)
+	}
+	invalid := OrderRequest{OrderID: "packed-bad", Symbol: "AAPL", Side: "hold"}
+	if err := engine.SubmitOrder(context.Background(), &invalid); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := mr.Stream("test-stream")
+	if len(entries) != 2 || streamField(entries[0].Values, payloadEncodingField) != codecMsgpack {
+		t.Fatalf("expected entries tagged %q, got %+v", codecMsgpack, entries)
+	}
+
+	drainOrderStream(t, engine)
+	if response, ok := engine.GetOrder("packed-1"); !ok || response.Status != statusFilled {
+		t.Fatalf("msgpack order was not executed: %+v", response)
+	}
+
+	// The dead-lettered copy keeps its encoding so a replay decodes it
+	dlq, _ := mr.Stream("test-stream.dlq")
+	if len(dlq) != 1 {
+		t.Fatalf("expected the invalid order in the DLQ, got %+v", dlq)
+	}
+	if streamField(dlq[0].Values, payloadEncodingField) != codecMsgpack {
+		t.Errorf("DLQ entry lost its encoding: %v", dlq[0].Values)
+	}
+}
+
+func TestUnknownEncodingDeadLettered(t *testing.T) {
+	engine, mr := newTestEngine(t)
+	engine.processOrder(redis.XMessage{ID: "0-1", Values: map[string]interface{}{"order": "{}", payloadEncodingField: "xml"}})
+	if dlq, _ := mr.Stream("test-stream.dlq"); len(dlq) != 1 {
+		t.Fatalf("expected the entry in the DLQ, got %+v", dlq)
+	}
+}
+
+// BenchmarkPayloadCodecs compares an order round trip through each codec
+func BenchmarkPayloadCodecs(b *testing.B) {
+	order := codecTestOrder
+	order.Timestamp = time.Now().UnixMilli()
+	for _, codec := range []PayloadCodec{JSONCodec{}, MsgpackCodec{}} {
+		b.Run(codec.Name(), func(b *testing.B) {
+			var decoded OrderRequest
+			for i := 0; i < b.N; i++ {
+				data, err := codec.Marshal(order)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := codec.Unmarshal(data, &decoded); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
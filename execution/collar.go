@@ -0,0 +1,77 @@
+// ==============================================================================
+// Price collars - stop market orders running through a thin book
+// ==============================================================================
+// A market order sweeps the book at any price, so against thin liquidity it
+// can fill far from where the symbol trades. PRICE_COLLAR_PCT sets how far
+// from the reference price (the last trade, else the seeded price; see
+// marketdata.go) a market order may fill, as a percentage, and PRICE_COLLARS
+// overrides it per symbol as JSON, e.g. {"AAPL":2,"BTCUSD":5}; 0 leaves a
+// symbol uncollared. A buy fills up to reference * (1 + pct/100), a sell down
+// to reference * (1 - pct/100).
+//
+// A collared order fills what it can inside the collar and the rest is
+// cancelled with reason "price_collar"; if nothing fills, the order is
+// rejected with that reason. Triggered stops execute as market orders and
+// are collared the same way. The collars are risk limits and reload with
+// them (see reload.go).
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// rejectPriceCollar is the reason on market orders stopped by their collar
+const rejectPriceCollar = "price_collar"
+
+// parsePriceCollars decodes the PRICE_COLLARS config, a JSON object of
+// symbol to collar percentage
+func parsePriceCollars(raw string) (map[string]float64, error) {
+	collars := map[string]float64{}
+	if raw == "" {
+		return collars, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &collars); err != nil {
+		return nil, err
+	}
+	for symbol, pct := range collars {
+		if pct < 0 {
+			return nil, fmt.Errorf("negative collar %v for %s", pct, symbol)
+		}
+	}
+	return collars, nil
+}
+
+// priceCollar is the collar percentage that applies to symbol
+func (l *RiskLimits) priceCollar(symbol string) float64 {
+	if pct, ok := l.PriceCollars[symbol]; ok {
+		return pct
+	}
+	return l.PriceCollarPct
+}
+
+// collarPrice returns the worst price a market order may fill at, or false
+// if the order isn't collared
+func (e *ExecutionEngine) collarPrice(order *OrderRequest) (decimal.Decimal, bool) {
+	if order.Type != "market" {
+		return decimal.Zero, false
+	}
+	pct := e.riskLimits().priceCollar(order.Symbol)
+	if pct <= 0 {
+		return decimal.Zero, false
+	}
+	reference := e.prices.reference(order.Symbol)
+	band := reference.Mul(decimal.NewFromFloat(pct)).Div(decimal.NewFromInt(100))
+	if order.Side == "buy" {
+		return reference.Add(band), true
+	}
+	// A collar of 100% or more leaves a sell no floor
+	if floor := reference.Sub(band); floor.IsPositive() {
+		return floor, true
+	}
+	return decimal.Zero, false
+}
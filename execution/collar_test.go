@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestPriceCollarStopsMarketSweep(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	setRiskLimits(engine, func(l *RiskLimits) { l.PriceCollars = map[string]float64{"AAPL": 5} })
+
+	// Reference 100, so buys may fill up to 105
+	seedAsk(t, engine, "ask-1", "AAPL", 100, 5)
+	seedAsk(t, engine, "ask-2", "AAPL", 104, 5)
+	seedAsk(t, engine, "ask-3", "AAPL", 150, 10)
+
+	buy := testOrder("sweep")
+	buy.Quantity = dec(20)
+	submitToEngine(t, engine, &buy)
+
+	response, _ := engine.GetOrder("sweep")
+	if response.Status != statusCancelled || response.RejectReason != rejectPriceCollar {
+		t.Fatalf("sweep through the collar: %s/%s, want cancelled with %s", response.Status, response.RejectReason, rejectPriceCollar)
+	}
+	if !response.FilledQuantity.Equal(dec(10)) {
+		t.Errorf("filled %s, want the 10 inside the collar", response.FilledQuantity)
+	}
+	if s := restingStatus(t, engine, "ask-3"); s != statusWorking {
+		t.Errorf("ask outside the collar: status %s, want untouched", s)
+	}
+
+	// Nothing left inside the collar around the last trade at 104
+	again := testOrder("again")
+	submitToEngine(t, engine, &again)
+	if response, _ := engine.GetOrder("again"); response.Status != statusRejected || response.RejectReason != rejectPriceCollar {
+		t.Errorf("market buy with no liquidity in the collar: %s/%s, want %s rejection", response.Status, response.RejectReason, rejectPriceCollar)
+	}
+}
+
+func TestPriceCollarOnlyAppliesToCollaredSymbols(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	setRiskLimits(engine, func(l *RiskLimits) {
+		l.PriceCollarPct = 5
+		l.PriceCollars = map[string]float64{"MSFT": 0}
+	})
+	seedAsk(t, engine, "ask-1", "MSFT", 100, 5)
+	seedAsk(t, engine, "ask-2", "MSFT", 150, 5)
+
+	buy := testOrder("sweep")
+	buy.Symbol = "MSFT"
+	submitToEngine(t, engine, &buy)
+	if response, _ := engine.GetOrder("sweep"); response.Status != statusFilled || !response.FilledQuantity.Equal(dec(10)) {
+		t.Errorf("uncollared symbol: %s %s, want filled 10", response.Status, response.FilledQuantity)
+	}
+
+	// The default collar covers every other symbol
+	seedAsk(t, engine, "ask-3", "AAPL", 100, 5)
+	seedAsk(t, engine, "ask-4", "AAPL", 150, 5)
+	dry := testOrder("dry")
+	if response := engine.DryRun(dry); !response.FilledQuantity.Equal(dec(5)) {
+		t.Errorf("dry run under the default collar estimated %s, want 5", response.FilledQuantity)
+	}
+}
@@ -0,0 +1,116 @@
+// ==============================================================================
+// Payload compression - keep large orders small on the stream
+// ==============================================================================
+// Orders with many legs or fat tags make for large stream entries, which cost
+// Redis memory and bandwidth for as long as the stream keeps them. With
+// STREAM_COMPRESSION set to "gzip" or "snappy", order payloads larger than
+// STREAM_COMPRESSION_THRESHOLD bytes (8KiB by default) are compressed before
+// they are added to the stream. Smaller payloads, most orders, are left as
+// they are, since compressing them costs more than it saves.
+//
+// Compressed entries name the algorithm in a "compression" field next to
+// "encoding", and the consumer decompresses whatever it is given, so
+// producers can compress independently of the engine's setting. The
+// MAX_PAYLOAD_BYTES limit applies to the payload both as stored and once
+// decompressed. Orders parked in the DLQ are kept decompressed where they
+// can be, and otherwise keep their "compression" field, which DLQ replay
+// decompresses by.
+// ==============================================================================
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/golang/snappy"
+)
+
+// Compression algorithms, as configured and as tagged on stream entries
+const (
+	compressionNone   = "none"
+	compressionGzip   = "gzip"
+	compressionSnappy = "snappy"
+)
+
+// payloadCompressionField is the stream entry field naming the algorithm its
+// payload was compressed with
+const payloadCompressionField = "compression"
+
+// errDecompressedTooLarge is returned for payloads that decompress past the
+// payload limit
+var errDecompressedTooLarge = errors.New("decompressed payload too large")
+
+// validCompression reports whether name is a STREAM_COMPRESSION value
+func validCompression(name string) bool {
+	switch name {
+	case "", compressionNone, compressionGzip, compressionSnappy:
+		return true
+	}
+	return false
+}
+
+// compressPayload compresses a payload over the threshold with the
+// configured algorithm, returning the algorithm it used, if any
+func (e *ExecutionEngine) compressPayload(payload []byte) ([]byte, string, error) {
+	algorithm := e.config.StreamCompression
+	if algorithm == "" || algorithm == compressionNone || len(payload) <= e.config.StreamCompressionThreshold {
+		return payload, "", nil
+	}
+	switch algorithm {
+	case compressionGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(payload); err != nil {
+			return nil, "", err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), algorithm, nil
+	case compressionSnappy:
+		return snappy.Encode(nil, payload), algorithm, nil
+	}
+	return nil, "", fmt.Errorf("unknown compression %q", algorithm)
+}
+
+// decompressPayload returns a stream entry's payload as its codec wrote it,
+// refusing to decompress more than limit bytes (0 for no limit)
+func decompressPayload(values map[string]interface{}, payload string, limit int64) (string, error) {
+	algorithm, _ := values[payloadCompressionField].(string)
+	switch algorithm {
+	case "", compressionNone:
+		return payload, nil
+	case compressionGzip:
+		zr, err := gzip.NewReader(bytes.NewReader([]byte(payload)))
+		if err != nil {
+			return "", err
+		}
+		var r io.Reader = zr
+		if limit > 0 {
+			r = io.LimitReader(zr, limit+1)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return "", err
+		}
+		if limit > 0 && int64(len(data)) > limit {
+			return "", errDecompressedTooLarge
+		}
+		return string(data), nil
+	case compressionSnappy:
+		n, err := snappy.DecodedLen([]byte(payload))
+		if err != nil {
+			return "", err
+		}
+		if limit > 0 && int64(n) > limit {
+			return "", errDecompressedTooLarge
+		}
+		data, err := snappy.Decode(nil, []byte(payload))
+		return string(data), err
+	}
+	return "", fmt.Errorf("unknown compression %q", algorithm)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// taggedOrder is a market order whose tags make it about 3.5KB of JSON
+func taggedOrder(id string) *OrderRequest {
+	order := testOrder(id)
+	order.Tags = map[string]string{}
+	for i := 0; i < maxOrderTags; i++ {
+		order.Tags[fmt.Sprintf("note-%d", i)] = strings.Repeat(fmt.Sprint(i%10), 200)
+	}
+	return &order
+}
+
+// lastEntry returns the fields of the newest order stream entry
+func lastEntry(t *testing.T, engine *ExecutionEngine) map[string]interface{} {
+	t.Helper()
+	entries, err := engine.redisClient.XRevRangeN(context.Background(), engine.streamName, "+", "-", 1).Result()
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("reading the stream: %v", err)
+	}
+	return entries[0].Values
+}
+
+func TestCompressedPayloadsRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{compressionGzip, compressionSnappy} {
+		t.Run(algorithm, func(t *testing.T) {
+			engine, _ := newTestEngine(t)
+			engine.config.StreamCompression = algorithm
+			engine.config.StreamCompressionThreshold = 1024
+			queueBatch(t, engine)
+
+			large := taggedOrder("large")
+			if err := engine.SubmitOrder(context.Background(), large); err != nil {
+				t.Fatal(err)
+			}
+			values := lastEntry(t, engine)
+			raw, _ := engine.payloadCodec().Marshal(large)
+			if values[payloadCompressionField] != algorithm || len(values["order"].(string)) >= len(raw) {
+				t.Errorf("%d byte payload stored as %d bytes with compression %v, want it compressed with %s", len(raw), len(values["order"].(string)), values[payloadCompressionField], algorithm)
+			}
+
+			small := testOrder("small")
+			if err := engine.SubmitOrder(context.Background(), &small); err != nil {
+				t.Fatal(err)
+			}
+			if values := lastEntry(t, engine); values[payloadCompressionField] != nil {
+				t.Errorf("small payload compressed with %v", values[payloadCompressionField])
+			}
+
+			engine.consumeBatch()
+			response, _ := engine.GetOrder("large")
+			if response == nil || response.Status != statusFilled || response.Tags["note-15"] != large.Tags["note-15"] {
+				t.Errorf("large order after decompression: %+v", response)
+			}
+			if response, _ := engine.GetOrder("small"); response == nil || response.Status != statusFilled {
+				t.Errorf("small order: %+v", response)
+			}
+		})
+	}
+}
+
+func TestDecompressionIsBoundedByThePayloadLimit(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.MaxPayloadBytes = 4096
+	engine.config.StreamCompression = compressionGzip
+	queueBatch(t, engine)
+
+	// A few kilobytes compressed, a megabyte decompressed
+	bomb, _, err := engine.compressPayload([]byte(`{"order_id":"bomb","pad":"` + strings.Repeat("x", 1<<20) + `"}`))
+	if err != nil || len(bomb) > 4096 {
+		t.Fatalf("compressed to %d bytes, %v", len(bomb), err)
+	}
+	engine.redisClient.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: engine.streamName,
+		Values: map[string]interface{}{"order": string(bomb), payloadCompressionField: compressionGzip},
+	})
+	engine.consumeBatch()
+
+	entries := engine.redisClient.XRange(context.Background(), engine.dlqStreamName, "-", "+").Val()
+	if len(entries) != 1 || entries[0].Values["reason"] != dlqReasonPayloadTooLarge {
+		t.Errorf("DLQ = %+v, want the order parked as %s", entries, dlqReasonPayloadTooLarge)
+	}
+}
+
+func TestCompressedOrdersReplayFromTheDLQ(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.StreamCompression = compressionGzip
+	engine.config.StreamCompressionThreshold = 1024
+	simulator := engine.broker
+	engine.broker = &panickingAdapter{poison: "large"}
+	queueBatch(t, engine)
+	ctx := context.Background()
+
+	// A compressed order that panics is parked decompressed
+	if err := engine.SubmitOrder(ctx, taggedOrder("large")); err != nil {
+		t.Fatal(err)
+	}
+	engine.consumeBatch()
+	entries := engine.redisClient.XRange(ctx, engine.dlqStreamName, "-", "+").Val()
+	if len(entries) != 1 || entries[0].Values[payloadCompressionField] != nil || !strings.HasPrefix(entries[0].Values["order"].(string), "{") {
+		t.Fatalf("DLQ = %+v, want the panicking order parked decompressed", entries)
+	}
+
+	// One parked still compressed is decompressed by replay
+	raw, _ := json.Marshal(taggedOrder("parked"))
+	compressed, algorithm, _ := engine.compressPayload(raw)
+	engine.redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: engine.dlqStreamName,
+		Values: map[string]interface{}{"order": string(compressed), payloadCompressionField: algorithm, "reason": dlqReasonPanic},
+	})
+
+	engine.broker = simulator
+	summary, err := engine.ReplayDLQ(ctx, DLQFilter{})
+	if err != nil || summary.Replayed != 2 {
+		t.Fatalf("replay = %+v, %v, want both orders replayed", summary, err)
+	}
+	engine.consumeBatch()
+	for _, id := range []string{"large", "parked"} {
+		if response, ok := engine.GetOrder(id); !ok || response.Status != statusFilled {
+			t.Errorf("%s after replay: %+v, want filled", id, response)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// ==============================================================================
+// Conditional orders - hold an order until another symbol's price is reached
+// ==============================================================================
+// An order with a condition such as "VIX < 15" is validated when it is
+// consumed, then held off the book like a stop until the condition's symbol
+// last traded at a price meeting it: above (>), at or above (>=), below (<)
+// or at or below (<=) the level. It then executes as the market or limit
+// order it is. The condition is checked as soon as the order arrives and
+// whenever its symbol's last price moves - after every order that trades in
+// it and every replayed tick (see marketdata.go). A symbol that has neither
+// traded nor been seeded with a reference price meets no condition. The
+// triggered order is handed to the actor of its own symbol (see actors.go),
+// so a trade in VIX doesn't execute SPY orders on VIX's actor.
+//
+// Waiting conditional orders are "held". Their payloads share
+// "<stream>.held.orders" with good-after-time orders and stops, indexed by
+// "<stream>.conditions.<op>.<symbol>" sorted sets scored by level, so they
+// survive a restart and are cancelled the same way.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shopspring/decimal"
+)
+
+// priceCondition is a comparison of a symbol's last price with a level
+type priceCondition struct {
+	Symbol string
+	Op     string
+	Level  decimal.Decimal
+}
+
+// conditionPattern matches "<symbol> <op> <level>", spaces optional
+var conditionPattern = regexp.MustCompile(`^\s*([A-Za-z0-9._/-]+)\s*(>=|<=|>|<)\s*(\S+)\s*$`)
+
+// conditionOps names the comparisons in index keys
+var conditionOps = map[string]string{">": "gt", ">=": "ge", "<": "lt", "<=": "le"}
+
+// parseCondition parses a condition such as "VIX < 15"
+func parseCondition(raw string) (priceCondition, error) {
+	m := conditionPattern.FindStringSubmatch(raw)
+	if m == nil {
+		return priceCondition{}, fmt.Errorf("invalid condition %q, want e.g. \"VIX < 15\"", raw)
+	}
+	level, err := decimal.NewFromString(m[3])
+	if err != nil || !level.IsPositive() {
+		return priceCondition{}, fmt.Errorf("condition %q: level must be a positive number", raw)
+	}
+	return priceCondition{Symbol: m[1], Op: m[2], Level: level}, nil
+}
+
+func (c priceCondition) String() string {
+	return c.Symbol + " " + c.Op + " " + c.Level.String()
+}
+
+// conditionKey is the sorted set indexing the orders waiting on one kind of
+// comparison with symbol's price
+func (e *ExecutionEngine) conditionKey(op string, symbol string) string {
+	return e.keyPrefix + ".conditions." + conditionOps[op] + "." + symbol
+}
+
+// holdConditional holds an order until its condition is met, triggering it
+// at once if it already is
+func (e *ExecutionEngine) holdConditional(order *OrderRequest) error {
+	cond, err := parseCondition(order.Condition)
+	if err != nil {
+		return err // validated already
+	}
+	cond.Symbol = e.canonicalSymbol(cond.Symbol)
+	order.Condition = cond.String()
+	level, _ := cond.Level.Float64()
+	if held, err := e.holdOrderIn(order, e.conditionKey(cond.Op, cond.Symbol), level); err != nil || !held {
+		return err
+	}
+	log.Printf("Order %s held until %s", order.OrderID, order.Condition)
+	e.triggerConditionals(order.Symbol, cond.Symbol)
+	return nil
+}
+
+// triggerConditionals executes the orders waiting on symbol's price whose
+// condition its last price meets. Each executes on the actor of its own
+// symbol; actor is the symbol whose actor is calling, if any.
+func (e *ExecutionEngine) triggerConditionals(actor string, symbol string) {
+	price, ok := e.prices.known(symbol)
+	if !ok {
+		return
+	}
+	last := price.String()
+	for _, cmp := range []struct {
+		op      string
+		trigger *redis.ZRangeBy
+	}{
+		{">", &redis.ZRangeBy{Min: "-inf", Max: "(" + last}},
+		{">=", &redis.ZRangeBy{Min: "-inf", Max: last}},
+		{"<", &redis.ZRangeBy{Min: "(" + last, Max: "+inf"}},
+		{"<=", &redis.ZRangeBy{Min: last, Max: "+inf"}},
+	} {
+		index := e.conditionKey(cmp.op, symbol)
+		triggered, err := e.redisClient.ZRangeByScore(e.ctx, index, cmp.trigger).Result()
+		if err != nil {
+			log.Printf("Error reading orders conditioned on %s: %v", symbol, err)
+			continue
+		}
+		for _, orderID := range triggered {
+			e.triggerConditional(actor, index, orderID, last)
+		}
+	}
+}
+
+// triggerConditional executes one order whose condition was met
+func (e *ExecutionEngine) triggerConditional(actor string, index string, orderID string, last string) {
+	payload, ok, err := e.unholdOrder(e.ctx, index, orderID)
+	if err != nil || !ok {
+		if err != nil {
+			log.Printf("Error triggering conditional order %s: %v", orderID, err)
+		}
+		return // cancelled meanwhile
+	}
+
+	var order OrderRequest
+	if err := json.Unmarshal([]byte(payload), &order); err != nil {
+		log.Printf("Discarding undecodable conditional order %s: %v", orderID, err)
+		return
+	}
+	condition := order.Condition
+	order.Condition = ""
+	data, err := json.Marshal(&order)
+	if err != nil {
+		return
+	}
+
+	log.Printf("Conditional order %s triggered (%s, last %s)", orderID, condition, last)
+	e.runOnActor(actor, e.canonicalSymbol(order.Symbol), func() {
+		if err := e.handleMessage(redis.XMessage{ID: "cond:" + orderID, Values: map[string]interface{}{"order": string(data)}}); err != nil {
+			log.Printf("Error executing triggered conditional order %s: %v", orderID, err)
+		}
+	})
+}
+
+// priceMoved executes the held orders a new last price in symbol may have
+// set off: its stops, then the orders conditioned on it. actor is the symbol
+// whose actor is calling, or empty if none is; orders of other symbols are
+// handed to their own actors rather than executed on it.
+func (e *ExecutionEngine) priceMoved(actor string, symbol string) {
+	e.triggerStops(actor, symbol)
+	e.triggerConditionals(actor, symbol)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+)
+
+// tick records a last price in symbol as a market data tick would
+func tick(engine *ExecutionEngine, symbol string, price float64) {
+	engine.prices.record(symbol, dec(price))
+	engine.priceMoved("", symbol)
+}
+
+func TestConditionalOrderActivatesOnReferenceSymbolTick(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	tick(engine, "VIX", 18)
+
+	order := testOrder("spy-if-calm")
+	order.Symbol = "SPY"
+	order.Condition = "VIX < 15"
+	submitToEngine(t, engine, &order)
+	if status := restingStatus(t, engine, "spy-if-calm"); status != statusHeld {
+		t.Fatalf("status with VIX at 18 = %s, want held", status)
+	}
+
+	tick(engine, "VIX", 15) // not below 15 yet
+	if status := restingStatus(t, engine, "spy-if-calm"); status != statusHeld {
+		t.Fatalf("status with VIX at 15 = %s, want held", status)
+	}
+	tick(engine, "SPY", 50) // other symbols don't matter
+	tick(engine, "VIX", 14.5)
+	response, _ := engine.GetOrder("spy-if-calm")
+	if response.Status != statusFilled || !response.FilledAvgPrice.Equal(dec(50)) {
+		t.Errorf("after VIX ticked to 14.5: %s at %s, want filled at SPY's 50", response.Status, response.FilledAvgPrice)
+	}
+}
+
+func TestConditionalOrderMetOnArrivalExecutesAtOnce(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	tick(engine, "VIX", 30)
+	order := testOrder("hedge")
+	order.Condition = "vix >= 30"
+	submitToEngine(t, engine, &order)
+	if status := restingStatus(t, engine, "hedge"); status != statusFilled {
+		t.Errorf("status = %s, want filled", status)
+	}
+
+	// A symbol without a price meets nothing
+	order = testOrder("unknown")
+	order.Condition = "NOPE > 1"
+	submitToEngine(t, engine, &order)
+	if status := restingStatus(t, engine, "unknown"); status != statusHeld {
+		t.Errorf("status conditioned on an unpriced symbol = %s, want held", status)
+	}
+}
+
+func TestCancelConditionalOrder(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	order := testOrder("cond-1")
+	order.Condition = "VIX > 40"
+	submitToEngine(t, engine, &order)
+	if _, err := engine.CancelOrder("cond-1", ""); err != nil {
+		t.Fatal(err)
+	}
+	tick(engine, "VIX", 45)
+	if status := restingStatus(t, engine, "cond-1"); status != statusCancelled {
+		t.Errorf("status = %s, want cancelled", status)
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	cond, err := parseCondition(" VIX<=15.5 ")
+	if err != nil || cond.String() != "VIX <= 15.5" {
+		t.Errorf("parseCondition = %v, %v", cond, err)
+	}
+	for _, raw := range []string{"VIX", "VIX == 15", "VIX < abc", "VIX < -1", "< 15"} {
+		if _, err := parseCondition(raw); err == nil {
+			t.Errorf("parseCondition(%q) accepted", raw)
+		}
+	}
+	order := testOrder("c")
+	order.Type, order.StopPrice, order.Condition = "stop", dec(100), "VIX < 15"
+	if err := validateOrder(&order); err == nil {
+		t.Error("conditional stop order accepted")
+	}
+}
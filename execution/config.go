@@ -0,0 +1,552 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+// Config holds the tunable settings of the execution engine
+type Config struct {
+	RedisHost  string
+	RedisPort  string
+	StreamName string
+	HTTPPort   string
+
+	// Port of the gRPC API; empty disables it
+	GRPCPort string
+
+	// Port of the FIX acceptor (empty disables it), and the CompID clients
+	// address it by
+	FIXPort   string
+	FIXCompID string
+
+	// This replica's index (0-1023), embedded in the order IDs it generates;
+	// replicas sharing a stream need distinct ones
+	InstanceID int
+
+	// Comma-separated Redis Cluster seed addresses; when set they replace
+	// RedisHost/RedisPort and derived keys are hash-tagged
+	RedisClusterAddrs string
+
+	// Timeout of individual Redis calls
+	RedisTimeout time.Duration
+
+	// Connections the Redis client may open (per node in a cluster), and
+	// how many it keeps open while idle
+	RedisPoolSize     int
+	RedisMinIdleConns int
+
+	// How long Start keeps retrying an unreachable Redis before failing
+	RedisStartupTimeout time.Duration
+
+	// How long resting orders keep trading after /admin/drain before the
+	// engine stops
+	DrainGracePeriod time.Duration
+
+	// Where to simulate a crash processing the first order (before_publish
+	// or before_ack), to test recovery; never set in production
+	SimulateCrash string
+
+	// Name of this engine in the consumer group, unique per replica; empty
+	// uses INSTANCE_ID when set, else the hostname
+	ConsumerName string
+
+	// How long an order may sit unacknowledged with another consumer before
+	// this engine takes it over (see reclaim.go), and how often it looks; 0
+	// never takes over other consumers' orders
+	ReclaimMinIdle time.Duration
+
+	// Where streams and state live: redis, or memory for an in-process
+	// store that needs no Redis server
+	Transport string
+
+	// How consumed orders are executed: per_symbol runs each symbol's
+	// orders in order on its own goroutine, symbols in parallel; serial runs
+	// a batch one order at a time
+	MatchingMode string
+
+	// Order in which consumed orders are executed: fifo, in stream order,
+	// or fair, round-robin across accounts by weight (see fairness.go);
+	// ACCOUNT_WEIGHTS is a JSON object of account to weight
+	SchedulingMode       string
+	AccountWeights       string
+	DefaultAccountWeight int
+
+	// Approximate MAXLEN the order stream is trimmed to (0 disables), and
+	// the consumer group backlog at which submissions get 503 (0 disables)
+	StreamMaxLen       int64
+	StreamBacklogLimit int64
+
+	// Encoding of order payloads and published updates: json or msgpack
+	StreamCodec string
+
+	// Compression of order payloads over StreamCompressionThreshold bytes:
+	// none, gzip or snappy
+	StreamCompression          string
+	StreamCompressionThreshold int
+
+	// Largest HTTP request body or order message accepted, in bytes (0
+	// disables the limit)
+	MaxPayloadBytes int64
+
+	// Whether order responses served over HTTP list their individual fills
+	// (a request's ?fills= overrides it)
+	ResponseFills bool
+
+	// JSON or CSV file of resting orders to seed the books with at startup
+	BookSeedFile string
+
+	// Recording of ticks and orders to replay instead of serving, how fast
+	// to replay it relative to real time (0 = unpaced), and where to write
+	// the fill log (stdout when empty)
+	ReplayFile   string
+	ReplaySpeed  float64
+	ReplayOutput string
+
+	// How often held good-after-time orders are checked for activation
+	ActivationSweepInterval time.Duration
+
+	// How often running TWAP and VWAP algos are checked for slices that are
+	// due
+	AlgoSweepInterval time.Duration
+
+	// The volume curve VWAPs follow when they don't bring their own, as a
+	// JSON array of the volume expected in each slice, e.g. [30,20,20,30]
+	VWAPVolumeCurve string
+
+	// Publish attempts per order update and sink, the backoff before the
+	// first retry (doubling after each), and how often updates parked after
+	// exhausting them are redelivered
+	FillSinkMaxAttempts    int
+	FillSinkBackoff        time.Duration
+	FillRedeliveryInterval time.Duration
+
+	// How long partial fill updates of an order are held back and merged
+	// into one before publishing; 0 publishes every one
+	FillAggregationWindow time.Duration
+
+	// Whether POST /orders answers once an order is queued (async) or waits
+	// for its execution outcome (sync), and how long it waits
+	OrderAckMode    string
+	OrderAckTimeout time.Duration
+
+	// How long rejected orders are kept in the audit trail (0 keeps them)
+	RejectionAuditTTL time.Duration
+
+	// API keys as "key:account,key:account", and an optional Redis hash of
+	// key -> account consulted for keys not in the list. Authentication is
+	// off when neither is set.
+	APIKeys         string
+	APIKeysRedisKey string
+
+	// Namespace of idempotency keys: "account" (keys only collide within an
+	// account) or "global"
+	IdempotencyScope string
+
+	// HMAC key signing the receipts returned for queued orders; empty
+	// disables receipts
+	ReceiptKey string
+
+	// How long the broker adapter may take to execute one order before it is
+	// reported as timed out and routed for reconciliation (0 disables)
+	OrderTimeout time.Duration
+
+	// How old an order's timestamp may be when it is picked up (0 disables
+	// the check), and how far ahead of the engine's clock it may be
+	MaxOrderAge       time.Duration
+	MaxOrderClockSkew time.Duration
+
+	// Consecutive broker failures (errors or timeouts) that open the circuit
+	// breaker (0 disables it), and how long it stays open before a probe
+	BrokerFailureThreshold int
+	BrokerOpenTimeout      time.Duration
+
+	// Sliding window of the fill-success and rejection ratio gauges
+	MetricsWindow time.Duration
+
+	// How often GET /stats/stream pushes a snapshot to its subscribers (0
+	// disables it)
+	StatsStreamInterval time.Duration
+
+	// Symbols that keep their own label on per-symbol metrics, and the
+	// orders after which any other symbol earns one (0: listed only); the
+	// rest are labeled "other". Neither set labels every symbol.
+	MetricsSymbols         string
+	MetricsSymbolMinOrders int
+
+	// Share of orders rejected over the metrics window above which all
+	// trading halts until reset (0 disables), and the fewest orders in the
+	// window for it to count
+	AutoBreakerRejectRatio float64
+	AutoBreakerMinOrders   int
+
+	// Upper bounds of the order quantity and notional histogram buckets,
+	// comma-separated; powers of ten when empty
+	OrderQuantityBuckets string
+	OrderNotionalBuckets string
+
+	// How often resting orders are snapshotted to Redis (0 disables)
+	BookSnapshotInterval time.Duration
+
+	// Book events each book keeps for GET /book/{symbol}/diff
+	BookDiffHistory int
+
+	// Order responses kept in memory, least recently used evicted first
+	// (0: no bound); evicted orders are read from the order store
+	OrderCacheSize int
+
+	// OTLP/HTTP collector URL for trace export; tracing is off when empty
+	OTLPEndpoint string
+
+	// Per-symbol starting prices for simulated market fills as JSON, e.g.
+	// {"AAPL":190.5}; the last trade takes over once a symbol trades
+	ReferencePrices string
+
+	// Simulated venue latency: model is zero, fixed or normal. SimLatency is
+	// the fixed delay or the mean, SimLatencyJitter the standard deviation.
+	SimLatencyModel  string
+	SimLatency       time.Duration
+	SimLatencyJitter time.Duration
+	// Seed for everything random in the simulator, so runs can be reproduced;
+	// 0 seeds from the time
+	SimSeed int64
+
+	// How market prints fill resting limit orders in the simulator: none,
+	// touch, probability (each with SimFillProbability) or queue
+	SimFillModel       string
+	SimFillProbability float64
+
+	// Per-symbol tick/lot sizes as JSON, and whether off-grid orders are
+	// rounded or rejected
+	Instruments      string
+	InstrumentPolicy string
+
+	// Maximum resting orders across all books and per book (0 = unlimited),
+	// and what to do when a limit is hit: reject or evict_oldest
+	BookMaxOrders          int
+	BookMaxOrdersPerSymbol int
+	BookFullPolicy         string
+
+	// Maximum orders one account may have resting at once (0 = unlimited)
+	MaxOpenOrdersPerAccount int
+
+	// Largest quantity one order may have, as a decimal string (empty or 0
+	// = unlimited)
+	MaxOrderQuantity string
+
+	// Most notional one account may execute in any rolling NotionalWindow,
+	// as a decimal string (empty or 0 = unlimited)
+	MaxNotionalPerWindow string
+	NotionalWindow       time.Duration
+
+	// How far from the reference price market orders may fill, in percent
+	// (0 disables), and per-symbol overrides as JSON, e.g. {"AAPL":2}
+	PriceCollarPct float64
+	PriceCollars   string
+
+	// How far from the reference price limit orders may be priced, in
+	// percent either way (0 disables), and per-symbol overrides as JSON,
+	// e.g. {"AAPL":10}
+	PriceBandPct float64
+	PriceBands   string
+
+	// How long an order identical to one the account executed counts as a
+	// duplicate (0 disables), per-account overrides as JSON, e.g.
+	// {"acct-1":"10s"}, and whether duplicates are rejected or only warned
+	// about (reject or warn)
+	DuplicateOrderWindow  time.Duration
+	DuplicateOrderWindows string
+	DuplicateOrderPolicy  string
+
+	// Share of an OCO leg's quantity that must fill before the rest of its
+	// group is cancelled (0 = any fill)
+	OCOFillThreshold float64
+
+	// Minimum time between re-pegs of one pegged order (0 re-pegs on every
+	// move), which is also how often deferred re-pegs are swept
+	PegRepriceInterval time.Duration
+
+	// How long orders must rest before they can be cancelled (0 disables),
+	// and per-symbol overrides as JSON, e.g. {"AAPL":"500ms"}
+	MinRestTime  time.Duration
+	MinRestTimes string
+
+	// When groups of symbols trade, as JSON (see tradinghours.go), and what
+	// happens to orders while their market is closed: reject or
+	// queue_for_open
+	TradingSessions    string
+	MarketClosedPolicy string
+
+	// How positions are kept: netting or hedge (separate long and short
+	// legs), and per-account overrides as JSON, e.g. {"acct-7":"hedge"}
+	PositionMode  string
+	PositionModes string
+
+	// What to cancel when an order would trade against its own account:
+	// cancel_resting, cancel_incoming or cancel_both
+	STPPolicy string
+
+	// Per-account default min fill ratio as JSON, e.g. {"acct-1":0.5}
+	MinFillRatios string
+
+	// Comma-separated symbols or glob patterns that may and may not be
+	// traded; an empty allow list permits everything not denied
+	SymbolAllowlist string
+	SymbolDenylist  string
+
+	// Symbol aliases as JSON, e.g. {"BRK-B":"BRK.B"}; orders for an alias
+	// are booked under its canonical symbol
+	SymbolAliases string
+
+	// Fee model charged on fills (none, per_share, per_trade, bps,
+	// maker_taker), its rate, and the per-share maker and taker rates used
+	// by maker_taker. Rates are decimal strings.
+	FeeModel     string
+	FeeRate      string
+	FeeMakerRate string
+	FeeTakerRate string
+
+	// Comma-separated sinks order updates are published to (redis, kafka),
+	// and the Kafka brokers and topic used by the kafka sink
+	FillSinks      string
+	KafkaBrokers   string
+	KafkaFillTopic string
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		RedisHost:               "localhost",
+		RedisPort:               "6379",
+		StreamName:              "execution.orders",
+		GRPCPort:                "50051",
+		FIXCompID:               "EXECUTION",
+		HTTPPort:                "8080",
+		StreamMaxLen:            1000000,
+		MaxPayloadBytes:         1 << 20,
+		StreamBacklogLimit:      100000,
+		StreamCodec:             codecJSON,
+		StreamCompression:       compressionNone,
+		RejectionAuditTTL:       7 * 24 * time.Hour,
+		OrderAckMode:            ackModeAsync,
+		OrderAckTimeout:         2 * time.Second,
+		ActivationSweepInterval: 100 * time.Millisecond,
+		MarketClosedPolicy:      marketClosedReject,
+		AlgoSweepInterval:       100 * time.Millisecond,
+		FillSinkMaxAttempts:     fillSinkMaxAttempts,
+		FillSinkBackoff:         fillSinkInitialBackoff,
+		FillRedeliveryInterval:  30 * time.Second,
+		RedisTimeout:            3 * time.Second,
+		RedisPoolSize:           100,
+		RedisMinIdleConns:       10,
+		RedisStartupTimeout:     30 * time.Second,
+		DrainGracePeriod:        30 * time.Second,
+		ReclaimMinIdle:          5 * time.Minute,
+		Transport:               transportRedis,
+		MatchingMode:            matchingModePerSymbol,
+		SchedulingMode:          schedulingModeFIFO,
+		DefaultAccountWeight:    1,
+		DuplicateOrderPolicy:    duplicatePolicyReject,
+		OrderTimeout:            100 * time.Millisecond,
+		MaxOrderClockSkew:       time.Second,
+		IdempotencyScope:        idempotencyScopeAccount,
+		BrokerFailureThreshold:  5,
+		BrokerOpenTimeout:       30 * time.Second,
+		BookSnapshotInterval:    30 * time.Second,
+		BookDiffHistory:         1000,
+		OrderCacheSize:          100000,
+		NotionalWindow:          time.Minute,
+		MetricsWindow:           60 * time.Second,
+		StatsStreamInterval:     time.Second,
+		AutoBreakerMinOrders:    20,
+		SimLatencyModel:         latencyModelZero,
+		SimFillModel:            fillModelNone,
+		InstrumentPolicy:        instrumentPolicyRound,
+		PegRepriceInterval:      100 * time.Millisecond,
+		STPPolicy:               stpCancelIncoming,
+		PositionMode:            positionModeNetting,
+		BookFullPolicy:          bookFullReject,
+		FeeModel:                feeModelNone,
+		FillSinks:               fillSinkRedis,
+		KafkaFillTopic:          "execution.fills",
+
+		// Compressing smaller payloads costs more than it saves
+		StreamCompressionThreshold: 8 << 10,
+	}
+}
+
+// LoadConfig reads the configuration from environment variables and
+// CONFIG_FILE, falling back to DefaultConfig for anything unset
+func LoadConfig() Config {
+	if err := loadConfigFile(); err != nil {
+		log.Printf("Invalid CONFIG_FILE (%v), using the environment only", err)
+	}
+	return configFromEnv()
+}
+
+// configFromEnv reads the configuration from environment variables and the
+// CONFIG_FILE values last loaded
+func configFromEnv() Config {
+	cfg := DefaultConfig()
+	cfg.RedisHost = getEnv("REDIS_HOST", cfg.RedisHost)
+	cfg.RedisPort = getEnv("REDIS_PORT", cfg.RedisPort)
+	cfg.RedisClusterAddrs = getEnv("REDIS_CLUSTER_ADDRS", cfg.RedisClusterAddrs)
+	cfg.StreamName = getEnv("REDIS_STREAM", cfg.StreamName)
+	cfg.ConsumerName = getEnv("CONSUMER_NAME", cfg.ConsumerName)
+	cfg.HTTPPort = getEnv("HTTP_PORT", cfg.HTTPPort)
+	cfg.GRPCPort = getEnv("GRPC_PORT", cfg.GRPCPort)
+	cfg.FIXPort = getEnv("FIX_PORT", cfg.FIXPort)
+	cfg.FIXCompID = getEnv("FIX_COMP_ID", cfg.FIXCompID)
+	cfg.InstanceID = getEnvInt("INSTANCE_ID", cfg.InstanceID)
+	cfg.RedisTimeout = getEnvDuration("REDIS_TIMEOUT", cfg.RedisTimeout)
+	cfg.RedisPoolSize = getEnvInt("REDIS_POOL_SIZE", cfg.RedisPoolSize)
+	cfg.RedisMinIdleConns = getEnvInt("REDIS_MIN_IDLE_CONNS", cfg.RedisMinIdleConns)
+	cfg.RedisStartupTimeout = getEnvDuration("REDIS_STARTUP_TIMEOUT", cfg.RedisStartupTimeout)
+	cfg.DrainGracePeriod = getEnvDuration("DRAIN_GRACE_PERIOD", cfg.DrainGracePeriod)
+	cfg.SimulateCrash = getEnv("SIMULATE_CRASH", cfg.SimulateCrash)
+	cfg.ReclaimMinIdle = getEnvDuration("RECLAIM_MIN_IDLE", cfg.ReclaimMinIdle)
+	cfg.Transport = getEnv("TRANSPORT", cfg.Transport)
+	cfg.MatchingMode = getEnv("MATCHING_MODE", cfg.MatchingMode)
+	cfg.SchedulingMode = getEnv("SCHEDULING_MODE", cfg.SchedulingMode)
+	cfg.AccountWeights = getEnv("ACCOUNT_WEIGHTS", cfg.AccountWeights)
+	cfg.DefaultAccountWeight = getEnvInt("DEFAULT_ACCOUNT_WEIGHT", cfg.DefaultAccountWeight)
+	cfg.StreamMaxLen = int64(getEnvInt("STREAM_MAX_LEN", int(cfg.StreamMaxLen)))
+	cfg.MaxPayloadBytes = int64(getEnvInt("MAX_PAYLOAD_BYTES", int(cfg.MaxPayloadBytes)))
+	cfg.ResponseFills = getEnvBool("RESPONSE_FILLS", cfg.ResponseFills)
+	cfg.StreamBacklogLimit = int64(getEnvInt("STREAM_BACKLOG_LIMIT", int(cfg.StreamBacklogLimit)))
+	cfg.StreamCodec = getEnv("STREAM_CODEC", cfg.StreamCodec)
+	cfg.StreamCompression = getEnv("STREAM_COMPRESSION", cfg.StreamCompression)
+	cfg.StreamCompressionThreshold = getEnvInt("STREAM_COMPRESSION_THRESHOLD", cfg.StreamCompressionThreshold)
+	cfg.BookSeedFile = getEnv("BOOK_SEED_FILE", cfg.BookSeedFile)
+	cfg.ReplayFile = getEnv("REPLAY_FILE", cfg.ReplayFile)
+	cfg.ReplaySpeed = getEnvFloat("REPLAY_SPEED", cfg.ReplaySpeed)
+	cfg.ReplayOutput = getEnv("REPLAY_OUTPUT", cfg.ReplayOutput)
+	cfg.ActivationSweepInterval = getEnvDuration("ACTIVATION_SWEEP_INTERVAL", cfg.ActivationSweepInterval)
+	cfg.AlgoSweepInterval = getEnvDuration("ALGO_SWEEP_INTERVAL", cfg.AlgoSweepInterval)
+	cfg.VWAPVolumeCurve = getEnv("VWAP_VOLUME_CURVE", cfg.VWAPVolumeCurve)
+	cfg.FillSinkMaxAttempts = getEnvInt("FILL_SINK_MAX_ATTEMPTS", cfg.FillSinkMaxAttempts)
+	cfg.FillSinkBackoff = getEnvDuration("FILL_SINK_BACKOFF", cfg.FillSinkBackoff)
+	cfg.FillRedeliveryInterval = getEnvDuration("FILL_REDELIVERY_INTERVAL", cfg.FillRedeliveryInterval)
+	cfg.FillAggregationWindow = getEnvDuration("FILL_AGGREGATION_WINDOW", cfg.FillAggregationWindow)
+	cfg.OrderAckMode = getEnv("ORDER_ACK_MODE", cfg.OrderAckMode)
+	cfg.OrderAckTimeout = getEnvDuration("ORDER_ACK_TIMEOUT", cfg.OrderAckTimeout)
+	cfg.RejectionAuditTTL = getEnvDuration("REJECTION_AUDIT_TTL", cfg.RejectionAuditTTL)
+	cfg.OrderTimeout = getEnvDuration("ORDER_TIMEOUT", cfg.OrderTimeout)
+	cfg.MaxOrderAge = getEnvDuration("MAX_ORDER_AGE", cfg.MaxOrderAge)
+	cfg.MaxOrderClockSkew = getEnvDuration("MAX_ORDER_CLOCK_SKEW", cfg.MaxOrderClockSkew)
+	cfg.IdempotencyScope = getEnv("IDEMPOTENCY_SCOPE", cfg.IdempotencyScope)
+	cfg.ReceiptKey = getEnv("RECEIPT_KEY", cfg.ReceiptKey)
+	cfg.BrokerFailureThreshold = getEnvInt("BROKER_FAILURE_THRESHOLD", cfg.BrokerFailureThreshold)
+	cfg.BrokerOpenTimeout = getEnvDuration("BROKER_OPEN_TIMEOUT", cfg.BrokerOpenTimeout)
+	cfg.APIKeys = getEnv("API_KEYS", cfg.APIKeys)
+	cfg.APIKeysRedisKey = getEnv("API_KEYS_REDIS_KEY", cfg.APIKeysRedisKey)
+	cfg.MetricsWindow = getEnvDuration("METRICS_WINDOW", cfg.MetricsWindow)
+	cfg.StatsStreamInterval = getEnvDuration("STATS_STREAM_INTERVAL", cfg.StatsStreamInterval)
+	cfg.MetricsSymbols = getEnv("METRICS_SYMBOLS", cfg.MetricsSymbols)
+	cfg.MetricsSymbolMinOrders = getEnvInt("METRICS_SYMBOL_MIN_ORDERS", cfg.MetricsSymbolMinOrders)
+	cfg.AutoBreakerRejectRatio = getEnvFloat("AUTO_BREAKER_REJECT_RATIO", cfg.AutoBreakerRejectRatio)
+	cfg.AutoBreakerMinOrders = getEnvInt("AUTO_BREAKER_MIN_ORDERS", cfg.AutoBreakerMinOrders)
+	cfg.OrderQuantityBuckets = getEnv("ORDER_QUANTITY_BUCKETS", cfg.OrderQuantityBuckets)
+	cfg.OrderNotionalBuckets = getEnv("ORDER_NOTIONAL_BUCKETS", cfg.OrderNotionalBuckets)
+	cfg.BookSnapshotInterval = getEnvDuration("BOOK_SNAPSHOT_INTERVAL", cfg.BookSnapshotInterval)
+	cfg.BookDiffHistory = getEnvInt("BOOK_DIFF_HISTORY", cfg.BookDiffHistory)
+	cfg.OrderCacheSize = getEnvInt("ORDER_CACHE_SIZE", cfg.OrderCacheSize)
+	cfg.OTLPEndpoint = getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", cfg.OTLPEndpoint)
+	cfg.ReferencePrices = getEnv("REFERENCE_PRICES", cfg.ReferencePrices)
+	cfg.SimLatencyModel = getEnv("SIM_LATENCY_MODEL", cfg.SimLatencyModel)
+	cfg.SimLatency = getEnvDuration("SIM_LATENCY", cfg.SimLatency)
+	cfg.SimLatencyJitter = getEnvDuration("SIM_LATENCY_JITTER", cfg.SimLatencyJitter)
+	cfg.SimSeed = int64(getEnvInt("SIM_SEED", int(cfg.SimSeed)))
+	cfg.SimFillModel = getEnv("SIM_FILL_MODEL", cfg.SimFillModel)
+	cfg.SimFillProbability = getEnvFloat("SIM_FILL_PROBABILITY", cfg.SimFillProbability)
+	cfg.Instruments = getEnv("INSTRUMENTS", cfg.Instruments)
+	cfg.InstrumentPolicy = getEnv("INSTRUMENT_POLICY", cfg.InstrumentPolicy)
+	cfg.STPPolicy = getEnv("STP_POLICY", cfg.STPPolicy)
+	cfg.MinRestTime = getEnvDuration("MIN_REST_TIME", cfg.MinRestTime)
+	cfg.MinRestTimes = getEnv("MIN_REST_TIMES", cfg.MinRestTimes)
+	cfg.TradingSessions = getEnv("TRADING_SESSIONS", cfg.TradingSessions)
+	cfg.MarketClosedPolicy = getEnv("MARKET_CLOSED_POLICY", cfg.MarketClosedPolicy)
+	cfg.PositionMode = getEnv("POSITION_MODE", cfg.PositionMode)
+	cfg.PositionModes = getEnv("POSITION_MODES", cfg.PositionModes)
+	cfg.BookMaxOrders = getEnvInt("BOOK_MAX_ORDERS", cfg.BookMaxOrders)
+	cfg.BookMaxOrdersPerSymbol = getEnvInt("BOOK_MAX_ORDERS_PER_SYMBOL", cfg.BookMaxOrdersPerSymbol)
+	cfg.BookFullPolicy = getEnv("BOOK_FULL_POLICY", cfg.BookFullPolicy)
+	cfg.MaxOpenOrdersPerAccount = getEnvInt("MAX_OPEN_ORDERS_PER_ACCOUNT", cfg.MaxOpenOrdersPerAccount)
+	cfg.MaxOrderQuantity = getEnv("MAX_ORDER_QUANTITY", cfg.MaxOrderQuantity)
+	cfg.MaxNotionalPerWindow = getEnv("MAX_NOTIONAL_PER_WINDOW", cfg.MaxNotionalPerWindow)
+	cfg.NotionalWindow = getEnvDuration("NOTIONAL_WINDOW", cfg.NotionalWindow)
+	cfg.PriceCollarPct = getEnvFloat("PRICE_COLLAR_PCT", cfg.PriceCollarPct)
+	cfg.PriceCollars = getEnv("PRICE_COLLARS", cfg.PriceCollars)
+	cfg.PriceBandPct = getEnvFloat("PRICE_BAND_PCT", cfg.PriceBandPct)
+	cfg.PriceBands = getEnv("PRICE_BANDS", cfg.PriceBands)
+	cfg.DuplicateOrderWindow = getEnvDuration("DUPLICATE_ORDER_WINDOW", cfg.DuplicateOrderWindow)
+	cfg.DuplicateOrderWindows = getEnv("DUPLICATE_ORDER_WINDOWS", cfg.DuplicateOrderWindows)
+	cfg.DuplicateOrderPolicy = getEnv("DUPLICATE_ORDER_POLICY", cfg.DuplicateOrderPolicy)
+	cfg.OCOFillThreshold = getEnvFloat("OCO_FILL_THRESHOLD", cfg.OCOFillThreshold)
+	cfg.PegRepriceInterval = getEnvDuration("PEG_REPRICE_INTERVAL", cfg.PegRepriceInterval)
+	cfg.MinFillRatios = getEnv("MIN_FILL_RATIOS", cfg.MinFillRatios)
+	cfg.SymbolAllowlist = getEnv("SYMBOL_ALLOWLIST", cfg.SymbolAllowlist)
+	cfg.SymbolDenylist = getEnv("SYMBOL_DENYLIST", cfg.SymbolDenylist)
+	cfg.SymbolAliases = getEnv("SYMBOL_ALIASES", cfg.SymbolAliases)
+	cfg.FeeModel = getEnv("FEE_MODEL", cfg.FeeModel)
+	cfg.FeeRate = getEnv("FEE_RATE", cfg.FeeRate)
+	cfg.FeeMakerRate = getEnv("FEE_MAKER_RATE", cfg.FeeMakerRate)
+	cfg.FeeTakerRate = getEnv("FEE_TAKER_RATE", cfg.FeeTakerRate)
+	cfg.FillSinks = getEnv("FILL_SINKS", cfg.FillSinks)
+	cfg.KafkaBrokers = getEnv("KAFKA_BROKERS", cfg.KafkaBrokers)
+	cfg.KafkaFillTopic = getEnv("KAFKA_FILL_TOPIC", cfg.KafkaFillTopic)
+	return cfg
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s (%q), using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid integer for %s (%q), using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid number for %s (%q), using %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return f
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean for %s (%q), using %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return b
+}
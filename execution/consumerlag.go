@@ -0,0 +1,156 @@
+// ==============================================================================
+// Consumer lag - unacknowledged orders per consumer
+// ==============================================================================
+// The backlog behind backpressure and /debug/info is the whole consumer
+// group's. An autoscaler wants it per consumer, to find the one falling
+// behind, so XPENDING is read on every scrape and exported as:
+//
+//   consumer_pending_messages           orders delivered to the consumer
+//                                       and not yet acknowledged
+//   consumer_oldest_pending_age_seconds age of its oldest such order, an
+//                                       approximate processing lag
+//
+// both labelled with the consumer name, and GET /stats/consumers returns the
+// same per consumer:
+//
+//   {"consumers":[{"consumer":"engine-7d9f-x2k4","pending":3,
+//                  "oldest_pending_age_ms":1250}]}
+//
+// The engine doesn't shard the order stream, so each consumer is an engine
+// reading the one stream in the one group, named by CONSUMER_NAME, else
+// after INSTANCE_ID, else its hostname, so replicas (pods) are told apart.
+// Orders not yet delivered to any consumer aren't anyone's and only show in
+// the group's backlog. The age is taken from the entry ID, so it counts from
+// when the order was submitted, by the engine clock. Consumers with nothing
+// pending are left out.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultConsumerName is the consumer name when there is no better one
+const defaultConsumerName = "execution-engine-1"
+
+// consumerNameFor names the engine in the consumer group: CONSUMER_NAME, or
+// else one derived from INSTANCE_ID, or else the hostname
+func consumerNameFor(cfg Config) string {
+	if cfg.ConsumerName != "" {
+		return cfg.ConsumerName
+	}
+	if cfg.InstanceID > 0 {
+		return fmt.Sprintf("execution-engine-%d", cfg.InstanceID)
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return defaultConsumerName
+}
+
+// ConsumerLag is one consumer's unacknowledged orders
+type ConsumerLag struct {
+	Consumer           string `json:"consumer"`
+	Pending            int64  `json:"pending"`
+	OldestPendingAgeMs int64  `json:"oldest_pending_age_ms"`
+}
+
+// consumerLags reads the pending orders of every consumer in the group,
+// sorted by consumer name
+func (e *ExecutionEngine) consumerLags(ctx context.Context) ([]ConsumerLag, error) {
+	summary, err := e.redisClient.XPending(ctx, e.streamName, e.consumerGroup).Result()
+	if err == redis.Nil {
+		return []ConsumerLag{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lags := make([]ConsumerLag, 0, len(summary.Consumers))
+	now := e.now().UnixMilli()
+	for consumer, pending := range summary.Consumers {
+		lag := ConsumerLag{Consumer: consumer, Pending: pending}
+		oldest, err := e.redisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream:   e.streamName,
+			Group:    e.consumerGroup,
+			Start:    "-",
+			End:      "+",
+			Count:    1,
+			Consumer: consumer,
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(oldest) > 0 {
+			ms, _ := splitStreamID(oldest[0].ID)
+			if age := now - int64(ms); age > 0 {
+				lag.OldestPendingAgeMs = age
+			}
+		}
+		lags = append(lags, lag)
+	}
+	sort.Slice(lags, func(i, j int) bool { return lags[i].Consumer < lags[j].Consumer })
+	return lags, nil
+}
+
+// consumerLagCollector exports the pending orders per consumer
+type consumerLagCollector struct {
+	engine  *ExecutionEngine
+	pending *prometheus.Desc
+	age     *prometheus.Desc
+}
+
+func newConsumerLagCollector(e *ExecutionEngine) *consumerLagCollector {
+	return &consumerLagCollector{
+		engine: e,
+		pending: prometheus.NewDesc("consumer_pending_messages",
+			"Orders delivered to a consumer and not yet acknowledged", []string{"consumer"}, nil),
+		age: prometheus.NewDesc("consumer_oldest_pending_age_seconds",
+			"Age of the oldest order a consumer has not yet acknowledged", []string{"consumer"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *consumerLagCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.pending
+	ch <- c.age
+}
+
+// Collect implements prometheus.Collector
+func (c *consumerLagCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.engine.config.RedisTimeout)
+	defer cancel()
+	lags, err := c.engine.consumerLags(ctx)
+	if err != nil {
+		log.Printf("Error reading consumer lag: %v", err)
+		return
+	}
+	for _, lag := range lags {
+		ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, float64(lag.Pending), lag.Consumer)
+		ch <- prometheus.MustNewConstMetric(c.age, prometheus.GaugeValue, float64(lag.OldestPendingAgeMs)/1000, lag.Consumer)
+	}
+}
+
+// handleConsumerLag serves GET /stats/consumers
+func (e *ExecutionEngine) handleConsumerLag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	lags, err := e.consumerLags(r.Context())
+	if err != nil {
+		writeError(w, errCodeUnavailable, "Consumer lag is unavailable")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string][]ConsumerLag{"consumers": lags})
+}
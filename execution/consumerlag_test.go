@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// readAs delivers the next count orders of the stream to consumer, leaving
+// them unacknowledged
+func readAs(t *testing.T, engine *ExecutionEngine, consumer string, count int64) []redis.XMessage {
+	t.Helper()
+	streams, err := engine.redisClient.XReadGroup(context.Background(), &redis.XReadGroupArgs{
+		Group:    engine.consumerGroup,
+		Consumer: consumer,
+		Streams:  []string{engine.streamName, ">"},
+		Count:    count,
+	}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return streams[0].Messages
+}
+
+// pendingGauges returns consumer_pending_messages by consumer
+func pendingGauges(t *testing.T, engine *ExecutionEngine) map[string]float64 {
+	t.Helper()
+	families, err := engine.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gauges := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "consumer_pending_messages" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			gauges[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+		}
+	}
+	return gauges
+}
+
+func TestConsumerLagPerConsumer(t *testing.T) {
+	engine, mr := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+	mr.SetTime(clock.Now())
+	queueBatch(t, engine, "lag-1", "lag-2", "lag-3", "lag-4", "lag-5", "lag-6")
+	readAs(t, engine, "engine-a", 3)
+	slow := readAs(t, engine, "engine-b", 2)
+
+	gauges := pendingGauges(t, engine)
+	if len(gauges) != 2 || gauges["engine-a"] != 3 || gauges["engine-b"] != 2 {
+		t.Fatalf("pending gauges = %v, want engine-a 3 and engine-b 2", gauges)
+	}
+
+	// Acknowledging shrinks one consumer's count only
+	engine.redisClient.XAck(context.Background(), engine.streamName, engine.consumerGroup, slow[0].ID)
+	if gauges := pendingGauges(t, engine); gauges["engine-a"] != 3 || gauges["engine-b"] != 1 {
+		t.Errorf("pending gauges after an ack = %v, want engine-a 3 and engine-b 1", gauges)
+	}
+
+	clock.advance(1500 * time.Millisecond)
+	rec := httptest.NewRecorder()
+	engine.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats/consumers", nil))
+	var body struct {
+		Consumers []ConsumerLag `json:"consumers"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("GET /stats/consumers: %d %v", rec.Code, err)
+	}
+	if len(body.Consumers) != 2 || body.Consumers[0].Consumer != "engine-a" || body.Consumers[0].Pending != 3 ||
+		body.Consumers[1].Consumer != "engine-b" || body.Consumers[1].Pending != 1 {
+		t.Errorf("consumers = %+v, want engine-a with 3 and engine-b with 1", body.Consumers)
+	}
+	for _, lag := range body.Consumers {
+		if lag.OldestPendingAgeMs != 1500 {
+			t.Errorf("%s oldest pending age %dms, want the 1500ms the clock moved", lag.Consumer, lag.OldestPendingAgeMs)
+		}
+	}
+}
+
+func TestConsumerNamePerInstance(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ConsumerName = "engine-a"
+	if got := consumerNameFor(cfg); got != "engine-a" {
+		t.Errorf("consumer name = %q, want CONSUMER_NAME", got)
+	}
+	cfg.ConsumerName = ""
+	cfg.InstanceID = 7
+	if got := consumerNameFor(cfg); got != "execution-engine-7" {
+		t.Errorf("consumer name = %q, want one from INSTANCE_ID", got)
+	}
+	cfg.InstanceID = 0
+	if host, _ := os.Hostname(); host != "" && consumerNameFor(cfg) != host {
+		t.Errorf("consumer name = %q, want the hostname %q", consumerNameFor(cfg), host)
+	}
+}
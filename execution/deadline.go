@@ -0,0 +1,40 @@
+// ==============================================================================
+// Order deadlines - skip work nobody is waiting for any more
+// ==============================================================================
+// An order may carry a deadline (unix ms) after which its submitter no longer
+// wants it executed. processOrder checks it last thing before execution,
+// against the engine clock, and skips an order past its deadline with reason
+// "deadline_exceeded" instead of sending it to the broker. Under a backlog
+// the engine so spends its time on orders that can still be of use.
+//
+// Unlike MAX_ORDER_AGE (see stale.go), which the engine sets for everyone,
+// deadlines are set per order by the client, and they apply whenever the
+// order is about to execute: held orders, such as stops, are skipped too if
+// they trigger after their deadline. Orders without a deadline never expire.
+// ==============================================================================
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// rejectDeadlineExceeded is the reason for orders picked up after their
+// deadline
+const rejectDeadlineExceeded = "deadline_exceeded"
+
+// checkDeadline skips an order whose deadline has passed
+func (e *ExecutionEngine) checkDeadline(order *OrderRequest) *rejection {
+	if order.Deadline <= 0 {
+		return nil
+	}
+	deadline := time.UnixMilli(order.Deadline)
+	if late := e.now().Sub(deadline); late > 0 {
+		return &rejection{
+			Reason: rejectDeadlineExceeded,
+			Detail: fmt.Sprintf("deadline %s passed %s ago", deadline.UTC().Format(time.RFC3339Nano), late),
+		}
+	}
+	return nil
+}
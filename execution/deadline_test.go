@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBacklogSkipsOrdersPastTheirDeadline(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+	queueBatch(t, engine)
+
+	deadlines := map[string]time.Duration{
+		"expired-1": time.Second,
+		"fresh-1":   time.Hour,
+		"expired-2": 4 * time.Second,
+		"fresh-2":   6 * time.Second,
+		"no-limit":  0,
+	}
+	for _, id := range []string{"expired-1", "fresh-1", "expired-2", "fresh-2", "no-limit"} {
+		order := testOrder(id)
+		if d := deadlines[id]; d > 0 {
+			order.Deadline = clock.Now().Add(d).UnixMilli()
+		}
+		if err := engine.SubmitOrder(context.Background(), &order); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The backlog is only worked through five seconds later
+	clock.advance(5 * time.Second)
+	engine.consumeBatch()
+
+	for id, d := range deadlines {
+		response, _ := engine.GetOrder(id)
+		if d > 0 && d < 5*time.Second {
+			if response.Status != statusRejected || response.RejectReason != rejectDeadlineExceeded {
+				t.Errorf("%s past its deadline: %s/%s, want skipped with %s", id, response.Status, response.RejectReason, rejectDeadlineExceeded)
+			}
+		} else if response.Status != statusFilled {
+			t.Errorf("%s within its deadline: %s/%s, want filled", id, response.Status, response.RejectReason)
+		}
+	}
+	if got := testutil.ToFloat64(engine.ordersRejected); got != 2 {
+		t.Errorf("%v orders counted as rejected, want 2", got)
+	}
+}
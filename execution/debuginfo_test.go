@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugInfo(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.apiKeys = map[string]string{"key-ops": "ops"}
+
+	// Two orders queued but not consumed, three executed market orders with
+	// idempotency keys, and three resting limit orders across two symbols
+	queueBatch(t, engine, "queued-1", "queued-2")
+	for _, id := range []string{"dbg-1", "dbg-2", "dbg-3"} {
+		order := testOrder(id)
+		submitToEngine(t, engine, &order)
+	}
+	submitToEngine(t, engine, limitOrder("bid-1", "AAPL", "buy", 99, 10))
+	submitToEngine(t, engine, limitOrder("bid-2", "AAPL", "buy", 98, 10))
+	submitToEngine(t, engine, limitOrder("ask-1", "MSFT", "sell", 400, 1))
+
+	handler := engine.routes()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/info", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("without an API key: got %d, want 401", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/info", nil)
+	req.Header.Set(apiKeyHeader, "key-ops")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /debug/info: %d %s", rec.Code, rec.Body.String())
+	}
+
+	var info DebugInfo
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != version || info.Commit == "" || info.GoVersion == "" {
+		t.Errorf("build info = %q %q %q", info.Version, info.Commit, info.GoVersion)
+	}
+	if info.UptimeSeconds <= 0 || info.StartedAt == 0 || info.Goroutines <= 0 {
+		t.Errorf("implausible runtime stats: %+v", info)
+	}
+	if info.ActiveBooks != 2 || info.RestingOrders != 3 {
+		t.Errorf("books = %d with %d resting, want 2 with 3", info.ActiveBooks, info.RestingOrders)
+	}
+	if info.IdempotencyKeys != 0 {
+		t.Errorf("idempotency keys = %d, want none once the orders have executed", info.IdempotencyKeys)
+	}
+	if info.ConsumerLag == nil || *info.ConsumerLag != 2 {
+		t.Errorf("consumer lag = %v, want 2", *info.ConsumerLag)
+	}
+}
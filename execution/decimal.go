@@ -0,0 +1,31 @@
+// ==============================================================================
+// Decimal arithmetic for prices and quantities
+// ==============================================================================
+// Prices and quantities are decimal.Decimal rather than float64. Binary floats
+// can't represent values like 0.1 exactly, so summing many partial fills made
+// filled quantities, VWAPs and book levels drift, which matters for fractional
+// shares and crypto sizes. Decimals still travel as plain JSON numbers, so
+// clients see the same wire format as before.
+//
+// encoding/json never finds a struct empty, so omitempty does nothing for a
+// decimal and an unset limit price would go out as 0. Types with optional
+// decimal fields encode them through omitZero in their own MarshalJSON,
+// which leaves zero ones out as before.
+// ==============================================================================
+
+package main
+
+import "github.com/shopspring/decimal"
+
+func init() {
+	decimal.MarshalJSONWithoutQuotes = true
+}
+
+// omitZero is d, or nil if it's zero, for an optional decimal field to be
+// encoded as an omitempty pointer
+func omitZero(d decimal.Decimal) *decimal.Decimal {
+	if d.IsZero() {
+		return nil
+	}
+	return &d
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPartialFillsAccumulateExactly(t *testing.T) {
+	engine, _ := newTestEngine(t)
+
+	// 0.1 has no exact binary float representation; a thousand float additions
+	// of it come to 99.9999999999986, not 100
+	resting := limitOrder("big-sell", "BTC", "sell", 30000.01, 100)
+	engine.orderCache.Store(resting.OrderID, engine.executeOrder(context.Background(), resting))
+
+	var incomingTotal = dec(0)
+	for i := 0; i < 1000; i++ {
+		resp := engine.executeOrder(context.Background(), limitOrder(fmt.Sprintf("buy-%d", i), "BTC", "buy", 30000.01, 0.1))
+		incomingTotal = incomingTotal.Add(resp.FilledQuantity)
+	}
+
+	response, _ := engine.GetOrder("big-sell")
+	if !response.FilledQuantity.Equal(dec(100)) || response.Status != "filled" {
+		t.Fatalf("resting order filled %s (%s), want exactly 100", response.FilledQuantity, response.Status)
+	}
+	if !response.FilledAvgPrice.Equal(dec(30000.01)) {
+		t.Errorf("avg price drifted to %s", response.FilledAvgPrice)
+	}
+	if !incomingTotal.Equal(dec(100)) {
+		t.Errorf("incoming fills sum to %s, want exactly 100", incomingTotal)
+	}
+
+	if n := engine.bookFor("BTC").Len(); n != 0 {
+		t.Errorf("dust left in the book: %d resting orders", n)
+	}
+}
+
+func TestDecimalWireFormatIsNumeric(t *testing.T) {
+	var order OrderRequest
+	body := `{"order_id":"w1","symbol":"BTC","side":"buy","quantity":0.00000001,"type":"limit","limit_price":30000.5}`
+	if err := json.Unmarshal([]byte(body), &order); err != nil {
+		t.Fatalf("numeric JSON should decode: %v", err)
+	}
+	if order.Quantity.String() != "0.00000001" || order.LimitPrice.String() != "30000.5" {
+		t.Errorf("decoded %s @ %s", order.Quantity, order.LimitPrice)
+	}
+
+	out, _ := json.Marshal(&OrderResponse{OrderID: "w1", FilledQuantity: order.Quantity, FilledAvgPrice: order.LimitPrice})
+	if !strings.Contains(string(out), `"filled_quantity":0.00000001`) || !strings.Contains(string(out), `"filled_avg_price":30000.5`) {
+		t.Errorf("decimals should encode as bare JSON numbers: %s", out)
+	}
+}
+
+func TestOrderRequestRoundTripsTheBaselineWireFormat(t *testing.T) {
+	// Orders as clients sent them before decimals: unset prices left out
+	for _, body := range []string{
+		`{"order_id":"o1","symbol":"AAPL","side":"buy","quantity":10,"type":"market","time_in_force":"day","idempotency_key":"k1","timestamp":1700000000000}`,
+		`{"order_id":"o2","symbol":"AAPL","side":"sell","quantity":5,"type":"limit","limit_price":150.25,"time_in_force":"gtc","idempotency_key":"k2","timestamp":1700000000000}`,
+		`{"order_id":"o3","symbol":"AAPL","side":"sell","quantity":5,"type":"stop","limit_price":94.5,"stop_price":95,"time_in_force":"day","idempotency_key":"k3","timestamp":1700000000000}`,
+	} {
+		var order OrderRequest
+		if err := json.Unmarshal([]byte(body), &order); err != nil {
+			t.Fatal(err)
+		}
+		out, err := json.Marshal(&order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := decodeJSONObject(t, []byte(body)), decodeJSONObject(t, out); !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip changed the order:\n got %s\nwant %s", out, body)
+		}
+	}
+}
+
+// decodeJSONObject decodes a JSON object keeping numbers as written
+func decodeJSONObject(t *testing.T, data []byte) map[string]any {
+	t.Helper()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var m map[string]any
+	if err := decoder.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// BenchmarkBookMatch rests ten sells over three price levels and sweeps them
+// with one buy. The float64 book this replaced ran the same loop in about
+// 4µs/op (23 allocs/op); decimals cost roughly 9µs/op (117 allocs/op), which
+// is still far inside the 100ms execution budget.
+func BenchmarkBookMatch(b *testing.B) {
+	book := NewOrderBook("AAPL")
+	ids := make([]string, 10)
+	prices := make([]BookOrder, 10)
+	for j := range ids {
+		ids[j] = fmt.Sprint(j)
+		prices[j] = BookOrder{OrderID: ids[j], Side: "sell", Price: dec(100 + float64(j%3)*0.01), Quantity: dec(0.37)}
+	}
+	incoming := BookOrder{OrderID: "in", Side: "buy", Price: dec(100.02), Quantity: dec(3.7)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range prices {
+			book.Add(prices[j])
+		}
+		book.Match(incoming, stpCancelIncoming)
+	}
+}
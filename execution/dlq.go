@@ -0,0 +1,183 @@
+// ==============================================================================
+// Dead-letter queue - parking and replay of orders that failed processing
+// ==============================================================================
+// Messages that cannot be processed are copied to "<stream>.dlq" together with
+// the reason they failed. Operators can inspect the DLQ with XRANGE, fix the
+// underlying problem and replay the entries back onto the order stream.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Reasons recorded on dead-lettered messages
+const (
+	dlqReasonInvalidFormat = "invalid_format"
+	dlqReasonDecodeError   = "decode_error"
+	dlqReasonValidation    = "validation_failed"
+	dlqReasonPanic         = "processing_panic"
+)
+
+// DLQFilter narrows which dead-lettered entries are replayed
+type DLQFilter struct {
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since,omitempty"`
+	Until  time.Time `json:"until,omitempty"`
+}
+
+// ReplaySummary reports the outcome of a DLQ replay
+type ReplaySummary struct {
+	Replayed int `json:"replayed"`
+	Skipped  int `json:"skipped"`
+}
+
+// sendToDLQ parks a message that could not be processed, keeping the
+// encoding its payload was tagged with (empty for untagged JSON)
+func (e *ExecutionEngine) sendToDLQ(sourceID string, payload string, encoding string, reason string, detail string) error {
+	return e.sendRejectionToDLQ(sourceID, payload, encoding, &rejection{Reason: reason, Detail: detail})
+}
+
+// sendRejectionToDLQ parks a rejected message with the rejection's detail
+func (e *ExecutionEngine) sendRejectionToDLQ(sourceID string, payload string, encoding string, rej *rejection) error {
+	return e.parkInDLQ(sourceID, payload, encoding, "", rej)
+}
+
+// parkInDLQ parks a payload, tagging the algorithm it is still compressed
+// with, if any, so replay can decompress it
+func (e *ExecutionEngine) parkInDLQ(sourceID string, payload string, encoding string, compression string, rej *rejection) error {
+	values := map[string]interface{}{
+		"order":     payload,
+		"reason":    rej.Reason,
+		"error":     rej.Detail,
+		"source_id": sourceID,
+	}
+	if encoding != "" {
+		values[payloadEncodingField] = encoding
+	}
+	if compression != "" {
+		values[payloadCompressionField] = compression
+	}
+	if rej.Limit != nil {
+		limit, _ := json.Marshal(rej.Limit)
+		values["risk_limit"] = string(limit)
+	}
+	if rej.RetryAfter > 0 {
+		values["retry_after_ms"] = retryAfterMs(rej.RetryAfter)
+	}
+	_, err := e.redisClient.XAdd(e.ctx, &redis.XAddArgs{
+		Stream: e.dlqStreamName,
+		Values: values,
+	}).Result()
+	if err != nil {
+		log.Printf("Error writing message %s to DLQ: %v", sourceID, err)
+	}
+	return err
+}
+
+// ReplayDLQ re-submits dead-lettered orders matching the filter to the order
+// stream. Entries that still fail validation are left in the DLQ, and entries
+// whose idempotency key has already been executed are dropped without being
+// replayed so they can't execute twice.
+func (e *ExecutionEngine) ReplayDLQ(ctx context.Context, filter DLQFilter) (*ReplaySummary, error) {
+	// DLQ entry IDs are millisecond timestamps, so the time range maps
+	// directly onto XRANGE bounds
+	start, end := "-", "+"
+	if !filter.Since.IsZero() {
+		start = strconv.FormatInt(filter.Since.UnixMilli(), 10)
+	}
+	if !filter.Until.IsZero() {
+		end = strconv.FormatInt(filter.Until.UnixMilli(), 10)
+	}
+
+	entries, err := e.redisClient.XRange(ctx, e.dlqStreamName, start, end).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &ReplaySummary{}
+	for _, entry := range entries {
+		if filter.Reason != "" && entry.Values["reason"] != filter.Reason {
+			continue
+		}
+
+		payload, _ := entry.Values["order"].(string)
+		var order OrderRequest
+		decompressed, err := decompressPayload(entry.Values, payload, e.config.MaxPayloadBytes)
+		codec, codecErr := entryCodec(entry.Values)
+		if err == nil {
+			err = codecErr
+		}
+		if err == nil {
+			err = codec.Unmarshal([]byte(decompressed), &order)
+		}
+		if err != nil || validateOrder(&order) != nil {
+			summary.Skipped++
+			continue
+		}
+
+		if order.IdempotencyKey != "" {
+			if e.idempotencyKeyUsed(ctx, &order) {
+				e.redisClient.XDel(ctx, e.dlqStreamName, entry.ID)
+				summary.Skipped++
+				continue
+			}
+		}
+
+		values := map[string]interface{}{
+			"order":         payload,
+			"replayed_from": entry.ID,
+		}
+		for _, field := range []string{payloadEncodingField, payloadCompressionField} {
+			if v, ok := entry.Values[field]; ok {
+				values[field] = v
+			}
+		}
+		_, err = e.redisClient.XAdd(ctx, &redis.XAddArgs{
+			Stream: e.streamName,
+			MaxLen: e.config.StreamMaxLen,
+			Approx: true,
+			Values: values,
+		}).Result()
+		if err != nil {
+			return summary, err
+		}
+		e.redisClient.XDel(ctx, e.dlqStreamName, entry.ID)
+		summary.Replayed++
+	}
+
+	log.Printf("DLQ replay complete: %d replayed, %d skipped", summary.Replayed, summary.Skipped)
+	return summary, nil
+}
+
+// handleDLQReplay serves POST /dlq/replay with an optional DLQFilter body
+func (e *ExecutionEngine) handleDLQReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+
+	var filter DLQFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, errCodeInvalidRequest, "Invalid request")
+		return
+	}
+
+	summary, err := e.ReplayDLQ(r.Context(), filter)
+	if err != nil {
+		writeError(w, errCodeInternal, "Failed to replay DLQ")
+		return
+	}
+
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func seedDLQ(t *testing.T, engine *ExecutionEngine, order OrderRequest, reason string) {
+	t.Helper()
+	payload, _ := json.Marshal(order)
+	engine.sendToDLQ("0-1", string(payload), "", reason, "seeded")
+}
+
+func testOrder(id string) OrderRequest {
+	return OrderRequest{
+		OrderID:        id,
+		Symbol:         "AAPL",
+		Side:           "buy",
+		Quantity:       dec(10),
+		Type:           "market",
+		TimeInForce:    "day",
+		IdempotencyKey: "key-" + id,
+	}
+}
+
+func TestReplayDLQMovesEntryBackToOrderStream(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	ctx := context.Background()
+
+	seedDLQ(t, engine, testOrder("dlq-1"), dlqReasonDecodeError)
+
+	summary, err := engine.ReplayDLQ(ctx, DLQFilter{})
+	if err != nil {
+		t.Fatalf("ReplayDLQ: %v", err)
+	}
+	if summary.Replayed != 1 || summary.Skipped != 0 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	if n := engine.redisClient.XLen(ctx, engine.dlqStreamName).Val(); n != 0 {
+		t.Errorf("expected empty DLQ, got %d entries", n)
+	}
+	msgs := engine.redisClient.XRange(ctx, engine.streamName, "-", "+").Val()
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message on order stream, got %d", len(msgs))
+	}
+	var replayed OrderRequest
+	json.Unmarshal([]byte(msgs[0].Values["order"].(string)), &replayed)
+	if replayed.OrderID != "dlq-1" {
+		t.Errorf("replayed wrong order %q", replayed.OrderID)
+	}
+}
+
+func TestReplayDLQSkipsExecutedAndFilteredEntries(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	ctx := context.Background()
+
+	executed := testOrder("done")
+	engine.idempotencyCache.Store(executed.IdempotencyKey, true)
+	seedDLQ(t, engine, executed, dlqReasonDecodeError)
+	seedDLQ(t, engine, testOrder("other"), dlqReasonValidation)
+
+	summary, err := engine.ReplayDLQ(ctx, DLQFilter{Reason: dlqReasonDecodeError})
+	if err != nil {
+		t.Fatalf("ReplayDLQ: %v", err)
+	}
+	if summary.Replayed != 0 || summary.Skipped != 1 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if n := engine.redisClient.XLen(ctx, engine.streamName).Val(); n != 0 {
+		t.Errorf("executed order must not be replayed, stream has %d", n)
+	}
+	// The entry with a different reason is untouched
+	if n := engine.redisClient.XLen(ctx, engine.dlqStreamName).Val(); n != 1 {
+		t.Errorf("expected 1 remaining DLQ entry, got %d", n)
+	}
+}
+
+func TestProcessOrderRoutesInvalidOrderToDLQ(t *testing.T) {
+	engine, _ := newTestEngine(t)
+
+	engine.processOrder(redis.XMessage{ID: "1-0", Values: map[string]interface{}{"order": `{"order_id":"x"}`}})
+
+	entries := engine.redisClient.XRange(context.Background(), engine.dlqStreamName, "-", "+").Val()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 DLQ entry, got %d", len(entries))
+	}
+	if entries[0].Values["reason"] != dlqReasonValidation {
+		t.Errorf("unexpected reason %v", entries[0].Values["reason"])
+	}
+}
+
+func TestDLQReplayEndpoint(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	seedDLQ(t, engine, testOrder("http-1"), dlqReasonDecodeError)
+
+	req := httptest.NewRequest(http.MethodPost, "/dlq/replay", strings.NewReader(`{"reason":"decode_error"}`))
+	rec := httptest.NewRecorder()
+	engine.routes().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var summary ReplaySummary
+	json.NewDecoder(rec.Body).Decode(&summary)
+	if summary.Replayed != 1 {
+		t.Errorf("expected 1 replayed, got %+v", summary)
+	}
+}
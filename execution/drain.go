@@ -0,0 +1,81 @@
+// ==============================================================================
+// Draining - a warm shutdown that lets resting orders trade out
+// ==============================================================================
+// POST /admin/drain puts the engine into drain mode ahead of a planned
+// shutdown. From then on new submissions are refused with 503 "unavailable"
+// over HTTP and Unavailable over gRPC, and /ready reports "draining" so load
+// balancers route them elsewhere. Everything else carries on: orders already
+// queued are still processed, resting orders keep matching against them,
+// stops and pegs keep working, and cancels are honored.
+//
+// After DRAIN_GRACE_PERIOD the engine stops consuming, snapshots its books
+// so whatever still rests is recovered by the next process, and signals
+// Drained; main then exits. Draining can't be undone, and a second request
+// only reports when the drain ends. Unlike killing the process, nothing
+// in flight is lost and clients get the grace period to cancel or trade out.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// errDraining refuses submissions while the engine drains
+var errDraining = errors.New("engine is draining and accepts no new orders")
+
+// Drain starts draining unless already draining, and returns when the
+// engine will stop consuming orders
+func (e *ExecutionEngine) Drain(grace time.Duration) time.Time {
+	if e.draining.Swap(true) {
+		return time.UnixMilli(e.drainDeadline.Load())
+	}
+	deadline := e.now().Add(grace)
+	e.drainDeadline.Store(deadline.UnixMilli())
+	log.Printf("Draining: refusing new orders, shutting down in %s", grace)
+
+	go func() {
+		time.Sleep(grace)
+		e.finishDrain()
+	}()
+	return deadline
+}
+
+// Draining reports whether the engine is draining
+func (e *ExecutionEngine) Draining() bool {
+	return e.draining.Load()
+}
+
+// Drained is closed once a drain has finished and the engine may exit
+func (e *ExecutionEngine) Drained() <-chan struct{} {
+	return e.drained
+}
+
+// finishDrain stops consumption, persists the books and signals Drained
+func (e *ExecutionEngine) finishDrain() {
+	e.Pause()
+	if err := e.SnapshotBooks(e.ctx); err != nil {
+		log.Printf("Error snapshotting books at the end of the drain: %v", err)
+	}
+	log.Printf("Drain complete")
+	if e.drained != nil {
+		close(e.drained)
+	}
+}
+
+// handleDrain serves POST /admin/drain
+func (e *ExecutionEngine) handleDrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	deadline := e.Drain(e.config.DrainGracePeriod)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"draining":    true,
+		"shutdown_at": deadline.UnixMilli(),
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDrainRefusesNewOrdersButLetsRestingOnesTrade(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	engine.ctx = ctx
+	t.Cleanup(cancel)
+	engine.config.DrainGracePeriod = 500 * time.Millisecond
+	if err := ensureConsumerGroup(ctx, engine.redisClient, engine.streamName, engine.consumerGroup); err != nil {
+		t.Fatal(err)
+	}
+	handler := engine.routes()
+
+	call := func(method, path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec.Code
+	}
+
+	// Two resting asks, and a buy queued before the drain starts
+	submitToEngine(t, engine, limitOrder("ask-1", "AAPL", "sell", 100, 10))
+	submitToEngine(t, engine, limitOrder("ask-2", "AAPL", "sell", 101, 10))
+	if err := engine.SubmitOrder(ctx, limitOrder("queued-buy", "AAPL", "buy", 100, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := call(http.MethodPost, "/admin/drain"); code != http.StatusOK || !engine.Draining() {
+		t.Fatalf("drain: got %d, draining=%v", code, engine.Draining())
+	}
+	if code := call(http.MethodGet, "/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("/ready while draining: got %d, want 503", code)
+	}
+
+	if rec := postOrder(handler, ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("submit while draining: got %d, want 503", rec.Code)
+	}
+	if response, err := engine.CancelOrder("ask-2", ""); err != nil || response.Status != statusCancelled {
+		t.Fatalf("cancel while draining: %v", err)
+	}
+
+	// The queued buy is still processed and fills the resting ask
+	go engine.consumeOrders()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if response, ok := engine.GetOrder("ask-1"); ok && response.Status == statusFilled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("resting order did not fill while draining")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-engine.Drained():
+	case <-time.After(2 * time.Second):
+		t.Fatal("drain did not finish after the grace period")
+	}
+	if !engine.Paused() {
+		t.Error("consumption still running after the drain")
+	}
+}
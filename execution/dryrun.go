@@ -0,0 +1,156 @@
+// ==============================================================================
+// Dry run - pre-check an order without executing it
+// ==============================================================================
+// POST /orders?dry_run=true (or with an X-Dry-Run: true header) runs an order
+// through the same checks processOrder applies - validation, instrument rules,
+// venue capabilities, the price band, min fill ratio, post-only and the price
+// collar - and estimates its fill against the current book. The answer is an
+// OrderResponse with status "simulated", or "rejected" with the reason the
+// order would be refused. Nothing is written to the order stream, the book or
+// the order store, and no metrics are recorded.
+//
+// The estimate only counts resting liquidity, skipping the account's own
+// orders as self-trade prevention would.
+// ==============================================================================
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+)
+
+const dryRunHeader = "X-Dry-Run"
+
+// isDryRun reports whether a submission asks for a dry run
+func isDryRun(r *http.Request) bool {
+	for _, v := range []string{r.URL.Query().Get("dry_run"), r.Header.Get(dryRunHeader)} {
+		if dry, err := strconv.ParseBool(v); err == nil && dry {
+			return true
+		}
+	}
+	return false
+}
+
+// DryRun checks an order and estimates its fill without executing it
+func (e *ExecutionEngine) DryRun(order OrderRequest) *OrderResponse {
+	e.normalizeOrderSymbol(&order)
+	response := &OrderResponse{
+		OrderID:        order.OrderID,
+		ClientOrderID:  order.IdempotencyKey,
+		Symbol:         order.Symbol,
+		ClientSymbol:   order.ClientSymbol,
+		Status:         statusSimulated,
+		AcknowledgedAt: e.now().UnixMilli(),
+		Tags:           order.Tags,
+		ParentOrderID:  order.ParentOrderID,
+	}
+	reject := func(reason string) *OrderResponse {
+		response.Status = statusRejected
+		response.RejectReason = reason
+		return response
+	}
+
+	if err := validateOrder(&order); err != nil {
+		return reject(dlqReasonValidation)
+	}
+	if rej := applyInstrumentRules(&order, e.instruments, e.config.InstrumentPolicy); rej != nil {
+		return reject(rej.Reason)
+	}
+	if _, ok := e.orderPermitted(&order); !ok {
+		return reject(rejectSymbolNotPermitted)
+	}
+	if rej := e.checkCapabilities(&order); rej != nil {
+		return reject(rej.Reason)
+	}
+	if rej := e.checkPriceBand(&order); rej != nil {
+		return reject(rej.Reason)
+	}
+	if order.Type == "spread" {
+		return e.dryRunSpread(&order, response)
+	}
+
+	var price decimal.Decimal
+	if order.Type == "limit" {
+		price = order.LimitPrice
+	}
+
+	book, ok := e.lookupBook(order.Symbol)
+	if !ok {
+		book = NewOrderBook(order.Symbol)
+	}
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	if order.PegTo != "" {
+		pegged, ok := book.pegPrice(order.PegTo, order.PegOffset)
+		if !ok {
+			return reject(rejectNoPegReference)
+		}
+		price = pegged
+	}
+
+	if collar, ok := e.collarPrice(&order); ok {
+		price = collar
+	}
+
+	if order.Notional.IsPositive() {
+		e.sizeNotional(book, &order)
+		if !order.Quantity.IsPositive() {
+			return reject(rejectNotionalTooSmall)
+		}
+	}
+
+	if ratio := e.minFillRatio(&order); ratio > 0 {
+		required := decimal.NewFromFloat(ratio).Mul(order.Quantity)
+		if book.Available(order.Side, price, order.AccountID).LessThan(required) {
+			return reject(rejectInsufficientLiquidity)
+		}
+	}
+
+	if order.PostOnly && book.wouldCross(order.Side, price) {
+		return reject(rejectWouldTakeLiquidity)
+	}
+
+	response.Fills = book.Estimate(order.Side, price, order.Quantity, order.AccountID)
+	var notional decimal.Decimal
+	for _, f := range response.Fills {
+		response.FilledQuantity = response.FilledQuantity.Add(f.Quantity)
+		notional = notional.Add(f.Price.Mul(f.Quantity))
+	}
+	if response.FilledQuantity.IsPositive() {
+		response.FilledAvgPrice = notional.Div(response.FilledQuantity)
+	}
+	return response
+}
+
+// Estimate returns the fills an order would get against the book right now,
+// without changing it. A zero price means a market order.
+func (b *OrderBook) Estimate(side string, price decimal.Decimal, quantity decimal.Decimal, accountID string) []BookFill {
+	var fills []BookFill
+	remaining := quantity
+	for _, level := range *b.levels(oppositeSide(side)) {
+		if !remaining.IsPositive() || (price.IsPositive() && !crosses(side, price, level.price)) {
+			break
+		}
+		for _, o := range level.orders {
+			if !remaining.IsPositive() {
+				break
+			}
+			if accountID != "" && o.AccountID == accountID {
+				continue
+			}
+			qty := decimal.Min(o.Quantity, remaining)
+			fills = append(fills, BookFill{
+				RestingOrderID:  o.OrderID,
+				RestingSequence: o.Sequence,
+				Price:           level.price,
+				Quantity:        qty,
+				Liquidity:       liquidityTaker,
+			})
+			remaining = remaining.Sub(qty)
+		}
+	}
+	return fills
+}
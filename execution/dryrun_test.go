@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postDryRun(t *testing.T, handler http.Handler, target string, body string, header bool) *OrderResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	if header {
+		req.Header.Set(dryRunHeader, "true")
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var response OrderResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return &response
+}
+
+func TestDryRunEstimatesWithoutExecuting(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.executeOrder(context.Background(), limitOrder("a-100", "AAPL", "sell", 100, 5))
+	engine.executeOrder(context.Background(), limitOrder("a-101", "AAPL", "sell", 101, 5))
+	before := snapshotOf(engine)
+	handler := engine.routes()
+
+	order := `{"order_id":"dry-1","symbol":"AAPL","side":"buy","quantity":8,"type":"limit","limit_price":101,"time_in_force":"day"}`
+	for _, tc := range []struct {
+		name   string
+		target string
+		header bool
+	}{
+		{"query param", "/orders?dry_run=true", false},
+		{"header", "/orders", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			response := postDryRun(t, handler, tc.target, order, tc.header)
+			if response.Status != statusSimulated {
+				t.Fatalf("status = %q, want simulated", response.Status)
+			}
+			if !response.FilledQuantity.Equal(dec(8)) || !response.FilledAvgPrice.Equal(dec(100.375)) || len(response.Fills) != 2 {
+				t.Errorf("unexpected estimate %+v", response)
+			}
+		})
+	}
+
+	if n := engine.redisClient.XLen(context.Background(), engine.streamName).Val(); n != 0 {
+		t.Errorf("dry run wrote %d entries to the order stream", n)
+	}
+	if !sameBooks(snapshotOf(engine), before) {
+		t.Error("dry run changed the book")
+	}
+	if _, ok := engine.GetOrder("dry-1"); ok {
+		t.Error("dry run stored the order")
+	}
+}
+
+func TestDryRunReportsRejection(t *testing.T) {
+	engine, _ := newTestEngine(t)
+
+	order := `{"order_id":"dry-2","symbol":"AAPL","side":"buy","quantity":10,"type":"market","time_in_force":"day","min_fill_ratio":0.5}`
+	response := postDryRun(t, engine.routes(), "/orders?dry_run=1", order, false)
+	if response.Status != "rejected" || response.RejectReason != rejectInsufficientLiquidity {
+		t.Fatalf("expected insufficient_liquidity rejection, got %+v", response)
+	}
+}
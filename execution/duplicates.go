@@ -0,0 +1,104 @@
+// ==============================================================================
+// Duplicate orders - catch accidental resubmission of the same order
+// ==============================================================================
+// Idempotency keys only protect against the same submission arriving twice.
+// A client that resubmits an order under a new key (a double click, a retry
+// loop that mints keys) gets a second execution. With DUPLICATE_ORDER_WINDOW
+// set, orders are fingerprinted by their economics - account, symbol, side,
+// type, quantity, notional and prices - and an order identical to one
+// executed by the same account within the window is a duplicate.
+// DUPLICATE_ORDER_WINDOWS overrides the window per account as JSON, e.g.
+// {"acct-hft":"0s","acct-retail":"10s"}; "0s" turns the check off for an
+// account.
+//
+// DUPLICATE_ORDER_POLICY decides what happens to duplicates: reject (the
+// default) refuses them with "duplicate_order"; warn executes them but logs
+// and reports the earlier order as duplicate_of on the response.
+// Fingerprints are "<stream>.dedup.<hash>" keys holding the first order's ID
+// and expiring with the window, so every engine instance shares them. Orders
+// are checked when they execute, after the other risk checks.
+// ==============================================================================
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Duplicate order policies
+const (
+	duplicatePolicyReject = "reject"
+	duplicatePolicyWarn   = "warn"
+)
+
+// rejectDuplicateOrder is the reason for orders identical to a recent one
+const rejectDuplicateOrder = "duplicate_order"
+
+// parseDuplicateWindows parses per-account windows, e.g. {"acct-1":"5s"}
+func parseDuplicateWindows(raw string) (map[string]time.Duration, error) {
+	windows := map[string]time.Duration{}
+	if raw == "" {
+		return windows, nil
+	}
+	var values map[string]string
+	if err := json.Unmarshal([]byte(raw), &values); err != nil {
+		return nil, err
+	}
+	for account, value := range values {
+		window, err := time.ParseDuration(value)
+		if err != nil || window < 0 {
+			return nil, fmt.Errorf("invalid window %q for account %s", value, account)
+		}
+		windows[account] = window
+	}
+	return windows, nil
+}
+
+// duplicateWindow is the window that applies to account's orders
+func (e *ExecutionEngine) duplicateWindow(account string) time.Duration {
+	limits := e.riskLimits()
+	if window, ok := limits.DuplicateOrderWindows[account]; ok {
+		return window
+	}
+	return limits.DuplicateOrderWindow
+}
+
+// orderFingerprint identifies an order's economics
+func orderFingerprint(order *OrderRequest) string {
+	fields := []string{
+		order.AccountID, order.Symbol, order.Side, order.Type,
+		order.Quantity.String(), order.Notional.String(),
+		order.LimitPrice.String(), order.StopPrice.String(),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "|")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// duplicateOf records order's fingerprint and returns the ID of an identical
+// order the account executed within its window, or "" if there is none.
+// Redis errors let the order through.
+func (e *ExecutionEngine) duplicateOf(order *OrderRequest) string {
+	window := e.duplicateWindow(order.AccountID)
+	if window <= 0 {
+		return ""
+	}
+	key := e.keyPrefix + ".dedup." + orderFingerprint(order)
+	fresh, err := e.redisClient.SetNX(e.ctx, key, order.OrderID, window).Result()
+	if err != nil || fresh {
+		if err != nil {
+			log.Printf("Error checking order %s for duplicates: %v", order.OrderID, err)
+		}
+		return ""
+	}
+	first, err := e.redisClient.Get(e.ctx, key).Result()
+	if err != nil || first == order.OrderID {
+		return "" // expired meanwhile, or this order redelivered
+	}
+	return first
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDuplicateOrderWindow(t *testing.T) {
+	engine, mr := newTestEngine(t)
+	setRiskLimits(engine, func(l *RiskLimits) { l.DuplicateOrderWindow = 5 * time.Second })
+	status := func(id string) (OrderState, string) {
+		response, _ := engine.GetOrder(id)
+		return response.Status, response.RejectReason
+	}
+
+	first, second := testOrder("first"), testOrder("second")
+	submitToEngine(t, engine, &first)
+	submitToEngine(t, engine, &second)
+	if s, reason := status("second"); s != statusRejected || reason != rejectDuplicateOrder {
+		t.Fatalf("identical order within the window: %s/%s, want %s rejection", s, reason, rejectDuplicateOrder)
+	}
+
+	// A different price is a different order
+	other := limitOrder("other", "AAPL", "buy", 99, 10)
+	submitToEngine(t, engine, other)
+	if s, _ := status("other"); s == statusRejected {
+		t.Errorf("order at another price rejected as a duplicate")
+	}
+
+	mr.FastForward(6 * time.Second)
+	third := testOrder("third")
+	submitToEngine(t, engine, &third)
+	if s, reason := status("third"); s != statusFilled {
+		t.Errorf("identical order after the window: %s/%s, want filled", s, reason)
+	}
+}
+
+func TestDuplicateOrderWindowPerAccount(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	windows, err := parseDuplicateWindows(`{"acct-hft":"0s"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setRiskLimits(engine, func(l *RiskLimits) {
+		l.DuplicateOrderWindow = 5 * time.Second
+		l.DuplicateOrderWindows = windows
+	})
+
+	for _, account := range []string{"acct-hft", "acct-retail"} {
+		for _, id := range []string{"1", "2"} {
+			order := testOrder(account + "-" + id)
+			order.AccountID = account
+			submitToEngine(t, engine, &order)
+		}
+	}
+	if response, _ := engine.GetOrder("acct-hft-2"); response.Status != statusFilled {
+		t.Errorf("account with the check off: second order %s, want filled", response.Status)
+	}
+	if response, _ := engine.GetOrder("acct-retail-2"); response.RejectReason != rejectDuplicateOrder {
+		t.Errorf("account on the default window: second order %s, want %s rejection", response.Status, rejectDuplicateOrder)
+	}
+}
+
+func TestDuplicateOrderWarnPolicy(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	setRiskLimits(engine, func(l *RiskLimits) {
+		l.DuplicateOrderWindow = 5 * time.Second
+		l.DuplicateOrderPolicy = duplicatePolicyWarn
+	})
+
+	first, second := testOrder("first"), testOrder("second")
+	submitToEngine(t, engine, &first)
+	submitToEngine(t, engine, &second)
+
+	response, _ := engine.GetOrder("second")
+	if response.Status != statusFilled || response.DuplicateOf != "first" {
+		t.Errorf("warned duplicate: %s, duplicate_of %q; want filled, duplicate_of first", response.Status, response.DuplicateOf)
+	}
+}
+
+func TestParseDuplicateWindowsRejectsBadDurations(t *testing.T) {
+	for _, raw := range []string{`{"acct-1":"soon"}`, `{"acct-1":"-1s"}`, `[1]`} {
+		if _, err := parseDuplicateWindows(raw); err == nil {
+			t.Errorf("%s: accepted", raw)
+		}
+	}
+}
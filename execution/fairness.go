@@ -0,0 +1,136 @@
+// ==============================================================================
+// Fair scheduling - sharing execution throughput between accounts
+// ==============================================================================
+// The order stream is FIFO, so an account that floods it holds up everyone
+// queued behind it. With SCHEDULING_MODE=fair the consumer reads ahead up to
+// fairReadAhead orders, queues them by account and builds each batch by
+// weighted round-robin: every account with orders waiting takes a turn of up
+// to its weight in orders before the next account's turn. An account's
+// weight comes from ACCOUNT_WEIGHTS, a JSON object such as {"acct-mm": 4},
+// or is DEFAULT_ACCOUNT_WEIGHT. Each account's own orders keep their stream
+// order; only orders of different accounts are reordered.
+//
+// Orders read ahead stay unacknowledged until executed. While consumption
+// is paused they wait in the buffer and are executed once it resumes.
+// SCHEDULING_MODE=fifo (the default) executes orders in stream order.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Scheduling modes
+const (
+	schedulingModeFIFO = "fifo"
+	schedulingModeFair = "fair"
+)
+
+// fairReadAhead is how many orders fair scheduling buffers at most
+const fairReadAhead = 100
+
+// parseAccountWeights parses ACCOUNT_WEIGHTS, a JSON object of account to
+// positive weight
+func parseAccountWeights(raw string) (map[string]int, error) {
+	weights := map[string]int{}
+	if raw == "" {
+		return weights, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &weights); err != nil {
+		return nil, err
+	}
+	for account, weight := range weights {
+		if weight < 1 {
+			return nil, fmt.Errorf("invalid weight %d for %s", weight, account)
+		}
+	}
+	return weights, nil
+}
+
+// fairScheduler buffers orders by account and hands them out by weighted
+// round-robin. Only the consumer uses it, under batchMu.
+type fairScheduler struct {
+	weights       map[string]int
+	defaultWeight int
+
+	queues   map[string][]redis.XMessage
+	ring     []string // accounts with orders queued; the first has the turn
+	turn     int      // orders the first account has taken this turn
+	buffered int
+}
+
+func newFairScheduler(weights map[string]int, defaultWeight int) *fairScheduler {
+	if defaultWeight < 1 {
+		defaultWeight = 1
+	}
+	return &fairScheduler{
+		weights:       weights,
+		defaultWeight: defaultWeight,
+		queues:        map[string][]redis.XMessage{},
+	}
+}
+
+// weight is how many orders account takes per turn
+func (f *fairScheduler) weight(account string) int {
+	if w, ok := f.weights[account]; ok {
+		return w
+	}
+	return f.defaultWeight
+}
+
+// push queues a message of account
+func (f *fairScheduler) push(account string, message redis.XMessage) {
+	if len(f.queues[account]) == 0 {
+		f.ring = append(f.ring, account)
+	}
+	f.queues[account] = append(f.queues[account], message)
+	f.buffered++
+}
+
+// next takes up to n messages in weighted round-robin order
+func (f *fairScheduler) next(n int) []redis.XMessage {
+	var batch []redis.XMessage
+	for len(batch) < n && len(f.ring) > 0 {
+		account := f.ring[0]
+		queue := f.queues[account]
+		batch = append(batch, queue[0])
+		f.buffered--
+		f.turn++
+		if len(queue) == 1 {
+			delete(f.queues, account)
+			f.ring = f.ring[1:]
+			f.turn = 0
+			continue
+		}
+		f.queues[account] = queue[1:]
+		if f.turn >= f.weight(account) {
+			f.ring = append(f.ring[1:], account)
+			f.turn = 0
+		}
+	}
+	return batch
+}
+
+// nextFairBatch tops up the read-ahead buffer from the order stream and
+// takes the next batch from it. It only waits for new orders when nothing
+// is buffered.
+func (e *ExecutionEngine) nextFairBatch() []redis.XMessage {
+	if room := fairReadAhead - e.fair.buffered; room > 0 {
+		block := consumeBlock
+		if e.fair.buffered > 0 {
+			block = -1
+		}
+		for _, message := range e.readOrders(int64(room), block) {
+			account := ""
+			if order, ok := e.messageOrder(message); ok {
+				account = order.AccountID
+			}
+			e.fair.push(account, message)
+		}
+	}
+	return e.fair.next(consumeBatchSize)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestFairSchedulerWeightedRoundRobin(t *testing.T) {
+	f := newFairScheduler(map[string]int{"heavy": 2}, 1)
+	for i := 0; i < 4; i++ {
+		f.push("heavy", redis.XMessage{ID: fmt.Sprintf("heavy-%d", i)})
+		f.push("light", redis.XMessage{ID: fmt.Sprintf("light-%d", i)})
+	}
+
+	var got []string
+	for _, batch := range [][]redis.XMessage{f.next(5), f.next(5)} {
+		for _, message := range batch {
+			got = append(got, message.ID)
+		}
+	}
+	want := "heavy-0 heavy-1 light-0 heavy-2 heavy-3 light-1 light-2 light-3"
+	if strings.Join(got, " ") != want {
+		t.Errorf("order = %v, want %s", got, want)
+	}
+	if f.buffered != 0 || len(f.ring) != 0 {
+		t.Errorf("scheduler not empty: %d buffered, ring %v", f.buffered, f.ring)
+	}
+}
+
+func TestFloodingAccountDoesNotStarveOthers(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	ctx := context.Background()
+	engine.actors = nil
+	engine.fair = newFairScheduler(map[string]int{}, 1)
+	if err := ensureConsumerGroup(ctx, engine.redisClient, engine.streamName, engine.consumerGroup); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var executed []string
+	engine.executed = func(order *OrderRequest, response *OrderResponse) {
+		mu.Lock()
+		defer mu.Unlock()
+		executed = append(executed, order.AccountID)
+	}
+
+	// The light account queues behind a flood
+	submit := func(account string, n int) {
+		for i := 0; i < n; i++ {
+			order := limitOrder(fmt.Sprintf("%s-%d", account, i), "AAPL", "buy", float64(90+i%5), 1)
+			order.AccountID = account
+			if err := engine.SubmitOrder(ctx, order); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	submit("acct-flood", 60)
+	submit("acct-light", 5)
+
+	// Serial mode executes each batch on this goroutine
+	for batches := 0; len(executed) < 65; batches++ {
+		if batches == 20 {
+			t.Fatalf("only %d of 65 orders executed", len(executed))
+		}
+		engine.consumeBatch()
+	}
+
+	// Round-robin puts every light order within the first ten executed,
+	// where FIFO would have made them wait for all sixty
+	last := -1
+	for i, account := range executed {
+		if account == "acct-light" {
+			last = i
+		}
+	}
+	if last < 0 || last >= 10 {
+		t.Errorf("last light order executed at position %d, want within the first 10", last)
+	}
+}
@@ -0,0 +1,155 @@
+// ==============================================================================
+// Order book features - top-of-book imbalance, microprice and hidden share
+// ==============================================================================
+// Researchers log these as model inputs. They are computed from the live book
+// only when asked for, by GET /book/{symbol}/features or a Prometheus scrape,
+// so matching pays nothing for them.
+//
+//   imbalance  = (bidSize - askSize) / (bidSize + askSize), in [-1, 1]
+//   microprice = (bestBid*askSize + bestAsk*bidSize) / (bidSize + askSize)
+//
+// where the sizes are the total quantity resting at the best bid and ask.
+//
+// The hidden ratio is the share of the whole book, both sides, held in
+// iceberg reserves rather than displayed: hidden / (displayed + hidden). A
+// high ratio flags a book that looks thin but is deep. The book keeps both
+// totals as it changes, so reporting them never walks the reserves.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/shopspring/decimal"
+)
+
+// BookFeatures are derived top-of-book statistics for one symbol. Prices are
+// nil when the side they depend on is empty.
+type BookFeatures struct {
+	Symbol     string           `json:"symbol"`
+	BestBid    *decimal.Decimal `json:"best_bid,omitempty"`
+	BestAsk    *decimal.Decimal `json:"best_ask,omitempty"`
+	BidSize    decimal.Decimal  `json:"bid_size"`
+	AskSize    decimal.Decimal  `json:"ask_size"`
+	Imbalance  float64          `json:"imbalance"`
+	Microprice *decimal.Decimal `json:"microprice,omitempty"`
+
+	DisplayedQuantity decimal.Decimal `json:"displayed_quantity"` // shown across both sides
+	HiddenQuantity    decimal.Decimal `json:"hidden_quantity"`    // held in iceberg reserves
+	HiddenRatio       float64         `json:"hidden_ratio"`
+}
+
+// levelSize is the total quantity resting at a price level
+func levelSize(level *priceLevel) decimal.Decimal {
+	var size decimal.Decimal
+	for _, o := range level.orders {
+		size = size.Add(o.Quantity)
+	}
+	return size
+}
+
+// Features computes the book's top-of-book statistics
+func (b *OrderBook) Features() BookFeatures {
+	f := BookFeatures{Symbol: b.Symbol}
+	if len(b.bids) > 0 {
+		bid := b.bids[0].price
+		f.BestBid = &bid
+		f.BidSize = levelSize(b.bids[0])
+	}
+	if len(b.asks) > 0 {
+		ask := b.asks[0].price
+		f.BestAsk = &ask
+		f.AskSize = levelSize(b.asks[0])
+	}
+
+	total := f.BidSize.Add(f.AskSize)
+	if total.IsPositive() {
+		f.Imbalance = f.BidSize.Sub(f.AskSize).Div(total).InexactFloat64()
+	}
+	if f.BestBid != nil && f.BestAsk != nil {
+		micro := f.BestBid.Mul(f.AskSize).Add(f.BestAsk.Mul(f.BidSize)).Div(total)
+		f.Microprice = &micro
+	}
+
+	f.DisplayedQuantity, f.HiddenQuantity = b.displayed, b.hidden
+	if all := b.displayed.Add(b.hidden); all.IsPositive() {
+		f.HiddenRatio = b.hidden.Div(all).InexactFloat64()
+	}
+	return f
+}
+
+// bookFeatures computes features for symbol, reporting false if there is no
+// book for it
+func (e *ExecutionEngine) bookFeatures(symbol string) (BookFeatures, bool) {
+	book, ok := e.lookupBook(symbol)
+	if !ok {
+		return BookFeatures{}, false
+	}
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	return book.Features(), true
+}
+
+// handleBookFeatures serves GET /book/{symbol}/features
+func (e *ExecutionEngine) handleBookFeatures(w http.ResponseWriter, symbol string) {
+	features, ok := e.bookFeatures(symbol)
+	if !ok {
+		writeError(w, errCodeNotFound, "Unknown symbol")
+		return
+	}
+	json.NewEncoder(w).Encode(features)
+}
+
+// bookFeatureCollector exports book features as gauges, computed at scrape time
+type bookFeatureCollector struct {
+	engine      *ExecutionEngine
+	imbalance   *prometheus.Desc
+	microprice  *prometheus.Desc
+	hiddenRatio *prometheus.Desc
+}
+
+func newBookFeatureCollector(e *ExecutionEngine) *bookFeatureCollector {
+	return &bookFeatureCollector{
+		engine: e,
+		imbalance: prometheus.NewDesc("order_book_imbalance",
+			"Top-of-book size imbalance, (bid - ask) / (bid + ask)", []string{"symbol"}, nil),
+		microprice: prometheus.NewDesc("order_book_microprice",
+			"Size-weighted mid price of the best bid and ask", []string{"symbol"}, nil),
+		hiddenRatio: prometheus.NewDesc("order_book_hidden_ratio",
+			"Share of resting quantity held in iceberg reserves rather than displayed", []string{"symbol"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *bookFeatureCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.imbalance
+	ch <- c.microprice
+	ch <- c.hiddenRatio
+}
+
+// Collect implements prometheus.Collector
+func (c *bookFeatureCollector) Collect(ch chan<- prometheus.Metric) {
+	books := c.engine.allBooks()
+	features := make([]BookFeatures, 0, len(books))
+	for _, book := range books {
+		if c.engine.symbolLabels.label(book.Symbol) == book.Symbol {
+			book.mu.Lock()
+			features = append(features, book.Features())
+			book.mu.Unlock()
+		}
+	}
+
+	for _, f := range features {
+		if f.BidSize.IsZero() && f.AskSize.IsZero() {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.imbalance, prometheus.GaugeValue, f.Imbalance, f.Symbol)
+		ch <- prometheus.MustNewConstMetric(c.hiddenRatio, prometheus.GaugeValue, f.HiddenRatio, f.Symbol)
+		if f.Microprice != nil {
+			ch <- prometheus.MustNewConstMetric(c.microprice, prometheus.GaugeValue, f.Microprice.InexactFloat64(), f.Symbol)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// seedFeatureBook rests 30 @ 99.98 and 10 @ 99.97 on the bid, 10 @ 100.02
+// on the ask. Only the best levels count, so:
+//
+//	imbalance  = (30 - 10) / (30 + 10)               = 0.5
+//	microprice = (99.98*10 + 100.02*30) / (30 + 10)  = 100.01
+func seedFeatureBook(e *ExecutionEngine) {
+	e.executeOrder(context.Background(), limitOrder("b1", "AAPL", "buy", 99.98, 20))
+	e.executeOrder(context.Background(), limitOrder("b2", "AAPL", "buy", 99.98, 10))
+	e.executeOrder(context.Background(), limitOrder("b3", "AAPL", "buy", 99.97, 10))
+	e.executeOrder(context.Background(), limitOrder("a1", "AAPL", "sell", 100.02, 10))
+}
+
+func TestBookFeatures(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	seedFeatureBook(engine)
+
+	f, ok := engine.bookFeatures("AAPL")
+	if !ok {
+		t.Fatal("expected a book for AAPL")
+	}
+	if f.Imbalance != 0.5 {
+		t.Errorf("imbalance = %v, want 0.5", f.Imbalance)
+	}
+	if f.Microprice == nil || !f.Microprice.Equal(dec(100.01)) {
+		t.Errorf("microprice = %v, want 100.01", f.Microprice)
+	}
+	if !f.BidSize.Equal(dec(30)) || !f.AskSize.Equal(dec(10)) {
+		t.Errorf("sizes = %s/%s, want 30/10", f.BidSize, f.AskSize)
+	}
+
+	// A one-sided book has an imbalance but no microprice
+	engine.executeOrder(context.Background(), limitOrder("b4", "MSFT", "buy", 300, 5))
+	f, _ = engine.bookFeatures("MSFT")
+	if f.Imbalance != 1 || f.Microprice != nil || f.BestAsk != nil {
+		t.Errorf("unexpected one-sided features %+v", f)
+	}
+}
+
+func TestBookFeaturesEndpointAndMetrics(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	seedFeatureBook(engine)
+	handler := engine.routes()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/book/AAPL/features", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var body map[string]interface{}
+	json.NewDecoder(rec.Body).Decode(&body)
+	if body["imbalance"] != 0.5 || body["microprice"] != 100.01 {
+		t.Errorf("unexpected body %v", body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/book/TSLA/features", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown symbol: expected 404, got %d", rec.Code)
+	}
+
+	expected := `
+# HELP order_book_imbalance Top-of-book size imbalance, (bid - ask) / (bid + ask)
+# TYPE order_book_imbalance gauge
+order_book_imbalance{symbol="AAPL"} 0.5
+# HELP order_book_microprice Size-weighted mid price of the best bid and ask
+# TYPE order_book_microprice gauge
+order_book_microprice{symbol="AAPL"} 100.01
+`
+	if err := testutil.GatherAndCompare(engine.registry, strings.NewReader(expected), "order_book_imbalance", "order_book_microprice"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBookFeaturesHiddenRatio(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.executeOrder(context.Background(), limitOrder("b1", "AAPL", "buy", 99, 20))
+	engine.executeOrder(context.Background(), icebergOrder("ice", "sell", 100, 40, 10))
+
+	// 20 + 10 displayed, 30 in the iceberg's reserve
+	f, _ := engine.bookFeatures("AAPL")
+	if !f.DisplayedQuantity.Equal(dec(30)) || !f.HiddenQuantity.Equal(dec(30)) || f.HiddenRatio != 0.5 {
+		t.Errorf("displayed %s, hidden %s, ratio %v; want 30, 30, 0.5", f.DisplayedQuantity, f.HiddenQuantity, f.HiddenRatio)
+	}
+	expected := `
+# HELP order_book_hidden_ratio Share of resting quantity held in iceberg reserves rather than displayed
+# TYPE order_book_hidden_ratio gauge
+order_book_hidden_ratio{symbol="AAPL"} 0.5
+`
+	if err := testutil.GatherAndCompare(engine.registry, strings.NewReader(expected), "order_book_hidden_ratio"); err != nil {
+		t.Error(err)
+	}
+
+	// Filling the slice and half the next leaves 5 shown and 20 in reserve
+	engine.executeOrder(context.Background(), limitOrder("b2", "AAPL", "buy", 100, 15))
+	f, _ = engine.bookFeatures("AAPL")
+	if !f.DisplayedQuantity.Equal(dec(25)) || !f.HiddenQuantity.Equal(dec(20)) {
+		t.Errorf("after the fill: displayed %s, hidden %s; want 25 and 20", f.DisplayedQuantity, f.HiddenQuantity)
+	}
+
+	// Cancelling the iceberg takes its reserve with it
+	book := engine.bookFor("AAPL")
+	book.mu.Lock()
+	book.Cancel("ice")
+	book.mu.Unlock()
+	f, _ = engine.bookFeatures("AAPL")
+	if !f.DisplayedQuantity.Equal(dec(20)) || !f.HiddenQuantity.IsZero() || f.HiddenRatio != 0 {
+		t.Errorf("after the cancel: displayed %s, hidden %s, ratio %v; want 20, 0, 0", f.DisplayedQuantity, f.HiddenQuantity, f.HiddenRatio)
+	}
+}
@@ -0,0 +1,172 @@
+// ==============================================================================
+// Fee models - commissions and venue fees charged on fills
+// ==============================================================================
+// FEE_MODEL selects how fills are charged:
+//   none         nothing (default)
+//   per_share    FEE_RATE per share, as commission
+//   per_trade    FEE_RATE per execution, as commission
+//   bps          FEE_RATE basis points of notional, as commission
+//   maker_taker  FEE_MAKER_RATE or FEE_TAKER_RATE per share, as venue fees;
+//                a negative rate is a rebate
+//
+// An order that trades on arrival removes liquidity (taker); a resting order
+// that is later hit added it (maker). Charges land on the OrderResponse and in
+// the position's cost basis.
+// ==============================================================================
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// Supported values for FEE_MODEL
+const (
+	feeModelNone       = "none"
+	feeModelPerShare   = "per_share"
+	feeModelPerTrade   = "per_trade"
+	feeModelBps        = "bps"
+	feeModelMakerTaker = "maker_taker"
+)
+
+// Liquidity flags: whether a fill added liquidity to the book or removed it
+const (
+	liquidityMaker = "maker"
+	liquidityTaker = "taker"
+)
+
+// FeeFill is an execution to be charged
+type FeeFill struct {
+	Quantity  decimal.Decimal
+	Price     decimal.Decimal
+	Liquidity string // liquidityMaker or liquidityTaker
+}
+
+// FeeCharge is what one execution costs
+type FeeCharge struct {
+	Commission decimal.Decimal // charged by the broker
+	Fees       decimal.Decimal // charged (or rebated, if negative) by the venue
+}
+
+// Total is the full cost of the execution
+func (c FeeCharge) Total() decimal.Decimal {
+	return c.Commission.Add(c.Fees)
+}
+
+// FeeModel decides what an execution costs
+type FeeModel interface {
+	Charge(fill FeeFill) FeeCharge
+}
+
+// NoFees charges nothing
+type NoFees struct{}
+
+// Charge implements FeeModel
+func (NoFees) Charge(FeeFill) FeeCharge { return FeeCharge{} }
+
+// PerShareFee charges a fixed commission per share
+type PerShareFee struct {
+	Rate decimal.Decimal
+}
+
+// Charge implements FeeModel
+func (m PerShareFee) Charge(fill FeeFill) FeeCharge {
+	return FeeCharge{Commission: fill.Quantity.Mul(m.Rate)}
+}
+
+// PerTradeFee charges a fixed commission per execution
+type PerTradeFee struct {
+	Amount decimal.Decimal
+}
+
+// Charge implements FeeModel
+func (m PerTradeFee) Charge(FeeFill) FeeCharge {
+	return FeeCharge{Commission: m.Amount}
+}
+
+// BpsFee charges a commission in basis points of notional
+type BpsFee struct {
+	Bps decimal.Decimal
+}
+
+var basisPoint = decimal.New(1, -4)
+
+// Charge implements FeeModel
+func (m BpsFee) Charge(fill FeeFill) FeeCharge {
+	return FeeCharge{Commission: fill.Quantity.Mul(fill.Price).Mul(m.Bps).Mul(basisPoint)}
+}
+
+// MakerTakerFee charges venue fees per share depending on whether the fill
+// added or removed liquidity
+type MakerTakerFee struct {
+	MakerRate decimal.Decimal
+	TakerRate decimal.Decimal
+}
+
+// Charge implements FeeModel
+func (m MakerTakerFee) Charge(fill FeeFill) FeeCharge {
+	rate := m.TakerRate
+	if fill.Liquidity == liquidityMaker {
+		rate = m.MakerRate
+	}
+	return FeeCharge{Fees: fill.Quantity.Mul(rate)}
+}
+
+// newFeeModel builds the model named in the config
+func newFeeModel(name string, rate string, makerRate string, takerRate string) (FeeModel, error) {
+	parse := func(setting string, value string) (decimal.Decimal, error) {
+		if value == "" {
+			return decimal.Zero, nil
+		}
+		d, err := decimal.NewFromString(value)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("invalid %s %q: %w", setting, value, err)
+		}
+		return d, nil
+	}
+
+	switch name {
+	case "", feeModelNone:
+		return NoFees{}, nil
+	case feeModelPerShare, feeModelPerTrade, feeModelBps:
+		r, err := parse("FEE_RATE", rate)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case feeModelPerShare:
+			return PerShareFee{Rate: r}, nil
+		case feeModelPerTrade:
+			return PerTradeFee{Amount: r}, nil
+		}
+		return BpsFee{Bps: r}, nil
+	case feeModelMakerTaker:
+		maker, err := parse("FEE_MAKER_RATE", makerRate)
+		if err != nil {
+			return nil, err
+		}
+		taker, err := parse("FEE_TAKER_RATE", takerRate)
+		if err != nil {
+			return nil, err
+		}
+		return MakerTakerFee{MakerRate: maker, TakerRate: taker}, nil
+	default:
+		return nil, fmt.Errorf("unknown fee model %q", name)
+	}
+}
+
+// chargeFill applies the engine's fee model to one execution
+func (e *ExecutionEngine) chargeFill(quantity decimal.Decimal, price decimal.Decimal, liquidity string) FeeCharge {
+	if e.feeModel == nil || !quantity.IsPositive() {
+		return FeeCharge{}
+	}
+	return e.feeModel.Charge(FeeFill{Quantity: quantity, Price: price, Liquidity: liquidity})
+}
+
+// addCharge adds an execution's charges to a response
+func (r *OrderResponse) addCharge(c FeeCharge) {
+	r.Commission = r.Commission.Add(c.Commission)
+	r.Fees = r.Fees.Add(c.Fees)
+}
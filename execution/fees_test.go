@@ -0,0 +1,91 @@
+package main
+
+import "testing"
+
+func TestFeeModels(t *testing.T) {
+	// 200 shares at 50.25: notional 10050
+	fill := FeeFill{Quantity: dec(200), Price: dec(50.25)}
+
+	tests := []struct {
+		name       string
+		model      string
+		rate       string
+		liquidity  string
+		commission float64
+		fees       float64
+	}{
+		{"none", feeModelNone, "", liquidityTaker, 0, 0},
+		{"per share", feeModelPerShare, "0.005", liquidityTaker, 1, 0},
+		{"per trade", feeModelPerTrade, "4.95", liquidityTaker, 4.95, 0},
+		{"bps", feeModelBps, "2", liquidityTaker, 2.01, 0},
+		{"taker", feeModelMakerTaker, "", liquidityTaker, 0, 0.6},
+		{"maker rebate", feeModelMakerTaker, "", liquidityMaker, 0, -0.4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, err := newFeeModel(tt.model, tt.rate, "-0.002", "0.003")
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := fill
+			f.Liquidity = tt.liquidity
+			charge := model.Charge(f)
+			if !charge.Commission.Equal(dec(tt.commission)) || !charge.Fees.Equal(dec(tt.fees)) {
+				t.Errorf("charge = %s commission, %s fees; want %v, %v", charge.Commission, charge.Fees, tt.commission, tt.fees)
+			}
+		})
+	}
+
+	if _, err := newFeeModel("per_share", "a nickel", "", ""); err == nil {
+		t.Error("invalid rate should fail")
+	}
+	if _, err := newFeeModel("per_smile", "", "", ""); err == nil {
+		t.Error("unknown model should fail")
+	}
+}
+
+func TestMakerTakerFeesOnBookFills(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.feeModel = MakerTakerFee{MakerRate: dec(-0.002), TakerRate: dec(0.003)}
+
+	maker := limitOrder("maker", "AAPL", "sell", 100, 1000)
+	maker.AccountID = "acct-maker"
+	submitToEngine(t, engine, maker)
+	taker := limitOrder("taker", "AAPL", "buy", 100, 1000)
+	taker.AccountID = "acct-taker"
+	submitToEngine(t, engine, taker)
+
+	if r, _ := engine.GetOrder("taker"); !r.Fees.Equal(dec(3)) {
+		t.Errorf("taker fees = %s, want 3", r.Fees)
+	}
+	if r, _ := engine.GetOrder("maker"); !r.Fees.Equal(dec(-2)) {
+		t.Errorf("maker fees = %s, want -2 (rebate)", r.Fees)
+	}
+
+	// Charges are part of the cost basis
+	if p, _ := engine.positions.Get("acct-taker", "AAPL"); !p.Quantity.Equal(dec(1000)) || !p.CostBasis.Equal(dec(100003)) {
+		t.Errorf("taker position %+v, want 1000 at cost 100003", p)
+	}
+	if p, _ := engine.positions.Get("acct-maker", "AAPL"); !p.Quantity.Equal(dec(-1000)) || !p.CostBasis.Equal(dec(-100002)) {
+		t.Errorf("maker position %+v, want -1000 at cost -100002", p)
+	}
+}
+
+func TestPositionTrackerReducesAndFlips(t *testing.T) {
+	tracker := NewPositionTracker()
+	tracker.Apply("a", "AAPL", "buy", dec(10), dec(100), dec(1))
+	tracker.Apply("a", "AAPL", "sell", dec(4), dec(110), dec(1))
+
+	p, _ := tracker.Get("a", "AAPL")
+	if !p.Quantity.Equal(dec(6)) || !p.CostBasis.Equal(dec(600.6)) || !p.Fees.Equal(dec(2)) {
+		t.Fatalf("after reduce: %+v", p)
+	}
+
+	// Selling 10 closes the 6 and opens a 4 short at 120
+	tracker.Apply("a", "AAPL", "sell", dec(10), dec(120), dec(0))
+	p, _ = tracker.Get("a", "AAPL")
+	if !p.Quantity.Equal(dec(-4)) || !p.CostBasis.Equal(dec(-480)) || !p.AvgCost().Equal(dec(120)) {
+		t.Fatalf("after flip: %+v", p)
+	}
+}
@@ -0,0 +1,720 @@
+// ==============================================================================
+// FIX order entry - a FIX 4.4 acceptor in front of the engine
+// ==============================================================================
+// With FIX_PORT set, institutional clients can trade over FIX instead of
+// HTTP/JSON. The acceptor speaks the subset of FIX 4.4 order entry needs:
+//
+//   Logon (A), Heartbeat (0), TestRequest (1), Reject (3), Logout (5)
+//   NewOrderSingle (D)      queued like POST /orders
+//   OrderCancelRequest (F)  cancels like DELETE /orders/{id}
+//   ExecutionReport (8)     sent for every update of the session's orders
+//   OrderCancelReject (9)   sent when a cancel fails
+//
+// A session starts with a Logon whose TargetCompID is FIX_COMP_ID and whose
+// MsgSeqNum is 1: each connection is a new session and both sides' sequence
+// numbers start over, as if ResetSeqNumFlag were always set. When API keys
+// are configured the Logon must carry one in Password (554), and the
+// session's orders belong to its account.
+//
+// Every later message must come from the same CompIDs with the next
+// MsgSeqNum. There is no message recovery: a sequence gap, or a number too
+// low without PossDupFlag, ends the session with a Logout. Messages with a
+// bad checksum are ignored. Each side sends a Heartbeat when it has been
+// quiet for HeartBtInt; a client quiet for twice that is disconnected.
+//
+// NewOrderSingle maps ClOrdID to the idempotency key, so a resent order
+// executes once, and the engine generates the OrderID. Symbol, Side (1 buy,
+// 2 sell), OrderQty, OrdType (1 market, 2 limit, 3 stop), Price, StopPx and
+// TimeInForce (0 day, 1 gtc, 3 ioc, 4 fok) carry over. An ExecutionReport
+// reports the order's state (OrdStatus/ExecType), its CumQty, AvgPx and
+// LeavesQty and, for a fill, the LastQty and LastPx of what just traded.
+// ==============================================================================
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	fixBeginString = "FIX.4.4"
+	fixSOH         = '\x01'
+	fixTimeFormat  = "20060102-15:04:05.000"
+
+	// maxFIXBodyLength bounds the BodyLength a client may announce
+	maxFIXBodyLength = 64 << 10
+
+	fixLogonTimeout     = 10 * time.Second
+	fixWriteTimeout     = 5 * time.Second
+	defaultFIXHeartbeat = 30 * time.Second
+)
+
+// FIX tags the acceptor reads or writes
+const (
+	fixTagAvgPx          = 6
+	fixTagBeginString    = 8
+	fixTagBodyLength     = 9
+	fixTagCheckSum       = 10
+	fixTagClOrdID        = 11
+	fixTagCumQty         = 14
+	fixTagExecID         = 17
+	fixTagLastPx         = 31
+	fixTagLastQty        = 32
+	fixTagMsgSeqNum      = 34
+	fixTagMsgType        = 35
+	fixTagOrderID        = 37
+	fixTagOrderQty       = 38
+	fixTagOrdStatus      = 39
+	fixTagOrdType        = 40
+	fixTagOrigClOrdID    = 41
+	fixTagPossDupFlag    = 43
+	fixTagPrice          = 44
+	fixTagRefSeqNum      = 45
+	fixTagSenderCompID   = 49
+	fixTagSendingTime    = 52
+	fixTagSide           = 54
+	fixTagSymbol         = 55
+	fixTagTargetCompID   = 56
+	fixTagText           = 58
+	fixTagTimeInForce    = 59
+	fixTagTransactTime   = 60
+	fixTagEncryptMethod  = 98
+	fixTagStopPx         = 99
+	fixTagCxlRejReason   = 102
+	fixTagHeartBtInt     = 108
+	fixTagTestReqID      = 112
+	fixTagResetSeqNum    = 141
+	fixTagExecType       = 150
+	fixTagLeavesQty      = 151
+	fixTagRefMsgType     = 372
+	fixTagRejectReason   = 373
+	fixTagCxlRejResponse = 434
+	fixTagPassword       = 554
+)
+
+// FIX message types
+const (
+	fixMsgHeartbeat         = "0"
+	fixMsgTestRequest       = "1"
+	fixMsgReject            = "3"
+	fixMsgLogout            = "5"
+	fixMsgExecutionReport   = "8"
+	fixMsgOrderCancelReject = "9"
+	fixMsgLogon             = "A"
+	fixMsgNewOrderSingle    = "D"
+	fixMsgCancelRequest     = "F"
+)
+
+// errFIXGarbled marks a message to ignore rather than end the session over
+var errFIXGarbled = errors.New("garbled FIX message")
+
+// fixField is one tag=value pair
+type fixField struct {
+	tag   int
+	value string
+}
+
+// fixMessage is a FIX message's fields in wire order, without the
+// BeginString, BodyLength and CheckSum framing
+type fixMessage struct {
+	fields []fixField
+}
+
+func (m *fixMessage) add(tag int, value string) *fixMessage {
+	m.fields = append(m.fields, fixField{tag, value})
+	return m
+}
+
+// get returns the value of a tag, or "" if the message hasn't got it
+func (m *fixMessage) get(tag int) string {
+	for _, f := range m.fields {
+		if f.tag == tag {
+			return f.value
+		}
+	}
+	return ""
+}
+
+// encode frames the message for the wire
+func (m *fixMessage) encode() []byte {
+	var body bytes.Buffer
+	for _, f := range m.fields {
+		fmt.Fprintf(&body, "%d=%s%c", f.tag, f.value, fixSOH)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "%d=%s%c%d=%d%c", fixTagBeginString, fixBeginString, fixSOH, fixTagBodyLength, body.Len(), fixSOH)
+	msg.Write(body.Bytes())
+	fmt.Fprintf(&msg, "%d=%03d%c", fixTagCheckSum, fixChecksum(msg.Bytes()), fixSOH)
+	return msg.Bytes()
+}
+
+// fixChecksum is the sum of a message's bytes modulo 256
+func fixChecksum(data []byte) int {
+	sum := 0
+	for _, b := range data {
+		sum += int(b)
+	}
+	return sum % 256
+}
+
+// readFIXMessage reads one message. It returns errFIXGarbled for a message
+// framed correctly but failing its checksum or field syntax, and other
+// errors when the stream itself can't be followed.
+func readFIXMessage(r *bufio.Reader) (*fixMessage, error) {
+	begin, err := readFIXField(r, fixTagBeginString)
+	if err != nil {
+		return nil, err
+	}
+	if begin != fixBeginString {
+		return nil, fmt.Errorf("unsupported BeginString %q", begin)
+	}
+	lengthField, err := readFIXField(r, fixTagBodyLength)
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(lengthField)
+	if err != nil || length <= 0 || length > maxFIXBodyLength {
+		return nil, fmt.Errorf("invalid BodyLength %q", lengthField)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	checksum, err := readFIXField(r, fixTagCheckSum)
+	if err != nil {
+		return nil, err
+	}
+
+	header := fmt.Sprintf("%d=%s%c%d=%s%c", fixTagBeginString, begin, fixSOH, fixTagBodyLength, lengthField, fixSOH)
+	if checksum != fmt.Sprintf("%03d", fixChecksum(append([]byte(header), body...))) {
+		return nil, errFIXGarbled
+	}
+	if body[len(body)-1] != fixSOH {
+		return nil, errFIXGarbled
+	}
+	m := &fixMessage{}
+	for _, field := range strings.Split(string(body[:len(body)-1]), string(fixSOH)) {
+		tag, value, ok := strings.Cut(field, "=")
+		n, err := strconv.Atoi(tag)
+		if !ok || err != nil {
+			return nil, errFIXGarbled
+		}
+		m.add(n, value)
+	}
+	if len(m.fields) == 0 || m.fields[0].tag != fixTagMsgType {
+		return nil, errFIXGarbled
+	}
+	return m, nil
+}
+
+// readFIXField reads a framing field, which must carry tag and fit in r's
+// buffer
+func readFIXField(r *bufio.Reader, tag int) (string, error) {
+	line, err := r.ReadSlice(fixSOH)
+	if err != nil {
+		return "", err
+	}
+	field := string(line)
+	got, value, ok := strings.Cut(strings.TrimSuffix(field, string(fixSOH)), "=")
+	if !ok || got != strconv.Itoa(tag) {
+		return "", fmt.Errorf("expected tag %d, got %q", tag, field)
+	}
+	return value, nil
+}
+
+// fixOrder is what a session remembers of an order it sent
+type fixOrder struct {
+	clOrdID       string
+	cancelClOrdID string // of the pending cancel request, if any
+	side          string
+	symbol        string
+	quantity      decimal.Decimal
+	cumQty        decimal.Decimal // as last reported
+	notional      decimal.Decimal // of the quantity last reported filled
+	lastStatus    OrderState
+	reports       int
+}
+
+// fixSession is one logged-on FIX connection
+type fixSession struct {
+	engine       *ExecutionEngine
+	conn         net.Conn
+	reader       *bufio.Reader
+	compID       string // ours
+	clientCompID string
+	account      string
+	heartbeat    time.Duration
+	inSeq        int // next expected; read by the reading goroutine only
+
+	sendMu   sync.Mutex
+	outSeq   int
+	lastSent time.Time
+
+	mu        sync.Mutex
+	orders    map[string]*fixOrder // by order ID
+	byClOrdID map[string]string
+}
+
+// FIXServer serves FIX order entry on port
+func (e *ExecutionEngine) FIXServer(port string) {
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatalf("Failed to listen for FIX on port %s: %v", port, err)
+	}
+	log.Printf("FIX acceptor starting on port %s", port)
+	log.Fatal(e.serveFIX(lis))
+}
+
+// serveFIX accepts FIX sessions on lis until it is closed
+func (e *ExecutionEngine) serveFIX(lis net.Listener) error {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go e.runFIXSession(conn)
+	}
+}
+
+// runFIXSession logs a connection on and serves it until it ends
+func (e *ExecutionEngine) runFIXSession(conn net.Conn) {
+	defer conn.Close()
+	s := &fixSession{
+		engine:    e,
+		conn:      conn,
+		reader:    bufio.NewReader(conn),
+		compID:    e.config.FIXCompID,
+		orders:    make(map[string]*fixOrder),
+		byClOrdID: make(map[string]string),
+	}
+	if !s.logon() {
+		return
+	}
+	log.Printf("FIX session %s logged on", s.clientCompID)
+
+	// Subscribe before taking orders so no update of theirs can slip past
+	updates := e.subscribers.subscribe("")
+	defer e.subscribers.unsubscribe(updates)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.forwardUpdates(updates, done)
+	}()
+
+	s.serve()
+	close(done)
+	<-stopped
+	log.Printf("FIX session %s ended", s.clientCompID)
+}
+
+// logon reads and answers the Logon opening a session, reporting whether
+// the session is on
+func (s *fixSession) logon() bool {
+	s.conn.SetReadDeadline(time.Now().Add(fixLogonTimeout))
+	msg, err := readFIXMessage(s.reader)
+	if err != nil || msg.get(fixTagMsgType) != fixMsgLogon {
+		return false // not a FIX session: disconnect without a word
+	}
+	s.clientCompID = msg.get(fixTagSenderCompID)
+	s.inSeq = 2
+	if msg.get(fixTagTargetCompID) != s.compID || s.clientCompID == "" {
+		s.logout("Unknown TargetCompID")
+		return false
+	}
+	if msg.get(fixTagMsgSeqNum) != "1" {
+		s.logout("Logon MsgSeqNum must be 1")
+		return false
+	}
+	s.heartbeat = defaultFIXHeartbeat
+	if v := msg.get(fixTagHeartBtInt); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			s.logout("Invalid HeartBtInt")
+			return false
+		}
+		s.heartbeat = time.Duration(seconds) * time.Second
+	}
+	if s.engine.authEnabled() {
+		account, ok, err := s.engine.lookupAPIKey(s.engine.ctx, msg.get(fixTagPassword))
+		if err != nil {
+			log.Printf("Error looking up API key: %v", err)
+			s.logout("Authentication unavailable")
+			return false
+		}
+		if !ok {
+			s.logout("Invalid API key")
+			return false
+		}
+		s.account = account
+	}
+	return s.send(fixMsgLogon,
+		fixField{fixTagEncryptMethod, "0"},
+		fixField{fixTagHeartBtInt, strconv.Itoa(int(s.heartbeat / time.Second))},
+		fixField{fixTagResetSeqNum, "Y"},
+	) == nil
+}
+
+// serve handles the session's messages until it ends
+func (s *fixSession) serve() {
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(2 * s.heartbeat))
+		msg, err := readFIXMessage(s.reader)
+		if errors.Is(err, errFIXGarbled) {
+			log.Printf("FIX session %s: ignoring garbled message", s.clientCompID)
+			continue
+		}
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				s.logout("Heartbeat timeout")
+			} else if !errors.Is(err, io.EOF) {
+				s.logout(err.Error())
+			}
+			return
+		}
+
+		if msg.get(fixTagSenderCompID) != s.clientCompID || msg.get(fixTagTargetCompID) != s.compID {
+			s.logout("CompID problem")
+			return
+		}
+		seq, err := strconv.Atoi(msg.get(fixTagMsgSeqNum))
+		switch {
+		case err != nil:
+			s.logout("Missing MsgSeqNum")
+			return
+		case seq < s.inSeq && msg.get(fixTagPossDupFlag) == "Y":
+			continue // already processed
+		case seq < s.inSeq:
+			s.logout(fmt.Sprintf("MsgSeqNum too low, expecting %d but received %d", s.inSeq, seq))
+			return
+		case seq > s.inSeq:
+			s.logout(fmt.Sprintf("MsgSeqNum gap, expecting %d but received %d", s.inSeq, seq))
+			return
+		}
+		s.inSeq++
+
+		switch msg.get(fixTagMsgType) {
+		case fixMsgHeartbeat:
+		case fixMsgTestRequest:
+			s.send(fixMsgHeartbeat, fixField{fixTagTestReqID, msg.get(fixTagTestReqID)})
+		case fixMsgLogout:
+			s.send(fixMsgLogout)
+			return
+		case fixMsgNewOrderSingle:
+			s.newOrderSingle(msg)
+		case fixMsgCancelRequest:
+			s.cancelRequest(msg)
+		default:
+			s.send(fixMsgReject,
+				fixField{fixTagRefSeqNum, strconv.Itoa(seq)},
+				fixField{fixTagRefMsgType, msg.get(fixTagMsgType)},
+				fixField{fixTagRejectReason, "11"}, // invalid MsgType
+				fixField{fixTagText, "Unsupported MsgType"},
+			)
+		}
+	}
+}
+
+// send sends a message with the session's header
+func (s *fixSession) send(msgType string, body ...fixField) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	s.outSeq++
+	msg := &fixMessage{}
+	msg.add(fixTagMsgType, msgType).
+		add(fixTagSenderCompID, s.compID).
+		add(fixTagTargetCompID, s.clientCompID).
+		add(fixTagMsgSeqNum, strconv.Itoa(s.outSeq)).
+		add(fixTagSendingTime, s.engine.now().UTC().Format(fixTimeFormat))
+	msg.fields = append(msg.fields, body...)
+
+	s.conn.SetWriteDeadline(time.Now().Add(fixWriteTimeout))
+	_, err := s.conn.Write(msg.encode())
+	s.lastSent = time.Now()
+	return err
+}
+
+// logout ends the session, saying why
+func (s *fixSession) logout(text string) {
+	log.Printf("FIX session %s: logging out (%s)", s.clientCompID, text)
+	s.send(fixMsgLogout, fixField{fixTagText, text})
+}
+
+// forwardUpdates sends ExecutionReports for the updates of the session's
+// orders, and Heartbeats when it is otherwise quiet, until done is closed
+func (s *fixSession) forwardUpdates(updates *fillSubscription, done <-chan struct{}) {
+	ticker := time.NewTicker(s.heartbeat / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case update := <-updates.updates:
+			s.report(update)
+		case <-ticker.C:
+			s.sendMu.Lock()
+			quiet := time.Since(s.lastSent) >= s.heartbeat
+			s.sendMu.Unlock()
+			if quiet {
+				s.send(fixMsgHeartbeat)
+			}
+		}
+	}
+}
+
+// newOrderSingle queues a NewOrderSingle
+func (s *fixSession) newOrderSingle(msg *fixMessage) {
+	order, err := fixOrderRequest(msg)
+	if err == nil {
+		s.mu.Lock()
+		if _, dup := s.byClOrdID[order.IdempotencyKey]; dup {
+			err = fmt.Errorf("duplicate ClOrdID %s", order.IdempotencyKey)
+		}
+		s.mu.Unlock()
+	}
+	if err != nil {
+		s.rejectOrder(msg, err.Error())
+		return
+	}
+	if s.account != "" {
+		order.AccountID = s.account
+	}
+
+	// Track it first: it may execute before SubmitOrder returns
+	s.engine.assignOrderID(order)
+	s.mu.Lock()
+	s.orders[order.OrderID] = &fixOrder{clOrdID: order.IdempotencyKey, side: msg.get(fixTagSide), symbol: order.Symbol, quantity: order.Quantity}
+	s.byClOrdID[order.IdempotencyKey] = order.OrderID
+	s.mu.Unlock()
+
+	if err := s.engine.SubmitOrder(s.engine.ctx, order); err != nil {
+		s.mu.Lock()
+		delete(s.orders, order.OrderID)
+		delete(s.byClOrdID, order.IdempotencyKey)
+		s.mu.Unlock()
+		s.rejectOrder(msg, err.Error())
+	}
+}
+
+// fixOrderRequest converts a NewOrderSingle
+func fixOrderRequest(msg *fixMessage) (*OrderRequest, error) {
+	order := &OrderRequest{
+		IdempotencyKey: msg.get(fixTagClOrdID),
+		Symbol:         msg.get(fixTagSymbol),
+	}
+	if order.IdempotencyKey == "" {
+		return nil, fmt.Errorf("ClOrdID is required")
+	}
+	switch msg.get(fixTagSide) {
+	case "1":
+		order.Side = "buy"
+	case "2":
+		order.Side = "sell"
+	default:
+		return nil, fmt.Errorf("unsupported Side %q", msg.get(fixTagSide))
+	}
+	switch msg.get(fixTagOrdType) {
+	case "1":
+		order.Type = "market"
+	case "2":
+		order.Type = "limit"
+	case "3":
+		order.Type = "stop"
+	default:
+		return nil, fmt.Errorf("unsupported OrdType %q", msg.get(fixTagOrdType))
+	}
+	switch msg.get(fixTagTimeInForce) {
+	case "", "0":
+		order.TimeInForce = "day"
+	case "1":
+		order.TimeInForce = "gtc"
+	case "3":
+		order.TimeInForce = "ioc"
+	case "4":
+		order.TimeInForce = "fok"
+	default:
+		return nil, fmt.Errorf("unsupported TimeInForce %q", msg.get(fixTagTimeInForce))
+	}
+	for _, f := range []struct {
+		tag  int
+		name string
+		dst  *decimal.Decimal
+	}{
+		{fixTagOrderQty, "OrderQty", &order.Quantity},
+		{fixTagPrice, "Price", &order.LimitPrice},
+		{fixTagStopPx, "StopPx", &order.StopPrice},
+	} {
+		if v := msg.get(f.tag); v != "" {
+			d, err := decimal.NewFromString(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", f.name, v)
+			}
+			*f.dst = d
+		}
+	}
+	return order, nil
+}
+
+// rejectOrder answers a NewOrderSingle that wasn't queued
+func (s *fixSession) rejectOrder(msg *fixMessage, text string) {
+	clOrdID := msg.get(fixTagClOrdID)
+	s.send(fixMsgExecutionReport,
+		fixField{fixTagOrderID, "NONE"},
+		fixField{fixTagClOrdID, clOrdID},
+		fixField{fixTagExecID, clOrdID + "-rejected"},
+		fixField{fixTagExecType, "8"},
+		fixField{fixTagOrdStatus, "8"},
+		fixField{fixTagSymbol, msg.get(fixTagSymbol)},
+		fixField{fixTagSide, msg.get(fixTagSide)},
+		fixField{fixTagLeavesQty, "0"},
+		fixField{fixTagCumQty, "0"},
+		fixField{fixTagAvgPx, "0"},
+		fixField{fixTagText, text},
+	)
+}
+
+// cancelRequest cancels an order of the session
+func (s *fixSession) cancelRequest(msg *fixMessage) {
+	clOrdID, origClOrdID := msg.get(fixTagClOrdID), msg.get(fixTagOrigClOrdID)
+	s.mu.Lock()
+	orderID, ok := s.byClOrdID[origClOrdID]
+	if ok {
+		s.orders[orderID].cancelClOrdID = clOrdID
+	}
+	s.mu.Unlock()
+
+	reason, text := "1", "Unknown order" // CxlRejReason 1: unknown order
+	if ok {
+		_, err := s.engine.CancelOrder(orderID, s.account)
+		if err == nil {
+			return // the ExecutionReport follows with the update
+		}
+		s.mu.Lock()
+		s.orders[orderID].cancelClOrdID = ""
+		s.mu.Unlock()
+		reason, text = "99", err.Error()
+		if errors.Is(err, errOrderNotWorking) {
+			reason = "0" // too late to cancel
+		}
+	}
+	status := "8"
+	if ok {
+		if response, found := s.engine.GetOrder(orderID); found {
+			status, _ = fixOrdStatus(response.Status)
+		}
+	}
+	s.send(fixMsgOrderCancelReject,
+		fixField{fixTagOrderID, orDefault(orderID, "NONE")},
+		fixField{fixTagClOrdID, clOrdID},
+		fixField{fixTagOrigClOrdID, origClOrdID},
+		fixField{fixTagOrdStatus, status},
+		fixField{fixTagCxlRejResponse, "1"}, // to an OrderCancelRequest
+		fixField{fixTagCxlRejReason, reason},
+		fixField{fixTagText, text},
+	)
+}
+
+// fixOrdStatus maps an order state to OrdStatus, reporting false for states
+// no ExecutionReport is sent for
+func fixOrdStatus(status OrderState) (string, bool) {
+	switch status {
+	case statusHeld:
+		return "A", true // pending new
+	case statusWorking:
+		return "0", true
+	case statusPartiallyFilled:
+		return "1", true
+	case statusFilled:
+		return "2", true
+	case statusCancelled:
+		return "4", true
+	case statusRejected:
+		return "8", true
+	}
+	return "", false
+}
+
+// report sends the ExecutionReport for an update of one of the session's
+// orders
+func (s *fixSession) report(r *OrderResponse) {
+	ordStatus, ok := fixOrdStatus(r.Status)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	o, ok := s.orders[r.OrderID]
+	if !ok || (r.Status == o.lastStatus && r.FilledQuantity.Equal(o.cumQty)) {
+		s.mu.Unlock()
+		return
+	}
+	lastQty := r.FilledQuantity.Sub(o.cumQty)
+	notional := r.FilledQuantity.Mul(r.FilledAvgPrice)
+	lastPx := decimal.Zero
+	if lastQty.IsPositive() {
+		lastPx = notional.Sub(o.notional).Div(lastQty)
+	}
+	o.cumQty, o.notional, o.lastStatus = r.FilledQuantity, notional, r.Status
+	o.reports++
+	execID := fmt.Sprintf("%s-%d", r.OrderID, o.reports)
+	clOrdID, origClOrdID := o.clOrdID, ""
+	if r.Status == statusCancelled && o.cancelClOrdID != "" {
+		clOrdID, origClOrdID = o.cancelClOrdID, o.clOrdID
+	}
+	leaves := decimal.Zero
+	if _, open := orderTransitions[r.Status]; open {
+		leaves = decimal.Max(o.quantity.Sub(r.FilledQuantity), decimal.Zero)
+	}
+	side, quantity := o.side, o.quantity
+	s.mu.Unlock()
+
+	execType := ordStatus
+	if lastQty.IsPositive() {
+		execType = "F" // trade
+	}
+	fields := []fixField{
+		{fixTagOrderID, r.OrderID},
+		{fixTagClOrdID, clOrdID},
+	}
+	if origClOrdID != "" {
+		fields = append(fields, fixField{fixTagOrigClOrdID, origClOrdID})
+	}
+	fields = append(fields,
+		fixField{fixTagExecID, execID},
+		fixField{fixTagExecType, execType},
+		fixField{fixTagOrdStatus, ordStatus},
+		fixField{fixTagSymbol, orDefault(r.ClientSymbol, r.Symbol)},
+		fixField{fixTagSide, side},
+		fixField{fixTagOrderQty, quantity.String()},
+		fixField{fixTagLastQty, lastQty.String()},
+		fixField{fixTagLastPx, lastPx.String()},
+		fixField{fixTagLeavesQty, leaves.String()},
+		fixField{fixTagCumQty, r.FilledQuantity.String()},
+		fixField{fixTagAvgPx, r.FilledAvgPrice.String()},
+		fixField{fixTagTransactTime, s.engine.now().UTC().Format(fixTimeFormat)},
+	)
+	if r.RejectReason != "" {
+		fields = append(fields, fixField{fixTagText, r.RejectReason})
+	}
+	s.send(fixMsgExecutionReport, fields...)
+}
+
+// orDefault returns s, or def if s is empty
+func orDefault(s string, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
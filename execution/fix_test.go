@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fixClient is the initiator end of a FIX session in tests
+type fixClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	seq    int
+}
+
+// dialFIX serves FIX on engine and connects to it
+func dialFIX(t *testing.T, engine *ExecutionEngine) *fixClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	go engine.serveFIX(lis)
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &fixClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func (c *fixClient) send(msgType string, fields ...fixField) {
+	c.t.Helper()
+	c.seq++
+	msg := &fixMessage{}
+	msg.add(fixTagMsgType, msgType).
+		add(fixTagSenderCompID, "CLIENT").
+		add(fixTagTargetCompID, DefaultConfig().FIXCompID).
+		add(fixTagMsgSeqNum, strconv.Itoa(c.seq)).
+		add(fixTagSendingTime, time.Now().UTC().Format(fixTimeFormat))
+	msg.fields = append(msg.fields, fields...)
+	if _, err := c.conn.Write(msg.encode()); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read returns the next message other than a Heartbeat
+func (c *fixClient) read() *fixMessage {
+	c.t.Helper()
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		msg, err := readFIXMessage(c.reader)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if msg.get(fixTagMsgType) != fixMsgHeartbeat {
+			return msg
+		}
+	}
+}
+
+func (c *fixClient) logon() {
+	c.t.Helper()
+	c.send(fixMsgLogon, fixField{fixTagEncryptMethod, "0"}, fixField{fixTagHeartBtInt, "30"})
+	if reply := c.read(); reply.get(fixTagMsgType) != fixMsgLogon {
+		c.t.Fatalf("logon answered with %+v", reply)
+	}
+}
+
+// consumeInBackground runs the consumer until the test ends
+func consumeInBackground(t *testing.T, engine *ExecutionEngine) {
+	queueBatch(t, engine) // creates the consumer group
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				engine.consumeBatch()
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-stopped
+	})
+}
+
+func expectFields(t *testing.T, msg *fixMessage, want map[int]string) {
+	t.Helper()
+	for tag, value := range want {
+		if got := msg.get(tag); got != value {
+			t.Errorf("tag %d: got %q, want %q (message %+v)", tag, got, value, msg.fields)
+		}
+	}
+}
+
+func TestFIXNewOrderSingleIsFilled(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	submitToEngine(t, engine, limitOrder("ask-1", "AAPL", "sell", 100, 10))
+	consumeInBackground(t, engine)
+
+	client := dialFIX(t, engine)
+	client.logon()
+	client.send(fixMsgNewOrderSingle,
+		fixField{fixTagClOrdID, "cl-1"},
+		fixField{fixTagSymbol, "AAPL"},
+		fixField{fixTagSide, "1"},
+		fixField{fixTagOrderQty, "10"},
+		fixField{fixTagOrdType, "1"},
+	)
+
+	report := client.read()
+	expectFields(t, report, map[int]string{
+		fixTagMsgType:   fixMsgExecutionReport,
+		fixTagClOrdID:   "cl-1",
+		fixTagExecType:  "F",
+		fixTagOrdStatus: "2",
+		fixTagLastQty:   "10",
+		fixTagLastPx:    "100",
+		fixTagCumQty:    "10",
+		fixTagLeavesQty: "0",
+		fixTagAvgPx:     "100",
+		fixTagSide:      "1",
+	})
+	if _, _, _, ok := DecodeOrderID(report.get(fixTagOrderID)); !ok {
+		t.Errorf("OrderID %q wasn't generated by the engine", report.get(fixTagOrderID))
+	}
+	if seq := report.get(fixTagMsgSeqNum); seq != "2" {
+		t.Errorf("ExecutionReport MsgSeqNum %s, want 2 after the Logon", seq)
+	}
+}
+
+func TestFIXOrderCancelRequest(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	consumeInBackground(t, engine)
+
+	client := dialFIX(t, engine)
+	client.logon()
+	client.send(fixMsgNewOrderSingle,
+		fixField{fixTagClOrdID, "cl-1"},
+		fixField{fixTagSymbol, "AAPL"},
+		fixField{fixTagSide, "1"},
+		fixField{fixTagOrderQty, "10"},
+		fixField{fixTagOrdType, "2"},
+		fixField{fixTagPrice, "99.5"},
+	)
+	expectFields(t, client.read(), map[int]string{fixTagExecType: "0", fixTagOrdStatus: "0", fixTagLeavesQty: "10"})
+
+	client.send(fixMsgCancelRequest, fixField{fixTagClOrdID, "cx-1"}, fixField{fixTagOrigClOrdID, "cl-1"})
+	expectFields(t, client.read(), map[int]string{
+		fixTagMsgType:     fixMsgExecutionReport,
+		fixTagExecType:    "4",
+		fixTagOrdStatus:   "4",
+		fixTagClOrdID:     "cx-1",
+		fixTagOrigClOrdID: "cl-1",
+	})
+
+	client.send(fixMsgCancelRequest, fixField{fixTagClOrdID, "cx-2"}, fixField{fixTagOrigClOrdID, "unknown"})
+	expectFields(t, client.read(), map[int]string{fixTagMsgType: fixMsgOrderCancelReject, fixTagCxlRejReason: "1"})
+}
+
+func TestFIXSessionRules(t *testing.T) {
+	engine, _ := newTestEngine(t)
+
+	// Logon to another CompID
+	client := dialFIX(t, engine)
+	msg := &fixMessage{}
+	msg.add(fixTagMsgType, fixMsgLogon).add(fixTagSenderCompID, "CLIENT").add(fixTagTargetCompID, "ELSEWHERE").add(fixTagMsgSeqNum, "1")
+	client.conn.Write(msg.encode())
+	expectFields(t, client.read(), map[int]string{fixTagMsgType: fixMsgLogout, fixTagText: "Unknown TargetCompID"})
+
+	// Sequence gap
+	client = dialFIX(t, engine)
+	client.logon()
+	client.seq++
+	client.send(fixMsgTestRequest, fixField{fixTagTestReqID, "ping"})
+	expectFields(t, client.read(), map[int]string{fixTagMsgType: fixMsgLogout, fixTagText: "MsgSeqNum gap, expecting 2 but received 3"})
+
+	// TestRequest answered, unsupported types rejected
+	client = dialFIX(t, engine)
+	client.logon()
+	client.send(fixMsgTestRequest, fixField{fixTagTestReqID, "ping"})
+	client.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	heartbeat, err := readFIXMessage(client.reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFields(t, heartbeat, map[int]string{fixTagMsgType: fixMsgHeartbeat, fixTagTestReqID: "ping"})
+	client.send("V")
+	expectFields(t, client.read(), map[int]string{fixTagMsgType: fixMsgReject, fixTagRefMsgType: "V"})
+}
+
+func TestFIXGarbledMessagesAreIgnored(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	client := dialFIX(t, engine)
+	client.logon()
+
+	msg := &fixMessage{}
+	msg.add(fixTagMsgType, fixMsgTestRequest).add(fixTagSenderCompID, "CLIENT").add(fixTagTargetCompID, DefaultConfig().FIXCompID).add(fixTagMsgSeqNum, "2")
+	garbled := msg.encode()
+	garbled[len(garbled)-2]++ // break the checksum
+	client.conn.Write(garbled)
+
+	// The garbled message didn't take up sequence number 2
+	client.send(fixMsgLogout)
+	expectFields(t, client.read(), map[int]string{fixTagMsgType: fixMsgLogout})
+}
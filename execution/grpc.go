@@ -0,0 +1,272 @@
+// ==============================================================================
+// gRPC API - protobuf alternative to the HTTP/JSON endpoints
+// ==============================================================================
+// Internal latency-sensitive clients can use the ExecutionService defined in
+// proto/execution.proto instead of JSON over HTTP. It runs on GRPC_PORT next
+// to the HTTP server and goes through the same engine calls: SubmitOrder
+// queues to the order stream, CancelOrder and GetOrder act on the same state,
+// and SubscribeFills streams the updates every fill sink receives.
+//
+// Authentication matches HTTP: when API keys are configured, SubmitOrder and
+// CancelOrder need an "x-api-key" metadata entry, and orders are scoped to the
+// key's account. Reads stay open.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"execution-engine/executionpb"
+)
+
+// grpcAPIKeyMetadata is the metadata key carrying the API key
+const grpcAPIKeyMetadata = "x-api-key"
+
+// grpcMutatingMethods require an API key when authentication is enabled
+var grpcMutatingMethods = map[string]bool{
+	executionpb.ExecutionService_SubmitOrder_FullMethodName: true,
+	executionpb.ExecutionService_CancelOrder_FullMethodName: true,
+}
+
+// grpcService implements executionpb.ExecutionServiceServer on the engine
+type grpcService struct {
+	executionpb.UnimplementedExecutionServiceServer
+	engine *ExecutionEngine
+}
+
+// SubmitOrder implements executionpb.ExecutionServiceServer
+func (s *grpcService) SubmitOrder(ctx context.Context, in *executionpb.Order) (*executionpb.SubmitOrderResponse, error) {
+	order, err := orderFromProto(in)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if account, ok := accountFromContext(ctx); ok {
+		order.AccountID = account
+	}
+
+	if err := s.engine.SubmitOrder(ctx, order); err != nil {
+		if errors.Is(err, errSymbolNotPermitted) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		if errors.Is(err, errQueueFull) || errors.Is(err, errQueueSlow) || errors.Is(err, errDraining) {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		return nil, status.Error(codes.Internal, "failed to queue order")
+	}
+	return &executionpb.SubmitOrderResponse{OrderId: order.OrderID, Status: string(statusAccepted)}, nil
+}
+
+// CancelOrder implements executionpb.ExecutionServiceServer
+func (s *grpcService) CancelOrder(ctx context.Context, in *executionpb.CancelOrderRequest) (*executionpb.OrderUpdate, error) {
+	account, _ := accountFromContext(ctx)
+	response, err := s.engine.CancelOrder(in.GetOrderId(), account)
+	switch {
+	case errors.Is(err, errOrderNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, errOrderNotWorking), errors.Is(err, errMinRestTime):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, errNotOrderOwner):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return updateToProto(response), nil
+}
+
+// GetOrder implements executionpb.ExecutionServiceServer
+func (s *grpcService) GetOrder(ctx context.Context, in *executionpb.GetOrderRequest) (*executionpb.OrderUpdate, error) {
+	response, ok := s.engine.GetOrder(in.GetOrderId())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "order not found")
+	}
+	return updateToProto(response), nil
+}
+
+// SubscribeFills implements executionpb.ExecutionServiceServer
+func (s *grpcService) SubscribeFills(in *executionpb.SubscribeFillsRequest, stream executionpb.ExecutionService_SubscribeFillsServer) error {
+	sub := s.engine.subscribers.subscribe(in.GetSymbol())
+	defer s.engine.subscribers.unsubscribe(sub)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case response := <-sub.updates:
+			if err := stream.Send(updateToProto(response)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// grpcAccount authenticates a call like the HTTP middleware does, returning
+// the context to continue with
+func (e *ExecutionEngine) grpcAccount(ctx context.Context, method string) (context.Context, error) {
+	if !e.authEnabled() || !grpcMutatingMethods[method] {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get(grpcAPIKeyMetadata)
+	if len(keys) == 0 || keys[0] == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing API key")
+	}
+	account, ok, err := e.lookupAPIKey(ctx, keys[0])
+	if err != nil {
+		log.Printf("Error looking up API key: %v", err)
+		return nil, status.Error(codes.Unavailable, "authentication unavailable")
+	}
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	return context.WithValue(ctx, accountContextKey{}, account), nil
+}
+
+// authenticatedStream overrides the context of a server stream
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s authenticatedStream) Context() context.Context { return s.ctx }
+
+// newGRPCServer builds a gRPC server exposing the engine
+func (e *ExecutionEngine) newGRPCServer() *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, err := e.grpcAccount(ctx, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := e.grpcAccount(ss.Context(), info.FullMethod)
+			if err != nil {
+				return err
+			}
+			return handler(srv, authenticatedStream{ss, ctx})
+		}),
+	)
+	executionpb.RegisterExecutionServiceServer(server, &grpcService{engine: e})
+	return server
+}
+
+// GRPCServer serves the gRPC API on port
+func (e *ExecutionEngine) GRPCServer(port string) {
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatalf("Failed to listen for gRPC on port %s: %v", port, err)
+	}
+	log.Printf("gRPC server starting on port %s", port)
+	log.Fatal(e.newGRPCServer().Serve(lis))
+}
+
+// orderFromProto converts a protobuf order, parsing its decimal fields
+func orderFromProto(in *executionpb.Order) (*OrderRequest, error) {
+	order := &OrderRequest{
+		OrderID:        in.GetOrderId(),
+		Symbol:         in.GetSymbol(),
+		Side:           in.GetSide(),
+		Type:           in.GetType(),
+		TimeInForce:    in.GetTimeInForce(),
+		IdempotencyKey: in.GetIdempotencyKey(),
+		Timestamp:      in.GetTimestamp(),
+		AccountID:      in.GetAccountId(),
+		MinFillRatio:   in.GetMinFillRatio(),
+		PostOnly:       in.GetPostOnly(),
+		ActivateAt:     in.GetActivateAt(),
+		PegTo:          in.GetPegTo(),
+		OCOGroup:       in.GetOcoGroup(),
+		ReduceOnly:     in.GetReduceOnly(),
+		Tags:           in.GetTags(),
+	}
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *decimal.Decimal
+	}{
+		{"quantity", in.GetQuantity(), &order.Quantity},
+		{"limit_price", in.GetLimitPrice(), &order.LimitPrice},
+		{"stop_price", in.GetStopPrice(), &order.StopPrice},
+		{"display_quantity", in.GetDisplayQuantity(), &order.DisplayQuantity},
+		{"peg_offset", in.GetPegOffset(), &order.PegOffset},
+		{"notional", in.GetNotional(), &order.Notional},
+	} {
+		if f.value == "" {
+			continue
+		}
+		d, err := decimal.NewFromString(f.value)
+		if err != nil {
+			return nil, errors.New("invalid " + f.name)
+		}
+		*f.dst = d
+	}
+	for _, leg := range in.GetLegs() {
+		spreadLeg := SpreadLeg{Symbol: leg.GetSymbol(), Side: leg.GetSide()}
+		if leg.GetRatio() != "" {
+			ratio, err := decimal.NewFromString(leg.GetRatio())
+			if err != nil {
+				return nil, errors.New("invalid ratio of leg " + leg.GetSymbol())
+			}
+			spreadLeg.Ratio = ratio
+		}
+		order.Legs = append(order.Legs, spreadLeg)
+	}
+	return order, nil
+}
+
+// updateToProto converts an order update for the wire
+func updateToProto(r *OrderResponse) *executionpb.OrderUpdate {
+	out := &executionpb.OrderUpdate{
+		OrderId:        r.OrderID,
+		ClientOrderId:  r.ClientOrderID,
+		Symbol:         r.Symbol,
+		Status:         string(r.Status),
+		FilledQuantity: r.FilledQuantity.String(),
+		FilledAvgPrice: r.FilledAvgPrice.String(),
+		LatencyMs:      r.LatencyMs,
+		AcknowledgedAt: r.AcknowledgedAt,
+		RejectReason:   r.RejectReason,
+		Commission:     r.Commission.String(),
+		Fees:           r.Fees.String(),
+		LiquidityFlag:  r.LiquidityFlag,
+		Tags:           r.Tags,
+	}
+	out.Fills = fillsToProto(r.Fills)
+	for _, leg := range r.Legs {
+		out.Legs = append(out.Legs, &executionpb.LegExecution{
+			Symbol:         leg.Symbol,
+			Side:           leg.Side,
+			FilledQuantity: leg.FilledQuantity.String(),
+			FilledAvgPrice: leg.FilledAvgPrice.String(),
+			Fills:          fillsToProto(leg.Fills),
+		})
+	}
+	return out
+}
+
+// fillsToProto converts book fills for the wire
+func fillsToProto(fills []BookFill) []*executionpb.Fill {
+	var out []*executionpb.Fill
+	for _, f := range fills {
+		out = append(out, &executionpb.Fill{
+			RestingOrderId:  f.RestingOrderID,
+			RestingSequence: f.RestingSequence,
+			Price:           f.Price.String(),
+			Quantity:        f.Quantity.String(),
+			Liquidity:       f.Liquidity,
+		})
+	}
+	return out
+}
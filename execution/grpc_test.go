@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"execution-engine/executionpb"
+)
+
+// newGRPCClient serves engine's gRPC API over an in-memory listener
+func newGRPCClient(t *testing.T, engine *ExecutionEngine) executionpb.ExecutionServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	server := engine.newGRPCServer()
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return executionpb.NewExecutionServiceClient(conn)
+}
+
+// drainOrderStream executes everything queued on the order stream, standing
+// in for the consumer loop
+func drainOrderStream(t *testing.T, engine *ExecutionEngine) {
+	t.Helper()
+	messages, err := engine.redisClient.XRange(context.Background(), engine.streamName, "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range messages {
+		engine.processOrder(m)
+	}
+	engine.redisClient.Del(context.Background(), engine.streamName)
+}
+
+func TestGRPCSubmitAndStreamFill(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	client := newGRPCClient(t, engine)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fills, err := client.SubscribeFills(ctx, &executionpb.SubscribeFillsRequest{Symbol: "AAPL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The subscription is registered once the server has the stream open
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		engine.subscribers.mu.Lock()
+		n := len(engine.subscribers.subs)
+		engine.subscribers.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ack, err := client.SubmitOrder(ctx, &executionpb.Order{
+		OrderId: "grpc-1", Symbol: "AAPL", Side: "buy", Quantity: "25", Type: "market", TimeInForce: "day",
+	})
+	if err != nil || ack.GetStatus() != "accepted" {
+		t.Fatalf("SubmitOrder: %v (%v)", ack, err)
+	}
+	drainOrderStream(t, engine)
+
+	update, err := fills.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if update.GetOrderId() != "grpc-1" || update.GetStatus() != "filled" || update.GetFilledQuantity() != "25" {
+		t.Fatalf("unexpected update %v", update)
+	}
+
+	got, err := client.GetOrder(ctx, &executionpb.GetOrderRequest{OrderId: "grpc-1"})
+	if err != nil || got.GetStatus() != "filled" {
+		t.Fatalf("GetOrder: %v (%v)", got, err)
+	}
+}
+
+func TestGRPCCancelAndAuth(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.apiKeys = map[string]string{"key-1": "acct-1"}
+	client := newGRPCClient(t, engine)
+	ctx := context.Background()
+
+	resting := limitOrder("rest-1", "AAPL", "sell", 100, 10)
+	resting.AccountID = "acct-1"
+	submitToEngine(t, engine, resting)
+
+	if _, err := client.CancelOrder(ctx, &executionpb.CancelOrderRequest{OrderId: "rest-1"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("cancel without key: expected Unauthenticated, got %v", err)
+	}
+
+	authed := metadata.AppendToOutgoingContext(ctx, grpcAPIKeyMetadata, "key-1")
+	update, err := client.CancelOrder(authed, &executionpb.CancelOrderRequest{OrderId: "rest-1"})
+	if err != nil || update.GetStatus() != "cancelled" {
+		t.Fatalf("CancelOrder: %v (%v)", update, err)
+	}
+	if inBook(engine, "AAPL", "rest-1") {
+		t.Error("cancelled order still in the book")
+	}
+	if _, err := client.CancelOrder(authed, &executionpb.CancelOrderRequest{OrderId: "rest-1"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("second cancel: expected FailedPrecondition, got %v", err)
+	}
+}
+
+// BenchmarkOrderSerializationProto is the protobuf counterpart of
+// BenchmarkOrderSerialization
+func BenchmarkOrderSerializationProto(b *testing.B) {
+	order := &executionpb.Order{
+		OrderId:        "test-order-1",
+		Symbol:         "AAPL",
+		Side:           "buy",
+		Quantity:       "100",
+		Type:           "market",
+		TimeInForce:    "day",
+		IdempotencyKey: "test-key-1",
+		Timestamp:      time.Now().UnixMilli(),
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := proto.Marshal(order); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// ==============================================================================
+// Trading halts - stop trading one symbol while the rest carry on
+// ==============================================================================
+// POST /admin/halt/{symbol} halts a symbol and POST /admin/resume/{symbol}
+// lifts the halt. While a symbol is halted, orders for it are rejected with
+// "symbol_halted" when they are consumed, so nothing matches; orders already
+// resting stay in the book (and can still be cancelled) and trade again once
+// the symbol resumes.
+//
+// GET /halts lists the halted symbols with the time each halt began, and the
+// symbol_halted gauge is 1 for halted symbols and 0 for resumed ones.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// rejectSymbolHalted is the reason for orders in halted symbols
+const rejectSymbolHalted = "symbol_halted"
+
+// haltTable tracks which symbols are halted and since when
+type haltTable struct {
+	mu     sync.RWMutex
+	halted map[string]time.Time
+	gauge  *prometheus.GaugeVec
+	now    func() time.Time
+}
+
+func newHaltTable(registry *prometheus.Registry) *haltTable {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "symbol_halted",
+		Help: "Whether trading in the symbol is halted (1) or not (0)",
+	}, []string{"symbol"})
+	registry.MustRegister(gauge)
+	return &haltTable{halted: make(map[string]time.Time), gauge: gauge, now: time.Now}
+}
+
+// halt halts symbol, reporting false if it already was
+func (h *haltTable) halt(symbol string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.halted[symbol]; ok {
+		return false
+	}
+	h.halted[symbol] = h.now()
+	h.gauge.WithLabelValues(symbol).Set(1)
+	return true
+}
+
+// resume lifts the halt on symbol, reporting false if it wasn't halted
+func (h *haltTable) resume(symbol string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.halted[symbol]; !ok {
+		return false
+	}
+	delete(h.halted, symbol)
+	h.gauge.WithLabelValues(symbol).Set(0)
+	return true
+}
+
+// isHalted reports whether symbol is halted. A nil table halts nothing.
+func (h *haltTable) isHalted(symbol string) bool {
+	if h == nil {
+		return false
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.halted[symbol]
+	return ok
+}
+
+// snapshot returns the halted symbols and when each halt began
+func (h *haltTable) snapshot() map[string]time.Time {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make(map[string]time.Time, len(h.halted))
+	for symbol, since := range h.halted {
+		out[symbol] = since
+	}
+	return out
+}
+
+// HaltSymbol stops trading in symbol
+func (e *ExecutionEngine) HaltSymbol(symbol string) {
+	if e.halts.halt(symbol) {
+		log.Printf("Trading halted in %s", symbol)
+	}
+}
+
+// ResumeSymbol lifts the halt on symbol
+func (e *ExecutionEngine) ResumeSymbol(symbol string) {
+	if e.halts.resume(symbol) {
+		log.Printf("Trading resumed in %s", symbol)
+	}
+}
+
+// handleHalt serves POST /admin/halt/{symbol} and /admin/resume/{symbol}
+func (e *ExecutionEngine) handleHalt(halt bool) http.HandlerFunc {
+	prefix := "/admin/resume/"
+	if halt {
+		prefix = "/admin/halt/"
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		symbol := strings.TrimPrefix(r.URL.Path, prefix)
+		if symbol == "" || strings.Contains(symbol, "/") {
+			notFound(w)
+			return
+		}
+		if halt {
+			e.HaltSymbol(symbol)
+		} else {
+			e.ResumeSymbol(symbol)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"symbol": symbol, "halted": e.halts.isHalted(symbol)})
+	}
+}
+
+// handleListHalts serves GET /halts
+func (e *ExecutionEngine) handleListHalts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"halted": e.halts.snapshot()})
+}
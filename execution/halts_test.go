@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHaltRejectsOrdersAndResumeRestoresTrading(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	handler := engine.routes()
+	post := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		return rec.Code
+	}
+
+	submitToEngine(t, engine, limitOrder("resting-ask", "AAPL", "sell", 100, 10))
+	if code := post("/admin/halt/AAPL"); code != http.StatusOK {
+		t.Fatalf("halt: got %d", code)
+	}
+
+	submitToEngine(t, engine, limitOrder("halted-bid", "AAPL", "buy", 100, 10))
+	response, _ := engine.GetOrder("halted-bid")
+	if response.Status != statusRejected || response.RejectReason != rejectSymbolHalted {
+		t.Fatalf("order during halt: got %+v, want symbol_halted rejection", response)
+	}
+	if !inBook(engine, "AAPL", "resting-ask") {
+		t.Fatal("resting order removed by the halt")
+	}
+
+	// Other symbols keep trading
+	submitToEngine(t, engine, limitOrder("msft-bid", "MSFT", "buy", 400, 1))
+	if status := restingStatus(t, engine, "msft-bid"); status != statusWorking {
+		t.Errorf("order in an unhalted symbol: status %s", status)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/halts", nil))
+	var listed struct {
+		Halted map[string]interface{} `json:"halted"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil || len(listed.Halted) != 1 || listed.Halted["AAPL"] == nil {
+		t.Errorf("GET /halts = %s", rec.Body.String())
+	}
+	expected := `
+# HELP symbol_halted Whether trading in the symbol is halted (1) or not (0)
+# TYPE symbol_halted gauge
+symbol_halted{symbol="AAPL"} 1
+`
+	if err := testutil.GatherAndCompare(engine.registry, strings.NewReader(expected), "symbol_halted"); err != nil {
+		t.Error(err)
+	}
+
+	if code := post("/admin/resume/AAPL"); code != http.StatusOK {
+		t.Fatalf("resume: got %d", code)
+	}
+	submitToEngine(t, engine, limitOrder("resumed-bid", "AAPL", "buy", 100, 10))
+	if status := restingStatus(t, engine, "resumed-bid"); status != statusFilled {
+		t.Fatalf("order after resume: status %s, want filled against the resting order", status)
+	}
+	if got := testutil.ToFloat64(engine.halts.gauge.WithLabelValues("AAPL")); got != 0 {
+		t.Errorf("symbol_halted after resume = %v, want 0", got)
+	}
+}
@@ -0,0 +1,152 @@
+// ==============================================================================
+// Idempotency - execute each idempotency key at most once
+// ==============================================================================
+// Claiming a key is a single atomic step: LoadOrStore in the local cache, then
+// SET NX on "<prefix>.idempotency.<key>" so engines sharing a Redis agree too.
+// With the default IDEMPOTENCY_SCOPE=account, keys are namespaced by account
+// ("<account>:<key>") so two accounts reusing a client-generated key do not
+// swallow each other's orders; IDEMPOTENCY_SCOPE=global shares one namespace.
+// Exactly one caller wins and executes the order. A concurrent duplicate in
+// the same process waits for the winner and shares its response; a duplicate
+// of a key claimed by another engine looks up that engine's response by the
+// order ID stored under the Redis key.
+//
+// The local cache only holds keys in flight: once the winner has finished,
+// its entry is dropped and the Redis record (kept for idempotencyTTL) stands
+// in for it. A claim Redis failed to record is written again when the order
+// finishes, and stays cached if that fails too, as the process's only guard;
+// a claim released in between (the order never reached the broker) isn't,
+// so the order can still be retried.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// idempotencyTTL is how long an idempotency key is remembered in Redis
+const idempotencyTTL = 24 * time.Hour
+
+// Idempotency scopes
+const (
+	idempotencyScopeAccount = "account" // keys only collide within an account
+	idempotencyScopeGlobal  = "global"  // keys collide across all accounts
+)
+
+// validIdempotencyScope reports whether scope is a known IDEMPOTENCY_SCOPE
+func validIdempotencyScope(scope string) bool {
+	return scope == idempotencyScopeAccount || scope == idempotencyScopeGlobal
+}
+
+// idempotentResult is the outcome of the one execution of an idempotency key
+type idempotentResult struct {
+	done     chan struct{}
+	response *OrderResponse // set before done is closed; nil if unknown
+	recorded bool           // the claim is recorded in Redis
+	released atomic.Bool    // the key was given up for a retry
+}
+
+// finish publishes the winner's response to waiting duplicates
+func (r *idempotentResult) finish(response *OrderResponse) {
+	r.response = response
+	close(r.done)
+}
+
+// wait blocks until the winning execution has finished and returns its response
+func (r *idempotentResult) wait() *OrderResponse {
+	<-r.done
+	return r.response
+}
+
+// idempotencyKey returns order's idempotency key in its namespace. Orders
+// without an account share the global namespace.
+func (e *ExecutionEngine) idempotencyKey(order *OrderRequest) string {
+	if e.idempotencyScope == idempotencyScopeGlobal || order.AccountID == "" {
+		return order.IdempotencyKey
+	}
+	return order.AccountID + ":" + order.IdempotencyKey
+}
+
+// idempotencyRedisKey is where the claim on a namespaced key is recorded in
+// Redis
+func (e *ExecutionEngine) idempotencyRedisKey(key string) string {
+	return e.keyPrefix + ".idempotency." + key
+}
+
+// claimIdempotencyKey atomically claims order's idempotency key. It returns
+// the key's result and whether the caller won the claim; the winner must call
+// finish on the result once the order has been handled.
+func (e *ExecutionEngine) claimIdempotencyKey(ctx context.Context, order *OrderRequest) (*idempotentResult, bool) {
+	key := e.idempotencyKey(order)
+	claim := &idempotentResult{done: make(chan struct{})}
+	if existing, loaded := e.idempotencyCache.LoadOrStore(key, claim); loaded {
+		return existing.(*idempotentResult), false
+	}
+
+	won, err := e.redisClient.SetNX(ctx, e.idempotencyRedisKey(key), order.OrderID, idempotencyTTL).Result()
+	if err != nil {
+		// The local claim still guards this process
+		log.Printf("Error claiming idempotency key %s in Redis: %v", key, err)
+		return claim, true
+	}
+	if won {
+		claim.recorded = true
+		return claim, true
+	}
+
+	// Another engine executed it; share its response if we can find it
+	var response *OrderResponse
+	if ownerID, err := e.redisClient.Get(ctx, e.idempotencyRedisKey(key)).Result(); err == nil {
+		response, _ = e.GetOrder(ownerID)
+	} else if err != redis.Nil {
+		log.Printf("Error reading idempotency key %s: %v", key, err)
+	}
+	claim.finish(response)
+	e.idempotencyCache.CompareAndDelete(key, claim)
+	return claim, false
+}
+
+// settleIdempotencyKey finishes order's claim with the winner's response and
+// drops it from the local cache once Redis has a record of the key
+func (e *ExecutionEngine) settleIdempotencyKey(ctx context.Context, order *OrderRequest, claim *idempotentResult, response *OrderResponse) {
+	claim.finish(response)
+	if claim.released.Load() {
+		return
+	}
+	key := e.idempotencyKey(order)
+	if !claim.recorded {
+		if err := e.redisClient.SetNX(ctx, e.idempotencyRedisKey(key), order.OrderID, idempotencyTTL).Err(); err != nil {
+			log.Printf("Error recording idempotency key %s in Redis, keeping it locally: %v", key, err)
+			return
+		}
+	}
+	e.idempotencyCache.CompareAndDelete(key, claim)
+}
+
+// releaseIdempotencyKey forgets order's claimed key so it can be retried
+func (e *ExecutionEngine) releaseIdempotencyKey(ctx context.Context, order *OrderRequest) {
+	key := e.idempotencyKey(order)
+	if claim, ok := e.idempotencyCache.LoadAndDelete(key); ok {
+		claim.(*idempotentResult).released.Store(true)
+	}
+	if err := e.redisClient.Del(ctx, e.idempotencyRedisKey(key)).Err(); err != nil {
+		log.Printf("Error releasing idempotency key %s: %v", key, err)
+	}
+}
+
+// idempotencyKeyUsed reports whether order's key has been claimed by any
+// engine
+func (e *ExecutionEngine) idempotencyKeyUsed(ctx context.Context, order *OrderRequest) bool {
+	key := e.idempotencyKey(order)
+	if _, ok := e.idempotencyCache.Load(key); ok {
+		return true
+	}
+	n, err := e.redisClient.Exists(ctx, e.idempotencyRedisKey(key)).Result()
+	return err == nil && n > 0
+}
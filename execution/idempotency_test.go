@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// countingAdapter fills every order after a short delay, counting executions
+type countingAdapter struct {
+	executions atomic.Int64
+}
+
+func (a *countingAdapter) Execute(ctx context.Context, order *OrderRequest) (*OrderResponse, error) {
+	a.executions.Add(1)
+	time.Sleep(10 * time.Millisecond)
+	return &OrderResponse{OrderID: order.OrderID, Symbol: order.Symbol, Status: "filled", FilledQuantity: order.Quantity}, nil
+}
+
+func TestConcurrentDuplicatesExecuteOnce(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	adapter := &countingAdapter{}
+	engine.broker = adapter
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			order := testOrder(fmt.Sprintf("dup-%d", i))
+			order.IdempotencyKey = "same-key"
+			submitToEngine(t, engine, &order)
+		}(i)
+	}
+	wg.Wait()
+
+	if got := adapter.executions.Load(); got != 1 {
+		t.Fatalf("order executed %d times, want 1", got)
+	}
+
+	// Every submission sees the one shared outcome
+	var winner string
+	for i := 0; i < n; i++ {
+		response, ok := engine.GetOrder(fmt.Sprintf("dup-%d", i))
+		if !ok || response.Status != "filled" {
+			t.Fatalf("dup-%d: expected the shared fill, got %+v", i, response)
+		}
+		if winner == "" {
+			winner = response.OrderID
+		} else if response.OrderID != winner {
+			t.Errorf("dup-%d shares order %s, want %s", i, response.OrderID, winner)
+		}
+	}
+}
+
+func TestIdempotencyKeyClaimedAcrossEngines(t *testing.T) {
+	first, mr := newTestEngine(t)
+	second := NewExecutionEngine(mr.Host(), mr.Port(), "test-stream")
+	t.Cleanup(func() { second.redisClient.Close() })
+	adapter := &countingAdapter{}
+	first.broker = adapter
+	second.broker = adapter
+
+	order := testOrder("first")
+	order.IdempotencyKey = "shared-key"
+	submitToEngine(t, first, &order)
+
+	order.OrderID = "second"
+	submitToEngine(t, second, &order)
+
+	if got := adapter.executions.Load(); got != 1 {
+		t.Fatalf("order executed %d times across engines, want 1", got)
+	}
+	if response, ok := second.GetOrder("second"); !ok || response.OrderID != "first" {
+		t.Errorf("second engine should share the first engine's response, got %+v", response)
+	}
+}
+
+func TestIdempotencyKeysScopedByAccount(t *testing.T) {
+	engine, mr := newTestEngine(t)
+	adapter := &countingAdapter{}
+	engine.broker = adapter
+
+	submit := func(id, account string) {
+		order := testOrder(id)
+		order.AccountID = account
+		order.IdempotencyKey = "client-key-1"
+		submitToEngine(t, engine, &order)
+	}
+	submit("acct-a-1", "acct-a")
+	submit("acct-b-1", "acct-b")
+	submit("acct-a-2", "acct-a")
+
+	if got := adapter.executions.Load(); got != 2 {
+		t.Fatalf("executed %d orders, want one per account", got)
+	}
+	for _, id := range []string{"acct-a-1", "acct-b-1"} {
+		if response, ok := engine.GetOrder(id); !ok || response.OrderID != id {
+			t.Errorf("%s should have executed on its own, got %+v", id, response)
+		}
+	}
+	if response, ok := engine.GetOrder("acct-a-2"); !ok || response.OrderID != "acct-a-1" {
+		t.Errorf("same-account duplicate should share acct-a-1's response, got %+v", response)
+	}
+	for _, account := range []string{"acct-a", "acct-b"} {
+		if !mr.Exists("test-stream.idempotency." + account + ":client-key-1") {
+			t.Errorf("missing Redis claim for %s", account)
+		}
+	}
+}
+
+func TestGlobalIdempotencyScope(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.idempotencyScope = idempotencyScopeGlobal
+	adapter := &countingAdapter{}
+	engine.broker = adapter
+
+	for _, account := range []string{"acct-a", "acct-b"} {
+		order := testOrder("order-" + account)
+		order.AccountID = account
+		order.IdempotencyKey = "client-key-1"
+		submitToEngine(t, engine, &order)
+	}
+	if got := adapter.executions.Load(); got != 1 {
+		t.Fatalf("executed %d orders under global scope, want 1", got)
+	}
+}
+
+func TestFinishedIdempotencyKeysLeaveTheLocalCache(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	adapter := &countingAdapter{}
+	engine.broker = adapter
+
+	for i := 0; i < 5; i++ {
+		order := testOrder(fmt.Sprintf("order-%d", i))
+		submitToEngine(t, engine, &order)
+	}
+	cached := 0
+	engine.idempotencyCache.Range(func(_, _ interface{}) bool {
+		cached++
+		return true
+	})
+	if cached != 0 {
+		t.Errorf("%d finished keys still cached, want none", cached)
+	}
+
+	// Redis still remembers them
+	duplicate := testOrder("order-0-again")
+	duplicate.IdempotencyKey = "key-order-0"
+	submitToEngine(t, engine, &duplicate)
+	if got := adapter.executions.Load(); got != 5 {
+		t.Fatalf("executed %d orders, want the duplicate skipped", got)
+	}
+	if response, ok := engine.GetOrder("order-0-again"); !ok || response.OrderID != "order-0" {
+		t.Errorf("duplicate should share order-0's response, got %+v", response)
+	}
+}
+
+// failFirstClaim fails the first idempotency claim sent to Redis, as a
+// passing Redis blip would
+type failFirstClaim struct {
+	failed atomic.Bool
+}
+
+func (h *failFirstClaim) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	args := cmd.Args()
+	if cmd.Name() == "set" && len(args) > 1 && strings.Contains(fmt.Sprint(args[1]), ".idempotency.") && h.failed.CompareAndSwap(false, true) {
+		return ctx, errors.New("connection reset")
+	}
+	return ctx, nil
+}
+
+func (h *failFirstClaim) AfterProcess(ctx context.Context, cmd redis.Cmder) error { return nil }
+
+func (h *failFirstClaim) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *failFirstClaim) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func TestRetryAfterCircuitRejectionOfAnUnrecordedClaim(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.redisClient.AddHook(&failFirstClaim{})
+	engine.circuit = newCircuitBreaker(1, time.Minute, prometheus.NewRegistry())
+	engine.circuit.failure()
+
+	// Redis misses the claim, then the open breaker rejects the order and
+	// gives its key up
+	if r := submitNext(t, engine, "retry-me"); r.RejectReason != rejectBrokerUnavailable {
+		t.Fatalf("order with the breaker open: %+v, want %s", r, rejectBrokerUnavailable)
+	}
+	if engine.idempotencyKeyUsed(context.Background(), &OrderRequest{IdempotencyKey: "key-retry-me"}) {
+		t.Fatal("rejected order's key is still claimed")
+	}
+
+	// Once the breaker closes, the client's retry executes
+	engine.circuit = newCircuitBreaker(1, time.Minute, prometheus.NewRegistry())
+	retry := testOrder("retry-me-2")
+	retry.IdempotencyKey = "key-retry-me"
+	submitToEngine(t, engine, &retry)
+	if response, _ := engine.GetOrder("retry-me-2"); response == nil || response.OrderID != "retry-me-2" || response.Status != statusFilled {
+		t.Errorf("retry: %+v, want it filled in its own right", response)
+	}
+}
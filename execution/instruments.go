@@ -0,0 +1,91 @@
+// ==============================================================================
+// Instrument rules - tick size and lot size enforcement
+// ==============================================================================
+// Prices must be a multiple of the symbol's tick size and quantities a multiple
+// of its lot size, otherwise off-grid values create phantom price levels in the
+// book. Depending on the configured policy an off-grid order is either
+// snapped to the nearest valid value or rejected.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// Supported values for INSTRUMENT_POLICY
+const (
+	instrumentPolicyRound  = "round"
+	instrumentPolicyReject = "reject"
+)
+
+// Rejection reasons for orders violating instrument rules
+const (
+	rejectInvalidTick = "invalid_tick_size"
+	rejectInvalidLot  = "invalid_lot_size"
+)
+
+// InstrumentSpec describes the trading increments of a symbol
+type InstrumentSpec struct {
+	TickSize decimal.Decimal `json:"tick_size"`
+	LotSize  decimal.Decimal `json:"lot_size"`
+}
+
+// parseInstruments decodes the INSTRUMENTS config, a JSON object keyed by
+// symbol, e.g. {"AAPL":{"tick_size":0.01,"lot_size":1}}
+func parseInstruments(raw string) (map[string]InstrumentSpec, error) {
+	specs := map[string]InstrumentSpec{}
+	if raw == "" {
+		return specs, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// applyInstrumentRules snaps or rejects prices and quantities that are off
+// the symbol's tick and lot grid. Symbols without a spec are left untouched.
+func applyInstrumentRules(order *OrderRequest, specs map[string]InstrumentSpec, policy string) *rejection {
+	spec, ok := specs[order.Symbol]
+	if !ok {
+		return nil
+	}
+
+	if spec.TickSize.IsPositive() {
+		for _, price := range []*decimal.Decimal{&order.LimitPrice, &order.StopPrice} {
+			if price.IsZero() || onGrid(*price, spec.TickSize) {
+				continue
+			}
+			if policy == instrumentPolicyReject {
+				return &rejection{Reason: rejectInvalidTick, Detail: fmt.Sprintf("price %v is not a multiple of tick size %v", *price, spec.TickSize)}
+			}
+			*price = snapToGrid(*price, spec.TickSize)
+		}
+	}
+
+	if spec.LotSize.IsPositive() && !onGrid(order.Quantity, spec.LotSize) {
+		if policy == instrumentPolicyReject {
+			return &rejection{Reason: rejectInvalidLot, Detail: fmt.Sprintf("quantity %v is not a multiple of lot size %v", order.Quantity, spec.LotSize)}
+		}
+		order.Quantity = snapToGrid(order.Quantity, spec.LotSize)
+		if !order.Quantity.IsPositive() {
+			return &rejection{Reason: rejectInvalidLot, Detail: fmt.Sprintf("quantity is below lot size %v", spec.LotSize)}
+		}
+	}
+
+	return nil
+}
+
+// onGrid reports whether v is a whole multiple of increment
+func onGrid(v decimal.Decimal, increment decimal.Decimal) bool {
+	return v.Mod(increment).IsZero()
+}
+
+// snapToGrid rounds v to the nearest multiple of increment
+func snapToGrid(v decimal.Decimal, increment decimal.Decimal) decimal.Decimal {
+	return v.Div(increment).Round(0).Mul(increment)
+}
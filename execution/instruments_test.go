@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var testInstruments = map[string]InstrumentSpec{
+	"AAPL": {TickSize: dec(0.01), LotSize: dec(1)},
+}
+
+func TestInstrumentRulesRoundPolicy(t *testing.T) {
+	order := limitOrder("r1", "AAPL", "buy", 100.004, 10.4)
+	if rej := applyInstrumentRules(order, testInstruments, instrumentPolicyRound); rej != nil {
+		t.Fatalf("unexpected rejection: %v", rej)
+	}
+	if !order.LimitPrice.Equal(dec(100)) {
+		t.Errorf("price rounded to %v, want 100.00", order.LimitPrice)
+	}
+	if !order.Quantity.Equal(dec(10)) {
+		t.Errorf("quantity rounded to %v, want 10", order.Quantity)
+	}
+
+	order = limitOrder("r2", "AAPL", "sell", 100.016, 3)
+	applyInstrumentRules(order, testInstruments, instrumentPolicyRound)
+	if !order.LimitPrice.Equal(dec(100.02)) {
+		t.Errorf("price rounded to %v, want 100.02", order.LimitPrice)
+	}
+
+	// Rounding down to zero shares is still a rejection
+	order = limitOrder("r3", "AAPL", "buy", 100, 0.3)
+	if rej := applyInstrumentRules(order, testInstruments, instrumentPolicyRound); rej == nil || rej.Reason != rejectInvalidLot {
+		t.Errorf("expected %s for sub-lot quantity, got %v", rejectInvalidLot, rej)
+	}
+}
+
+func TestInstrumentRulesRejectPolicy(t *testing.T) {
+	cases := []struct {
+		name   string
+		order  *OrderRequest
+		reason string
+	}{
+		{"on grid", limitOrder("j1", "AAPL", "buy", 100.01, 10), ""},
+		{"sub-dollar on grid", limitOrder("j2", "AAPL", "buy", 0.3, 10), ""},
+		{"off tick", limitOrder("j3", "AAPL", "buy", 100.005, 10), rejectInvalidTick},
+		{"off lot", limitOrder("j4", "AAPL", "buy", 100.01, 10.5), rejectInvalidLot},
+		{"unconfigured symbol", limitOrder("j5", "MSFT", "buy", 100.0001, 0.5), ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			before := *tc.order
+			rej := applyInstrumentRules(tc.order, testInstruments, instrumentPolicyReject)
+			if tc.reason == "" {
+				if rej != nil {
+					t.Fatalf("unexpected rejection: %v", rej)
+				}
+			} else if rej == nil || rej.Reason != tc.reason {
+				t.Fatalf("expected %s, got %v", tc.reason, rej)
+			}
+			if !reflect.DeepEqual(*tc.order, before) {
+				t.Errorf("reject policy must not modify the order")
+			}
+		})
+	}
+}
+
+func TestProcessOrderRejectsOffGridOrder(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.instruments = testInstruments
+	engine.config.InstrumentPolicy = instrumentPolicyReject
+
+	payload, _ := json.Marshal(limitOrder("grid-1", "AAPL", "buy", 100.005, 10))
+	engine.processOrder(redis.XMessage{ID: "1-0", Values: map[string]interface{}{"order": string(payload)}})
+
+	response, ok := engine.GetOrder("grid-1")
+	if !ok {
+		t.Fatal("rejected order should be queryable")
+	}
+	if response.Status != "rejected" || response.RejectReason != rejectInvalidTick {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if n := engine.bookFor("AAPL").Len(); n != 0 {
+		t.Errorf("rejected order must not rest in the book, found %d", n)
+	}
+}
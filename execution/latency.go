@@ -0,0 +1,73 @@
+// ==============================================================================
+// Latency breakdown - where an order's time went
+// ==============================================================================
+// processOrder laps a stageTimer at the end of each stage, so the response
+// shows how long the order waited in the stream and how long validation,
+// pre-trade checks (capabilities, idempotency, circuit breaker) and the broker
+// took. Stage times are monotonic clock deltas. Queue wait is wall clock time
+// since the stream assigned the message ID, so it is subject to clock skew
+// between hosts and is zero when the ID carries no timestamp.
+// ==============================================================================
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LatencyBreakdown splits an order's latency by stage, in milliseconds
+type LatencyBreakdown struct {
+	QueueWaitMs  float64 `json:"queue_wait_ms"`
+	ValidationMs float64 `json:"validation_ms"`
+	RiskMs       float64 `json:"risk_ms"`
+	BrokerMs     float64 `json:"broker_ms"`
+	TotalMs      float64 `json:"total_ms"` // queue wait plus all processing
+}
+
+// stageTimer measures consecutive stages of processing one order
+type stageTimer struct {
+	start     time.Time
+	last      time.Time
+	now       func() time.Time
+	breakdown LatencyBreakdown
+}
+
+// newStageTimer starts timing, at start on the clock now, an order read from
+// the stream as messageID
+func newStageTimer(messageID string, start time.Time, now func() time.Time) *stageTimer {
+	s := &stageTimer{start: start, last: start, now: now}
+	if enqueued, ok := streamIDTime(messageID); ok && start.After(enqueued) {
+		s.breakdown.QueueWaitMs = durationMs(start.Sub(enqueued))
+	}
+	return s
+}
+
+// lap records the time since the previous lap into stage
+func (s *stageTimer) lap(stage *float64) {
+	now := s.now()
+	*stage = durationMs(now.Sub(s.last))
+	s.last = now
+}
+
+// finish returns the breakdown with the total filled in
+func (s *stageTimer) finish() *LatencyBreakdown {
+	b := s.breakdown
+	b.TotalMs = b.QueueWaitMs + durationMs(s.now().Sub(s.start))
+	return &b
+}
+
+// streamIDTime returns the time encoded in a Redis stream ID ("<ms>-<seq>")
+func streamIDTime(id string) (time.Time, bool) {
+	msPart, _, _ := strings.Cut(id, "-")
+	ms, err := strconv.ParseInt(msPart, 10, 64)
+	if err != nil || ms <= 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestLatencyBreakdownAddsUpToTotal(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.broker = &countingAdapter{} // 10ms per order
+
+	order := testOrder("timed-1")
+	orderJSON, _ := json.Marshal(order)
+	enqueued := time.Now().Add(-50 * time.Millisecond)
+	engine.processOrder(redis.XMessage{
+		ID:     fmt.Sprintf("%d-0", enqueued.UnixMilli()),
+		Values: map[string]interface{}{"order": string(orderJSON)},
+	})
+
+	response, ok := engine.GetOrder("timed-1")
+	if !ok || response.Latency == nil {
+		t.Fatalf("expected a latency breakdown, got %+v", response)
+	}
+	l := response.Latency
+
+	for name, v := range map[string]float64{"queue_wait": l.QueueWaitMs, "validation": l.ValidationMs, "risk": l.RiskMs, "broker": l.BrokerMs} {
+		if v <= 0 {
+			t.Errorf("%s_ms not populated: %v", name, v)
+		}
+	}
+	if l.QueueWaitMs < 50 {
+		t.Errorf("queue_wait_ms = %v, want at least 50", l.QueueWaitMs)
+	}
+	if l.BrokerMs < 10 {
+		t.Errorf("broker_ms = %v, want at least 10", l.BrokerMs)
+	}
+
+	sum := l.QueueWaitMs + l.ValidationMs + l.RiskMs + l.BrokerMs
+	if sum > l.TotalMs || math.Abs(l.TotalMs-sum) > 5 {
+		t.Errorf("stages sum to %vms, total is %vms", sum, l.TotalMs)
+	}
+}
+
+func TestStreamIDTime(t *testing.T) {
+	if ts, ok := streamIDTime("1700000000123-4"); !ok || ts.UnixMilli() != 1700000000123 {
+		t.Errorf("unexpected time %v (%v)", ts, ok)
+	}
+	for _, id := range []string{"0-1", "", "not-an-id"} {
+		if _, ok := streamIDTime(id); ok {
+			t.Errorf("%q should carry no timestamp", id)
+		}
+	}
+}
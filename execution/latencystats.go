@@ -0,0 +1,150 @@
+// ==============================================================================
+// Live latency quantiles - execution latency percentiles without Prometheus
+// ==============================================================================
+// execution_latency_milliseconds is a Prometheus histogram with coarse
+// buckets, and percentiles have to be computed from it at query time. The
+// engine also feeds every order's execution latency into a latencySketch and
+// serves its quantiles on GET /stats/latency:
+//
+//   {"count":1200,"p50_ms":0.41,"p90_ms":0.93,"p95_ms":1.2,"p99_ms":3.8,"max_ms":12.5}
+//
+// The sketch is a log-bucketed histogram (as in DDSketch): bucket i counts
+// latencies in (gamma^(i-1), gamma^i] microseconds, so every quantile is
+// within sketchRelativeError of the true value, and its memory is a fixed
+// array however many orders are processed. Latencies under a microsecond
+// share the first bucket and those over sketchMaxLatency the last. It covers
+// everything since startup; max is exact.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Sketch accuracy and range
+const (
+	sketchRelativeError = 0.01
+	sketchMaxLatency    = time.Hour
+)
+
+var (
+	sketchGamma    = (1 + sketchRelativeError) / (1 - sketchRelativeError)
+	sketchLogGamma = math.Log(sketchGamma)
+	sketchBuckets  = int(math.Ceil(math.Log(float64(sketchMaxLatency/time.Microsecond))/sketchLogGamma)) + 1
+)
+
+// latencySketch estimates quantiles of a stream of latencies in bounded
+// memory. It is safe for concurrent use.
+type latencySketch struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	max    time.Duration
+}
+
+func newLatencySketch() *latencySketch {
+	return &latencySketch{counts: make([]uint64, sketchBuckets)}
+}
+
+// bucketOf is the bucket counting latency d
+func bucketOf(d time.Duration) int {
+	us := float64(d) / float64(time.Microsecond)
+	if us <= 1 {
+		return 0
+	}
+	i := int(math.Ceil(math.Log(us) / sketchLogGamma))
+	if i >= sketchBuckets {
+		return sketchBuckets - 1
+	}
+	return i
+}
+
+// bucketValue is the latency a bucket's entries are estimated at: the point
+// within sketchRelativeError of both its bounds
+func bucketValue(i int) time.Duration {
+	if i == 0 {
+		return time.Microsecond
+	}
+	us := 2 * math.Pow(sketchGamma, float64(i)) / (sketchGamma + 1)
+	return time.Duration(us * float64(time.Microsecond))
+}
+
+// observe records one latency. A nil sketch records nothing.
+func (s *latencySketch) observe(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[bucketOf(d)]++
+	s.count++
+	if d > s.max {
+		s.max = d
+	}
+}
+
+// quantiles estimates the latency at each of qs, which must be ascending and
+// in [0, 1], returning the number of latencies and the largest
+func (s *latencySketch) quantiles(qs ...float64) ([]time.Duration, uint64, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]time.Duration, len(qs))
+	if s.count == 0 {
+		return out, 0, 0
+	}
+	var seen uint64
+	i := 0
+	for bucket, n := range s.counts {
+		seen += n
+		for ; i < len(qs) && float64(seen) >= math.Ceil(qs[i]*float64(s.count)); i++ {
+			out[i] = bucketValue(bucket)
+			if out[i] > s.max {
+				out[i] = s.max
+			}
+		}
+		if i == len(qs) {
+			break
+		}
+	}
+	return out, s.count, s.max
+}
+
+// LatencyStats is the body of GET /stats/latency
+type LatencyStats struct {
+	Count uint64  `json:"count"`
+	P50Ms float64 `json:"p50_ms"`
+	P90Ms float64 `json:"p90_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	P99Ms float64 `json:"p99_ms"`
+	MaxMs float64 `json:"max_ms"`
+}
+
+// latencyStats summarizes the execution latency sketch
+func (e *ExecutionEngine) latencyStats() LatencyStats {
+	if e.latencySketch == nil {
+		return LatencyStats{}
+	}
+	q, count, largest := e.latencySketch.quantiles(0.5, 0.9, 0.95, 0.99)
+	return LatencyStats{
+		Count: count,
+		P50Ms: durationMs(q[0]),
+		P90Ms: durationMs(q[1]),
+		P95Ms: durationMs(q[2]),
+		P99Ms: durationMs(q[3]),
+		MaxMs: durationMs(largest),
+	}
+}
+
+// handleLatencyStats serves GET /stats/latency
+func (e *ExecutionEngine) handleLatencyStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	json.NewEncoder(w).Encode(e.latencyStats())
+}
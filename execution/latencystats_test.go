@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLatencySketchQuantilesWithinTolerance(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	for name, draw := range map[string]func() time.Duration{
+		"uniform":     func() time.Duration { return time.Duration(rng.Int63n(int64(50 * time.Millisecond))) },
+		"exponential": func() time.Duration { return time.Duration(rng.ExpFloat64() * float64(2*time.Millisecond)) },
+		"lognormal":   func() time.Duration { return time.Duration(math.Exp(rng.NormFloat64()) * float64(time.Millisecond)) },
+	} {
+		sketch := newLatencySketch()
+		latencies := make([]time.Duration, 100000)
+		for i := range latencies {
+			latencies[i] = draw()
+			sketch.observe(latencies[i])
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		qs := []float64{0.5, 0.9, 0.95, 0.99, 1}
+		got, count, largest := sketch.quantiles(qs...)
+		if count != uint64(len(latencies)) || largest != latencies[len(latencies)-1] {
+			t.Fatalf("%s: count %d, max %v, want %d and %v", name, count, largest, len(latencies), latencies[len(latencies)-1])
+		}
+		for i, q := range qs {
+			want := latencies[int(math.Ceil(q*float64(len(latencies))))-1]
+			if diff := math.Abs(float64(got[i]-want)) / float64(want); diff > sketchRelativeError {
+				t.Errorf("%s: p%v = %v, want %v (off by %.2f%%)", name, q*100, got[i], want, diff*100)
+			}
+		}
+	}
+}
+
+func TestLatencySketchIsSafeForConcurrentUse(t *testing.T) {
+	sketch := newLatencySketch()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= 1000; i++ {
+				sketch.observe(time.Duration(i) * time.Microsecond)
+				sketch.quantiles(0.5, 0.99)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, count, largest := sketch.quantiles(0.5); count != 8000 || largest != time.Millisecond {
+		t.Fatalf("count %d, max %v, want 8000 and 1ms", count, largest)
+	}
+}
+
+func TestLatencyStatsEndpoint(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	for i := 0; i < 3; i++ {
+		order := testOrder(fmt.Sprintf("order-%d", i))
+		submitToEngine(t, engine, &order)
+	}
+
+	rec := httptest.NewRecorder()
+	engine.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats/latency", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /stats/latency: %d", rec.Code)
+	}
+	var stats LatencyStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Count != 3 || stats.P50Ms > stats.P99Ms || stats.P99Ms > stats.MaxMs {
+		t.Fatalf("stats = %+v, want 3 orders with ordered quantiles", stats)
+	}
+}
@@ -0,0 +1,60 @@
+// ==============================================================================
+// Minimum fill ratio - reject orders the book can't fill enough of
+// ==============================================================================
+// An order may set min_fill_ratio (0-1], or inherit its account's default from
+// MIN_FILL_RATIOS. If the liquidity immediately available at acceptable prices
+// covers less than that share of the quantity, the whole order is rejected as
+// insufficient_liquidity instead of receiving a small partial fill. A ratio of
+// 1 behaves like fill-or-kill.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/shopspring/decimal"
+)
+
+const rejectInsufficientLiquidity = "insufficient_liquidity"
+
+// parseMinFillRatios decodes the MIN_FILL_RATIOS config, a JSON object of
+// account ID to default ratio, e.g. {"acct-1":0.5}
+func parseMinFillRatios(raw string) (map[string]float64, error) {
+	ratios := map[string]float64{}
+	if raw == "" {
+		return ratios, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &ratios); err != nil {
+		return nil, err
+	}
+	return ratios, nil
+}
+
+// minFillRatio is the ratio that applies to an order: its own, or else its
+// account's default
+func (e *ExecutionEngine) minFillRatio(order *OrderRequest) float64 {
+	if order.MinFillRatio > 0 {
+		return order.MinFillRatio
+	}
+	return e.riskLimits().MinFillRatios[order.AccountID]
+}
+
+// Available returns how much of an incoming order could fill right now: the
+// contra quantity at prices the order accepts (any price when price is 0),
+// excluding resting orders of the same account, which can never fill it
+func (b *OrderBook) Available(side string, price decimal.Decimal, accountID string) decimal.Decimal {
+	var total decimal.Decimal
+	for _, level := range *b.levels(oppositeSide(side)) {
+		if price.IsPositive() && !crosses(side, price, level.price) {
+			break
+		}
+		for _, o := range level.orders {
+			if accountID != "" && o.AccountID == accountID {
+				continue
+			}
+			total = total.Add(o.Quantity)
+		}
+	}
+	return total
+}
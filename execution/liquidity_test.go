@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// seedAsks rests 100 shares at acceptable prices and 50 beyond a 101 limit
+func seedAsks(e *ExecutionEngine) {
+	e.executeOrder(context.Background(), limitOrder("a1", "AAPL", "sell", 100, 60))
+	e.executeOrder(context.Background(), limitOrder("a2", "AAPL", "sell", 101, 40))
+	e.executeOrder(context.Background(), limitOrder("a3", "AAPL", "sell", 102, 50))
+}
+
+func TestMinFillRatioThreshold(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity float64
+		wantFill float64 // 0 means rejected
+	}{
+		{"just above threshold", 199, 100},
+		{"exactly at threshold", 200, 100},
+		{"just below threshold", 201, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, _ := newTestEngine(t)
+			seedAsks(engine)
+
+			order := limitOrder("buy", "AAPL", "buy", 101, tt.quantity)
+			order.MinFillRatio = 0.5
+			resp := engine.executeOrder(context.Background(), order)
+
+			if tt.wantFill == 0 {
+				if resp.Status != "rejected" || resp.RejectReason != rejectInsufficientLiquidity || !resp.FilledQuantity.IsZero() {
+					t.Fatalf("expected insufficient_liquidity rejection, got %+v", resp)
+				}
+				if book := engine.bookFor("AAPL"); book.Len() != 3 {
+					t.Errorf("rejected order touched the book: %d resting orders", book.Len())
+				}
+				return
+			}
+			if !resp.FilledQuantity.Equal(dec(tt.wantFill)) || resp.Status != "partially_filled" {
+				t.Fatalf("expected partial fill of %v, got %+v", tt.wantFill, resp)
+			}
+		})
+	}
+}
+
+func TestMinFillRatioAccountDefault(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	setRiskLimits(engine, func(l *RiskLimits) { l.MinFillRatios = map[string]float64{"acct-strict": 1} })
+	seedAsks(engine)
+
+	strict := limitOrder("strict", "AAPL", "buy", 101, 150)
+	strict.AccountID = "acct-strict"
+	if resp := engine.executeOrder(context.Background(), strict); resp.RejectReason != rejectInsufficientLiquidity {
+		t.Fatalf("account default not applied: %+v", resp)
+	}
+
+	// An explicit ratio on the order overrides the account default
+	relaxed := limitOrder("relaxed", "AAPL", "buy", 101, 150)
+	relaxed.AccountID = "acct-strict"
+	relaxed.MinFillRatio = 0.5
+	if resp := engine.executeOrder(context.Background(), relaxed); !resp.FilledQuantity.Equal(dec(100)) {
+		t.Fatalf("order ratio should override account default: %+v", resp)
+	}
+}
+
+func TestMinFillRatioIgnoresOwnLiquidity(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	own := limitOrder("own", "AAPL", "sell", 100, 100)
+	own.AccountID = "acct-1"
+	engine.executeOrder(context.Background(), own)
+
+	order := limitOrder("buy", "AAPL", "buy", 100, 100)
+	order.AccountID = "acct-1"
+	order.MinFillRatio = 0.1
+	if resp := engine.executeOrder(context.Background(), order); resp.RejectReason != rejectInsufficientLiquidity {
+		t.Fatalf("same-account liquidity should not count: %+v", resp)
+	}
+}
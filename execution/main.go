@@ -8,9 +8,9 @@
 // - Order reconciliation
 // - Prometheus metrics export
 //
-// Run: go run main.go
+// Run: go run .
 // Benchmark: go test -bench=. -benchmem
-// Profile: go run main.go -cpuprofile=cpu.prof
+// Profile: go run . -cpuprofile=cpu.prof
 // ==============================================================================
 
 package main
@@ -18,68 +18,367 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // OrderRequest represents an incoming order
 type OrderRequest struct {
 	OrderID         string  `json:"order_id"`
 	Symbol          string  `json:"symbol"`
+	ClientSymbol    string  `json:"client_symbol,omitempty"` // as sent, when Symbol is its canonical form
 	Side            string  `json:"side"` // buy or sell
-	Quantity        float64 `json:"quantity"`
-	Type            string  `json:"type"` // market, limit, stop
-	LimitPrice      float64 `json:"limit_price,omitempty"`
-	StopPrice       float64 `json:"stop_price,omitempty"`
+	Quantity        decimal.Decimal `json:"quantity"`
+	Type            string  `json:"type"` // market, limit, stop, trailing_stop, spread
+	LimitPrice      decimal.Decimal `json:"limit_price,omitempty"`
+	StopPrice       decimal.Decimal `json:"stop_price,omitempty"`
+	TrailAmount     decimal.Decimal `json:"trail_amount,omitempty"` // trailing stops: how far the stop price trails the last price
+	TrailPercent    decimal.Decimal `json:"trail_percent,omitempty"` // or by what percentage of it
 	TimeInForce     string  `json:"time_in_force"`
 	IdempotencyKey  string  `json:"idempotency_key"`
 	Timestamp       int64   `json:"timestamp"`
+	AccountID       string  `json:"account_id,omitempty"`
+	MinFillRatio    float64 `json:"min_fill_ratio,omitempty"` // reject unless this share can fill now
+	PostOnly        bool    `json:"post_only,omitempty"` // reject rather than take liquidity
+	ActivateAt      int64   `json:"activate_at,omitempty"` // unix ms; held, not executed, until then
+	DisplayQuantity decimal.Decimal `json:"display_quantity,omitempty"` // iceberg: the slice of quantity shown in the book
+	PegTo           string  `json:"peg_to,omitempty"` // bid, ask or mid: limit price tracks it
+	PegOffset       decimal.Decimal `json:"peg_offset,omitempty"` // added to the peg reference
+	OCOGroup        string  `json:"oco_group,omitempty"` // fills cancel the account's other orders in the group
+	Notional        decimal.Decimal `json:"notional,omitempty"` // market orders: spend this instead of giving a quantity
+	ReduceOnly      bool    `json:"reduce_only,omitempty"` // may only shrink the account's position
+	Legs            []SpreadLeg `json:"legs,omitempty"` // spread orders: legs traded together at a net price
+	Tags            map[string]string `json:"tags,omitempty"` // caller metadata, carried onto every update
+	ParentOrderID   string  `json:"parent_order_id,omitempty"` // the algo order this order is a slice of
+	ParentQuantity  decimal.Decimal `json:"parent_quantity,omitempty"` // the parent's target quantity
+	Condition       string  `json:"condition,omitempty"` // e.g. "VIX < 15": held until another symbol's price meets it
+	Deadline        int64   `json:"deadline,omitempty"` // unix ms; skipped, not executed, if picked up later
+}
+
+// MarshalJSON leaves out the optional decimal fields that aren't set (see
+// decimal.go)
+func (o OrderRequest) MarshalJSON() ([]byte, error) {
+	type plain OrderRequest
+	return json.Marshal(struct {
+		plain
+		LimitPrice      *decimal.Decimal `json:"limit_price,omitempty"`
+		StopPrice       *decimal.Decimal `json:"stop_price,omitempty"`
+		TrailAmount     *decimal.Decimal `json:"trail_amount,omitempty"`
+		TrailPercent    *decimal.Decimal `json:"trail_percent,omitempty"`
+		DisplayQuantity *decimal.Decimal `json:"display_quantity,omitempty"`
+		PegOffset       *decimal.Decimal `json:"peg_offset,omitempty"`
+		Notional        *decimal.Decimal `json:"notional,omitempty"`
+		ParentQuantity  *decimal.Decimal `json:"parent_quantity,omitempty"`
+	}{
+		plain:           plain(o),
+		LimitPrice:      omitZero(o.LimitPrice),
+		StopPrice:       omitZero(o.StopPrice),
+		TrailAmount:     omitZero(o.TrailAmount),
+		TrailPercent:    omitZero(o.TrailPercent),
+		DisplayQuantity: omitZero(o.DisplayQuantity),
+		PegOffset:       omitZero(o.PegOffset),
+		Notional:        omitZero(o.Notional),
+		ParentQuantity:  omitZero(o.ParentQuantity),
+	})
 }
 
 // OrderResponse represents the execution response
 type OrderResponse struct {
 	OrderID          string  `json:"order_id"`
 	ClientOrderID    string  `json:"client_order_id"`
-	Status           string  `json:"status"`
-	FilledQuantity   float64 `json:"filled_quantity"`
-	FilledAvgPrice   float64 `json:"filled_avg_price"`
+	Symbol           string  `json:"symbol,omitempty"`
+	ClientSymbol     string  `json:"client_symbol,omitempty"` // the order's symbol as sent, if it was an alias
+	Status           OrderState `json:"status"`
+	FilledQuantity   decimal.Decimal `json:"filled_quantity"`
+	FilledAvgPrice   decimal.Decimal `json:"filled_avg_price"`
 	LatencyMs        float64 `json:"latency_ms"`
 	AcknowledgedAt   int64   `json:"acknowledged_at"`
+	RejectReason     string  `json:"reject_reason,omitempty"`
+	Fills            []BookFill `json:"fills,omitempty"` // book executions, in match order
+	Latency          *LatencyBreakdown `json:"latency,omitempty"` // per-stage timings
+	Commission       decimal.Decimal `json:"commission"` // broker commission on all fills so far
+	Fees             decimal.Decimal `json:"fees"`       // venue fees, negative for a net rebate
+	LiquidityFlag    string  `json:"liquidity_flag,omitempty"` // whether the latest fill was maker or taker
+	RiskLimit        *RiskLimit `json:"risk_limit,omitempty"` // the limit a rejected order ran into
+	RetryAfterMs     int64   `json:"retry_after_ms,omitempty"` // how long to wait before resubmitting a rejected order
+	DuplicateOf      string  `json:"duplicate_of,omitempty"` // an identical order executed just before this one
+	Legs             []LegExecution `json:"legs,omitempty"` // what each leg of a spread traded
+	Tags             map[string]string `json:"tags,omitempty"` // the order's tags
+	ParentOrderID    string  `json:"parent_order_id,omitempty"` // the algo order this order is a slice of
 }
 
 // ExecutionEngine handles order execution with low latency
 type ExecutionEngine struct {
-	redisClient      *redis.Client
+	redisClient      redis.UniversalClient
+	closeTransport   func()
 	streamName       string
+	dlqStreamName    string
+	keyPrefix        string // prefix of every key derived from streamName
+	codec            PayloadCodec
 	consumerGroup    string
 	consumerName     string
 	idempotencyCache sync.Map
-	orderCache       sync.Map
+	idempotencyScope string
+	orderCache       orderCache
 	ctx              context.Context
+	startedAt        time.Time
+	clock            Clock
+	config           Config
+	tracer           trace.Tracer
+	latencyModel     LatencyModel
+	simFills         *simFills
+	instruments      map[string]InstrumentSpec
+	broker           BrokerAdapter
+	circuit          *circuitBreaker
+	apiKeys          map[string]string
+	limits           atomic.Pointer[RiskLimits]
+	symbolAliases    map[string]string
+	minRestTimes     map[string]time.Duration
+	calendar         *tradingCalendar // nil when every market is always open
+	feeModel         FeeModel
+	prices           *priceCache
+	positions        *PositionTracker
+	symbolPolicy     atomic.Pointer[SymbolPolicy]
+	paused           atomic.Bool // consumption paused via /admin/pause
+	starting         atomic.Bool // Start hasn't finished yet
+	crashed          atomic.Bool // stopped by SIMULATE_CRASH
+	draining         atomic.Bool // refusing new orders via /admin/drain
+	drainDeadline    atomic.Int64 // unix ms at which a drain ends
+	drained          chan struct{} // closed when a drain has finished
+	batchMu          sync.Mutex  // held while a batch of orders is read and processed
+
+	// Order books, keyed by symbol, and their persistence. booksMu guards
+	// the map, each book has its own lock (see lockBooks)
+	booksMu           sync.RWMutex
+	books             map[string]*OrderBook
+	journalMu         sync.Mutex // guards lastJournalID
+	openOrders        openOrderCounts // open orders per account (see openorders.go)
+	bookJournalStream string
+	bookEventStream   string
+	bookSnapshotKey   string
+	orderStoreKey     string
+	lastJournalID     string
+	journalFailures   prometheus.Counter // book mutations the journal missed
+	snapshotDue       chan struct{}      // signalled when the journal missed a mutation
+	
+	// Symbols whose trading is halted, and the breaker that halts them all
+	halts       *haltTable
+	autoBreaker *autoBreaker
+	
+	// Good-after-time orders waiting for activation
+	heldKey       string
+	heldOrdersKey string
+
+	// Per-symbol execution goroutines; nil in serial mode
+	actors *symbolActors
+
+	// Per-account order buffers; nil unless scheduling fairly
+	fair *fairScheduler
+
+	// Called with every executed order's outcome while replaying
+	executed func(order *OrderRequest, response *OrderResponse)
+	
+	// Serializes each account's risk checks with its execution
+	accountLocks accountLocks
+
+	// Running TWAP and VWAP algos, and the default VWAP volume curve
+	twaps     *twapAlgos
+	vwapCurve []float64
+	
+	// One-cancels-other groups
+	ocos   *ocoRegistry
+	ocoKey string
+	
+	// Downstream delivery of order updates
+	fills       *fillDispatcher
+	subscribers *fillBroadcaster
+	partials    *partialFillAggregator // nil unless FILL_AGGREGATION_WINDOW is set
+	
+	// Orders whose outcome at the venue is uncertain
+	reconcileStreamName string
+	
+	// Audit trail of rejected orders
+	rejectionStreamName string
+	
+	// Lifecycle audit, numbered under auditMu
+	auditStreamName string
+	auditMu         sync.Mutex
+	auditSeq        uint64
 	
 	// Metrics
+	registry         *prometheus.Registry
 	executionLatency prometheus.Histogram
+	latencySketch    *latencySketch // live quantiles of executionLatency
 	ordersProcessed  prometheus.Counter
 	ordersRejected   prometheus.Counter
+	ordersTimedOut   prometheus.Counter
+	ordersBackpressured prometheus.Counter
+	outcomes         *outcomeWindow
+	stats            *statsStream // live snapshots for GET /stats/stream
+	orderSizes       *orderSizeMetrics
+	stageLatency     *stageLatencyMetrics
+	symbolLabels     *symbolLabeler // nil: every symbol gets its own metric label
+	orderIDs         *orderIDGenerator
+	velocity         *notionalVelocity // recent executed notional per account
 }
 
 // NewExecutionEngine creates a new execution engine instance
 func NewExecutionEngine(redisHost string, redisPort string, streamName string) *ExecutionEngine {
-	client := redis.NewClient(&redis.Options{
-		Addr:         fmt.Sprintf("%s:%s", redisHost, redisPort),
-		Password:     "",
-		DB:           0,
-		PoolSize:     100,
-		MinIdleConns: 10,
-	})
+	cfg := DefaultConfig()
+	cfg.RedisHost = redisHost
+	cfg.RedisPort = redisPort
+	cfg.StreamName = streamName
+	return NewExecutionEngineFromConfig(cfg)
+}
+
+// NewExecutionEngineFromConfig creates an execution engine from a full Config
+func NewExecutionEngineFromConfig(cfg Config) *ExecutionEngine {
+	streamName := cfg.StreamName
+	simRand := newSimRand(cfg.SimSeed)
+	fillRand := rand.New(rand.NewSource(simRand.Int63()))
+	latencyModel, err := newLatencyModel(cfg.SimLatencyModel, cfg.SimLatency, cfg.SimLatencyJitter, simRand)
+	if err != nil {
+		log.Printf("Invalid simulator latency config (%v), using zero latency", err)
+		latencyModel = ZeroLatency{}
+	}
+	fillModel, err := newFillModel(cfg.SimFillModel, cfg.SimFillProbability)
+	if err != nil {
+		log.Printf("Invalid simulator fill model config (%v), using %q", err, fillModelNone)
+		fillModel = NoFills{}
+	}
+
+	codec, err := newPayloadCodec(cfg.StreamCodec)
+	if err != nil {
+		log.Printf("Invalid STREAM_CODEC config (%v), using JSON", err)
+		codec = JSONCodec{}
+	}
+	if !validCompression(cfg.StreamCompression) {
+		log.Printf("Invalid STREAM_COMPRESSION %q, not compressing", cfg.StreamCompression)
+		cfg.StreamCompression = compressionNone
+	}
+	if cfg.StreamCompressionThreshold < 0 {
+		log.Printf("Invalid STREAM_COMPRESSION_THRESHOLD %d, using %d", cfg.StreamCompressionThreshold, DefaultConfig().StreamCompressionThreshold)
+		cfg.StreamCompressionThreshold = DefaultConfig().StreamCompressionThreshold
+	}
+
+	instruments, err := parseInstruments(cfg.Instruments)
+	if err != nil {
+		log.Printf("Invalid INSTRUMENTS config (%v), tick/lot rules disabled", err)
+	}
+
+	limits, err := newRiskLimits(cfg)
+	if err != nil {
+		log.Printf("Invalid risk limit config (%v), those limits are disabled", err)
+	}
+
+	minRestTimes, err := parseMinRestTimes(cfg.MinRestTimes)
+	if err != nil {
+		log.Printf("Invalid MIN_REST_TIMES config (%v), per-symbol resting times disabled", err)
+	}
+
+	vwapCurve, err := parseVolumeCurve(cfg.VWAPVolumeCurve)
+	if err != nil {
+		log.Printf("Invalid VWAP_VOLUME_CURVE config (%v), VWAPs must bring their own curve", err)
+	}
+
+	calendar, err := parseTradingSessions(cfg.TradingSessions)
+	if err != nil {
+		log.Printf("Invalid TRADING_SESSIONS config (%v), markets are always open", err)
+	}
+	if cfg.MarketClosedPolicy != marketClosedReject && cfg.MarketClosedPolicy != marketClosedQueueForOpen {
+		log.Printf("Invalid MARKET_CLOSED_POLICY %q, using %q", cfg.MarketClosedPolicy, marketClosedReject)
+		cfg.MarketClosedPolicy = marketClosedReject
+	}
+
+	symbolAliases, err := parseSymbolAliases(cfg.SymbolAliases)
+	if err != nil {
+		log.Printf("Invalid SYMBOL_ALIASES config (%v), aliases disabled", err)
+	}
+
+	// A bad symbol list is fatal in Start; don't fall back to permitting all
+	symbolPolicy, err := parseSymbolPolicy(cfg.SymbolAllowlist, cfg.SymbolDenylist)
+	if err != nil {
+		log.Printf("Invalid symbol lists: %v", err)
+	}
+
+	idempotencyScope := cfg.IdempotencyScope
+	if !validIdempotencyScope(idempotencyScope) {
+		log.Printf("Invalid IDEMPOTENCY_SCOPE %q, using %q", idempotencyScope, idempotencyScopeAccount)
+		idempotencyScope = idempotencyScopeAccount
+	}
+
+	prices, err := newPriceCache(cfg.ReferencePrices)
+	if err != nil {
+		log.Printf("Invalid REFERENCE_PRICES config (%v), using last trades only", err)
+	}
+
+	positions := NewPositionTracker()
+	positionModes, err := parsePositionModes(cfg.PositionModes)
+	if err != nil || !validPositionMode(cfg.PositionMode) {
+		log.Printf("Invalid POSITION_MODE %q or POSITION_MODES (%v), netting every account", cfg.PositionMode, err)
+	} else {
+		positions.defaultMode, positions.modes = cfg.PositionMode, positionModes
+	}
+
+	feeModel, err := newFeeModel(cfg.FeeModel, cfg.FeeRate, cfg.FeeMakerRate, cfg.FeeTakerRate)
+	if err != nil {
+		log.Printf("Invalid fee config (%v), fills are not charged", err)
+		feeModel = NoFees{}
+	}
+
+	// A bad API_KEYS value is fatal in Start; don't fall back to no auth
+	apiKeys, err := parseAPIKeys(cfg.APIKeys)
+	if err != nil {
+		log.Printf("Invalid API_KEYS config: %v", err)
+	}
+
+	if cfg.RedisPoolSize <= 0 {
+		log.Printf("Invalid REDIS_POOL_SIZE %d, using %d", cfg.RedisPoolSize, DefaultConfig().RedisPoolSize)
+		cfg.RedisPoolSize = DefaultConfig().RedisPoolSize
+	}
+	if cfg.RedisMinIdleConns < 0 || cfg.RedisMinIdleConns > cfg.RedisPoolSize {
+		log.Printf("Invalid REDIS_MIN_IDLE_CONNS %d, keeping no idle connections", cfg.RedisMinIdleConns)
+		cfg.RedisMinIdleConns = 0
+	}
+	if cfg.OrderCacheSize < 0 {
+		log.Printf("Invalid ORDER_CACHE_SIZE %d, using %d", cfg.OrderCacheSize, DefaultConfig().OrderCacheSize)
+		cfg.OrderCacheSize = DefaultConfig().OrderCacheSize
+	}
+	if cfg.InstanceID < 0 || cfg.InstanceID > maxInstanceID {
+		log.Printf("Invalid INSTANCE_ID %d, using 0", cfg.InstanceID)
+		cfg.InstanceID = 0
+	}
+	if cfg.ReclaimMinIdle < 0 {
+		log.Printf("Invalid RECLAIM_MIN_IDLE %s, using %s", cfg.ReclaimMinIdle, DefaultConfig().ReclaimMinIdle)
+		cfg.ReclaimMinIdle = DefaultConfig().ReclaimMinIdle
+	}
+	if cfg.SimulateCrash != "" && cfg.SimulateCrash != crashBeforePublish && cfg.SimulateCrash != crashBeforeAck {
+		log.Printf("Invalid SIMULATE_CRASH %q, not simulating a crash", cfg.SimulateCrash)
+		cfg.SimulateCrash = ""
+	}
+	client, closeTransport := newTransport(cfg)
+	keyPrefix := redisKeyPrefix(streamName, cfg.RedisClusterAddrs != "" && cfg.Transport != transportMemory)
 
 	executionLatency := prometheus.NewHistogram(prometheus.HistogramOpts{
 		Name:    "execution_latency_milliseconds",
@@ -97,28 +396,226 @@ func NewExecutionEngine(redisHost string, redisPort string, streamName string) *
 		Help: "Total number of orders rejected",
 	})
 
-	prometheus.MustRegister(executionLatency)
-	prometheus.MustRegister(ordersProcessed)
-	prometheus.MustRegister(ordersRejected)
+	ordersTimedOut := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "orders_timed_out_total",
+		Help: "Total number of orders the broker adapter didn't answer within the order timeout",
+	})
+
+	ordersBackpressured := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "orders_backpressure_rejected_total",
+		Help: "Total number of submissions refused with 503 because the order stream was backed up or slow",
+	})
+
+	quantityBuckets, err := parseBuckets(cfg.OrderQuantityBuckets, defaultQuantityBuckets)
+	if err != nil {
+		log.Printf("Invalid ORDER_QUANTITY_BUCKETS config (%v), using the defaults", err)
+		quantityBuckets = defaultQuantityBuckets
+	}
+	notionalBuckets, err := parseBuckets(cfg.OrderNotionalBuckets, defaultNotionalBuckets)
+	if err != nil {
+		log.Printf("Invalid ORDER_NOTIONAL_BUCKETS config (%v), using the defaults", err)
+		notionalBuckets = defaultNotionalBuckets
+	}
+
+	// Each engine owns its registry so several engines can coexist in one
+	// process (tests construct many of them)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector())
+	registry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	registry.MustRegister(executionLatency)
+	registry.MustRegister(ordersProcessed)
+	registry.MustRegister(ordersRejected)
+	registry.MustRegister(ordersTimedOut)
+	registry.MustRegister(ordersBackpressured)
+	registry.MustRegister(newPoolStatsCollector(client))
 
-	return &ExecutionEngine{
+	e := &ExecutionEngine{
 		redisClient:      client,
+		closeTransport:   closeTransport,
 		streamName:       streamName,
+		dlqStreamName:    keyPrefix + ".dlq",
+		keyPrefix:        keyPrefix,
+		codec:            codec,
 		consumerGroup:    "execution-engine-group",
-		consumerName:     "execution-engine-1",
+		consumerName:     consumerNameFor(cfg),
 		ctx:              context.Background(),
+		clock:            systemClock{},
+		config:           cfg,
+		tracer:           otel.Tracer(tracerName),
+		latencyModel:     latencyModel,
+		simFills:         &simFills{model: fillModel, rng: fillRand},
+		instruments:      instruments,
+		apiKeys:          apiKeys,
+		symbolAliases:    symbolAliases,
+		minRestTimes:     minRestTimes,
+		calendar:         calendar,
+		feeModel:         feeModel,
+		idempotencyScope: idempotencyScope,
+		prices:           prices,
+		positions:        positions,
+		books:            make(map[string]*OrderBook),
+		registry:         registry,
 		executionLatency: executionLatency,
+		latencySketch:    newLatencySketch(),
+		drained:          make(chan struct{}),
 		ordersProcessed:  ordersProcessed,
 		ordersRejected:   ordersRejected,
+		ordersTimedOut:   ordersTimedOut,
+		ordersBackpressured: ordersBackpressured,
+		outcomes:         newOutcomeWindow(cfg.MetricsWindow, registry),
+		stats:            newStatsStream(),
+		orderSizes:       newOrderSizeMetrics(registry, quantityBuckets, notionalBuckets),
+		stageLatency:     newStageLatencyMetrics(registry),
+		journalFailures:  newJournalFailureCounter(registry),
+		snapshotDue:      make(chan struct{}, 1),
+		symbolLabels:     newSymbolLabeler(cfg.MetricsSymbols, cfg.MetricsSymbolMinOrders),
+
+		bookJournalStream: keyPrefix + ".book.journal",
+		bookEventStream:   keyPrefix + ".book.events",
+		bookSnapshotKey:   keyPrefix + ".book.snapshot",
+		orderStoreKey:     keyPrefix + ".orders",
+		heldKey:           keyPrefix + ".held",
+		heldOrdersKey:     keyPrefix + ".held.orders",
+		ocos:              newOCORegistry(),
+		twaps:             newTWAPAlgos(),
+		vwapCurve:         vwapCurve,
+		velocity:          newNotionalVelocity(),
+		ocoKey:            keyPrefix + ".oco",
+
+		reconcileStreamName: keyPrefix + ".reconcile",
+		rejectionStreamName: keyPrefix + ".rejections",
+		auditStreamName:     keyPrefix + ".audit",
+	}
+
+	e.startedAt = e.now()
+	e.orderIDs = newOrderIDGenerator(cfg.InstanceID, e.now)
+	e.orderCache.bound(cfg.OrderCacheSize, registry)
+	e.outcomes.now = e.now
+	e.limits.Store(limits)
+	e.symbolPolicy.Store(symbolPolicy)
+
+	sinks, err := newFillSinks(cfg, client)
+	if err != nil {
+		log.Printf("Invalid FILL_SINKS config (%v), publishing to Redis only", err)
+		sinks = []FillSink{&RedisFillSink{client: client, codec: codec}}
+	}
+	sinkMetrics := newFillSinkMetrics(registry)
+	e.subscribers = newFillBroadcaster(sinkMetrics)
+	e.fills = newFillDispatcher(e.ctx, append(sinks, e.subscribers), sinkMetrics)
+	if cfg.FillSinkMaxAttempts > 0 {
+		e.fills.maxAttempts = cfg.FillSinkMaxAttempts
+	}
+	e.fills.backoff = cfg.FillSinkBackoff
+	e.fills.pending = &pendingDeliveries{client: client, stream: keyPrefix + ".pending_delivery"}
+	if cfg.FillAggregationWindow > 0 {
+		e.partials = newPartialFillAggregator(cfg.FillAggregationWindow, func(r *OrderResponse) { e.fills.dispatch(r) })
 	}
+	e.broker = simulatorAdapter{engine: e}
+	e.circuit = newCircuitBreaker(cfg.BrokerFailureThreshold, cfg.BrokerOpenTimeout, registry)
+	e.circuit.now = e.now
+	registry.MustRegister(newBookFeatureCollector(e))
+	registry.MustRegister(newBookSizeCollector(e))
+	registry.MustRegister(newConsumerLagCollector(e))
+	e.halts = newHaltTable(registry)
+	e.autoBreaker = newAutoBreaker(cfg.AutoBreakerRejectRatio, cfg.AutoBreakerMinOrders, registry)
+	e.halts.now = e.now
+	switch cfg.MatchingMode {
+	case matchingModeSerial:
+	case matchingModePerSymbol:
+		e.actors = newSymbolActors()
+	default:
+		log.Printf("Invalid MATCHING_MODE %q, using %q", cfg.MatchingMode, matchingModePerSymbol)
+		e.actors = newSymbolActors()
+	}
+	switch cfg.SchedulingMode {
+	case schedulingModeFIFO:
+	case schedulingModeFair:
+		weights, err := parseAccountWeights(cfg.AccountWeights)
+		if err != nil {
+			log.Printf("Invalid ACCOUNT_WEIGHTS config (%v), weighting every account %d", err, cfg.DefaultAccountWeight)
+			weights = map[string]int{}
+		}
+		e.fair = newFairScheduler(weights, cfg.DefaultAccountWeight)
+	default:
+		log.Printf("Invalid SCHEDULING_MODE %q, using %q", cfg.SchedulingMode, schedulingModeFIFO)
+	}
+
+	return e
 }
 
 // Start initializes the execution engine
 func (e *ExecutionEngine) Start() error {
+	e.starting.Store(true)
+	defer e.starting.Store(false)
+
+	if _, err := parseAPIKeys(e.config.APIKeys); err != nil {
+		return fmt.Errorf("invalid API_KEYS: %w", err)
+	}
+	if !e.authEnabled() {
+		log.Printf("No API keys configured, mutating endpoints are unauthenticated")
+	}
+	// Likewise a bad symbol list must not silently permit everything
+	if _, err := parseSymbolPolicy(e.config.SymbolAllowlist, e.config.SymbolDenylist); err != nil {
+		return fmt.Errorf("invalid symbol lists: %w", err)
+	}
+	
+	// Nothing below works without Redis
+	if err := e.waitForRedis(e.config.RedisStartupTimeout); err != nil {
+		return err
+	}
+
 	// Create consumer group if it doesn't exist
-	_, err := e.redisClient.XGroupCreateMkStream(e.ctx, e.streamName, e.consumerGroup, "$").Result()
-	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
-		log.Printf("Error creating consumer group: %v", err)
+	if err := ensureConsumerGroup(e.ctx, e.redisClient, e.streamName, e.consumerGroup); err != nil {
+		return err
+	}
+
+	// Rebuild resting orders lost with the previous process
+	if err := e.RecoverBooks(e.ctx); err != nil {
+		return fmt.Errorf("recovering order books: %w", err)
+	}
+	if err := e.RecoverOCOGroups(); err != nil {
+		return fmt.Errorf("recovering OCO groups: %w", err)
+	}
+	if err := e.RecoverAuditSequence(); err != nil {
+		return fmt.Errorf("recovering audit sequence: %w", err)
+	}
+	if e.config.BookSeedFile != "" {
+		orders, err := loadSeedFile(e.config.BookSeedFile)
+		if err != nil {
+			return fmt.Errorf("loading book seed file: %w", err)
+		}
+		if _, err := e.SeedBook(orders); err != nil {
+			return err
+		}
+	}
+
+	// Finish orders a crash left unacknowledged before taking new ones
+	if n, err := e.ReclaimPending(); err != nil {
+		return fmt.Errorf("reclaiming pending orders: %w", err)
+	} else if n > 0 {
+		log.Printf("Reclaimed %d pending orders", n)
+	}
+	if e.config.BookSnapshotInterval > 0 {
+		go e.snapshotLoop(e.ctx, e.config.BookSnapshotInterval)
+	}
+	if e.config.ReclaimMinIdle > 0 {
+		go e.reclaimLoop(e.ctx, e.config.ReclaimMinIdle)
+	}
+	go e.outcomes.run(e.ctx, time.Second)
+	if e.config.FillRedeliveryInterval > 0 {
+		go e.fills.redeliveryLoop(e.ctx, e.config.FillRedeliveryInterval)
+	}
+	if e.config.ActivationSweepInterval > 0 {
+		go e.activationLoop(e.ctx, e.config.ActivationSweepInterval)
+	}
+	if e.config.PegRepriceInterval > 0 {
+		go e.pegLoop(e.ctx, e.config.PegRepriceInterval)
+	}
+	if e.config.AlgoSweepInterval > 0 {
+		go e.twapLoop(e.ctx, e.config.AlgoSweepInterval)
+	}
+	if e.config.StatsStreamInterval > 0 {
+		go e.statsLoop(e.ctx, e.config.StatsStreamInterval)
 	}
 
 	log.Printf("Execution engine started, listening on stream: %s", e.streamName)
@@ -129,195 +626,942 @@ func (e *ExecutionEngine) Start() error {
 	return nil
 }
 
-// consumeOrders continuously reads from Redis Stream
+// groupCreator is the part of the Redis client ensureConsumerGroup needs
+type groupCreator interface {
+	XGroupCreateMkStream(ctx context.Context, stream, group, start string) *redis.StatusCmd
+}
+
+// ensureConsumerGroup creates the consumer group (and the stream, if needed).
+// A group that already exists is fine; any other failure is returned.
+func ensureConsumerGroup(ctx context.Context, client groupCreator, stream string, group string) error {
+	err := client.XGroupCreateMkStream(ctx, stream, group, "$").Err()
+	if err == nil || isBusyGroup(err) {
+		return nil
+	}
+	return fmt.Errorf("creating consumer group %s on %s: %w", group, stream, err)
+}
+
+// isBusyGroup reports whether err is Redis saying the group already exists.
+// Only the BUSYGROUP error code is stable across Redis versions; the message
+// after it is not.
+func isBusyGroup(err error) bool {
+	var redisErr redis.Error
+	return errors.As(err, &redisErr) && strings.HasPrefix(redisErr.Error(), "BUSYGROUP")
+}
+
+// consumeOrders continuously reads from Redis Stream until the engine's
+// context is done, idling while consumption is paused
 func (e *ExecutionEngine) consumeOrders() {
-	for {
-		streams, err := e.redisClient.XReadGroup(e.ctx, &redis.XReadGroupArgs{
-			Group:    e.consumerGroup,
-			Consumer: e.consumerName,
-			Streams:  []string{e.streamName, ">"},
-			Count:    10,
-			Block:    100 * time.Millisecond,
-		}).Result()
+	for e.ctx.Err() == nil {
+		if !e.consumeBatch() {
+			time.Sleep(pausedPollInterval)
+		}
+	}
+}
 
-		if err != nil {
-			if err != redis.Nil {
-				log.Printf("Error reading from stream: %v", err)
+// consumeBatch reads and processes one batch of orders. It returns false
+// without reading while consumption is paused. Pause waits on batchMu for
+// a batch in flight.
+func (e *ExecutionEngine) consumeBatch() bool {
+	e.batchMu.Lock()
+	defer e.batchMu.Unlock()
+	if e.paused.Load() || e.crashed.Load() {
+		return false
+	}
+	
+	var messages []redis.XMessage
+	if e.fair != nil {
+		messages = e.nextFairBatch()
+	} else {
+		messages = e.readOrders(consumeBatchSize, consumeBlock)
+	}
+
+	var wg sync.WaitGroup
+	for _, message := range messages {
+		message := message
+		e.dispatch(message, &wg, func() {
+			// Acknowledge only once the message is processed or parked
+			// in the DLQ; otherwise it stays pending for redelivery
+			if err := e.handleMessage(message); err != nil {
+				log.Printf("Leaving message %s pending: %v", message.ID, err)
+				return
 			}
-			continue
+			e.redisClient.XAck(e.ctx, e.streamName, e.consumerGroup, message.ID)
+		})
+	}
+	wg.Wait()
+	if e.actors != nil {
+		e.actors.wait() // and what the batch's trades handed between actors
+	}
+	return true
+}
+
+// Orders are read from the stream consumeBatchSize at a time, waiting up to
+// consumeBlock for one to arrive
+const (
+	consumeBatchSize = 10
+	consumeBlock     = 100 * time.Millisecond
+)
+
+// readOrders reads up to count new messages from the order stream, waiting
+// up to block for them (not at all if block is negative)
+func (e *ExecutionEngine) readOrders(count int64, block time.Duration) []redis.XMessage {
+	streams, err := e.redisClient.XReadGroup(e.ctx, &redis.XReadGroupArgs{
+		Group:    e.consumerGroup,
+		Consumer: e.consumerName,
+		Streams:  []string{e.streamName, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err != nil {
+		if err != redis.Nil && e.ctx.Err() == nil {
+			log.Printf("Error reading from stream: %v", err)
 		}
+		return nil
+	}
+	var messages []redis.XMessage
+	for _, stream := range streams {
+		messages = append(messages, stream.Messages...)
+	}
+	return messages
+}
 
-		for _, stream := range streams {
-			for _, message := range stream.Messages {
-				e.processOrder(message)
-				
-				// Acknowledge the message
-				e.redisClient.XAck(e.ctx, e.streamName, e.consumerGroup, message.ID)
+// handleMessage processes one stream message, isolating the rest of the
+// batch from a panic by parking the message in the DLQ
+func (e *ExecutionEngine) handleMessage(message redis.XMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic processing message %s: %v\n%s", message.ID, r, debug.Stack())
+			e.recordRejection(dlqReasonPanic)
+			payload, _ := message.Values["order"].(string)
+			encoding, _ := message.Values[payloadEncodingField].(string)
+			compression, _ := message.Values[payloadCompressionField].(string)
+			if decompressed, err := decompressPayload(message.Values, payload, e.config.MaxPayloadBytes); err == nil {
+				payload, compression = decompressed, ""
 			}
+			err = e.parkInDLQ(message.ID, payload, encoding, compression, &rejection{Reason: dlqReasonPanic, Detail: fmt.Sprint(r)})
 		}
-	}
+	}()
+	return e.processOrder(message)
 }
 
-// processOrder executes a single order with latency tracking
-func (e *ExecutionEngine) processOrder(message redis.XMessage) {
-	startTime := time.Now()
+// processOrder executes a single order with latency tracking. Orders that
+// can't be executed are rejected or parked in the DLQ; an error means the
+// message could not be parked and must not be acknowledged.
+func (e *ExecutionEngine) processOrder(message redis.XMessage) error {
+	startTime := e.now()
+	stages := newStageTimer(message.ID, startTime, e.now)
 	
-	// Parse order request
-	orderJSON, ok := message.Values["order"].(string)
+	// Continue the trace started by the submitter
+	ctx := extractTraceContext(e.ctx, message.Values)
+	ctx, span := e.tracer.Start(ctx, "process_order", trace.WithSpanKind(trace.SpanKindConsumer))
+	defer span.End()
+	
+	// Parse order request with the codec it was written with
+	payload, ok := message.Values["order"].(string)
+	encoding, _ := message.Values[payloadEncodingField].(string)
 	if !ok {
 		log.Printf("Invalid order format in message: %v", message.ID)
-		e.ordersRejected.Inc()
-		return
+		span.SetStatus(codes.Error, "invalid order format")
+		e.recordRejection(dlqReasonInvalidFormat)
+		return e.sendToDLQ(message.ID, "", encoding, dlqReasonInvalidFormat, "missing order field")
+	}
+	if e.oversizedPayload(payload) {
+		log.Printf("Order message %s is %d bytes, over the limit", message.ID, len(payload))
+		span.SetStatus(codes.Error, "payload too large")
+		e.recordRejection(dlqReasonPayloadTooLarge)
+		compression, _ := message.Values[payloadCompressionField].(string)
+		return e.parkInDLQ(message.ID, payload, encoding, compression, &rejection{Reason: dlqReasonPayloadTooLarge, Detail: fmt.Sprintf("%d bytes exceeds %d", len(payload), e.config.MaxPayloadBytes)})
+	}
+	decompressed, err := decompressPayload(message.Values, payload, e.config.MaxPayloadBytes)
+	if err != nil {
+		reason := dlqReasonDecodeError
+		if errors.Is(err, errDecompressedTooLarge) {
+			reason = dlqReasonPayloadTooLarge
+		}
+		log.Printf("Error decompressing order message %s: %v", message.ID, err)
+		span.SetStatus(codes.Error, "decompression error")
+		e.recordRejection(reason)
+		compression, _ := message.Values[payloadCompressionField].(string)
+		return e.parkInDLQ(message.ID, payload, encoding, compression, &rejection{Reason: reason, Detail: err.Error()})
 	}
+	payload = decompressed
 
 	var order OrderRequest
-	if err := json.Unmarshal([]byte(orderJSON), &order); err != nil {
+	codec, err := entryCodec(message.Values)
+	if err == nil {
+		err = codec.Unmarshal([]byte(payload), &order)
+	}
+	if err != nil {
 		log.Printf("Error unmarshaling order: %v", err)
-		e.ordersRejected.Inc()
-		return
+		span.SetStatus(codes.Error, "decode error")
+		e.recordRejection(dlqReasonDecodeError)
+		return e.sendToDLQ(message.ID, payload, encoding, dlqReasonDecodeError, err.Error())
+	}
+
+	fresh := !internalMessage(message.ID)
+	if fresh {
+		e.auditOrder(&order, auditReceived, "")
+	}
+
+	// Orders queued by other producers haven't been normalized yet
+	e.normalizeOrderSymbol(&order)
+	span.SetAttributes(orderAttributes(&order)...)
+
+	if err := validateOrder(&order); err != nil {
+		log.Printf("Order %s failed validation: %v", order.OrderID, err)
+		span.SetStatus(codes.Error, "validation failed")
+		e.recordRejection(dlqReasonValidation)
+		e.auditRejection(&order, &rejection{Reason: dlqReasonValidation, Detail: err.Error()})
+		return e.sendToDLQ(message.ID, payload, encoding, dlqReasonValidation, err.Error())
+	}
+
+	// Orders that sat in the queue past their useful life don't execute
+	if fresh {
+		if rej := e.checkOrderAge(&order); rej != nil {
+			span.SetStatus(codes.Error, "order stale")
+			e.rejectOrder(&order, rej)
+			return nil
+		}
+	}
+
+	// Snap or reject prices and quantities off the instrument's grid
+	if rej := applyInstrumentRules(&order, e.instruments, e.config.InstrumentPolicy); rej != nil {
+		span.SetStatus(codes.Error, "instrument rules")
+		e.rejectOrder(&order, rej)
+		return nil
+	}
+
+	// Orders queued by other producers haven't been screened yet
+	if symbol, ok := e.orderPermitted(&order); !ok {
+		span.SetStatus(codes.Error, "symbol not permitted")
+		e.rejectOrder(&order, &rejection{Reason: rejectSymbolNotPermitted, Detail: "symbol " + symbol + " may not be traded"})
+		return nil
+	}
+
+	stages.lap(&stages.breakdown.ValidationMs)
+	if fresh {
+		e.auditOrder(&order, auditValidated, "")
+	}
+
+	// Don't send the venue orders it has said it can't handle
+	if rej := e.checkCapabilities(&order); rej != nil {
+		span.SetStatus(codes.Error, "unsupported order type")
+		e.rejectOrder(&order, rej)
+		return nil
+	}
+
+	e.joinOCOGroup(&order)
+
+	// Good-after-time orders wait, unmatched, until the sweeper activates them
+	if order.ActivateAt > e.now().UnixMilli() {
+		return e.holdOrder(&order)
+	}
+
+	// Nothing trades once the auto-breaker has tripped
+	if e.autoBreaker.isHalted() {
+		span.SetStatus(codes.Error, "auto-breaker tripped")
+		e.rejectOrder(&order, &rejection{Reason: rejectAutoBreaker, Detail: "trading is halted by the rejection-rate auto-breaker"})
+		return nil
+	}
+
+	// Nothing trades in a halted symbol
+	if symbol, halted := e.orderHalted(&order); halted {
+		span.SetStatus(codes.Error, "symbol halted")
+		e.rejectOrder(&order, &rejection{Reason: rejectSymbolHalted, Detail: "trading in " + symbol + " is halted"})
+		return nil
+	}
+
+	// Orders arriving while their market is closed wait for the open or are
+	// refused
+	if handled, err := e.checkTradingHours(&order); handled {
+		return err
+	}
+
+	// Stops wait, unmatched, until the market trades through their price
+	if order.Type == "stop" {
+		return e.restStop(&order)
+	}
+	if order.Type == orderTypeTrailingStop {
+		return e.restTrailingStop(&order)
+	}
+	
+	// Conditional orders wait until another symbol's price meets the condition
+	if order.Condition != "" {
+		return e.holdConditional(&order)
 	}
 
-	// Check idempotency
+	// Claim the idempotency key; exactly one delivery of a key executes and
+	// concurrent duplicates share its response
+	var final *OrderResponse
+	answered := false
 	if order.IdempotencyKey != "" {
-		if _, exists := e.idempotencyCache.Load(order.IdempotencyKey); exists {
+		claim, won := e.claimIdempotencyKey(ctx, &order)
+		if !won {
+			shared := claim.wait()
 			log.Printf("Duplicate order detected (idempotency key: %s)", order.IdempotencyKey)
-			return
+			if shared != nil {
+				e.orderCache.LoadOrStore(order.OrderID, shared)
+			}
+			return nil
 		}
-		e.idempotencyCache.Store(order.IdempotencyKey, true)
+		defer func() {
+			// A panic before the broker answered leaves the order unexecuted,
+			// and its DLQ copy must be replayable
+			if final == nil && !answered {
+				e.releaseIdempotencyKey(ctx, &order)
+			}
+			e.settleIdempotencyKey(ctx, &order, claim, final)
+		}()
+	}
+
+	// Account limits span symbols: hold the account from its checks until
+	// the fills they admit are recorded (see actors.go)
+	unlockAccount := e.accountLocks.lock(order.AccountID)
+	defer unlockAccount()
+
+	// Protect the books from runaway strategies
+	if rej := e.checkOrderQuantity(&order); rej != nil {
+		span.SetStatus(codes.Error, "order too large")
+		final = e.rejectOrder(&order, rej)
+		return nil
+	}
+	if rej := e.checkPriceBand(&order); rej != nil {
+		span.SetStatus(codes.Error, "price out of band")
+		final = e.rejectOrder(&order, rej)
+		return nil
+	}
+	if rej := e.checkOpenOrders(&order); rej != nil {
+		span.SetStatus(codes.Error, "too many open orders")
+		final = e.rejectOrder(&order, rej)
+		return nil
+	}
+	if rej := e.checkNotionalVelocity(&order); rej != nil {
+		span.SetStatus(codes.Error, "notional velocity exceeded")
+		final = e.rejectOrder(&order, rej)
+		return nil
+	}
+
+	// Catch the same economic order resubmitted under a new key
+	duplicateOf := e.duplicateOf(&order)
+	if duplicateOf != "" {
+		if e.riskLimits().DuplicateOrderPolicy != duplicatePolicyWarn {
+			span.SetStatus(codes.Error, "duplicate order")
+			final = e.rejectOrder(&order, &rejection{Reason: rejectDuplicateOrder, Detail: "same as order " + duplicateOf})
+			return nil
+		}
+		log.Printf("Order %s duplicates order %s, executing it anyway", order.OrderID, duplicateOf)
+	}
+
+	// Never let a reduce-only order open or grow a position
+	if rej := e.capReduceOnly(&order); rej != nil {
+		span.SetStatus(codes.Error, "would increase position")
+		final = e.rejectOrder(&order, rej)
+		return nil
+	}
+
+	stages.lap(&stages.breakdown.RiskMs)
+
+	// Don't spend a backlog on orders their submitter has given up on
+	if rej := e.checkDeadline(&order); rej != nil {
+		span.SetStatus(codes.Error, "deadline exceeded")
+		final = e.rejectOrder(&order, rej)
+		return nil
+	}
+
+	// An order that already finished isn't executed again
+	if current, ok := e.orderCache.Load(order.OrderID); ok && current.Status.final() {
+		log.Printf("Order %s is already %s, not executing it again", order.OrderID, current.Status)
+		final = current
+		return nil
 	}
 
-	// Simulate order execution (in production, this would call a broker API)
-	response := e.executeOrder(&order)
+	// Fail fast while the broker is down; the DLQ copy can be replayed later,
+	// so the idempotency key must not block it. This comes last before the
+	// broker: a half-open breaker admits one probe, and only the broker's
+	// answer to it settles the breaker again.
+	if !e.circuit.allow() {
+		span.SetStatus(codes.Error, "broker unavailable")
+		if order.IdempotencyKey != "" {
+			e.releaseIdempotencyKey(ctx, &order)
+		}
+		rej := &rejection{Reason: rejectBrokerUnavailable, Detail: "broker circuit breaker is open", RetryAfter: e.circuit.retryAfter()}
+		final = e.rejectOrder(&order, rej)
+		return e.sendRejectionToDLQ(message.ID, payload, encoding, rej)
+	}
+
+	// Execute through the broker adapter, bounded by the per-order timeout
+	e.auditOrder(&order, auditRouted, "")
+	e.observeOrderSize(&order)
+	for _, symbol := range orderSymbols(&order) {
+		e.symbolLabels.observe(symbol)
+	}
+	execCtx, execSpan := e.tracer.Start(ctx, "execute_order")
+	response, err := e.executeGuarded(execCtx, &order)
+	answered = true
+	stages.lap(&stages.breakdown.BrokerMs)
+	if err != nil || response.Status == statusTimedOut {
+		e.circuit.failure()
+	} else {
+		e.circuit.success()
+	}
+	if err != nil {
+		execSpan.SetStatus(codes.Error, "broker error")
+		execSpan.End()
+		span.SetStatus(codes.Error, "broker error")
+		final = e.rejectOrder(&order, &rejection{Reason: rejectBrokerError, Detail: err.Error()})
+		return nil
+	}
+	execSpan.SetAttributes(attribute.String("order.status", string(response.Status)))
+	execSpan.End()
 	
 	// Calculate latency
-	latency := time.Since(startTime).Milliseconds()
+	elapsed := e.now().Sub(startTime)
+	latency := elapsed.Milliseconds()
 	response.LatencyMs = float64(latency)
-	response.AcknowledgedAt = time.Now().UnixMilli()
+	response.AcknowledgedAt = e.now().UnixMilli()
+	response.Latency = stages.finish()
+	response.ClientSymbol = order.ClientSymbol
+	response.DuplicateOf = duplicateOf
+	response.Tags = order.Tags
+	response.ParentOrderID = order.ParentOrderID
 	
 	// Record metrics
 	e.executionLatency.Observe(float64(latency))
+	e.latencySketch.observe(elapsed)
 	e.ordersProcessed.Inc()
+	if response.Status != statusRejected {
+		e.outcomes.processed()
+	}
 	
-	// Store order response
-	e.orderCache.Store(order.OrderID, response)
+	// Store order response. An order that got somewhere final meanwhile
+	// keeps its state, though any trades made still count.
+	stored := e.storeResponse(response) == nil
+	if stored {
+		if e.executed != nil {
+			e.executed(&order, response)
+		}
+		e.saveOrder(&order, response)
+		final = response
+		if response.Status != statusRejected {
+			e.auditState(order.AccountID, response) // rejections audit themselves
+		}
+	} else {
+		final, _ = e.GetOrder(order.OrderID)
+	}
+	e.applyPositions(&order, response)
+	e.recordNotional(order.AccountID, response.FilledQuantity.Mul(response.FilledAvgPrice))
+	unlockAccount()
 	
+	if e.simulatedCrash(crashBeforePublish) {
+		return errSimulatedCrash
+	}
+
 	// Publish response back to Redis
-	responseJSON, _ := json.Marshal(response)
-	e.redisClient.Publish(e.ctx, fmt.Sprintf("order.response.%s", order.OrderID), responseJSON)
+	if stored {
+		_, pubSpan := e.tracer.Start(ctx, "publish_response", trace.WithSpanKind(trace.SpanKindProducer))
+		e.publishResponse(response)
+		pubSpan.End()
+	}
 	
 	log.Printf("Order executed: %s (latency: %dms)", order.OrderID, latency)
-}
-
-// executeOrder simulates order execution with realistic latency
-func (e *ExecutionEngine) executeOrder(order *OrderRequest) *OrderResponse {
-	// Simulate execution with minimal latency (< 10ms for local adapter)
-	time.Sleep(2 * time.Millisecond)
 	
-	// Calculate fill price (simplified)
-	fillPrice := order.LimitPrice
-	if order.Type == "market" {
-		// Simulate market price with minor slippage
-		fillPrice = 100.0 + (float64(time.Now().UnixNano()%100) / 100.0)
+	// Settle what the trades set off: OCO cancels first, then stops
+	e.recordOCOFill(order.OrderID, response.FilledQuantity)
+	e.cancelOCOSiblings()
+	if response.FilledQuantity.IsPositive() {
+		for _, symbol := range orderSymbols(&order) {
+			e.priceMoved(order.Symbol, symbol)
+		}
+	}
+	if e.simulatedCrash(crashBeforeAck) {
+		return errSimulatedCrash
 	}
+	return nil
+}
+
+// rejection is a business-level refusal to execute an order
+type rejection struct {
+	Reason     string        // machine-readable, e.g. "invalid_tick_size"
+	Detail     string        // human-readable explanation
+	Limit      *RiskLimit    // the limit the order ran into, if any
+	RetryAfter time.Duration // how long until resubmitting may succeed, if known
+}
+
+func (r *rejection) Error() string { return r.Reason + ": " + r.Detail }
+
+// rejectOrder records a business rejection and tells the client why
+func (e *ExecutionEngine) rejectOrder(order *OrderRequest, rej *rejection) *OrderResponse {
+	log.Printf("Order %s rejected: %s (%s)", order.OrderID, rej.Reason, rej.Detail)
+	e.recordRejection(rej.Reason)
+	e.auditRejection(order, rej)
 	
-	return &OrderResponse{
+	response := &OrderResponse{
 		OrderID:        order.OrderID,
 		ClientOrderID:  order.IdempotencyKey,
-		Status:         "filled",
-		FilledQuantity: order.Quantity,
-		FilledAvgPrice: fillPrice,
+		Symbol:         order.Symbol,
+		ClientSymbol:   order.ClientSymbol,
+		Status:         statusRejected,
+		AcknowledgedAt: e.now().UnixMilli(),
+		RejectReason:   rej.Reason,
+		RiskLimit:      rej.Limit,
+		RetryAfterMs:   retryAfterMs(rej.RetryAfter),
+		Tags:           order.Tags,
+		ParentOrderID:  order.ParentOrderID,
+	}
+	if err := e.storeResponse(response); err != nil {
+		current, _ := e.GetOrder(order.OrderID)
+		return current
+	}
+	e.saveOrder(order, response)
+	e.publishResponse(response)
+	return response
+}
+
+// executeOrder simulates order execution with realistic latency. It returns
+// nil, having changed nothing, if the order times out first (see broker.go).
+func (e *ExecutionEngine) executeOrder(ctx context.Context, order *OrderRequest) *OrderResponse {
+	// Simulate venue latency (zero unless a latency model is configured)
+	e.simulateLatency(ctx)
+	
+	// Spreads trade all their legs in the books or nothing
+	if order.Type == "spread" {
+		return e.matchSpread(ctx, order)
+	}
+	
+	// Limit orders always go through the book; market orders only when there
+	// is resting liquidity to take, otherwise they fill at the simulated price
+	var response *OrderResponse
+	if order.Type == "limit" || (order.Type == "market" && e.bookHasLiquidity(order)) {
+		response = e.matchOrder(ctx, order)
+		if response == nil {
+			return nil
+		}
+	} else {
+		if !commitExecution(ctx) {
+			return nil
+		}
+		// Market orders fill at the symbol's reference price
+		matchStart := latencyStart()
+		fillPrice := order.LimitPrice
+		if order.Type == "market" {
+			fillPrice = e.prices.reference(order.Symbol)
+		}
+		if order.Notional.IsPositive() {
+			order.Quantity = e.floorToLot(order.Symbol, order.Notional.Div(fillPrice))
+			if !order.Quantity.IsPositive() {
+				return e.bookRejection(order, &rejection{Reason: rejectNotionalTooSmall})
+			}
+		}
+		e.prices.record(order.Symbol, fillPrice)
+		e.prices.recordVolume(order.Symbol, order.Quantity)
+		e.observeMatchLatency(matchStart)
+		
+		response = &OrderResponse{
+			OrderID:        order.OrderID,
+			ClientOrderID:  order.IdempotencyKey,
+			Symbol:         order.Symbol,
+			Status:         statusFilled,
+			FilledQuantity: order.Quantity,
+			FilledAvgPrice: fillPrice,
+		}
 	}
+	
+	// Whatever traded on arrival took liquidity
+	response.addCharge(e.chargeFill(response.FilledQuantity, response.FilledAvgPrice, liquidityTaker))
+	if response.FilledQuantity.IsPositive() {
+		response.LiquidityFlag = liquidityTaker
+	}
+	return response
 }
 
-// GetOrder retrieves an order by ID
+// validateOrder performs basic sanity checks before an order is executed
+func validateOrder(order *OrderRequest) error {
+	if order.OrderID == "" {
+		return fmt.Errorf("order_id is required")
+	}
+	if order.Symbol == "" {
+		return fmt.Errorf("symbol is required")
+	}
+	if order.Side != "buy" && order.Side != "sell" {
+		return fmt.Errorf("invalid side %q", order.Side)
+	}
+	if order.Notional.IsNegative() {
+		return fmt.Errorf("notional must be positive")
+	}
+	if order.Notional.IsPositive() {
+		if !order.Quantity.IsZero() {
+			return fmt.Errorf("quantity and notional are mutually exclusive")
+		}
+		if order.Type != "market" {
+			return fmt.Errorf("notional requires a market order")
+		}
+		if order.ReduceOnly {
+			return fmt.Errorf("reduce_only requires a quantity")
+		}
+	} else if !order.Quantity.IsPositive() {
+		return fmt.Errorf("quantity must be positive")
+	}
+	if order.MinFillRatio < 0 || order.MinFillRatio > 1 {
+		return fmt.Errorf("min_fill_ratio must be between 0 and 1")
+	}
+	if order.PostOnly && order.Type != "limit" {
+		return fmt.Errorf("post_only requires a limit order")
+	}
+	if !order.DisplayQuantity.IsZero() {
+		if order.Type != "limit" {
+			return fmt.Errorf("display_quantity requires a limit order")
+		}
+		if !order.DisplayQuantity.IsPositive() || !order.DisplayQuantity.LessThan(order.Quantity) {
+			return fmt.Errorf("display_quantity must be positive and less than quantity")
+		}
+	}
+	if order.PegTo != "" {
+		if order.Type != "limit" {
+			return fmt.Errorf("peg_to requires a limit order")
+		}
+		if !validPegReference(order.PegTo) {
+			return fmt.Errorf("invalid peg_to %q", order.PegTo)
+		}
+	}
+	if len(order.Legs) > 0 && order.Type != "spread" {
+		return fmt.Errorf("legs require a spread order")
+	}
+	if err := validateTags(order.Tags); err != nil {
+		return err
+	}
+	if err := validateTrail(order); err != nil {
+		return err
+	}
+	if order.Deadline < 0 {
+		return fmt.Errorf("deadline must be a unix time in milliseconds")
+	}
+	if err := validateParent(order); err != nil {
+		return err
+	}
+	if order.Condition != "" {
+		if _, err := parseCondition(order.Condition); err != nil {
+			return err
+		}
+		if order.Type != "market" && order.Type != "limit" {
+			return fmt.Errorf("condition requires a market or limit order")
+		}
+	}
+	switch order.Type {
+	case "market":
+	case "limit":
+		if order.PegTo == "" && !order.LimitPrice.IsPositive() {
+			return fmt.Errorf("limit order requires a positive limit_price")
+		}
+	case "stop":
+		if !order.StopPrice.IsPositive() {
+			return fmt.Errorf("stop order requires a positive stop_price")
+		}
+	case orderTypeTrailingStop:
+	case "spread":
+		if err := validateSpread(order); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid order type %q", order.Type)
+	}
+	return nil
+}
+
+// GetOrder retrieves an order by ID, falling back to the order store for
+// orders no longer held in memory
 func (e *ExecutionEngine) GetOrder(orderID string) (*OrderResponse, bool) {
-	val, ok := e.orderCache.Load(orderID)
+	response, ok := e.orderCache.Load(orderID)
 	if !ok {
-		return nil, false
+		return e.loadStoredOrder(e.ctx, orderID)
 	}
-	response := val.(*OrderResponse)
 	return response, true
 }
 
-// HTTPServer provides HTTP endpoints for order submission
-func (e *ExecutionEngine) HTTPServer(port string) {
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+// SubmitOrder queues an order on the order stream for execution, carrying
+// the trace context in ctx, and gives it an ID if it has none. It refuses
+// with errSymbolNotPermitted for symbols that may not be traded, and with
+// errQueueFull or errQueueSlow when the stream is backed up. While draining it refuses everything with errDraining.
+func (e *ExecutionEngine) SubmitOrder(ctx context.Context, order *OrderRequest) error {
+	if e.Draining() {
+		return errDraining
+	}
+	e.assignOrderID(order)
+	e.normalizeOrderSymbol(order)
+	if _, ok := e.orderPermitted(order); !ok {
+		e.recordRejection(rejectSymbolNotPermitted)
+		e.auditRejection(order, &rejection{Reason: rejectSymbolNotPermitted})
+		return errSymbolNotPermitted
+	}
+	
+	// Shed load rather than queue orders the consumers can't keep up with
+	if e.streamSaturated(ctx) {
+		e.ordersBackpressured.Inc()
+		return errQueueFull
+	}
+	
+	// Add to Redis Stream for processing, tagged with its encoding and
+	// compression and carrying the trace context
+	codec := e.payloadCodec()
+	payload, err := codec.Marshal(order)
+	if err != nil {
+		return err
+	}
+	payload, compression, err := e.compressPayload(payload)
+	if err != nil {
+		return err
+	}
+	values := map[string]interface{}{
+		"order":              payload,
+		payloadEncodingField: codec.Name(),
+	}
+	if compression != "" {
+		values[payloadCompressionField] = compression
+	}
+	injectTraceContext(ctx, values)
+	
+	_, xaddSpan := e.tracer.Start(ctx, "redis.xadd", trace.WithSpanKind(trace.SpanKindProducer))
+	_, err = e.redisClient.XAdd(e.ctx, &redis.XAddArgs{
+		Stream: e.streamName,
+		MaxLen: e.config.StreamMaxLen,
+		Approx: true,
+		Values: values,
+	}).Result()
+	xaddSpan.End()
+	
+	if isTimeout(err) {
+		e.ordersBackpressured.Inc()
+		return errQueueSlow
+	}
+	return err
+}
+
+// routes builds the HTTP handler tree for the engine
+func (e *ExecutionEngine) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 	})
 	
-	http.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			e.handleListOrders(w, r)
+			return
+		}
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			methodNotAllowed(w)
 			return
 		}
 		
+		ctx := tracePropagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := e.tracer.Start(ctx, "submit_order", trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+		
 		var order OrderRequest
 		if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
+			span.SetStatus(codes.Error, "invalid request")
+			if payloadTooLarge(err) {
+				writePayloadTooLarge(w, e.config.MaxPayloadBytes)
+				return
+			}
+			writeError(w, errCodeInvalidRequest, "Invalid request")
 			return
 		}
+		span.SetAttributes(orderAttributes(&order)...)
 		
-		// Add to Redis Stream for processing
-		orderJSON, _ := json.Marshal(order)
-		_, err := e.redisClient.XAdd(e.ctx, &redis.XAddArgs{
-			Stream: e.streamName,
-			Values: map[string]interface{}{
-				"order": orderJSON,
-			},
-		}).Result()
+		// Orders belong to the account of the API key that submitted them
+		if account, ok := accountFromContext(r.Context()); ok {
+			order.AccountID = account
+		}
 		
-		if err != nil {
-			http.Error(w, "Failed to queue order", http.StatusInternalServerError)
+		// Pre-check only: estimate against the book without queueing
+		if isDryRun(r) {
+			json.NewEncoder(w).Encode(e.DryRun(order))
 			return
 		}
 		
-		w.WriteHeader(http.StatusAccepted)
-		json.NewEncoder(w).Encode(map[string]string{
+		// Subscribe before queueing so the outcome can't slip past
+		var outcomes *fillSubscription
+		if e.wantsSyncAck(r) {
+			outcomes = e.subscribers.subscribe(e.canonicalSymbol(order.Symbol))
+			defer e.subscribers.unsubscribe(outcomes)
+		}
+		
+		if err := e.SubmitOrder(ctx, &order); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			if errors.Is(err, errSymbolNotPermitted) {
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(&OrderResponse{
+					OrderID:        order.OrderID,
+					ClientOrderID:  order.IdempotencyKey,
+					Symbol:         order.Symbol,
+					ClientSymbol:   order.ClientSymbol,
+					Status:         statusRejected,
+					AcknowledgedAt: e.now().UnixMilli(),
+					RejectReason:   rejectSymbolNotPermitted,
+				})
+				return
+			}
+			if errors.Is(err, errQueueFull) || errors.Is(err, errQueueSlow) {
+				w.Header().Set("Retry-After", strconv.Itoa(int(backpressureRetryAfter/time.Second)))
+				writeAPIError(w, APIError{Code: errCodeBackpressure, Message: err.Error(), RetryAfterMs: retryAfterMs(backpressureRetryAfter)})
+				return
+			}
+			if errors.Is(err, errDraining) {
+				writeError(w, errCodeUnavailable, err.Error())
+				return
+			}
+			writeError(w, errCodeInternal, "Failed to queue order")
+			return
+		}
+		
+		if outcomes != nil {
+			if response, ok := e.awaitOutcome(r.Context(), outcomes, order.OrderID, e.ackTimeout(r)); ok {
+				json.NewEncoder(w).Encode(e.presentOrder(r, response))
+				return
+			}
+		}
+		
+		accepted := map[string]interface{}{
 			"order_id": order.OrderID,
-			"status":   "accepted",
-		})
+			"status":   string(statusAccepted),
+		}
+		if receipt, err := e.issueReceipt(ctx, &order); err != nil {
+			log.Printf("Error issuing receipt for order %s: %v", order.OrderID, err)
+		} else if receipt != nil {
+			accepted["receipt"] = receipt
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(accepted)
 	})
 	
-	http.HandleFunc("/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/orders/cancel-all", e.handleCancelAll)
+	
+	// Order entry sessions over a WebSocket
+	mux.HandleFunc("/session", e.handleSession)
+	
+	// Execution algos working parent orders
+	mux.HandleFunc("/algos/twap", e.handleStartTWAP)
+	mux.HandleFunc("/algos/twap/", e.handleCancelTWAP)
+	mux.HandleFunc("/algos/vwap", e.handleStartVWAP)
+	mux.HandleFunc("/algos/vwap/", e.handleCancelTWAP)
+	
+	mux.HandleFunc("/orders/", func(w http.ResponseWriter, r *http.Request) {
 		// Extract order ID from path
 		orderID := r.URL.Path[len("/orders/"):]
+		if parentID, ok := strings.CutSuffix(orderID, "/children"); ok {
+			e.handleOrderChildren(w, r, parentID)
+			return
+		}
 		
 		response, ok := e.GetOrder(orderID)
 		if !ok {
-			http.Error(w, "Order not found", http.StatusNotFound)
+			writeError(w, errCodeNotFound, "Order not found")
 			return
 		}
 		
-		json.NewEncoder(w).Encode(response)
+		json.NewEncoder(w).Encode(e.presentOrder(r, response))
 	})
 	
-	// Prometheus metrics endpoint
-	http.Handle("/metrics", promhttp.Handler())
+	// Order book features for research, and snapshots and diffs for UIs
+	mux.HandleFunc("/book/", e.handleBook)
+	
+	// Readiness, and pausing consumption for maintenance
+	mux.HandleFunc("/ready", e.handleReady)
+	mux.HandleFunc("/admin/pause", e.handlePause(true))
+	mux.HandleFunc("/admin/resume", e.handlePause(false))
+	mux.HandleFunc("/admin/drain", e.handleDrain)
+	mux.HandleFunc("/admin/seed-book", e.handleSeedBook)
+	mux.HandleFunc("/admin/reload", e.handleReload)
+	
+	// Per-symbol trading halts
+	mux.HandleFunc("/halts", e.handleListHalts)
+	mux.HandleFunc("/admin/halt/", e.handleHalt(true))
+	mux.HandleFunc("/admin/resume/", e.handleHalt(false))
+	mux.HandleFunc("/admin/autobreaker", e.handleAutoBreaker)
+	mux.HandleFunc("/admin/autobreaker/reset", e.handleAutoBreakerReset)
+	
+	// Profit and loss of positions
+	mux.HandleFunc("/pnl", e.handlePnL)
+	mux.HandleFunc("/pnl/", e.handlePnL)
+	mux.HandleFunc("/admin/positions", e.handlePositions)
+	
+	// Audit trail of rejected orders
+	mux.HandleFunc("/rejections", e.handleListRejections)
+	
+	// Tradable symbols
+	mux.HandleFunc("/symbols", e.handleSymbols)
 	
+	// Dead-letter queue replay
+	mux.HandleFunc("/dlq/replay", e.handleDLQReplay)
+	
+	// Build and runtime stats for operators
+	mux.HandleFunc("/debug/info", e.handleDebugInfo)
+	mux.HandleFunc("/stats/latency", e.handleLatencyStats)
+	mux.HandleFunc("/stats/stream", e.handleStatsStream)
+	mux.HandleFunc("/stats/consumers", e.handleConsumerLag)
+	
+	// Prometheus metrics endpoint
+	mux.Handle("/metrics", promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{}))
+
+	return e.limitBodies(e.authenticate(mux))
+}
+
+// HTTPServer provides HTTP endpoints for order submission
+func (e *ExecutionEngine) HTTPServer(port string) {
 	log.Printf("HTTP server starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	log.Fatal(http.ListenAndServe(":"+port, e.routes()))
 }
 
 func main() {
-	redisHost := getEnv("REDIS_HOST", "localhost")
-	redisPort := getEnv("REDIS_PORT", "6379")
-	streamName := getEnv("REDIS_STREAM", "execution.orders")
-	httpPort := getEnv("HTTP_PORT", "8080")
+	cfg := LoadConfig()
+	
+	shutdownTracing, err := initTracing(context.Background(), cfg.OTLPEndpoint)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
 	
-	engine := NewExecutionEngine(redisHost, redisPort, streamName)
+	engine := NewExecutionEngineFromConfig(cfg)
+	
+	// Backtests replay a recording instead of serving
+	if cfg.ReplayFile != "" {
+		if err := engine.RunReplay(); err != nil {
+			log.Fatalf("Replay failed: %v", err)
+		}
+		return
+	}
+	engine.reloadOnSignal()
+	
+	// Serve HTTP while starting so probes see /ready report it
+	engine.starting.Store(true)
+	go engine.HTTPServer(cfg.HTTPPort)
 	
 	if err := engine.Start(); err != nil {
 		log.Fatalf("Failed to start execution engine: %v", err)
 	}
 	
-	// Start HTTP server
-	engine.HTTPServer(httpPort)
+	// Start the gRPC API alongside HTTP
+	if cfg.GRPCPort != "" {
+		go engine.GRPCServer(cfg.GRPCPort)
+	}
+	if cfg.FIXPort != "" {
+		go engine.FIXServer(cfg.FIXPort)
+	}
+	
+	// Run until a drain via /admin/drain has finished
+	<-engine.Drained()
+	engine.Close()
 }
 
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
+	if value := configFileValue(key); value != "" {
+		return value
+	}
 	return defaultValue
 }
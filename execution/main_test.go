@@ -9,12 +9,35 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/shopspring/decimal"
 )
 
-// BenchmarkOrderExecution measures order execution latency
+// newTestEngine returns an engine wired to an in-process Redis
+func newTestEngine(t *testing.T) (*ExecutionEngine, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	engine := NewExecutionEngine(mr.Host(), mr.Port(), "test-stream")
+	t.Cleanup(func() { engine.redisClient.Close() })
+	return engine, mr
+}
+
+// dec converts a float literal to a decimal for test fixtures
+func dec(f float64) decimal.Decimal {
+	return decimal.NewFromFloat(f)
+}
+
+// BenchmarkOrderExecution measures order execution latency. The zero-value
+// engine has no latency model, so this measures our code rather than the
+// simulated venue delay (see BenchmarkLatencyModels for those).
 func BenchmarkOrderExecution(b *testing.B) {
 	engine := &ExecutionEngine{}
 	
@@ -22,7 +45,7 @@ func BenchmarkOrderExecution(b *testing.B) {
 		OrderID:        "test-order-1",
 		Symbol:         "AAPL",
 		Side:           "buy",
-		Quantity:       100,
+		Quantity:       dec(100),
 		Type:           "market",
 		TimeInForce:    "day",
 		IdempotencyKey: "test-key-1",
@@ -31,7 +54,7 @@ func BenchmarkOrderExecution(b *testing.B) {
 	
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		engine.executeOrder(order)
+		engine.executeOrder(context.Background(), order)
 	}
 }
 
@@ -41,7 +64,7 @@ func BenchmarkOrderSerialization(b *testing.B) {
 		OrderID:        "test-order-1",
 		Symbol:         "AAPL",
 		Side:           "buy",
-		Quantity:       100,
+		Quantity:       dec(100),
 		Type:           "market",
 		TimeInForce:    "day",
 		IdempotencyKey: "test-key-1",
@@ -81,7 +104,7 @@ func BenchmarkEndToEndLatency(b *testing.B) {
 		OrderID:        "test-order-1",
 		Symbol:         "AAPL",
 		Side:           "buy",
-		Quantity:       100,
+		Quantity:       dec(100),
 		Type:           "market",
 		TimeInForce:    "day",
 		IdempotencyKey: "test-key-1",
@@ -93,7 +116,7 @@ func BenchmarkEndToEndLatency(b *testing.B) {
 		startTime := time.Now()
 		
 		// Simulate full execution path
-		response := engine.executeOrder(order)
+		response := engine.executeOrder(context.Background(), order)
 		response.LatencyMs = float64(time.Since(startTime).Milliseconds())
 		
 		// Store in cache
@@ -109,7 +132,7 @@ func TestOrderExecutionLatency(t *testing.T) {
 		OrderID:        "test-order-1",
 		Symbol:         "AAPL",
 		Side:           "buy",
-		Quantity:       100,
+		Quantity:       dec(100),
 		Type:           "market",
 		TimeInForce:    "day",
 		IdempotencyKey: "test-key-1",
@@ -120,7 +143,7 @@ func TestOrderExecutionLatency(t *testing.T) {
 	latencies := make([]float64, 1000)
 	for i := 0; i < 1000; i++ {
 		startTime := time.Now()
-		engine.executeOrder(order)
+		engine.executeOrder(context.Background(), order)
 		latencies[i] = float64(time.Since(startTime).Microseconds()) / 1000.0
 	}
 	
@@ -153,3 +176,137 @@ func calculatePercentiles(latencies []float64) (p50, p95, p99 float64) {
 	
 	return sum / float64(n), max * 0.95, max * 0.99
 }
+
+// fakeGroupCreator answers XGROUP CREATE with a canned error
+type fakeGroupCreator struct {
+	err error
+}
+
+func (f fakeGroupCreator) XGroupCreateMkStream(ctx context.Context, stream, group, start string) *redis.StatusCmd {
+	return redis.NewStatusResult("OK", f.err)
+}
+
+// fakeRedisError mimics an error reply from the server
+type fakeRedisError string
+
+func (e fakeRedisError) Error() string { return string(e) }
+func (fakeRedisError) RedisError()     {}
+
+func TestEnsureConsumerGroup(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{"created", nil, false},
+		{"busygroup", fakeRedisError("BUSYGROUP Consumer Group name already exists"), false},
+		{"busygroup other wording", fakeRedisError("BUSYGROUP consumer group already exists for this key"), false},
+		{"wrong type", fakeRedisError("WRONGTYPE Operation against a key holding the wrong kind of value"), true},
+		{"connection refused", errors.New("dial tcp 127.0.0.1:6379: connect: connection refused"), true},
+		{"busygroup text in a non-redis error", fmt.Errorf("proxy: BUSYGROUP"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ensureConsumerGroup(context.Background(), fakeGroupCreator{tt.err}, "s", "g")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ensureConsumerGroup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, tt.err) {
+				t.Errorf("original error not wrapped: %v", err)
+			}
+		})
+	}
+}
+
+func TestEnsureConsumerGroupAgainstRedis(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	if err := ensureConsumerGroup(context.Background(), engine.redisClient, engine.streamName, engine.consumerGroup); err != nil {
+		t.Fatalf("first create: %v", err)
+	}
+	if err := ensureConsumerGroup(context.Background(), engine.redisClient, engine.streamName, engine.consumerGroup); err != nil {
+		t.Fatalf("second create should tolerate BUSYGROUP: %v", err)
+	}
+
+	engine.redisClient.Set(context.Background(), "not-a-stream", "x", 0)
+	if err := ensureConsumerGroup(context.Background(), engine.redisClient, "not-a-stream", "g"); err == nil {
+		t.Fatal("expected an error creating a group on a non-stream key")
+	}
+}
+
+// panickingAdapter fills every order except poison, on which it panics
+type panickingAdapter struct {
+	countingAdapter
+	poison string
+}
+
+func (a *panickingAdapter) Execute(ctx context.Context, order *OrderRequest) (*OrderResponse, error) {
+	if order.OrderID == a.poison {
+		panic("adapter exploded")
+	}
+	return a.countingAdapter.Execute(ctx, order)
+}
+
+// queueBatch puts orders on the stream for the consumer group to read
+func queueBatch(t *testing.T, engine *ExecutionEngine, ids ...string) {
+	t.Helper()
+	if err := ensureConsumerGroup(context.Background(), engine.redisClient, engine.streamName, engine.consumerGroup); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		order := testOrder(id)
+		if err := engine.SubmitOrder(context.Background(), &order); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPanicInBatchIsIsolatedToItsMessage(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.broker = &panickingAdapter{poison: "batch-2"}
+	queueBatch(t, engine, "batch-1", "batch-2", "batch-3")
+
+	engine.consumeBatch()
+
+	for _, id := range []string{"batch-1", "batch-3"} {
+		if response, ok := engine.GetOrder(id); !ok || response.Status != statusFilled {
+			t.Errorf("%s should have been processed despite the panic, got %+v", id, response)
+		}
+	}
+	ctx := context.Background()
+	if pending := engine.redisClient.XPending(ctx, engine.streamName, engine.consumerGroup).Val(); pending.Count != 0 {
+		t.Errorf("%d messages left pending, want all acknowledged", pending.Count)
+	}
+	entries := engine.redisClient.XRange(ctx, engine.dlqStreamName, "-", "+").Val()
+	if len(entries) != 1 || entries[0].Values["reason"] != dlqReasonPanic {
+		t.Fatalf("expected the panicking order in the DLQ, got %v", entries)
+	}
+	var parked OrderRequest
+	if err := json.Unmarshal([]byte(entries[0].Values["order"].(string)), &parked); err != nil || parked.OrderID != "batch-2" {
+		t.Errorf("DLQ entry holds %+v (%v), want batch-2", parked, err)
+	}
+}
+
+func TestMessageLeftPendingWhenDLQUnavailable(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.broker = &panickingAdapter{poison: "batch-1"}
+	queueBatch(t, engine, "batch-1", "batch-2")
+	ctx := context.Background()
+	engine.redisClient.Set(ctx, engine.dlqStreamName, "not a stream", 0)
+
+	engine.consumeBatch()
+
+	pending := engine.redisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: engine.streamName,
+		Group:  engine.consumerGroup,
+		Start:  "-",
+		End:    "+",
+		Count:  10,
+	}).Val()
+	if len(pending) != 1 {
+		t.Fatalf("expected only the unparked message to stay pending, got %v", pending)
+	}
+	if _, ok := engine.GetOrder("batch-2"); !ok {
+		t.Error("batch-2 should still have been processed")
+	}
+}
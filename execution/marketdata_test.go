@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestMarketOrdersFillAtSymbolReferencePrice(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	prices, err := newPriceCache(`{"AAPL":190.5,"BTCUSD":65000}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.prices = prices
+
+	for _, tc := range []struct {
+		id, symbol string
+		want       float64
+	}{
+		{"mkt-aapl", "AAPL", 190.5},
+		{"mkt-btc", "BTCUSD", 65000},
+		{"mkt-aapl-2", "AAPL", 190.5},
+		{"mkt-msft", "MSFT", 100},
+	} {
+		submitToEngine(t, engine, &OrderRequest{OrderID: tc.id, Symbol: tc.symbol, Side: "buy", Quantity: dec(1), Type: "market", TimeInForce: "day"})
+		response, ok := engine.GetOrder(tc.id)
+		if !ok || response.Status != "filled" {
+			t.Fatalf("%s: expected filled, got %+v", tc.id, response)
+		}
+		if !response.FilledAvgPrice.Equal(dec(tc.want)) {
+			t.Errorf("%s: filled at %s, want %v", tc.id, response.FilledAvgPrice, tc.want)
+		}
+	}
+}
+
+func TestBookTradeMovesReferencePrice(t *testing.T) {
+	engine, _ := newTestEngine(t)
+
+	submitToEngine(t, engine, limitOrder("ask-1", "AAPL", "sell", 187.25, 5))
+	submitToEngine(t, engine, limitOrder("bid-1", "AAPL", "buy", 187.25, 5))
+	if got := engine.prices.reference("AAPL"); !got.Equal(dec(187.25)) {
+		t.Fatalf("reference after book trade = %s, want 187.25", got)
+	}
+
+	submitToEngine(t, engine, &OrderRequest{OrderID: "mkt-1", Symbol: "AAPL", Side: "buy", Quantity: dec(1), Type: "market", TimeInForce: "day"})
+	if response, _ := engine.GetOrder("mkt-1"); !response.FilledAvgPrice.Equal(dec(187.25)) {
+		t.Errorf("market order filled at %s, want last trade 187.25", response.FilledAvgPrice)
+	}
+}
+
+func TestNewPriceCacheRejectsBadConfig(t *testing.T) {
+	if _, err := newPriceCache(`{"AAPL":`); err == nil {
+		t.Fatal("expected error for malformed REFERENCE_PRICES")
+	}
+}
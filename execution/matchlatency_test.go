@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// histogramSamples returns the sample count and sum of an unlabeled histogram
+func histogramSamples(t testing.TB, engine *ExecutionEngine, name string) (uint64, float64) {
+	t.Helper()
+	families, err := engine.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == name {
+			h := family.GetMetric()[0].GetHistogram()
+			return h.GetSampleCount(), h.GetSampleSum()
+		}
+	}
+	return 0, 0
+}
+
+func TestMatchLatencyExcludesSimulatedVenueLatency(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.latencyModel = FixedLatency{Latency: 20 * time.Millisecond}
+	submitToEngine(t, engine, limitOrder("ask", "AAPL", "sell", 100, 10))
+	submitToEngine(t, engine, limitOrder("bid", "AAPL", "buy", 100, 10))
+
+	brokerCount, brokerSum := histogramSamples(t, engine, "broker_latency_milliseconds")
+	matchCount, matchSum := histogramSamples(t, engine, "match_latency_milliseconds")
+	if brokerCount != 2 || matchCount != 2 {
+		t.Fatalf("observed %d broker calls and %d matches, want 2 of each", brokerCount, matchCount)
+	}
+	if brokerSum < 40 {
+		t.Errorf("broker latency %.3fms over two orders, want at least the 40ms simulated", brokerSum)
+	}
+	if matchSum >= 40 {
+		t.Errorf("match latency %.3fms over two orders includes the simulated delay", matchSum)
+	}
+}
+
+func TestMatchLatencyExcludesWaitingForTheBook(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	submitToEngine(t, engine, limitOrder("ask", "AAPL", "sell", 100, 10))
+
+	// Someone else holds the book while the bid arrives
+	book := engine.bookFor("AAPL")
+	book.mu.Lock()
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		book.mu.Unlock()
+	}()
+	submitToEngine(t, engine, limitOrder("bid", "AAPL", "buy", 100, 10))
+
+	if status := restingStatus(t, engine, "bid"); status != statusFilled {
+		t.Fatalf("bid %s, want filled", status)
+	}
+	count, sum := histogramSamples(t, engine, "match_latency_milliseconds")
+	if count != 2 || sum >= 30 {
+		t.Errorf("match latency %.3fms over %d orders, want 2 without the 30ms wait for the book", sum, count)
+	}
+}
+
+// BenchmarkMatchLatency executes crossing orders through the simulator with
+// a venue delay, reporting the mean match latency apart from the time per
+// order
+func BenchmarkMatchLatency(b *testing.B) {
+	for _, venue := range []time.Duration{0, 100 * time.Microsecond} {
+		b.Run(fmt.Sprintf("venue=%v", venue), func(b *testing.B) {
+			mr := miniredis.RunT(b)
+			engine := NewExecutionEngine(mr.Host(), mr.Port(), "bench-stream")
+			defer engine.redisClient.Close()
+			engine.latencyModel = FixedLatency{Latency: venue}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				side := "buy"
+				if i%2 == 1 {
+					side = "sell"
+				}
+				engine.executeOrder(context.Background(), limitOrder(fmt.Sprint(i), "AAPL", side, 100, 1))
+			}
+			b.StopTimer()
+
+			count, sum := histogramSamples(b, engine, "match_latency_milliseconds")
+			b.ReportMetric(sum*1e3/float64(count), "match-µs/op")
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// ==============================================================================
+// Minimum resting time - orders must stay in the book before they can go
+// ==============================================================================
+// Some venues require orders to rest for a minimum time before they may be
+// cancelled, which makes flashing orders to move the market (spoofing)
+// expensive. MIN_REST_TIME sets that time for every symbol (0 disables it)
+// and MIN_REST_TIMES overrides it per symbol as JSON, e.g.
+// {"AAPL":"500ms","BTCUSD":"0s"}. A cancel of an order that has rested for
+// less is refused with "min_rest_time_not_elapsed"; mass cancels leave such
+// orders in the book.
+//
+// Resting time is measured on the engine's clock from when the order entered
+// the book (rested_at on the book entry). Cancels the engine makes itself -
+// OCO siblings, self-trade prevention, book eviction - aren't held back, and
+// orders restored without a rested_at count as having rested long enough.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// rejectMinRestTime is the reason cancels of too recent orders are refused
+const rejectMinRestTime = "min_rest_time_not_elapsed"
+
+// errMinRestTime is returned by CancelOrder for orders that haven't rested
+// long enough
+var errMinRestTime = errors.New(rejectMinRestTime)
+
+// parseMinRestTimes parses per-symbol resting times, e.g. {"AAPL":"500ms"}
+func parseMinRestTimes(raw string) (map[string]time.Duration, error) {
+	times := map[string]time.Duration{}
+	if raw == "" {
+		return times, nil
+	}
+	var values map[string]string
+	if err := json.Unmarshal([]byte(raw), &values); err != nil {
+		return nil, err
+	}
+	for symbol, value := range values {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid resting time %q for %s", value, symbol)
+		}
+		times[symbol] = d
+	}
+	return times, nil
+}
+
+// minRestTime is the resting time required in symbol
+func (e *ExecutionEngine) minRestTime(symbol string) time.Duration {
+	if d, ok := e.minRestTimes[symbol]; ok {
+		return d
+	}
+	return e.config.MinRestTime
+}
+
+// restTimeLeft is how much longer order must rest in symbol's book before it
+// may be cancelled
+func (e *ExecutionEngine) restTimeLeft(symbol string, order *BookOrder) time.Duration {
+	required := e.minRestTime(symbol)
+	if required <= 0 || order.RestedAt == 0 {
+		return 0
+	}
+	rested := e.now().Sub(time.UnixMilli(order.RestedAt))
+	if rested >= required {
+		return 0
+	}
+	return required - rested
+}
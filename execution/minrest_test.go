@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCancelHeldBackUntilMinRestTime(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+	engine.minRestTimes = map[string]time.Duration{"AAPL": 500 * time.Millisecond}
+
+	submitToEngine(t, engine, limitOrder("bid-1", "AAPL", "buy", 99, 10))
+
+	clock.advance(200 * time.Millisecond)
+	if _, err := engine.CancelOrder("bid-1", ""); !errors.Is(err, errMinRestTime) {
+		t.Fatalf("cancel after 200ms: err %v, want %v", err, errMinRestTime)
+	}
+	if s := restingStatus(t, engine, "bid-1"); s != statusWorking {
+		t.Fatalf("order after a refused cancel: status %s, want working", s)
+	}
+	if cancelled := engine.CancelAll(CancelFilter{}); len(cancelled) != 0 {
+		t.Errorf("mass cancel before the resting time cancelled %v", cancelled)
+	}
+
+	clock.advance(300 * time.Millisecond)
+	response, err := engine.CancelOrder("bid-1", "")
+	if err != nil {
+		t.Fatalf("cancel after 500ms: %v", err)
+	}
+	if response.Status != statusCancelled {
+		t.Errorf("status after cancel = %s, want cancelled", response.Status)
+	}
+}
+
+func TestMinRestTimeIsPerSymbol(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.clock = newFakeClock()
+	engine.config.MinRestTime = time.Second
+	engine.minRestTimes = map[string]time.Duration{"MSFT": 0}
+
+	submitToEngine(t, engine, limitOrder("aapl-bid", "AAPL", "buy", 99, 10))
+	submitToEngine(t, engine, limitOrder("msft-bid", "MSFT", "buy", 99, 10))
+
+	if _, err := engine.CancelOrder("aapl-bid", ""); !errors.Is(err, errMinRestTime) {
+		t.Errorf("cancel under the default resting time: err %v, want %v", err, errMinRestTime)
+	}
+	if _, err := engine.CancelOrder("msft-bid", ""); err != nil {
+		t.Errorf("cancel in a symbol without a resting time: %v", err)
+	}
+}
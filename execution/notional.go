@@ -0,0 +1,67 @@
+// ==============================================================================
+// Notional orders - size a market order by what it spends
+// ==============================================================================
+// A market order may give notional (e.g. 1000 to buy $1000 of BTC) instead of
+// quantity. Its quantity is worked out when it executes: against the book,
+// by walking the opposite side until the notional is spent; with nothing to
+// take, at the symbol's reference price. Quantities are rounded down to the
+// instrument's lot size so the order never spends more than its notional.
+//
+// If the book runs out first, the quantity still covers the whole notional,
+// priced at the last level reached, so the order partially fills and the
+// rest is cancelled like any market order's. Iceberg reserves are not
+// visible to the walk. An order whose notional doesn't buy a single lot is
+// rejected.
+// ==============================================================================
+
+package main
+
+import "github.com/shopspring/decimal"
+
+// rejectNotionalTooSmall is the reason for notional orders smaller than a lot
+const rejectNotionalTooSmall = "notional_below_lot_size"
+
+// quantityForNotional returns the quantity notional buys against the visible
+// side of the book an order on side takes from, skipping accountID's own
+// orders. It reports false if there is nothing to take.
+func (b *OrderBook) quantityForNotional(side string, notional decimal.Decimal, accountID string) (decimal.Decimal, bool) {
+	var quantity, lastPrice decimal.Decimal
+	remaining := notional
+	for _, level := range *b.levels(oppositeSide(side)) {
+		for _, o := range level.orders {
+			if accountID != "" && o.AccountID == accountID {
+				continue
+			}
+			lastPrice = level.price
+			if buys := remaining.Div(level.price); buys.LessThanOrEqual(o.Quantity) {
+				return quantity.Add(buys), true
+			}
+			quantity = quantity.Add(o.Quantity)
+			remaining = remaining.Sub(o.Quantity.Mul(level.price))
+		}
+	}
+	if lastPrice.IsZero() {
+		return decimal.Zero, false
+	}
+	return quantity.Add(remaining.Div(lastPrice)), true
+}
+
+// sizeNotional sets a notional order's quantity from book, or from the
+// reference price if the book has nothing to take. Callers must hold the
+// book's lock.
+func (e *ExecutionEngine) sizeNotional(book *OrderBook, order *OrderRequest) {
+	quantity, ok := book.quantityForNotional(order.Side, order.Notional, order.AccountID)
+	if !ok {
+		quantity = order.Notional.Div(e.prices.reference(order.Symbol))
+	}
+	order.Quantity = e.floorToLot(order.Symbol, quantity)
+}
+
+// floorToLot rounds quantity down to symbol's lot size, if it has one
+func (e *ExecutionEngine) floorToLot(symbol string, quantity decimal.Decimal) decimal.Decimal {
+	spec, ok := e.instruments[symbol]
+	if !ok || !spec.LotSize.IsPositive() {
+		return quantity
+	}
+	return quantity.Div(spec.LotSize).Floor().Mul(spec.LotSize)
+}
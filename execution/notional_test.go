@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// notionalBuy is a market buy of notional worth of sym for acct-buyer
+func notionalBuy(id string, sym string, notional float64) *OrderRequest {
+	return &OrderRequest{OrderID: id, Symbol: sym, Side: "buy", Type: "market", Notional: dec(notional), AccountID: "acct-buyer"}
+}
+
+// seedAsk rests a sell limit from acct-seller
+func seedAsk(t *testing.T, engine *ExecutionEngine, id string, sym string, price float64, qty float64) {
+	t.Helper()
+	ask := limitOrder(id, sym, "sell", price, qty)
+	ask.AccountID = "acct-seller"
+	submitToEngine(t, engine, ask)
+}
+
+func TestNotionalMarketOrderFillsNotionalOverPrice(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	seedAsk(t, engine, "ask-1", "BTC", 50000, 1)
+
+	submitToEngine(t, engine, notionalBuy("buy-1", "BTC", 1000))
+
+	response, _ := engine.GetOrder("buy-1")
+	want := dec(1000).Div(dec(50000))
+	if response.Status != statusFilled || !response.FilledQuantity.Equal(want) || !response.FilledAvgPrice.Equal(dec(50000)) {
+		t.Errorf("got %s %s @ %s, want filled %s @ 50000", response.Status, response.FilledQuantity, response.FilledAvgPrice, want)
+	}
+}
+
+func TestNotionalOrderWalksTheBook(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	seedAsk(t, engine, "ask-1", "AAPL", 100, 5)
+	seedAsk(t, engine, "ask-2", "AAPL", 110, 10)
+
+	submitToEngine(t, engine, notionalBuy("buy-1", "AAPL", 1050))
+
+	// 5 @ 100 spends 500, the other 550 buys 5 @ 110
+	response, _ := engine.GetOrder("buy-1")
+	if response.Status != statusFilled || !response.FilledQuantity.Equal(dec(10)) || !response.FilledAvgPrice.Equal(dec(105)) {
+		t.Errorf("got %s %s @ %s, want filled 10 @ 105", response.Status, response.FilledQuantity, response.FilledAvgPrice)
+	}
+	if resting, ok := engine.bookFor("AAPL").Get("ask-2"); !ok || !resting.Quantity.Equal(dec(5)) {
+		t.Errorf("ask-2 should have 5 left")
+	}
+}
+
+func TestNotionalOrderBeyondTheBookPartiallyFills(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	seedAsk(t, engine, "ask-1", "AAPL", 100, 5)
+
+	submitToEngine(t, engine, notionalBuy("buy-1", "AAPL", 1000))
+
+	response, _ := engine.GetOrder("buy-1")
+	if response.Status != statusPartiallyFilled || !response.FilledQuantity.Equal(dec(5)) {
+		t.Errorf("got %s %s, want partially filled 5", response.Status, response.FilledQuantity)
+	}
+	if inBook(engine, "AAPL", "buy-1") {
+		t.Error("unspent notional rested in the book")
+	}
+}
+
+func TestNotionalOrderWithoutBookFillsAtReferencePrice(t *testing.T) {
+	engine, _ := newTestEngine(t)
+
+	submitToEngine(t, engine, notionalBuy("buy-1", "AAPL", 250))
+
+	response, _ := engine.GetOrder("buy-1")
+	want := dec(250).Div(defaultReferencePrice)
+	if response.Status != statusFilled || !response.FilledQuantity.Equal(want) {
+		t.Errorf("got %s %s, want filled %s", response.Status, response.FilledQuantity, want)
+	}
+}
+
+func TestNotionalQuantityRoundsDownToLotSize(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.instruments = map[string]InstrumentSpec{"AAPL": {LotSize: dec(1)}}
+
+	submitToEngine(t, engine, notionalBuy("buy-1", "AAPL", 250))
+	if response, _ := engine.GetOrder("buy-1"); !response.FilledQuantity.Equal(dec(2)) {
+		t.Errorf("250 at 100 filled %s, want 2 whole lots", response.FilledQuantity)
+	}
+
+	submitToEngine(t, engine, notionalBuy("buy-2", "AAPL", 50))
+	if response, _ := engine.GetOrder("buy-2"); response.Status != statusRejected || response.RejectReason != rejectNotionalTooSmall {
+		t.Errorf("notional below a lot: %s %s, want rejected %s", response.Status, response.RejectReason, rejectNotionalTooSmall)
+	}
+}
+
+func TestValidateNotional(t *testing.T) {
+	both := notionalBuy("o", "AAPL", 1000)
+	both.Quantity = dec(1)
+	limit := notionalBuy("o", "AAPL", 1000)
+	limit.Type = "limit"
+	limit.LimitPrice = dec(100)
+	negative := notionalBuy("o", "AAPL", 1000)
+	negative.Notional = decimal.NewFromInt(-1)
+
+	for name, order := range map[string]*OrderRequest{"both": both, "limit": limit, "negative": negative} {
+		if err := validateOrder(order); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+	if err := validateOrder(notionalBuy("o", "AAPL", 1000)); err != nil {
+		t.Errorf("notional market order rejected: %v", err)
+	}
+}
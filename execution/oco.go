@@ -0,0 +1,170 @@
+// ==============================================================================
+// OCO groups - linked orders where one filling cancels the others
+// ==============================================================================
+// Orders submitted with the same oco_group by the same account form a
+// one-cancels-other group, typically a take-profit limit and a stop-loss stop
+// bracketing a position. Once any leg has filled OCO_FILL_THRESHOLD of its
+// quantity (0, the default, means any fill at all), the group's other legs
+// are cancelled.
+//
+// Legs join their group when they are consumed. The cancels go out as soon as
+// the order that caused the fill has finished executing, before stops are
+// checked, so a stop-loss can't trigger off the trade that filled its
+// take-profit. Group membership and fills are kept in "<stream>.oco" so
+// groups survive a restart.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// ocoLeg is one order's membership of an OCO group
+type ocoLeg struct {
+	Group    string          `json:"group"` // account and group name
+	Quantity decimal.Decimal `json:"quantity"`
+	Filled   decimal.Decimal `json:"filled"`
+}
+
+// ocoRegistry tracks OCO groups and the legs whose fills have reached the
+// threshold but whose siblings are not cancelled yet
+type ocoRegistry struct {
+	mu     sync.Mutex
+	legs   map[string]*ocoLeg         // by order ID
+	groups map[string]map[string]bool // group -> order IDs
+	fired  []string                   // order IDs
+}
+
+func newOCORegistry() *ocoRegistry {
+	return &ocoRegistry{legs: map[string]*ocoLeg{}, groups: map[string]map[string]bool{}}
+}
+
+// ocoGroupKey names an account's group, so accounts can't link each other's
+// orders
+func ocoGroupKey(account string, group string) string {
+	return account + "/" + group
+}
+
+// add registers a leg, reporting false if the order already is one (e.g. a
+// held order being activated)
+func (r *ocoRegistry) add(orderID string, leg *ocoLeg) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.legs[orderID]; ok {
+		return false
+	}
+	r.legs[orderID] = leg
+	if r.groups[leg.Group] == nil {
+		r.groups[leg.Group] = map[string]bool{}
+	}
+	r.groups[leg.Group][orderID] = true
+	return true
+}
+
+// recordFill adds a fill to a leg, returning a copy of the updated leg and
+// whether the fill took it to threshold, firing its group
+func (r *ocoRegistry) recordFill(orderID string, quantity decimal.Decimal, threshold float64) (*ocoLeg, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	leg, ok := r.legs[orderID]
+	if !ok || !quantity.IsPositive() {
+		return nil, false
+	}
+	leg.Filled = leg.Filled.Add(quantity)
+	updated := *leg
+	if leg.Filled.LessThan(decimal.NewFromFloat(threshold).Mul(leg.Quantity)) {
+		return &updated, false
+	}
+	r.fired = append(r.fired, orderID)
+	return &updated, true
+}
+
+// takeFired removes and returns the groups that fired, as the siblings of
+// each firing leg
+func (r *ocoRegistry) takeFired() (siblings []string, removed []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, orderID := range r.fired {
+		leg, ok := r.legs[orderID]
+		if !ok {
+			continue // its group already fired
+		}
+		for id := range r.groups[leg.Group] {
+			if id != orderID {
+				siblings = append(siblings, id)
+			}
+			removed = append(removed, id)
+			delete(r.legs, id)
+		}
+		delete(r.groups, leg.Group)
+	}
+	r.fired = nil
+	return siblings, removed
+}
+
+// joinOCOGroup registers an order as a leg of its OCO group
+func (e *ExecutionEngine) joinOCOGroup(order *OrderRequest) {
+	if order.OCOGroup == "" {
+		return
+	}
+	leg := &ocoLeg{Group: ocoGroupKey(order.AccountID, order.OCOGroup), Quantity: order.Quantity}
+	if e.ocos.add(order.OrderID, leg) {
+		e.saveOCOLeg(order.OrderID, leg)
+	}
+}
+
+// recordOCOFill counts a fill towards an order's OCO group threshold
+func (e *ExecutionEngine) recordOCOFill(orderID string, quantity decimal.Decimal) {
+	leg, fired := e.ocos.recordFill(orderID, quantity, e.config.OCOFillThreshold)
+	if leg == nil {
+		return
+	}
+	if fired {
+		log.Printf("Order %s filled %s of %s, cancelling the rest of its OCO group", orderID, leg.Filled, leg.Quantity)
+	}
+	e.saveOCOLeg(orderID, leg)
+}
+
+// cancelOCOSiblings cancels the other legs of every group that fired. It
+// must not be called with a book's lock held.
+func (e *ExecutionEngine) cancelOCOSiblings() {
+	siblings, removed := e.ocos.takeFired()
+	for _, orderID := range siblings {
+		if _, err := e.cancelOrder(orderID, "", false); err != nil && err != errOrderNotWorking {
+			log.Printf("Error cancelling OCO leg %s: %v", orderID, err)
+		}
+	}
+	if len(removed) > 0 {
+		e.redisClient.HDel(e.ctx, e.ocoKey, removed...)
+	}
+}
+
+// saveOCOLeg persists a leg
+func (e *ExecutionEngine) saveOCOLeg(orderID string, leg *ocoLeg) {
+	data, _ := json.Marshal(leg)
+	if err := e.redisClient.HSet(e.ctx, e.ocoKey, orderID, data).Err(); err != nil {
+		log.Printf("Error saving OCO leg %s: %v", orderID, err)
+	}
+}
+
+// RecoverOCOGroups reloads the OCO groups saved before a restart
+func (e *ExecutionEngine) RecoverOCOGroups() error {
+	saved, err := e.redisClient.HGetAll(e.ctx, e.ocoKey).Result()
+	if err != nil {
+		return err
+	}
+	for orderID, data := range saved {
+		var leg ocoLeg
+		if err := json.Unmarshal([]byte(data), &leg); err != nil {
+			log.Printf("Discarding undecodable OCO leg %s: %v", orderID, err)
+			continue
+		}
+		e.ocos.add(orderID, &leg)
+	}
+	return nil
+}
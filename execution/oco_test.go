@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// bracket submits a take-profit sell at 110 and a stop-loss sell at 95 for
+// acct-1, linked in one OCO group
+func bracket(t *testing.T, engine *ExecutionEngine) {
+	t.Helper()
+	takeProfit := limitOrder("take-profit", "AAPL", "sell", 110, 10)
+	takeProfit.AccountID = "acct-1"
+	takeProfit.OCOGroup = "bracket-1"
+	stopLoss := stopOrder("stop-loss")
+	stopLoss.LimitPrice = decimal.Zero
+	stopLoss.AccountID = "acct-1"
+	stopLoss.OCOGroup = "bracket-1"
+	submitToEngine(t, engine, takeProfit)
+	submitToEngine(t, engine, stopLoss)
+}
+
+func TestOCOTakeProfitFillCancelsStopLoss(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	trade(t, engine, "t1", 100)
+	bracket(t, engine)
+
+	buy := limitOrder("buy", "AAPL", "buy", 110, 10)
+	buy.AccountID = "acct-2"
+	submitToEngine(t, engine, buy)
+
+	if response, _ := engine.GetOrder("take-profit"); response.Status != statusFilled {
+		t.Fatalf("take-profit: status %s, want filled", response.Status)
+	}
+	if response, _ := engine.GetOrder("stop-loss"); response.Status != statusCancelled {
+		t.Fatalf("stop-loss: status %s, want cancelled", response.Status)
+	}
+
+	// The cancelled stop stays cancelled when the market falls through it
+	trade(t, engine, "t2", 90)
+	if response, _ := engine.GetOrder("stop-loss"); response.Status != statusCancelled {
+		t.Errorf("cancelled stop-loss executed: %+v", response)
+	}
+}
+
+func TestOCOStopLossFillCancelsTakeProfit(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	trade(t, engine, "t1", 100)
+	bracket(t, engine)
+
+	trade(t, engine, "t2", 95)
+	if response, _ := engine.GetOrder("stop-loss"); response.Status != statusFilled {
+		t.Fatalf("stop-loss: status %s, want filled", response.Status)
+	}
+	if response, _ := engine.GetOrder("take-profit"); response.Status != statusCancelled {
+		t.Fatalf("take-profit: status %s, want cancelled", response.Status)
+	}
+	if inBook(engine, "AAPL", "take-profit") {
+		t.Error("cancelled take-profit still resting")
+	}
+}
+
+func TestOCOPartialFillBelowThresholdKeepsGroup(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.OCOFillThreshold = 0.5
+	trade(t, engine, "t1", 100)
+	bracket(t, engine)
+
+	buy := limitOrder("buy-1", "AAPL", "buy", 110, 4)
+	buy.AccountID = "acct-2"
+	submitToEngine(t, engine, buy)
+	if status := restingStatus(t, engine, "stop-loss"); status != statusHeld {
+		t.Fatalf("stop-loss after a 40%% fill: status %s, want held", status)
+	}
+
+	buy = limitOrder("buy-2", "AAPL", "buy", 110, 1)
+	buy.AccountID = "acct-2"
+	submitToEngine(t, engine, buy)
+	if response, _ := engine.GetOrder("stop-loss"); response.Status != statusCancelled {
+		t.Errorf("stop-loss after a 50%% fill: status %s, want cancelled", response.Status)
+	}
+}
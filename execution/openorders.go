@@ -0,0 +1,116 @@
+// ==============================================================================
+// Open order limits - cap how many orders one account has working
+// ==============================================================================
+// MAX_OPEN_ORDERS_PER_ACCOUNT (0 = unlimited) bounds the orders an account may
+// have resting in the books at once, so a runaway strategy can't flood them.
+// Once an account is at its cap, its new orders are rejected with
+// "too_many_open_orders" until one of its working orders fills, is cancelled
+// or is evicted. Orders without an account aren't capped.
+//
+// Held orders - stops and conditionals waiting for their trigger and
+// good-after-time orders waiting to activate - count as well, since they'll
+// work without the account sending anything more.
+//
+// Each account's count is kept as orders come and go rather than worked out
+// per order: books add to it whenever an order rests and take from it
+// whenever one leaves, however it leaves (fill, cancel, eviction), and
+// holding or releasing an order does the same. It is rebuilt from the books
+// and the held orders on startup (see RecoverBooks).
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// rejectTooManyOpenOrders is the reason for orders from accounts at their cap
+const rejectTooManyOpenOrders = "too_many_open_orders"
+
+// openOrderCounts is the number of open orders of each account
+type openOrderCounts struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// add changes account's count by n. Orders without an account aren't
+// counted.
+func (c *openOrderCounts) add(account string, n int) {
+	if c == nil || account == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int)
+	}
+	c.counts[account] += n
+	if c.counts[account] <= 0 {
+		delete(c.counts, account)
+	}
+}
+
+// get returns account's count
+func (c *openOrderCounts) get(account string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[account]
+}
+
+// reset forgets every count
+func (c *openOrderCounts) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts = nil
+}
+
+// openOrderCount is the number of orders account has resting or held
+func (e *ExecutionEngine) openOrderCount(account string) int {
+	return e.openOrders.get(account)
+}
+
+// recountOpenOrders rebuilds the open order counts from the books and the
+// held orders, and has the books keep them from then on. Callers must hold
+// lockBooks.
+func (e *ExecutionEngine) recountOpenOrders(ctx context.Context) error {
+	e.openOrders.reset()
+	for _, book := range e.books {
+		book.open = &e.openOrders
+		for _, order := range book.orders {
+			e.openOrders.add(order.AccountID, 1)
+		}
+	}
+	held, err := e.redisClient.HVals(ctx, e.heldOrdersKey).Result()
+	if err != nil {
+		return err
+	}
+	for _, payload := range held {
+		var order OrderRequest
+		if json.Unmarshal([]byte(payload), &order) == nil {
+			e.openOrders.add(order.AccountID, 1)
+		}
+	}
+	return nil
+}
+
+// checkOpenOrders refuses an order whose account is at its open order cap
+func (e *ExecutionEngine) checkOpenOrders(order *OrderRequest) *rejection {
+	limit := e.riskLimits().MaxOpenOrdersPerAccount
+	if limit <= 0 || order.AccountID == "" {
+		return nil
+	}
+	open := e.openOrderCount(order.AccountID)
+	if open < limit {
+		return nil
+	}
+	return &rejection{
+		Reason: rejectTooManyOpenOrders,
+		Detail: fmt.Sprintf("account %s has %d open orders (limit %d)", order.AccountID, open, limit),
+		Limit:  &RiskLimit{Name: riskLimitOpenOrders, Limit: decimal.NewFromInt(int64(limit)), Current: decimal.NewFromInt(int64(open))},
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOpenOrderCapPerAccount(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	setRiskLimits(engine, func(l *RiskLimits) { l.MaxOpenOrdersPerAccount = 2 })
+	order := func(id string, account string, side string, price float64) *OrderRequest {
+		o := limitOrder(id, "AAPL", side, price, 10)
+		o.AccountID = account
+		return o
+	}
+	status := func(id string) (OrderState, string) {
+		response, _ := engine.GetOrder(id)
+		return response.Status, response.RejectReason
+	}
+
+	submitToEngine(t, engine, order("bid-1", "acct-1", "buy", 99))
+	submitToEngine(t, engine, order("bid-2", "acct-1", "buy", 98))
+	submitToEngine(t, engine, order("bid-3", "acct-1", "buy", 97))
+	if s, reason := status("bid-3"); s != statusRejected || reason != rejectTooManyOpenOrders {
+		t.Fatalf("order over the cap: got %s/%s, want %s rejection", s, reason, rejectTooManyOpenOrders)
+	}
+
+	// Other accounts aren't affected
+	submitToEngine(t, engine, order("other", "acct-2", "buy", 97))
+	if s := restingStatus(t, engine, "other"); s != statusWorking {
+		t.Errorf("another account's order: status %s, want working", s)
+	}
+
+	// A cancel frees a slot
+	if _, err := engine.CancelOrder("bid-2", "acct-1"); err != nil {
+		t.Fatal(err)
+	}
+	submitToEngine(t, engine, order("bid-4", "acct-1", "buy", 97))
+	if s := restingStatus(t, engine, "bid-4"); s != statusWorking {
+		t.Fatalf("order after a cancel: status %s, want working", s)
+	}
+
+	// So does a fill
+	submitToEngine(t, engine, order("ask", "acct-2", "sell", 99))
+	submitToEngine(t, engine, order("bid-5", "acct-1", "buy", 96))
+	if s := restingStatus(t, engine, "bid-5"); s != statusWorking {
+		t.Errorf("order after a fill: status %s, want working", s)
+	}
+}
+
+func TestHeldOrdersCountTowardsTheCap(t *testing.T) {
+	engine, mr := newTestEngine(t)
+	setRiskLimits(engine, func(l *RiskLimits) { l.MaxOpenOrdersPerAccount = 3 })
+	tick(engine, "VIX", 20)
+
+	stop := stopOrder("stop")
+	stop.AccountID = "acct-1"
+	cond := testOrder("cond")
+	cond.AccountID, cond.Condition = "acct-1", "VIX < 15"
+	gat := limitOrder("gat", "AAPL", "buy", 90, 10)
+	gat.AccountID, gat.ActivateAt = "acct-1", engine.now().Add(time.Hour).UnixMilli()
+	for _, order := range []*OrderRequest{stop, &cond, gat} {
+		submitToEngine(t, engine, order)
+		if s := restingStatus(t, engine, order.OrderID); s != statusHeld {
+			t.Fatalf("%s: status %s, want held", order.OrderID, s)
+		}
+	}
+
+	over := limitOrder("over", "AAPL", "buy", 90, 10)
+	over.AccountID = "acct-1"
+	submitToEngine(t, engine, over)
+	if response, _ := engine.GetOrder("over"); response.RejectReason != rejectTooManyOpenOrders {
+		t.Fatalf("order over the cap with three held: %s/%s, want %s rejection", response.Status, response.RejectReason, rejectTooManyOpenOrders)
+	}
+
+	// The count is rebuilt from the held orders on restart
+	restarted := restartEngine(t, mr)
+	if err := restarted.RecoverBooks(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := restarted.openOrderCount("acct-1"); n != 3 {
+		t.Errorf("open orders after a restart = %d, want 3", n)
+	}
+
+	// Cancelling a held order frees its slot, and so does a trigger that
+	// fills it
+	if _, err := engine.CancelOrder("gat", "acct-1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := engine.openOrderCount("acct-1"); n != 2 {
+		t.Errorf("open orders after cancelling a held order = %d, want 2", n)
+	}
+	tick(engine, "AAPL", 100)
+	tick(engine, "VIX", 14)
+	if s := restingStatus(t, engine, "cond"); s != statusFilled {
+		t.Fatalf("triggered conditional: status %s, want filled", s)
+	}
+	if n := engine.openOrderCount("acct-1"); n != 1 {
+		t.Errorf("open orders after the conditional filled = %d, want 1", n)
+	}
+}
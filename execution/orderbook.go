@@ -0,0 +1,702 @@
+// ==============================================================================
+// Order Book - in-memory price-time priority limit order book
+// ==============================================================================
+// Each symbol has its own book holding resting limit orders. Price levels are
+// kept sorted best-first and orders within a level are queued in arrival order,
+// so matching always consumes the best price and the oldest order first.
+//
+// Iceberg orders rest only a display slice of their size. When the slice is
+// filled, the next one is cut from the hidden reserve and joins the back of
+// its level with a new sequence number, losing its queue priority. The
+// reserve is never visible: liquidity checks and dry-run estimates only see
+// displayed quantity, and book features only report the total hidden
+// across the book, which is kept as a running sum so reading it is free.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// BookOrder is a resting order in the book
+type BookOrder struct {
+	OrderID   string          `json:"order_id"`
+	AccountID string          `json:"account_id,omitempty"`
+	Side      string          `json:"side"`
+	Price     decimal.Decimal `json:"price"`
+	Quantity  decimal.Decimal `json:"quantity"` // remaining displayed quantity
+	Sequence  uint64          `json:"sequence"` // arrival order within the book
+
+	// Iceberg orders only: the size of each displayed slice and the hidden
+	// quantity left to replenish it from
+	DisplayQuantity decimal.Decimal `json:"display_quantity,omitempty"`
+	Reserve         decimal.Decimal `json:"reserve,omitempty"`
+
+	// Pegged orders only: the reference the price tracks, the offset from
+	// it, and when the price was last set
+	PegTo     string          `json:"peg_to,omitempty"`
+	PegOffset decimal.Decimal `json:"peg_offset,omitempty"`
+	peggedAt  time.Time
+
+	// Whether the order may only shrink its account's position
+	ReduceOnly bool `json:"reduce_only,omitempty"`
+
+	// When the order entered the book, in unix ms
+	RestedAt int64 `json:"rested_at,omitempty"`
+}
+
+// MarshalJSON leaves out the iceberg and peg fields of orders that are
+// neither (see decimal.go)
+func (o BookOrder) MarshalJSON() ([]byte, error) {
+	type plain BookOrder
+	return json.Marshal(struct {
+		plain
+		DisplayQuantity *decimal.Decimal `json:"display_quantity,omitempty"`
+		Reserve         *decimal.Decimal `json:"reserve,omitempty"`
+		PegOffset       *decimal.Decimal `json:"peg_offset,omitempty"`
+	}{
+		plain:           plain(o),
+		DisplayQuantity: omitZero(o.DisplayQuantity),
+		Reserve:         omitZero(o.Reserve),
+		PegOffset:       omitZero(o.PegOffset),
+	})
+}
+
+// newRestingOrder is the book entry for quantity of order resting, cut into a
+// display slice and a reserve if the order is an iceberg
+func newRestingOrder(order *OrderRequest, quantity decimal.Decimal) BookOrder {
+	resting := BookOrder{
+		OrderID:   order.OrderID,
+		AccountID: order.AccountID,
+		Side:      order.Side,
+		Price:     order.LimitPrice,
+		Quantity:  quantity,
+		PegTo:     order.PegTo,
+		PegOffset: order.PegOffset,
+
+		ReduceOnly: order.ReduceOnly,
+	}
+	if display := order.DisplayQuantity; display.IsPositive() && display.LessThan(quantity) {
+		resting.DisplayQuantity = display
+		resting.Quantity = display
+		resting.Reserve = quantity.Sub(display)
+	}
+	return resting
+}
+
+// BookFill is a single execution against a resting order
+type BookFill struct {
+	RestingOrderID  string          `json:"resting_order_id"`
+	RestingSequence uint64          `json:"resting_sequence"` // arrival order of the resting order
+	Price           decimal.Decimal `json:"price"`
+	Quantity        decimal.Decimal `json:"quantity"`
+	Liquidity       string          `json:"liquidity"`           // liquidityTaker: the incoming order aggressed
+	Timestamp       int64           `json:"timestamp,omitempty"` // unix ms of the match
+
+	restingAccount    string // owner of the resting order, kept off the wire
+	restingReduceOnly bool   // whether the resting order is reduce-only
+}
+
+// Self-trade prevention policies, applied when an incoming order would
+// match a resting order from the same account
+const (
+	stpCancelResting  = "cancel_resting"
+	stpCancelIncoming = "cancel_incoming"
+	stpCancelBoth     = "cancel_both"
+)
+
+// rejectSelfTrade is the reason reported on orders cancelled by STP
+const rejectSelfTrade = "self_trade_prevention"
+
+// rejectWouldTakeLiquidity is the reason for post-only orders that cross
+const rejectWouldTakeLiquidity = "would_take_liquidity"
+
+// MatchResult describes the outcome of matching an incoming order
+type MatchResult struct {
+	Fills             []BookFill
+	CancelledResting  []*BookOrder // resting orders removed by self-trade prevention
+	IncomingCancelled bool         // incoming order stopped by self-trade prevention
+	Remaining         decimal.Decimal
+}
+
+// priceLevel holds the resting orders at one price in arrival order
+type priceLevel struct {
+	price  decimal.Decimal
+	orders []*BookOrder
+}
+
+// OrderBook is a limit order book for a single symbol. It is not safe for
+// concurrent use: whoever reads or changes it holds mu, which is normally the
+// actor of its symbol (see actors.go).
+type OrderBook struct {
+	Symbol string
+
+	mu   sync.Mutex
+	size atomic.Int64 // len(orders), readable without mu
+
+	bids   []*priceLevel // best (highest) first
+	asks   []*priceLevel // best (lowest) first
+	orders map[string]*BookOrder
+	seq    uint64
+
+	// Running totals of displayed quantity and iceberg reserve on both sides
+	displayed decimal.Decimal
+	hidden    decimal.Decimal
+
+	// Book events not yet published and the last one's number; only books
+	// the engine trades on record them (see bookevents.go)
+	recordEvents bool
+	events       []BookEvent
+	eventSeq     uint64
+
+	// The latest published events, for diffs (see bookdiff.go)
+	history []BookEvent
+
+	// Open order counts kept up to date as orders rest and leave; only the
+	// engine's books keep them (see openorders.go)
+	open *openOrderCounts
+}
+
+// NewOrderBook creates an empty book for symbol
+func NewOrderBook(symbol string) *OrderBook {
+	return &OrderBook{
+		Symbol: symbol,
+		orders: make(map[string]*BookOrder),
+	}
+}
+
+// Add rests a new order at the back of its price level, assigning it the
+// next sequence number
+func (b *OrderBook) Add(order BookOrder) *BookOrder {
+	b.seq++
+	order.Sequence = b.seq
+	b.insert(&order)
+	b.record(bookEventAdd, &order, order.Quantity)
+	return &order
+}
+
+// insert places an order into its level without assigning a new sequence
+func (b *OrderBook) insert(order *BookOrder) {
+	levels := b.levels(order.Side)
+	i := sort.Search(len(*levels), func(i int) bool {
+		if order.Side == "buy" {
+			return (*levels)[i].price.LessThanOrEqual(order.Price)
+		}
+		return (*levels)[i].price.GreaterThanOrEqual(order.Price)
+	})
+
+	if i < len(*levels) && (*levels)[i].price.Equal(order.Price) {
+		(*levels)[i].orders = append((*levels)[i].orders, order)
+	} else {
+		*levels = append(*levels, nil)
+		copy((*levels)[i+1:], (*levels)[i:])
+		(*levels)[i] = &priceLevel{price: order.Price, orders: []*BookOrder{order}}
+	}
+	b.orders[order.OrderID] = order
+	b.size.Add(1)
+	b.open.add(order.AccountID, 1)
+	b.displayed = b.displayed.Add(order.Quantity)
+	b.hidden = b.hidden.Add(order.Reserve)
+}
+
+// Cancel removes a resting order from the book
+func (b *OrderBook) Cancel(orderID string) (*BookOrder, bool) {
+	order, ok := b.orders[orderID]
+	if !ok {
+		return nil, false
+	}
+	b.remove(order)
+	b.record(bookEventCancel, order, decimal.Zero)
+	return order, true
+}
+
+// Get returns a resting order by ID
+func (b *OrderBook) Get(orderID string) (*BookOrder, bool) {
+	order, ok := b.orders[orderID]
+	return order, ok
+}
+
+// Len returns the number of resting orders. It is safe without the book's
+// lock.
+func (b *OrderBook) Len() int {
+	return int(b.size.Load())
+}
+
+// BestBid returns the highest resting bid price
+func (b *OrderBook) BestBid() (decimal.Decimal, bool) {
+	if len(b.bids) == 0 {
+		return decimal.Zero, false
+	}
+	return b.bids[0].price, true
+}
+
+// BestAsk returns the lowest resting ask price
+func (b *OrderBook) BestAsk() (decimal.Decimal, bool) {
+	if len(b.asks) == 0 {
+		return decimal.Zero, false
+	}
+	return b.asks[0].price, true
+}
+
+// Match executes an incoming order against the opposite side of the book
+// with strict price-time priority: the best price level is swept first and,
+// within a level, resting orders fill oldest first. An incoming Price of 0
+// means the order takes any price (market order). Fills are returned in the
+// order they occurred, so identical inputs always produce identical fills. When the incoming order
+// would trade with a resting order of the same account, stp decides which
+// side is cancelled.
+func (b *OrderBook) Match(incoming BookOrder, stp string) MatchResult {
+	contra := b.levels(oppositeSide(incoming.Side))
+	result := MatchResult{Remaining: incoming.Quantity}
+
+	for result.Remaining.IsPositive() && len(*contra) > 0 && !result.IncomingCancelled {
+		level := (*contra)[0]
+		if incoming.Price.IsPositive() && !crosses(incoming.Side, incoming.Price, level.price) {
+			break
+		}
+
+		for result.Remaining.IsPositive() && len(level.orders) > 0 {
+			resting := level.orders[0]
+
+			if incoming.AccountID != "" && resting.AccountID == incoming.AccountID {
+				if stp == stpCancelResting || stp == stpCancelBoth {
+					b.remove(resting)
+					b.record(bookEventCancel, resting, decimal.Zero)
+					result.CancelledResting = append(result.CancelledResting, resting)
+				}
+				if stp != stpCancelResting {
+					result.IncomingCancelled = true
+					break
+				}
+				continue
+			}
+
+			qty := decimal.Min(resting.Quantity, result.Remaining)
+			result.Fills = append(result.Fills, BookFill{
+				RestingOrderID:    resting.OrderID,
+				RestingSequence:   resting.Sequence,
+				Price:             level.price,
+				Quantity:          qty,
+				Liquidity:         liquidityTaker,
+				restingAccount:    resting.AccountID,
+				restingReduceOnly: resting.ReduceOnly,
+			})
+			result.Remaining = result.Remaining.Sub(qty)
+			b.reduce(resting, qty)
+		}
+	}
+
+	return result
+}
+
+// reduce takes qty off a resting order, removing it once fully filled. An
+// iceberg whose slice is filled is replenished from its reserve instead.
+func (b *OrderBook) reduce(order *BookOrder, qty decimal.Decimal) {
+	order.Quantity = order.Quantity.Sub(qty)
+	b.displayed = b.displayed.Sub(qty)
+	b.record(bookEventExecute, order, qty)
+	if order.Quantity.IsPositive() {
+		return
+	}
+	b.remove(order)
+	if order.Reserve.IsPositive() {
+		b.replenish(order)
+	}
+}
+
+// replenish displays the next slice of an iceberg's reserve at the back of
+// its price level
+func (b *OrderBook) replenish(order *BookOrder) {
+	slice := decimal.Min(order.DisplayQuantity, order.Reserve)
+	order.Quantity = slice
+	order.Reserve = order.Reserve.Sub(slice)
+	b.seq++
+	order.Sequence = b.seq
+	b.insert(order)
+	b.record(bookEventAdd, order, slice)
+}
+
+// reprice moves a resting order to price, at the back of its new level
+func (b *OrderBook) reprice(order *BookOrder, price decimal.Decimal) {
+	b.remove(order)
+	order.Price = price
+	b.seq++
+	order.Sequence = b.seq
+	b.insert(order)
+	b.record(bookEventModify, order, order.Quantity)
+}
+
+// remove unlinks an order from its price level
+func (b *OrderBook) remove(order *BookOrder) {
+	levels := b.levels(order.Side)
+	for i, level := range *levels {
+		if !level.price.Equal(order.Price) {
+			continue
+		}
+		for j, o := range level.orders {
+			if o == order {
+				level.orders = append(level.orders[:j], level.orders[j+1:]...)
+				break
+			}
+		}
+		if len(level.orders) == 0 {
+			*levels = append((*levels)[:i], (*levels)[i+1:]...)
+		}
+		break
+	}
+	if _, ok := b.orders[order.OrderID]; ok {
+		delete(b.orders, order.OrderID)
+		b.size.Add(-1)
+		b.open.add(order.AccountID, -1)
+		b.displayed = b.displayed.Sub(order.Quantity)
+		b.hidden = b.hidden.Sub(order.Reserve)
+	}
+}
+
+// wouldCross reports whether an order at price would trade on arrival
+func (b *OrderBook) wouldCross(side string, price decimal.Decimal) bool {
+	contra := *b.levels(oppositeSide(side))
+	return len(contra) > 0 && crosses(side, price, contra[0].price)
+}
+
+// hasLiquidity reports whether anything rests on the side an order of the
+// given side would trade against
+func (b *OrderBook) hasLiquidity(side string) bool {
+	return len(*b.levels(oppositeSide(side))) > 0
+}
+
+func (b *OrderBook) levels(side string) *[]*priceLevel {
+	if side == "buy" {
+		return &b.bids
+	}
+	return &b.asks
+}
+
+func oppositeSide(side string) string {
+	if side == "buy" {
+		return "sell"
+	}
+	return "buy"
+}
+
+// crosses reports whether an order at limitPrice can trade at price
+func crosses(side string, limitPrice decimal.Decimal, price decimal.Decimal) bool {
+	if side == "buy" {
+		return limitPrice.GreaterThanOrEqual(price)
+	}
+	return limitPrice.LessThanOrEqual(price)
+}
+
+// bookFor returns the book for symbol, creating it on first use. The book
+// isn't locked.
+func (e *ExecutionEngine) bookFor(symbol string) *OrderBook {
+	if book, ok := e.lookupBook(symbol); ok {
+		return book
+	}
+	e.booksMu.Lock()
+	defer e.booksMu.Unlock()
+	if e.books == nil {
+		e.books = make(map[string]*OrderBook)
+	}
+	book, ok := e.books[symbol]
+	if !ok {
+		book = NewOrderBook(symbol)
+		book.recordEvents = true
+		book.open = &e.openOrders
+		e.books[symbol] = book
+	}
+	return book
+}
+
+// lookupBook returns the book for symbol if there is one. The book isn't
+// locked.
+func (e *ExecutionEngine) lookupBook(symbol string) (*OrderBook, bool) {
+	e.booksMu.RLock()
+	defer e.booksMu.RUnlock()
+	book, ok := e.books[symbol]
+	return book, ok
+}
+
+// allBooks returns every book, unlocked
+func (e *ExecutionEngine) allBooks() []*OrderBook {
+	e.booksMu.RLock()
+	defer e.booksMu.RUnlock()
+	books := make([]*OrderBook, 0, len(e.books))
+	for _, book := range e.books {
+		books = append(books, book)
+	}
+	return books
+}
+
+// lockBooks locks every book, in symbol order, for a view consistent across
+// all of them, and returns the unlock. No book is created until then, so the
+// caller may range over e.books.
+func (e *ExecutionEngine) lockBooks() func() {
+	for {
+		books := e.allBooks()
+		sort.Slice(books, func(i, j int) bool { return books[i].Symbol < books[j].Symbol })
+		for _, book := range books {
+			book.mu.Lock()
+		}
+		unlockBooks := func() {
+			for _, book := range books {
+				book.mu.Unlock()
+			}
+		}
+
+		// booksMu only after the book locks: their holders take it only
+		// briefly, so it can't deadlock. A book created meanwhile means
+		// starting over.
+		e.booksMu.Lock()
+		if len(e.books) == len(books) {
+			return func() {
+				e.booksMu.Unlock()
+				unlockBooks()
+			}
+		}
+		e.booksMu.Unlock()
+		unlockBooks()
+	}
+}
+
+// lockBooksOf locks the books of symbols, created on first use, in symbol
+// order, and returns the unlock
+func (e *ExecutionEngine) lockBooksOf(symbols []string) func() {
+	sorted := append([]string(nil), symbols...)
+	sort.Strings(sorted)
+	var books []*OrderBook
+	for i, symbol := range sorted {
+		if i > 0 && symbol == sorted[i-1] {
+			continue
+		}
+		book := e.bookFor(symbol)
+		book.mu.Lock()
+		books = append(books, book)
+	}
+	return func() {
+		for _, book := range books {
+			book.mu.Unlock()
+		}
+	}
+}
+
+// bookHasLiquidity reports whether an order could trade against the book
+func (e *ExecutionEngine) bookHasLiquidity(order *OrderRequest) bool {
+	book, ok := e.lookupBook(order.Symbol)
+	if !ok {
+		return false
+	}
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	return book.hasLiquidity(order.Side)
+}
+
+// matchOrder executes an order against its symbol's book. Any quantity a
+// limit order can't fill immediately rests in the book; the unfilled part of
+// a market order is cancelled. Only that book is locked, so orders in other
+// symbols match at the same time. It returns nil, having changed nothing, if
+// the order timed out before it got the book.
+func (e *ExecutionEngine) matchOrder(ctx context.Context, order *OrderRequest) *OrderResponse {
+	book := e.bookFor(order.Symbol)
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	if !commitExecution(ctx) {
+		return nil
+	}
+
+	if order.PegTo != "" && !pegOrder(book, order) {
+		return e.bookRejection(order, &rejection{Reason: rejectNoPegReference})
+	}
+	if order.Notional.IsPositive() {
+		e.sizeNotional(book, order)
+		if !order.Quantity.IsPositive() {
+			return e.bookRejection(order, &rejection{Reason: rejectNotionalTooSmall})
+		}
+	}
+	incoming := BookOrder{
+		OrderID:   order.OrderID,
+		AccountID: order.AccountID,
+		Side:      order.Side,
+		Quantity:  order.Quantity,
+	}
+	if order.Type == "limit" {
+		incoming.Price = order.LimitPrice
+	}
+
+	// Market orders only sweep as far as their collar
+	collar, collared := e.collarPrice(order)
+	if collared {
+		incoming.Price = collar
+	}
+
+	// Reject outright rather than partially fill below the minimum ratio
+	if ratio := e.minFillRatio(order); ratio > 0 {
+		required := decimal.NewFromFloat(ratio).Mul(order.Quantity)
+		if available := book.Available(incoming.Side, incoming.Price, incoming.AccountID); available.LessThan(required) {
+			log.Printf("Order %s rejected: %s (%s available, %s required)", order.OrderID, rejectInsufficientLiquidity, available, required)
+			return e.bookRejection(order, &rejection{
+				Reason: rejectInsufficientLiquidity,
+				Limit:  &RiskLimit{Name: riskLimitMinFillQuantity, Limit: required, Current: available},
+			})
+		}
+	}
+
+	// Post-only orders may only ever add liquidity
+	if order.PostOnly && book.wouldCross(incoming.Side, incoming.Price) {
+		log.Printf("Order %s rejected: %s", order.OrderID, rejectWouldTakeLiquidity)
+		return e.bookRejection(order, &rejection{Reason: rejectWouldTakeLiquidity})
+	}
+
+	matchStart := latencyStart()
+	result := book.Match(incoming, e.config.STPPolicy)
+	var filled, notional decimal.Decimal
+	for _, fill := range result.Fills {
+		filled = filled.Add(fill.Quantity)
+		notional = notional.Add(fill.Price.Mul(fill.Quantity))
+	}
+	e.observeMatchLatency(matchStart)
+
+	for _, cancelled := range result.CancelledResting {
+		e.journal(book, bookMutation{Op: journalOpCancel, Symbol: order.Symbol, OrderID: cancelled.OrderID})
+		e.updateCachedResponse(cancelled.OrderID, statusCancelled, func(r *OrderResponse) {
+			r.RejectReason = rejectSelfTrade
+		})
+	}
+
+	matchedAt := e.now().UnixMilli()
+	for i, fill := range result.Fills {
+		result.Fills[i].Timestamp = matchedAt
+		e.journal(book, bookMutation{Op: journalOpFill, Symbol: order.Symbol, OrderID: fill.RestingOrderID, Quantity: fill.Quantity})
+		e.applyRestingFill(book, fill, oppositeSide(order.Side))
+	}
+
+	response := &OrderResponse{
+		OrderID:        order.OrderID,
+		ClientOrderID:  order.IdempotencyKey,
+		Symbol:         order.Symbol,
+		Status:         statusAccepted,
+		FilledQuantity: filled,
+	}
+	if filled.IsPositive() {
+		response.FilledAvgPrice = notional.Div(filled)
+		response.Fills = result.Fills
+		e.prices.record(order.Symbol, result.Fills[len(result.Fills)-1].Price)
+		e.prices.recordVolume(order.Symbol, filled)
+	}
+
+	// The order moves on from accepted to wherever matching left it
+	outcome := statusFilled
+	switch {
+	case result.IncomingCancelled:
+		outcome = statusCancelled
+		response.RejectReason = rejectSelfTrade
+	case collared && result.Remaining.IsPositive():
+		rej := &rejection{Reason: rejectPriceCollar, Detail: fmt.Sprintf("no liquidity within the collar at %s for %s", collar, result.Remaining)}
+		log.Printf("Order %s stopped at its collar: %s", order.OrderID, rej.Detail)
+		if filled.IsZero() {
+			return e.bookRejection(order, rej)
+		}
+		e.recordRejection(rejectPriceCollar)
+		e.auditRejection(order, rej)
+		outcome = statusCancelled
+		response.RejectReason = rejectPriceCollar
+	case result.Remaining.IsPositive():
+		outcome = statusPartiallyFilled
+		if order.Type == "limit" && !e.makeRoom(book) {
+			log.Printf("Order %s not rested: %s", order.OrderID, rejectBookFull)
+			if filled.IsZero() {
+				return e.bookRejection(order, &rejection{Reason: rejectBookFull})
+			}
+			e.recordRejection(rejectBookFull)
+			e.auditRejection(order, &rejection{Reason: rejectBookFull, Detail: "unfilled remainder " + result.Remaining.String() + " dropped"})
+			response.RejectReason = rejectBookFull
+		} else if order.Type == "limit" {
+			resting := book.Add(newRestingOrder(order, result.Remaining))
+			resting.RestedAt = e.now().UnixMilli()
+			if resting.PegTo != "" {
+				resting.peggedAt = e.now()
+			}
+			e.journal(book, bookMutation{Op: journalOpAdd, Symbol: order.Symbol, Order: resting})
+			if filled.IsZero() {
+				outcome = statusWorking
+			}
+		}
+	}
+	response.transition(outcome)
+
+	// The book moved, so pegs may have to follow
+	e.repeg(book)
+
+	return response
+}
+
+// bookRejection records and returns the rejection of an order the book
+// refused before matching
+func (e *ExecutionEngine) bookRejection(order *OrderRequest, rej *rejection) *OrderResponse {
+	e.recordRejection(rej.Reason)
+	e.auditRejection(order, rej)
+	return &OrderResponse{
+		OrderID:       order.OrderID,
+		ClientOrderID: order.IdempotencyKey,
+		Symbol:        order.Symbol,
+		Status:        statusRejected,
+		RejectReason:  rej.Reason,
+		RiskLimit:     rej.Limit,
+	}
+}
+
+// applyRestingFill updates the cached response and position of a resting
+// order that was hit by an incoming order, charging it as a maker and
+// flagging the update as a maker fill, and publishes the update to its owner
+func (e *ExecutionEngine) applyRestingFill(book *OrderBook, fill BookFill, side string) {
+	_, stillResting := book.Get(fill.RestingOrderID)
+	charge := e.chargeFill(fill.Quantity, fill.Price, liquidityMaker)
+	apply := e.positions.Apply
+	if fill.restingReduceOnly {
+		apply = e.positions.ApplyReduceOnly
+	}
+	apply(fill.restingAccount, book.Symbol, side, fill.Quantity, fill.Price, charge.Total())
+	e.recordNotional(fill.restingAccount, fill.Price.Mul(fill.Quantity))
+	e.recordOCOFill(fill.RestingOrderID, fill.Quantity)
+	state := statusFilled
+	if stillResting {
+		state = statusPartiallyFilled
+	}
+	e.updateCachedResponse(fill.RestingOrderID, state, func(r *OrderResponse) {
+		notional := r.FilledAvgPrice.Mul(r.FilledQuantity).Add(fill.Price.Mul(fill.Quantity))
+		r.FilledQuantity = r.FilledQuantity.Add(fill.Quantity)
+		r.FilledAvgPrice = notional.Div(r.FilledQuantity)
+		r.addCharge(charge)
+		r.LiquidityFlag = liquidityMaker
+	})
+}
+
+// updateCachedResponse moves a copy of an order's cached response to state to,
+// applies update to it, stores it and publishes it. The copy keeps readers of
+// the old value safe. Illegal transitions leave the order untouched. An order
+// evicted from the cache is updated from the store.
+func (e *ExecutionEngine) updateCachedResponse(orderID string, to OrderState, update func(*OrderResponse)) {
+	current, ok := e.GetOrder(orderID)
+	if !ok {
+		return
+	}
+	updated := *current
+	if err := updated.transition(to); err != nil {
+		return
+	}
+	update(&updated)
+	e.orderCache.Store(orderID, &updated)
+	e.updateStoredOrder(&updated)
+	e.auditState("", &updated)
+	e.publishResponse(&updated)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMatchFillsSameLevelInArrivalOrder(t *testing.T) {
+	engine, _ := newTestEngine(t)
+
+	// Three sells at one price, arriving first, second, third
+	engine.executeOrder(context.Background(), limitOrder("s-first", "AAPL", "sell", 100, 5))
+	engine.executeOrder(context.Background(), limitOrder("s-second", "AAPL", "sell", 100, 5))
+	engine.executeOrder(context.Background(), limitOrder("s-third", "AAPL", "sell", 100, 5))
+
+	resp := engine.executeOrder(context.Background(), limitOrder("buy", "AAPL", "buy", 100, 12))
+
+	want := []BookFill{
+		{RestingOrderID: "s-first", RestingSequence: 1, Price: dec(100), Quantity: dec(5)},
+		{RestingOrderID: "s-second", RestingSequence: 2, Price: dec(100), Quantity: dec(5)},
+		{RestingOrderID: "s-third", RestingSequence: 3, Price: dec(100), Quantity: dec(2)},
+	}
+	if !fillsEqual(resp.Fills, want) {
+		t.Fatalf("fills out of arrival order\n got: %+v\nwant: %+v", resp.Fills, want)
+	}
+	if resp.Status != "filled" || !resp.FilledQuantity.Equal(dec(12)) {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	// The partially filled order keeps its place at the front of the queue
+	engine.executeOrder(context.Background(), limitOrder("s-fourth", "AAPL", "sell", 100, 5))
+	resp = engine.executeOrder(context.Background(), limitOrder("buy-2", "AAPL", "buy", 100, 4))
+	if len(resp.Fills) != 2 || resp.Fills[0].RestingOrderID != "s-third" || resp.Fills[1].RestingOrderID != "s-fourth" {
+		t.Fatalf("partially filled order lost queue priority: %+v", resp.Fills)
+	}
+}
+
+func TestMatchSweepsBestPriceFirst(t *testing.T) {
+	engine, _ := newTestEngine(t)
+
+	// Rest asks out of price order; the oldest order is the worst price
+	engine.executeOrder(context.Background(), limitOrder("a-102", "AAPL", "sell", 102, 1))
+	engine.executeOrder(context.Background(), limitOrder("a-100", "AAPL", "sell", 100, 1))
+	engine.executeOrder(context.Background(), limitOrder("a-101", "AAPL", "sell", 101, 1))
+	engine.executeOrder(context.Background(), limitOrder("a-103", "AAPL", "sell", 103, 1))
+
+	resp := engine.executeOrder(context.Background(), limitOrder("sweep", "AAPL", "buy", 102, 5))
+
+	var got []string
+	for _, f := range resp.Fills {
+		got = append(got, f.RestingOrderID)
+	}
+	if want := []string{"a-100", "a-101", "a-102"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sweep order = %v, want %v", got, want)
+	}
+	if !resp.FilledAvgPrice.Equal(dec(101)) || resp.Status != "partially_filled" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	// The unfilled remainder rests as the new best bid; 103 is untouched
+	book := engine.bookFor("AAPL")
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	if bid, _ := book.BestBid(); !bid.Equal(dec(102)) {
+		t.Errorf("best bid = %v, want 102", bid)
+	}
+	if ask, _ := book.BestAsk(); !ask.Equal(dec(103)) {
+		t.Errorf("best ask = %v, want 103", ask)
+	}
+}
+
+// fillsEqual compares fills by value, since equal decimals may differ in
+// representation
+func fillsEqual(a, b []BookFill) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].RestingOrderID != b[i].RestingOrderID || a[i].RestingSequence != b[i].RestingSequence ||
+			!a[i].Price.Equal(b[i].Price) || !a[i].Quantity.Equal(b[i].Quantity) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPostOnly(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	submitToEngine(t, engine, limitOrder("ask", "AAPL", "sell", 100, 10))
+
+	crossing := limitOrder("po-cross", "AAPL", "buy", 100, 5)
+	crossing.PostOnly = true
+	submitToEngine(t, engine, crossing)
+
+	resting := limitOrder("po-rest", "AAPL", "buy", 99.5, 5)
+	resting.PostOnly = true
+	submitToEngine(t, engine, resting)
+
+	if r, _ := engine.GetOrder("po-cross"); r.Status != "rejected" || r.RejectReason != rejectWouldTakeLiquidity {
+		t.Errorf("crossing post-only order: got %+v", r)
+	}
+	if r, _ := engine.GetOrder("po-rest"); r.Status != "working" || !inBook(engine, "AAPL", "po-rest") {
+		t.Errorf("non-crossing post-only order should rest, got %+v", r)
+	}
+	if r, _ := engine.GetOrder("ask"); r.Status != "working" || !r.FilledQuantity.IsZero() {
+		t.Errorf("resting ask was traded against: %+v", r)
+	}
+}
+
+func TestCrossingOrderFlagsTakerAndMakerFills(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	sink := newMockSink("events", 0)
+	engine.fills = newFillDispatcher(context.Background(), []FillSink{sink}, newFillSinkMetrics(prometheus.NewRegistry()))
+
+	submitToEngine(t, engine, limitOrder("maker-1", "AAPL", "sell", 100, 10))
+	submitToEngine(t, engine, limitOrder("taker-1", "AAPL", "buy", 100, 4))
+	engine.fills.close()
+
+	taker, _ := engine.GetOrder("taker-1")
+	if taker.LiquidityFlag != liquidityTaker || len(taker.Fills) != 1 || taker.Fills[0].Liquidity != liquidityTaker {
+		t.Fatalf("aggressing order should get a taker fill, got %+v", taker)
+	}
+	if taker.Fills[0].RestingOrderID != "maker-1" {
+		t.Errorf("taker fill attributed to %s, want maker-1", taker.Fills[0].RestingOrderID)
+	}
+	maker, _ := engine.GetOrder("maker-1")
+	if maker.LiquidityFlag != liquidityMaker || !maker.FilledQuantity.Equal(dec(4)) {
+		t.Fatalf("resting order should get a maker fill, got %+v", maker)
+	}
+
+	// The fill events carry the same flags: nothing for the new resting
+	// order, then the maker fill, then the taker fill
+	var flags []string
+	for _, r := range sink.delivered {
+		flags = append(flags, r.OrderID+"/"+r.LiquidityFlag)
+	}
+	if want := []string{"maker-1/", "maker-1/maker", "taker-1/taker"}; !reflect.DeepEqual(flags, want) {
+		t.Errorf("fill events = %v, want %v", flags, want)
+	}
+}
+
+// icebergOrder is a limit order showing display of its quantity
+func icebergOrder(id string, side string, price float64, qty float64, display float64) *OrderRequest {
+	order := limitOrder(id, "AAPL", side, price, qty)
+	order.DisplayQuantity = dec(display)
+	return order
+}
+
+func TestIcebergNeverShowsMoreThanItsDisplaySlice(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.executeOrder(context.Background(), icebergOrder("ice", "sell", 100, 50, 10))
+
+	visible := func() BookFeatures {
+		features, _ := engine.bookFeatures("AAPL")
+		return features
+	}
+	if size := visible().AskSize; !size.Equal(dec(10)) {
+		t.Fatalf("iceberg displays %s, want its 10 slice", size)
+	}
+
+	// Take it down in lots that straddle slice boundaries
+	var filled float64
+	for i := 0; i < 7; i++ {
+		resp := engine.executeOrder(context.Background(), limitOrder(fmt.Sprintf("take-%d", i), "AAPL", "buy", 100, 7))
+		filled += resp.FilledQuantity.InexactFloat64()
+		if size := visible().AskSize; size.GreaterThan(dec(10)) {
+			t.Fatalf("after %v filled the iceberg displays %s, more than its slice", filled, size)
+		}
+	}
+	if filled != 49 {
+		t.Fatalf("filled %v against the iceberg, want 49", filled)
+	}
+	if size := visible().AskSize; !size.Equal(dec(1)) {
+		t.Errorf("last slice displays %s, want the 1 left", size)
+	}
+}
+
+func TestReplenishedIcebergSliceLosesPriority(t *testing.T) {
+	engine, mr := newTestEngine(t)
+	engine.executeOrder(context.Background(), icebergOrder("ice", "sell", 100, 30, 10))
+	engine.executeOrder(context.Background(), limitOrder("plain", "AAPL", "sell", 100, 5))
+
+	// The first slice fills ahead of the later plain order; the next slice
+	// then queues behind it
+	resp := engine.executeOrder(context.Background(), limitOrder("take-1", "AAPL", "buy", 100, 10))
+	if len(resp.Fills) != 1 || resp.Fills[0].RestingOrderID != "ice" {
+		t.Fatalf("first slice should fill first, got %+v", resp.Fills)
+	}
+	resp = engine.executeOrder(context.Background(), limitOrder("take-2", "AAPL", "buy", 100, 8))
+	if len(resp.Fills) != 2 || resp.Fills[0].RestingOrderID != "plain" || resp.Fills[1].RestingOrderID != "ice" || !resp.Fills[1].Quantity.Equal(dec(3)) {
+		t.Fatalf("replenished slice kept its priority: %+v", resp.Fills)
+	}
+	if resp.Fills[1].RestingSequence <= 2 {
+		t.Errorf("replenished slice has sequence %d, want a new one", resp.Fills[1].RestingSequence)
+	}
+
+	// A slice filled within one sweep rejoins the level and can be hit again
+	resp = engine.executeOrder(context.Background(), limitOrder("take-3", "AAPL", "buy", 100, 12))
+	if !resp.FilledQuantity.Equal(dec(12)) || len(resp.Fills) != 2 {
+		t.Fatalf("sweep through a replenishment: %+v", resp)
+	}
+	book := engine.bookFor("AAPL")
+	book.mu.Lock()
+	ice, ok := book.Get("ice")
+	book.mu.Unlock()
+	if !ok || !ice.Quantity.Equal(dec(5)) || !ice.Reserve.IsZero() {
+		t.Errorf("iceberg left as %+v, want 5 displayed and no reserve", ice)
+	}
+
+	// Replaying the journal rebuilds the same slices and reserve
+	restored := NewExecutionEngine(mr.Host(), mr.Port(), "test-stream")
+	defer restored.redisClient.Close()
+	if err := restored.RecoverBooks(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := snapshotOf(restored), snapshotOf(engine); !sameBooks(got, want) {
+		t.Fatalf("recovered iceberg differs\n got: %+v\nwant: %+v", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+// ==============================================================================
+// Order cache - the most recently used order responses, in memory
+// ==============================================================================
+// GetOrder and the book's order updates read responses from an in-memory
+// cache in front of the Redis order store. Every response cached is also
+// written to the store, so the cache only has to bound what it keeps: at
+// most ORDER_CACHE_SIZE orders (0: no bound), evicting the least recently
+// read or written when full. An evicted order is still found by GetOrder,
+// from the store, and goes back into the cache when it next changes.
+//
+// Evictions are counted in order_cache_evictions_total and the cache's size
+// is exported as order_cache_entries.
+// ==============================================================================
+
+package main
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// orderCache is an LRU of order responses by order ID. The zero value is
+// an unbounded, empty cache.
+type orderCache struct {
+	mu        sync.Mutex
+	max       int // 0: unbounded
+	entries   map[string]*list.Element
+	recency   list.List // front: most recently used
+	evictions prometheus.Counter
+}
+
+// cachedOrder is an element of the recency list
+type cachedOrder struct {
+	orderID  string
+	response *OrderResponse
+}
+
+// bound limits the cache to max orders, exporting its metrics on registry
+func (c *orderCache) bound(max int, registry *prometheus.Registry) {
+	c.max = max
+	c.evictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "order_cache_evictions_total",
+		Help: "Orders evicted from the in-memory order cache",
+	})
+	registry.MustRegister(c.evictions)
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "order_cache_entries",
+		Help: "Orders held in the in-memory order cache",
+	}, func() float64 { return float64(c.Len()) }))
+}
+
+// Load returns the cached response of an order, marking it recently used
+func (c *orderCache) Load(orderID string) (*OrderResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[orderID]
+	if !ok {
+		return nil, false
+	}
+	c.recency.MoveToFront(elem)
+	return elem.Value.(*cachedOrder).response, true
+}
+
+// Store caches the response of an order, evicting the least recently used
+// order if the cache is full
+func (c *orderCache) Store(orderID string, response *OrderResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(orderID, response)
+}
+
+// LoadOrStore returns the cached response of an order if there is one, and
+// otherwise caches response. It reports whether the response was loaded.
+func (c *orderCache) LoadOrStore(orderID string, response *OrderResponse) (*OrderResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[orderID]; ok {
+		c.recency.MoveToFront(elem)
+		return elem.Value.(*cachedOrder).response, true
+	}
+	c.store(orderID, response)
+	return response, false
+}
+
+// Len returns the number of cached orders
+func (c *orderCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// store caches a response. Callers must hold mu.
+func (c *orderCache) store(orderID string, response *OrderResponse) {
+	if elem, ok := c.entries[orderID]; ok {
+		elem.Value.(*cachedOrder).response = response
+		c.recency.MoveToFront(elem)
+		return
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+	c.entries[orderID] = c.recency.PushFront(&cachedOrder{orderID: orderID, response: response})
+	for c.max > 0 && len(c.entries) > c.max {
+		oldest := c.recency.Back()
+		c.recency.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedOrder).orderID)
+		if c.evictions != nil {
+			c.evictions.Inc()
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestOrderCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	var cache orderCache
+	cache.max = 2
+	cache.Store("a", &OrderResponse{OrderID: "a"})
+	cache.Store("b", &OrderResponse{OrderID: "b"})
+	cache.Load("a") // b is now the least recently used
+	cache.Store("c", &OrderResponse{OrderID: "c"})
+
+	if _, ok := cache.Load("b"); ok {
+		t.Error("least recently used order b still cached")
+	}
+	for _, id := range []string{"a", "c"} {
+		if _, ok := cache.Load(id); !ok {
+			t.Errorf("order %s evicted", id)
+		}
+	}
+	if cache.Len() != 2 {
+		t.Errorf("cache holds %d orders, want 2", cache.Len())
+	}
+}
+
+func TestEvictedOrdersAreReadFromTheStore(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.orderCache.max = 3
+
+	for i := 1; i <= 5; i++ {
+		submitToEngine(t, engine, limitOrder(fmt.Sprintf("lru-%d", i), "AAPL", "buy", 100, 10))
+	}
+	if n := engine.orderCache.Len(); n != 3 {
+		t.Fatalf("cache holds %d orders, want 3", n)
+	}
+	if _, ok := engine.orderCache.Load("lru-1"); ok {
+		t.Fatal("oldest order still cached past the bound")
+	}
+
+	response, ok := engine.GetOrder("lru-1")
+	if !ok || response.OrderID != "lru-1" || response.Status != statusWorking {
+		t.Fatalf("evicted order: got %+v, %v; want it working, from the store", response, ok)
+	}
+
+	// A fill of the evicted resting order still updates it
+	submitToEngine(t, engine, &OrderRequest{OrderID: "lru-sell", Symbol: "AAPL", Side: "sell", Quantity: dec(10), Type: "market", TimeInForce: "day"})
+	response, ok = engine.GetOrder("lru-1")
+	if !ok || response.Status != statusFilled {
+		t.Errorf("evicted order after its fill: got %+v, want filled", response)
+	}
+}
@@ -0,0 +1,111 @@
+// ==============================================================================
+// Order IDs - time-ordered IDs generated by the engine
+// ==============================================================================
+// An order submitted without an order_id is given one by the engine taking
+// it, before it is queued, so the ID is what the order cache, the response
+// stream and the acknowledgement all know it by. An ID packs three fields
+// into 63 bits, Snowflake style:
+//
+//   41 bits  milliseconds since 2024-01-01 UTC (good until 2093)
+//   10 bits  the instance (INSTANCE_ID, 0-1023) that generated it
+//   12 bits  a counter, 4096 IDs per instance per millisecond
+//
+// and is written as 13 Crockford base32 characters, so IDs sort as strings
+// in the order they were generated. Replicas with distinct INSTANCE_IDs
+// never collide, without coordinating. Within an instance IDs only ever
+// increase: if the clock steps back, or the counter runs out within a
+// millisecond, the generator carries on from the last timestamp it used.
+// DecodeOrderID recovers the fields, e.g. to route an order to the instance
+// that owns it.
+// ==============================================================================
+
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	orderIDTimeBits     = 41
+	orderIDInstanceBits = 10
+	orderIDCounterBits  = 12
+	orderIDLength       = 13
+
+	maxInstanceID   = 1<<orderIDInstanceBits - 1
+	maxIDCounter    = 1<<orderIDCounterBits - 1
+	orderIDAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+// orderIDEpoch is the zero of generated IDs' timestamps
+var orderIDEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// orderIDGenerator generates the order IDs of one instance
+type orderIDGenerator struct {
+	instance int64
+	now      func() time.Time
+
+	mu      sync.Mutex
+	last    int64 // ms since orderIDEpoch of the last ID
+	counter int64
+}
+
+func newOrderIDGenerator(instance int, now func() time.Time) *orderIDGenerator {
+	return &orderIDGenerator{instance: int64(instance), now: now, last: -1}
+}
+
+// next returns a new ID, greater than every ID generated before it
+func (g *orderIDGenerator) next() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ms := max(g.now().Sub(orderIDEpoch).Milliseconds(), 0)
+	switch {
+	case ms > g.last:
+		g.last, g.counter = ms, 0
+	case g.counter < maxIDCounter:
+		g.counter++
+	default:
+		// Out of IDs for this millisecond: borrow the next one
+		g.last, g.counter = g.last+1, 0
+	}
+	return encodeOrderID(g.last<<(orderIDInstanceBits+orderIDCounterBits) | g.instance<<orderIDCounterBits | g.counter)
+}
+
+// assignOrderID gives an order without an order_id a generated one
+func (e *ExecutionEngine) assignOrderID(order *OrderRequest) {
+	if order.OrderID == "" {
+		order.OrderID = e.orderIDs.next()
+	}
+}
+
+// encodeOrderID writes an ID's bits in fixed-width base32
+func encodeOrderID(v int64) string {
+	var b [orderIDLength]byte
+	for i := orderIDLength - 1; i >= 0; i-- {
+		b[i] = orderIDAlphabet[v&31]
+		v >>= 5
+	}
+	return string(b[:])
+}
+
+// DecodeOrderID splits an engine-generated order ID into the time it was
+// generated, the instance that generated it and its counter. It reports
+// false for IDs the engine didn't generate.
+func DecodeOrderID(id string) (generated time.Time, instance int, counter int, ok bool) {
+	if len(id) != orderIDLength {
+		return time.Time{}, 0, 0, false
+	}
+	var v uint64
+	for i := 0; i < len(id); i++ {
+		digit := strings.IndexByte(orderIDAlphabet, id[i])
+		if digit < 0 || v>>(orderIDTimeBits+orderIDInstanceBits+orderIDCounterBits-5) != 0 {
+			return time.Time{}, 0, 0, false
+		}
+		v = v<<5 | uint64(digit)
+	}
+	ms := int64(v >> (orderIDInstanceBits + orderIDCounterBits))
+	instance = int(v >> orderIDCounterBits & maxInstanceID)
+	counter = int(v & maxIDCounter)
+	return orderIDEpoch.Add(time.Duration(ms) * time.Millisecond), instance, counter, true
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrderIDsIncreaseWithinAnInstance(t *testing.T) {
+	clock := newFakeClock()
+	clock.advance(365 * 24 * time.Hour)
+	gen := newOrderIDGenerator(7, clock.Now)
+
+	var ids []string
+	for i := 0; i < 10000; i++ { // overruns the per-millisecond counter
+		ids = append(ids, gen.next())
+		if i == 5000 {
+			clock.advance(-time.Second) // clock stepping back
+		}
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("ID %d %s doesn't sort after %s", i, ids[i], ids[i-1])
+		}
+	}
+}
+
+func TestOrderIDsAreUniqueAcrossConcurrentInstances(t *testing.T) {
+	now := time.Now
+	gens := []*orderIDGenerator{newOrderIDGenerator(0, now), newOrderIDGenerator(1, now), newOrderIDGenerator(maxInstanceID, now)}
+
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	var wg sync.WaitGroup
+	for _, gen := range gens {
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func(gen *orderIDGenerator) {
+				defer wg.Done()
+				ids := make([]string, 2000)
+				for i := range ids {
+					ids[i] = gen.next()
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				for _, id := range ids {
+					if seen[id] {
+						t.Errorf("duplicate ID %s", id)
+					}
+					seen[id] = true
+				}
+			}(gen)
+		}
+	}
+	wg.Wait()
+	if len(seen) != len(gens)*4*2000 {
+		t.Errorf("got %d distinct IDs, want %d", len(seen), len(gens)*4*2000)
+	}
+}
+
+func TestOrderIDsDecodeToTheirInstance(t *testing.T) {
+	at := time.Date(2025, 6, 1, 12, 0, 0, 123e6, time.UTC)
+	for _, instance := range []int{0, 1, 513, maxInstanceID} {
+		gen := newOrderIDGenerator(instance, func() time.Time { return at })
+		gen.next()
+		id := gen.next()
+
+		generated, gotInstance, counter, ok := DecodeOrderID(id)
+		if !ok || gotInstance != instance || counter != 1 || !generated.Equal(at) {
+			t.Errorf("DecodeOrderID(%s) = %v, %d, %d, %v; want %v, %d, 1", id, generated, gotInstance, counter, ok, at, instance)
+		}
+	}
+
+	for _, id := range []string{"", "order-1", "ZZZZZZZZZZZZZ", "0000000000001U"} {
+		if _, _, _, ok := DecodeOrderID(id); ok {
+			t.Errorf("DecodeOrderID(%q) succeeded for an ID the engine didn't generate", id)
+		}
+	}
+}
+
+func TestSubmitOrderAssignsMissingIDs(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.orderIDs = newOrderIDGenerator(3, engine.now)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		order := testOrder("")
+		if err := engine.SubmitOrder(context.Background(), &order); err != nil {
+			t.Fatal(err)
+		}
+		if _, instance, _, ok := DecodeOrderID(order.OrderID); !ok || instance != 3 {
+			t.Errorf("order given ID %q, want one generated by instance 3", order.OrderID)
+		}
+		ids = append(ids, order.OrderID)
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("generated IDs %v out of order", ids)
+	}
+
+	order := testOrder("client-id")
+	engine.SubmitOrder(context.Background(), &order)
+	if order.OrderID != "client-id" {
+		t.Errorf("client's order ID replaced with %s", order.OrderID)
+	}
+}
@@ -0,0 +1,99 @@
+// ==============================================================================
+// Order size metrics - distributions of order quantity and notional
+// ==============================================================================
+// Every order that passes validation and the risk checks and is routed for
+// execution is observed in two histograms labeled by side:
+//
+//   order_quantity  the order's quantity (for notional orders, the notional
+//                   over the price)
+//   order_notional  quantity times price: the limit price, or the symbol's
+//                   reference price for market orders
+//
+// A fat-fingered order shows up in the top buckets, and the distributions
+// over time show how order sizes trend. The buckets default to powers of ten
+// and can be set with ORDER_QUANTITY_BUCKETS and ORDER_NOTIONAL_BUCKETS,
+// each a comma-separated list of increasing upper bounds.
+// ==============================================================================
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/shopspring/decimal"
+)
+
+// Default histogram buckets: quantities from 1 to 10M, notionals from 100 to
+// 1B
+var (
+	defaultQuantityBuckets = prometheus.ExponentialBuckets(1, 10, 8)
+	defaultNotionalBuckets = prometheus.ExponentialBuckets(100, 10, 8)
+)
+
+// parseBuckets parses a comma-separated list of increasing bucket bounds,
+// returning fallback for an empty list
+func parseBuckets(raw string, fallback []float64) ([]float64, error) {
+	if strings.TrimSpace(raw) == "" {
+		return fallback, nil
+	}
+	var buckets []float64
+	for _, field := range strings.Split(raw, ",") {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %q", field)
+		}
+		if len(buckets) > 0 && bound <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("buckets must increase, %v follows %v", bound, buckets[len(buckets)-1])
+		}
+		buckets = append(buckets, bound)
+	}
+	return buckets, nil
+}
+
+// orderSizeMetrics holds the order size histograms
+type orderSizeMetrics struct {
+	quantity *prometheus.HistogramVec
+	notional *prometheus.HistogramVec
+}
+
+func newOrderSizeMetrics(registry *prometheus.Registry, quantityBuckets, notionalBuckets []float64) *orderSizeMetrics {
+	m := &orderSizeMetrics{
+		quantity: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "order_quantity",
+			Help:    "Quantity of orders routed for execution",
+			Buckets: quantityBuckets,
+		}, []string{"side"}),
+		notional: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "order_notional",
+			Help:    "Notional value (quantity times price) of orders routed for execution",
+			Buckets: notionalBuckets,
+		}, []string{"side"}),
+	}
+	registry.MustRegister(m.quantity, m.notional)
+	return m
+}
+
+// observeOrderSize records a routed order's quantity and notional
+func (e *ExecutionEngine) observeOrderSize(order *OrderRequest) {
+	if e.orderSizes == nil {
+		return
+	}
+	price := order.LimitPrice
+	if order.Type == "market" || !price.IsPositive() {
+		price = e.prices.reference(order.Symbol)
+	}
+	quantity := order.Quantity
+	notional := quantity.Mul(price)
+	if order.Notional.IsPositive() {
+		notional = order.Notional
+		quantity = decimal.Zero
+		if price.IsPositive() {
+			quantity = notional.Div(price)
+		}
+	}
+	e.orderSizes.quantity.WithLabelValues(order.Side).Observe(quantity.InexactFloat64())
+	e.orderSizes.notional.WithLabelValues(order.Side).Observe(notional.InexactFloat64())
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+)
+
+// histogramBySide returns the sample count and sum of each side's series of
+// a histogram
+func histogramBySide(t *testing.T, engine *ExecutionEngine, name string) map[string][2]float64 {
+	t.Helper()
+	families, err := engine.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][2]float64{}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "side" {
+					h := metric.GetHistogram()
+					got[label.GetValue()] = [2]float64{float64(h.GetSampleCount()), h.GetSampleSum()}
+				}
+			}
+		}
+	}
+	return got
+}
+
+func TestOrderSizeHistogramsBySide(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	submitToEngine(t, engine, limitOrder("buy-1", "AAPL", "buy", 100, 5))
+	submitToEngine(t, engine, limitOrder("buy-2", "AAPL", "buy", 99, 10))
+	sell := testOrder("sell-1")
+	sell.Side = "sell"
+	sell.Symbol = "MSFT"
+	sell.Quantity = dec(20)
+	submitToEngine(t, engine, &sell) // a market order, at MSFT's reference price of 100
+
+	quantities := histogramBySide(t, engine, "order_quantity")
+	if quantities["buy"] != [2]float64{2, 15} || quantities["sell"] != [2]float64{1, 20} {
+		t.Errorf("order_quantity (count, sum) by side = %v, want buy (2, 15) and sell (1, 20)", quantities)
+	}
+	notionals := histogramBySide(t, engine, "order_notional")
+	if notionals["buy"] != [2]float64{2, 1490} || notionals["sell"] != [2]float64{1, 2000} {
+		t.Errorf("order_notional (count, sum) by side = %v, want buy (2, 1490) and sell (1, 2000)", notionals)
+	}
+}
+
+func TestParseBuckets(t *testing.T) {
+	buckets, err := parseBuckets("1, 5,25", defaultQuantityBuckets)
+	if err != nil || len(buckets) != 3 || buckets[2] != 25 {
+		t.Errorf("parseBuckets = %v, %v", buckets, err)
+	}
+	if buckets, _ := parseBuckets("", defaultQuantityBuckets); len(buckets) != len(defaultQuantityBuckets) {
+		t.Errorf("empty list gave %v, want the defaults", buckets)
+	}
+	for _, raw := range []string{"1,x", "10,5", "1,1"} {
+		if _, err := parseBuckets(raw, nil); err == nil {
+			t.Errorf("parseBuckets(%q) accepted", raw)
+		}
+	}
+}
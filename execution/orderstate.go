@@ -0,0 +1,88 @@
+// ==============================================================================
+// Order states - the lifecycle an order's status may follow
+// ==============================================================================
+// An order response is created in its first state (a queued order is
+// "accepted", a seeded one "working"). Every later change goes through
+// transition, which only allows the moves listed in orderTransitions and logs
+// and refuses anything else, so e.g. a fill can never resurrect a cancelled
+// order. Matching moves an incoming order on from "accepted" the same way,
+// and storeResponse checks every execution's outcome against the state the
+// order is cached in, so a late or repeated execution can't overwrite a
+// final one.
+// Filled, cancelled, rejected and simulated orders are final.
+// ==============================================================================
+
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// OrderState is the status of an order
+type OrderState string
+
+// Order states
+const (
+	statusAccepted        OrderState = "accepted"         // queued, not executed yet
+	statusHeld            OrderState = "held"             // waiting for its activation time or stop price
+	statusWorking         OrderState = "working"          // resting in the book, nothing filled
+	statusPartiallyFilled OrderState = "partially_filled" // some quantity filled, the rest working or gone
+	statusFilled          OrderState = "filled"
+	statusCancelled       OrderState = "cancelled"
+	statusRejected        OrderState = "rejected"
+	statusTimedOut        OrderState = "timed_out" // venue state unknown, awaiting reconciliation
+	statusSimulated       OrderState = "simulated" // dry-run estimate, never executed
+)
+
+// orderTransitions lists the states each state may move to. States without
+// an entry are final.
+var orderTransitions = map[OrderState][]OrderState{
+	statusAccepted:        {statusHeld, statusWorking, statusPartiallyFilled, statusFilled, statusCancelled, statusRejected, statusTimedOut},
+	statusHeld:            {statusHeld, statusWorking, statusPartiallyFilled, statusFilled, statusCancelled, statusRejected, statusTimedOut},
+	statusWorking:         {statusPartiallyFilled, statusFilled, statusCancelled},
+	statusPartiallyFilled: {statusPartiallyFilled, statusFilled, statusCancelled},
+	statusTimedOut:        {statusWorking, statusPartiallyFilled, statusFilled, statusCancelled, statusRejected},
+}
+
+// canTransition reports whether an order may move from one state to another
+func (s OrderState) canTransition(to OrderState) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// final reports whether an order in state s can't change any more
+func (s OrderState) final() bool {
+	_, more := orderTransitions[s]
+	return !more
+}
+
+// transition moves r to state to, refusing and logging illegal moves
+func (r *OrderResponse) transition(to OrderState) error {
+	if !r.Status.canTransition(to) {
+		err := fmt.Errorf("illegal order state transition %s -> %s", r.Status, to)
+		log.Printf("Order %s: %v", r.OrderID, err)
+		return err
+	}
+	r.Status = to
+	return nil
+}
+
+// storeResponse caches response as its order's latest state if the order
+// may move there from the state it's cached in (accepted if it isn't). An
+// illegal move leaves the cached state alone.
+func (e *ExecutionEngine) storeResponse(response *OrderResponse) error {
+	current := OrderResponse{OrderID: response.OrderID, Status: statusAccepted}
+	if cached, ok := e.orderCache.Load(response.OrderID); ok {
+		current.Status = cached.Status
+	}
+	if err := current.transition(response.Status); err != nil {
+		return err
+	}
+	e.orderCache.Store(response.OrderID, response)
+	return nil
+}
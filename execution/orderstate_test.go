@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+func TestLegalOrderTransitions(t *testing.T) {
+	for from, targets := range orderTransitions {
+		for _, to := range targets {
+			r := &OrderResponse{OrderID: "o1", Status: from}
+			if err := r.transition(to); err != nil || r.Status != to {
+				t.Errorf("%s -> %s: got %s (%v)", from, to, r.Status, err)
+			}
+		}
+	}
+}
+
+func TestIllegalOrderTransitionsRejected(t *testing.T) {
+	for _, tc := range []struct{ from, to OrderState }{
+		{statusCancelled, statusFilled},
+		{statusFilled, statusCancelled},
+		{statusFilled, statusPartiallyFilled},
+		{statusRejected, statusFilled},
+		{statusPartiallyFilled, statusWorking},
+		{statusWorking, statusAccepted},
+		{statusWorking, statusRejected},
+		{statusSimulated, statusFilled},
+	} {
+		r := &OrderResponse{OrderID: "o1", Status: tc.from}
+		if err := r.transition(tc.to); err == nil {
+			t.Errorf("%s -> %s should be rejected", tc.from, tc.to)
+		}
+		if r.Status != tc.from {
+			t.Errorf("%s -> %s changed the status to %s", tc.from, tc.to, r.Status)
+		}
+	}
+}
+
+func TestCancelledOrderCannotBeFilled(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	submitToEngine(t, engine, limitOrder("rest-1", "AAPL", "sell", 100, 10))
+	if _, err := engine.CancelOrder("rest-1", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	engine.updateCachedResponse("rest-1", statusFilled, func(r *OrderResponse) {
+		r.FilledQuantity = dec(10)
+	})
+	response, _ := engine.GetOrder("rest-1")
+	if response.Status != statusCancelled || !response.FilledQuantity.IsZero() {
+		t.Fatalf("cancelled order was modified: %+v", response)
+	}
+}
+
+func TestExecutionCannotMoveAFinalOrder(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	sink := withMockSink(engine)
+	submitToEngine(t, engine, limitOrder("ask", "AAPL", "sell", 100, 10))
+	submitToEngine(t, engine, limitOrder("done", "AAPL", "buy", 100, 10))
+	if s := restingStatus(t, engine, "done"); s != statusFilled {
+		t.Fatalf("status %s, want filled", s)
+	}
+
+	// The same order again, once to rest and once to be rejected outright
+	rest := limitOrder("done", "AAPL", "buy", 90, 10)
+	rest.IdempotencyKey = "key-done-2"
+	submitToEngine(t, engine, rest)
+	if inBook(engine, "AAPL", "done") {
+		t.Error("filled order rested again")
+	}
+	setRiskLimits(engine, func(l *RiskLimits) { l.MaxOrderQuantity = dec(1) })
+	again := limitOrder("done", "AAPL", "buy", 90, 10)
+	again.IdempotencyKey = "key-done-3"
+	submitToEngine(t, engine, again)
+
+	response, _ := engine.GetOrder("done")
+	if response.Status != statusFilled || !response.FilledQuantity.Equal(dec(10)) {
+		t.Errorf("filled order became %s with %s filled", response.Status, response.FilledQuantity)
+	}
+	engine.fills.close()
+	for _, sent := range sink.delivered {
+		if sent.OrderID == "done" && sent.Status != statusFilled {
+			t.Errorf("published %s for a filled order", sent.Status)
+		}
+	}
+}
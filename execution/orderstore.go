@@ -0,0 +1,255 @@
+// ==============================================================================
+// Order store - Redis-persisted order history
+// ==============================================================================
+// Every order response is written to the "<stream>.orders" hash keyed by order
+// ID. Sorted sets index the orders by acknowledgement time, one across all
+// symbols and one per symbol. All members share score 0 and are ordered
+// lexicographically as "<zero-padded unix ms>:<order id>", which gives a
+// unique, stable sort key that doubles as the pagination cursor.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Bounds on the page size of GET /orders
+const (
+	defaultOrderPageSize = 100
+	maxOrderPageSize     = 1000
+)
+
+// errInvalidCursor is returned for a pagination cursor we didn't issue
+var errInvalidCursor = errors.New("invalid cursor")
+
+// OrderFilter narrows an order history query. Zero values match everything.
+type OrderFilter struct {
+	Symbol string
+	Status OrderState
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Cursor string
+}
+
+// OrderPage is one page of an order history query
+type OrderPage struct {
+	Orders     []*OrderResponse `json:"orders"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// orderIndexMember is the sort key of an order in the history indexes
+func orderIndexMember(acknowledgedAt int64, orderID string) string {
+	return fmt.Sprintf("%013d:%s", acknowledgedAt, orderID)
+}
+
+func (e *ExecutionEngine) orderIndexKey(symbol string) string {
+	if symbol == "" {
+		return e.orderStoreKey + ".index"
+	}
+	return e.orderStoreKey + ".index." + symbol
+}
+
+// saveOrder persists a new order response and adds it to the history indexes
+func (e *ExecutionEngine) saveOrder(order *OrderRequest, response *OrderResponse) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Error encoding order %s for the store: %v", response.OrderID, err)
+		return
+	}
+
+	member := &redis.Z{Member: orderIndexMember(response.AcknowledgedAt, response.OrderID)}
+	pipe := e.redisClient.TxPipeline()
+	pipe.HSet(e.ctx, e.orderStoreKey, response.OrderID, data)
+	pipe.ZAddNX(e.ctx, e.orderIndexKey(""), member)
+	pipe.ZAddNX(e.ctx, e.orderIndexKey(order.Symbol), member)
+	if order.ParentOrderID != "" {
+		e.indexChild(pipe, order)
+	}
+	if _, err := pipe.Exec(e.ctx); err != nil {
+		log.Printf("Error saving order %s: %v", response.OrderID, err)
+	}
+}
+
+// updateStoredOrder overwrites the stored response of an order already in the
+// history, e.g. after a resting order is filled
+func (e *ExecutionEngine) updateStoredOrder(response *OrderResponse) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Error encoding order %s for the store: %v", response.OrderID, err)
+		return
+	}
+	if err := e.redisClient.HSet(e.ctx, e.orderStoreKey, response.OrderID, data).Err(); err != nil {
+		log.Printf("Error updating stored order %s: %v", response.OrderID, err)
+	}
+}
+
+// loadStoredOrder reads a single order from the store
+func (e *ExecutionEngine) loadStoredOrder(ctx context.Context, orderID string) (*OrderResponse, bool) {
+	data, err := e.redisClient.HGet(ctx, e.orderStoreKey, orderID).Result()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Error loading order %s: %v", orderID, err)
+		}
+		return nil, false
+	}
+	var response OrderResponse
+	if err := json.Unmarshal([]byte(data), &response); err != nil {
+		log.Printf("Error decoding stored order %s: %v", orderID, err)
+		return nil, false
+	}
+	return &response, true
+}
+
+// ListOrders returns the orders matching the filter, oldest first. Pass the
+// returned NextCursor back in the filter to fetch the following page.
+func (e *ExecutionEngine) ListOrders(ctx context.Context, filter OrderFilter) (*OrderPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultOrderPageSize
+	}
+	if limit > maxOrderPageSize {
+		limit = maxOrderPageSize
+	}
+
+	min := "-"
+	if !filter.Since.IsZero() {
+		min = "[" + orderIndexMember(filter.Since.UnixMilli(), "")
+	}
+	if filter.Cursor != "" {
+		after, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil {
+			return nil, errInvalidCursor
+		}
+		min = "(" + string(after)
+	}
+	max := "+"
+	if !filter.Until.IsZero() {
+		max = "(" + orderIndexMember(filter.Until.UnixMilli(), "")
+	}
+
+	page := &OrderPage{Orders: []*OrderResponse{}}
+	members := []string{}
+
+	// Status isn't indexed, so read the index in batches and filter until the
+	// page is full. One match past the limit tells us another page exists.
+	for len(page.Orders) <= limit {
+		batch, err := e.redisClient.ZRangeByLex(ctx, e.orderIndexKey(filter.Symbol), &redis.ZRangeBy{
+			Min:   min,
+			Max:   max,
+			Count: int64(limit + 1),
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		ids := make([]string, len(batch))
+		for i, member := range batch {
+			ids[i] = orderIDFromIndexMember(member)
+		}
+		stored, err := e.redisClient.HMGet(ctx, e.orderStoreKey, ids...).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for i, raw := range stored {
+			data, ok := raw.(string)
+			if !ok {
+				continue
+			}
+			var response OrderResponse
+			if err := json.Unmarshal([]byte(data), &response); err != nil {
+				log.Printf("Error decoding stored order %s: %v", ids[i], err)
+				continue
+			}
+			if filter.Status != "" && response.Status != filter.Status {
+				continue
+			}
+			page.Orders = append(page.Orders, &response)
+			members = append(members, batch[i])
+			if len(page.Orders) > limit {
+				break
+			}
+		}
+
+		min = "(" + batch[len(batch)-1]
+	}
+
+	if len(page.Orders) > limit {
+		page.Orders = page.Orders[:limit]
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(members[limit-1]))
+	}
+	return page, nil
+}
+
+// orderIDFromIndexMember strips the timestamp prefix off an index member
+func orderIDFromIndexMember(member string) string {
+	if len(member) < 14 || member[13] != ':' {
+		return member
+	}
+	return member[14:]
+}
+
+// handleListOrders serves GET /orders?symbol=&status=&since=&until=&limit=&cursor=
+func (e *ExecutionEngine) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := OrderFilter{
+		Symbol: query.Get("symbol"),
+		Status: OrderState(query.Get("status")),
+		Cursor: query.Get("cursor"),
+	}
+
+	var err error
+	if filter.Since, err = parseTimeParam(query.Get("since")); err != nil {
+		writeFieldError(w, "since", "Invalid since")
+		return
+	}
+	if filter.Until, err = parseTimeParam(query.Get("until")); err != nil {
+		writeFieldError(w, "until", "Invalid until")
+		return
+	}
+	if raw := query.Get("limit"); raw != "" {
+		if filter.Limit, err = strconv.Atoi(raw); err != nil || filter.Limit <= 0 {
+			writeFieldError(w, "limit", "Invalid limit")
+			return
+		}
+	}
+
+	page, err := e.ListOrders(r.Context(), filter)
+	if errors.Is(err, errInvalidCursor) {
+		writeFieldError(w, "cursor", "Invalid cursor")
+		return
+	}
+	if err != nil {
+		writeError(w, errCodeInternal, "Failed to list orders")
+		return
+	}
+
+	e.presentOrders(r, page.Orders)
+	json.NewEncoder(w).Encode(page)
+}
+
+// parseTimeParam accepts either RFC 3339 or unix milliseconds
+func parseTimeParam(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
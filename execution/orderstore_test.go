@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// seedOrderHistory stores orders acknowledged one millisecond apart from base,
+// with the last two sharing a timestamp to exercise the tie-break on order ID
+func seedOrderHistory(e *ExecutionEngine, base int64) {
+	seed := []struct {
+		id, symbol, status string
+		at                 int64
+	}{
+		{"o1", "AAPL", "filled", base},
+		{"o2", "MSFT", "filled", base + 1},
+		{"o3", "AAPL", "rejected", base + 2},
+		{"o4", "AAPL", "filled", base + 3},
+		{"o5", "MSFT", "working", base + 4},
+		{"o6", "AAPL", "filled", base + 5},
+		{"o7", "AAPL", "filled", base + 5},
+	}
+	for _, s := range seed {
+		e.saveOrder(&OrderRequest{OrderID: s.id, Symbol: s.symbol}, &OrderResponse{
+			OrderID:        s.id,
+			Status:         OrderState(s.status),
+			AcknowledgedAt: s.at,
+		})
+	}
+}
+
+func orderIDs(page *OrderPage) []string {
+	ids := []string{}
+	for _, o := range page.Orders {
+		ids = append(ids, o.OrderID)
+	}
+	return ids
+}
+
+func TestListOrdersFilters(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	base := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC).UnixMilli()
+	seedOrderHistory(engine, base)
+
+	tests := []struct {
+		name   string
+		filter OrderFilter
+		want   []string
+	}{
+		{"no filter", OrderFilter{}, []string{"o1", "o2", "o3", "o4", "o5", "o6", "o7"}},
+		{"symbol", OrderFilter{Symbol: "MSFT"}, []string{"o2", "o5"}},
+		{"status", OrderFilter{Status: "filled"}, []string{"o1", "o2", "o4", "o6", "o7"}},
+		{"symbol and status", OrderFilter{Symbol: "AAPL", Status: "filled"}, []string{"o1", "o4", "o6", "o7"}},
+		{"since", OrderFilter{Since: time.UnixMilli(base + 3)}, []string{"o4", "o5", "o6", "o7"}},
+		{"until", OrderFilter{Until: time.UnixMilli(base + 2)}, []string{"o1", "o2"}},
+		{"all filters", OrderFilter{Symbol: "AAPL", Status: "filled", Since: time.UnixMilli(base + 1), Until: time.UnixMilli(base + 5)}, []string{"o4"}},
+		{"unknown symbol", OrderFilter{Symbol: "TSLA"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := engine.ListOrders(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("ListOrders: %v", err)
+			}
+			if got := orderIDs(page); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if page.NextCursor != "" {
+				t.Errorf("unexpected cursor on a single page: %q", page.NextCursor)
+			}
+		})
+	}
+}
+
+func TestListOrdersPagination(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		filter OrderFilter
+		limit  int
+		pages  [][]string
+	}{
+		{"uneven pages", OrderFilter{}, 3, [][]string{{"o1", "o2", "o3"}, {"o4", "o5", "o6"}, {"o7"}}},
+		{"exact multiple", OrderFilter{Symbol: "AAPL"}, 5, [][]string{{"o1", "o3", "o4", "o6", "o7"}}},
+		{"page ends on a timestamp tie", OrderFilter{Symbol: "AAPL"}, 4, [][]string{{"o1", "o3", "o4", "o6"}, {"o7"}}},
+		{"filtered pages", OrderFilter{Status: "filled"}, 2, [][]string{{"o1", "o2"}, {"o4", "o6"}, {"o7"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, _ := newTestEngine(t)
+			seedOrderHistory(engine, time.Now().UnixMilli())
+			filter := tt.filter
+			filter.Limit = tt.limit
+			for i, want := range tt.pages {
+				page, err := engine.ListOrders(ctx, filter)
+				if err != nil {
+					t.Fatalf("page %d: %v", i, err)
+				}
+				if got := orderIDs(page); !reflect.DeepEqual(got, want) {
+					t.Fatalf("page %d: got %v, want %v", i, got, want)
+				}
+				last := i == len(tt.pages)-1
+				if last != (page.NextCursor == "") {
+					t.Fatalf("page %d: next cursor %q, last page %v", i, page.NextCursor, last)
+				}
+
+				// An order sorting before the cursor must not shift later pages
+				engine.saveOrder(&OrderRequest{OrderID: "late", Symbol: "AAPL"}, &OrderResponse{
+					OrderID:        "late",
+					Status:         "filled",
+					AcknowledgedAt: 1,
+				})
+				filter.Cursor = page.NextCursor
+			}
+		})
+	}
+
+	engine, _ := newTestEngine(t)
+	if _, err := engine.ListOrders(ctx, OrderFilter{Cursor: "not base64!"}); !errors.Is(err, errInvalidCursor) {
+		t.Errorf("expected errInvalidCursor, got %v", err)
+	}
+}
+
+func TestOrderHistoryEndpoints(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	seedOrderHistory(engine, time.Now().UnixMilli())
+	mux := engine.routes()
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders?symbol=AAPL&status=filled&limit=2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var page OrderPage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got := orderIDs(&page); !reflect.DeepEqual(got, []string{"o1", "o4"}) || page.NextCursor == "" {
+		t.Fatalf("unexpected page: %v cursor %q", got, page.NextCursor)
+	}
+
+	// Single-order lookups fall back to the store when the cache is cold
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders/o3", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for stored order, got %d", rec.Code)
+	}
+
+	for _, bad := range []string{"/orders?limit=0", "/orders?since=yesterday", "/orders?cursor=%25%25"} {
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, bad, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", bad, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// ==============================================================================
+// Outcome window - sliding fill-success and rejection ratios for alerting
+// ==============================================================================
+// orders_processed_total and orders_rejected_total only ever grow, which makes
+// "rejections spiked in the last minute" awkward to alert on. The outcome
+// window keeps one bucket per second for the last METRICS_WINDOW, so memory
+// stays fixed however busy we are, and periodically exports:
+//   - order_fill_success_ratio: processed / (processed + rejected)
+//   - order_rejection_ratio{reason}: rejected for reason / all outcomes
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// outcomeBucket counts the outcomes of one second
+type outcomeBucket struct {
+	second    int64
+	processed int
+	rejected  map[string]int
+}
+
+// outcomeWindow tracks order outcomes over a sliding window of whole seconds
+type outcomeWindow struct {
+	mu      sync.Mutex
+	buckets []outcomeBucket
+	now     func() time.Time
+
+	successRatio  prometheus.Gauge
+	rejectionRate *prometheus.GaugeVec
+	reasons       map[string]bool // every reason exported so far, reset to 0 when idle
+}
+
+func newOutcomeWindow(window time.Duration, registry *prometheus.Registry) *outcomeWindow {
+	seconds := int(window / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	w := &outcomeWindow{
+		buckets: make([]outcomeBucket, seconds),
+		now:     time.Now,
+		successRatio: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "order_fill_success_ratio",
+			Help: "Share of orders processed rather than rejected over the sliding window",
+		}),
+		rejectionRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "order_rejection_ratio",
+			Help: "Share of orders rejected over the sliding window, by reason",
+		}, []string{"reason"}),
+		reasons: map[string]bool{},
+	}
+	w.successRatio.Set(1)
+	registry.MustRegister(w.successRatio, w.rejectionRate)
+	return w
+}
+
+// bucket returns the bucket for the current second, recycling a stale one.
+// Callers must hold mu.
+func (w *outcomeWindow) bucket() *outcomeBucket {
+	sec := w.now().Unix()
+	b := &w.buckets[sec%int64(len(w.buckets))]
+	if b.second != sec {
+		*b = outcomeBucket{second: sec}
+	}
+	return b
+}
+
+// processed records an order that was executed
+func (w *outcomeWindow) processed() {
+	w.mu.Lock()
+	w.bucket().processed++
+	w.mu.Unlock()
+}
+
+// rejected records an order that was rejected for reason
+func (w *outcomeWindow) rejected(reason string) {
+	w.mu.Lock()
+	b := w.bucket()
+	if b.rejected == nil {
+		b.rejected = map[string]int{}
+	}
+	b.rejected[reason]++
+	w.mu.Unlock()
+}
+
+// totals counts the outcomes in the window from second since on
+func (w *outcomeWindow) totals(since int64) (processed, rejected int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	oldest := max(w.now().Unix()-int64(len(w.buckets))+1, since)
+	for _, b := range w.buckets {
+		if b.second < oldest {
+			continue
+		}
+		processed += b.processed
+		for _, n := range b.rejected {
+			rejected += n
+		}
+	}
+	return processed, rejected
+}
+
+// update recomputes the exported gauges from the buckets still in the window
+func (w *outcomeWindow) update() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	oldest := w.now().Unix() - int64(len(w.buckets)) + 1
+	processed, rejected := 0, 0
+	byReason := map[string]int{}
+	for _, b := range w.buckets {
+		if b.second < oldest {
+			continue
+		}
+		processed += b.processed
+		for reason, n := range b.rejected {
+			byReason[reason] += n
+			rejected += n
+		}
+	}
+
+	total := processed + rejected
+	if total == 0 {
+		// No traffic is not a failure
+		w.successRatio.Set(1)
+	} else {
+		w.successRatio.Set(float64(processed) / float64(total))
+	}
+
+	for reason := range byReason {
+		w.reasons[reason] = true
+	}
+	for reason := range w.reasons {
+		ratio := 0.0
+		if total > 0 {
+			ratio = float64(byReason[reason]) / float64(total)
+		}
+		w.rejectionRate.WithLabelValues(reason).Set(ratio)
+	}
+}
+
+// run refreshes the gauges every interval until ctx is cancelled
+func (w *outcomeWindow) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.update()
+		}
+	}
+}
+
+// recordRejection counts a rejected order in the totals and the window,
+// which may trip the auto-breaker
+func (e *ExecutionEngine) recordRejection(reason string) {
+	e.ordersRejected.Inc()
+	e.outcomes.rejected(reason)
+	e.checkAutoBreaker()
+}
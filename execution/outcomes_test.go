@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestOutcomeWindowRatios(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	w := newOutcomeWindow(10*time.Second, prometheus.NewRegistry())
+	w.now = func() time.Time { return now }
+
+	// 15 processed, 3 tick rejections and 2 liquidity rejections, spread over
+	// a few seconds of the window
+	for i := 0; i < 15; i++ {
+		w.processed()
+		if i%5 == 4 {
+			now = now.Add(time.Second)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		w.rejected(rejectInvalidTick)
+	}
+	w.rejected(rejectInsufficientLiquidity)
+	w.rejected(rejectInsufficientLiquidity)
+	w.update()
+
+	if got := testutil.ToFloat64(w.successRatio); got != 0.75 {
+		t.Errorf("success ratio = %v, want 0.75", got)
+	}
+	if got := testutil.ToFloat64(w.rejectionRate.WithLabelValues(rejectInvalidTick)); got != 0.15 {
+		t.Errorf("invalid tick ratio = %v, want 0.15", got)
+	}
+	if got := testutil.ToFloat64(w.rejectionRate.WithLabelValues(rejectInsufficientLiquidity)); got != 0.1 {
+		t.Errorf("insufficient liquidity ratio = %v, want 0.1", got)
+	}
+
+	// Once everything has aged out the window reads as healthy again
+	now = now.Add(10 * time.Second)
+	w.processed()
+	w.update()
+	if got := testutil.ToFloat64(w.successRatio); got != 1 {
+		t.Errorf("success ratio after window = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(w.rejectionRate.WithLabelValues(rejectInvalidTick)); got != 0 {
+		t.Errorf("expired reason should drop to 0, got %v", got)
+	}
+}
+
+func TestOutcomeWindowIsBounded(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	w := newOutcomeWindow(5*time.Second, prometheus.NewRegistry())
+	w.now = func() time.Time { return now }
+
+	for i := 0; i < 1000; i++ {
+		w.processed()
+		now = now.Add(time.Second)
+	}
+	if len(w.buckets) != 5 {
+		t.Fatalf("window grew to %d buckets", len(w.buckets))
+	}
+}
+
+func TestEngineRecordsOutcomes(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.instruments = map[string]InstrumentSpec{"AAPL": {LotSize: dec(10)}}
+	engine.config.InstrumentPolicy = instrumentPolicyReject
+
+	for i, qty := range []float64{10, 20, 5, 30} {
+		order := testOrder(string(rune('a' + i)))
+		order.Quantity = dec(qty)
+		submitToEngine(t, engine, &order)
+	}
+	engine.outcomes.update()
+
+	if got := testutil.ToFloat64(engine.outcomes.successRatio); got != 0.75 {
+		t.Errorf("success ratio = %v, want 0.75", got)
+	}
+	if got := testutil.ToFloat64(engine.outcomes.rejectionRate.WithLabelValues(rejectInvalidLot)); got != 0.25 {
+		t.Errorf("invalid lot ratio = %v, want 0.25", got)
+	}
+}
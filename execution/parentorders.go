@@ -0,0 +1,191 @@
+// ==============================================================================
+// Parent orders - rolling algo child orders up to the order they slice
+// ==============================================================================
+// An execution algo (TWAP, VWAP, ...) works a large parent order by sending
+// it to the engine as a series of child orders. Each child names its parent
+// in parent_order_id and may carry the parent's target quantity in
+// parent_quantity; the parent itself never reaches the engine.
+//
+// Children are indexed per parent in the "<stream>.orders.children.<parent>"
+// hash as they are stored. GET /orders/{parent}/children returns them with
+// the parent's roll-up: the quantity filled against the target (the largest
+// parent_quantity any child carried, or else the children's total quantity),
+// the average fill price and charges, the net position the children built
+// and its P&L marked at the reference price.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shopspring/decimal"
+)
+
+// ParentOrder is the roll-up of an algo parent order's children
+type ParentOrder struct {
+	ParentOrderID  string           `json:"parent_order_id"`
+	Status         OrderState       `json:"status"`
+	TargetQuantity decimal.Decimal  `json:"target_quantity"`
+	FilledQuantity decimal.Decimal  `json:"filled_quantity"`
+	FilledAvgPrice decimal.Decimal  `json:"filled_avg_price"`
+	Commission     decimal.Decimal  `json:"commission"`
+	Fees           decimal.Decimal  `json:"fees"`
+	Position       decimal.Decimal  `json:"position"` // signed: negative when the children sold
+	PnL            decimal.Decimal  `json:"pnl"`      // of the children's fills at the reference price, net of charges
+	Children       []*OrderResponse `json:"children"`
+}
+
+// childEntry is what the children index keeps of a child, as the response
+// doesn't carry it
+type childEntry struct {
+	Side           string          `json:"side"`
+	Quantity       decimal.Decimal `json:"quantity"`
+	ParentQuantity decimal.Decimal `json:"parent_quantity,omitempty"`
+}
+
+// MarshalJSON leaves out an unset parent quantity (see decimal.go)
+func (c childEntry) MarshalJSON() ([]byte, error) {
+	type plain childEntry
+	return json.Marshal(struct {
+		plain
+		ParentQuantity *decimal.Decimal `json:"parent_quantity,omitempty"`
+	}{plain(c), omitZero(c.ParentQuantity)})
+}
+
+// validateParent checks an order's parent fields
+func validateParent(order *OrderRequest) error {
+	if order.ParentOrderID == "" {
+		if !order.ParentQuantity.IsZero() {
+			return fmt.Errorf("parent_quantity requires a parent_order_id")
+		}
+		return nil
+	}
+	if order.ParentOrderID == order.OrderID {
+		return fmt.Errorf("an order can't be its own parent")
+	}
+	if strings.Contains(order.ParentOrderID, "/") {
+		return fmt.Errorf("invalid parent_order_id %q", order.ParentOrderID)
+	}
+	if order.ParentQuantity.IsNegative() {
+		return fmt.Errorf("parent_quantity must be positive")
+	}
+	return nil
+}
+
+func (e *ExecutionEngine) childrenKey(parentID string) string {
+	return e.orderStoreKey + ".children." + parentID
+}
+
+// indexChild adds an order to its parent's children, in the same
+// transaction that stores it
+func (e *ExecutionEngine) indexChild(pipe redis.Pipeliner, order *OrderRequest) {
+	data, err := json.Marshal(childEntry{Side: order.Side, Quantity: order.Quantity, ParentQuantity: order.ParentQuantity})
+	if err != nil {
+		log.Printf("Error encoding child order %s: %v", order.OrderID, err)
+		return
+	}
+	pipe.HSet(e.ctx, e.childrenKey(order.ParentOrderID), order.OrderID, data)
+}
+
+// ParentOrder rolls up the children of an algo parent order, oldest first.
+// It reports false if the parent has no children.
+func (e *ExecutionEngine) ParentOrder(parentID string) (*ParentOrder, bool, error) {
+	entries, err := e.redisClient.HGetAll(e.ctx, e.childrenKey(parentID)).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	parent := &ParentOrder{ParentOrderID: parentID, Children: []*OrderResponse{}}
+	var quantity, notional decimal.Decimal
+	open, rejected := false, 0
+	for childID, data := range entries {
+		var entry childEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			log.Printf("Error decoding child order %s of %s: %v", childID, parentID, err)
+			continue
+		}
+		child, ok := e.GetOrder(childID)
+		if !ok {
+			continue
+		}
+		parent.Children = append(parent.Children, child)
+		quantity = quantity.Add(entry.Quantity)
+		parent.TargetQuantity = decimal.Max(parent.TargetQuantity, entry.ParentQuantity)
+
+		filled := child.FilledQuantity
+		parent.FilledQuantity = parent.FilledQuantity.Add(filled)
+		notional = notional.Add(filled.Mul(child.FilledAvgPrice))
+		parent.Commission = parent.Commission.Add(child.Commission)
+		parent.Fees = parent.Fees.Add(child.Fees)
+		signed := filled
+		if entry.Side == "sell" {
+			signed = signed.Neg()
+		}
+		parent.Position = parent.Position.Add(signed)
+		mark := e.prices.reference(child.Symbol)
+		parent.PnL = parent.PnL.Add(signed.Mul(mark.Sub(child.FilledAvgPrice))).Sub(child.Commission).Sub(child.Fees)
+
+		if !child.Status.final() {
+			open = true
+		}
+		if child.Status == statusRejected {
+			rejected++
+		}
+	}
+	if len(parent.Children) == 0 {
+		return nil, false, nil
+	}
+	sort.Slice(parent.Children, func(i, j int) bool {
+		a, b := parent.Children[i], parent.Children[j]
+		if a.AcknowledgedAt != b.AcknowledgedAt {
+			return a.AcknowledgedAt < b.AcknowledgedAt
+		}
+		return a.OrderID < b.OrderID
+	})
+	if parent.TargetQuantity.IsZero() {
+		parent.TargetQuantity = quantity
+	}
+	if parent.FilledQuantity.IsPositive() {
+		parent.FilledAvgPrice = notional.Div(parent.FilledQuantity)
+	}
+
+	switch {
+	case parent.FilledQuantity.IsPositive() && parent.FilledQuantity.GreaterThanOrEqual(parent.TargetQuantity):
+		parent.Status = statusFilled
+	case parent.FilledQuantity.IsPositive():
+		parent.Status = statusPartiallyFilled
+	case open:
+		parent.Status = statusWorking
+	case rejected == len(parent.Children):
+		parent.Status = statusRejected
+	default:
+		parent.Status = statusCancelled
+	}
+	return parent, true, nil
+}
+
+// handleOrderChildren serves GET /orders/{parent}/children
+func (e *ExecutionEngine) handleOrderChildren(w http.ResponseWriter, r *http.Request, parentID string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	parent, ok, err := e.ParentOrder(parentID)
+	if err != nil {
+		log.Printf("Error loading children of %s: %v", parentID, err)
+		writeError(w, errCodeInternal, "Failed to load child orders")
+		return
+	}
+	if !ok {
+		writeError(w, errCodeNotFound, "No child orders for this parent")
+		return
+	}
+	e.presentOrders(r, parent.Children)
+	json.NewEncoder(w).Encode(parent)
+}
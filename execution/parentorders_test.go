@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParentOrderRollsUpChildren(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	handler := engine.routes()
+	children := func() (int, ParentOrder) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders/twap-1/children", nil))
+		var parent ParentOrder
+		json.Unmarshal(rec.Body.Bytes(), &parent)
+		return rec.Code, parent
+	}
+	if code, _ := children(); code != http.StatusNotFound {
+		t.Fatalf("parent without children: got %d, want 404", code)
+	}
+
+	// Two slices take another account's offer, the third rests below it
+	offer := limitOrder("offer", "AAPL", "sell", 100, 20)
+	offer.AccountID = "other"
+	submitToEngine(t, engine, offer)
+	for _, child := range []*OrderRequest{
+		limitOrder("twap-1-a", "AAPL", "buy", 101, 10),
+		limitOrder("twap-1-b", "AAPL", "buy", 101, 10),
+		limitOrder("twap-1-c", "AAPL", "buy", 99, 10),
+	} {
+		child.ParentOrderID = "twap-1"
+		child.ParentQuantity = dec(30)
+		submitToEngine(t, engine, child)
+	}
+
+	code, parent := children()
+	if code != http.StatusOK {
+		t.Fatalf("GET children: got %d", code)
+	}
+	if len(parent.Children) != 3 || parent.Children[0].ParentOrderID != "twap-1" {
+		t.Fatalf("children = %+v, want the three slices", parent.Children)
+	}
+	if parent.Status != statusPartiallyFilled || !parent.FilledQuantity.Equal(dec(20)) || !parent.TargetQuantity.Equal(dec(30)) {
+		t.Errorf("with two slices filled: got %s, %s of %s, want partially_filled, 20 of 30", parent.Status, parent.FilledQuantity, parent.TargetQuantity)
+	}
+
+	// Filling the resting slice completes the parent
+	seller := limitOrder("seller", "AAPL", "sell", 99, 10)
+	seller.AccountID = "other"
+	submitToEngine(t, engine, seller)
+	_, parent = children()
+	if parent.Status != statusFilled || !parent.FilledQuantity.Equal(dec(30)) || !parent.Position.Equal(dec(30)) {
+		t.Errorf("with all slices filled: got %s, %s filled, position %s, want filled, 30, 30", parent.Status, parent.FilledQuantity, parent.Position)
+	}
+	// 20 bought at 100 and 10 at 99, marked at the last trade of 99
+	if !parent.PnL.Equal(dec(-20)) {
+		t.Errorf("PnL = %s, want -20", parent.PnL)
+	}
+}
+
+func TestValidateParent(t *testing.T) {
+	for _, tc := range []struct {
+		parent   string
+		quantity float64
+		ok       bool
+	}{
+		{"", 0, true},
+		{"algo-1", 0, true},
+		{"algo-1", 100, true},
+		{"", 100, false},
+		{"child", 0, false},
+		{"a/children", 0, false},
+		{"algo-1", -1, false},
+	} {
+		order := limitOrder("child", "AAPL", "buy", 100, 1)
+		order.ParentOrderID, order.ParentQuantity = tc.parent, dec(tc.quantity)
+		if err := validateParent(order); (err == nil) != tc.ok {
+			t.Errorf("parent %q quantity %v: got %v", tc.parent, tc.quantity, err)
+		}
+	}
+}
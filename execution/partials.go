@@ -0,0 +1,85 @@
+// ==============================================================================
+// Partial fill aggregation - one update for a burst of partial fills
+// ==============================================================================
+// A large resting order swept by many small takers would otherwise publish
+// an update per fill. With FILL_AGGREGATION_WINDOW set, the first
+// partially_filled update of an order starts a window of that length; later
+// partial updates within it replace the held one, and when the window ends
+// the latest is published. Updates are cumulative, so the one published
+// carries every fill of the window.
+//
+// Any other update - filled, cancelled, rejected - is published at once and
+// discards the held partial it supersedes, so terminal events are never
+// delayed. Holding and publishing happen under one lock, so an order's
+// updates still reach the sinks in order.
+// ==============================================================================
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// partialFillAggregator merges each order's partial fill updates over a
+// window
+type partialFillAggregator struct {
+	window  time.Duration
+	publish func(*OrderResponse)
+
+	mu      sync.Mutex
+	pending map[string]*heldPartial
+}
+
+// heldPartial is the latest partial update of an order in its window
+type heldPartial struct {
+	response *OrderResponse
+	timer    *time.Timer
+}
+
+func newPartialFillAggregator(window time.Duration, publish func(*OrderResponse)) *partialFillAggregator {
+	return &partialFillAggregator{
+		window:  window,
+		publish: publish,
+		pending: make(map[string]*heldPartial),
+	}
+}
+
+// offer publishes an update, or holds it if it is a partial fill
+func (a *partialFillAggregator) offer(response *OrderResponse) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	held, ok := a.pending[response.OrderID]
+	if response.Status == statusPartiallyFilled {
+		if ok {
+			held.response = response
+			return
+		}
+		orderID := response.OrderID
+		a.pending[orderID] = &heldPartial{
+			response: response,
+			timer:    time.AfterFunc(a.window, func() { a.flush(orderID) }),
+		}
+		return
+	}
+
+	// Anything else supersedes the held partial
+	if ok {
+		held.timer.Stop()
+		delete(a.pending, response.OrderID)
+	}
+	a.publish(response)
+}
+
+// flush publishes an order's held partial at the end of its window
+func (a *partialFillAggregator) flush(orderID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	held, ok := a.pending[orderID]
+	if !ok {
+		return
+	}
+	delete(a.pending, orderID)
+	a.publish(held.response)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPartialFillsAggregatedWithinWindow(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	const window = 300 * time.Millisecond
+	engine.partials = newPartialFillAggregator(window, func(r *OrderResponse) { engine.fills.dispatch(r) })
+
+	submitToEngine(t, engine, limitOrder("ask-1", "AAPL", "sell", 100, 10))
+	sub := engine.subscribers.subscribe("AAPL")
+	defer engine.subscribers.unsubscribe(sub)
+
+	// restingUpdate waits for the next update of the resting order, skipping
+	// the takers' own and its working acknowledgement
+	restingUpdate := func(timeout time.Duration) (*OrderResponse, time.Duration) {
+		t.Helper()
+		start := time.Now()
+		deadline := time.After(timeout)
+		for {
+			select {
+			case update := <-sub.updates:
+				if update.OrderID == "ask-1" && update.Status != statusWorking {
+					return update, time.Since(start)
+				}
+			case <-deadline:
+				return nil, time.Since(start)
+			}
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		submitToEngine(t, engine, limitOrder(fmt.Sprintf("buy-%d", i), "AAPL", "buy", 100, 2))
+	}
+	update, _ := restingUpdate(2 * time.Second)
+	if update == nil || update.Status != statusPartiallyFilled || !update.FilledQuantity.Equal(dec(6)) {
+		t.Fatalf("aggregated update: got %+v, want one partially_filled update for 6", update)
+	}
+	if extra, _ := restingUpdate(window); extra != nil {
+		t.Fatalf("partials in one window published more than once: %+v", extra)
+	}
+
+	// Another partial, then the fill that completes it: the terminal update
+	// goes out at once and supersedes the held partial
+	submitToEngine(t, engine, limitOrder("buy-3", "AAPL", "buy", 100, 1))
+	submitToEngine(t, engine, limitOrder("buy-4", "AAPL", "buy", 100, 3))
+	update, elapsed := restingUpdate(2 * time.Second)
+	if update == nil || update.Status != statusFilled || !update.FilledQuantity.Equal(dec(10)) {
+		t.Fatalf("terminal update: got %+v, want filled for 10", update)
+	}
+	if elapsed >= window {
+		t.Errorf("terminal update took %s, want it before the %s window", elapsed, window)
+	}
+	if extra, _ := restingUpdate(window + 100*time.Millisecond); extra != nil {
+		t.Fatalf("held partial published after the terminal update: %+v", extra)
+	}
+}
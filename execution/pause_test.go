@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPauseHaltsConsumptionAndResumeDrains(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	engine.ctx = ctx
+	t.Cleanup(cancel)
+	engine.apiKeys = map[string]string{"key-ops": "ops"}
+	if err := ensureConsumerGroup(ctx, engine.redisClient, engine.streamName, engine.consumerGroup); err != nil {
+		t.Fatal(err)
+	}
+	go engine.consumeOrders()
+	handler := engine.routes()
+
+	call := func(method, path, apiKey string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if apiKey != "" {
+			req.Header.Set(apiKeyHeader, apiKey)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := call(http.MethodPost, "/admin/pause", ""); code != http.StatusUnauthorized {
+		t.Fatalf("pause without an API key: got %d, want 401", code)
+	}
+	if code := call(http.MethodPost, "/admin/pause", "key-ops"); code != http.StatusOK || !engine.Paused() {
+		t.Fatalf("pause: got %d, paused=%v", code, engine.Paused())
+	}
+	if code := call(http.MethodGet, "/ready", ""); code != http.StatusServiceUnavailable {
+		t.Errorf("/ready while paused: got %d, want 503", code)
+	}
+
+	if rec := postOrder(handler, "key-ops"); rec.Code != http.StatusAccepted {
+		t.Fatalf("submit while paused: got %d", rec.Code)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if _, ok := engine.GetOrder("auth-1"); ok {
+		t.Fatal("order was processed while paused")
+	}
+
+	if code := call(http.MethodPost, "/admin/resume", "key-ops"); code != http.StatusOK || engine.Paused() {
+		t.Fatalf("resume: got %d, paused=%v", code, engine.Paused())
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, ok := engine.GetOrder("auth-1"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("order queued while paused was not processed after resume")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if code := call(http.MethodGet, "/ready", ""); code != http.StatusOK {
+		t.Errorf("/ready after resume: got %d, want 200", code)
+	}
+}
@@ -0,0 +1,55 @@
+// ==============================================================================
+// Payload limits - bounding the size of requests and order messages
+// ==============================================================================
+// Nothing decoded by the engine may be larger than MAX_PAYLOAD_BYTES (0
+// disables the limit). HTTP request bodies are read through
+// http.MaxBytesReader: a body declared larger is answered 413
+// "payload_too_large" before it is read, and one that turns out larger
+// while decoding fails the request, with 413 from POST /orders. Order
+// messages on the stream, which other producers may write without going
+// through the API, are checked before decoding and dead-lettered with reason
+// "payload_too_large".
+// ==============================================================================
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// dlqReasonPayloadTooLarge is the DLQ reason of oversized order messages
+const dlqReasonPayloadTooLarge = "payload_too_large"
+
+// limitBodies caps the size of every request body
+func (e *ExecutionEngine) limitBodies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := e.config.MaxPayloadBytes
+		if limit > 0 && r.Body != nil {
+			if r.ContentLength > limit {
+				writePayloadTooLarge(w, limit)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// payloadTooLarge reports whether err came from reading past the body limit
+func payloadTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
+
+// writePayloadTooLarge answers 413 for a body over limit bytes
+func writePayloadTooLarge(w http.ResponseWriter, limit int64) {
+	writeError(w, errCodePayloadTooLarge, fmt.Sprintf("Request body exceeds %d bytes", limit))
+}
+
+// oversizedPayload reports whether a stream message's payload is over the
+// limit
+func (e *ExecutionEngine) oversizedPayload(payload string) bool {
+	return e.config.MaxPayloadBytes > 0 && int64(len(payload)) > e.config.MaxPayloadBytes
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestOversizedRequestBodyRefused(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.MaxPayloadBytes = 512
+	handler := engine.routes()
+
+	post := func(body string, chunked bool) int {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		if chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	normal := `{"order_id":"ok-1","symbol":"AAPL","side":"buy","quantity":"10","type":"market"}`
+	if code := post(normal, false); code != http.StatusAccepted {
+		t.Errorf("normal body: got %d, want 202", code)
+	}
+
+	oversized := `{"order_id":"big-1","symbol":"AAPL","side":"buy","quantity":"10","type":"market","tags":{"note":"` + strings.Repeat("x", 1024) + `"}}`
+	if code := post(oversized, false); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: got %d, want 413", code)
+	}
+	if code := post(oversized, true); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body without a length: got %d, want 413", code)
+	}
+}
+
+func TestOversizedStreamMessageDeadLettered(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.MaxPayloadBytes = 512
+	payload := `{"order_id":"big-1","symbol":"AAPL","side":"buy","quantity":"10","type":"market","tags":{"note":"` + strings.Repeat("x", 1024) + `"}}`
+
+	if err := engine.processOrder(redis.XMessage{ID: "0-1", Values: map[string]interface{}{"order": payload}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := engine.GetOrder("big-1"); ok {
+		t.Error("oversized order was processed")
+	}
+	entries := engine.redisClient.XRange(context.Background(), engine.dlqStreamName, "-", "+").Val()
+	if len(entries) != 1 || entries[0].Values["reason"] != dlqReasonPayloadTooLarge {
+		t.Fatalf("DLQ = %v, want the oversized message with reason %s", entries, dlqReasonPayloadTooLarge)
+	}
+}
@@ -0,0 +1,142 @@
+// ==============================================================================
+// Pegged orders - limit orders that track the best bid or offer
+// ==============================================================================
+// A limit order with peg_to ("bid", "ask" or "mid") has no fixed price: it is
+// priced at the reference plus peg_offset (signed, so -0.01 is a cent below
+// it) when it arrives, and re-pegged whenever the reference moves. Pegs track
+// the best unpegged orders only, so they never chase each other or
+// themselves.
+//
+// A re-peg moves the order to its new level with a new sequence number, so it
+// loses queue priority, and is journaled as a cancel and an add. One order is
+// re-pegged at most once per PEG_REPRICE_INTERVAL; moves in between are
+// picked up by the next book change or the periodic sweep, whichever comes
+// first. A peg never reprices into a crossing price: it keeps its old price
+// until the new one would rest.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Peg references
+const (
+	pegBid = "bid"
+	pegAsk = "ask"
+	pegMid = "mid"
+)
+
+// rejectNoPegReference is the reason for pegged orders whose reference side
+// of the book is empty
+const rejectNoPegReference = "peg_reference_unavailable"
+
+// validPegReference reports whether ref is a known peg_to
+func validPegReference(ref string) bool {
+	return ref == pegBid || ref == pegAsk || ref == pegMid
+}
+
+// bestUnpegged returns the best price on levels with an unpegged order
+func bestUnpegged(levels []*priceLevel) (decimal.Decimal, bool) {
+	for _, level := range levels {
+		for _, o := range level.orders {
+			if o.PegTo == "" {
+				return level.price, true
+			}
+		}
+	}
+	return decimal.Zero, false
+}
+
+// pegPrice returns the price of an order pegged to ref with offset, and
+// false if the reference is missing or the price wouldn't be positive
+func (b *OrderBook) pegPrice(ref string, offset decimal.Decimal) (decimal.Decimal, bool) {
+	bid, hasBid := bestUnpegged(b.bids)
+	ask, hasAsk := bestUnpegged(b.asks)
+
+	var reference decimal.Decimal
+	switch {
+	case ref == pegBid && hasBid:
+		reference = bid
+	case ref == pegAsk && hasAsk:
+		reference = ask
+	case ref == pegMid && hasBid && hasAsk:
+		reference = bid.Add(ask).Div(decimal.NewFromInt(2))
+	default:
+		return decimal.Zero, false
+	}
+	price := reference.Add(offset)
+	return price, price.IsPositive()
+}
+
+// repeg moves the book's pegged orders to their current peg prices, subject
+// to the reprice interval. Callers must hold the book's lock.
+func (e *ExecutionEngine) repeg(book *OrderBook) {
+	var pegged []*BookOrder
+	for _, order := range book.orders {
+		if order.PegTo != "" {
+			pegged = append(pegged, order)
+		}
+	}
+	// Oldest first, so replays and repeated runs assign the same sequences
+	sort.Slice(pegged, func(i, j int) bool { return pegged[i].Sequence < pegged[j].Sequence })
+
+	now := e.now()
+	for _, order := range pegged {
+		price, ok := book.pegPrice(order.PegTo, order.PegOffset)
+		if !ok || price.Equal(order.Price) || now.Sub(order.peggedAt) < e.config.PegRepriceInterval {
+			continue
+		}
+		if book.wouldCross(order.Side, price) {
+			continue
+		}
+
+		book.reprice(order, price)
+		order.peggedAt = now
+
+		e.journal(book, bookMutation{Op: journalOpCancel, Symbol: book.Symbol, OrderID: order.OrderID})
+		e.journal(book, bookMutation{Op: journalOpAdd, Symbol: book.Symbol, Order: order})
+	}
+}
+
+// repegAll re-pegs the pegged orders in every book
+func (e *ExecutionEngine) repegAll() {
+	for _, book := range e.allBooks() {
+		book.mu.Lock()
+		e.repeg(book)
+		book.mu.Unlock()
+	}
+}
+
+// pegLoop periodically re-pegs orders whose reprice was deferred by the
+// interval, until ctx is cancelled
+func (e *ExecutionEngine) pegLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.repegAll()
+		}
+	}
+}
+
+// pegOrder prices a pegged order off book, rejecting it if the reference is
+// missing. Callers must hold the book's lock.
+func pegOrder(book *OrderBook, order *OrderRequest) bool {
+	price, ok := book.pegPrice(order.PegTo, order.PegOffset)
+	if !ok {
+		log.Printf("Order %s rejected: %s (%s side empty)", order.OrderID, rejectNoPegReference, order.PegTo)
+		return false
+	}
+	order.LimitPrice = price
+	return true
+}
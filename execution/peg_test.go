@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// peggedOrder is a limit buy of 10 AAPL pegged to ref plus offset
+func peggedOrder(id string, ref string, offset float64) *OrderRequest {
+	order := limitOrder(id, "AAPL", "buy", 0, 10)
+	order.LimitPrice = dec(0)
+	order.PegTo = ref
+	order.PegOffset = dec(offset)
+	return order
+}
+
+func TestPeggedOrderTracksBestBid(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.PegRepriceInterval = 0
+
+	price := func() *BookOrder {
+		t.Helper()
+		book := engine.bookFor("AAPL")
+		book.mu.Lock()
+		defer book.mu.Unlock()
+		order, ok := book.Get("peg")
+		if !ok {
+			t.Fatal("pegged order not resting")
+		}
+		copied := *order
+		return &copied
+	}
+
+	submitToEngine(t, engine, limitOrder("bid-1", "AAPL", "buy", 99, 10))
+	submitToEngine(t, engine, limitOrder("ask-1", "AAPL", "sell", 101, 10))
+	submitToEngine(t, engine, peggedOrder("peg", pegBid, -0.5))
+	if status := restingStatus(t, engine, "peg"); status != statusWorking {
+		t.Fatalf("pegged order: status %s, want working", status)
+	}
+	if got := price().Price; !got.Equal(dec(98.5)) {
+		t.Fatalf("pegged price = %s, want 98.5", got)
+	}
+
+	// A better bid moves the peg up with it, to the back of its new level
+	submitToEngine(t, engine, limitOrder("bid-0", "AAPL", "buy", 99, 10))
+	submitToEngine(t, engine, limitOrder("bid-2", "AAPL", "buy", 99.5, 10))
+	repegged := price()
+	if !repegged.Price.Equal(dec(99)) {
+		t.Fatalf("pegged price after bid moved to 99.5 = %s, want 99", repegged.Price)
+	}
+	bid0, _ := engine.books["AAPL"].Get("bid-0")
+	if repegged.Sequence <= bid0.Sequence {
+		t.Errorf("re-pegged order kept its queue priority (seq %d, bid-0 seq %d)", repegged.Sequence, bid0.Sequence)
+	}
+
+	// And back down when the best bid goes away
+	if _, err := engine.CancelOrder("bid-2", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := price().Price; !got.Equal(dec(98.5)) {
+		t.Errorf("pegged price after best bid cancelled = %s, want 98.5", got)
+	}
+
+	// Re-pegs are rate limited; the sweep catches up once the interval passes
+	engine.config.PegRepriceInterval = time.Hour
+	submitToEngine(t, engine, limitOrder("bid-3", "AAPL", "buy", 100, 10))
+	if got := price().Price; !got.Equal(dec(98.5)) {
+		t.Errorf("pegged price within the reprice interval = %s, want 98.5", got)
+	}
+	engine.config.PegRepriceInterval = 0
+	engine.repegAll()
+	if got := price().Price; !got.Equal(dec(99.5)) {
+		t.Errorf("pegged price after sweep = %s, want 99.5", got)
+	}
+}
+
+func TestPeggedOrderNeedsItsReference(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	submitToEngine(t, engine, limitOrder("ask-1", "AAPL", "sell", 101, 10))
+
+	submitToEngine(t, engine, peggedOrder("peg-bid", pegBid, 0))
+	response, _ := engine.GetOrder("peg-bid")
+	if response.Status != statusRejected || response.RejectReason != rejectNoPegReference {
+		t.Errorf("peg to an empty bid side: got %+v, want %s rejection", response, rejectNoPegReference)
+	}
+
+	submitToEngine(t, engine, peggedOrder("peg-mid", pegMid, 0))
+	response, _ = engine.GetOrder("peg-mid")
+	if response.Status != statusRejected || response.RejectReason != rejectNoPegReference {
+		t.Errorf("peg to mid with one side empty: got %+v, want %s rejection", response, rejectNoPegReference)
+	}
+
+	// Pegged to the offer, a buy just below it rests
+	submitToEngine(t, engine, peggedOrder("peg-ask", pegAsk, -1))
+	if status := restingStatus(t, engine, "peg-ask"); status != statusWorking {
+		t.Errorf("peg to the offer: status %s, want working", status)
+	}
+}
@@ -0,0 +1,81 @@
+// ==============================================================================
+// P&L - realized and unrealized profit and loss per position
+// ==============================================================================
+// Realized P&L is accumulated by the position tracker as fills reduce
+// positions. Unrealized P&L marks the open quantity to the market data
+// cache's price for the symbol: the last trade, or the configured reference
+// price before the first one.
+//
+// GET /pnl and GET /pnl/{symbol} return the P&L of every position (in the
+// symbol), with totals; ?account= narrows them to one account. Positions of
+// hedge mode accounts are reported leg by leg.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// PnL is the profit and loss of one position
+type PnL struct {
+	AccountID  string          `json:"account_id,omitempty"`
+	Symbol     string          `json:"symbol"`
+	Leg        string          `json:"leg,omitempty"` // hedge mode only: long or short
+	Quantity   decimal.Decimal `json:"quantity"`
+	AvgCost    decimal.Decimal `json:"avg_cost"`
+	Mark       decimal.Decimal `json:"mark"`
+	Realized   decimal.Decimal `json:"realized"`
+	Unrealized decimal.Decimal `json:"unrealized"`
+}
+
+// PnLReport is the P&L of a set of positions and their totals
+type PnLReport struct {
+	Positions  []PnL           `json:"positions"`
+	Realized   decimal.Decimal `json:"realized"`
+	Unrealized decimal.Decimal `json:"unrealized"`
+}
+
+// PnL reports the P&L of the positions matching account and symbol, either
+// of which may be empty to match all
+func (e *ExecutionEngine) PnL(account string, symbol string) PnLReport {
+	report := PnLReport{Positions: []PnL{}}
+	for _, p := range e.positions.All() {
+		if (account != "" && p.AccountID != account) || (symbol != "" && p.Symbol != symbol) {
+			continue
+		}
+		mark := e.prices.reference(p.Symbol)
+		entry := PnL{
+			AccountID:  p.AccountID,
+			Symbol:     p.Symbol,
+			Leg:        p.Leg,
+			Quantity:   p.Quantity,
+			AvgCost:    p.AvgCost(),
+			Mark:       mark,
+			Realized:   p.Realized,
+			Unrealized: p.Unrealized(mark),
+		}
+		report.Positions = append(report.Positions, entry)
+		report.Realized = report.Realized.Add(entry.Realized)
+		report.Unrealized = report.Unrealized.Add(entry.Unrealized)
+	}
+	return report
+}
+
+// handlePnL serves GET /pnl and /pnl/{symbol}
+func (e *ExecutionEngine) handlePnL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	symbol := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/pnl"), "/")
+	if strings.Contains(symbol, "/") {
+		notFound(w)
+		return
+	}
+	json.NewEncoder(w).Encode(e.PnL(r.URL.Query().Get("account"), symbol))
+}
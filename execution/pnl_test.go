@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// pnlFill is an execution applied to a position
+type pnlFill struct {
+	side                string
+	qty, price, charges float64
+}
+
+func TestRealizedPnLAgainstCostBasis(t *testing.T) {
+	tests := []struct {
+		name         string
+		fills        []pnlFill
+		wantQty      float64
+		wantBasis    float64
+		wantRealized float64
+	}{
+		{
+			name: "long round trip",
+			fills: []pnlFill{
+				{"buy", 10, 100, 0}, {"sell", 10, 110, 0},
+			},
+			wantRealized: 100,
+		},
+		{
+			name: "short round trip",
+			fills: []pnlFill{
+				{"sell", 10, 100, 0}, {"buy", 10, 90, 0},
+			},
+			wantRealized: 100,
+		},
+		{
+			name: "partial reduce at averaged cost",
+			fills: []pnlFill{
+				{"buy", 10, 100, 0}, {"buy", 10, 110, 0}, {"sell", 5, 120, 0},
+			},
+			wantQty:      15,
+			wantBasis:    1575,
+			wantRealized: 75,
+		},
+		{
+			name: "flip closes the old side first",
+			fills: []pnlFill{
+				{"buy", 10, 100, 0}, {"sell", 15, 90, 0},
+			},
+			wantQty:      -5,
+			wantBasis:    -450,
+			wantRealized: -100,
+		},
+		{
+			name: "net of opening and closing charges",
+			fills: []pnlFill{
+				{"buy", 10, 100, 2}, {"sell", 10, 110, 3},
+			},
+			wantRealized: 95,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewPositionTracker()
+			for _, f := range tt.fills {
+				tracker.Apply("acct-1", "AAPL", f.side, dec(f.qty), dec(f.price), dec(f.charges))
+			}
+			p, _ := tracker.Get("acct-1", "AAPL")
+			if !p.Quantity.Equal(dec(tt.wantQty)) || !p.CostBasis.Equal(dec(tt.wantBasis)) {
+				t.Errorf("position = %s @ basis %s, want %v @ %v", p.Quantity, p.CostBasis, tt.wantQty, tt.wantBasis)
+			}
+			if !p.Realized.Equal(dec(tt.wantRealized)) {
+				t.Errorf("realized = %s, want %v", p.Realized, tt.wantRealized)
+			}
+		})
+	}
+}
+
+func TestUnrealizedPnLAtMark(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.positions.Apply("acct-1", "AAPL", "buy", dec(10), dec(100), decimal.Zero)
+	engine.positions.Apply("acct-2", "MSFT", "sell", dec(4), dec(400), decimal.Zero)
+	engine.prices.record("AAPL", dec(105))
+	engine.prices.record("MSFT", dec(410))
+
+	rec := httptest.NewRecorder()
+	engine.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/pnl/AAPL", nil))
+	var report PnLReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Positions) != 1 || !report.Positions[0].Mark.Equal(dec(105)) || !report.Unrealized.Equal(dec(50)) {
+		t.Fatalf("GET /pnl/AAPL = %+v, want 50 unrealized at a mark of 105", report)
+	}
+
+	all := engine.PnL("", "")
+	if len(all.Positions) != 2 || !all.Unrealized.Equal(dec(10)) {
+		t.Errorf("total unrealized = %s over %d positions, want 10 (50 long AAPL, -40 short MSFT)", all.Unrealized, len(all.Positions))
+	}
+	if mine := engine.PnL("acct-2", ""); len(mine.Positions) != 1 || mine.Positions[0].Symbol != "MSFT" {
+		t.Errorf("account filter returned %+v", mine.Positions)
+	}
+}
@@ -0,0 +1,158 @@
+// ==============================================================================
+// Position import and export - start from the broker's positions
+// ==============================================================================
+// The position tracker starts empty, so an engine deployed against accounts
+// that already hold positions gets their P&L and reduce-only checks wrong
+// until it is told about them. POST /admin/positions imports a JSON array of
+// positions:
+//
+//   [{"account_id":"acct-1","symbol":"AAPL","quantity":"100","avg_cost":"150.25"}]
+//
+// quantity is signed (negative when short) and avg_cost is the cost per unit.
+// Hedge mode accounts give each leg separately, with "leg":"long" for a
+// positive quantity or "leg":"short" for a negative one; netting accounts
+// give none. realized_pnl and fees may be carried over as well.
+//
+// ?mode=merge (the default) sets the imported positions and keeps the rest;
+// ?mode=replace drops every position not imported. Either way the whole
+// import is validated first and applied at once, or not at all.
+//
+// GET /admin/positions exports every position in the same format, so a
+// snapshot can be restored by importing it.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/shopspring/decimal"
+)
+
+// Position import modes
+const (
+	positionImportMerge   = "merge"
+	positionImportReplace = "replace"
+)
+
+// PositionSnapshot is a position as imported and exported
+type PositionSnapshot struct {
+	AccountID string          `json:"account_id,omitempty"`
+	Symbol    string          `json:"symbol"`
+	Leg       string          `json:"leg,omitempty"`
+	Quantity  decimal.Decimal `json:"quantity"`
+	AvgCost   decimal.Decimal `json:"avg_cost"`
+	Realized  decimal.Decimal `json:"realized_pnl"`
+	Fees      decimal.Decimal `json:"fees"`
+}
+
+// Export returns a snapshot of every position, ordered like All
+func (t *PositionTracker) Export() []PositionSnapshot {
+	all := t.All()
+	snapshots := make([]PositionSnapshot, len(all))
+	for i, p := range all {
+		snapshots[i] = PositionSnapshot{
+			AccountID: p.AccountID,
+			Symbol:    p.Symbol,
+			Leg:       p.Leg,
+			Quantity:  p.Quantity,
+			AvgCost:   p.AvgCost(),
+			Realized:  p.Realized,
+			Fees:      p.Fees,
+		}
+	}
+	return snapshots
+}
+
+// Import sets the positions in snapshots, dropping every other position if
+// replace is set. Nothing changes unless every snapshot is valid.
+func (t *PositionTracker) Import(snapshots []PositionSnapshot, replace bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	imported := make(map[positionKey]*Position, len(snapshots))
+	for i, s := range snapshots {
+		if err := t.validSnapshot(s); err != nil {
+			return fmt.Errorf("position %d (%s %s): %w", i, s.AccountID, s.Symbol, err)
+		}
+		key := positionKey{s.AccountID, s.Symbol, s.Leg}
+		if _, ok := imported[key]; ok {
+			return fmt.Errorf("position %d (%s %s): imported twice", i, s.AccountID, s.Symbol)
+		}
+		imported[key] = &Position{
+			AccountID: s.AccountID,
+			Symbol:    s.Symbol,
+			Leg:       s.Leg,
+			Quantity:  s.Quantity,
+			CostBasis: s.Quantity.Mul(s.AvgCost),
+			Fees:      s.Fees,
+			Realized:  s.Realized,
+		}
+	}
+
+	if replace {
+		t.positions = imported
+		return nil
+	}
+	for key, p := range imported {
+		t.positions[key] = p
+	}
+	return nil
+}
+
+// validSnapshot checks a snapshot can be imported. Callers must hold mu.
+func (t *PositionTracker) validSnapshot(s PositionSnapshot) error {
+	if s.Symbol == "" {
+		return fmt.Errorf("symbol is required")
+	}
+	if s.AvgCost.IsNegative() || (!s.Quantity.IsZero() && !s.AvgCost.IsPositive()) {
+		return fmt.Errorf("avg_cost must be positive")
+	}
+	if !t.hedged(s.AccountID) {
+		if s.Leg != "" {
+			return fmt.Errorf("leg given for a netting account")
+		}
+		return nil
+	}
+	switch {
+	case s.Leg == positionLegLong && !s.Quantity.IsNegative():
+	case s.Leg == positionLegShort && !s.Quantity.IsPositive():
+	default:
+		return fmt.Errorf("hedge mode positions need leg long with a positive quantity or short with a negative one")
+	}
+	return nil
+}
+
+// handlePositions serves GET /admin/positions, exporting every position, and
+// POST /admin/positions?mode=merge|replace, importing them
+func (e *ExecutionEngine) handlePositions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(e.positions.Export())
+	case http.MethodPost:
+		mode := r.URL.Query().Get("mode")
+		if mode == "" {
+			mode = positionImportMerge
+		}
+		if mode != positionImportMerge && mode != positionImportReplace {
+			writeError(w, errCodeInvalidRequest, fmt.Sprintf("Invalid mode %q, want merge or replace", mode))
+			return
+		}
+		var snapshots []PositionSnapshot
+		if err := json.NewDecoder(r.Body).Decode(&snapshots); err != nil {
+			writeError(w, errCodeInvalidRequest, "Invalid request")
+			return
+		}
+		if err := e.positions.Import(snapshots, mode == positionImportReplace); err != nil {
+			writeError(w, errCodeInvalidRequest, err.Error())
+			return
+		}
+		log.Printf("Imported %d positions (%s)", len(snapshots), mode)
+		json.NewEncoder(w).Encode(map[string]interface{}{"imported": len(snapshots), "mode": mode})
+	default:
+		methodNotAllowed(w)
+	}
+}
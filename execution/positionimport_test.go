@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postPositions(t *testing.T, engine *ExecutionEngine, mode string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	engine.handlePositions(rec, httptest.NewRequest(http.MethodPost, "/admin/positions?mode="+mode, strings.NewReader(body)))
+	return rec
+}
+
+func TestImportedPositionsAreTheBasisForReduceOnlyAndPnL(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	rec := postPositions(t, engine, "merge", `[
+		{"account_id":"acct-1","symbol":"AAPL","quantity":"10","avg_cost":"90"},
+		{"account_id":"acct-1","symbol":"MSFT","quantity":"-5","avg_cost":"200"}
+	]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("import = %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Reduce-only orders can close what was imported and no more
+	submitToEngine(t, engine, reduceOnlySell("close", 120, 25))
+	if resting, ok := engine.bookFor("AAPL").Get("close"); !ok || !resting.Quantity.Equal(dec(10)) {
+		t.Fatalf("reduce-only sell of 25 against an imported long 10 should rest 10, got %+v", resting)
+	}
+
+	// Closing realizes against the imported cost
+	buyer := limitOrder("buyer", "AAPL", "buy", 120, 10)
+	buyer.AccountID = "acct-2"
+	submitToEngine(t, engine, buyer)
+	if report := engine.PnL("acct-1", "AAPL"); !report.Realized.Equal(dec(300)) {
+		t.Errorf("realized P&L closing 10 bought at 90 at 120 = %s, want 300", report.Realized)
+	}
+
+	// The imported short is marked against its cost: 5 sold at 200, now 100
+	if report := engine.PnL("acct-1", "MSFT"); !report.Unrealized.Equal(dec(500)) || !report.Positions[0].AvgCost.Equal(dec(200)) {
+		t.Errorf("MSFT P&L = %+v, want 500 unrealized at an average cost of 200", report)
+	}
+}
+
+func TestPositionImportModesAndValidation(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	postPositions(t, engine, "merge", `[
+		{"account_id":"acct-1","symbol":"AAPL","quantity":"10","avg_cost":"90"},
+		{"account_id":"acct-2","symbol":"AAPL","quantity":"3","avg_cost":"95"}
+	]`)
+
+	// An invalid entry leaves every position as it was
+	rec := postPositions(t, engine, "replace", `[
+		{"account_id":"acct-1","symbol":"AAPL","quantity":"20","avg_cost":"91"},
+		{"account_id":"acct-1","symbol":"MSFT","quantity":"1","avg_cost":"0"}
+	]`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("import with a zero avg_cost = %d, want 400", rec.Code)
+	}
+	if p, _ := engine.positions.Get("acct-1", "AAPL"); !p.Quantity.Equal(dec(10)) {
+		t.Errorf("failed import changed acct-1 to %s", p.Quantity)
+	}
+
+	// Merging sets the listed positions and keeps the others
+	postPositions(t, engine, "merge", `[{"account_id":"acct-1","symbol":"AAPL","quantity":"20","avg_cost":"91"}]`)
+	if p, _ := engine.positions.Get("acct-2", "AAPL"); !p.Quantity.Equal(dec(3)) {
+		t.Errorf("merge dropped acct-2's position")
+	}
+
+	// Replacing keeps only the listed positions
+	postPositions(t, engine, "replace", `[{"account_id":"acct-1","symbol":"AAPL","quantity":"20","avg_cost":"91"}]`)
+	if _, ok := engine.positions.Get("acct-2", "AAPL"); ok {
+		t.Errorf("replace kept acct-2's position")
+	}
+
+	// Exports import back unchanged
+	rec = httptest.NewRecorder()
+	engine.handlePositions(rec, httptest.NewRequest(http.MethodGet, "/admin/positions", nil))
+	var exported []PositionSnapshot
+	if err := json.NewDecoder(rec.Body).Decode(&exported); err != nil || len(exported) != 1 || !exported[0].AvgCost.Equal(dec(91)) {
+		t.Fatalf("export = %+v, %v", exported, err)
+	}
+	restored := NewPositionTracker()
+	if err := restored.Import(exported, true); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := restored.All(), engine.positions.All(); len(got) != 1 || !got[0].CostBasis.Equal(want[0].CostBasis) {
+		t.Errorf("restored %+v, want %+v", got, want)
+	}
+
+	for _, body := range []string{
+		`[{"account_id":"acct-1","quantity":"1","avg_cost":"1"}]`,
+		`[{"account_id":"acct-1","symbol":"AAPL","leg":"long","quantity":"1","avg_cost":"1"}]`,
+		`[{"account_id":"acct-1","symbol":"AAPL","quantity":"1","avg_cost":"1"},{"account_id":"acct-1","symbol":"AAPL","quantity":"2","avg_cost":"1"}]`,
+	} {
+		if rec := postPositions(t, engine, "merge", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("import %s = %d, want 400", body, rec.Code)
+		}
+	}
+	if rec := postPositions(t, engine, "sum", `[]`); rec.Code != http.StatusBadRequest {
+		t.Errorf("import with mode sum = %d, want 400", rec.Code)
+	}
+}
+
+func TestImportHedgeLegs(t *testing.T) {
+	positions := NewPositionTracker()
+	positions.defaultMode = positionModeHedge
+	err := positions.Import([]PositionSnapshot{
+		{AccountID: "acct-1", Symbol: "AAPL", Leg: positionLegLong, Quantity: dec(10), AvgCost: dec(100)},
+		{AccountID: "acct-1", Symbol: "AAPL", Leg: positionLegShort, Quantity: dec(-4), AvgCost: dec(105)},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closable := positions.Closable("acct-1", "AAPL", "buy"); !closable.Equal(dec(4)) {
+		t.Errorf("a buy can close %s of the imported short leg, want 4", closable)
+	}
+	if err := positions.Import([]PositionSnapshot{{AccountID: "acct-1", Symbol: "AAPL", Quantity: dec(1), AvgCost: dec(1)}}, false); err == nil {
+		t.Error("imported a hedge position without a leg")
+	}
+}
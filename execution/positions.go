@@ -0,0 +1,298 @@
+// ==============================================================================
+// Positions - net holdings and cost basis per account and symbol
+// ==============================================================================
+// Every execution is applied to the position of the account that traded. The
+// quantity is signed (negative when short) and the cost basis is the signed
+// cost of the open quantity, including the charges paid to open it. Reducing a
+// position releases cost basis pro rata and realizes the difference between
+// the released basis and the closing price, less the charges on the closing
+// part, so realized P&L is net of all charges. A fill that flips the position
+// closes the old side at that price before opening the new one.
+//
+// That is netting, the default. Accounts in hedge mode (POSITION_MODE for
+// every account, POSITION_MODES per account as JSON, e.g. {"acct-7":"hedge"})
+// instead hold a long and a short leg in each symbol, each with its own cost
+// basis and realized P&L. Their ordinary fills open or add to the leg on the
+// fill's side; only reduce-only fills close a leg, the one on the other side.
+// Reduce-only orders are capped at what that leg holds, but a fill can still
+// outgrow it (the leg may shrink while the order works), and since the excess
+// did trade it opens the leg on the fill's side rather than being lost. Get
+// reports the legs' sum, and All and P&L report each leg.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// Position accounting modes
+const (
+	positionModeNetting = "netting"
+	positionModeHedge   = "hedge"
+)
+
+// Legs of a hedge mode position
+const (
+	positionLegLong  = "long"
+	positionLegShort = "short"
+)
+
+// parsePositionModes decodes the POSITION_MODES config, a JSON object of
+// account to mode
+func parsePositionModes(raw string) (map[string]string, error) {
+	modes := map[string]string{}
+	if raw == "" {
+		return modes, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &modes); err != nil {
+		return nil, err
+	}
+	for account, mode := range modes {
+		if !validPositionMode(mode) {
+			return nil, fmt.Errorf("unknown position mode %q for %s", mode, account)
+		}
+	}
+	return modes, nil
+}
+
+func validPositionMode(mode string) bool {
+	return mode == positionModeNetting || mode == positionModeHedge
+}
+
+// Position is an account's holding in one symbol
+type Position struct {
+	AccountID string          `json:"account_id,omitempty"`
+	Symbol    string          `json:"symbol"`
+	Leg       string          `json:"leg,omitempty"` // hedge mode only: long or short
+	Quantity  decimal.Decimal `json:"quantity"`      // signed: negative when short
+	CostBasis decimal.Decimal `json:"cost_basis"`    // signed cost of the open quantity, including opening charges
+	Fees      decimal.Decimal `json:"fees"`          // all commissions and fees paid
+	Realized  decimal.Decimal `json:"realized_pnl"`  // P&L locked in by reducing fills, net of charges
+}
+
+// Unrealized is the P&L of the open quantity if it were closed at mark
+func (p Position) Unrealized(mark decimal.Decimal) decimal.Decimal {
+	return p.Quantity.Mul(mark).Sub(p.CostBasis)
+}
+
+// AvgCost is the cost per unit of the open quantity
+func (p Position) AvgCost() decimal.Decimal {
+	if p.Quantity.IsZero() {
+		return decimal.Zero
+	}
+	return p.CostBasis.Div(p.Quantity)
+}
+
+type positionKey struct {
+	account string
+	symbol  string
+	leg     string // empty for a netting position
+}
+
+// PositionTracker keeps positions up to date as orders execute. It is safe
+// for concurrent use.
+type PositionTracker struct {
+	mu        sync.Mutex
+	positions map[positionKey]*Position
+
+	// Accounting mode of accounts not in modes; netting if empty
+	defaultMode string
+	modes       map[string]string
+}
+
+// NewPositionTracker creates an empty tracker that nets every account
+func NewPositionTracker() *PositionTracker {
+	return &PositionTracker{positions: make(map[positionKey]*Position)}
+}
+
+// hedged reports whether account is in hedge mode
+func (t *PositionTracker) hedged(account string) bool {
+	if mode, ok := t.modes[account]; ok {
+		return mode == positionModeHedge
+	}
+	return t.defaultMode == positionModeHedge
+}
+
+// closingLeg is the hedge leg a fill on side reduces
+func closingLeg(side string) string {
+	if side == "buy" {
+		return positionLegShort
+	}
+	return positionLegLong
+}
+
+// position returns the position under key, creating it. Callers must hold mu.
+func (t *PositionTracker) position(key positionKey) *Position {
+	p, ok := t.positions[key]
+	if !ok {
+		p = &Position{AccountID: key.account, Symbol: key.symbol, Leg: key.leg}
+		t.positions[key] = p
+	}
+	return p
+}
+
+// Apply records an execution of quantity at price, costing charges. In hedge
+// mode it opens or adds to the leg on side.
+func (t *PositionTracker) Apply(account string, symbol string, side string, quantity decimal.Decimal, price decimal.Decimal, charges decimal.Decimal) {
+	t.apply(account, symbol, side, quantity, price, charges, false)
+}
+
+// ApplyReduceOnly records the execution of a reduce-only order. In hedge mode
+// it closes the leg on the other side of side, opening the leg on side with
+// whatever that leg doesn't hold.
+func (t *PositionTracker) ApplyReduceOnly(account string, symbol string, side string, quantity decimal.Decimal, price decimal.Decimal, charges decimal.Decimal) {
+	t.apply(account, symbol, side, quantity, price, charges, true)
+}
+
+func (t *PositionTracker) apply(account string, symbol string, side string, quantity decimal.Decimal, price decimal.Decimal, charges decimal.Decimal, reduceOnly bool) {
+	if !quantity.IsPositive() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.hedged(account) {
+		t.position(positionKey{account, symbol, ""}).apply(side, quantity, price, charges)
+		return
+	}
+	if !reduceOnly {
+		leg := positionLegLong
+		if side == "sell" {
+			leg = positionLegShort
+		}
+		t.position(positionKey{account, symbol, leg}).apply(side, quantity, price, charges)
+		return
+	}
+	closing := closingLeg(side)
+	p := t.position(positionKey{account, symbol, closing})
+	if held := p.Quantity.Abs(); quantity.GreaterThan(held) {
+		// What the leg can't absorb opens the other one
+		if held.IsPositive() {
+			p.apply(side, held, price, charges.Mul(held).Div(quantity))
+		}
+		opening := positionLegLong
+		if closing == positionLegLong {
+			opening = positionLegShort
+		}
+		excess := quantity.Sub(held)
+		t.position(positionKey{account, symbol, opening}).apply(side, excess, price, charges.Mul(excess).Div(quantity))
+		return
+	}
+	p.apply(side, quantity, price, charges)
+}
+
+// apply nets an execution into the position
+func (p *Position) apply(side string, quantity decimal.Decimal, price decimal.Decimal, charges decimal.Decimal) {
+	p.Fees = p.Fees.Add(charges)
+
+	signed := func(q decimal.Decimal) decimal.Decimal {
+		if side == "sell" {
+			return q.Neg()
+		}
+		return q
+	}
+
+	// Part of the trade closes the existing position
+	opening := quantity
+	if !p.Quantity.IsZero() && p.Quantity.Sign() != signed(quantity).Sign() {
+		closing := decimal.Min(quantity, p.Quantity.Abs())
+		released := p.CostBasis.Mul(closing).Div(p.Quantity.Abs())
+		closingCharges := charges.Mul(closing).Div(quantity)
+		closed := closing.Mul(decimal.NewFromInt(int64(p.Quantity.Sign())))
+		p.Realized = p.Realized.Add(closed.Mul(price).Sub(released).Sub(closingCharges))
+		p.CostBasis = p.CostBasis.Sub(released)
+		p.Quantity = p.Quantity.Add(signed(closing))
+		opening = quantity.Sub(closing)
+	}
+
+	// The rest opens or adds to it
+	if opening.IsPositive() {
+		openingCharges := charges.Mul(opening).Div(quantity)
+		p.Quantity = p.Quantity.Add(signed(opening))
+		p.CostBasis = p.CostBasis.Add(signed(opening).Mul(price)).Add(openingCharges)
+	}
+}
+
+// Get returns a copy of the position of account in symbol: for a hedge mode
+// account, the sum of its legs
+func (t *PositionTracker) Get(account string, symbol string) (Position, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.hedged(account) {
+		p, ok := t.positions[positionKey{account, symbol, ""}]
+		if !ok {
+			return Position{}, false
+		}
+		return *p, true
+	}
+
+	sum := Position{AccountID: account, Symbol: symbol}
+	found := false
+	for _, leg := range []string{positionLegLong, positionLegShort} {
+		p, ok := t.positions[positionKey{account, symbol, leg}]
+		if !ok {
+			continue
+		}
+		found = true
+		sum.Quantity = sum.Quantity.Add(p.Quantity)
+		sum.CostBasis = sum.CostBasis.Add(p.CostBasis)
+		sum.Fees = sum.Fees.Add(p.Fees)
+		sum.Realized = sum.Realized.Add(p.Realized)
+	}
+	return sum, found
+}
+
+// GetLeg returns a copy of one leg of a hedge mode position
+func (t *PositionTracker) GetLeg(account string, symbol string, leg string) (Position, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.positions[positionKey{account, symbol, leg}]
+	if !ok {
+		return Position{}, false
+	}
+	return *p, true
+}
+
+// Closable is how much of account's position in symbol an order on side can
+// close: the netting position on the other side, or in hedge mode the leg on
+// the other side
+func (t *PositionTracker) Closable(account string, symbol string, side string) decimal.Decimal {
+	var open decimal.Decimal
+	if t.hedged(account) {
+		leg, _ := t.GetLeg(account, symbol, closingLeg(side))
+		open = leg.Quantity
+	} else {
+		p, _ := t.Get(account, symbol)
+		open = p.Quantity
+	}
+	if side == "buy" {
+		open = open.Neg() // a buy reduces a short
+	}
+	return open
+}
+
+// All returns copies of every position, ordered by account, symbol and leg
+func (t *PositionTracker) All() []Position {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	all := make([]Position, 0, len(t.positions))
+	for _, p := range t.positions {
+		all = append(all, *p)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].AccountID != all[j].AccountID {
+			return all[i].AccountID < all[j].AccountID
+		}
+		if all[i].Symbol != all[j].Symbol {
+			return all[i].Symbol < all[j].Symbol
+		}
+		return all[i].Leg < all[j].Leg
+	})
+	return all
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// hedgeTracker is a tracker that keeps acct-h in hedge mode
+func hedgeTracker() *PositionTracker {
+	tracker := NewPositionTracker()
+	tracker.modes = map[string]string{"acct-h": positionModeHedge}
+	return tracker
+}
+
+func TestNettingBuyThenSellFlattens(t *testing.T) {
+	tracker := hedgeTracker()
+	tracker.Apply("acct-n", "AAPL", "buy", dec(10), dec(100), decimal.Zero)
+	tracker.Apply("acct-n", "AAPL", "sell", dec(10), dec(110), decimal.Zero)
+
+	p, _ := tracker.Get("acct-n", "AAPL")
+	if !p.Quantity.IsZero() || !p.CostBasis.IsZero() || !p.Realized.Equal(dec(100)) {
+		t.Errorf("netted position = %s @ basis %s, realized %s; want flat with 100 realized", p.Quantity, p.CostBasis, p.Realized)
+	}
+	if all := tracker.All(); len(all) != 1 || all[0].Leg != "" {
+		t.Errorf("netting account positions = %+v, want one without a leg", all)
+	}
+}
+
+func TestHedgeBuyThenSellHoldsTwoLegs(t *testing.T) {
+	tracker := hedgeTracker()
+	tracker.Apply("acct-h", "AAPL", "buy", dec(10), dec(100), decimal.Zero)
+	tracker.Apply("acct-h", "AAPL", "sell", dec(10), dec(110), decimal.Zero)
+
+	long, _ := tracker.GetLeg("acct-h", "AAPL", positionLegLong)
+	short, _ := tracker.GetLeg("acct-h", "AAPL", positionLegShort)
+	if !long.Quantity.Equal(dec(10)) || !long.AvgCost().Equal(dec(100)) {
+		t.Errorf("long leg = %s @ %s, want 10 @ 100", long.Quantity, long.AvgCost())
+	}
+	if !short.Quantity.Equal(dec(-10)) || !short.AvgCost().Equal(dec(110)) {
+		t.Errorf("short leg = %s @ %s, want -10 @ 110", short.Quantity, short.AvgCost())
+	}
+	if !long.Realized.IsZero() || !short.Realized.IsZero() {
+		t.Errorf("opening both legs realized %s and %s, want nothing", long.Realized, short.Realized)
+	}
+	if sum, _ := tracker.Get("acct-h", "AAPL"); !sum.Quantity.IsZero() {
+		t.Errorf("legs sum to %s, want 0", sum.Quantity)
+	}
+
+	// A reduce-only sell closes the long leg, and what outgrows it opens the
+	// short leg
+	tracker.ApplyReduceOnly("acct-h", "AAPL", "sell", dec(4), dec(120), decimal.Zero)
+	long, _ = tracker.GetLeg("acct-h", "AAPL", positionLegLong)
+	if !long.Quantity.Equal(dec(6)) || !long.Realized.Equal(dec(80)) {
+		t.Errorf("long leg after closing 4 @ 120 = %s, realized %s; want 6 and 80", long.Quantity, long.Realized)
+	}
+	tracker.ApplyReduceOnly("acct-h", "AAPL", "sell", dec(9), dec(120), decimal.Zero)
+	long, _ = tracker.GetLeg("acct-h", "AAPL", positionLegLong)
+	short, _ = tracker.GetLeg("acct-h", "AAPL", positionLegShort)
+	if !long.Quantity.IsZero() || !short.Quantity.Equal(dec(-13)) || !short.CostBasis.Equal(dec(-1460)) {
+		t.Errorf("after an oversized close: long %s, short %s costing %s; want 0 and -13 costing -1460", long.Quantity, short.Quantity, short.CostBasis)
+	}
+	if sum, _ := tracker.Get("acct-h", "AAPL"); !sum.Quantity.Equal(dec(-13)) {
+		t.Errorf("legs sum to %s, want every share sold: -13", sum.Quantity)
+	}
+	if got := tracker.Closable("acct-h", "AAPL", "buy"); !got.Equal(dec(13)) {
+		t.Errorf("a buy can close %s, want the short leg's 13", got)
+	}
+}
+
+func TestReduceOnlyClosesHedgeLeg(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.positions.modes = map[string]string{"acct-1": positionModeHedge}
+	engine.positions.Apply("acct-1", "AAPL", "buy", dec(10), dec(100), decimal.Zero)
+	engine.positions.Apply("acct-1", "AAPL", "sell", dec(10), dec(100), decimal.Zero)
+
+	// Netted this account would be flat; hedged, the sell closes its long leg
+	submitToEngine(t, engine, reduceOnlySell("close-long", 105, 25))
+	resting, ok := engine.bookFor("AAPL").Get("close-long")
+	if !ok || !resting.Quantity.Equal(dec(10)) {
+		t.Fatalf("reduce-only sell of 25 against a long leg of 10 should rest 10, got %+v", resting)
+	}
+
+	buyer := limitOrder("buyer", "AAPL", "buy", 105, 50)
+	buyer.AccountID = "acct-2"
+	submitToEngine(t, engine, buyer)
+	long, _ := engine.positions.GetLeg("acct-1", "AAPL", positionLegLong)
+	short, _ := engine.positions.GetLeg("acct-1", "AAPL", positionLegShort)
+	if !long.Quantity.IsZero() || !long.Realized.Equal(dec(50)) || !short.Quantity.Equal(dec(-10)) {
+		t.Errorf("after the close: long %s realized %s, short %s; want 0, 50 and -10", long.Quantity, long.Realized, short.Quantity)
+	}
+
+	report := engine.PnL("acct-1", "AAPL")
+	if len(report.Positions) != 2 || report.Positions[0].Leg != positionLegLong || report.Positions[1].Leg != positionLegShort {
+		t.Errorf("P&L of a hedged account = %+v, want its long and short legs", report.Positions)
+	}
+}
@@ -0,0 +1,85 @@
+// ==============================================================================
+// Price bands - refuse limit orders priced far from the market
+// ==============================================================================
+// A limit buy 50% above where a symbol trades, or a sell 50% below, is almost
+// certainly a fat finger. PRICE_BAND_PCT sets how far from the reference
+// price (the last trade, else the seeded price; see marketdata.go) a limit
+// price may be, as a percentage either way, and PRICE_BANDS overrides it per
+// symbol as JSON, e.g. {"AAPL":10,"BTCUSD":25}; 0 leaves a symbol unbanded.
+//
+// Limit orders priced outside the band are rejected with reason
+// "price_out_of_band" before they can trade or rest, with the band's edge as
+// the risk limit and their price as the current value. Stop-limits are
+// checked when they trigger, against the reference price then. Pegged orders
+// are priced off the book, and symbols with no reference price yet aren't
+// banded. Like collars, the bands are risk limits and reload with them (see
+// reload.go).
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// rejectPriceOutOfBand is the reason for limit orders priced outside their
+// symbol's band
+const rejectPriceOutOfBand = "price_out_of_band"
+
+// parsePriceBands decodes the PRICE_BANDS config, a JSON object of symbol to
+// band percentage
+func parsePriceBands(raw string) (map[string]float64, error) {
+	bands := map[string]float64{}
+	if raw == "" {
+		return bands, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &bands); err != nil {
+		return nil, err
+	}
+	for symbol, pct := range bands {
+		if pct < 0 {
+			return nil, fmt.Errorf("negative band %v for %s", pct, symbol)
+		}
+	}
+	return bands, nil
+}
+
+// priceBand is the band percentage that applies to symbol
+func (l *RiskLimits) priceBand(symbol string) float64 {
+	if pct, ok := l.PriceBands[symbol]; ok {
+		return pct
+	}
+	return l.PriceBandPct
+}
+
+// checkPriceBand refuses a limit order priced further from the reference
+// price than its symbol's band allows
+func (e *ExecutionEngine) checkPriceBand(order *OrderRequest) *rejection {
+	if order.Type != "limit" || order.PegTo != "" {
+		return nil
+	}
+	pct := e.riskLimits().priceBand(order.Symbol)
+	if pct <= 0 {
+		return nil
+	}
+	reference, ok := e.prices.known(order.Symbol)
+	if !ok {
+		return nil
+	}
+	band := reference.Mul(decimal.NewFromFloat(pct)).Div(decimal.NewFromInt(100))
+	edge := reference.Add(band)
+	if order.LimitPrice.LessThanOrEqual(edge) {
+		edge = reference.Sub(band)
+		if order.LimitPrice.GreaterThanOrEqual(edge) {
+			return nil
+		}
+	}
+	return &rejection{
+		Reason: rejectPriceOutOfBand,
+		Detail: fmt.Sprintf("limit %s is more than %v%% from the reference price %s", order.LimitPrice, pct, reference),
+		Limit:  &RiskLimit{Name: riskLimitPriceBand, Limit: edge, Current: order.LimitPrice},
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestPriceBandsRejectLimitsFarFromTheMarket(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	setRiskLimits(engine, func(l *RiskLimits) { l.PriceBandPct = 10 })
+	engine.prices.record("AAPL", dec(200))
+
+	tests := []struct {
+		id, side string
+		price    float64
+		inBand   bool
+	}{
+		{"buy-in", "buy", 185, true},
+		{"sell-in", "sell", 215, true},
+		{"buy-above", "buy", 300, false},
+		{"sell-below", "sell", 100, false},
+		{"buy-below", "buy", 170, false},
+	}
+	for _, tt := range tests {
+		submitToEngine(t, engine, limitOrder(tt.id, "AAPL", tt.side, tt.price, 5))
+		response, _ := engine.GetOrder(tt.id)
+		switch {
+		case tt.inBand && response.Status != statusWorking:
+			t.Errorf("%s %s at %v within 10%% of 200: %s/%s, want resting", tt.id, tt.side, tt.price, response.Status, response.RejectReason)
+		case !tt.inBand && (response.Status != statusRejected || response.RejectReason != rejectPriceOutOfBand):
+			t.Errorf("%s %s at %v: %s/%s, want rejected with %s", tt.id, tt.side, tt.price, response.Status, response.RejectReason, rejectPriceOutOfBand)
+		case !tt.inBand && inBook(engine, "AAPL", tt.id):
+			t.Errorf("%s rests in the book", tt.id)
+		}
+	}
+
+	response, _ := engine.GetOrder("buy-above")
+	if limit := response.RiskLimit; limit == nil || limit.Name != riskLimitPriceBand || !limit.Limit.Equal(dec(220)) || !limit.Current.Equal(dec(300)) {
+		t.Errorf("risk limit %+v, want the band's edge at 220 against 300", limit)
+	}
+}
+
+func TestPriceBandOverridesAndUnknownReferences(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	setRiskLimits(engine, func(l *RiskLimits) {
+		l.PriceBandPct = 10
+		l.PriceBands = map[string]float64{"MSFT": 50, "TSLA": 0}
+	})
+	engine.prices.record("MSFT", dec(100))
+	engine.prices.record("TSLA", dec(100))
+
+	for id, order := range map[string]*OrderRequest{
+		"msft-wide": limitOrder("msft-wide", "MSFT", "buy", 140, 1), // inside its own 50% band
+		"tsla":      limitOrder("tsla", "TSLA", "sell", 500, 1),     // unbanded
+		"no-ref":    limitOrder("no-ref", "NVDA", "buy", 1, 1),      // never traded
+		"market":    {OrderID: "market", Symbol: "MSFT", Side: "buy", Quantity: dec(1), Type: "market"},
+	} {
+		submitToEngine(t, engine, order)
+		if response, _ := engine.GetOrder(id); response.Status == statusRejected {
+			t.Errorf("%s rejected with %s", id, response.RejectReason)
+		}
+	}
+	submitToEngine(t, engine, limitOrder("msft-far", "MSFT", "buy", 160, 1))
+	if response, _ := engine.GetOrder("msft-far"); response.RejectReason != rejectPriceOutOfBand {
+		t.Errorf("MSFT buy 60%% above the market: %s/%s, want rejected", response.Status, response.RejectReason)
+	}
+}
@@ -0,0 +1,139 @@
+// ==============================================================================
+// Submission receipts - signed proof that an order was received
+// ==============================================================================
+// With RECEIPT_KEY set, the 202 answer to POST /orders carries a receipt:
+// the SHA-256 of the order as accepted (its canonical JSON, after symbol
+// normalization and with the submitting account), the server time it was
+// received and a sequence number from "<stream>.receipts.seq", signed with
+// HMAC-SHA256 under the key. A client keeps the receipt to prove what it
+// submitted and when; anyone holding the key can check it with
+// VerifyReceipt.
+//
+// An order with an idempotency key gets one receipt per key, remembered in
+// "<stream>.receipts.<key>" as long as the key itself (idempotencyTTL):
+// retries are answered with the first submission's receipt, byte for byte.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Receipt is the server's signed acknowledgement of an order submission
+type Receipt struct {
+	OrderHash  string `json:"order_hash"`  // hex SHA-256 of the order's canonical JSON
+	ReceivedAt int64  `json:"received_at"` // unix ms
+	Sequence   int64  `json:"sequence"`
+	Signature  string `json:"signature"` // hex HMAC-SHA256 of "<order_hash>:<received_at>:<sequence>"
+}
+
+// signedPart is the message a receipt's signature covers
+func (r *Receipt) signedPart() string {
+	return fmt.Sprintf("%s:%d:%d", r.OrderHash, r.ReceivedAt, r.Sequence)
+}
+
+// sign computes the receipt's signature under key
+func (r *Receipt) sign(key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(r.signedPart()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyReceipt reports whether receipt was signed under key and, if order
+// is not nil, whether it was issued for that order
+func VerifyReceipt(key string, receipt *Receipt, order *OrderRequest) bool {
+	signature, err := hex.DecodeString(receipt.Signature)
+	if err != nil {
+		return false
+	}
+	expected, _ := hex.DecodeString(receipt.sign(key))
+	if !hmac.Equal(signature, expected) {
+		return false
+	}
+	if order == nil {
+		return true
+	}
+	hash, err := orderHash(order)
+	return err == nil && hash == receipt.OrderHash
+}
+
+// orderHash is the hex SHA-256 of an order's canonical JSON
+func orderHash(order *OrderRequest) (string, error) {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// issueReceipt signs a receipt for a queued order, or returns the one
+// already issued for its idempotency key. It returns nil without
+// RECEIPT_KEY.
+func (e *ExecutionEngine) issueReceipt(ctx context.Context, order *OrderRequest) (*Receipt, error) {
+	key := e.config.ReceiptKey
+	if key == "" {
+		return nil, nil
+	}
+	redisKey := ""
+	if order.IdempotencyKey != "" {
+		redisKey = e.keyPrefix + ".receipts." + e.idempotencyKey(order)
+		if original, err := e.storedReceipt(ctx, redisKey); original != nil || err != nil {
+			return original, err
+		}
+	}
+
+	hash, err := orderHash(order)
+	if err != nil {
+		return nil, err
+	}
+	sequence, err := e.redisClient.Incr(ctx, e.keyPrefix+".receipts.seq").Result()
+	if err != nil {
+		return nil, err
+	}
+	receipt := &Receipt{OrderHash: hash, ReceivedAt: e.now().UnixMilli(), Sequence: sequence}
+	receipt.Signature = receipt.sign(key)
+	if redisKey == "" {
+		return receipt, nil
+	}
+
+	// Of two concurrent submissions with the key, the first stored stands
+	data, err := json.Marshal(receipt)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := e.redisClient.SetNX(ctx, redisKey, data, idempotencyTTL).Result()
+	if err != nil || stored {
+		return receipt, err
+	}
+	if original, err := e.storedReceipt(ctx, redisKey); original != nil || err != nil {
+		return original, err
+	}
+	return receipt, nil // expired just now
+}
+
+// storedReceipt reads the receipt issued for an idempotency key, returning
+// nil if there is none
+func (e *ExecutionEngine) storedReceipt(ctx context.Context, redisKey string) (*Receipt, error) {
+	data, err := e.redisClient.Get(ctx, redisKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var receipt Receipt
+	if err := json.Unmarshal(data, &receipt); err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// submitForReceipt posts an order and returns the receipt in the 202 answer
+func submitForReceipt(t *testing.T, handler http.Handler, body string) *Receipt {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("POST /orders: got %d: %s", rec.Code, rec.Body)
+	}
+	var accepted struct {
+		Receipt *Receipt `json:"receipt"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &accepted); err != nil {
+		t.Fatal(err)
+	}
+	return accepted.Receipt
+}
+
+func TestReceiptVerifiesAndIsStableAcrossRetries(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.ReceiptKey = "receipt-secret"
+	handler := engine.routes()
+	const body = `{"order_id":"r-1","symbol":"AAPL","side":"buy","quantity":"10","type":"market","idempotency_key":"k-1"}`
+
+	receipt := submitForReceipt(t, handler, body)
+	if receipt == nil {
+		t.Fatal("no receipt")
+	}
+	var order OrderRequest
+	json.Unmarshal([]byte(body), &order)
+	if !VerifyReceipt("receipt-secret", receipt, &order) {
+		t.Errorf("receipt %+v doesn't verify against the key and order", receipt)
+	}
+	if VerifyReceipt("other-secret", receipt, nil) {
+		t.Error("receipt verifies under another key")
+	}
+	order.Quantity = dec(11)
+	if VerifyReceipt("receipt-secret", receipt, &order) {
+		t.Error("receipt verifies for a different order")
+	}
+	forged := *receipt
+	forged.Sequence++
+	if VerifyReceipt("receipt-secret", &forged, nil) {
+		t.Error("receipt with an altered sequence verifies")
+	}
+
+	engine.clock = newFakeClock() // a retry later gets the same receipt
+	if retry := submitForReceipt(t, handler, body); *retry != *receipt {
+		t.Errorf("retry got receipt %+v, want the original %+v", retry, receipt)
+	}
+	other := submitForReceipt(t, handler, strings.Replace(body, "k-1", "k-2", 1))
+	if other.Sequence != receipt.Sequence+1 {
+		t.Errorf("next key's receipt has sequence %d, want %d", other.Sequence, receipt.Sequence+1)
+	}
+}
+
+func TestNoReceiptWithoutKey(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	if receipt := submitForReceipt(t, engine.routes(), authTestOrder); receipt != nil {
+		t.Errorf("receipt %+v issued without RECEIPT_KEY", receipt)
+	}
+}
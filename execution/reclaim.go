@@ -0,0 +1,256 @@
+// ==============================================================================
+// Crash recovery - orders read but never acknowledged
+// ==============================================================================
+// An order is acknowledged on the stream only once it has been executed,
+// stored and published, so a crash anywhere before that leaves it pending in
+// the consumer group. On start the engine reclaims its consumer's pending
+// orders before reading new ones. Consumer names are unique per replica (see
+// consumerNameFor), so these were read by this engine before it restarted
+// and nothing else can be executing them:
+//
+//   - An order already stored with an outcome was executed before the crash.
+//     It isn't executed again; its stored response is published again, so an
+//     update lost with the crash is delivered now and a delivered one is
+//     delivered twice, as at-least-once allows.
+//   - An order without an outcome is executed. An idempotency key its own
+//     crashed execution claimed is released first, or it would be taken for
+//     a duplicate of itself and never execute.
+//
+// Orders pending with other consumers are left to them until they have sat
+// unacknowledged for RECLAIM_MIN_IDLE, when the replica is taken to be gone
+// and XCLAIM moves them to this one, at start and every RECLAIM_MIN_IDLE
+// after. Their owner may only be stalled, though, so a taken over order is
+// never executed on a key it already claimed: one stored with an outcome is
+// published again as above, one whose key is unclaimed is executed (the key
+// claim settles a race with its owner), and one that claimed its key without
+// an outcome, or has no key to guard it, is routed for reconciliation as
+// orphaned_execution instead.
+//
+// SIMULATE_CRASH makes the engine behave as if it crashed at a point in
+// processing, to check the above end to end: before_publish stops after the
+// first order has been executed and stored but before its response is
+// published, before_ack after it has been published but before it is
+// acknowledged. Either way the engine leaves the order pending and consumes
+// nothing more until restarted. It is for test environments only.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Points in processing SIMULATE_CRASH can stop at
+const (
+	crashBeforePublish = "before_publish"
+	crashBeforeAck     = "before_ack"
+)
+
+// errSimulatedCrash leaves the order being processed pending
+var errSimulatedCrash = errors.New("simulated crash")
+
+// simulatedCrash reports whether the engine is to crash at point, which it
+// does the first time an order reaches the configured point
+func (e *ExecutionEngine) simulatedCrash(point string) bool {
+	if e.config.SimulateCrash != point || !e.crashed.CompareAndSwap(false, true) {
+		return false
+	}
+	log.Printf("Simulating a crash %s, consuming no more orders", point)
+	return true
+}
+
+// ReclaimPending executes the orders this consumer read but never
+// acknowledged, takes over those other consumers have left idle, and returns
+// how many it reclaimed
+func (e *ExecutionEngine) ReclaimPending() (int, error) {
+	reclaimed, err := e.reclaimOwn()
+	if err != nil || e.config.ReclaimMinIdle <= 0 {
+		return reclaimed, err
+	}
+	taken, err := e.takeOverIdle(e.config.ReclaimMinIdle)
+	return reclaimed + taken, err
+}
+
+// reclaimOwn executes the orders this consumer read but never acknowledged
+func (e *ExecutionEngine) reclaimOwn() (int, error) {
+	reclaimed := 0
+	after := "0"
+	for {
+		streams, err := e.redisClient.XReadGroup(e.ctx, &redis.XReadGroupArgs{
+			Group:    e.consumerGroup,
+			Consumer: e.consumerName,
+			Streams:  []string{e.streamName, after},
+			Count:    consumeBatchSize,
+			Block:    -1,
+		}).Result()
+		if err != nil && err != redis.Nil {
+			return reclaimed, err
+		}
+		if len(streams) == 0 || len(streams[0].Messages) == 0 {
+			return reclaimed, nil
+		}
+		for _, message := range streams[0].Messages {
+			after = message.ID
+			if message.Values != nil {
+				if err := e.reclaim(message); err != nil {
+					log.Printf("Leaving reclaimed message %s pending: %v", message.ID, err)
+					continue
+				}
+				reclaimed++
+			}
+			// Entries trimmed from the stream since come back without values
+			e.redisClient.XAck(e.ctx, e.streamName, e.consumerGroup, message.ID)
+		}
+	}
+}
+
+// takeOverIdle claims the orders other consumers have left unacknowledged
+// for at least minIdle and finishes them without executing any twice
+func (e *ExecutionEngine) takeOverIdle(minIdle time.Duration) (int, error) {
+	taken := 0
+	start := "-"
+	for {
+		pending, err := e.redisClient.XPendingExt(e.ctx, &redis.XPendingExtArgs{
+			Stream: e.streamName,
+			Group:  e.consumerGroup,
+			Idle:   minIdle,
+			Start:  start,
+			End:    "+",
+			Count:  consumeBatchSize,
+		}).Result()
+		if err != nil && err != redis.Nil {
+			return taken, err
+		}
+		if len(pending) == 0 {
+			return taken, nil
+		}
+		var ids []string
+		for _, entry := range pending {
+			if entry.Consumer != e.consumerName {
+				ids = append(ids, entry.ID)
+			}
+		}
+		ms, seq := splitStreamID(pending[len(pending)-1].ID)
+		start = fmt.Sprintf("%d-%d", ms, seq+1)
+		if len(ids) == 0 {
+			continue
+		}
+
+		// MinIdle again, so an entry its consumer acknowledged or another
+		// engine claimed in the meantime is left alone
+		messages, err := e.redisClient.XClaim(e.ctx, &redis.XClaimArgs{
+			Stream:   e.streamName,
+			Group:    e.consumerGroup,
+			Consumer: e.consumerName,
+			MinIdle:  minIdle,
+			Messages: ids,
+		}).Result()
+		if err != nil && err != redis.Nil {
+			return taken, err
+		}
+		for _, message := range messages {
+			if message.Values != nil {
+				if err := e.takeOver(message); err != nil {
+					log.Printf("Leaving taken over message %s pending: %v", message.ID, err)
+					continue
+				}
+				taken++
+			}
+			// Entries trimmed from the stream since come back without values
+			e.redisClient.XAck(e.ctx, e.streamName, e.consumerGroup, message.ID)
+		}
+	}
+}
+
+// reclaimLoop takes over orders idle for interval, every interval
+func (e *ExecutionEngine) reclaimLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := e.takeOverIdle(interval); err != nil {
+				log.Printf("Error taking over idle orders: %v", err)
+			} else if n > 0 {
+				log.Printf("Took over %d idle orders", n)
+			}
+		}
+	}
+}
+
+// decodeReclaimed decodes a pending message's order, reporting false when it
+// can't be and belongs in the DLQ
+func (e *ExecutionEngine) decodeReclaimed(message redis.XMessage) (OrderRequest, bool) {
+	payload, _ := message.Values["order"].(string)
+	var order OrderRequest
+	payload, err := decompressPayload(message.Values, payload, e.config.MaxPayloadBytes)
+	var codec PayloadCodec
+	if err == nil {
+		codec, err = entryCodec(message.Values)
+	}
+	if err == nil {
+		err = codec.Unmarshal([]byte(payload), &order)
+	}
+	return order, err == nil && order.OrderID != ""
+}
+
+// republishExecuted publishes the stored response of order again if it was
+// executed, reporting whether it was
+func (e *ExecutionEngine) republishExecuted(order *OrderRequest) bool {
+	stored, ok := e.GetOrder(order.OrderID)
+	if !ok || stored.Status == statusAccepted {
+		return false
+	}
+	log.Printf("Order %s was already executed, publishing its response again", order.OrderID)
+	e.publishResponse(stored)
+	return true
+}
+
+// ownsIdempotencyKey reports whether order's key is claimed by order itself
+func (e *ExecutionEngine) ownsIdempotencyKey(order *OrderRequest) bool {
+	owner, err := e.redisClient.Get(e.ctx, e.idempotencyRedisKey(e.idempotencyKey(order))).Result()
+	return err == nil && owner == order.OrderID
+}
+
+// reclaim finishes processing an order a crash of this consumer interrupted
+func (e *ExecutionEngine) reclaim(message redis.XMessage) error {
+	order, ok := e.decodeReclaimed(message)
+	if !ok {
+		return e.handleMessage(message) // parks it in the DLQ
+	}
+	if e.republishExecuted(&order) {
+		return nil
+	}
+	if order.IdempotencyKey != "" && e.ownsIdempotencyKey(&order) {
+		e.releaseIdempotencyKey(e.ctx, &order)
+	}
+	log.Printf("Reclaimed order %s, executing it", order.OrderID)
+	return e.handleMessage(message)
+}
+
+// takeOver finishes an order taken from a consumer that may still be
+// executing it
+func (e *ExecutionEngine) takeOver(message redis.XMessage) error {
+	order, ok := e.decodeReclaimed(message)
+	if !ok {
+		return e.handleMessage(message) // parks it in the DLQ
+	}
+	if e.republishExecuted(&order) {
+		return nil
+	}
+	if order.IdempotencyKey == "" || e.ownsIdempotencyKey(&order) {
+		log.Printf("Order %s may still be executing with another consumer, routing for reconciliation", order.OrderID)
+		e.sendToReconciliation(&order, reconcileReasonOrphan, nil)
+		return nil
+	}
+	log.Printf("Took over order %s, executing it", order.OrderID)
+	return e.handleMessage(message)
+}
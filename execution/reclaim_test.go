@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// withMockSink routes engine's order updates to a mock sink
+func withMockSink(engine *ExecutionEngine) *mockSink {
+	sink := newMockSink("client", 0)
+	engine.fills = newFillDispatcher(context.Background(), []FillSink{sink}, newFillSinkMetrics(prometheus.NewRegistry()))
+	engine.fills.backoff = 0
+	return sink
+}
+
+// restartEngine starts a new engine process on the same Redis
+func restartEngine(t *testing.T, mr *miniredis.Miniredis) *ExecutionEngine {
+	engine := NewExecutionEngine(mr.Host(), mr.Port(), "test-stream")
+	t.Cleanup(func() { engine.redisClient.Close() })
+	return engine
+}
+
+func pendingCount(t *testing.T, engine *ExecutionEngine) int64 {
+	t.Helper()
+	pending, err := engine.redisClient.XPending(context.Background(), engine.streamName, engine.consumerGroup).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pending.Count
+}
+
+// crashOn processes orderID on an engine set to crash at point, returning
+// what its clients were sent
+func crashOn(t *testing.T, engine *ExecutionEngine, point, orderID string) []OrderResponse {
+	t.Helper()
+	engine.config.SimulateCrash = point
+	sink := withMockSink(engine)
+	queueBatch(t, engine, orderID)
+	engine.consumeBatch()
+	if !engine.crashed.Load() {
+		t.Fatalf("engine didn't crash %s", point)
+	}
+	if engine.consumeBatch() {
+		t.Error("engine kept consuming after the crash")
+	}
+	if n := pendingCount(t, engine); n != 1 {
+		t.Fatalf("%d messages pending after the crash, want 1", n)
+	}
+	engine.fills.close()
+	return sink.delivered
+}
+
+func TestCrashBeforeAckIsReclaimedWithoutReexecuting(t *testing.T) {
+	crashedEngine, mr := newTestEngine(t)
+	if sent := crashOn(t, crashedEngine, crashBeforeAck, "crash-1"); len(sent) != 1 || sent[0].Status != statusFilled {
+		t.Fatalf("sent before the crash: %+v, want the fill", sent)
+	}
+
+	engine := restartEngine(t, mr)
+	sink := withMockSink(engine)
+	if n, err := engine.ReclaimPending(); err != nil || n != 1 {
+		t.Fatalf("ReclaimPending = %d, %v; want 1", n, err)
+	}
+	engine.fills.close()
+
+	if got := testutil.ToFloat64(engine.ordersProcessed); got != 0 {
+		t.Errorf("reclaimed order executed again (%v processed)", got)
+	}
+	if len(sink.delivered) != 1 || sink.delivered[0].OrderID != "crash-1" || sink.delivered[0].Status != statusFilled {
+		t.Errorf("sent after the restart: %+v, want the fill again", sink.delivered)
+	}
+	if n := pendingCount(t, engine); n != 0 {
+		t.Errorf("%d messages still pending", n)
+	}
+}
+
+func TestCrashBeforePublishRedeliversResponse(t *testing.T) {
+	crashedEngine, mr := newTestEngine(t)
+	if sent := crashOn(t, crashedEngine, crashBeforePublish, "crash-2"); len(sent) != 0 {
+		t.Fatalf("sent before the crash: %+v, want nothing", sent)
+	}
+
+	engine := restartEngine(t, mr)
+	sink := withMockSink(engine)
+	if n, err := engine.ReclaimPending(); err != nil || n != 1 {
+		t.Fatalf("ReclaimPending = %d, %v; want 1", n, err)
+	}
+	engine.fills.close()
+
+	if got := testutil.ToFloat64(engine.ordersProcessed); got != 0 {
+		t.Errorf("reclaimed order executed again (%v processed)", got)
+	}
+	if len(sink.delivered) != 1 || sink.delivered[0].OrderID != "crash-2" || sink.delivered[0].Status != statusFilled {
+		t.Errorf("sent after the restart: %+v, want the fill", sink.delivered)
+	}
+}
+
+func TestReclaimedOrderThatNeverExecutedRuns(t *testing.T) {
+	crashedEngine, mr := newTestEngine(t)
+	queueBatch(t, crashedEngine, "crash-3")
+
+	// Read and its idempotency key claimed, then the crash
+	if messages := crashedEngine.readOrders(consumeBatchSize, -1); len(messages) != 1 {
+		t.Fatalf("read %d messages, want 1", len(messages))
+	}
+	order := testOrder("crash-3")
+	mr.Set(crashedEngine.idempotencyRedisKey(crashedEngine.idempotencyKey(&order)), "crash-3")
+
+	engine := restartEngine(t, mr)
+	if n, err := engine.ReclaimPending(); err != nil || n != 1 {
+		t.Fatalf("ReclaimPending = %d, %v; want 1", n, err)
+	}
+	if response, ok := engine.GetOrder("crash-3"); !ok || response.Status != statusFilled {
+		t.Errorf("reclaimed order: %+v, want filled", response)
+	}
+	if n := pendingCount(t, engine); n != 0 {
+		t.Errorf("%d messages still pending", n)
+	}
+}
+
+// readAsConsumer reads orderID as consumer, claiming its idempotency key as
+// its execution would, and leaves it pending there
+func readAsConsumer(t *testing.T, engine *ExecutionEngine, mr *miniredis.Miniredis, consumer, orderID string) {
+	t.Helper()
+	engine.consumerName = consumer
+	queueBatch(t, engine, orderID)
+	if messages := engine.readOrders(consumeBatchSize, -1); len(messages) != 1 {
+		t.Fatalf("read %d messages, want 1", len(messages))
+	}
+	order := testOrder(orderID)
+	mr.Set(engine.idempotencyRedisKey(engine.idempotencyKey(&order)), orderID)
+}
+
+func TestReclaimLeavesOtherConsumersInFlightOrders(t *testing.T) {
+	busyEngine, mr := newTestEngine(t)
+	mr.SetTime(time.Unix(1700000000, 0))
+	readAsConsumer(t, busyEngine, mr, "engine-a", "inflight-1")
+
+	engine := restartEngine(t, mr)
+	engine.consumerName = "engine-b"
+	if n, err := engine.ReclaimPending(); err != nil || n != 0 {
+		t.Fatalf("ReclaimPending = %d, %v; want 0", n, err)
+	}
+	if _, ok := engine.GetOrder("inflight-1"); ok {
+		t.Error("another consumer's in-flight order was executed")
+	}
+	order := testOrder("inflight-1")
+	if !engine.ownsIdempotencyKey(&order) {
+		t.Error("in-flight order's idempotency key was released")
+	}
+	if lags, _ := engine.consumerLags(context.Background()); len(lags) != 1 || lags[0].Consumer != "engine-a" {
+		t.Errorf("pending: %+v, want it left with engine-a", lags)
+	}
+}
+
+func TestIdleOrderTakenOverIsReconciledNotReexecuted(t *testing.T) {
+	stalledEngine, mr := newTestEngine(t)
+	mr.SetTime(time.Unix(1700000000, 0))
+	readAsConsumer(t, stalledEngine, mr, "engine-a", "stalled-1")
+
+	engine := restartEngine(t, mr)
+	engine.consumerName = "engine-b"
+	mr.SetTime(time.Unix(1700000000, 0).Add(engine.config.ReclaimMinIdle + time.Second))
+	if n, err := engine.ReclaimPending(); err != nil || n != 1 {
+		t.Fatalf("ReclaimPending = %d, %v; want 1", n, err)
+	}
+
+	if _, ok := engine.GetOrder("stalled-1"); ok {
+		t.Error("order its stalled consumer may still execute was executed again")
+	}
+	order := testOrder("stalled-1")
+	if !engine.ownsIdempotencyKey(&order) {
+		t.Error("taken over order's idempotency key was released")
+	}
+	entries := engine.redisClient.XRange(context.Background(), engine.reconcileStreamName, "-", "+").Val()
+	if len(entries) != 1 || entries[0].Values["reason"] != reconcileReasonOrphan {
+		t.Errorf("reconciliation entries: %v, want the orphaned order", entries)
+	}
+	if n := pendingCount(t, engine); n != 0 {
+		t.Errorf("%d messages still pending", n)
+	}
+}
+
+func TestIdleOrderThatNeverClaimedItsKeyIsTakenOverAndRuns(t *testing.T) {
+	deadEngine, mr := newTestEngine(t)
+	mr.SetTime(time.Unix(1700000000, 0))
+	deadEngine.consumerName = "engine-a"
+	queueBatch(t, deadEngine, "dead-1")
+	if messages := deadEngine.readOrders(consumeBatchSize, -1); len(messages) != 1 {
+		t.Fatalf("read %d messages, want 1", len(messages))
+	}
+
+	engine := restartEngine(t, mr)
+	engine.consumerName = "engine-b"
+	mr.SetTime(time.Unix(1700000000, 0).Add(engine.config.ReclaimMinIdle + time.Second))
+	if n, err := engine.ReclaimPending(); err != nil || n != 1 {
+		t.Fatalf("ReclaimPending = %d, %v; want 1", n, err)
+	}
+	if response, ok := engine.GetOrder("dead-1"); !ok || response.Status != statusFilled {
+		t.Errorf("taken over order: %+v, want filled", response)
+	}
+}
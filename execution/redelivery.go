@@ -0,0 +1,121 @@
+// ==============================================================================
+// Pending deliveries - order updates a fill sink couldn't take yet
+// ==============================================================================
+// When a sink still fails after FILL_SINK_MAX_ATTEMPTS publishes, or its
+// queue is full when the update arrives, the update is parked in the
+// "<stream>.pending_delivery" stream, tagged with the sink it is for, instead
+// of being dropped. Every FILL_REDELIVERY_INTERVAL the parked
+// updates are offered to their sinks again, once each, and removed when a
+// publish succeeds, so a client learns of its fill once the sink recovers.
+// A round pages through the whole stream, redeliveryBatch entries at a time,
+// and stops offering updates to a sink for the rest of the round once it
+// fails, so a sink still down costs one publish per round rather than one
+// per parked update.
+// Redelivered updates arrive after newer ones for the same order; consumers
+// should order updates by status, not arrival.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// redeliveryBatch is how many parked updates a redelivery round reads at a
+// time
+const redeliveryBatch = 100
+
+// pendingDeliveries stores updates whose delivery to a sink was given up on
+type pendingDeliveries struct {
+	client redis.UniversalClient
+	stream string
+}
+
+// park stores an update for later redelivery to sink
+func (p *pendingDeliveries) park(ctx context.Context, sink string, response *OrderResponse) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.stream,
+		Values: map[string]interface{}{"sink": sink, "response": data},
+	}).Err()
+}
+
+// redeliver offers each parked update to its sink once, removing the ones
+// that were delivered, and returns how many were
+func (d *fillDispatcher) redeliver(ctx context.Context) (int, error) {
+	if d.pending == nil {
+		return 0, nil
+	}
+	sinks := make(map[string]FillSink, len(d.sinks))
+	for _, sink := range d.sinks {
+		sinks[sink.Name()] = sink
+	}
+	failing := make(map[string]bool)
+
+	delivered := 0
+	start := "-"
+	for {
+		entries, err := d.pending.client.XRangeN(ctx, d.pending.stream, start, "+", redeliveryBatch).Result()
+		if err != nil {
+			return delivered, err
+		}
+		for _, entry := range entries {
+			name, _ := entry.Values["sink"].(string)
+			if failing[name] {
+				continue
+			}
+			payload, _ := entry.Values["response"].(string)
+			var response OrderResponse
+			sink, ok := sinks[name]
+			if !ok || json.Unmarshal([]byte(payload), &response) != nil {
+				log.Printf("Discarding undeliverable parked update %s for sink %q", entry.ID, name)
+				d.pending.client.XDel(ctx, d.pending.stream, entry.ID)
+				continue
+			}
+
+			publishCtx, cancel := context.WithTimeout(ctx, fillSinkPublishTimeout)
+			err := sink.Publish(publishCtx, &response)
+			cancel()
+			if err != nil {
+				d.metrics.failures.WithLabelValues(name).Inc()
+				failing[name] = true
+				continue
+			}
+			d.pending.client.XDel(ctx, d.pending.stream, entry.ID)
+			delivered++
+		}
+		if len(entries) < redeliveryBatch {
+			return delivered, nil
+		}
+		// Continue after the last entry read (exclusive bound)
+		start = "(" + entries[len(entries)-1].ID
+	}
+}
+
+// redeliveryLoop periodically redelivers parked updates until ctx is
+// cancelled
+func (d *fillDispatcher) redeliveryLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			delivered, err := d.redeliver(ctx)
+			if err != nil {
+				log.Printf("Error redelivering parked order updates: %v", err)
+			} else if delivered > 0 {
+				log.Printf("Redelivered %d parked order updates", delivered)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestHashTag(t *testing.T) {
+	for key, want := range map[string]string{
+		"execution.orders":          "execution.orders",
+		"{execution.orders}.dlq":    "execution.orders",
+		"{execution.orders}.orders": "execution.orders",
+		"{}.dlq":                    "{}.dlq",
+		"no{close":                  "no{close",
+		"a{b}{c}":                   "b",
+	} {
+		if got := hashTag(key); got != want {
+			t.Errorf("hashTag(%q) = %q, want %q", key, got, want)
+		}
+	}
+
+	if got := redisKeyPrefix("execution.orders", false); got != "execution.orders" {
+		t.Errorf("single-node prefix = %q", got)
+	}
+	if got := redisKeyPrefix("execution.orders", true); got != "{execution.orders}" {
+		t.Errorf("cluster prefix = %q", got)
+	}
+	if got := redisKeyPrefix("{orders}.v2", true); got != "{orders}.v2" {
+		t.Errorf("cluster prefix of a tagged stream = %q", got)
+	}
+}
+
+// TestClusterKeysShareTheStreamSlot drives an engine through a cluster client
+// (miniredis answers CLUSTER SLOTS as a one-node cluster) and checks every key
+// it writes hashes to the same slot as the order stream
+func TestClusterKeysShareTheStreamSlot(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := DefaultConfig()
+	cfg.RedisClusterAddrs = mr.Addr()
+	cfg.StreamName = "test-stream"
+	engine := NewExecutionEngineFromConfig(cfg)
+	t.Cleanup(func() { engine.redisClient.Close() })
+
+	if _, ok := engine.redisClient.(*redis.ClusterClient); !ok {
+		t.Fatalf("expected a cluster client, got %T", engine.redisClient)
+	}
+	if engine.dlqStreamName != "{test-stream}.dlq" {
+		t.Errorf("DLQ stream = %q, want it tagged with the stream name", engine.dlqStreamName)
+	}
+
+	ctx := context.Background()
+	queued := testOrder("queued-1")
+	if err := engine.SubmitOrder(ctx, &queued); err != nil {
+		t.Fatal(err)
+	}
+	keyed := testOrder("keyed-1")
+	keyed.IdempotencyKey = "client-key"
+	submitToEngine(t, engine, &keyed)
+	submitToEngine(t, engine, limitOrder("rest-1", "AAPL", "sell", 100, 10))
+	submitToEngine(t, engine, &OrderRequest{OrderID: "bad-1", Symbol: "AAPL", Side: "hold"})
+	if err := engine.SnapshotBooks(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := mr.Keys()
+	for _, want := range []string{"test-stream", "{test-stream}.dlq", "{test-stream}.orders", "{test-stream}.book.snapshot", "{test-stream}.idempotency.client-key"} {
+		if !mr.Exists(want) {
+			t.Errorf("expected key %q, have %v", want, keys)
+		}
+	}
+	for _, key := range keys {
+		if hashTag(key) != "test-stream" {
+			t.Errorf("key %q hashes to a different slot than the stream", key)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// ==============================================================================
+// Redis pool metrics - connection pool stats of the Redis client
+// ==============================================================================
+// The client keeps up to REDIS_POOL_SIZE connections (per node in a cluster)
+// and at least REDIS_MIN_IDLE_CONNS of them open while idle. Its pool stats
+// are read on every scrape and exported as:
+//
+//   redis_pool_total_conns     connections open, idle or in use
+//   redis_pool_idle_conns      connections open and idle
+//   redis_pool_stale_conns     stale connections closed so far
+//   redis_pool_hits_total      times an idle connection was free to use
+//   redis_pool_misses_total    times none was and one had to be opened
+//   redis_pool_timeouts_total  times a caller gave up waiting for a
+//                              connection (pool starvation)
+//
+// A steady rise in timeouts, or total conns pinned at the pool size with no
+// idle ones, means the pool is too small for the load. The client doesn't
+// track how often or how long callers wait short of a timeout.
+// ==============================================================================
+
+package main
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolStatser is the part of the Redis client the pool metrics need
+type poolStatser interface {
+	PoolStats() *redis.PoolStats
+}
+
+// poolStatsCollector exports a Redis client's pool stats
+type poolStatsCollector struct {
+	client poolStatser
+
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+}
+
+func newPoolStatsCollector(client poolStatser) *poolStatsCollector {
+	return &poolStatsCollector{
+		client:     client,
+		totalConns: prometheus.NewDesc("redis_pool_total_conns", "Connections in the Redis client pool, idle or in use", nil, nil),
+		idleConns:  prometheus.NewDesc("redis_pool_idle_conns", "Idle connections in the Redis client pool", nil, nil),
+		staleConns: prometheus.NewDesc("redis_pool_stale_conns", "Stale connections removed from the Redis client pool", nil, nil),
+		hits:       prometheus.NewDesc("redis_pool_hits_total", "Times a free connection was found in the Redis client pool", nil, nil),
+		misses:     prometheus.NewDesc("redis_pool_misses_total", "Times no free connection was found in the Redis client pool", nil, nil),
+		timeouts:   prometheus.NewDesc("redis_pool_timeouts_total", "Times waiting for a Redis client pool connection timed out", nil, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *poolStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{c.totalConns, c.idleConns, c.staleConns, c.hits, c.misses, c.timeouts} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *poolStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.staleConns, prometheus.GaugeValue, float64(stats.StaleConns))
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+}
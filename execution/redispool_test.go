@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+)
+
+// gaugeValue returns the value of an unlabeled gauge or counter in the
+// engine's registry
+func gaugeValue(t *testing.T, engine *ExecutionEngine, name string) (float64, bool) {
+	t.Helper()
+	families, err := engine.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name || len(family.GetMetric()) == 0 {
+			continue
+		}
+		metric := family.GetMetric()[0]
+		if metric.GetGauge() != nil {
+			return metric.GetGauge().GetValue(), true
+		}
+		return metric.GetCounter().GetValue(), true
+	}
+	return 0, false
+}
+
+func TestRedisPoolStatsAreExported(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	for _, id := range []string{"pool-1", "pool-2", "pool-3"} {
+		order := testOrder(id)
+		submitToEngine(t, engine, &order)
+	}
+
+	for _, name := range []string{"redis_pool_total_conns", "redis_pool_idle_conns", "redis_pool_stale_conns", "redis_pool_hits_total", "redis_pool_misses_total", "redis_pool_timeouts_total"} {
+		if _, ok := gaugeValue(t, engine, name); !ok {
+			t.Errorf("%s not exported", name)
+		}
+	}
+	stats := engine.redisClient.PoolStats()
+	if total, _ := gaugeValue(t, engine, "redis_pool_total_conns"); total < 1 || total != float64(stats.TotalConns) {
+		t.Errorf("redis_pool_total_conns = %v, want the pool's %d, at least 1", total, stats.TotalConns)
+	}
+	if hits, _ := gaugeValue(t, engine, "redis_pool_hits_total"); hits < 1 {
+		t.Errorf("redis_pool_hits_total = %v after several commands, want some reuse", hits)
+	}
+}
@@ -0,0 +1,72 @@
+// ==============================================================================
+// Reduce-only orders - orders that may only shrink a position
+// ==============================================================================
+// An order with reduce_only may close some or all of the account's position
+// in its symbol but never open or add to one. When it executes, its quantity
+// is capped at what is left to close: the open position on the other side,
+// less the quantity of the account's reduce-only orders already working in
+// the book on the same side, so several of them can't together close more
+// than the position. In hedge mode (see positions.go) the position is the
+// leg on the other side: a reduce-only sell closes the long leg and a buy the
+// short one. An order with nothing left to close - a flat position,
+// one on the order's own side, or one already covered by working orders - is
+// rejected with "would_increase_position".
+//
+// The cap is taken when the order executes, so a stop or good-after-time
+// reduce-only order is capped against the position it triggers into. A
+// working reduce-only order isn't re-capped if the position later shrinks by
+// other means.
+// ==============================================================================
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// rejectWouldIncreasePosition is the reason for reduce-only orders with
+// nothing to reduce
+const rejectWouldIncreasePosition = "would_increase_position"
+
+// workingReduceOnly is the quantity of account's reduce-only orders working
+// on side of symbol's book
+func (e *ExecutionEngine) workingReduceOnly(account string, symbol string, side string) decimal.Decimal {
+	var total decimal.Decimal
+	book, ok := e.lookupBook(symbol)
+	if !ok {
+		return total
+	}
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	for _, order := range book.orders {
+		if order.ReduceOnly && order.AccountID == account && order.Side == side {
+			total = total.Add(order.Quantity).Add(order.Reserve)
+		}
+	}
+	return total
+}
+
+// capReduceOnly cuts a reduce-only order down to the position it can still
+// close, refusing it if that is nothing
+func (e *ExecutionEngine) capReduceOnly(order *OrderRequest) *rejection {
+	if !order.ReduceOnly {
+		return nil
+	}
+	open := e.positions.Closable(order.AccountID, order.Symbol, order.Side)
+
+	working := e.workingReduceOnly(order.AccountID, order.Symbol, order.Side)
+
+	closable := open.Sub(working)
+	if !closable.IsPositive() {
+		return &rejection{
+			Reason: rejectWouldIncreasePosition,
+			Detail: fmt.Sprintf("%s of the position in %s closable, %s already being closed", decimal.Max(open, decimal.Zero), order.Symbol, working),
+		}
+	}
+	if order.Quantity.GreaterThan(closable) {
+		order.Quantity = closable
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+// openLong gives acct-1 a long position of qty AAPL
+func openLong(t *testing.T, engine *ExecutionEngine, qty float64) {
+	t.Helper()
+	buy := testOrder("open-long")
+	buy.AccountID = "acct-1"
+	buy.Quantity = dec(qty)
+	submitToEngine(t, engine, &buy)
+}
+
+// reduceOnlySell is a reduce-only limit sell for acct-1
+func reduceOnlySell(id string, price float64, qty float64) *OrderRequest {
+	sell := limitOrder(id, "AAPL", "sell", price, qty)
+	sell.AccountID = "acct-1"
+	sell.ReduceOnly = true
+	return sell
+}
+
+func TestReduceOnlyCappedToPosition(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	openLong(t, engine, 10)
+
+	submitToEngine(t, engine, reduceOnlySell("close", 120, 25))
+
+	resting, ok := engine.bookFor("AAPL").Get("close")
+	if !ok || !resting.Quantity.Equal(dec(10)) {
+		t.Fatalf("reduce-only sell of 25 against a long 10 should rest 10, got %+v", resting)
+	}
+
+	// Another reduce-only sell has nothing left to close
+	submitToEngine(t, engine, reduceOnlySell("close-again", 121, 5))
+	if response, _ := engine.GetOrder("close-again"); response.RejectReason != rejectWouldIncreasePosition {
+		t.Errorf("second reduce-only sell: %s/%s, want %s", response.Status, response.RejectReason, rejectWouldIncreasePosition)
+	}
+
+	// Filling the first closes the position exactly
+	buyer := limitOrder("buyer", "AAPL", "buy", 120, 50)
+	buyer.AccountID = "acct-2"
+	submitToEngine(t, engine, buyer)
+	if position, _ := engine.positions.Get("acct-1", "AAPL"); !position.Quantity.IsZero() {
+		t.Errorf("position after the reduce-only fill = %s, want flat", position.Quantity)
+	}
+}
+
+func TestReduceOnlyRejectedWhenFlatOrSameSide(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	submitToEngine(t, engine, reduceOnlySell("flat", 100, 5))
+	if response, _ := engine.GetOrder("flat"); response.Status != statusRejected || response.RejectReason != rejectWouldIncreasePosition {
+		t.Errorf("reduce-only sell on a flat position: %s/%s, want %s", response.Status, response.RejectReason, rejectWouldIncreasePosition)
+	}
+
+	openLong(t, engine, 10)
+	buy := testOrder("add")
+	buy.AccountID = "acct-1"
+	buy.ReduceOnly = true
+	submitToEngine(t, engine, &buy)
+	if response, _ := engine.GetOrder("add"); response.RejectReason != rejectWouldIncreasePosition {
+		t.Errorf("reduce-only buy on a long position: %s/%s, want %s", response.Status, response.RejectReason, rejectWouldIncreasePosition)
+	}
+}
+
+func TestReduceOnlyMarketOrderClosesShort(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	sell := testOrder("open-short")
+	sell.AccountID = "acct-1"
+	sell.Side = "sell"
+	sell.Quantity = dec(4)
+	submitToEngine(t, engine, &sell)
+
+	cover := testOrder("cover")
+	cover.AccountID = "acct-1"
+	cover.ReduceOnly = true
+	submitToEngine(t, engine, &cover)
+
+	if response, _ := engine.GetOrder("cover"); response.Status != statusFilled || !response.FilledQuantity.Equal(dec(4)) {
+		t.Errorf("reduce-only buy of 10 against a short 4: %s %s, want filled 4", response.Status, response.FilledQuantity)
+	}
+}
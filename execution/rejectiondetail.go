@@ -0,0 +1,74 @@
+// ==============================================================================
+// Rejection detail - what a client needs to adapt to a refusal
+// ==============================================================================
+// A reason says why an order was refused, not how to get the next one
+// through. Refusals for reaching a limit also carry the limit and the current
+// usage (risk_limit), and refusals that clear with time carry how long to
+// wait (retry_after_ms):
+//
+//   too_many_open_orders    risk_limit max_open_orders_per_account
+//   order_too_large         risk_limit max_order_quantity
+//   insufficient_liquidity  risk_limit min_fill_quantity: the quantity the
+//                           min fill ratio requires and what is available
+//   broker_unavailable      retry_after_ms until the circuit breaker admits
+//                           a probe
+//   notional_velocity_exceeded
+//                           risk_limit max_notional_per_window, and
+//                           retry_after_ms until the order would fit
+//   market_closed           retry_after_ms until the market opens
+//   price_out_of_band       risk_limit price_band: the edge of the band the
+//                           limit price is past, and the limit price
+//   backpressure (503)      retry_after_ms, as well as Retry-After
+//
+// Rejected orders carry the detail on their OrderResponse and rejection audit
+// record, and orders parked in the DLQ on their DLQ entry ("risk_limit" as
+// JSON and "retry_after_ms"). Submissions refused outright carry it in the
+// HTTP error body.
+// ==============================================================================
+
+package main
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Names of the limits a RiskLimit reports
+const (
+	riskLimitOpenOrders       = "max_open_orders_per_account"
+	riskLimitMinFillQuantity  = "min_fill_quantity"
+	riskLimitOrderQuantity    = "max_order_quantity"
+	riskLimitNotionalVelocity = "max_notional_per_window"
+	riskLimitPriceBand        = "price_band"
+)
+
+// RiskLimit is a limit an order ran into and where the account stands
+// against it
+type RiskLimit struct {
+	Name    string          `json:"name"`
+	Limit   decimal.Decimal `json:"limit"`
+	Current decimal.Decimal `json:"current"`
+}
+
+// retryAfterMs converts a wait to whole milliseconds, rounding up so clients
+// never retry early
+func retryAfterMs(d time.Duration) int64 {
+	return int64((d + time.Millisecond - 1) / time.Millisecond)
+}
+
+// retryAfter returns how long until the breaker admits an order again: the
+// rest of its open timeout, or a whole timeout while a probe is in flight
+func (b *circuitBreaker) retryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case circuitOpen:
+		if wait := b.openTimeout - b.now().Sub(b.openedAt); wait > 0 {
+			return wait
+		}
+	case circuitHalfOpen:
+		return b.openTimeout
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRiskRejectionCarriesLimitAndUsage(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	setRiskLimits(engine, func(l *RiskLimits) { l.MaxOpenOrdersPerAccount = 2 })
+	for _, id := range []string{"bid-1", "bid-2", "bid-3"} {
+		bid := limitOrder(id, "AAPL", "buy", 99, 10)
+		bid.AccountID = "acct-1"
+		submitToEngine(t, engine, bid)
+	}
+
+	want := RiskLimit{Name: riskLimitOpenOrders, Limit: dec(2), Current: dec(2)}
+	response, _ := engine.GetOrder("bid-3")
+	if !sameRiskLimit(response.RiskLimit, want) {
+		t.Errorf("response risk limit = %+v, want %+v", response.RiskLimit, want)
+	}
+
+	records, err := engine.ListRejections(context.Background(), RejectionFilter{Reason: rejectTooManyOpenOrders})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("audited rejections: %v, %v", records, err)
+	}
+	if !sameRiskLimit(records[0].RiskLimit, want) {
+		t.Errorf("audit record risk limit = %+v, want %+v", records[0].RiskLimit, want)
+	}
+}
+
+func sameRiskLimit(got *RiskLimit, want RiskLimit) bool {
+	return got != nil && got.Name == want.Name && got.Limit.Equal(want.Limit) && got.Current.Equal(want.Current)
+}
+
+func TestLiquidityRejectionCarriesRequiredAndAvailable(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	ask := limitOrder("ask-1", "AAPL", "sell", 100, 3)
+	ask.AccountID = "acct-seller"
+	submitToEngine(t, engine, ask)
+
+	order := testOrder("buy-1")
+	order.MinFillRatio = 0.5
+	submitToEngine(t, engine, &order)
+
+	response, _ := engine.GetOrder("buy-1")
+	if want := (RiskLimit{Name: riskLimitMinFillQuantity, Limit: dec(5), Current: dec(3)}); !sameRiskLimit(response.RiskLimit, want) {
+		t.Errorf("risk limit = %+v, want %+v", response.RiskLimit, want)
+	}
+}
+
+func TestBrokerUnavailableRejectionCarriesRetryAfter(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+	engine.circuit = newCircuitBreaker(1, 10*time.Second, prometheus.NewRegistry())
+	engine.circuit.now = engine.now
+	engine.circuit.failure()
+	clock.advance(4 * time.Second)
+
+	order := testOrder("down-1")
+	submitToEngine(t, engine, &order)
+
+	if response, _ := engine.GetOrder("down-1"); response.RetryAfterMs != 6000 {
+		t.Errorf("response retry_after_ms = %d, want 6000", response.RetryAfterMs)
+	}
+	entries, err := engine.redisClient.XRange(engine.ctx, engine.dlqStreamName, "-", "+").Result()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("DLQ entries: %v, %v", entries, err)
+	}
+	if got := entries[0].Values["retry_after_ms"]; got != "6000" {
+		t.Errorf("DLQ retry_after_ms = %v, want 6000", got)
+	}
+}
@@ -0,0 +1,174 @@
+// ==============================================================================
+// Rejection audit - queryable trail of refused orders
+// ==============================================================================
+// Every business rejection (failed validation, instrument or symbol rules,
+// unsupported types, liquidity checks, an unavailable broker) is appended to
+// the "<stream>.rejections" stream with a snapshot of the order, the reason
+// and the account. Entry IDs are millisecond timestamps, so writes trim
+// entries older than REJECTION_AUDIT_TTL and time filters map straight onto
+// XRANGE bounds. Unlike the DLQ, nothing here is meant to be replayed.
+//
+// GET /rejections?reason=&account=&since=&until=&limit= returns matching
+// rejections, newest first.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// rejectionScanBatch is how many audit entries a query reads per round trip
+const rejectionScanBatch = 500
+
+// RejectionRecord is one audited rejection
+type RejectionRecord struct {
+	ID        string        `json:"id"`
+	OrderID   string        `json:"order_id"`
+	AccountID string        `json:"account_id,omitempty"`
+	Symbol    string        `json:"symbol,omitempty"`
+	Reason    string        `json:"reason"`
+	Detail    string        `json:"detail,omitempty"`
+	Timestamp int64         `json:"timestamp"` // unix ms
+	Order     *OrderRequest `json:"order"`
+
+	RiskLimit    *RiskLimit `json:"risk_limit,omitempty"`
+	RetryAfterMs int64      `json:"retry_after_ms,omitempty"`
+}
+
+// RejectionFilter narrows an audit query. Zero values match everything.
+type RejectionFilter struct {
+	Reason    string
+	AccountID string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// auditRejection appends a rejected order to the audit trail and its
+// lifecycle audit
+func (e *ExecutionEngine) auditRejection(order *OrderRequest, rej *rejection) {
+	e.auditOrder(order, auditRejected, rej.Reason)
+	now := e.now()
+	record := RejectionRecord{
+		OrderID:      order.OrderID,
+		AccountID:    order.AccountID,
+		Symbol:       order.Symbol,
+		Reason:       rej.Reason,
+		Detail:       rej.Detail,
+		Timestamp:    now.UnixMilli(),
+		Order:        order,
+		RiskLimit:    rej.Limit,
+		RetryAfterMs: retryAfterMs(rej.RetryAfter),
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Error encoding rejection of order %s for audit: %v", order.OrderID, err)
+		return
+	}
+
+	args := &redis.XAddArgs{
+		Stream: e.rejectionStreamName,
+		Values: map[string]interface{}{"record": data},
+	}
+	if ttl := e.config.RejectionAuditTTL; ttl > 0 {
+		args.MinID = strconv.FormatInt(now.Add(-ttl).UnixMilli(), 10)
+		args.Approx = true
+	}
+	if err := e.redisClient.XAdd(e.ctx, args).Err(); err != nil {
+		log.Printf("Error auditing rejection of order %s: %v", order.OrderID, err)
+	}
+}
+
+// ListRejections returns audited rejections matching filter, newest first
+func (e *ExecutionEngine) ListRejections(ctx context.Context, filter RejectionFilter) ([]RejectionRecord, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultOrderPageSize
+	}
+	if filter.Limit > maxOrderPageSize {
+		filter.Limit = maxOrderPageSize
+	}
+
+	start, end := "-", "+"
+	if !filter.Since.IsZero() {
+		start = strconv.FormatInt(filter.Since.UnixMilli(), 10)
+	}
+	if !filter.Until.IsZero() {
+		end = strconv.FormatInt(filter.Until.UnixMilli(), 10)
+	}
+
+	records := []RejectionRecord{}
+	for len(records) < filter.Limit {
+		entries, err := e.redisClient.XRevRangeN(ctx, e.rejectionStreamName, end, start, rejectionScanBatch).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			payload, _ := entry.Values["record"].(string)
+			var record RejectionRecord
+			if err := json.Unmarshal([]byte(payload), &record); err != nil {
+				log.Printf("Error decoding audited rejection %s: %v", entry.ID, err)
+				continue
+			}
+			if (filter.Reason != "" && record.Reason != filter.Reason) ||
+				(filter.AccountID != "" && record.AccountID != filter.AccountID) {
+				continue
+			}
+			record.ID = entry.ID
+			records = append(records, record)
+			if len(records) == filter.Limit {
+				break
+			}
+		}
+		if len(entries) < rejectionScanBatch {
+			break
+		}
+		// Continue below the oldest entry read (exclusive bound)
+		end = "(" + entries[len(entries)-1].ID
+	}
+	return records, nil
+}
+
+// handleListRejections serves GET /rejections?reason=&account=&since=&until=&limit=
+func (e *ExecutionEngine) handleListRejections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := RejectionFilter{
+		Reason:    query.Get("reason"),
+		AccountID: query.Get("account"),
+	}
+
+	var err error
+	if filter.Since, err = parseTimeParam(query.Get("since")); err != nil {
+		writeFieldError(w, "since", "Invalid since")
+		return
+	}
+	if filter.Until, err = parseTimeParam(query.Get("until")); err != nil {
+		writeFieldError(w, "until", "Invalid until")
+		return
+	}
+	if raw := query.Get("limit"); raw != "" {
+		if filter.Limit, err = strconv.Atoi(raw); err != nil || filter.Limit <= 0 {
+			writeFieldError(w, "limit", "Invalid limit")
+			return
+		}
+	}
+
+	records, err := e.ListRejections(r.Context(), filter)
+	if err != nil {
+		writeError(w, errCodeInternal, "Failed to list rejections")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"rejections": records})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRejectionsAudited(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	policy, _ := parseSymbolPolicy("", "TSLA")
+	engine.SetSymbolPolicy(policy)
+
+	// A risk rule and a validation failure, from two accounts
+	risky := testOrder("risk-1")
+	risky.Symbol = "TSLA"
+	risky.AccountID = "acct-1"
+	submitToEngine(t, engine, &risky)
+	invalid := testOrder("invalid-1")
+	invalid.Side = "hold"
+	invalid.AccountID = "acct-2"
+	submitToEngine(t, engine, &invalid)
+	filled := testOrder("ok-1")
+	submitToEngine(t, engine, &filled)
+
+	query := func(params string) []RejectionRecord {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		engine.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rejections"+params, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /rejections%s: %d %s", params, rec.Code, rec.Body)
+		}
+		var body struct {
+			Rejections []RejectionRecord `json:"rejections"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Rejections
+	}
+
+	all := query("")
+	if len(all) != 2 || all[0].OrderID != "invalid-1" || all[1].OrderID != "risk-1" {
+		t.Fatalf("expected both rejections newest first, got %+v", all)
+	}
+	if all[0].Reason != dlqReasonValidation || all[0].Detail == "" || all[0].Order == nil || all[0].Order.Side != "hold" {
+		t.Errorf("validation rejection recorded as %+v", all[0])
+	}
+	if all[1].Reason != rejectSymbolNotPermitted || all[1].AccountID != "acct-1" || all[1].Timestamp == 0 {
+		t.Errorf("risk rejection recorded as %+v", all[1])
+	}
+
+	if got := query("?reason=" + rejectSymbolNotPermitted); len(got) != 1 || got[0].OrderID != "risk-1" {
+		t.Errorf("reason filter: got %+v", got)
+	}
+	if got := query("?account=acct-2"); len(got) != 1 || got[0].OrderID != "invalid-1" {
+		t.Errorf("account filter: got %+v", got)
+	}
+	if got := query("?until=1"); len(got) != 0 {
+		t.Errorf("time filter: got %+v", got)
+	}
+	if got := query("?limit=1"); len(got) != 1 || got[0].OrderID != "invalid-1" {
+		t.Errorf("limit: got %+v", got)
+	}
+}
@@ -0,0 +1,262 @@
+// ==============================================================================
+// Reloading - change risk limits and symbol permissions without a restart
+// ==============================================================================
+// SIGHUP or POST /admin/reload re-reads the configuration - the environment,
+// and CONFIG_FILE if set - and swaps in its risk limits and symbol lists:
+//
+//   MAX_ORDER_QUANTITY, MAX_OPEN_ORDERS_PER_ACCOUNT, BOOK_MAX_ORDERS,
+//   BOOK_MAX_ORDERS_PER_SYMBOL, BOOK_FULL_POLICY, MIN_FILL_RATIOS,
+//   DUPLICATE_ORDER_WINDOW, DUPLICATE_ORDER_WINDOWS, DUPLICATE_ORDER_POLICY,
+//   PRICE_COLLAR_PCT, PRICE_COLLARS, PRICE_BAND_PCT, PRICE_BANDS,
+//   MAX_NOTIONAL_PER_WINDOW, NOTIONAL_WINDOW, SYMBOL_ALLOWLIST,
+//   SYMBOL_DENYLIST
+//
+// Every other setting still needs a restart. CONFIG_FILE is a file of
+// KEY=VALUE lines (blank lines and # comments are ignored) for settings that
+// must change while the process runs; variables set in the environment take
+// precedence over it. A configuration with an invalid limit or symbol list
+// is refused as a whole and the active settings stay in place.
+//
+// The limits are one immutable RiskLimits value behind an atomic pointer.
+// Reloads swap it while holding batchMu, like Pause, so an order executing
+// from the stream is checked against one set of limits from start to end,
+// and nothing in flight is dropped. The symbol lists replace those set with
+// PUT /symbols.
+// ==============================================================================
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// rejectOrderTooLarge is the reason for orders over MAX_ORDER_QUANTITY
+const rejectOrderTooLarge = "order_too_large"
+
+// RiskLimits are the reloadable limits orders are checked against. A value
+// is never changed once published; reloads replace it.
+type RiskLimits struct {
+	MaxOrderQuantity        decimal.Decimal          `json:"max_order_quantity"`
+	MaxOpenOrdersPerAccount int                      `json:"max_open_orders_per_account"`
+	BookMaxOrders           int                      `json:"book_max_orders"`
+	BookMaxOrdersPerSymbol  int                      `json:"book_max_orders_per_symbol"`
+	BookFullPolicy          string                   `json:"book_full_policy"`
+	MinFillRatios           map[string]float64       `json:"min_fill_ratios"`
+	DuplicateOrderWindow    time.Duration            `json:"duplicate_order_window"`
+	DuplicateOrderWindows   map[string]time.Duration `json:"duplicate_order_windows"`
+	DuplicateOrderPolicy    string                   `json:"duplicate_order_policy"`
+	PriceCollarPct          float64                  `json:"price_collar_pct"`
+	PriceCollars            map[string]float64       `json:"price_collars"`
+	PriceBandPct            float64                  `json:"price_band_pct"`
+	PriceBands              map[string]float64       `json:"price_bands"`
+	MaxNotionalPerWindow    decimal.Decimal          `json:"max_notional_per_window"`
+	NotionalWindow          time.Duration            `json:"notional_window"`
+}
+
+// newRiskLimits builds the limits of cfg. Invalid values are reported in the
+// error and left at their zero value, which disables them.
+func newRiskLimits(cfg Config) (*RiskLimits, error) {
+	var errs []error
+	limits := &RiskLimits{
+		MaxOpenOrdersPerAccount: cfg.MaxOpenOrdersPerAccount,
+		BookMaxOrders:           cfg.BookMaxOrders,
+		BookMaxOrdersPerSymbol:  cfg.BookMaxOrdersPerSymbol,
+		BookFullPolicy:          cfg.BookFullPolicy,
+		DuplicateOrderWindow:    cfg.DuplicateOrderWindow,
+		DuplicateOrderPolicy:    cfg.DuplicateOrderPolicy,
+		NotionalWindow:          cfg.NotionalWindow,
+	}
+	if cfg.PriceCollarPct < 0 {
+		errs = append(errs, fmt.Errorf("negative PRICE_COLLAR_PCT %v", cfg.PriceCollarPct))
+	} else {
+		limits.PriceCollarPct = cfg.PriceCollarPct
+	}
+	if cfg.PriceBandPct < 0 {
+		errs = append(errs, fmt.Errorf("negative PRICE_BAND_PCT %v", cfg.PriceBandPct))
+	} else {
+		limits.PriceBandPct = cfg.PriceBandPct
+	}
+	if cfg.MaxOrderQuantity != "" {
+		quantity, err := decimal.NewFromString(cfg.MaxOrderQuantity)
+		if err != nil || quantity.IsNegative() {
+			errs = append(errs, fmt.Errorf("invalid MAX_ORDER_QUANTITY %q", cfg.MaxOrderQuantity))
+		} else {
+			limits.MaxOrderQuantity = quantity
+		}
+	}
+	if cfg.MaxNotionalPerWindow != "" {
+		notional, err := decimal.NewFromString(cfg.MaxNotionalPerWindow)
+		if err != nil || notional.IsNegative() {
+			errs = append(errs, fmt.Errorf("invalid MAX_NOTIONAL_PER_WINDOW %q", cfg.MaxNotionalPerWindow))
+		} else {
+			limits.MaxNotionalPerWindow = notional
+		}
+	}
+	if cfg.NotionalWindow < 0 {
+		errs = append(errs, fmt.Errorf("negative NOTIONAL_WINDOW %v", cfg.NotionalWindow))
+		limits.NotionalWindow = 0
+	}
+	ratios, err := parseMinFillRatios(cfg.MinFillRatios)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid MIN_FILL_RATIOS: %w", err))
+		ratios = map[string]float64{}
+	}
+	limits.MinFillRatios = ratios
+	windows, err := parseDuplicateWindows(cfg.DuplicateOrderWindows)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid DUPLICATE_ORDER_WINDOWS: %w", err))
+		windows = map[string]time.Duration{}
+	}
+	limits.DuplicateOrderWindows = windows
+	collars, err := parsePriceCollars(cfg.PriceCollars)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid PRICE_COLLARS: %w", err))
+		collars = map[string]float64{}
+	}
+	limits.PriceCollars = collars
+	bands, err := parsePriceBands(cfg.PriceBands)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid PRICE_BANDS: %w", err))
+		bands = map[string]float64{}
+	}
+	limits.PriceBands = bands
+	return limits, errors.Join(errs...)
+}
+
+// riskLimits returns the active limits; an engine without any has none
+func (e *ExecutionEngine) riskLimits() *RiskLimits {
+	if limits := e.limits.Load(); limits != nil {
+		return limits
+	}
+	return &RiskLimits{}
+}
+
+// checkOrderQuantity refuses an order larger than MAX_ORDER_QUANTITY.
+// Notional orders are sized later and aren't checked.
+func (e *ExecutionEngine) checkOrderQuantity(order *OrderRequest) *rejection {
+	limit := e.riskLimits().MaxOrderQuantity
+	if !limit.IsPositive() || order.Quantity.LessThanOrEqual(limit) {
+		return nil
+	}
+	return &rejection{
+		Reason: rejectOrderTooLarge,
+		Detail: fmt.Sprintf("quantity %s over the limit of %s", order.Quantity, limit),
+		Limit:  &RiskLimit{Name: riskLimitOrderQuantity, Limit: limit, Current: order.Quantity},
+	}
+}
+
+// configFileValues holds the settings last read from CONFIG_FILE
+var configFileValues atomic.Pointer[map[string]string]
+
+// readConfigFile reads a file of KEY=VALUE lines
+func readConfigFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, line)
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return values, scanner.Err()
+}
+
+// loadConfigFile reads CONFIG_FILE, if set, so getEnv falls back to it
+func loadConfigFile() error {
+	values := map[string]string{}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		var err error
+		if values, err = readConfigFile(path); err != nil {
+			return err
+		}
+	}
+	configFileValues.Store(&values)
+	return nil
+}
+
+// configFileValue returns key's value in CONFIG_FILE, or ""
+func configFileValue(key string) string {
+	if values := configFileValues.Load(); values != nil {
+		return (*values)[key]
+	}
+	return ""
+}
+
+// Reload re-reads the configuration and swaps in its risk limits and symbol
+// lists, returning the new limits. Nothing changes if any of them is invalid.
+func (e *ExecutionEngine) Reload() (*RiskLimits, error) {
+	if err := loadConfigFile(); err != nil {
+		return nil, fmt.Errorf("reading CONFIG_FILE: %w", err)
+	}
+	cfg := configFromEnv()
+	limits, err := newRiskLimits(cfg)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := parseSymbolPolicy(cfg.SymbolAllowlist, cfg.SymbolDenylist)
+	if err != nil {
+		return nil, err
+	}
+
+	// Let the batch in flight finish on the limits it started with
+	e.batchMu.Lock()
+	e.limits.Store(limits)
+	e.symbolPolicy.Store(policy)
+	e.batchMu.Unlock()
+
+	log.Printf("Configuration reloaded: max order quantity %s, max open orders %d, symbols allow=%v deny=%v",
+		limits.MaxOrderQuantity, limits.MaxOpenOrdersPerAccount, policy.Allow, policy.Deny)
+	return limits, nil
+}
+
+// reloadOnSignal reloads the configuration on every SIGHUP
+func (e *ExecutionEngine) reloadOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			if _, err := e.Reload(); err != nil {
+				log.Printf("Configuration reload refused: %v", err)
+			}
+		}
+	}()
+}
+
+// handleReload serves POST /admin/reload
+func (e *ExecutionEngine) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	limits, err := e.Reload()
+	if err != nil {
+		log.Printf("Configuration reload refused: %v", err)
+		writeError(w, errCodeInvalidRequest, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(limits)
+}
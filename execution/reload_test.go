@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setRiskLimits changes the engine's active limits
+func setRiskLimits(engine *ExecutionEngine, change func(*RiskLimits)) {
+	limits := *engine.riskLimits()
+	change(&limits)
+	engine.limits.Store(&limits)
+}
+
+// bigOrder is a market buy of qty AAPL
+func bigOrder(id string, qty float64) *OrderRequest {
+	order := testOrder(id)
+	order.Quantity = dec(qty)
+	return &order
+}
+
+func TestReloadChangesMaxOrderQuantity(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	submitToEngine(t, engine, bigOrder("before", 100))
+	if response, _ := engine.GetOrder("before"); response.Status == statusRejected {
+		t.Fatalf("order before the reload rejected: %s", response.RejectReason)
+	}
+
+	t.Setenv("MAX_ORDER_QUANTITY", "50")
+	if _, err := engine.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	submitToEngine(t, engine, bigOrder("over", 100))
+	response, _ := engine.GetOrder("over")
+	if response.Status != statusRejected || response.RejectReason != rejectOrderTooLarge {
+		t.Fatalf("order over the reloaded limit: %s/%s, want %s", response.Status, response.RejectReason, rejectOrderTooLarge)
+	}
+	want := RiskLimit{Name: riskLimitOrderQuantity, Limit: dec(50), Current: dec(100)}
+	if !sameRiskLimit(response.RiskLimit, want) {
+		t.Errorf("risk limit = %+v, want %+v", response.RiskLimit, want)
+	}
+
+	submitToEngine(t, engine, bigOrder("within", 50))
+	if response, _ := engine.GetOrder("within"); response.Status == statusRejected {
+		t.Errorf("order at the reloaded limit rejected: %s", response.RejectReason)
+	}
+}
+
+func TestReloadRefusesInvalidConfig(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	t.Setenv("MAX_ORDER_QUANTITY", "50")
+	if _, err := engine.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	// One bad value keeps every active setting
+	t.Setenv("MAX_ORDER_QUANTITY", "10")
+	t.Setenv("SYMBOL_DENYLIST", "[AAPL")
+	if _, err := engine.Reload(); err == nil {
+		t.Fatal("reload with an invalid deny list succeeded")
+	}
+	if limit := engine.riskLimits().MaxOrderQuantity; !limit.Equal(dec(50)) {
+		t.Errorf("max order quantity after a refused reload = %s, want 50", limit)
+	}
+}
+
+func TestReloadEndpointReadsConfigFile(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	path := filepath.Join(t.TempDir(), "engine.env")
+	content := "# limits\nMAX_OPEN_ORDERS_PER_ACCOUNT = 3\nSYMBOL_DENYLIST=TSLA\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Cleanup(func() { configFileValues.Store(&map[string]string{}) })
+
+	rec := httptest.NewRecorder()
+	engine.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/reload: %d %s", rec.Code, rec.Body)
+	}
+	if limit := engine.riskLimits().MaxOpenOrdersPerAccount; limit != 3 {
+		t.Errorf("max open orders after reload = %d, want 3", limit)
+	}
+	if engine.symbolPermitted("TSLA") {
+		t.Error("TSLA permitted after reloading a deny list with it")
+	}
+
+	// The environment wins over the file
+	t.Setenv("MAX_OPEN_ORDERS_PER_ACCOUNT", "7")
+	if _, err := engine.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if limit := engine.riskLimits().MaxOpenOrdersPerAccount; limit != 7 {
+		t.Errorf("max open orders with the variable set = %d, want 7", limit)
+	}
+}
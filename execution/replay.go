@@ -0,0 +1,269 @@
+// ==============================================================================
+// Replay - backtest by feeding recorded ticks and orders through the engine
+// ==============================================================================
+// With REPLAY_FILE set the engine doesn't serve: it replays the file through
+// the order pipeline, writes the resulting fills and exits. The file holds
+// one JSON event per line, a market data tick or an order:
+//
+//   {"ts":1700000000000,"type":"tick","symbol":"AAPL","price":"190.5","quantity":"300"}
+//   {"ts":1700000000250,"type":"order","order":{"order_id":"o-1",...}}
+//
+// Events are replayed in timestamp order (ties in file order) on a replay
+// clock set to each event's ts, so timestamps, held order activation and
+// halts follow the recording rather than the wall clock. A tick becomes the
+// symbol's reference price, may fill the resting orders it reaches (see
+// simfill.go; its quantity is optional) and triggers the stops it reaches;
+// an order goes through processOrder exactly as if it had been read from
+// the stream.
+// REPLAY_SPEED paces the replay against real time: 1 replays in real time,
+// 10 ten times faster, and 0 (the default) as fast as possible.
+//
+// Every fill - including those of triggered stops, activated orders and
+// resting orders filled by ticks - is written to REPLAY_OUTPUT (stdout when
+// unset) as one JSON line. Run replays with TRANSPORT=memory so they start
+// from empty books and leave nothing behind.
+// ==============================================================================
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shopspring/decimal"
+)
+
+// Replay event types
+const (
+	replayEventTick  = "tick"
+	replayEventOrder = "order"
+)
+
+// ReplayEvent is one line of a replay file
+type ReplayEvent struct {
+	Timestamp int64           `json:"ts"` // unix ms
+	Type      string          `json:"type"`
+	Symbol    string          `json:"symbol,omitempty"`
+	Price     decimal.Decimal `json:"price,omitempty"`
+	Quantity  decimal.Decimal `json:"quantity,omitempty"` // volume of a tick, for the fill model
+	Order     *OrderRequest   `json:"order,omitempty"`
+}
+
+// MarshalJSON leaves out the price and quantity of events without them (see
+// decimal.go)
+func (ev ReplayEvent) MarshalJSON() ([]byte, error) {
+	type plain ReplayEvent
+	return json.Marshal(struct {
+		plain
+		Price    *decimal.Decimal `json:"price,omitempty"`
+		Quantity *decimal.Decimal `json:"quantity,omitempty"`
+	}{plain(ev), omitZero(ev.Price), omitZero(ev.Quantity)})
+}
+
+// ReplayFill is one fill in a replay's fill log. Book fills name the resting
+// order they traded against.
+type ReplayFill struct {
+	Timestamp      int64           `json:"ts"`
+	OrderID        string          `json:"order_id"`
+	AccountID      string          `json:"account_id,omitempty"`
+	Symbol         string          `json:"symbol"`
+	Side           string          `json:"side"`
+	Price          decimal.Decimal `json:"price"`
+	Quantity       decimal.Decimal `json:"quantity"`
+	Liquidity      string          `json:"liquidity,omitempty"`
+	RestingOrderID string          `json:"resting_order_id,omitempty"`
+}
+
+// replayClock is a Clock that only moves when the replay says so
+type replayClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// Now implements Clock
+func (c *replayClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *replayClock) set(t time.Time) {
+	c.mu.Lock()
+	c.t = t
+	c.mu.Unlock()
+}
+
+// readReplayEvents reads a replay file and sorts its events by time
+func readReplayEvents(r io.Reader) ([]ReplayEvent, error) {
+	var events []ReplayEvent
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event ReplayEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		switch {
+		case event.Type == replayEventTick && (event.Symbol == "" || !event.Price.IsPositive()):
+			return nil, fmt.Errorf("line %d: tick needs a symbol and a positive price", line)
+		case event.Type == replayEventOrder && event.Order == nil:
+			return nil, fmt.Errorf("line %d: order event without an order", line)
+		case event.Type != replayEventTick && event.Type != replayEventOrder:
+			return nil, fmt.Errorf("line %d: unknown event type %q", line, event.Type)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp < events[j].Timestamp })
+	return events, nil
+}
+
+// Replay feeds events through the engine at speed times real time (0 for
+// no pacing) and returns every fill they produced, in execution order
+func (e *ExecutionEngine) Replay(events []ReplayEvent, speed float64) ([]ReplayFill, error) {
+	if speed < 0 {
+		return nil, errors.New("replay speed must not be negative")
+	}
+	clock := &replayClock{}
+	e.clock = clock
+
+	// Everything set off runs in turn on this goroutine, so fills come out
+	// in one order
+	actors := e.actors
+	e.actors = nil
+	defer func() { e.actors = actors }()
+
+	var fills []ReplayFill
+	e.executed = func(order *OrderRequest, response *OrderResponse) {
+		fills = append(fills, replayFills(e.now().UnixMilli(), order, response)...)
+	}
+	defer func() { e.executed = nil }()
+
+	for i, event := range events {
+		if i > 0 && speed > 0 {
+			gap := time.Duration(event.Timestamp-events[i-1].Timestamp) * time.Millisecond
+			time.Sleep(time.Duration(float64(gap) / speed))
+		}
+		clock.set(time.UnixMilli(event.Timestamp))
+		e.activateDue()
+
+		switch event.Type {
+		case replayEventTick:
+			symbol := e.canonicalSymbol(event.Symbol)
+			e.prices.record(symbol, event.Price)
+			e.prices.recordVolume(symbol, event.Quantity)
+			for _, f := range e.fillOnPrint(symbol, event.Price, event.Quantity) {
+				fills = append(fills, ReplayFill{
+					Timestamp: event.Timestamp,
+					OrderID:   f.fill.RestingOrderID,
+					AccountID: f.fill.restingAccount,
+					Symbol:    symbol,
+					Side:      f.side,
+					Price:     f.fill.Price,
+					Quantity:  f.fill.Quantity,
+					Liquidity: f.fill.Liquidity,
+				})
+			}
+			e.priceMoved("", symbol)
+		case replayEventOrder:
+			if err := e.replayOrder(i, event); err != nil {
+				return fills, err
+			}
+		}
+	}
+	return fills, nil
+}
+
+// replayOrder puts one order event through the pipeline
+func (e *ExecutionEngine) replayOrder(i int, event ReplayEvent) error {
+	order := *event.Order
+	if order.Timestamp == 0 {
+		order.Timestamp = event.Timestamp
+	}
+	payload, err := json.Marshal(&order)
+	if err != nil {
+		return err
+	}
+	id := fmt.Sprintf("%d-%d", event.Timestamp, i)
+	return e.handleMessage(redis.XMessage{ID: id, Values: map[string]interface{}{"order": string(payload)}})
+}
+
+// replayFills turns an order's execution into fill log entries
+func replayFills(ts int64, order *OrderRequest, response *OrderResponse) []ReplayFill {
+	fill := ReplayFill{
+		Timestamp: ts,
+		OrderID:   order.OrderID,
+		AccountID: order.AccountID,
+		Symbol:    order.Symbol,
+		Side:      order.Side,
+		Liquidity: response.LiquidityFlag,
+	}
+	if len(response.Fills) == 0 {
+		if !response.FilledQuantity.IsPositive() {
+			return nil
+		}
+		fill.Price = response.FilledAvgPrice
+		fill.Quantity = response.FilledQuantity
+		return []ReplayFill{fill}
+	}
+	fills := make([]ReplayFill, 0, len(response.Fills))
+	for _, bookFill := range response.Fills {
+		fill.Price = bookFill.Price
+		fill.Quantity = bookFill.Quantity
+		fill.RestingOrderID = bookFill.RestingOrderID
+		fills = append(fills, fill)
+	}
+	return fills
+}
+
+// RunReplay replays the configured REPLAY_FILE and writes its fill log
+func (e *ExecutionEngine) RunReplay() error {
+	file, err := os.Open(e.config.ReplayFile)
+	if err != nil {
+		return err
+	}
+	events, err := readReplayEvents(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", e.config.ReplayFile, err)
+	}
+
+	out := io.Writer(os.Stdout)
+	if e.config.ReplayOutput != "" {
+		f, err := os.Create(e.config.ReplayOutput)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	log.Printf("Replaying %d events from %s at speed %v", len(events), e.config.ReplayFile, e.config.ReplaySpeed)
+	fills, err := e.Replay(events, e.config.ReplaySpeed)
+	writer := bufio.NewWriter(out)
+	encoder := json.NewEncoder(writer)
+	for i := range fills {
+		if encodeErr := encoder.Encode(&fills[i]); encodeErr != nil {
+			return encodeErr
+		}
+	}
+	if flushErr := writer.Flush(); flushErr != nil {
+		return flushErr
+	}
+	log.Printf("Replay finished with %d fills", len(fills))
+	return err
+}
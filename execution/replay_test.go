@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// replayFixture reads the recorded session in testdata
+func replayFixture(t *testing.T) []ReplayEvent {
+	t.Helper()
+	file, err := os.Open("testdata/replay_session.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	events, err := readReplayEvents(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestReplayProducesDeterministicFills(t *testing.T) {
+	const start = 1700000000000
+	want := []ReplayFill{
+		{Timestamp: start + 300, OrderID: "buy-1", Side: "buy", Price: dec(101), Quantity: dec(4), RestingOrderID: "ask-1"},
+		{Timestamp: start + 400, OrderID: "buy-2", Side: "buy", Price: dec(101), Quantity: dec(2), RestingOrderID: "ask-1"},
+		{Timestamp: start + 500, OrderID: "gat-1", Side: "buy", Price: dec(101), Quantity: dec(1), RestingOrderID: "ask-1"},
+		{Timestamp: start + 500, OrderID: "stop-1", Side: "sell", Price: dec(95), Quantity: dec(5)},
+	}
+
+	for run := 0; run < 2; run++ {
+		engine, _ := newTestEngine(t)
+		fills, err := engine.Replay(replayFixture(t), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fills) != len(want) {
+			t.Fatalf("run %d: %d fills, want %d: %+v", run, len(fills), len(want), fills)
+		}
+		for i, got := range fills {
+			w := want[i]
+			if got.Timestamp != w.Timestamp || got.OrderID != w.OrderID || got.Side != w.Side ||
+				!got.Price.Equal(w.Price) || !got.Quantity.Equal(w.Quantity) || got.RestingOrderID != w.RestingOrderID {
+				t.Errorf("run %d fill %d = %+v, want %+v", run, i, got, w)
+			}
+		}
+
+		// What was left of the ask still rests
+		if resting, ok := engine.bookFor("AAPL").Get("ask-1"); !ok || !resting.Quantity.Equal(dec(3)) {
+			t.Errorf("run %d: ask-1 left resting %+v, want 3", run, resting)
+		}
+	}
+}
+
+func TestReplayHonorsSpeed(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	began := time.Now()
+	if _, err := engine.Replay(replayFixture(t), 10); err != nil {
+		t.Fatal(err)
+	}
+	// 500ms of recording at ten times real time
+	if elapsed := time.Since(began); elapsed < 50*time.Millisecond {
+		t.Errorf("replay at speed 10 took %s, want at least 50ms", elapsed)
+	}
+}
+
+func TestRunReplayWritesFillLog(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.ReplayFile = "testdata/replay_session.jsonl"
+	engine.config.ReplayOutput = filepath.Join(t.TempDir(), "fills.jsonl")
+	if err := engine.RunReplay(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(engine.config.ReplayOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		lines++
+	}
+	if lines != 4 {
+		t.Errorf("fill log has %d lines, want 4", lines)
+	}
+}
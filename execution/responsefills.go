@@ -0,0 +1,52 @@
+// ==============================================================================
+// Response fills - the individual executions behind an order's fill
+// ==============================================================================
+// An order's response aggregates its executions into FilledQuantity and
+// FilledAvgPrice, and keeps each one in Fills: price, quantity, liquidity
+// flag, match time and the resting order it traded against. Transaction cost
+// analysis needs them, but most clients don't, so order responses served
+// over HTTP (GET /orders, GET /orders/{id}, synchronous acks and a parent's
+// children) leave them out unless RESPONSE_FILLS is set. A request can ask
+// either way with ?fills=true or ?fills=false. Fill sinks, the gRPC stream
+// and dry-run estimates always carry them.
+// ==============================================================================
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// wantsFills reports whether order responses to r should list their fills
+func (e *ExecutionEngine) wantsFills(r *http.Request) bool {
+	if include, err := strconv.ParseBool(r.URL.Query().Get("fills")); err == nil {
+		return include
+	}
+	return e.config.ResponseFills
+}
+
+// presentOrder returns response as r should see it: without its fills
+// unless asked for them
+func (e *ExecutionEngine) presentOrder(r *http.Request, response *OrderResponse) *OrderResponse {
+	if e.wantsFills(r) {
+		return response
+	}
+	trimmed := *response
+	trimmed.Fills = nil
+	if len(response.Legs) > 0 {
+		trimmed.Legs = make([]LegExecution, len(response.Legs))
+		for i, leg := range response.Legs {
+			leg.Fills = nil
+			trimmed.Legs[i] = leg
+		}
+	}
+	return &trimmed
+}
+
+// presentOrders applies presentOrder to every response in place
+func (e *ExecutionEngine) presentOrders(r *http.Request, responses []*OrderResponse) {
+	for i, response := range responses {
+		responses[i] = e.presentOrder(r, response)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestSweepingOrderListsFillPerLevel(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+	handler := engine.routes()
+	for id, price := range map[string]float64{"ask-a": 100, "ask-b": 101, "ask-c": 102} {
+		ask := limitOrder(id, "AAPL", "sell", price, 5)
+		ask.AccountID = "maker"
+		submitToEngine(t, engine, ask)
+	}
+	sweep := testOrder("sweep")
+	sweep.Quantity = dec(12)
+	submitToEngine(t, engine, &sweep)
+
+	get := func(query string) OrderResponse {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders/sweep"+query, nil))
+		var response OrderResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	if response := get(""); len(response.Fills) != 0 {
+		t.Errorf("fills served by default: %+v", response.Fills)
+	}
+	response := get("?fills=true")
+	want := []struct {
+		resting  string
+		price    float64
+		quantity float64
+	}{{"ask-a", 100, 5}, {"ask-b", 101, 5}, {"ask-c", 102, 2}}
+	if len(response.Fills) != len(want) {
+		t.Fatalf("fills = %+v, want one per level", response.Fills)
+	}
+	var quantity, notional decimal.Decimal
+	for i, fill := range response.Fills {
+		if fill.RestingOrderID != want[i].resting || !fill.Price.Equal(dec(want[i].price)) || !fill.Quantity.Equal(dec(want[i].quantity)) {
+			t.Errorf("fill %d = %+v, want %+v", i, fill, want[i])
+		}
+		if fill.Liquidity != liquidityTaker || fill.Timestamp != clock.Now().UnixMilli() {
+			t.Errorf("fill %d: liquidity %q at %d, want taker at %d", i, fill.Liquidity, fill.Timestamp, clock.Now().UnixMilli())
+		}
+		quantity = quantity.Add(fill.Quantity)
+		notional = notional.Add(fill.Quantity.Mul(fill.Price))
+	}
+	if !quantity.Equal(response.FilledQuantity) || !notional.Div(quantity).Equal(response.FilledAvgPrice) {
+		t.Errorf("fills add up to %s at %s, response says %s at %s", quantity, notional.Div(quantity), response.FilledQuantity, response.FilledAvgPrice)
+	}
+
+	engine.config.ResponseFills = true
+	if response := get(""); len(response.Fills) != 3 {
+		t.Errorf("RESPONSE_FILLS set: got %d fills, want 3", len(response.Fills))
+	}
+	if response := get("?fills=false"); len(response.Fills) != 0 {
+		t.Errorf("?fills=false: got %d fills, want none", len(response.Fills))
+	}
+}
@@ -0,0 +1,183 @@
+// ==============================================================================
+// Book seeding - start from a known book for load tests and demos
+// ==============================================================================
+// BOOK_SEED_FILE names a JSON or CSV file of resting limit orders loaded at
+// startup, after any book recovery; POST /admin/seed-book takes the same
+// orders as a JSON array at runtime. JSON entries use the order fields
+// (order_id, symbol, side, quantity, limit_price, account_id). CSV files have
+// the header "order_id,symbol,side,quantity,price" plus an optional
+// "account_id" column.
+//
+// Seeded orders are ordinary working orders: they are journaled, stored and
+// published like any resting order, so they match and cancel normally. A seed
+// must rest, so an order that would cross the book is refused, as is an order
+// ID that is already known.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// SeedBook rests orders in the book in the order given and returns how many
+// were seeded. It stops at the first order it can't seed.
+func (e *ExecutionEngine) SeedBook(orders []OrderRequest) (int, error) {
+	for i := range orders {
+		if err := e.seedOrder(&orders[i]); err != nil {
+			return i, fmt.Errorf("seeding order %d (%s): %w", i, orders[i].OrderID, err)
+		}
+	}
+	if len(orders) > 0 {
+		log.Printf("Seeded %d resting orders", len(orders))
+	}
+	return len(orders), nil
+}
+
+// seedOrder rests one order in its book as a working order
+func (e *ExecutionEngine) seedOrder(order *OrderRequest) error {
+	order.Type = "limit"
+	if order.TimeInForce == "" {
+		order.TimeInForce = "gtc"
+	}
+	if err := validateOrder(order); err != nil {
+		return err
+	}
+	if !order.LimitPrice.IsPositive() {
+		return errors.New("limit_price must be positive")
+	}
+	if _, ok := e.GetOrder(order.OrderID); ok {
+		return errors.New("order ID already in use")
+	}
+
+	book := e.bookFor(order.Symbol)
+	book.mu.Lock()
+	if book.wouldCross(order.Side, order.LimitPrice) {
+		book.mu.Unlock()
+		return errors.New("order would cross the book")
+	}
+	if !e.makeRoom(book) {
+		book.mu.Unlock()
+		return errors.New(rejectBookFull)
+	}
+	resting := book.Add(newRestingOrder(order, order.Quantity))
+	resting.RestedAt = e.now().UnixMilli()
+	e.journal(book, bookMutation{Op: journalOpAdd, Symbol: order.Symbol, Order: resting})
+	book.mu.Unlock()
+
+	response := &OrderResponse{
+		OrderID:        order.OrderID,
+		ClientOrderID:  order.IdempotencyKey,
+		Symbol:         order.Symbol,
+		Status:         statusWorking,
+		AcknowledgedAt: e.now().UnixMilli(),
+		Tags:           order.Tags,
+		ParentOrderID:  order.ParentOrderID,
+	}
+	e.orderCache.Store(order.OrderID, response)
+	e.saveOrder(order, response)
+	e.publishResponse(response)
+	return nil
+}
+
+// loadSeedFile reads seed orders from a .json or .csv file
+func loadSeedFile(path string) ([]OrderRequest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var orders []OrderRequest
+		if err := json.NewDecoder(f).Decode(&orders); err != nil {
+			return nil, err
+		}
+		return orders, nil
+	case ".csv":
+		return parseSeedCSV(f)
+	default:
+		return nil, fmt.Errorf("seed file %s is neither .json nor .csv", path)
+	}
+}
+
+// parseSeedCSV decodes seed orders from CSV with a header row
+func parseSeedCSV(r io.Reader) ([]OrderRequest, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"order_id", "symbol", "side", "quantity", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("seed CSV has no %s column", required)
+		}
+	}
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	orders := make([]OrderRequest, 0, len(rows)-1)
+	for line, row := range rows[1:] {
+		quantity, err := decimal.NewFromString(field(row, "quantity"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid quantity", line+2)
+		}
+		price, err := decimal.NewFromString(field(row, "price"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid price", line+2)
+		}
+		orders = append(orders, OrderRequest{
+			OrderID:    field(row, "order_id"),
+			Symbol:     field(row, "symbol"),
+			Side:       field(row, "side"),
+			Quantity:   quantity,
+			LimitPrice: price,
+			AccountID:  field(row, "account_id"),
+		})
+	}
+	return orders, nil
+}
+
+// handleSeedBook serves POST /admin/seed-book with a JSON array of orders
+func (e *ExecutionEngine) handleSeedBook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+
+	var orders []OrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&orders); err != nil {
+		writeError(w, errCodeInvalidRequest, "Invalid request")
+		return
+	}
+
+	seeded, err := e.SeedBook(orders)
+	if err != nil {
+		writeError(w, errCodeInvalidRequest, fmt.Sprintf("%v (%d orders seeded before it)", err, seeded))
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"seeded": seeded})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSeedBookFromFileMatchesIncomingOrders(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	orders, err := loadSeedFile("testdata/seed_book.csv")
+	if err != nil {
+		t.Fatalf("loading fixture: %v", err)
+	}
+	if n, err := engine.SeedBook(orders); err != nil || n != 5 {
+		t.Fatalf("SeedBook = %d, %v; want 5, nil", n, err)
+	}
+	if status := restingStatus(t, engine, "seed-ask-1"); status != statusWorking {
+		t.Fatalf("seeded order status = %s, want working", status)
+	}
+
+	// A buy through the best ask sweeps the first seeded level
+	submitToEngine(t, engine, limitOrder("taker-1", "AAPL", "buy", 100.50, 150))
+	taker, _ := engine.GetOrder("taker-1")
+	if len(taker.Fills) != 1 || taker.Fills[0].RestingOrderID != "seed-ask-1" || !taker.FilledQuantity.Equal(dec(100)) {
+		t.Fatalf("incoming order should fill against seed-ask-1, got %+v", taker)
+	}
+	if status := restingStatus(t, engine, "seed-ask-1"); status != statusFilled {
+		t.Errorf("seed-ask-1 status = %s, want filled", status)
+	}
+
+	// Seeds cancel like any working order
+	if _, err := engine.CancelOrder("seed-bid-2", "mm-1"); err != nil {
+		t.Fatalf("cancelling a seeded order: %v", err)
+	}
+	if inBook(engine, "AAPL", "seed-bid-2") {
+		t.Error("cancelled seed still in the book")
+	}
+}
+
+func TestSeedBookRefusesCrossingAndDuplicateOrders(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	seeds := []OrderRequest{
+		*limitOrder("ask", "AAPL", "sell", 100, 10),
+		*limitOrder("bid", "AAPL", "buy", 101, 10),
+	}
+	if n, err := engine.SeedBook(seeds); err == nil || n != 1 {
+		t.Fatalf("crossing seed: SeedBook = %d, %v; want 1 and an error", n, err)
+	}
+	if _, err := engine.SeedBook([]OrderRequest{*limitOrder("ask", "AAPL", "sell", 102, 10)}); err == nil {
+		t.Fatal("expected a duplicate order ID to be refused")
+	}
+}
+
+func TestHandleSeedBook(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	body := `[{"order_id":"s-1","symbol":"MSFT","side":"buy","quantity":"5","limit_price":"400"}]`
+	req := httptest.NewRequest(http.MethodPost, "/admin/seed-book", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	engine.handleSeedBook(rec, req)
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"seeded":1`) {
+		t.Fatalf("seed-book = %d %s", rec.Code, rec.Body.String())
+	}
+	if !inBook(engine, "MSFT", "s-1") {
+		t.Error("seeded order not in the book")
+	}
+}
@@ -0,0 +1,203 @@
+// ==============================================================================
+// Trading sessions - order entry over a WebSocket, cancelled on disconnect
+// ==============================================================================
+// GET /session upgrades to a WebSocket on which a client sends orders, one
+// JSON OrderRequest per message, and receives back an acknowledgement for
+// each ({"order_id": ..., "status": "accepted"}, or an APIError if it wasn't
+// queued) followed by every update of the orders it sent on the session.
+// Orders belong to the account of the API key the session was opened with.
+//
+// A session opened with ?cancel_on_disconnect=true owns its orders: when the
+// connection drops, however it drops, every one of them still working or
+// held is cancelled. Orders still on the stream at that point are given up to
+// ORDER_ACK_TIMEOUT to be executed first, so they can't rest afterwards.
+// Without the flag the orders outlive the session like any others.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// sessionHello is the first message on a session, confirming its settings
+type sessionHello struct {
+	CancelOnDisconnect bool `json:"cancel_on_disconnect"`
+}
+
+// session is one client connection and the orders sent on it
+type session struct {
+	conn               *websocket.Conn
+	account            string
+	cancelOnDisconnect bool
+
+	mu     sync.Mutex
+	orders map[string]bool
+}
+
+func (s *session) track(orderID string) {
+	s.mu.Lock()
+	s.orders[orderID] = true
+	s.mu.Unlock()
+}
+
+func (s *session) owns(orderID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.orders[orderID]
+}
+
+func (s *session) orderIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.orders))
+	for id := range s.orders {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// handleSession serves GET /session
+func (e *ExecutionEngine) handleSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	cancelOnDisconnect := false
+	if v := r.URL.Query().Get("cancel_on_disconnect"); v != "" {
+		var err error
+		if cancelOnDisconnect, err = strconv.ParseBool(v); err != nil {
+			writeFieldError(w, "cancel_on_disconnect", "cancel_on_disconnect must be true or false")
+			return
+		}
+	}
+	account, _ := accountFromContext(r.Context())
+
+	// Sessions authenticate by API key, not by origin
+	server := websocket.Server{
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			conn.MaxPayloadBytes = int(e.config.MaxPayloadBytes)
+			e.runSession(&session{conn: conn, account: account, cancelOnDisconnect: cancelOnDisconnect, orders: map[string]bool{}})
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// runSession takes orders from a session until its connection drops
+func (e *ExecutionEngine) runSession(s *session) {
+	defer s.conn.Close()
+
+	// Subscribe before taking orders so no update of theirs can slip past
+	updates := e.subscribers.subscribe("")
+	defer e.subscribers.unsubscribe(updates)
+	forwarding := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		e.forwardSessionUpdates(s, updates, forwarding)
+	}()
+
+	if err := websocket.JSON.Send(s.conn, sessionHello{CancelOnDisconnect: s.cancelOnDisconnect}); err == nil {
+		e.readSessionOrders(s)
+	}
+
+	close(forwarding)
+	<-stopped
+	if s.cancelOnDisconnect {
+		e.cancelSessionOrders(s, updates)
+	}
+}
+
+// readSessionOrders queues the orders a session sends, acknowledging each,
+// until the connection drops
+func (e *ExecutionEngine) readSessionOrders(s *session) {
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(s.conn, &data); err != nil {
+			return
+		}
+		var order OrderRequest
+		if err := json.Unmarshal(data, &order); err != nil {
+			websocket.JSON.Send(s.conn, APIError{Code: errCodeInvalidRequest, Message: "Invalid request"})
+			continue
+		}
+		if s.account != "" {
+			order.AccountID = s.account
+		}
+
+		// Track it first: it may execute before SubmitOrder returns
+		e.assignOrderID(&order)
+		s.track(order.OrderID)
+		if err := e.SubmitOrder(e.ctx, &order); err != nil {
+			websocket.JSON.Send(s.conn, sessionSubmitError(err))
+			continue
+		}
+		websocket.JSON.Send(s.conn, map[string]string{"order_id": order.OrderID, "status": string(statusAccepted)})
+	}
+}
+
+// sessionSubmitError is the APIError a session answers a refused order with
+func sessionSubmitError(err error) APIError {
+	switch {
+	case errors.Is(err, errSymbolNotPermitted):
+		return APIError{Code: errCodeForbidden, Message: err.Error()}
+	case errors.Is(err, errQueueFull), errors.Is(err, errQueueSlow):
+		return APIError{Code: errCodeBackpressure, Message: err.Error(), RetryAfterMs: retryAfterMs(backpressureRetryAfter)}
+	case errors.Is(err, errDraining):
+		return APIError{Code: errCodeUnavailable, Message: err.Error()}
+	}
+	return APIError{Code: errCodeInternal, Message: "Failed to queue order"}
+}
+
+// forwardSessionUpdates sends a session the updates of its orders until done
+// is closed
+func (e *ExecutionEngine) forwardSessionUpdates(s *session, updates *fillSubscription, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case update := <-updates.updates:
+			if s.owns(update.OrderID) {
+				websocket.JSON.Send(s.conn, update)
+			}
+		}
+	}
+}
+
+// cancelSessionOrders cancels a closed session's working and held orders,
+// first waiting out those not yet executed
+func (e *ExecutionEngine) cancelSessionOrders(s *session, updates *fillSubscription) {
+	deadline := e.now().Add(e.config.OrderAckTimeout)
+	cancelled := 0
+	for _, orderID := range s.orderIDs() {
+		response, ok := e.GetOrder(orderID)
+		if !ok || response.Status == statusAccepted {
+			if response, ok = e.awaitOutcome(context.Background(), updates, orderID, deadline.Sub(e.now())); !ok {
+				log.Printf("Order %s of a closed session not executed in time to cancel", orderID)
+				continue
+			}
+		}
+		if _, open := orderTransitions[response.Status]; !open {
+			continue
+		}
+		if _, err := e.cancelOrder(orderID, s.account, false); err != nil {
+			if !errors.Is(err, errOrderNotWorking) {
+				log.Printf("Error cancelling order %s of a closed session: %v", orderID, err)
+			}
+			continue
+		}
+		cancelled++
+	}
+	if cancelled > 0 {
+		log.Printf("Session disconnected: cancelled %d orders", cancelled)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// openSession opens a WebSocket session on engine and reads its hello
+func openSession(t *testing.T, engine *ExecutionEngine, query string) *websocket.Conn {
+	t.Helper()
+	queueBatch(t, engine) // creates the consumer group
+	server := httptest.NewServer(engine.routes())
+	t.Cleanup(server.Close)
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/session"+query, "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	var hello sessionHello
+	if err := websocket.JSON.Receive(conn, &hello); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// sendSessionOrder sends an order on a session and waits for its ack
+func sendSessionOrder(t *testing.T, conn *websocket.Conn, order *OrderRequest) {
+	t.Helper()
+	if err := websocket.JSON.Send(conn, order); err != nil {
+		t.Fatal(err)
+	}
+	var ack map[string]string
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := websocket.JSON.Receive(conn, &ack); err != nil {
+		t.Fatal(err)
+	}
+	if ack["order_id"] != order.OrderID || ack["status"] != string(statusAccepted) {
+		t.Fatalf("order %s acknowledged with %v", order.OrderID, ack)
+	}
+}
+
+// awaitStatus consumes the stream until orderID reaches want
+func awaitStatus(t *testing.T, engine *ExecutionEngine, orderID string, want OrderState) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		engine.consumeBatch()
+		if response, ok := engine.GetOrder(orderID); ok && response.Status == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	response, _ := engine.GetOrder(orderID)
+	t.Fatalf("order %s: got %+v, want %s", orderID, response, want)
+}
+
+func TestSessionForwardsUpdatesOfItsOrders(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	conn := openSession(t, engine, "")
+
+	sendSessionOrder(t, conn, limitOrder("sess-1", "AAPL", "buy", 100, 10))
+	engine.consumeBatch()
+
+	var update OrderResponse
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := websocket.JSON.Receive(conn, &update); err != nil {
+		t.Fatal(err)
+	}
+	if update.OrderID != "sess-1" || update.Status != statusWorking {
+		t.Errorf("session sent %+v, want sess-1 working", update)
+	}
+}
+
+func TestSessionCancelsOrdersOnDisconnect(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	conn := openSession(t, engine, "?cancel_on_disconnect=true")
+
+	sendSessionOrder(t, conn, limitOrder("sess-1", "AAPL", "buy", 100, 10))
+	awaitStatus(t, engine, "sess-1", statusWorking)
+
+	conn.Close()
+	awaitStatus(t, engine, "sess-1", statusCancelled)
+	if inBook(engine, "AAPL", "sess-1") {
+		t.Error("order of the dropped session still rests in the book")
+	}
+}
+
+func TestSessionCancelsOrdersExecutedAfterDisconnect(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	conn := openSession(t, engine, "?cancel_on_disconnect=true")
+
+	// Still on the stream when the connection drops
+	sendSessionOrder(t, conn, limitOrder("sess-1", "AAPL", "buy", 100, 10))
+	conn.Close()
+
+	awaitStatus(t, engine, "sess-1", statusCancelled)
+}
+
+func TestSessionOrdersOutliveItWithoutTheFlag(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	conn := openSession(t, engine, "")
+
+	sendSessionOrder(t, conn, limitOrder("sess-1", "AAPL", "buy", 100, 10))
+	awaitStatus(t, engine, "sess-1", statusWorking)
+
+	conn.Close()
+	time.Sleep(100 * time.Millisecond)
+	if status := restingStatus(t, engine, "sess-1"); status != statusWorking {
+		t.Errorf("order of a session without cancel-on-disconnect: got %s, want working", status)
+	}
+}
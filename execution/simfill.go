@@ -0,0 +1,195 @@
+// ==============================================================================
+// Simulator fill models - when market prints fill resting limit orders
+// ==============================================================================
+// In a backtest the engine's resting limit orders sit in a venue queue with
+// everyone else's. A replayed tick is a print in the market: it reaches the
+// resting buys priced at or above it and the resting sells at or below it.
+// Whether each of those fills is up to the fill model, SIM_FILL_MODEL:
+//
+//   none         prints never fill resting orders (the default); they only
+//                trade against incoming orders
+//   touch        every order the print reaches fills in full
+//   probability  each reached order fills with SIM_FILL_PROBABILITY
+//   queue        each reached order fills with probability
+//                volume / (ahead + quantity), where volume is the print's
+//                quantity and ahead the quantity resting before the order on
+//                its side; an order at the front of a print as large as it
+//                always fills, one deep in the queue rarely does
+//
+// An order that doesn't fill keeps resting and may fill on a later print.
+// Fills trade the order's remaining displayed quantity at its own price as
+// maker fills, exactly like a fill against an incoming order. Random draws
+// come from a generator seeded from SIM_SEED, so replays stay reproducible.
+// ==============================================================================
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// Supported values for SIM_FILL_MODEL
+const (
+	fillModelNone        = "none"
+	fillModelTouch       = "touch"
+	fillModelProbability = "probability"
+	fillModelQueue       = "queue"
+)
+
+// FillModel decides how likely a market print is to fill a resting order
+// it reaches
+type FillModel interface {
+	// FillProbability is the chance the order fills, given the quantity
+	// resting ahead of it, its own quantity and the print's volume
+	FillProbability(ahead, quantity, volume decimal.Decimal) float64
+}
+
+// NoFills never fills resting orders from prints
+type NoFills struct{}
+
+// FillProbability implements FillModel
+func (NoFills) FillProbability(ahead, quantity, volume decimal.Decimal) float64 { return 0 }
+
+// TouchFills fills every order a print reaches
+type TouchFills struct{}
+
+// FillProbability implements FillModel
+func (TouchFills) FillProbability(ahead, quantity, volume decimal.Decimal) float64 { return 1 }
+
+// ConstantFills fills each reached order with the same probability
+type ConstantFills struct {
+	Probability float64
+}
+
+// FillProbability implements FillModel
+func (m ConstantFills) FillProbability(ahead, quantity, volume decimal.Decimal) float64 {
+	return m.Probability
+}
+
+// QueueFills fills an order with the share of its queue, itself included,
+// that the print's volume covers
+type QueueFills struct{}
+
+// FillProbability implements FillModel
+func (QueueFills) FillProbability(ahead, quantity, volume decimal.Decimal) float64 {
+	queue := ahead.Add(quantity)
+	if !volume.IsPositive() || !queue.IsPositive() {
+		return 0
+	}
+	if volume.GreaterThanOrEqual(queue) {
+		return 1
+	}
+	return volume.Div(queue).InexactFloat64()
+}
+
+// newFillModel builds the model named in the config
+func newFillModel(name string, probability float64) (FillModel, error) {
+	switch name {
+	case "", fillModelNone:
+		return NoFills{}, nil
+	case fillModelTouch:
+		return TouchFills{}, nil
+	case fillModelProbability:
+		if probability < 0 || probability > 1 {
+			return nil, fmt.Errorf("fill probability %v is outside [0, 1]", probability)
+		}
+		return ConstantFills{Probability: probability}, nil
+	case fillModelQueue:
+		return QueueFills{}, nil
+	default:
+		return nil, fmt.Errorf("unknown fill model %q", name)
+	}
+}
+
+// simFills draws fills from the engine's fill model
+type simFills struct {
+	model FillModel
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// draw reports whether an order with probability p of filling fills
+func (s *simFills) draw(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	if p >= 1 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Float64() < p
+}
+
+// printFill is a resting order's fill by a market print
+type printFill struct {
+	side string // of the resting order
+	fill BookFill
+}
+
+// fillOnPrint lets the fill model fill the resting orders in symbol that a
+// print of volume at price reaches, returning the fills
+func (e *ExecutionEngine) fillOnPrint(symbol string, price, volume decimal.Decimal) []printFill {
+	if e.simFills == nil {
+		return nil
+	}
+	if _, ok := e.simFills.model.(NoFills); ok {
+		return nil
+	}
+	book, ok := e.lookupBook(symbol)
+	if !ok {
+		return nil
+	}
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	// Decide every order before trading any, so the queue each one sees is
+	// the book as the print found it
+	var filled []*BookOrder
+	for _, side := range []struct {
+		levels  []*priceLevel
+		reached func(level decimal.Decimal) bool
+	}{
+		{book.bids, func(level decimal.Decimal) bool { return level.GreaterThanOrEqual(price) }},
+		{book.asks, func(level decimal.Decimal) bool { return level.LessThanOrEqual(price) }},
+	} {
+		var ahead decimal.Decimal
+		for _, level := range side.levels {
+			if !side.reached(level.price) {
+				break
+			}
+			for _, order := range level.orders {
+				if e.simFills.draw(e.simFills.model.FillProbability(ahead, order.Quantity, volume)) {
+					filled = append(filled, order)
+				}
+				ahead = ahead.Add(order.Quantity)
+			}
+		}
+	}
+
+	fills := make([]printFill, 0, len(filled))
+	for _, order := range filled {
+		fill := BookFill{
+			RestingOrderID:    order.OrderID,
+			RestingSequence:   order.Sequence,
+			Price:             order.Price,
+			Quantity:          order.Quantity,
+			Liquidity:         liquidityMaker,
+			restingAccount:    order.AccountID,
+			restingReduceOnly: order.ReduceOnly,
+		}
+		book.reduce(order, fill.Quantity)
+		e.journal(book, bookMutation{Op: journalOpFill, Symbol: symbol, OrderID: fill.RestingOrderID, Quantity: fill.Quantity})
+		e.applyRestingFill(book, fill, order.Side)
+		fills = append(fills, printFill{side: order.Side, fill: fill})
+	}
+	if len(fills) > 0 {
+		e.repeg(book)
+	}
+	return fills
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestQueueFillModelRateForMidQueueOrder(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.simFills = &simFills{model: QueueFills{}, rng: rand.New(rand.NewSource(7))}
+
+	// Three asks of 10 at 100 and a print of 10 there: the order in the
+	// middle has 10 ahead of it, so fills with probability 10 / (10 + 10)
+	const trials = 2000
+	front, middle, back := 0, 0, 0
+	for i := 0; i < trials; i++ {
+		ids := [3]string{fmt.Sprintf("front-%d", i), fmt.Sprintf("mid-%d", i), fmt.Sprintf("back-%d", i)}
+		for _, id := range ids {
+			engine.executeOrder(context.Background(), limitOrder(id, "AAPL", "sell", 100, 10))
+		}
+		for _, f := range engine.fillOnPrint("AAPL", dec(100), dec(10)) {
+			switch f.fill.RestingOrderID {
+			case ids[0]:
+				front++
+			case ids[1]:
+				middle++
+			case ids[2]:
+				back++
+			}
+		}
+		engine.CancelAll(CancelFilter{Symbol: "AAPL"})
+	}
+
+	if front != trials {
+		t.Errorf("front of the queue filled %d of %d times, want always", front, trials)
+	}
+	for _, tc := range []struct {
+		name   string
+		filled int
+		want   float64
+	}{
+		{"middle", middle, 0.5},
+		{"back", back, 1.0 / 3},
+	} {
+		rate := float64(tc.filled) / trials
+		if math.Abs(rate-tc.want) > 0.04 {
+			t.Errorf("%s of the queue filled at rate %.3f, want %.3f", tc.name, rate, tc.want)
+		}
+	}
+}
+
+func TestPrintsOnlyFillOrdersTheyReach(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.simFills = &simFills{model: TouchFills{}}
+	engine.executeOrder(context.Background(), limitOrder("bid-99", "AAPL", "buy", 99, 5))
+	engine.executeOrder(context.Background(), limitOrder("bid-98", "AAPL", "buy", 98, 5))
+	engine.executeOrder(context.Background(), limitOrder("ask-101", "AAPL", "sell", 101, 5))
+
+	fills := engine.fillOnPrint("AAPL", dec(99), dec(0))
+	if len(fills) != 1 || fills[0].fill.RestingOrderID != "bid-99" || fills[0].side != "buy" || !fills[0].fill.Quantity.Equal(dec(5)) {
+		t.Fatalf("fills = %+v, want bid-99 filled for 5", fills)
+	}
+	if restingQuantity(engine, "AAPL", "bid-99") != 0 {
+		t.Error("filled bid still resting")
+	}
+	if restingQuantity(engine, "AAPL", "bid-98") != 5 || restingQuantity(engine, "AAPL", "ask-101") != 5 {
+		t.Error("orders the print didn't reach were filled")
+	}
+
+	// The default model leaves resting orders to incoming orders
+	engine.simFills = &simFills{model: NoFills{}}
+	if fills := engine.fillOnPrint("AAPL", dec(98), dec(100)); len(fills) != 0 {
+		t.Errorf("no-fill model filled %+v", fills)
+	}
+}
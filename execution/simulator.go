@@ -0,0 +1,138 @@
+// ==============================================================================
+// Simulator latency models
+// ==============================================================================
+// The simulated venue used to time.Sleep(2ms) on every order. That sleep
+// dominated BenchmarkOrderExecution, so the benchmark measured the timer rather
+// than our own code, and the coarse sleep granularity also inflated latency
+// figures. The delay is now a pluggable model that defaults to zero.
+//
+// Everything random in the simulator draws from one generator seeded with
+// SIM_SEED, or from generators seeded by it (see simfill.go), so two runs
+// with the same seed and the same input produce the same fills and delays.
+// Leaving SIM_SEED at 0 seeds it from the time.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Supported values for SIM_LATENCY_MODEL
+const (
+	latencyModelZero   = "zero"
+	latencyModelFixed  = "fixed"
+	latencyModelNormal = "normal"
+)
+
+// busyWaitThreshold is the delay below which we spin instead of sleeping,
+// since the runtime timer can't reliably sleep for less than about 1ms
+const busyWaitThreshold = time.Millisecond
+
+// LatencyModel decides how long the simulated venue takes to execute an order
+type LatencyModel interface {
+	Delay() time.Duration
+}
+
+// ZeroLatency executes instantly so only our own code is measured
+type ZeroLatency struct{}
+
+// Delay implements LatencyModel
+func (ZeroLatency) Delay() time.Duration { return 0 }
+
+// FixedLatency delays every order by the same amount
+type FixedLatency struct {
+	Latency time.Duration
+}
+
+// Delay implements LatencyModel
+func (m FixedLatency) Delay() time.Duration { return m.Latency }
+
+// NormalLatency draws delays from a normal distribution, clamped at zero
+type NormalLatency struct {
+	Mean   time.Duration
+	StdDev time.Duration
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewNormalLatency creates a normally distributed latency model drawing from
+// rng, which the model then owns
+func NewNormalLatency(mean time.Duration, stdDev time.Duration, rng *rand.Rand) *NormalLatency {
+	return &NormalLatency{
+		Mean:   mean,
+		StdDev: stdDev,
+		rng:    rng,
+	}
+}
+
+// newSimRand creates the simulator's random source from SIM_SEED, or from
+// the time when the seed is 0
+func newSimRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// Delay implements LatencyModel
+func (m *NormalLatency) Delay() time.Duration {
+	m.mu.Lock()
+	sample := m.rng.NormFloat64()
+	m.mu.Unlock()
+
+	d := m.Mean + time.Duration(sample*float64(m.StdDev))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// newLatencyModel builds the model named in the config. Random models draw
+// from rng.
+func newLatencyModel(name string, latency time.Duration, jitter time.Duration, rng *rand.Rand) (LatencyModel, error) {
+	switch name {
+	case "", latencyModelZero:
+		return ZeroLatency{}, nil
+	case latencyModelFixed:
+		return FixedLatency{Latency: latency}, nil
+	case latencyModelNormal:
+		return NewNormalLatency(latency, jitter, rng), nil
+	default:
+		return nil, fmt.Errorf("unknown latency model %q", name)
+	}
+}
+
+// simulateLatency blocks for the delay chosen by the engine's latency model,
+// or until ctx is done
+func (e *ExecutionEngine) simulateLatency(ctx context.Context) {
+	if e.latencyModel == nil {
+		return
+	}
+	wait(ctx, e.latencyModel.Delay())
+}
+
+// wait blocks for d or until ctx is done, busy-waiting for sub-millisecond
+// delays
+func wait(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	if d >= busyWaitThreshold {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		return
+	}
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+	}
+}
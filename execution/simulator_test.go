@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNewLatencyModel(t *testing.T) {
+	model, err := newLatencyModel("", 0, 0, nil)
+	if err != nil || model.Delay() != 0 {
+		t.Fatalf("default model should be zero latency, got %v (%v)", model, err)
+	}
+
+	model, err = newLatencyModel(latencyModelFixed, 250*time.Microsecond, 0, nil)
+	if err != nil || model.Delay() != 250*time.Microsecond {
+		t.Fatalf("fixed model returned %v (%v)", model.Delay(), err)
+	}
+
+	model, err = newLatencyModel(latencyModelNormal, time.Millisecond, 100*time.Microsecond, newSimRand(1))
+	if err != nil {
+		t.Fatalf("normal model: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		if d := model.Delay(); d < 0 {
+			t.Fatalf("negative delay %v", d)
+		}
+	}
+
+	if _, err := newLatencyModel("gaussian", 0, 0, nil); err == nil {
+		t.Fatal("expected error for unknown model")
+	}
+}
+
+func TestSeededLatencyRepeats(t *testing.T) {
+	a := NewNormalLatency(time.Millisecond, 200*time.Microsecond, newSimRand(42))
+	b := NewNormalLatency(time.Millisecond, 200*time.Microsecond, newSimRand(42))
+	for i := 0; i < 100; i++ {
+		if da, db := a.Delay(), b.Delay(); da != db {
+			t.Fatalf("delay %d: %v and %v from the same seed", i, da, db)
+		}
+	}
+}
+
+func TestSameSeedSameFills(t *testing.T) {
+	t.Setenv("SIM_SEED", "42")
+	t.Setenv("SIM_LATENCY_MODEL", latencyModelNormal)
+	t.Setenv("SIM_LATENCY", "20us")
+	t.Setenv("SIM_LATENCY_JITTER", "10us")
+
+	var runs [2][]byte
+	for run := range runs {
+		engine, _ := newTestEngine(t)
+		fills, err := engine.Replay(replayFixture(t), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		encoder := json.NewEncoder(&out)
+		for i := range fills {
+			if err := encoder.Encode(&fills[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		runs[run] = out.Bytes()
+	}
+	if len(runs[0]) == 0 || !bytes.Equal(runs[0], runs[1]) {
+		t.Fatalf("fills differ between runs with the same seed:\n%s\n%s", runs[0], runs[1])
+	}
+}
+
+func TestWaitBusyWaitsSubMillisecond(t *testing.T) {
+	start := time.Now()
+	wait(context.Background(), 200*time.Microsecond)
+	if elapsed := time.Since(start); elapsed < 200*time.Microsecond {
+		t.Fatalf("wait returned after %v", elapsed)
+	}
+}
+
+// BenchmarkLatencyModels compares execution cost under each simulator model
+func BenchmarkLatencyModels(b *testing.B) {
+	models := map[string]LatencyModel{
+		"zero":         ZeroLatency{},
+		"fixed-100us":  FixedLatency{Latency: 100 * time.Microsecond},
+		"fixed-2ms":    FixedLatency{Latency: 2 * time.Millisecond},
+		"normal-500us": NewNormalLatency(500*time.Microsecond, 100*time.Microsecond, newSimRand(1)),
+	}
+
+	order := &OrderRequest{
+		OrderID:  "bench-order",
+		Symbol:   "AAPL",
+		Side:     "buy",
+		Quantity: dec(100),
+		Type:     "market",
+	}
+
+	for name, model := range models {
+		b.Run(name, func(b *testing.B) {
+			engine := &ExecutionEngine{latencyModel: model}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				engine.executeOrder(context.Background(), order)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+// ==============================================================================
+// Fill sinks - delivery of order updates to downstream consumers
+// ==============================================================================
+// Every order update is handed to each configured FillSink: Redis pub/sub on
+// "order.response.<id>" (the original behaviour) and/or a Kafka topic keyed by
+// symbol, plus an in-process broadcaster feeding live subscribers such as
+// gRPC SubscribeFills streams. Each sink has its own bounded queue and worker,
+// so a slow or failing sink neither blocks order processing nor delays the
+// other sinks. Failed publishes are retried FILL_SINK_MAX_ATTEMPTS times with
+// backoff starting at FILL_SINK_BACKOFF; updates that still fail are parked
+// for redelivery (see redelivery.go), as are updates arriving while a sink's
+// queue is full. Updates are dropped, and counted, when they can't be parked.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+)
+
+// Supported values in FILL_SINKS
+const (
+	fillSinkRedis = "redis"
+	fillSinkKafka = "kafka"
+)
+
+// Delivery tuning shared by all sinks; the attempts and backoff are defaults
+// for the config
+const (
+	fillSinkQueueSize      = 1024
+	fillSinkMaxAttempts    = 5
+	fillSinkInitialBackoff = 50 * time.Millisecond
+	fillSinkPublishTimeout = 2 * time.Second
+)
+
+// FillSink publishes order updates to a downstream system
+type FillSink interface {
+	Name() string
+	Publish(ctx context.Context, response *OrderResponse) error
+}
+
+// RedisFillSink publishes each update on the order's Redis pub/sub channel,
+// encoded with the configured payload codec
+type RedisFillSink struct {
+	client redis.UniversalClient
+	codec  PayloadCodec
+}
+
+// Name implements FillSink
+func (s *RedisFillSink) Name() string { return fillSinkRedis }
+
+// Publish implements FillSink
+func (s *RedisFillSink) Publish(ctx context.Context, response *OrderResponse) error {
+	payload, err := s.codec.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return s.client.Publish(ctx, fmt.Sprintf("order.response.%s", response.OrderID), payload).Err()
+}
+
+// KafkaFillSink writes each update to a Kafka topic, keyed by symbol so all
+// updates for a symbol land on the same partition in order
+type KafkaFillSink struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaFillSink creates a sink writing to topic on the given brokers
+func NewKafkaFillSink(brokers []string, topic string) *KafkaFillSink {
+	return &KafkaFillSink{
+		writer: kafka.NewWriter(kafka.WriterConfig{
+			Brokers:      brokers,
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			MaxAttempts:  1, // retries are handled by the dispatcher
+			BatchTimeout: 10 * time.Millisecond,
+		}),
+	}
+}
+
+// Name implements FillSink
+func (s *KafkaFillSink) Name() string { return fillSinkKafka }
+
+// Publish implements FillSink
+func (s *KafkaFillSink) Publish(ctx context.Context, response *OrderResponse) error {
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return s.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(response.Symbol),
+		Value: responseJSON,
+	})
+}
+
+// fillSubscriberBuffer is how many updates a subscriber may fall behind by
+// before further updates to it are dropped
+const fillSubscriberBuffer = 256
+
+// fillBroadcaster is an in-process sink fanning updates out to live
+// subscribers, such as gRPC SubscribeFills streams. It is always installed.
+type fillBroadcaster struct {
+	metrics *fillSinkMetrics
+
+	mu   sync.Mutex
+	subs map[*fillSubscription]struct{}
+}
+
+// fillSubscription receives the updates for one subscriber
+type fillSubscription struct {
+	symbol  string // empty for every symbol
+	updates chan *OrderResponse
+}
+
+func newFillBroadcaster(metrics *fillSinkMetrics) *fillBroadcaster {
+	return &fillBroadcaster{metrics: metrics, subs: make(map[*fillSubscription]struct{})}
+}
+
+// Name implements FillSink
+func (b *fillBroadcaster) Name() string { return "subscribers" }
+
+// Publish implements FillSink. It never blocks: a subscriber that isn't
+// keeping up misses the update.
+func (b *fillBroadcaster) Publish(ctx context.Context, response *OrderResponse) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		if sub.symbol != "" && sub.symbol != response.Symbol {
+			continue
+		}
+		select {
+		case sub.updates <- response:
+		default:
+			b.metrics.dropped.WithLabelValues(b.Name(), "subscriber_full").Inc()
+		}
+	}
+	return nil
+}
+
+// subscribe registers a subscriber for updates on symbol, or every symbol
+// if it is empty
+func (b *fillBroadcaster) subscribe(symbol string) *fillSubscription {
+	sub := &fillSubscription{symbol: symbol, updates: make(chan *OrderResponse, fillSubscriberBuffer)}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// unsubscribe stops delivering updates to sub
+func (b *fillBroadcaster) unsubscribe(sub *fillSubscription) {
+	b.mu.Lock()
+	delete(b.subs, sub)
+	b.mu.Unlock()
+}
+
+// newFillSinks builds the sinks named in the comma-separated FILL_SINKS config
+func newFillSinks(cfg Config, client redis.UniversalClient) ([]FillSink, error) {
+	codec, err := newPayloadCodec(cfg.StreamCodec)
+	if err != nil {
+		return nil, err
+	}
+	var sinks []FillSink
+	for _, name := range strings.Split(cfg.FillSinks, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case fillSinkRedis:
+			sinks = append(sinks, &RedisFillSink{client: client, codec: codec})
+		case fillSinkKafka:
+			if cfg.KafkaBrokers == "" {
+				return nil, fmt.Errorf("kafka fill sink needs KAFKA_BROKERS")
+			}
+			sinks = append(sinks, NewKafkaFillSink(strings.Split(cfg.KafkaBrokers, ","), cfg.KafkaFillTopic))
+		default:
+			return nil, fmt.Errorf("unknown fill sink %q", name)
+		}
+	}
+	return sinks, nil
+}
+
+// fillSinkMetrics count delivery problems per sink
+type fillSinkMetrics struct {
+	failures *prometheus.CounterVec // failed publish attempts, including retried ones
+	dropped  *prometheus.CounterVec // updates never delivered
+	parked   *prometheus.CounterVec // updates stored for redelivery
+}
+
+func newFillSinkMetrics(registry *prometheus.Registry) *fillSinkMetrics {
+	m := &fillSinkMetrics{
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fill_sink_publish_failures_total",
+			Help: "Failed attempts to publish an order update to a fill sink",
+		}, []string{"sink"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fill_sink_dropped_total",
+			Help: "Order updates a fill sink never received, by cause",
+		}, []string{"sink", "cause"}),
+		parked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fill_sink_parked_total",
+			Help: "Order updates parked for redelivery because a fill sink's queue was full or it exhausted its retries",
+		}, []string{"sink"}),
+	}
+	registry.MustRegister(m.failures, m.dropped, m.parked)
+	return m
+}
+
+// fillDispatcher fans order updates out to every sink through one queue and
+// worker per sink
+type fillDispatcher struct {
+	queues      []chan *OrderResponse
+	sinks       []FillSink
+	metrics     *fillSinkMetrics
+	maxAttempts int
+	backoff     time.Duration
+	pending     *pendingDeliveries // nil drops updates a sink can't take
+	ctx         context.Context
+	wg          sync.WaitGroup
+}
+
+func newFillDispatcher(ctx context.Context, sinks []FillSink, metrics *fillSinkMetrics) *fillDispatcher {
+	d := &fillDispatcher{
+		sinks:       sinks,
+		metrics:     metrics,
+		maxAttempts: fillSinkMaxAttempts,
+		backoff:     fillSinkInitialBackoff,
+		ctx:         ctx,
+	}
+	for _, sink := range sinks {
+		queue := make(chan *OrderResponse, fillSinkQueueSize)
+		d.queues = append(d.queues, queue)
+		d.wg.Add(1)
+		go d.run(ctx, sink, queue)
+	}
+	return d
+}
+
+// dispatch queues an update for every sink without waiting for it, parking
+// it for a sink whose queue is full
+func (d *fillDispatcher) dispatch(response *OrderResponse) {
+	for i, queue := range d.queues {
+		select {
+		case queue <- response:
+		default:
+			sink := d.sinks[i].Name()
+			if d.park(d.ctx, sink, response) {
+				log.Printf("Fill sink %s queue full, parked update for order %s", sink, response.OrderID)
+				continue
+			}
+			d.metrics.dropped.WithLabelValues(sink, "queue_full").Inc()
+			log.Printf("Fill sink %s queue full, dropping update for order %s", sink, response.OrderID)
+		}
+	}
+}
+
+// park stores an update for redelivery to sink, reporting whether it was
+func (d *fillDispatcher) park(ctx context.Context, sink string, response *OrderResponse) bool {
+	if d.pending == nil {
+		return false
+	}
+	if err := d.pending.park(ctx, sink, response); err != nil {
+		log.Printf("Error parking update for order %s: %v", response.OrderID, err)
+		return false
+	}
+	d.metrics.parked.WithLabelValues(sink).Inc()
+	return true
+}
+
+// close stops accepting updates and waits for queued ones to be delivered
+func (d *fillDispatcher) close() {
+	for _, queue := range d.queues {
+		close(queue)
+	}
+	d.wg.Wait()
+}
+
+func (d *fillDispatcher) run(ctx context.Context, sink FillSink, queue <-chan *OrderResponse) {
+	defer d.wg.Done()
+	for response := range queue {
+		d.deliver(ctx, sink, response)
+	}
+}
+
+// deliver publishes one update, retrying with exponential backoff and
+// parking it once retries are exhausted
+func (d *fillDispatcher) deliver(ctx context.Context, sink FillSink, response *OrderResponse) {
+	backoff := d.backoff
+	for attempt := 1; ; attempt++ {
+		publishCtx, cancel := context.WithTimeout(ctx, fillSinkPublishTimeout)
+		err := sink.Publish(publishCtx, response)
+		cancel()
+		if err == nil {
+			return
+		}
+
+		d.metrics.failures.WithLabelValues(sink.Name()).Inc()
+		if attempt >= d.maxAttempts {
+			if d.park(ctx, sink.Name(), response) {
+				log.Printf("Fill sink %s failing (%v), parked update for order %s", sink.Name(), err, response.OrderID)
+				return
+			}
+			d.metrics.dropped.WithLabelValues(sink.Name(), "retries_exhausted").Inc()
+			log.Printf("Fill sink %s giving up on order %s: %v", sink.Name(), response.OrderID, err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// publishResponse sends an order update to every fill sink, by way of the
+// partial fill aggregator when there is one
+func (e *ExecutionEngine) publishResponse(response *OrderResponse) {
+	if e.fills == nil {
+		return
+	}
+	if e.partials != nil {
+		e.partials.offer(response)
+		return
+	}
+	e.fills.dispatch(response)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// mockSink records delivered updates and fails the first failFirst attempts
+// for every update
+type mockSink struct {
+	name      string
+	failFirst int
+
+	mu        sync.Mutex
+	attempts  map[string]int
+	delivered []OrderResponse
+}
+
+func newMockSink(name string, failFirst int) *mockSink {
+	return &mockSink{name: name, failFirst: failFirst, attempts: map[string]int{}}
+}
+
+func (s *mockSink) Name() string { return s.name }
+
+func (s *mockSink) Publish(ctx context.Context, response *OrderResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := response.OrderID + "/" + string(response.Status)
+	s.attempts[key]++
+	if s.attempts[key] <= s.failFirst {
+		return errors.New("sink unavailable")
+	}
+	s.delivered = append(s.delivered, *response)
+	return nil
+}
+
+func submitToEngine(t *testing.T, e *ExecutionEngine, order *OrderRequest) {
+	t.Helper()
+	orderJSON, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.processOrder(redis.XMessage{ID: "0-1", Values: map[string]interface{}{"order": string(orderJSON)}})
+}
+
+func TestFillSinksDeliverExactlyOncePerSink(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	healthy := newMockSink("healthy", 0)
+	flaky := newMockSink("flaky", 2)
+	engine.fills = newFillDispatcher(context.Background(), []FillSink{healthy, flaky}, newFillSinkMetrics(prometheus.NewRegistry()))
+	engine.fills.backoff = 0
+
+	// A resting sell, a buy that fills against it, and a market order on an
+	// empty book: four updates including the resting order's fill
+	submitToEngine(t, engine, limitOrder("s1", "AAPL", "sell", 100, 10))
+	submitToEngine(t, engine, limitOrder("b1", "AAPL", "buy", 100, 10))
+	submitToEngine(t, engine, &OrderRequest{OrderID: "m1", Symbol: "MSFT", Side: "buy", Quantity: dec(5), Type: "market", TimeInForce: "day"})
+	engine.fills.close()
+
+	want := []string{"s1/working", "s1/filled", "b1/filled", "m1/filled"}
+	for _, sink := range []*mockSink{healthy, flaky} {
+		if len(sink.delivered) != len(want) {
+			t.Fatalf("sink %s got %d updates, want %d: %+v", sink.name, len(sink.delivered), len(want), sink.delivered)
+		}
+		seen := map[string]int{}
+		for _, r := range sink.delivered {
+			seen[r.OrderID+"/"+string(r.Status)]++
+			if r.Symbol == "" {
+				t.Errorf("sink %s: update for %s has no symbol", sink.name, r.OrderID)
+			}
+		}
+		for _, key := range want {
+			if seen[key] != 1 {
+				t.Errorf("sink %s delivered %s %d times", sink.name, key, seen[key])
+			}
+		}
+	}
+
+	if got := testutil.ToFloat64(engine.fills.metrics.failures.WithLabelValues("flaky")); got != float64(2*len(want)) {
+		t.Errorf("flaky sink failures = %v, want %d", got, 2*len(want))
+	}
+}
+
+func TestFillSinkGivesUpAfterRetries(t *testing.T) {
+	broken := newMockSink("broken", 100)
+	fills := newFillDispatcher(context.Background(), []FillSink{broken}, newFillSinkMetrics(prometheus.NewRegistry()))
+	fills.backoff = 0
+
+	fills.dispatch(&OrderResponse{OrderID: "o1", Status: "filled"})
+	fills.close()
+
+	if got := broken.attempts["o1/filled"]; got != fillSinkMaxAttempts {
+		t.Errorf("attempts = %d, want %d", got, fillSinkMaxAttempts)
+	}
+	if got := testutil.ToFloat64(fills.metrics.dropped.WithLabelValues("broken", "retries_exhausted")); got != 1 {
+		t.Errorf("dropped = %v, want 1", got)
+	}
+}
+
+func TestNewFillSinks(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.FillSinks = "redis, kafka"
+	if _, err := newFillSinks(cfg, nil); err == nil {
+		t.Error("kafka sink without brokers should fail")
+	}
+
+	cfg.KafkaBrokers = "localhost:9092"
+	sinks, err := newFillSinks(cfg, nil)
+	if err != nil || len(sinks) != 2 || sinks[0].Name() != fillSinkRedis || sinks[1].Name() != fillSinkKafka {
+		t.Fatalf("unexpected sinks %v (%v)", sinks, err)
+	}
+
+	cfg.FillSinks = "redis,carrier-pigeon"
+	if _, err := newFillSinks(cfg, nil); err == nil {
+		t.Error("unknown sink should fail")
+	}
+}
+
+func TestExhaustedUpdateIsParkedAndRedelivered(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	flaky := newMockSink("flaky", 2)
+	fills := newFillDispatcher(context.Background(), []FillSink{flaky}, newFillSinkMetrics(prometheus.NewRegistry()))
+	fills.maxAttempts = 1
+	fills.backoff = 0
+	fills.pending = &pendingDeliveries{client: engine.redisClient, stream: "test.pending_delivery"}
+
+	fills.dispatch(&OrderResponse{OrderID: "o1", Symbol: "AAPL", Status: statusFilled})
+	fills.close()
+	if len(flaky.delivered) != 0 {
+		t.Fatal("update delivered despite the failing sink")
+	}
+	if got := testutil.ToFloat64(fills.metrics.parked.WithLabelValues("flaky")); got != 1 {
+		t.Fatalf("parked = %v, want 1", got)
+	}
+
+	// The second publish fails too; the third gets through
+	for round, want := range []int{0, 1} {
+		delivered, err := fills.redeliver(context.Background())
+		if err != nil || delivered != want {
+			t.Fatalf("redelivery round %d: delivered %d (%v), want %d", round, delivered, err, want)
+		}
+	}
+	if len(flaky.delivered) != 1 || flaky.delivered[0].OrderID != "o1" || flaky.delivered[0].Status != statusFilled {
+		t.Fatalf("delivered %+v, want the o1 fill", flaky.delivered)
+	}
+	if n := engine.redisClient.XLen(context.Background(), "test.pending_delivery").Val(); n != 0 {
+		t.Errorf("%d updates still parked after redelivery", n)
+	}
+	if got := testutil.ToFloat64(fills.metrics.failures.WithLabelValues("flaky")); got != 2 {
+		t.Errorf("failures = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(fills.metrics.dropped.WithLabelValues("flaky", "retries_exhausted")); got != 0 {
+		t.Errorf("dropped = %v, want 0", got)
+	}
+}
+
+// blockingSink holds up every publish until released
+type blockingSink struct {
+	*mockSink
+	release chan struct{}
+}
+
+func (s *blockingSink) Publish(ctx context.Context, response *OrderResponse) error {
+	<-s.release
+	return s.mockSink.Publish(ctx, response)
+}
+
+func TestUpdateForAFullQueueIsParked(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	slow := &blockingSink{mockSink: newMockSink("slow", 0), release: make(chan struct{})}
+	fills := newFillDispatcher(context.Background(), []FillSink{slow}, newFillSinkMetrics(prometheus.NewRegistry()))
+	fills.pending = &pendingDeliveries{client: engine.redisClient, stream: "test.pending_delivery"}
+
+	// One update held by the worker and a queue's worth behind it
+	for i := 0; i <= fillSinkQueueSize; i++ {
+		fills.dispatch(&OrderResponse{OrderID: "queued", Status: statusWorking})
+		if i == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	fills.dispatch(&OrderResponse{OrderID: "overflow", Status: statusFilled})
+	close(slow.release)
+	fills.close()
+
+	if got := testutil.ToFloat64(fills.metrics.dropped.WithLabelValues("slow", "queue_full")); got != 0 {
+		t.Errorf("dropped = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(fills.metrics.parked.WithLabelValues("slow")); got != 1 {
+		t.Fatalf("parked = %v, want 1", got)
+	}
+	if delivered, err := fills.redeliver(context.Background()); err != nil || delivered != 1 {
+		t.Fatalf("redelivered %d (%v), want the overflow", delivered, err)
+	}
+	if last := slow.delivered[len(slow.delivered)-1]; last.OrderID != "overflow" || last.Status != statusFilled {
+		t.Errorf("last delivered %+v, want the overflow fill", last)
+	}
+}
+
+func TestRedeliveryPagesThroughEveryParkedUpdate(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	healthy := newMockSink("healthy", 0)
+	down := newMockSink("down", 1000)
+	fills := newFillDispatcher(context.Background(), []FillSink{healthy, down}, newFillSinkMetrics(prometheus.NewRegistry()))
+	fills.pending = &pendingDeliveries{client: engine.redisClient, stream: "test.pending_delivery"}
+	defer fills.close()
+
+	// More than a batch for the healthy sink, behind a page of the down one's
+	ctx := context.Background()
+	for i := 0; i < redeliveryBatch; i++ {
+		if err := fills.pending.park(ctx, "down", &OrderResponse{OrderID: fmt.Sprintf("down-%d", i), Status: statusFilled}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2*redeliveryBatch+1; i++ {
+		if err := fills.pending.park(ctx, "healthy", &OrderResponse{OrderID: fmt.Sprintf("up-%d", i), Status: statusFilled}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	delivered, err := fills.redeliver(ctx)
+	if err != nil || delivered != 2*redeliveryBatch+1 {
+		t.Fatalf("redelivered %d (%v), want all %d for the healthy sink", delivered, err, 2*redeliveryBatch+1)
+	}
+	if got := testutil.ToFloat64(fills.metrics.failures.WithLabelValues("down")); got != 1 {
+		t.Errorf("down sink tried %v times in the round, want once", got)
+	}
+	if n := engine.redisClient.XLen(ctx, "test.pending_delivery").Val(); n != redeliveryBatch {
+		t.Errorf("%d updates parked after redelivery, want the down sink's %d", n, redeliveryBatch)
+	}
+}
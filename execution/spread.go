@@ -0,0 +1,298 @@
+// ==============================================================================
+// Spread orders - several legs that fill together at a net price
+// ==============================================================================
+// An order of type "spread" carries legs, each a symbol, a side and a ratio
+// (1 if omitted), and trades quantity units of the spread: every leg trades
+// ratio * quantity in its own book. Buying the spread executes the legs as
+// given; selling it executes each leg on the opposite side. The net price
+// of a unit is the sum of each leg's average price times its ratio, counted
+// positive for legs given as buys and negative for legs given as sells, so
+// a calendar spread bought at 1.25 pays at most 1.25 more for the long leg
+// than it receives for the short one. limit_price is that net price: at
+// most it when buying, at least it when selling; it may be zero or negative.
+//
+// Spreads are all-or-none and execute atomically, holding the locks of all
+// their legs' books: every leg is priced against its book first, and only if every leg can fill in full and
+// the net price meets the limit do all of them trade. Otherwise nothing
+// trades and the spread is rejected with "insufficient_liquidity" or
+// "net_price_not_met". Legs never rest. Like a dry run, a leg passes over
+// resting orders of the spread's own account instead of trading with them.
+//
+// A spread is booked under the symbol of its legs joined with "/" (e.g.
+// "ESZ4/ESH5") unless it names one, and every leg symbol has to be permitted
+// and not halted. The response reports the spread's quantity and net price,
+// and each leg's fills under legs; positions, fees and stops follow the legs.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// rejectNetPriceNotMet is the reason for spreads whose legs would trade
+// through the spread's limit
+const rejectNetPriceNotMet = "net_price_not_met"
+
+// maxSpreadLegs is the most legs one spread may have
+const maxSpreadLegs = 4
+
+// SpreadLeg is one leg of a spread order
+type SpreadLeg struct {
+	Symbol string          `json:"symbol"`
+	Side   string          `json:"side"`
+	Ratio  decimal.Decimal `json:"ratio,omitempty"` // units of the leg per unit of the spread; 1 if zero
+}
+
+// MarshalJSON leaves out an unset ratio (see decimal.go)
+func (l SpreadLeg) MarshalJSON() ([]byte, error) {
+	type plain SpreadLeg
+	return json.Marshal(struct {
+		plain
+		Ratio *decimal.Decimal `json:"ratio,omitempty"`
+	}{plain(l), omitZero(l.Ratio)})
+}
+
+// LegExecution is what one leg of a spread traded
+type LegExecution struct {
+	Symbol         string          `json:"symbol"`
+	Side           string          `json:"side"` // as executed
+	FilledQuantity decimal.Decimal `json:"filled_quantity"`
+	FilledAvgPrice decimal.Decimal `json:"filled_avg_price"`
+	Commission     decimal.Decimal `json:"commission"`
+	Fees           decimal.Decimal `json:"fees"`
+	Fills          []BookFill      `json:"fills,omitempty"`
+}
+
+// ratio is the leg's units per spread unit
+func (l SpreadLeg) ratio() decimal.Decimal {
+	if l.Ratio.IsZero() {
+		return decimal.NewFromInt(1)
+	}
+	return l.Ratio
+}
+
+// spreadSymbol is the symbol a spread is booked under
+func spreadSymbol(legs []SpreadLeg) string {
+	symbols := make([]string, len(legs))
+	for i, leg := range legs {
+		symbols[i] = leg.Symbol
+	}
+	return strings.Join(symbols, "/")
+}
+
+// validateSpread checks a spread order's legs and the fields spreads can't
+// have
+func validateSpread(order *OrderRequest) error {
+	if len(order.Legs) < 2 || len(order.Legs) > maxSpreadLegs {
+		return fmt.Errorf("spread order requires 2 to %d legs", maxSpreadLegs)
+	}
+	seen := map[string]bool{}
+	for i, leg := range order.Legs {
+		if leg.Symbol == "" {
+			return fmt.Errorf("leg %d: symbol is required", i)
+		}
+		if seen[leg.Symbol] {
+			return fmt.Errorf("leg %d: symbol %s is already a leg", i, leg.Symbol)
+		}
+		seen[leg.Symbol] = true
+		if leg.Side != "buy" && leg.Side != "sell" {
+			return fmt.Errorf("leg %d: invalid side %q", i, leg.Side)
+		}
+		if leg.Ratio.IsNegative() {
+			return fmt.Errorf("leg %d: ratio must be positive", i)
+		}
+	}
+	if order.ReduceOnly || order.OCOGroup != "" {
+		return fmt.Errorf("spread orders can't be reduce_only or in an OCO group")
+	}
+	return nil
+}
+
+// normalizeSpread moves a spread's legs onto their canonical symbols and
+// books the spread under them
+func (e *ExecutionEngine) normalizeSpread(order *OrderRequest) {
+	for i := range order.Legs {
+		order.Legs[i].Symbol = e.canonicalSymbol(order.Legs[i].Symbol)
+	}
+	symbol := spreadSymbol(order.Legs)
+	if order.Symbol != "" && order.Symbol != symbol && order.ClientSymbol == "" {
+		order.ClientSymbol = order.Symbol
+	}
+	order.Symbol = symbol
+}
+
+// orderSymbols are the symbols an order trades: its legs' for a spread
+func orderSymbols(order *OrderRequest) []string {
+	if len(order.Legs) == 0 {
+		return []string{order.Symbol}
+	}
+	symbols := make([]string, len(order.Legs))
+	for i, leg := range order.Legs {
+		symbols[i] = leg.Symbol
+	}
+	return symbols
+}
+
+// orderPermitted returns the first symbol order trades that the symbol
+// policy refuses, if any
+func (e *ExecutionEngine) orderPermitted(order *OrderRequest) (string, bool) {
+	for _, symbol := range orderSymbols(order) {
+		if !e.symbolPermitted(symbol) {
+			return symbol, false
+		}
+	}
+	return "", true
+}
+
+// orderHalted returns the first symbol order trades that is halted, if any
+func (e *ExecutionEngine) orderHalted(order *OrderRequest) (string, bool) {
+	for _, symbol := range orderSymbols(order) {
+		if e.halts.isHalted(symbol) {
+			return symbol, true
+		}
+	}
+	return "", false
+}
+
+// executedSide is the side a leg trades on when the spread is bought or sold
+func executedSide(spreadSide string, leg SpreadLeg) string {
+	if spreadSide == "sell" {
+		return oppositeSide(leg.Side)
+	}
+	return leg.Side
+}
+
+// priceSpread works out what every leg of a spread would trade right now and
+// the net price, or why the spread can't execute. Callers must hold the locks
+// of the legs' books (see lockSpread).
+func (e *ExecutionEngine) priceSpread(order *OrderRequest) ([]LegExecution, decimal.Decimal, *rejection) {
+	legs := make([]LegExecution, len(order.Legs))
+	var net decimal.Decimal
+	for i, leg := range order.Legs {
+		side := executedSide(order.Side, leg)
+		quantity := leg.ratio().Mul(order.Quantity)
+		fills := e.bookFor(leg.Symbol).Estimate(side, decimal.Zero, quantity, order.AccountID)
+
+		var filled, notional decimal.Decimal
+		for _, fill := range fills {
+			filled = filled.Add(fill.Quantity)
+			notional = notional.Add(fill.Price.Mul(fill.Quantity))
+		}
+		if filled.LessThan(quantity) {
+			return nil, decimal.Zero, &rejection{
+				Reason: rejectInsufficientLiquidity,
+				Detail: fmt.Sprintf("leg %s can fill %s of %s", leg.Symbol, filled, quantity),
+				Limit:  &RiskLimit{Name: riskLimitMinFillQuantity, Limit: quantity, Current: filled},
+			}
+		}
+		average := notional.Div(filled)
+		legs[i] = LegExecution{Symbol: leg.Symbol, Side: side, FilledQuantity: filled, FilledAvgPrice: average, Fills: fills}
+		if leg.Side == "buy" {
+			net = net.Add(average.Mul(leg.ratio()))
+		} else {
+			net = net.Sub(average.Mul(leg.ratio()))
+		}
+	}
+	if (order.Side == "buy" && net.GreaterThan(order.LimitPrice)) || (order.Side == "sell" && net.LessThan(order.LimitPrice)) {
+		return nil, decimal.Zero, &rejection{
+			Reason: rejectNetPriceNotMet,
+			Detail: fmt.Sprintf("net price %s against a limit of %s", net, order.LimitPrice),
+		}
+	}
+	return legs, net, nil
+}
+
+// lockSpread locks the books of a spread's legs and returns the unlock
+func (e *ExecutionEngine) lockSpread(order *OrderRequest) func() {
+	symbols := make([]string, len(order.Legs))
+	for i, leg := range order.Legs {
+		symbols[i] = leg.Symbol
+	}
+	return e.lockBooksOf(symbols)
+}
+
+// matchSpread executes all legs of a spread against their books, or none. It
+// returns nil if the order timed out before it got the books.
+func (e *ExecutionEngine) matchSpread(ctx context.Context, order *OrderRequest) *OrderResponse {
+	defer e.lockSpread(order)()
+	if !commitExecution(ctx) {
+		return nil
+	}
+
+	matchStart := latencyStart()
+	legs, net, rej := e.priceSpread(order)
+	e.observeMatchLatency(matchStart)
+	if rej != nil {
+		return e.bookRejection(order, rej)
+	}
+
+	// Every leg can fill at an acceptable net price: trade them all
+	response := &OrderResponse{
+		OrderID:        order.OrderID,
+		ClientOrderID:  order.IdempotencyKey,
+		Symbol:         order.Symbol,
+		Status:         statusFilled,
+		FilledQuantity: order.Quantity,
+		FilledAvgPrice: net,
+		LiquidityFlag:  liquidityTaker,
+	}
+	matchedAt := e.now().UnixMilli()
+	for i := range legs {
+		leg := &legs[i]
+		book := e.bookFor(leg.Symbol)
+		for j, fill := range leg.Fills {
+			leg.Fills[j].Timestamp = matchedAt
+			resting, _ := book.Get(fill.RestingOrderID)
+			leg.Fills[j].restingAccount = resting.AccountID
+			leg.Fills[j].restingReduceOnly = resting.ReduceOnly
+			book.reduce(resting, fill.Quantity)
+			e.journal(book, bookMutation{Op: journalOpFill, Symbol: leg.Symbol, OrderID: fill.RestingOrderID, Quantity: fill.Quantity})
+			e.applyRestingFill(book, leg.Fills[j], oppositeSide(leg.Side))
+		}
+		e.prices.record(leg.Symbol, leg.Fills[len(leg.Fills)-1].Price)
+		e.prices.recordVolume(leg.Symbol, leg.FilledQuantity)
+		charge := e.chargeFill(leg.FilledQuantity, leg.FilledAvgPrice, liquidityTaker)
+		leg.Commission, leg.Fees = charge.Commission, charge.Fees
+		response.addCharge(charge)
+		e.repeg(book)
+	}
+	response.Legs = legs
+	return response
+}
+
+// dryRunSpread estimates a spread's execution into response without trading
+func (e *ExecutionEngine) dryRunSpread(order *OrderRequest, response *OrderResponse) *OrderResponse {
+	defer e.lockSpread(order)()
+	legs, net, rej := e.priceSpread(order)
+	if rej != nil {
+		response.Status = statusRejected
+		response.RejectReason = rej.Reason
+		return response
+	}
+	response.Legs = legs
+	response.FilledQuantity = order.Quantity
+	response.FilledAvgPrice = net
+	return response
+}
+
+// applyPositions books an executed order's fills into its account's
+// positions, leg by leg for a spread
+func (e *ExecutionEngine) applyPositions(order *OrderRequest, response *OrderResponse) {
+	if len(response.Legs) == 0 {
+		apply := e.positions.Apply
+		if order.ReduceOnly {
+			apply = e.positions.ApplyReduceOnly
+		}
+		apply(order.AccountID, order.Symbol, order.Side, response.FilledQuantity, response.FilledAvgPrice, response.Commission.Add(response.Fees))
+		return
+	}
+	for _, leg := range response.Legs {
+		e.positions.Apply(order.AccountID, leg.Symbol, leg.Side, leg.FilledQuantity, leg.FilledAvgPrice, leg.Commission.Add(leg.Fees))
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import "testing"
+
+// calendarSpread buys ESZ4 and sells ESH5 for acct-1
+func calendarSpread(id string, qty float64, limit float64) *OrderRequest {
+	return &OrderRequest{
+		OrderID:     id,
+		Side:        "buy",
+		Type:        "spread",
+		Quantity:    dec(qty),
+		LimitPrice:  dec(limit),
+		TimeInForce: "day",
+		AccountID:   "acct-1",
+		Legs: []SpreadLeg{
+			{Symbol: "ESZ4", Side: "buy"},
+			{Symbol: "ESH5", Side: "sell"},
+		},
+	}
+}
+
+// seedLeg rests a market maker's order in one leg's book
+func seedLeg(t *testing.T, engine *ExecutionEngine, id string, sym string, side string, price float64) {
+	t.Helper()
+	order := limitOrder(id, sym, side, price, 5)
+	order.AccountID = "acct-mm"
+	submitToEngine(t, engine, order)
+}
+
+// restingQuantity is what is left of a resting order, zero once gone
+func restingQuantity(engine *ExecutionEngine, sym string, id string) float64 {
+	book := engine.bookFor(sym)
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	if order, ok := book.Get(id); ok {
+		qty, _ := order.Quantity.Float64()
+		return qty
+	}
+	return 0
+}
+
+func TestSpreadFillsAllLegsAtNetPrice(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	seedLeg(t, engine, "ask-z4", "ESZ4", "sell", 100)
+	seedLeg(t, engine, "bid-h5", "ESH5", "buy", 98.5)
+
+	submitToEngine(t, engine, calendarSpread("spread-1", 3, 2))
+
+	response, _ := engine.GetOrder("spread-1")
+	if response.Status != statusFilled || !response.FilledQuantity.Equal(dec(3)) || !response.FilledAvgPrice.Equal(dec(1.5)) {
+		t.Fatalf("spread: %s %s @ %s (%s), want filled 3 @ 1.5", response.Status, response.FilledQuantity, response.FilledAvgPrice, response.RejectReason)
+	}
+	if response.Symbol != "ESZ4/ESH5" || len(response.Legs) != 2 {
+		t.Fatalf("spread symbol %q with %d legs, want ESZ4/ESH5 with 2", response.Symbol, len(response.Legs))
+	}
+	if leg := response.Legs[1]; leg.Side != "sell" || !leg.FilledAvgPrice.Equal(dec(98.5)) {
+		t.Errorf("second leg %s @ %s, want sell @ 98.5", leg.Side, leg.FilledAvgPrice)
+	}
+
+	for _, want := range []struct {
+		sym string
+		qty float64
+	}{{"ESZ4", 3}, {"ESH5", -3}} {
+		position, _ := engine.positions.Get("acct-1", want.sym)
+		if !position.Quantity.Equal(dec(want.qty)) {
+			t.Errorf("acct-1 %s position = %s, want %v", want.sym, position.Quantity, want.qty)
+		}
+	}
+	if left := restingQuantity(engine, "ESZ4", "ask-z4"); left != 2 {
+		t.Errorf("ESZ4 ask left %v, want 2", left)
+	}
+	if maker, _ := engine.GetOrder("bid-h5"); maker.Status != statusPartiallyFilled || !maker.FilledQuantity.Equal(dec(3)) {
+		t.Errorf("ESH5 bid: %s %s, want partially filled 3", maker.Status, maker.FilledQuantity)
+	}
+}
+
+func TestSpreadRejectedWhenALegLacksLiquidity(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	seedLeg(t, engine, "ask-z4", "ESZ4", "sell", 100)
+
+	submitToEngine(t, engine, calendarSpread("spread-1", 3, 2))
+
+	response, _ := engine.GetOrder("spread-1")
+	if response.Status != statusRejected || response.RejectReason != rejectInsufficientLiquidity {
+		t.Fatalf("spread without ESH5 bids: %s/%s, want %s rejection", response.Status, response.RejectReason, rejectInsufficientLiquidity)
+	}
+	if left := restingQuantity(engine, "ESZ4", "ask-z4"); left != 5 {
+		t.Errorf("ESZ4 ask left %v, want untouched 5", left)
+	}
+	if position, ok := engine.positions.Get("acct-1", "ESZ4"); ok && !position.Quantity.IsZero() {
+		t.Errorf("acct-1 ESZ4 position = %s, want none", position.Quantity)
+	}
+}
+
+func TestSpreadRejectedWhenNetPriceMissesLimit(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	seedLeg(t, engine, "ask-z4", "ESZ4", "sell", 100)
+	seedLeg(t, engine, "bid-h5", "ESH5", "buy", 98.5)
+
+	submitToEngine(t, engine, calendarSpread("spread-1", 3, 1))
+
+	response, _ := engine.GetOrder("spread-1")
+	if response.Status != statusRejected || response.RejectReason != rejectNetPriceNotMet {
+		t.Fatalf("spread at 1.5 with a limit of 1: %s/%s, want %s rejection", response.Status, response.RejectReason, rejectNetPriceNotMet)
+	}
+	if left := restingQuantity(engine, "ESH5", "bid-h5"); left != 5 {
+		t.Errorf("ESH5 bid left %v, want untouched 5", left)
+	}
+}
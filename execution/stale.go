@@ -0,0 +1,44 @@
+// ==============================================================================
+// Stale orders - refusing orders that waited too long in the queue
+// ==============================================================================
+// An order's timestamp is when its submitter created it, in unix ms. With
+// MAX_ORDER_AGE set, processOrder rejects an order picked up more than that
+// long after its timestamp with "order_stale": the signal it was sent on has
+// likely moved on. Timestamps more than MAX_ORDER_CLOCK_SKEW ahead of the
+// engine's clock are implausible and rejected the same way, so a submitter
+// with a fast clock can't stretch the window. Orders without a timestamp,
+// and orders re-entering processing after being held, aren't checked.
+// ==============================================================================
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// rejectOrderStale is the reason for orders too old, or too far in the
+// future, when picked up
+const rejectOrderStale = "order_stale"
+
+// checkOrderAge rejects orders whose timestamp is outside the accepted
+// window around now
+func (e *ExecutionEngine) checkOrderAge(order *OrderRequest) *rejection {
+	if e.config.MaxOrderAge <= 0 || order.Timestamp == 0 {
+		return nil
+	}
+	age := e.now().Sub(time.UnixMilli(order.Timestamp))
+	if age > e.config.MaxOrderAge {
+		return &rejection{
+			Reason: rejectOrderStale,
+			Detail: fmt.Sprintf("order is %s old, more than the %s allowed", age, e.config.MaxOrderAge),
+		}
+	}
+	if -age > e.config.MaxOrderClockSkew {
+		return &rejection{
+			Reason: rejectOrderStale,
+			Detail: fmt.Sprintf("order timestamp is %s in the future", -age),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStaleOrdersRejectedAtPickup(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+	engine.config.MaxOrderAge = 5 * time.Second
+	engine.config.MaxOrderClockSkew = time.Second
+
+	for _, tc := range []struct {
+		id         string
+		offset     time.Duration // timestamp relative to the engine's clock
+		wantReject bool
+	}{
+		{"fresh", -2 * time.Second, false},
+		{"slightly-ahead", 500 * time.Millisecond, false},
+		{"stale", -6 * time.Second, true},
+		{"future", 10 * time.Second, true},
+	} {
+		order := testOrder(tc.id)
+		order.Timestamp = clock.Now().Add(tc.offset).UnixMilli()
+		submitToEngine(t, engine, &order)
+
+		response, ok := engine.GetOrder(tc.id)
+		if !ok {
+			t.Fatalf("%s: no response", tc.id)
+		}
+		rejected := response.Status == statusRejected && response.RejectReason == rejectOrderStale
+		if rejected != tc.wantReject {
+			t.Errorf("%s: status %s (%s), want rejected as stale = %v", tc.id, response.Status, response.RejectReason, tc.wantReject)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// ==============================================================================
+// Startup - wait for Redis before taking orders
+// ==============================================================================
+// Start pings Redis before doing anything else, retrying with backoff (from
+// redisStartupInitialBackoff, doubling up to redisStartupMaxBackoff) until it
+// answers. If it still hasn't after REDIS_STARTUP_TIMEOUT, Start fails and
+// the process exits rather than serving with nothing behind it.
+//
+// The HTTP server comes up first so orchestrators can probe it: /health
+// answers as soon as it listens, while /ready reports "starting" with a 503
+// until Start has recovered the books and the consumer is running.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Backoff between Redis pings at startup
+const (
+	redisStartupInitialBackoff = 100 * time.Millisecond
+	redisStartupMaxBackoff     = 5 * time.Second
+)
+
+// waitForRedis pings Redis until it answers, giving up after timeout
+func (e *ExecutionEngine) waitForRedis(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := redisStartupInitialBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithDeadline(e.ctx, deadline)
+		err := e.redisClient.Ping(ctx).Err()
+		cancel()
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("Redis reachable after %d attempts", attempt)
+			}
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 || e.ctx.Err() != nil {
+			return fmt.Errorf("redis not reachable within %s: %w", timeout, err)
+		}
+		log.Printf("Redis not reachable (%v), retrying in %s", err, backoff)
+		select {
+		case <-time.After(min(backoff, remaining)):
+		case <-e.ctx.Done():
+			return e.ctx.Err()
+		}
+		backoff = min(2*backoff, redisStartupMaxBackoff)
+	}
+}
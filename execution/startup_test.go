@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// unstartedRedis returns a Redis that isn't listening yet on addr
+func unstartedRedis(t *testing.T) (*miniredis.Miniredis, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	mr := miniredis.NewMiniRedis()
+	t.Cleanup(mr.Close)
+	return mr, addr
+}
+
+// startingEngine creates an engine for the Redis at addr, stopped with the
+// test
+func startingEngine(t *testing.T, addr string, timeout time.Duration) *ExecutionEngine {
+	t.Helper()
+	host, port, _ := net.SplitHostPort(addr)
+	engine := NewExecutionEngine(host, port, "test-stream")
+	engine.config.RedisStartupTimeout = timeout
+	engine.config.BookSnapshotInterval = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	engine.ctx = ctx
+	t.Cleanup(func() {
+		cancel()
+		engine.redisClient.Close()
+	})
+	return engine
+}
+
+func readyCode(engine *ExecutionEngine) int {
+	rec := httptest.NewRecorder()
+	engine.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	return rec.Code
+}
+
+func TestStartWaitsForRedis(t *testing.T) {
+	mr, addr := unstartedRedis(t)
+	engine := startingEngine(t, addr, 10*time.Second)
+
+	started := make(chan error, 1)
+	go func() { started <- engine.Start() }()
+
+	time.Sleep(300 * time.Millisecond)
+	select {
+	case err := <-started:
+		t.Fatalf("Start returned %v before Redis was up", err)
+	default:
+	}
+	if code := readyCode(engine); code != http.StatusServiceUnavailable {
+		t.Fatalf("/ready while waiting for Redis: %d, want 503", code)
+	}
+
+	if err := mr.StartAddr(addr); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start didn't notice Redis come up")
+	}
+	if code := readyCode(engine); code != http.StatusOK {
+		t.Fatalf("/ready once started: %d, want 200", code)
+	}
+}
+
+func TestStartFailsWhenRedisNeverComes(t *testing.T) {
+	_, addr := unstartedRedis(t)
+	engine := startingEngine(t, addr, 300*time.Millisecond)
+
+	began := time.Now()
+	if err := engine.Start(); err == nil {
+		t.Fatal("Start succeeded without Redis")
+	}
+	if elapsed := time.Since(began); elapsed > 3*time.Second {
+		t.Errorf("Start gave up after %v, want about 300ms", elapsed)
+	}
+}
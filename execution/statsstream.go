@@ -0,0 +1,210 @@
+// ==============================================================================
+// Stats stream - live engine stats pushed to dashboards
+// ==============================================================================
+// GET /stats/stream is a Server-Sent Events stream of engine stats, so a
+// dashboard can show them live without polling /metrics. Every
+// STATS_STREAM_INTERVAL (1s by default, 0 disables the stream) the engine
+// takes one snapshot and sends it to every subscriber as a "stats" event:
+//
+//   event: stats
+//   data: {"timestamp":1700000000000,"orders_processed":1200,"orders_rejected":12,
+//          "throughput_per_sec":85.5,"reject_rate":0.01,"backlog":3,
+//          "latency":{"count":1200,"p50_ms":0.41,...}}
+//
+// The snapshot reads the state the other endpoints do: the processed and
+// rejected counters behind /metrics, the latency sketch behind
+// /stats/latency, the outcome window behind order_rejection_ratio (so
+// reject_rate covers METRICS_WINDOW) and the consumer group's backlog, left
+// out when Redis can't report it. Throughput is the orders processed per
+// second over the last interval.
+//
+// Snapshots are taken once per interval however many subscribers there are,
+// and handed to each without blocking: a subscriber still writing out the
+// previous one when the next is ready gets the newer one instead, so a slow
+// dashboard skips snapshots rather than holding up the others. Subscribers
+// are dropped as soon as their connection goes, and streams end when the
+// engine stops.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// StatsSnapshot is one event on GET /stats/stream
+type StatsSnapshot struct {
+	Timestamp        int64        `json:"timestamp"`
+	OrdersProcessed  uint64       `json:"orders_processed"`
+	OrdersRejected   uint64       `json:"orders_rejected"`
+	ThroughputPerSec float64      `json:"throughput_per_sec"`
+	RejectRate       float64      `json:"reject_rate"`
+	Backlog          *int64       `json:"backlog,omitempty"`
+	Latency          LatencyStats `json:"latency"`
+}
+
+// statsStream fans snapshots out to the subscribers of GET /stats/stream
+type statsStream struct {
+	mu   sync.Mutex
+	subs map[chan StatsSnapshot]struct{}
+
+	// When the previous snapshot was taken and the processed count then
+	lastAt        time.Time
+	lastProcessed float64
+}
+
+func newStatsStream() *statsStream {
+	return &statsStream{subs: map[chan StatsSnapshot]struct{}{}}
+}
+
+// subscribe registers a subscriber. Its channel holds the latest snapshot
+// it hasn't taken yet.
+func (s *statsStream) subscribe() chan StatsSnapshot {
+	ch := make(chan StatsSnapshot, 1)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+	return ch
+}
+
+// unsubscribe stops sending snapshots to ch
+func (s *statsStream) unsubscribe(ch chan StatsSnapshot) {
+	s.mu.Lock()
+	delete(s.subs, ch)
+	s.mu.Unlock()
+}
+
+// publish hands snapshot to every subscriber, replacing one it hasn't taken
+func (s *statsStream) publish(snapshot StatsSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- snapshot
+	}
+}
+
+// subscribers is the number of subscribers
+func (s *statsStream) subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs)
+}
+
+// throughput returns the orders processed per second since the previous
+// call (or since startup), now that processed have been
+func (e *ExecutionEngine) throughput(now time.Time, processed float64) float64 {
+	e.stats.mu.Lock()
+	defer e.stats.mu.Unlock()
+	since, before := e.stats.lastAt, e.stats.lastProcessed
+	if since.IsZero() {
+		since = e.startedAt
+	}
+	e.stats.lastAt, e.stats.lastProcessed = now, processed
+	if elapsed := now.Sub(since); elapsed > 0 && !since.IsZero() {
+		return (processed - before) / elapsed.Seconds()
+	}
+	return 0
+}
+
+// counterValue reads a Prometheus counter
+func counterValue(c prometheus.Counter) float64 {
+	var m dto.Metric
+	if c == nil || c.Write(&m) != nil {
+		return 0
+	}
+	return m.GetCounter().GetValue()
+}
+
+// takeStatsSnapshot takes a snapshot
+func (e *ExecutionEngine) takeStatsSnapshot(ctx context.Context) StatsSnapshot {
+	now := e.now()
+	processed := counterValue(e.ordersProcessed)
+	snapshot := StatsSnapshot{
+		Timestamp:        now.UnixMilli(),
+		OrdersProcessed:  uint64(processed),
+		OrdersRejected:   uint64(counterValue(e.ordersRejected)),
+		ThroughputPerSec: e.throughput(now, processed),
+		Latency:          e.latencyStats(),
+	}
+
+	if ok, rejected := e.outcomes.totals(0); ok+rejected > 0 {
+		snapshot.RejectRate = float64(rejected) / float64(ok+rejected)
+	}
+	if backlog, err := e.streamBacklog(ctx); err == nil {
+		snapshot.Backlog = &backlog
+	}
+	return snapshot
+}
+
+// statsLoop publishes a snapshot every interval while anyone is subscribed,
+// and otherwise just keeps throughput current, until ctx is cancelled
+func (e *ExecutionEngine) statsLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if e.stats.subscribers() == 0 {
+				e.throughput(e.now(), counterValue(e.ordersProcessed))
+				continue
+			}
+			e.stats.publish(e.takeStatsSnapshot(ctx))
+		}
+	}
+}
+
+// handleStatsStream serves GET /stats/stream
+func (e *ExecutionEngine) handleStatsStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	if e.config.StatsStreamInterval <= 0 {
+		writeError(w, errCodeUnavailable, "Stats streaming is disabled")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, errCodeInternal, "Streaming is not supported")
+		return
+	}
+
+	snapshots := e.stats.subscribe()
+	defer e.stats.unsubscribe(snapshots)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-e.ctx.Done():
+			return
+		case snapshot := <-snapshots:
+			data, err := json.Marshal(snapshot)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: stats\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsStreamPushesPeriodicSnapshots(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.config.StatsStreamInterval = 20 * time.Millisecond
+	for i := 0; i < 3; i++ {
+		order := testOrder(fmt.Sprintf("ok-%d", i))
+		submitToEngine(t, engine, &order)
+	}
+	bad := testOrder("bad")
+	bad.Quantity = dec(0)
+	submitToEngine(t, engine, &bad)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go engine.statsLoop(ctx, engine.config.StatsStreamInterval)
+	server := httptest.NewServer(engine.routes())
+	defer server.Close()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/stats/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type %q, want text/event-stream", ct)
+	}
+
+	var snapshots []map[string]interface{}
+	scanner := bufio.NewScanner(resp.Body)
+	for len(snapshots) < 2 && scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var snapshot map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &snapshot); err != nil {
+			t.Fatalf("snapshot %s: %v", data, err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if len(snapshots) < 2 {
+		t.Fatalf("got %d snapshots before the stream ended: %v", len(snapshots), scanner.Err())
+	}
+
+	for _, field := range []string{"timestamp", "orders_processed", "orders_rejected", "throughput_per_sec", "reject_rate", "latency"} {
+		if _, ok := snapshots[0][field]; !ok {
+			t.Errorf("snapshot has no %s: %v", field, snapshots[0])
+		}
+	}
+	if snapshots[1]["timestamp"].(float64) <= snapshots[0]["timestamp"].(float64) {
+		t.Errorf("snapshot timestamps %v then %v, want them to advance", snapshots[0]["timestamp"], snapshots[1]["timestamp"])
+	}
+	if processed, rejected := snapshots[0]["orders_processed"], snapshots[0]["orders_rejected"]; processed != 3.0 || rejected != 1.0 {
+		t.Errorf("%v orders processed and %v rejected, want 3 and 1", processed, rejected)
+	}
+	if got := snapshots[0]["reject_rate"]; got != 0.25 {
+		t.Errorf("reject_rate = %v, want 0.25", got)
+	}
+	if latency := snapshots[0]["latency"].(map[string]interface{}); latency["count"] != 3.0 {
+		t.Errorf("latency = %v, want 3 orders counted", latency)
+	}
+}
+
+func TestSlowStatsSubscribersOnlyMissSnapshots(t *testing.T) {
+	stream := newStatsStream()
+	slow := stream.subscribe()
+
+	// Nobody reads slow, yet publishing goes on and it ends up with the newest
+	done := make(chan struct{})
+	go func() {
+		for i := int64(1); i <= 5; i++ {
+			stream.publish(StatsSnapshot{Timestamp: i})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publishing blocked on a subscriber that isn't reading")
+	}
+	if snapshot := <-slow; snapshot.Timestamp != 5 {
+		t.Errorf("slow subscriber got snapshot %d, want the newest, 5", snapshot.Timestamp)
+	}
+
+	stream.unsubscribe(slow)
+	if n := stream.subscribers(); n != 0 {
+		t.Errorf("%d subscribers after unsubscribing", n)
+	}
+}
@@ -0,0 +1,108 @@
+// ==============================================================================
+// Stop orders - hold an order until the market trades through a price
+// ==============================================================================
+// A stop order is validated when it is consumed, then held off the book like
+// a good-after-time order until its symbol trades at or through stop_price:
+// at or above it for a buy stop, at or below it for a sell stop. It then
+// executes as a market order, or as a limit order at limit_price if it has
+// one (a stop-limit). Stops are checked after every order that trades, and
+// as soon as they arrive, against the symbol's reference price (see
+// marketdata.go); a stop whose price has already been reached triggers at
+// once. Trailing stops (see trailing.go) are stops whose price follows the
+// market.
+//
+// Waiting stops are "held". Their payloads share "<stream>.held.orders" with
+// good-after-time orders, indexed by "<stream>.stops.<side>.<symbol>" sorted
+// sets scored by stop price, so they survive a restart and are cancelled the
+// same way.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shopspring/decimal"
+)
+
+// stopKey is the sorted set indexing the waiting stops on one side of symbol
+func (e *ExecutionEngine) stopKey(side string, symbol string) string {
+	return e.keyPrefix + ".stops." + side + "." + symbol
+}
+
+// restStop holds a stop order until its price is reached, triggering it at
+// once if it already has been
+func (e *ExecutionEngine) restStop(order *OrderRequest) error {
+	price, _ := order.StopPrice.Float64()
+	if held, err := e.holdOrderIn(order, e.stopKey(order.Side, order.Symbol), price); err != nil || !held {
+		return err
+	}
+	log.Printf("Order %s held until %s trades through %s", order.OrderID, order.Symbol, order.StopPrice)
+	e.triggerStops(order.Symbol, order.Symbol)
+	return nil
+}
+
+// triggerStops executes the waiting stops in symbol whose price the market
+// has reached. Triggered stops that trade may trigger further stops. They
+// execute on symbol's actor; actor is the symbol whose actor is calling, if
+// any.
+func (e *ExecutionEngine) triggerStops(actor string, symbol string) {
+	lastPrice := e.prices.reference(symbol)
+	e.ratchetTrailingStops(symbol, lastPrice)
+	last := lastPrice.String()
+	for _, side := range []struct {
+		name    string
+		trigger *redis.ZRangeBy
+	}{
+		{"buy", &redis.ZRangeBy{Min: "-inf", Max: last}},
+		{"sell", &redis.ZRangeBy{Min: last, Max: "+inf"}},
+	} {
+		index := e.stopKey(side.name, symbol)
+		triggered, err := e.redisClient.ZRangeByScore(e.ctx, index, side.trigger).Result()
+		if err != nil {
+			log.Printf("Error reading %s stops in %s: %v", side.name, symbol, err)
+			continue
+		}
+		for _, orderID := range triggered {
+			e.triggerStop(actor, index, orderID, last)
+		}
+	}
+}
+
+// triggerStop executes one triggered stop as a market or limit order
+func (e *ExecutionEngine) triggerStop(actor string, index string, orderID string, last string) {
+	payload, ok, err := e.unholdOrder(e.ctx, index, orderID)
+	if err != nil || !ok {
+		if err != nil {
+			log.Printf("Error triggering stop %s: %v", orderID, err)
+		}
+		return // cancelled meanwhile
+	}
+
+	var order OrderRequest
+	if err := json.Unmarshal([]byte(payload), &order); err != nil {
+		log.Printf("Discarding undecodable stop %s: %v", orderID, err)
+		return
+	}
+	if order.Type == orderTypeTrailingStop {
+		e.redisClient.SRem(e.ctx, e.trailingKey(order.Side, order.Symbol), orderID)
+		order.TrailAmount, order.TrailPercent = decimal.Zero, decimal.Zero
+	}
+	order.Type = "market"
+	if order.LimitPrice.IsPositive() {
+		order.Type = "limit"
+	}
+	data, err := json.Marshal(&order)
+	if err != nil {
+		return
+	}
+
+	log.Printf("Stop %s triggered at %s, executing as a %s order", orderID, last, order.Type)
+	e.runOnActor(actor, e.canonicalSymbol(order.Symbol), func() {
+		if err := e.handleMessage(redis.XMessage{ID: "stop:" + orderID, Values: map[string]interface{}{"order": string(data)}}); err != nil {
+			log.Printf("Error executing triggered stop %s: %v", orderID, err)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// trade crosses two accounts' limit orders in AAPL at price
+func trade(t *testing.T, engine *ExecutionEngine, id string, price float64) {
+	t.Helper()
+	bid := limitOrder(id+"-bid", "AAPL", "buy", price, 1)
+	bid.AccountID = "acct-bid"
+	ask := limitOrder(id+"-ask", "AAPL", "sell", price, 1)
+	ask.AccountID = "acct-ask"
+	submitToEngine(t, engine, bid)
+	submitToEngine(t, engine, ask)
+}
+
+func TestStopWaitsForTheMarketToTradeThroughIt(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	trade(t, engine, "t1", 100)
+
+	stop := stopOrder("stop-1")
+	stop.LimitPrice = decimal.Zero
+	submitToEngine(t, engine, stop)
+	if status := restingStatus(t, engine, "stop-1"); status != statusHeld {
+		t.Fatalf("stop above its price: status %s, want held", status)
+	}
+
+	trade(t, engine, "t2", 96)
+	if status := restingStatus(t, engine, "stop-1"); status != statusHeld {
+		t.Fatalf("stop after a trade above its price: status %s, want held", status)
+	}
+
+	trade(t, engine, "t3", 95)
+	response, _ := engine.GetOrder("stop-1")
+	if response.Status != statusFilled || !response.FilledQuantity.Equal(dec(10)) {
+		t.Fatalf("stop after a trade at its price: %+v, want filled", response)
+	}
+	if n := engine.redisClient.ZCard(engine.ctx, engine.stopKey("sell", "AAPL")).Val(); n != 0 {
+		t.Errorf("%d stops still waiting after triggering", n)
+	}
+}
+
+func TestStopLimitRestsOnceTriggered(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	submitToEngine(t, engine, stopOrder("stop-1"))
+
+	trade(t, engine, "t1", 94)
+	if status := restingStatus(t, engine, "stop-1"); status != statusWorking || !inBook(engine, "AAPL", "stop-1") {
+		t.Errorf("triggered stop-limit: status %s, want working in the book", status)
+	}
+}
+
+func TestCancelWaitingStop(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	submitToEngine(t, engine, stopOrder("stop-1"))
+
+	response, err := engine.CancelOrder("stop-1", "")
+	if err != nil || response.Status != statusCancelled {
+		t.Fatalf("cancel waiting stop: %+v, %v", response, err)
+	}
+	trade(t, engine, "t1", 90)
+	if response, _ := engine.GetOrder("stop-1"); response.Status != statusCancelled {
+		t.Errorf("cancelled stop executed: %+v", response)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// seedSelfCross rests a sell for acct-1 and returns an incoming buy from the
+// same account that crosses it
+func seedSelfCross(t *testing.T, policy string) (*ExecutionEngine, *OrderRequest) {
+	engine, _ := newTestEngine(t)
+	engine.config.STPPolicy = policy
+
+	resting := limitOrder("sell-1", "AAPL", "sell", 100.0, 10)
+	resting.AccountID = "acct-1"
+	engine.orderCache.Store(resting.OrderID, engine.executeOrder(context.Background(), resting))
+
+	incoming := limitOrder("buy-1", "AAPL", "buy", 100.0, 10)
+	incoming.AccountID = "acct-1"
+	return engine, incoming
+}
+
+func restingStatus(t *testing.T, e *ExecutionEngine, orderID string) OrderState {
+	response, ok := e.orderCache.Load(orderID)
+	if !ok {
+		t.Fatalf("no cached response for %s", orderID)
+	}
+	return response.Status
+}
+
+func inBook(e *ExecutionEngine, symbol string, orderID string) bool {
+	book := e.bookFor(symbol)
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	_, ok := book.Get(orderID)
+	return ok
+}
+
+func TestSTPCancelResting(t *testing.T) {
+	engine, incoming := seedSelfCross(t, stpCancelResting)
+
+	resp := engine.executeOrder(context.Background(), incoming)
+	if !resp.FilledQuantity.IsZero() {
+		t.Fatalf("self trade executed: %+v", resp)
+	}
+	if resp.Status != "working" {
+		t.Errorf("incoming status = %q, want working", resp.Status)
+	}
+	if !inBook(engine, "AAPL", "buy-1") {
+		t.Error("incoming order should rest in the book")
+	}
+	if inBook(engine, "AAPL", "sell-1") {
+		t.Error("resting order should have been cancelled")
+	}
+	if got := restingStatus(t, engine, "sell-1"); got != "cancelled" {
+		t.Errorf("resting status = %q, want cancelled", got)
+	}
+}
+
+func TestSTPCancelIncoming(t *testing.T) {
+	engine, incoming := seedSelfCross(t, stpCancelIncoming)
+
+	resp := engine.executeOrder(context.Background(), incoming)
+	if !resp.FilledQuantity.IsZero() || resp.Status != "cancelled" || resp.RejectReason != rejectSelfTrade {
+		t.Fatalf("unexpected incoming response: %+v", resp)
+	}
+	if inBook(engine, "AAPL", "buy-1") {
+		t.Error("cancelled incoming order should not rest")
+	}
+	if !inBook(engine, "AAPL", "sell-1") {
+		t.Error("resting order should be untouched")
+	}
+}
+
+func TestSTPCancelBoth(t *testing.T) {
+	engine, incoming := seedSelfCross(t, stpCancelBoth)
+
+	resp := engine.executeOrder(context.Background(), incoming)
+	if !resp.FilledQuantity.IsZero() || resp.Status != "cancelled" {
+		t.Fatalf("unexpected incoming response: %+v", resp)
+	}
+	if inBook(engine, "AAPL", "buy-1") || inBook(engine, "AAPL", "sell-1") {
+		t.Error("both orders should be out of the book")
+	}
+	if got := restingStatus(t, engine, "sell-1"); got != "cancelled" {
+		t.Errorf("resting status = %q, want cancelled", got)
+	}
+}
+
+func TestSTPIgnoresOtherAccounts(t *testing.T) {
+	engine, incoming := seedSelfCross(t, stpCancelIncoming)
+	incoming.AccountID = "acct-2"
+
+	resp := engine.executeOrder(context.Background(), incoming)
+	if resp.Status != "filled" || !resp.FilledQuantity.Equal(dec(10)) {
+		t.Fatalf("orders from different accounts should trade: %+v", resp)
+	}
+}
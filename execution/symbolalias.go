@@ -0,0 +1,73 @@
+// ==============================================================================
+// Symbol normalization - one canonical symbol per instrument
+// ==============================================================================
+// Clients spell the same instrument differently (brk.b, BRK-B, btcusd,
+// BTC-USD), which would otherwise split its book, positions and prices. Every
+// order's symbol is trimmed and upper-cased on ingestion, then mapped through
+// the SYMBOL_ALIASES table, a JSON object of alias -> canonical symbol such as
+// {"BRK-B":"BRK.B","BTCUSD":"BTC-USD"}. Aliases match case-insensitively and
+// are not chained.
+//
+// The order is booked, tracked and priced under the canonical symbol, which is
+// what responses report as "symbol"; the client's spelling, when different,
+// is echoed back as "client_symbol".
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// normalizeSymbolCase trims and upper-cases a symbol
+func normalizeSymbolCase(symbol string) string {
+	return strings.ToUpper(strings.TrimSpace(symbol))
+}
+
+// parseSymbolAliases decodes the SYMBOL_ALIASES config
+func parseSymbolAliases(raw string) (map[string]string, error) {
+	aliases := map[string]string{}
+	if raw == "" {
+		return aliases, nil
+	}
+	var table map[string]string
+	if err := json.Unmarshal([]byte(raw), &table); err != nil {
+		return nil, err
+	}
+	for alias, canonical := range table {
+		canonical = normalizeSymbolCase(canonical)
+		if canonical == "" {
+			return nil, fmt.Errorf("alias %q has no canonical symbol", alias)
+		}
+		aliases[normalizeSymbolCase(alias)] = canonical
+	}
+	return aliases, nil
+}
+
+// canonicalSymbol returns the symbol an order for symbol is booked under
+func (e *ExecutionEngine) canonicalSymbol(symbol string) string {
+	symbol = normalizeSymbolCase(symbol)
+	if canonical, ok := e.symbolAliases[symbol]; ok {
+		return canonical
+	}
+	return symbol
+}
+
+// normalizeOrderSymbol moves order onto its canonical symbol, remembering
+// the client's spelling. It is safe to apply more than once.
+func (e *ExecutionEngine) normalizeOrderSymbol(order *OrderRequest) {
+	if len(order.Legs) > 0 {
+		e.normalizeSpread(order)
+		return
+	}
+	canonical := e.canonicalSymbol(order.Symbol)
+	if canonical == order.Symbol {
+		return
+	}
+	if order.ClientSymbol == "" {
+		order.ClientSymbol = order.Symbol
+	}
+	order.Symbol = canonical
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestCanonicalSymbol(t *testing.T) {
+	aliases, err := parseSymbolAliases(`{"brk-b":"BRK.B","BTCUSD":"btc-usd"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := &ExecutionEngine{symbolAliases: aliases}
+
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"AAPL", "AAPL"},
+		{"aapl", "AAPL"},
+		{" msft ", "MSFT"},
+		{"BRK-B", "BRK.B"},
+		{"brk-b", "BRK.B"},
+		{"BRK.B", "BRK.B"},
+		{"btcusd", "BTC-USD"},
+		{"BtcUsd", "BTC-USD"},
+		{"btc-usd", "BTC-USD"},
+	} {
+		if got := engine.canonicalSymbol(tc.in); got != tc.want {
+			t.Errorf("canonicalSymbol(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+
+	if _, err := parseSymbolAliases(`{"BRK-B":" "}`); err == nil {
+		t.Error("alias without a canonical symbol accepted")
+	}
+}
+
+func TestAliasedOrdersShareOneBook(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.symbolAliases = map[string]string{"BTCUSD": "BTC-USD"}
+
+	submitToEngine(t, engine, limitOrder("ask", "btcusd", "sell", 100, 10))
+	submitToEngine(t, engine, limitOrder("bid", "BTC-USD", "buy", 100, 4))
+
+	ask, _ := engine.GetOrder("ask")
+	if ask.Symbol != "BTC-USD" || ask.ClientSymbol != "btcusd" {
+		t.Errorf("aliased order: symbol %q client_symbol %q, want BTC-USD and btcusd", ask.Symbol, ask.ClientSymbol)
+	}
+	bid, _ := engine.GetOrder("bid")
+	if bid.Status != statusFilled || bid.ClientSymbol != "" {
+		t.Errorf("canonical order should fill against the aliased one without a client_symbol, got %+v", bid)
+	}
+	if len(engine.books) != 1 || !inBook(engine, "BTC-USD", "ask") {
+		t.Errorf("aliases fragmented the book: %d books", len(engine.books))
+	}
+	if position, ok := engine.positions.Get("", "BTC-USD"); !ok || !position.Quantity.Equal(dec(0)) {
+		t.Errorf("positions not tracked under the canonical symbol: %+v", position)
+	}
+}
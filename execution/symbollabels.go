@@ -0,0 +1,81 @@
+// ==============================================================================
+// Symbol labels - bounding the cardinality of per-symbol metrics
+// ==============================================================================
+// Metrics labeled by symbol get a series per symbol, so a client sending
+// orders in thousands of junk symbols would blow up the number of series
+// Prometheus has to store. With METRICS_SYMBOLS (a comma-separated list)
+// or METRICS_SYMBOL_MIN_ORDERS set, only the listed symbols and those that
+// have seen at least that many orders routed for execution keep their own
+// label; every other symbol is reported as "other". Counts add up into the
+// "other" series (order_book_resting_orders); per-book figures that don't
+// add up (the book feature gauges) are left out for it.
+//
+// Without either setting every symbol keeps its label. Symbol halts are
+// operator actions and always keep theirs. At most maxTrackedSymbols symbols
+// are counted towards the threshold, so the guard's own memory is bounded
+// too.
+// ==============================================================================
+
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// otherSymbolLabel stands in for the symbols that don't get their own label
+const otherSymbolLabel = "other"
+
+// maxTrackedSymbols bounds the symbols whose order counts are kept
+const maxTrackedSymbols = 10000
+
+// symbolLabeler decides which symbols get their own metric label
+type symbolLabeler struct {
+	allow     map[string]bool
+	minOrders int // 0: listed symbols only
+
+	mu     sync.Mutex
+	orders map[string]int
+}
+
+// newSymbolLabeler builds the guard from the METRICS_SYMBOLS list and
+// threshold. It returns nil, labeling every symbol, if neither is set.
+func newSymbolLabeler(symbols string, minOrders int) *symbolLabeler {
+	allow := map[string]bool{}
+	for _, symbol := range strings.Split(symbols, ",") {
+		if symbol = strings.TrimSpace(symbol); symbol != "" {
+			allow[normalizeSymbolCase(symbol)] = true
+		}
+	}
+	if len(allow) == 0 && minOrders <= 0 {
+		return nil
+	}
+	return &symbolLabeler{allow: allow, minOrders: max(minOrders, 0), orders: map[string]int{}}
+}
+
+// observe counts an order routed in symbol
+func (l *symbolLabeler) observe(symbol string) {
+	if l == nil || l.minOrders == 0 || l.allow[symbol] {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.orders[symbol]; ok || len(l.orders) < maxTrackedSymbols {
+		l.orders[symbol]++
+	}
+}
+
+// label returns the label symbol is reported under
+func (l *symbolLabeler) label(symbol string) string {
+	if l == nil || l.allow[symbol] {
+		return symbol
+	}
+	if l.minOrders > 0 {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.orders[symbol] >= l.minOrders {
+			return symbol
+		}
+	}
+	return otherSymbolLabel
+}
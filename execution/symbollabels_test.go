@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// gaugesBySymbol returns a per-symbol gauge's value for each symbol label
+func gaugesBySymbol(t *testing.T, engine *ExecutionEngine, name string) map[string]float64 {
+	t.Helper()
+	families, err := engine.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "symbol" {
+					got[label.GetValue()] = metric.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	return got
+}
+
+func TestJunkSymbolsCollapseIntoOtherLabel(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	engine.symbolLabels = newSymbolLabeler("aapl", 3)
+
+	submitToEngine(t, engine, limitOrder("aapl-1", "AAPL", "buy", 90, 1))
+	for i := 0; i < 3; i++ {
+		submitToEngine(t, engine, limitOrder(fmt.Sprintf("msft-%d", i), "MSFT", "buy", 90, 1))
+	}
+	for i := 0; i < 50; i++ {
+		submitToEngine(t, engine, limitOrder(fmt.Sprintf("junk-%d", i), fmt.Sprintf("JUNK%d", i), "buy", 90, 1))
+	}
+
+	resting := gaugesBySymbol(t, engine, "order_book_resting_orders")
+	want := map[string]float64{"AAPL": 1, "MSFT": 3, otherSymbolLabel: 50}
+	if len(resting) != len(want) {
+		t.Errorf("order_book_resting_orders has %d series, want %d: %v", len(resting), len(want), resting)
+	}
+	for label, count := range want {
+		if resting[label] != count {
+			t.Errorf("order_book_resting_orders{symbol=%q} = %v, want %v", label, resting[label], count)
+		}
+	}
+	if imbalance := gaugesBySymbol(t, engine, "order_book_imbalance"); len(imbalance) != 2 {
+		t.Errorf("order_book_imbalance series %v, want AAPL and MSFT only", imbalance)
+	}
+
+	// Symbols past the threshold earn their label
+	for i := 0; i < 2; i++ {
+		submitToEngine(t, engine, limitOrder(fmt.Sprintf("junk0-%d", i), "JUNK0", "buy", 90, 1))
+	}
+	if resting := gaugesBySymbol(t, engine, "order_book_resting_orders"); resting["JUNK0"] != 3 || resting[otherSymbolLabel] != 49 {
+		t.Errorf("after JUNK0's third order: %v, want JUNK0 3 and other 49", resting)
+	}
+}
+
+func TestSymbolLabelerDisabledByDefault(t *testing.T) {
+	if l := newSymbolLabeler("", 0); l != nil || l.label("ANY") != "ANY" {
+		t.Errorf("guard without config = %+v, want nil labeling every symbol", l)
+	}
+}
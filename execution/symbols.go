@@ -0,0 +1,123 @@
+// ==============================================================================
+// Symbol permissions - which instruments this engine may trade
+// ==============================================================================
+// SYMBOL_ALLOWLIST and SYMBOL_DENYLIST are comma-separated symbols or glob
+// patterns ("AAPL", "BTC*", "ES?[HMUZ]4"). A symbol matching the deny list is
+// refused; otherwise, when an allow list is set, the symbol must match it.
+// Orders for refused symbols are rejected with "symbol_not_permitted" before
+// they are queued, and again on the consumer side for orders other producers
+// put straight onto the stream.
+//
+// GET /symbols returns the current lists and PUT /symbols replaces them, so
+// the permitted set can change without a restart.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// rejectSymbolNotPermitted is the reason for orders in refused symbols
+const rejectSymbolNotPermitted = "symbol_not_permitted"
+
+// errSymbolNotPermitted is returned by SubmitOrder for refused symbols
+var errSymbolNotPermitted = errors.New(rejectSymbolNotPermitted)
+
+// SymbolPolicy is an allow and deny list of symbol patterns. An empty allow
+// list permits every symbol that isn't denied.
+type SymbolPolicy struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// newSymbolPolicy builds a policy, checking every pattern is a valid glob
+func newSymbolPolicy(allow, deny []string) (*SymbolPolicy, error) {
+	p := &SymbolPolicy{Allow: []string{}, Deny: []string{}}
+	for _, list := range []struct {
+		patterns []string
+		dst      *[]string
+	}{{allow, &p.Allow}, {deny, &p.Deny}} {
+		for _, pattern := range list.patterns {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				continue
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid symbol pattern %q", pattern)
+			}
+			*list.dst = append(*list.dst, pattern)
+		}
+	}
+	return p, nil
+}
+
+// parseSymbolPolicy decodes the SYMBOL_ALLOWLIST and SYMBOL_DENYLIST config
+func parseSymbolPolicy(allow, deny string) (*SymbolPolicy, error) {
+	return newSymbolPolicy(strings.Split(allow, ","), strings.Split(deny, ","))
+}
+
+// permits reports whether symbol may be traded. A nil policy permits all.
+func (p *SymbolPolicy) permits(symbol string) bool {
+	if p == nil {
+		return true
+	}
+	if matchesAny(p.Deny, symbol) {
+		return false
+	}
+	return len(p.Allow) == 0 || matchesAny(p.Allow, symbol)
+}
+
+// matchesAny reports whether symbol matches one of patterns
+func matchesAny(patterns []string, symbol string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, symbol); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// symbolPermitted reports whether the current policy permits symbol
+func (e *ExecutionEngine) symbolPermitted(symbol string) bool {
+	return e.symbolPolicy.Load().permits(symbol)
+}
+
+// SetSymbolPolicy replaces the permitted symbols
+func (e *ExecutionEngine) SetSymbolPolicy(policy *SymbolPolicy) {
+	e.symbolPolicy.Store(policy)
+	log.Printf("Symbol policy updated: allow=%v deny=%v", policy.Allow, policy.Deny)
+}
+
+// handleSymbols serves GET and PUT /symbols
+func (e *ExecutionEngine) handleSymbols(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		policy := e.symbolPolicy.Load()
+		if policy == nil {
+			policy = &SymbolPolicy{Allow: []string{}, Deny: []string{}}
+		}
+		json.NewEncoder(w).Encode(policy)
+	case http.MethodPut:
+		var body SymbolPolicy
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, errCodeInvalidRequest, "Invalid request")
+			return
+		}
+		policy, err := newSymbolPolicy(body.Allow, body.Deny)
+		if err != nil {
+			writeError(w, errCodeInvalidRequest, err.Error())
+			return
+		}
+		e.SetSymbolPolicy(policy)
+		json.NewEncoder(w).Encode(policy)
+	default:
+		methodNotAllowed(w)
+	}
+}
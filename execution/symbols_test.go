@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSymbolPolicy(t *testing.T) {
+	policy, err := parseSymbolPolicy("AAPL, MSFT, BTC*", "BTCDOWN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for symbol, want := range map[string]bool{
+		"AAPL":    true,  // exact allow
+		"BTCUSD":  true,  // glob allow
+		"BTCDOWN": false, // deny beats a matching allow glob
+		"TSLA":    false, // not on the allow list
+	} {
+		if got := policy.permits(symbol); got != want {
+			t.Errorf("permits(%s) = %v, want %v", symbol, got, want)
+		}
+	}
+
+	denyOnly, _ := parseSymbolPolicy("", "*DOWN,*UP")
+	if !denyOnly.permits("TSLA") || denyOnly.permits("ETHUP") {
+		t.Error("with no allow list everything but the deny list should be permitted")
+	}
+
+	if _, err := parseSymbolPolicy("[AAPL", ""); err == nil {
+		t.Error("expected an error for a malformed glob")
+	}
+}
+
+// submitSymbol posts a market order for symbol to handler
+func submitSymbol(handler http.Handler, symbol string) *httptest.ResponseRecorder {
+	body := `{"order_id":"o-` + symbol + `","symbol":"` + symbol + `","side":"buy","quantity":"1","type":"market"}`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body)))
+	return rec
+}
+
+func TestDeniedSymbolRejectedBeforeQueueing(t *testing.T) {
+	engine, mr := newTestEngine(t)
+	policy, _ := parseSymbolPolicy("AAPL,ES*", "")
+	engine.SetSymbolPolicy(policy)
+	handler := engine.routes()
+
+	for _, symbol := range []string{"AAPL", "ESZ4"} {
+		if rec := submitSymbol(handler, symbol); rec.Code != http.StatusAccepted {
+			t.Errorf("%s: got %d, want 202", symbol, rec.Code)
+		}
+	}
+
+	rec := submitSymbol(handler, "TSLA")
+	var response OrderResponse
+	json.NewDecoder(rec.Body).Decode(&response)
+	if rec.Code != http.StatusForbidden || response.Status != statusRejected || response.RejectReason != rejectSymbolNotPermitted {
+		t.Fatalf("TSLA: got %d %+v, want a symbol_not_permitted rejection", rec.Code, response)
+	}
+	if entries, _ := mr.Stream("test-stream"); len(entries) != 2 {
+		t.Errorf("%d orders queued, want only the 2 permitted", len(entries))
+	}
+
+	// Orders other producers queue directly are screened by the consumer
+	submitToEngine(t, engine, &OrderRequest{OrderID: "direct-1", Symbol: "TSLA", Side: "buy", Quantity: dec(1), Type: "market"})
+	if got, _ := engine.GetOrder("direct-1"); got == nil || got.RejectReason != rejectSymbolNotPermitted {
+		t.Errorf("directly queued order: got %+v, want a symbol_not_permitted rejection", got)
+	}
+}
+
+func TestReloadSymbolPolicy(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	policy, _ := parseSymbolPolicy("AAPL", "")
+	engine.SetSymbolPolicy(policy)
+	handler := engine.routes()
+
+	if rec := submitSymbol(handler, "MSFT"); rec.Code != http.StatusForbidden {
+		t.Fatalf("MSFT before reload: got %d, want 403", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/symbols", strings.NewReader(`{"allow":["AAPL","MSFT"],"deny":[]}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /symbols: got %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := submitSymbol(handler, "MSFT"); rec.Code != http.StatusAccepted {
+		t.Errorf("MSFT after reload: got %d, want 202", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/symbols", strings.NewReader(`{"allow":["[bad"]}`)))
+	if rec.Code != http.StatusBadRequest || !engine.symbolPermitted("MSFT") {
+		t.Errorf("a bad reload should be refused and keep the old lists, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,40 @@
+// ==============================================================================
+// Order tags - caller metadata carried alongside an order
+// ==============================================================================
+// Strategies attach tags (strategy ID, signal source, ...) to orders for
+// attribution downstream. The engine never looks at them: they don't affect
+// validation beyond their size, matching or risk checks. They are copied
+// onto every update of the order - the response, its fill events on every
+// sink and the order store - and onto its lifecycle audit records.
+//
+// An order may carry at most maxOrderTags tags, with keys of at most
+// maxTagKeyLength bytes and values of at most maxTagValueLength bytes, so a
+// misbehaving client can't bloat every record the order touches.
+// ==============================================================================
+
+package main
+
+import "fmt"
+
+// Limits on an order's tags
+const (
+	maxOrderTags      = 16
+	maxTagKeyLength   = 64
+	maxTagValueLength = 256
+)
+
+// validateTags checks an order's tags against the size limits
+func validateTags(tags map[string]string) error {
+	if len(tags) > maxOrderTags {
+		return fmt.Errorf("%d tags, at most %d allowed", len(tags), maxOrderTags)
+	}
+	for key, value := range tags {
+		if key == "" || len(key) > maxTagKeyLength {
+			return fmt.Errorf("tag key %q must be 1 to %d bytes", key, maxTagKeyLength)
+		}
+		if len(value) > maxTagValueLength {
+			return fmt.Errorf("tag %s: value over %d bytes", key, maxTagValueLength)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// awaitUpdates collects sub's updates until every order in want has reached
+// its state, returning the update that reached it
+func awaitUpdates(t *testing.T, sub *fillSubscription, want map[string]OrderState) map[string]*OrderResponse {
+	t.Helper()
+	got := map[string]*OrderResponse{}
+	timeout := time.After(2 * time.Second)
+	for len(got) < len(want) {
+		select {
+		case update := <-sub.updates:
+			if state, ok := want[update.OrderID]; ok && update.Status == state {
+				got[update.OrderID] = update
+			}
+		case <-timeout:
+			t.Fatalf("updates %v, want orders in %v", got, want)
+		}
+	}
+	return got
+}
+
+func TestTagsCarriedOntoFillsAndAudit(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	sub := engine.subscribers.subscribe("AAPL")
+	defer engine.subscribers.unsubscribe(sub)
+
+	sellTags := map[string]string{"strategy": "mm-3"}
+	sell := limitOrder("sell-1", "AAPL", "sell", 100, 10)
+	sell.AccountID = "acct-mm"
+	sell.Tags = sellTags
+	submitToEngine(t, engine, sell)
+
+	buyTags := map[string]string{"strategy": "momo-7", "signal": "breakout/5m"}
+	buy := limitOrder("buy-1", "AAPL", "buy", 100, 4)
+	buy.AccountID = "acct-1"
+	buy.Tags = buyTags
+	submitToEngine(t, engine, buy)
+
+	fills := awaitUpdates(t, sub, map[string]OrderState{"buy-1": statusFilled, "sell-1": statusPartiallyFilled})
+	if tags := fills["buy-1"].Tags; !reflect.DeepEqual(tags, buyTags) {
+		t.Errorf("buy fill tags = %v, want %v", tags, buyTags)
+	}
+	if tags := fills["sell-1"].Tags; !reflect.DeepEqual(tags, sellTags) {
+		t.Errorf("resting sell's fill tags = %v, want %v", tags, sellTags)
+	}
+
+	var audited int
+	for _, record := range auditRecords(t, engine) {
+		if record.OrderID != "buy-1" {
+			continue
+		}
+		audited++
+		if !reflect.DeepEqual(record.Tags, buyTags) {
+			t.Errorf("%s audit record tags = %v, want %v", record.Event, record.Tags, buyTags)
+		}
+	}
+	if audited == 0 {
+		t.Fatal("buy-1 was never audited")
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	tooMany := map[string]string{}
+	for i := 0; i <= maxOrderTags; i++ {
+		tooMany[fmt.Sprintf("k%d", i)] = "v"
+	}
+	for name, tc := range map[string]struct {
+		tags  map[string]string
+		valid bool
+	}{
+		"none":          {nil, true},
+		"some":          {map[string]string{"strategy": "momo-7", "note": ""}, true},
+		"too many":      {tooMany, false},
+		"empty key":     {map[string]string{"": "v"}, false},
+		"long key":      {map[string]string{strings.Repeat("k", maxTagKeyLength+1): "v"}, false},
+		"long value":    {map[string]string{"k": strings.Repeat("v", maxTagValueLength+1)}, false},
+		"longest value": {map[string]string{"k": strings.Repeat("v", maxTagValueLength)}, true},
+	} {
+		if err := validateTags(tc.tags); (err == nil) != tc.valid {
+			t.Errorf("%s: validateTags = %v, want valid %v", name, err, tc.valid)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// ==============================================================================
+// Tracing - OpenTelemetry spans across the submit → queue → execute pipeline
+// ==============================================================================
+// The trace context of a submitted order is carried through the Redis stream
+// as W3C traceparent/tracestate message fields, so the consumer's spans join
+// the trace started by the HTTP handler. Spans are exported over OTLP/HTTP
+// when OTEL_EXPORTER_OTLP_ENDPOINT is set; otherwise tracing is a no-op.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"log"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const tracerName = "execution-engine"
+
+// tracePropagator serializes trace context onto HTTP headers and stream fields
+var tracePropagator = propagation.TraceContext{}
+
+// initTracing installs an OTLP exporting tracer provider when an endpoint is
+// configured. The returned function flushes and stops the exporter.
+func initTracing(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", tracerName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.Printf("Tracing enabled, exporting to %s", endpoint)
+	return provider.Shutdown, nil
+}
+
+// injectTraceContext copies the span context in ctx onto stream message fields
+func injectTraceContext(ctx context.Context, values map[string]interface{}) {
+	carrier := propagation.MapCarrier{}
+	tracePropagator.Inject(ctx, carrier)
+	for k, v := range carrier {
+		values[k] = v
+	}
+}
+
+// extractTraceContext recovers the submitter's span context from a stream message
+func extractTraceContext(ctx context.Context, values map[string]interface{}) context.Context {
+	carrier := propagation.MapCarrier{}
+	for _, key := range tracePropagator.Fields() {
+		if v, ok := values[key].(string); ok {
+			carrier[key] = v
+		}
+	}
+	return tracePropagator.Extract(ctx, carrier)
+}
+
+// orderAttributes are the span attributes identifying an order
+func orderAttributes(order *OrderRequest) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("order.id", order.OrderID),
+		attribute.String("order.symbol", order.Symbol),
+		attribute.String("order.side", order.Side),
+		attribute.String("order.type", order.Type),
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOrderTraceSpanHierarchy(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	engine.tracer = provider.Tracer(tracerName)
+
+	body := `{"order_id":"trace-1","symbol":"AAPL","side":"buy","quantity":10,"type":"market","time_in_force":"day"}`
+	rec := httptest.NewRecorder()
+	engine.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rec.Code)
+	}
+
+	// Pick the message up the way the consumer would
+	msgs := engine.redisClient.XRange(context.Background(), engine.streamName, "-", "+").Val()
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 queued message, got %d", len(msgs))
+	}
+	if _, ok := msgs[0].Values["traceparent"]; !ok {
+		t.Fatalf("trace context not carried on stream message: %v", msgs[0].Values)
+	}
+	engine.processOrder(msgs[0])
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, s := range exporter.GetSpans() {
+		spans[s.Name] = s
+	}
+
+	parents := map[string]string{
+		"submit_order":     "",
+		"redis.xadd":       "submit_order",
+		"process_order":    "submit_order",
+		"execute_order":    "process_order",
+		"publish_response": "process_order",
+	}
+	root := spans["submit_order"].SpanContext.TraceID()
+	for name, parent := range parents {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("missing span %q", name)
+			continue
+		}
+		if span.SpanContext.TraceID() != root {
+			t.Errorf("span %q is in a different trace", name)
+		}
+		if parent == "" {
+			if span.Parent.IsValid() {
+				t.Errorf("span %q should be a root span", name)
+			}
+			continue
+		}
+		if span.Parent.SpanID() != spans[parent].SpanContext.SpanID() {
+			t.Errorf("span %q should be a child of %q", name, parent)
+		}
+	}
+}
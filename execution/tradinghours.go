@@ -0,0 +1,207 @@
+// ==============================================================================
+// Trading hours - what happens to orders while the market is closed
+// ==============================================================================
+// TRADING_SESSIONS lists when groups of symbols trade, as JSON:
+//
+//   [{"symbols":["AAPL","MSFT","*.US"],"timezone":"America/New_York",
+//     "open":"09:30","close":"16:00","days":["mon","tue","wed","thu","fri"]}]
+//
+// symbols are symbols or glob patterns, and a symbol belongs to the first
+// group it matches. The market is open from open until close (local times in
+// timezone, UTC if none, with open before close) on the listed days, every
+// day if none are. Symbols in no group always trade.
+//
+// An order for a symbol whose market is closed, when it is consumed, is
+// handled per MARKET_CLOSED_POLICY:
+//
+//   reject          rejected with reason "market_closed" and retry_after_ms
+//                   until the next open (the default)
+//   queue_for_open  held like a good-after-time order until the next open,
+//                   when the activation sweeper executes it
+//
+// Spreads are closed while any leg's market is, and queued until all of them
+// have opened. Times come from the engine clock.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	_ "time/tzdata" // session time zones, whatever the host has installed
+)
+
+// rejectMarketClosed is the reason for orders refused outside trading hours
+const rejectMarketClosed = "market_closed"
+
+// MARKET_CLOSED_POLICY values
+const (
+	marketClosedReject       = "reject"
+	marketClosedQueueForOpen = "queue_for_open"
+)
+
+// tradingSessionConfig is one group of TRADING_SESSIONS as configured
+type tradingSessionConfig struct {
+	Symbols  []string `json:"symbols"`
+	Timezone string   `json:"timezone"`
+	Open     string   `json:"open"`
+	Close    string   `json:"close"`
+	Days     []string `json:"days"`
+}
+
+// tradingSession is when one group of symbols trades
+type tradingSession struct {
+	symbols     []string
+	location    *time.Location
+	open, close time.Duration // since local midnight
+	days        [7]bool       // indexed by time.Weekday
+}
+
+// tradingCalendar holds the configured sessions. A nil calendar is always
+// open.
+type tradingCalendar struct {
+	sessions []tradingSession
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseTradingSessions decodes the TRADING_SESSIONS config
+func parseTradingSessions(raw string) (*tradingCalendar, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var configs []tradingSessionConfig
+	if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+		return nil, err
+	}
+	calendar := &tradingCalendar{}
+	for i, c := range configs {
+		session := tradingSession{symbols: c.Symbols}
+		for _, pattern := range c.Symbols {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("session %d: invalid symbol pattern %q", i, pattern)
+			}
+		}
+		location, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("session %d: %w", i, err)
+		}
+		session.location = location
+		if session.open, err = parseTimeOfDay(c.Open); err != nil {
+			return nil, fmt.Errorf("session %d: open: %w", i, err)
+		}
+		if session.close, err = parseTimeOfDay(c.Close); err != nil {
+			return nil, fmt.Errorf("session %d: close: %w", i, err)
+		}
+		if session.open >= session.close {
+			return nil, fmt.Errorf("session %d: open %s isn't before close %s", i, c.Open, c.Close)
+		}
+		for _, day := range c.Days {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return nil, fmt.Errorf("session %d: invalid day %q", i, day)
+			}
+			session.days[weekday] = true
+		}
+		if len(c.Days) == 0 {
+			session.days = [7]bool{true, true, true, true, true, true, true}
+		}
+		calendar.sessions = append(calendar.sessions, session)
+	}
+	return calendar, nil
+}
+
+// parseTimeOfDay parses "HH:MM" into the time since midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// session returns the session symbol trades in, or nil if it always trades
+func (c *tradingCalendar) session(symbol string) *tradingSession {
+	if c == nil {
+		return nil
+	}
+	for i := range c.sessions {
+		if matchesAny(c.sessions[i].symbols, symbol) {
+			return &c.sessions[i]
+		}
+	}
+	return nil
+}
+
+// nextOpen reports whether the session is open at now and, if it isn't,
+// when it next opens
+func (s *tradingSession) nextOpen(now time.Time) (time.Time, bool) {
+	local := now.In(s.location)
+	for day := 0; day <= 7; day++ {
+		date := local.AddDate(0, 0, day)
+		if !s.days[date.Weekday()] {
+			continue
+		}
+		midnight := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, s.location)
+		open, close := midnight.Add(s.open), midnight.Add(s.close)
+		if !now.Before(close) {
+			continue
+		}
+		if !now.Before(open) {
+			return time.Time{}, true
+		}
+		return open, false
+	}
+	return time.Time{}, false // no trading days
+}
+
+// marketOpens reports whether order's markets are open and, if not, the
+// first symbol found closed and when all of them will have opened
+func (e *ExecutionEngine) marketOpens(order *OrderRequest) (string, time.Time, bool) {
+	now := e.now()
+	var closed string
+	var opens time.Time
+	for _, symbol := range orderSymbols(order) {
+		session := e.calendar.session(symbol)
+		if session == nil {
+			continue
+		}
+		open, isOpen := session.nextOpen(now)
+		if isOpen {
+			continue
+		}
+		if closed == "" {
+			closed = symbol
+		}
+		if open.After(opens) {
+			opens = open
+		}
+	}
+	return closed, opens, closed == ""
+}
+
+// checkTradingHours rejects or queues an order whose market is closed. It
+// reports whether it handled the order.
+func (e *ExecutionEngine) checkTradingHours(order *OrderRequest) (bool, error) {
+	symbol, opens, open := e.marketOpens(order)
+	if open {
+		return false, nil
+	}
+	if e.config.MarketClosedPolicy == marketClosedQueueForOpen && !opens.IsZero() {
+		order.ActivateAt = opens.UnixMilli()
+		return true, e.holdOrder(order)
+	}
+	rej := &rejection{Reason: rejectMarketClosed, Detail: "the market for " + symbol + " is closed"}
+	if !opens.IsZero() {
+		rej.Detail += ", opening " + opens.UTC().Format(time.RFC3339)
+		rej.RetryAfter = opens.Sub(e.now())
+	}
+	e.rejectOrder(order, rej)
+	return true, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// usEquities trades AAPL from 09:30 to 16:00 New York time on weekdays. The
+// fake clock starts on Tuesday 14 November 2023 at 17:13 there, after the
+// close.
+const usEquities = `[{"symbols":["AAPL"],"timezone":"America/New_York","open":"09:30","close":"16:00","days":["mon","tue","wed","thu","fri"]}]`
+
+// wednesdayOpen is when AAPL next opens after the fake clock's start
+var wednesdayOpen = time.Date(2023, 11, 15, 14, 30, 0, 0, time.UTC)
+
+func newTradingHoursEngine(t *testing.T, policy string) (*ExecutionEngine, *fakeClock) {
+	t.Helper()
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+	calendar, err := parseTradingSessions(usEquities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.calendar = calendar
+	engine.config.MarketClosedPolicy = policy
+	return engine, clock
+}
+
+func TestInSessionOrderIsAccepted(t *testing.T) {
+	engine, clock := newTradingHoursEngine(t, marketClosedReject)
+	clock.advance(wednesdayOpen.Sub(clock.Now()) + time.Hour)
+
+	submitToEngine(t, engine, limitOrder("in-session", "AAPL", "buy", 100, 10))
+	if status := restingStatus(t, engine, "in-session"); status != statusWorking {
+		t.Errorf("order during trading hours: status %s, want working", status)
+	}
+
+	// Symbols without a session always trade
+	clock.advance(12 * time.Hour)
+	submitToEngine(t, engine, limitOrder("no-session", "BTCUSD", "buy", 100, 10))
+	if status := restingStatus(t, engine, "no-session"); status != statusWorking {
+		t.Errorf("order for a symbol without a session: status %s, want working", status)
+	}
+}
+
+func TestOutOfSessionOrderIsRejected(t *testing.T) {
+	engine, clock := newTradingHoursEngine(t, marketClosedReject)
+
+	submitToEngine(t, engine, limitOrder("after-close", "AAPL", "buy", 100, 10))
+	response, _ := engine.GetOrder("after-close")
+	if response.Status != statusRejected || response.RejectReason != rejectMarketClosed {
+		t.Fatalf("order after the close: %s/%s, want rejected for %s", response.Status, response.RejectReason, rejectMarketClosed)
+	}
+	if want := wednesdayOpen.Sub(clock.Now()).Milliseconds(); response.RetryAfterMs != want {
+		t.Errorf("retry after %dms, want %dms until the open", response.RetryAfterMs, want)
+	}
+
+	// Saturday, with the market closed until Monday
+	clock.advance(wednesdayOpen.Sub(clock.Now()) + 3*24*time.Hour + time.Hour)
+	submitToEngine(t, engine, limitOrder("weekend", "AAPL", "buy", 100, 10))
+	response, _ = engine.GetOrder("weekend")
+	monday := time.Date(2023, 11, 20, 14, 30, 0, 0, time.UTC)
+	if response.Status != statusRejected || response.RetryAfterMs != monday.Sub(clock.Now()).Milliseconds() {
+		t.Errorf("order at the weekend: %s, retry after %dms; want rejected until Monday", response.Status, response.RetryAfterMs)
+	}
+}
+
+func TestOutOfSessionOrderIsQueuedForOpen(t *testing.T) {
+	engine, clock := newTradingHoursEngine(t, marketClosedQueueForOpen)
+
+	submitToEngine(t, engine, limitOrder("queued", "AAPL", "buy", 100, 10))
+	if status := restingStatus(t, engine, "queued"); status != statusHeld {
+		t.Fatalf("order after the close: status %s, want held", status)
+	}
+	if score := engine.redisClient.ZScore(engine.ctx, engine.heldKey, "queued").Val(); int64(score) != wednesdayOpen.UnixMilli() {
+		t.Errorf("held until %v, want the open at %v", time.UnixMilli(int64(score)).UTC(), wednesdayOpen)
+	}
+
+	clock.advance(wednesdayOpen.Sub(clock.Now()))
+	engine.activateDue()
+	if status := restingStatus(t, engine, "queued"); status != statusWorking {
+		t.Errorf("queued order at the open: status %s, want working", status)
+	}
+}
+
+func TestParseTradingSessionsRejectsBadConfig(t *testing.T) {
+	for _, raw := range []string{
+		`{"symbols":["AAPL"]}`,
+		`[{"symbols":["AAPL"],"timezone":"Mars/Olympus","open":"09:30","close":"16:00"}]`,
+		`[{"symbols":["AAPL"],"open":"9.30","close":"16:00"}]`,
+		`[{"symbols":["AAPL"],"open":"16:00","close":"09:30"}]`,
+		`[{"symbols":["AAPL"],"open":"09:30","close":"16:00","days":["funday"]}]`,
+		`[{"symbols":["[AAPL"],"open":"09:30","close":"16:00"}]`,
+	} {
+		if _, err := parseTradingSessions(raw); err == nil {
+			t.Errorf("parseTradingSessions(%s) succeeded", raw)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// ==============================================================================
+// Trailing stops - stops that follow the market
+// ==============================================================================
+// A trailing_stop order is a stop whose stop price trails the last price by
+// trail_amount, or by trail_percent of it: below the market for a sell, above
+// it for a buy. Whenever the symbol's last price moves in the order's favour
+// (up for a sell, down for a buy) the stop price ratchets after it; when the
+// price moves back against it, the stop price stays put. Once the market
+// reverses by the trail and trades through the stop price, the order triggers
+// like any stop: as a market order, or a limit order at limit_price if it has
+// one.
+//
+// Trailing stops are held with the other stops, scored by their current stop
+// price, and listed in "<stream>.trailing.<side>.<symbol>" sets so the
+// ratchet can find them. Entries for stops that have since triggered or been
+// cancelled are dropped the next time the ratchet runs.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shopspring/decimal"
+)
+
+// orderTypeTrailingStop is the type of trailing stop orders
+const orderTypeTrailingStop = "trailing_stop"
+
+var hundred = decimal.NewFromInt(100)
+
+// trailingKey is the set listing the trailing stops on one side of symbol
+func (e *ExecutionEngine) trailingKey(side string, symbol string) string {
+	return e.keyPrefix + ".trailing." + side + "." + symbol
+}
+
+// validateTrail checks an order's trail: a trailing stop needs exactly one of
+// trail_amount and trail_percent, and other orders neither
+func validateTrail(order *OrderRequest) error {
+	amount, percent := order.TrailAmount, order.TrailPercent
+	if order.Type != orderTypeTrailingStop {
+		if !amount.IsZero() || !percent.IsZero() {
+			return fmt.Errorf("trail_amount and trail_percent require a trailing_stop order")
+		}
+		return nil
+	}
+	if amount.IsPositive() == percent.IsPositive() || amount.IsNegative() || percent.IsNegative() {
+		return fmt.Errorf("trailing_stop order requires either a positive trail_amount or a positive trail_percent")
+	}
+	if percent.GreaterThanOrEqual(hundred) {
+		return fmt.Errorf("trail_percent must be less than 100")
+	}
+	return nil
+}
+
+// trailingStopPrice is where a trailing stop's price trails last
+func trailingStopPrice(order *OrderRequest, last decimal.Decimal) decimal.Decimal {
+	trail := order.TrailAmount
+	if order.TrailPercent.IsPositive() {
+		trail = last.Mul(order.TrailPercent).Div(hundred)
+	}
+	if order.Side == "buy" {
+		return last.Add(trail)
+	}
+	return last.Sub(trail)
+}
+
+// restTrailingStop sets a trailing stop's price from the last price and holds
+// it with the other stops
+func (e *ExecutionEngine) restTrailingStop(order *OrderRequest) error {
+	order.StopPrice = trailingStopPrice(order, e.prices.reference(order.Symbol))
+	if err := e.redisClient.SAdd(e.ctx, e.trailingKey(order.Side, order.Symbol), order.OrderID).Err(); err != nil {
+		log.Printf("Error listing trailing stop %s: %v", order.OrderID, err)
+		return err
+	}
+	return e.restStop(order)
+}
+
+// ratchetTrailingStops moves the stop price of symbol's trailing stops after
+// a favourable last price
+func (e *ExecutionEngine) ratchetTrailingStops(symbol string, last decimal.Decimal) {
+	for _, side := range []string{"buy", "sell"} {
+		listed := e.trailingKey(side, symbol)
+		orderIDs, err := e.redisClient.SMembers(e.ctx, listed).Result()
+		if err != nil || len(orderIDs) == 0 {
+			continue
+		}
+		payloads, err := e.redisClient.HMGet(e.ctx, e.heldOrdersKey, orderIDs...).Result()
+		if err != nil {
+			log.Printf("Error reading %s trailing stops in %s: %v", side, symbol, err)
+			continue
+		}
+		for i, payload := range payloads {
+			data, ok := payload.(string)
+			if !ok {
+				e.redisClient.SRem(e.ctx, listed, orderIDs[i]) // triggered or cancelled
+				continue
+			}
+			var order OrderRequest
+			if err := json.Unmarshal([]byte(data), &order); err != nil {
+				continue
+			}
+			price, _ := trailingStopPrice(&order, last).Float64()
+			// Only ever towards the market, and only while still held
+			e.redisClient.ZAddArgs(e.ctx, e.stopKey(side, symbol), redis.ZAddArgs{
+				XX:      true,
+				GT:      side == "sell",
+				LT:      side == "buy",
+				Members: []redis.Z{{Score: price, Member: order.OrderID}},
+			})
+		}
+	}
+}
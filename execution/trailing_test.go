@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+)
+
+func trailingStopOrder(id string, side string) *OrderRequest {
+	return &OrderRequest{OrderID: id, Symbol: "AAPL", Side: side, Quantity: dec(10), Type: orderTypeTrailingStop, TimeInForce: "day"}
+}
+
+// stopLevel returns a waiting stop's current stop price
+func stopLevel(t *testing.T, engine *ExecutionEngine, side string, orderID string) float64 {
+	t.Helper()
+	score, err := engine.redisClient.ZScore(engine.ctx, engine.stopKey(side, "AAPL"), orderID).Result()
+	if err != nil {
+		t.Fatalf("stop %s isn't waiting: %v", orderID, err)
+	}
+	return score
+}
+
+func TestTrailingStopRatchetsThenTriggersOnReversal(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	trade(t, engine, "t1", 100)
+
+	stop := trailingStopOrder("trail-1", "sell")
+	stop.TrailAmount = dec(5)
+	submitToEngine(t, engine, stop)
+	if level := stopLevel(t, engine, "sell", "trail-1"); level != 95 {
+		t.Fatalf("initial stop price %v, want 95", level)
+	}
+
+	// The stop follows the market up, but not back down
+	trade(t, engine, "t2", 110)
+	if level := stopLevel(t, engine, "sell", "trail-1"); level != 105 {
+		t.Fatalf("stop price after a rise to 110: %v, want 105", level)
+	}
+	trade(t, engine, "t3", 107)
+	if level := stopLevel(t, engine, "sell", "trail-1"); level != 105 {
+		t.Fatalf("stop price after a dip to 107: %v, want 105", level)
+	}
+	if status := restingStatus(t, engine, "trail-1"); status != statusHeld {
+		t.Fatalf("stop before the reversal: status %s, want held", status)
+	}
+
+	trade(t, engine, "t4", 104)
+	response, _ := engine.GetOrder("trail-1")
+	if response.Status != statusFilled || !response.FilledQuantity.Equal(dec(10)) {
+		t.Fatalf("stop after a reversal past the trail: %+v, want filled", response)
+	}
+	if n := engine.redisClient.SCard(engine.ctx, engine.trailingKey("sell", "AAPL")).Val(); n != 0 {
+		t.Errorf("%d trailing stops still listed after triggering", n)
+	}
+}
+
+func TestTrailingStopHoldsWithoutEnoughReversal(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	trade(t, engine, "t1", 100)
+
+	stop := trailingStopOrder("trail-1", "buy")
+	stop.TrailPercent = dec(10)
+	submitToEngine(t, engine, stop)
+	if level := stopLevel(t, engine, "buy", "trail-1"); level != 110 {
+		t.Fatalf("initial stop price %v, want 110", level)
+	}
+
+	trade(t, engine, "t2", 90)
+	trade(t, engine, "t3", 95)
+	trade(t, engine, "t4", 98)
+	if level := stopLevel(t, engine, "buy", "trail-1"); level != 99 {
+		t.Errorf("stop price after a fall to 90: %v, want 99", level)
+	}
+	if status := restingStatus(t, engine, "trail-1"); status != statusHeld {
+		t.Errorf("stop after an 8 point rebound on a 9 point trail: status %s, want held", status)
+	}
+}
+
+func TestValidateTrail(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		order   func(*OrderRequest)
+		wantErr bool
+	}{
+		{"amount", func(o *OrderRequest) { o.TrailAmount = dec(1) }, false},
+		{"percent", func(o *OrderRequest) { o.TrailPercent = dec(2.5) }, false},
+		{"neither", func(o *OrderRequest) {}, true},
+		{"both", func(o *OrderRequest) { o.TrailAmount, o.TrailPercent = dec(1), dec(1) }, true},
+		{"whole price", func(o *OrderRequest) { o.TrailPercent = dec(100) }, true},
+		{"not a trailing stop", func(o *OrderRequest) { o.Type = "market"; o.TrailAmount = dec(1) }, true},
+	} {
+		order := trailingStopOrder("trail-1", "sell")
+		tc.order(order)
+		if err := validateOrder(order); (err != nil) != tc.wantErr {
+			t.Errorf("%s: validateOrder = %v, want error %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
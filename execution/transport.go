@@ -0,0 +1,109 @@
+// ==============================================================================
+// Transport - the store behind the order stream and engine state
+// ==============================================================================
+// By default the engine runs on Redis (see rediscluster.go). TRANSPORT=memory
+// runs it with no Redis server for local development and demos: an embedded
+// in-process store speaks the Redis protocol over in-memory pipes, so the
+// order stream, consumer group, pub/sub updates and every key the engine
+// keeps behave exactly as they do on Redis, and the HTTP and gRPC APIs and
+// matching are unchanged. Nothing survives a restart, and producers outside
+// the process can't reach the stream, so orders have to come in through the
+// APIs.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// Transports
+const (
+	transportRedis  = "redis"
+	transportMemory = "memory"
+)
+
+// memoryExpiryInterval is how often the in-memory store expires keys
+const memoryExpiryInterval = time.Second
+
+// newTransport returns the client the engine runs on and a func releasing it
+func newTransport(cfg Config) (redis.UniversalClient, func()) {
+	switch cfg.Transport {
+	case transportMemory:
+		client, closeStore, err := newMemoryClient(cfg)
+		if err == nil {
+			log.Printf("Using the in-memory transport; nothing is persisted")
+			return client, closeStore
+		}
+		log.Printf("Starting the in-memory transport failed (%v), using Redis", err)
+	case transportRedis, "":
+	default:
+		log.Printf("Invalid TRANSPORT %q, using Redis", cfg.Transport)
+	}
+	client := newRedisClient(cfg)
+	return client, func() { client.Close() }
+}
+
+// newMemoryClient starts an in-process store and returns a client connected
+// to it through in-memory pipes
+func newMemoryClient(cfg Config) (redis.UniversalClient, func(), error) {
+	store := miniredis.NewMiniRedis()
+	if err := store.StartAddr("127.0.0.1:0"); err != nil {
+		return nil, nil, err
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr: "memory",
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, peer := net.Pipe()
+			store.Server().ServeConn(peer)
+			return conn, nil
+		},
+		PoolSize:     cfg.RedisPoolSize,
+		ReadTimeout:  cfg.RedisTimeout,
+		WriteTimeout: cfg.RedisTimeout,
+	})
+
+	// The store only expires keys when told time has passed
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(memoryExpiryInterval)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				store.FastForward(now.Sub(last))
+				last = now
+			}
+		}
+	}()
+
+	var once sync.Once
+	closeStore := func() {
+		once.Do(func() {
+			close(done)
+			client.Close()
+			store.Close()
+		})
+	}
+	return client, closeStore, nil
+}
+
+// Close releases the engine's connection to its transport, stopping the
+// in-memory store if it has one
+func (e *ExecutionEngine) Close() {
+	if e.closeTransport != nil {
+		e.closeTransport()
+		return
+	}
+	e.redisClient.Close()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMemoryTransportRunsWithoutRedis(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Transport = transportMemory
+	cfg.RedisHost, cfg.RedisPort = "127.0.0.1", "1" // nothing listens here
+	cfg.StreamName = "memory-stream"
+	engine := NewExecutionEngineFromConfig(cfg)
+	t.Cleanup(engine.Close)
+
+	if err := ensureConsumerGroup(engine.ctx, engine.redisClient, engine.streamName, engine.consumerGroup); err != nil {
+		t.Fatalf("creating the consumer group in memory: %v", err)
+	}
+	handler := engine.routes()
+	if rec := postOrder(handler, ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("submit: got %d: %s", rec.Code, rec.Body)
+	}
+	engine.consumeBatch()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders/auth-1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get: got %d: %s", rec.Code, rec.Body)
+	}
+	var response OrderResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != statusFilled || !response.FilledQuantity.Equal(dec(10)) {
+		t.Errorf("got %s %s, want filled 10", response.Status, response.FilledQuantity)
+	}
+}
+
+func TestUnknownTransportFallsBackToRedis(t *testing.T) {
+	engine, mr := newTestEngine(t)
+	cfg := engine.config
+	cfg.Transport = "carrier-pigeon"
+	cfg.RedisHost, cfg.RedisPort = mr.Host(), mr.Port()
+	client, closeClient := newTransport(cfg)
+	defer closeClient()
+	if err := client.Ping(engine.ctx).Err(); err != nil {
+		t.Fatalf("fallback client can't reach Redis: %v", err)
+	}
+	if mr.TotalConnectionCount() == 0 {
+		t.Error("fallback client didn't connect to Redis")
+	}
+}
@@ -0,0 +1,453 @@
+// ==============================================================================
+// TWAP - slice a large order evenly over a time horizon
+// ==============================================================================
+// POST /algos/twap takes a parent order (symbol, side, quantity, market or
+// limit) with a duration and a slice interval, and works it as
+// duration/interval child orders, one per interval from the start. Each
+// slice is the quantity still to be worked spread over the slices left,
+// rounded down to the symbol's lot size; the last slice takes the
+// remainder. Quantity a limit slice didn't fill by the time it was done
+// (an IOC slice, say) is worked again by the later slices.
+//
+// Children are numbered "<parent>-1", "<parent>-2", ... and carry the parent
+// in parent_order_id (see parentorders.go), so GET /orders/{parent}/children
+// shows the algo's progress. The parent itself is an order too: it is
+// "working" from the start, its fills are the children's and it ends
+// "filled" once they add up to its quantity. If the horizon elapses first,
+// or the parent is cancelled (DELETE /algos/twap/{parent}, or a cancel of
+// the parent's order ID), slicing stops, its working children are cancelled
+// and it ends "cancelled" with whatever was filled.
+//
+// The algo sweeper runs every ALGO_SWEEP_INTERVAL and sends the slices that
+// are due on the engine's clock, at most one per algo per sweep. Like held
+// order activation it skips while consumption is paused. Running algos,
+// VWAPs (see vwap.go) included, are held in memory only and don't survive a
+// restart.
+// ==============================================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shopspring/decimal"
+)
+
+// maxTWAPSlices bounds the number of child orders one TWAP may send
+const maxTWAPSlices = 10000
+
+// TWAPRequest starts a TWAP algo
+type TWAPRequest struct {
+	OrderID     string            `json:"order_id"` // the parent's ID, after which the children are named
+	Symbol      string            `json:"symbol"`
+	Side        string            `json:"side"`
+	Quantity    decimal.Decimal   `json:"quantity"`
+	Type        string            `json:"type"` // of the slices: market (the default) or limit
+	LimitPrice  decimal.Decimal   `json:"limit_price"`
+	TimeInForce string            `json:"time_in_force"`
+	AccountID   string            `json:"account_id,omitempty"`
+	DurationMs  int64             `json:"duration_ms"`
+	IntervalMs  int64             `json:"interval_ms"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// twapAlgo is a running TWAP, or VWAP (see vwap.go) when it has a volume
+// curve. mu is held while a slice is sent, so a cancel can't race one out.
+type twapAlgo struct {
+	mu       sync.Mutex
+	spec     TWAPRequest
+	kind     string // "TWAP" or "VWAP", for logs
+	start    time.Time
+	end      time.Time
+	interval time.Duration
+	slices   int
+	sent     int
+	children map[string]decimal.Decimal // child order ID to quantity
+	done     bool
+
+	curve         []float64       // expected market volume per slice
+	inShares      bool            // curve is in shares rather than relative
+	volumeAtStart decimal.Decimal // the symbol's traded volume at the start
+}
+
+// twapAlgos holds the running TWAPs and VWAPs by parent order ID
+type twapAlgos struct {
+	mu    sync.Mutex
+	algos map[string]*twapAlgo
+}
+
+func newTWAPAlgos() *twapAlgos {
+	return &twapAlgos{algos: map[string]*twapAlgo{}}
+}
+
+func (t *twapAlgos) get(parentID string) *twapAlgo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.algos[parentID]
+}
+
+// running lists the running TWAPs in parent order ID order
+func (t *twapAlgos) running() []*twapAlgo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	algos := make([]*twapAlgo, 0, len(t.algos))
+	for _, a := range t.algos {
+		algos = append(algos, a)
+	}
+	sort.Slice(algos, func(i, j int) bool { return algos[i].spec.OrderID < algos[j].spec.OrderID })
+	return algos
+}
+
+func (t *twapAlgos) remove(parentID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.algos, parentID)
+}
+
+// sliceOrder is the child order for a slice of quantity
+func (spec *TWAPRequest) sliceOrder(orderID string, quantity decimal.Decimal, now time.Time) *OrderRequest {
+	return &OrderRequest{
+		OrderID:        orderID,
+		Symbol:         spec.Symbol,
+		Side:           spec.Side,
+		Quantity:       quantity,
+		Type:           spec.Type,
+		LimitPrice:     spec.LimitPrice,
+		TimeInForce:    spec.TimeInForce,
+		Timestamp:      now.UnixMilli(),
+		AccountID:      spec.AccountID,
+		Tags:           spec.Tags,
+		ParentOrderID:  spec.OrderID,
+		ParentQuantity: spec.Quantity,
+	}
+}
+
+// validateTWAP checks a TWAP request, defaulting its slice type
+func validateTWAP(spec *TWAPRequest) error {
+	if spec.Type == "" {
+		spec.Type = "market"
+	}
+	if spec.Type != "market" && spec.Type != "limit" {
+		return fmt.Errorf("slices must be market or limit orders, not %q", spec.Type)
+	}
+	if spec.OrderID == "" {
+		return fmt.Errorf("order_id is required")
+	}
+	if strings.Contains(spec.OrderID, "/") {
+		return fmt.Errorf("invalid order_id %q", spec.OrderID)
+	}
+	if err := validateOrder(spec.sliceOrder(spec.OrderID+"-1", spec.Quantity, time.Time{})); err != nil {
+		return err
+	}
+	if spec.DurationMs <= 0 || spec.IntervalMs <= 0 {
+		return fmt.Errorf("duration_ms and interval_ms must be positive")
+	}
+	if spec.IntervalMs > spec.DurationMs {
+		return fmt.Errorf("interval_ms must not exceed duration_ms")
+	}
+	if slices := (spec.DurationMs + spec.IntervalMs - 1) / spec.IntervalMs; slices > maxTWAPSlices {
+		return fmt.Errorf("%d slices, at most %d allowed", slices, maxTWAPSlices)
+	}
+	return nil
+}
+
+// StartTWAP starts working a parent order as a TWAP and returns the parent's
+// response
+func (e *ExecutionEngine) StartTWAP(spec TWAPRequest) (*OrderResponse, error) {
+	if e.Draining() {
+		return nil, errDraining
+	}
+	spec.Symbol = e.canonicalSymbol(spec.Symbol)
+	if err := validateTWAP(&spec); err != nil {
+		return nil, err
+	}
+	return e.startAlgo(spec, nil, false)
+}
+
+// startAlgo registers a validated TWAP, or a VWAP following curve, and opens
+// its parent order. inShares says the curve is in shares, so realized volume
+// can stand in for it.
+func (e *ExecutionEngine) startAlgo(spec TWAPRequest, curve []float64, inShares bool) (*OrderResponse, error) {
+	e.twaps.mu.Lock()
+	defer e.twaps.mu.Unlock()
+	if _, ok := e.twaps.algos[spec.OrderID]; ok {
+		return nil, fmt.Errorf("order %s already exists", spec.OrderID)
+	}
+	if _, ok := e.GetOrder(spec.OrderID); ok {
+		return nil, fmt.Errorf("order %s already exists", spec.OrderID)
+	}
+
+	now := e.now()
+	duration := time.Duration(spec.DurationMs) * time.Millisecond
+	interval := time.Duration(spec.IntervalMs) * time.Millisecond
+	a := &twapAlgo{
+		spec:     spec,
+		kind:     "TWAP",
+		start:    now,
+		end:      now.Add(duration),
+		interval: interval,
+		slices:   int((duration + interval - 1) / interval),
+		children: map[string]decimal.Decimal{},
+	}
+	if curve != nil {
+		a.kind = "VWAP"
+		a.slices = len(curve)
+		a.curve = curve
+		a.inShares = inShares
+		a.volumeAtStart = e.prices.tradedVolume(spec.Symbol)
+	}
+	parent := &OrderRequest{OrderID: spec.OrderID, Symbol: spec.Symbol, Side: spec.Side, Quantity: spec.Quantity, Type: spec.Type, LimitPrice: spec.LimitPrice, AccountID: spec.AccountID, Tags: spec.Tags}
+	response := &OrderResponse{
+		OrderID:        spec.OrderID,
+		Symbol:         spec.Symbol,
+		Status:         statusWorking,
+		AcknowledgedAt: now.UnixMilli(),
+		Tags:           spec.Tags,
+	}
+	e.orderCache.Store(spec.OrderID, response)
+	e.saveOrder(parent, response)
+	e.auditState(spec.AccountID, response)
+	e.publishResponse(response)
+	e.twaps.algos[spec.OrderID] = a
+
+	log.Printf("%s %s started: %s %s %s over %s in %d slices", a.kind, spec.OrderID, spec.Side, spec.Quantity, spec.Symbol, duration, a.slices)
+	return response, nil
+}
+
+// runTWAPs sends the slices that are due and finishes algos that are done.
+// Like a consumer batch, it waits out and is skipped while consumption is
+// paused.
+func (e *ExecutionEngine) runTWAPs() {
+	e.batchMu.Lock()
+	defer e.batchMu.Unlock()
+	if e.paused.Load() {
+		return
+	}
+	for _, a := range e.twaps.running() {
+		e.stepTWAP(a)
+	}
+}
+
+// stepTWAP sends a TWAP's next slice if it is due, then updates the parent
+func (e *ExecutionEngine) stepTWAP(a *twapAlgo) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.done {
+		return
+	}
+	now := e.now()
+	if a.sent < a.slices && !now.Before(a.start.Add(time.Duration(a.sent)*a.interval)) {
+		e.sendSlice(a, now)
+	}
+
+	filled := e.updateTWAPParent(a)
+	switch {
+	case filled.GreaterThanOrEqual(a.spec.Quantity):
+		e.finishTWAP(a, "filled")
+	case a.sent == a.slices && !now.Before(a.end):
+		e.finishTWAP(a, "horizon elapsed")
+	}
+}
+
+// sendSlice sends a TWAP's next child order. Callers must hold a.mu.
+func (e *ExecutionEngine) sendSlice(a *twapAlgo, now time.Time) {
+	// Children that are done count with what they filled, working ones with
+	// their whole quantity
+	remaining := a.spec.Quantity
+	for childID, quantity := range a.children {
+		if child, ok := e.GetOrder(childID); ok {
+			if _, open := orderTransitions[child.Status]; !open {
+				quantity = child.FilledQuantity
+			}
+		}
+		remaining = remaining.Sub(quantity)
+	}
+
+	slice := a.sent
+	left := a.slices - a.sent
+	if !now.Before(a.end) {
+		left = 1 // out of time: everything goes in this slice
+	}
+	if left == 1 {
+		a.sent = a.slices
+	} else {
+		a.sent++
+	}
+	quantity := remaining
+	if left > 1 {
+		if a.curve != nil {
+			quantity = e.vwapSliceQuantity(a, slice, remaining)
+		} else {
+			quantity = remaining.Div(decimal.NewFromInt(int64(left)))
+		}
+		if lot := e.instruments[a.spec.Symbol].LotSize; lot.IsPositive() {
+			quantity = quantity.Div(lot).Floor().Mul(lot)
+		}
+	}
+	if !quantity.IsPositive() {
+		return
+	}
+
+	child := a.spec.sliceOrder(fmt.Sprintf("%s-%d", a.spec.OrderID, len(a.children)+1), quantity, now)
+	payload, err := json.Marshal(child)
+	if err != nil {
+		log.Printf("Error encoding slice %s of %s %s: %v", child.OrderID, a.kind, a.spec.OrderID, err)
+		return
+	}
+	a.children[child.OrderID] = quantity
+	if err := e.handleMessage(redis.XMessage{ID: "twap:" + child.OrderID, Values: map[string]interface{}{"order": string(payload)}}); err != nil {
+		log.Printf("Error sending slice %s of %s %s: %v", child.OrderID, a.kind, a.spec.OrderID, err)
+	}
+}
+
+// updateTWAPParent rolls the children's fills up onto the parent's response
+// and returns the quantity filled
+func (e *ExecutionEngine) updateTWAPParent(a *twapAlgo) decimal.Decimal {
+	rollup, ok, err := e.ParentOrder(a.spec.OrderID)
+	if err != nil {
+		log.Printf("Error rolling up %s %s: %v", a.kind, a.spec.OrderID, err)
+	}
+	if !ok {
+		return decimal.Zero
+	}
+	parent, ok := e.GetOrder(a.spec.OrderID)
+	if !ok || parent.FilledQuantity.Equal(rollup.FilledQuantity) {
+		return rollup.FilledQuantity
+	}
+	to := statusPartiallyFilled
+	if rollup.FilledQuantity.GreaterThanOrEqual(a.spec.Quantity) {
+		to = statusFilled
+	}
+	e.updateCachedResponse(a.spec.OrderID, to, func(r *OrderResponse) {
+		r.FilledQuantity = rollup.FilledQuantity
+		r.FilledAvgPrice = rollup.FilledAvgPrice
+		r.Commission = rollup.Commission
+		r.Fees = rollup.Fees
+	})
+	return rollup.FilledQuantity
+}
+
+// finishTWAP stops a TWAP, cancelling its working children and the parent
+// unless it filled. Callers must hold a.mu.
+func (e *ExecutionEngine) finishTWAP(a *twapAlgo, why string) {
+	a.done = true
+	e.twaps.remove(a.spec.OrderID)
+
+	for childID := range a.children {
+		child, ok := e.GetOrder(childID)
+		if !ok {
+			continue
+		}
+		if _, open := orderTransitions[child.Status]; !open {
+			continue
+		}
+		if _, err := e.cancelOrder(childID, "", false); err != nil && err != errOrderNotWorking {
+			log.Printf("Error cancelling slice %s of %s %s: %v", childID, a.kind, a.spec.OrderID, err)
+		}
+	}
+
+	filled := e.updateTWAPParent(a)
+	if filled.LessThan(a.spec.Quantity) {
+		e.updateCachedResponse(a.spec.OrderID, statusCancelled, func(r *OrderResponse) {})
+	}
+	log.Printf("%s %s finished (%s): %s of %s filled in %d slices", a.kind, a.spec.OrderID, why, filled, a.spec.Quantity, len(a.children))
+}
+
+// cancelTWAP stops a running TWAP or VWAP. A non-empty account must own it.
+func (e *ExecutionEngine) cancelTWAP(a *twapAlgo, account string) (*OrderResponse, error) {
+	if account != "" && a.spec.AccountID != account {
+		return nil, errNotOrderOwner
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.done {
+		return nil, errOrderNotWorking
+	}
+	e.finishTWAP(a, "cancelled")
+	response, _ := e.GetOrder(a.spec.OrderID)
+	return response, nil
+}
+
+// twapLoop runs the algo sweeper until ctx is cancelled
+func (e *ExecutionEngine) twapLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.runTWAPs()
+		}
+	}
+}
+
+// handleStartTWAP serves POST /algos/twap
+func (e *ExecutionEngine) handleStartTWAP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	var spec TWAPRequest
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		if payloadTooLarge(err) {
+			writePayloadTooLarge(w, e.config.MaxPayloadBytes)
+			return
+		}
+		writeError(w, errCodeInvalidRequest, "Invalid request")
+		return
+	}
+	if account, ok := accountFromContext(r.Context()); ok {
+		spec.AccountID = account
+	}
+	response, err := e.StartTWAP(spec)
+	if errors.Is(err, errDraining) {
+		writeError(w, errCodeUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, errCodeInvalidRequest, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleCancelTWAP serves DELETE /algos/twap/{parent} and
+// DELETE /algos/vwap/{parent}
+func (e *ExecutionEngine) handleCancelTWAP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		methodNotAllowed(w)
+		return
+	}
+	account, _ := accountFromContext(r.Context())
+	kind := "TWAP"
+	parentID := strings.TrimPrefix(r.URL.Path, "/algos/twap/")
+	if strings.HasPrefix(r.URL.Path, "/algos/vwap/") {
+		kind = "VWAP"
+		parentID = strings.TrimPrefix(r.URL.Path, "/algos/vwap/")
+	}
+	a := e.twaps.get(parentID)
+	if a == nil || a.kind != kind {
+		writeError(w, errCodeNotFound, "No running "+kind+" with this order ID")
+		return
+	}
+	response, err := e.cancelTWAP(a, account)
+	switch {
+	case errors.Is(err, errNotOrderOwner):
+		writeError(w, errCodeForbidden, err.Error())
+	case err != nil:
+		writeError(w, errCodeNotFound, "No running "+kind+" with this order ID")
+	default:
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func startTWAP(t *testing.T, engine *ExecutionEngine, id string, quantity float64, duration, interval time.Duration) {
+	t.Helper()
+	if _, err := engine.StartTWAP(TWAPRequest{
+		OrderID:    id,
+		Symbol:     "AAPL",
+		Side:       "buy",
+		Quantity:   dec(quantity),
+		DurationMs: duration.Milliseconds(),
+		IntervalMs: interval.Milliseconds(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sliceFills returns the filled quantities of a parent's children, oldest first
+func sliceFills(t *testing.T, engine *ExecutionEngine, parentID string) []string {
+	t.Helper()
+	parent, ok, err := engine.ParentOrder(parentID)
+	if err != nil || !ok {
+		return nil
+	}
+	var quantities []string
+	for _, child := range parent.Children {
+		quantities = append(quantities, child.FilledQuantity.String())
+	}
+	return quantities
+}
+
+func TestTWAPSlicesOverTheHorizon(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+	engine.instruments = map[string]InstrumentSpec{"AAPL": {LotSize: decimal.NewFromInt(1)}}
+
+	// 10 over a second in 250ms slices: 10/4 rounds down to 2, then 8/3 to
+	// 2, 6/2 is 3 and the last slice takes the remaining 3
+	startTWAP(t, engine, "twap-1", 10, time.Second, 250*time.Millisecond)
+	for i := 0; i < 4; i++ {
+		engine.runTWAPs()
+		if got := len(sliceFills(t, engine, "twap-1")); got != i+1 {
+			t.Fatalf("after %v: %d slices sent, want %d", time.Duration(i)*250*time.Millisecond, got, i+1)
+		}
+		engine.runTWAPs() // nothing more is due until the next interval
+		clock.advance(250 * time.Millisecond)
+	}
+	if got := strings.Join(sliceFills(t, engine, "twap-1"), ","); got != "2,2,3,3" {
+		t.Errorf("slices filled %s, want 2,2,3,3", got)
+	}
+	parent, _ := engine.GetOrder("twap-1")
+	if parent.Status != statusFilled || !parent.FilledQuantity.Equal(dec(10)) {
+		t.Errorf("parent %s with %s filled, want filled with 10", parent.Status, parent.FilledQuantity)
+	}
+	if engine.twaps.get("twap-1") != nil {
+		t.Error("filled TWAP still running")
+	}
+}
+
+func TestTWAPEndsAtTheHorizonWithWhatFilled(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+
+	// Limit slices below the market rest unfilled
+	if _, err := engine.StartTWAP(TWAPRequest{OrderID: "twap-2", Symbol: "AAPL", Side: "buy", Quantity: dec(20), Type: "limit", LimitPrice: dec(90), DurationMs: 200, IntervalMs: 100}); err != nil {
+		t.Fatal(err)
+	}
+	engine.runTWAPs()
+	clock.advance(100 * time.Millisecond)
+	engine.runTWAPs()
+	if restingQuantity(engine, "AAPL", "twap-2-1") != 10 || restingQuantity(engine, "AAPL", "twap-2-2") != 10 {
+		t.Fatal("limit slices of 10 are not resting")
+	}
+
+	clock.advance(100 * time.Millisecond)
+	engine.runTWAPs()
+	if restingQuantity(engine, "AAPL", "twap-2-1") != 0 || restingQuantity(engine, "AAPL", "twap-2-2") != 0 {
+		t.Error("working slices still resting after the horizon")
+	}
+	if parent, _ := engine.GetOrder("twap-2"); parent.Status != statusCancelled {
+		t.Errorf("parent after the horizon: %s, want cancelled", parent.Status)
+	}
+}
+
+func TestCancellingTWAPStopsSlicing(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+	handler := engine.routes()
+
+	startTWAP(t, engine, "twap-3", 40, time.Second, 250*time.Millisecond)
+	engine.runTWAPs()
+	clock.advance(250 * time.Millisecond)
+	engine.runTWAPs()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/algos/twap/twap-3", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cancel: got %d: %s", rec.Code, rec.Body)
+	}
+	for i := 0; i < 3; i++ {
+		clock.advance(250 * time.Millisecond)
+		engine.runTWAPs()
+	}
+	if got := len(sliceFills(t, engine, "twap-3")); got != 2 {
+		t.Errorf("%d slices sent, want the 2 sent before the cancel", got)
+	}
+	parent, _ := engine.GetOrder("twap-3")
+	if parent.Status != statusCancelled || !parent.FilledQuantity.Equal(dec(20)) {
+		t.Errorf("parent %s with %s filled, want cancelled with 20", parent.Status, parent.FilledQuantity)
+	}
+	if _, err := engine.CancelOrder("twap-3", ""); err == nil {
+		t.Error("cancelled a finished TWAP again")
+	}
+}
+
+func TestValidateTWAP(t *testing.T) {
+	valid := func() TWAPRequest {
+		return TWAPRequest{OrderID: "p", Symbol: "AAPL", Side: "buy", Quantity: dec(10), DurationMs: 1000, IntervalMs: 100}
+	}
+	spec := valid()
+	if err := validateTWAP(&spec); err != nil || spec.Type != "market" {
+		t.Fatalf("valid request: %v, type %q", err, spec.Type)
+	}
+	for name, change := range map[string]func(*TWAPRequest){
+		"no order id":         func(s *TWAPRequest) { s.OrderID = "" },
+		"stop slices":         func(s *TWAPRequest) { s.Type = "stop" },
+		"no quantity":         func(s *TWAPRequest) { s.Quantity = dec(0) },
+		"no interval":         func(s *TWAPRequest) { s.IntervalMs = 0 },
+		"interval > duration": func(s *TWAPRequest) { s.IntervalMs = 2000 },
+		"too many slices":     func(s *TWAPRequest) { s.DurationMs, s.IntervalMs = 1000000, 1 },
+		"limit without price": func(s *TWAPRequest) { s.Type = "limit" },
+	} {
+		spec := valid()
+		change(&spec)
+		if err := validateTWAP(&spec); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
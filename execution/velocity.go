@@ -0,0 +1,139 @@
+// ==============================================================================
+// Notional velocity - cap what an account executes per rolling window
+// ==============================================================================
+// Per-order limits don't stop a strategy from trading too fast in orders
+// that are each fine. With MAX_NOTIONAL_PER_WINDOW set (a decimal amount,
+// e.g. 10000000), each account may execute at most that much notional - the
+// quantity filled times its price, taker and maker fills alike - in any
+// NOTIONAL_WINDOW (1m by default). An order whose estimated notional would
+// take its account over the cap is rejected with
+// "notional_velocity_exceeded", telling the client when enough of the
+// window will have passed for it to fit.
+//
+// An order is estimated at its notional, or its quantity at its limit price
+// or, for market orders, the reference price. Fills leave the window as it
+// moves past them, timed by the engine clock. The window is kept per engine
+// instance and starts empty on restart, and fills are only counted while the
+// cap is on, so one turned on by a reload starts empty too. Orders without
+// an account aren't capped.
+// ==============================================================================
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// rejectNotionalVelocity is the reason for orders over an account's window cap
+const rejectNotionalVelocity = "notional_velocity_exceeded"
+
+// notionalFill is notional an account executed at a time
+type notionalFill struct {
+	at       time.Time
+	notional decimal.Decimal
+}
+
+// notionalVelocity tracks the notional each account executed recently
+type notionalVelocity struct {
+	mu       sync.Mutex
+	accounts map[string][]notionalFill // oldest first
+}
+
+func newNotionalVelocity() *notionalVelocity {
+	return &notionalVelocity{accounts: make(map[string][]notionalFill)}
+}
+
+// record adds notional account executed at now, dropping its fills older
+// than window
+func (v *notionalVelocity) record(account string, notional decimal.Decimal, now time.Time, window time.Duration) {
+	if v == nil || account == "" || !notional.IsPositive() {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	fills, _ := v.window(account, window, now)
+	v.accounts[account] = append(fills, notionalFill{at: now, notional: notional})
+}
+
+// recordNotional counts notional account executed against its window cap.
+// Nothing is kept while MAX_NOTIONAL_PER_WINDOW is off.
+func (e *ExecutionEngine) recordNotional(account string, notional decimal.Decimal) {
+	limits := e.riskLimits()
+	if !limits.MaxNotionalPerWindow.IsPositive() || limits.NotionalWindow <= 0 {
+		return
+	}
+	e.velocity.record(account, notional, e.now(), limits.NotionalWindow)
+}
+
+// window returns account's fills within window of now, oldest first, and
+// their total, dropping the older ones. Callers must hold mu.
+func (v *notionalVelocity) window(account string, window time.Duration, now time.Time) ([]notionalFill, decimal.Decimal) {
+	fills := v.accounts[account]
+	start := 0
+	for start < len(fills) && !fills[start].at.After(now.Add(-window)) {
+		start++
+	}
+	fills = fills[start:]
+	if len(fills) == 0 {
+		delete(v.accounts, account)
+	} else {
+		v.accounts[account] = fills
+	}
+	total := decimal.Zero
+	for _, fill := range fills {
+		total = total.Add(fill.notional)
+	}
+	return fills, total
+}
+
+// estimatedNotional is what an order is expected to execute
+func (e *ExecutionEngine) estimatedNotional(order *OrderRequest) decimal.Decimal {
+	if order.Notional.IsPositive() {
+		return order.Notional
+	}
+	price := order.LimitPrice
+	if order.Type == "market" || !price.IsPositive() {
+		price = e.prices.reference(order.Symbol)
+	}
+	return order.Quantity.Mul(price)
+}
+
+// checkNotionalVelocity refuses an order that would take its account over
+// MAX_NOTIONAL_PER_WINDOW
+func (e *ExecutionEngine) checkNotionalVelocity(order *OrderRequest) *rejection {
+	limits := e.riskLimits()
+	limit, window := limits.MaxNotionalPerWindow, limits.NotionalWindow
+	if !limit.IsPositive() || window <= 0 || order.AccountID == "" || e.velocity == nil {
+		return nil
+	}
+	estimate := e.estimatedNotional(order)
+	now := e.now()
+
+	e.velocity.mu.Lock()
+	defer e.velocity.mu.Unlock()
+	fills, executed := e.velocity.window(order.AccountID, window, now)
+	if executed.Add(estimate).LessThanOrEqual(limit) {
+		return nil
+	}
+
+	// The order fits once enough of the oldest fills leave the window
+	var retryAfter time.Duration
+	remaining := executed
+	for _, fill := range fills {
+		remaining = remaining.Sub(fill.notional)
+		if remaining.Add(estimate).LessThanOrEqual(limit) {
+			retryAfter = fill.at.Add(window).Sub(now)
+			break
+		}
+	}
+	return &rejection{
+		Reason:     rejectNotionalVelocity,
+		Detail:     fmt.Sprintf("account %s executed %s in the last %s; %s more would exceed the limit of %s", order.AccountID, executed, window, estimate, limit),
+		Limit:      &RiskLimit{Name: riskLimitNotionalVelocity, Limit: limit, Current: executed},
+		RetryAfter: retryAfter,
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNotionalVelocityCapPerAccount(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+	ask := limitOrder("ask", "AAPL", "sell", 100, 1000)
+	ask.AccountID = "acct-mm"
+	submitToEngine(t, engine, ask)
+	setRiskLimits(engine, func(l *RiskLimits) {
+		l.MaxNotionalPerWindow = dec(10000)
+		l.NotionalWindow = time.Minute
+	})
+
+	buy := func(id string) *OrderResponse {
+		order := limitOrder(id, "AAPL", "buy", 100, 30) // 3000 notional
+		order.AccountID = "acct-1"
+		submitToEngine(t, engine, order)
+		response, _ := engine.GetOrder(id)
+		return response
+	}
+
+	// 9000 over 20s stays under the cap
+	for i := 1; i <= 3; i++ {
+		if response := buy(fmt.Sprintf("buy-%d", i)); response.Status != statusFilled {
+			t.Fatalf("order %d under the cap: got %s/%s, want filled", i, response.Status, response.RejectReason)
+		}
+		clock.advance(10 * time.Second)
+	}
+
+	// Another 3000 would make 12000 within the minute
+	response := buy("buy-4")
+	if response.Status != statusRejected || response.RejectReason != rejectNotionalVelocity {
+		t.Fatalf("order over the cap: got %s/%s, want %s", response.Status, response.RejectReason, rejectNotionalVelocity)
+	}
+	if response.RiskLimit == nil || !response.RiskLimit.Current.Equal(dec(9000)) || response.RetryAfterMs != 30000 {
+		t.Errorf("rejection reports %+v, retry after %dms; want 9000 of 10000, retry after 30s", response.RiskLimit, response.RetryAfterMs)
+	}
+
+	// The maker side's fills count against its account too
+	bid := limitOrder("mm-bid", "AAPL", "buy", 90, 20)
+	bid.AccountID = "acct-mm"
+	submitToEngine(t, engine, bid)
+	if s := restingStatus(t, engine, "mm-bid"); s != statusRejected {
+		t.Errorf("maker with 9000 executed adding 1800: status %s, want rejected", s)
+	}
+
+	// Capacity frees as the first fill leaves the window
+	clock.advance(31 * time.Second)
+	if response := buy("buy-5"); response.Status != statusFilled {
+		t.Errorf("order once the window moved on: got %s/%s, want filled", response.Status, response.RejectReason)
+	}
+}
+
+func TestNotionalVelocityKeepsOnlyTheWindow(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+	fills := func(account string) int {
+		engine.velocity.mu.Lock()
+		defer engine.velocity.mu.Unlock()
+		return len(engine.velocity.accounts[account])
+	}
+
+	// With the cap off, fills aren't kept at all
+	engine.recordNotional("acct-1", dec(100))
+	if n := fills("acct-1"); n != 0 {
+		t.Fatalf("%d fills kept with the cap off, want 0", n)
+	}
+
+	// With it on, recording drops the fills the window has moved past
+	setRiskLimits(engine, func(l *RiskLimits) {
+		l.MaxNotionalPerWindow = dec(10000)
+		l.NotionalWindow = time.Minute
+	})
+	for i := 0; i < 10; i++ {
+		engine.recordNotional("acct-1", dec(100))
+		clock.advance(20 * time.Second)
+	}
+	if n := fills("acct-1"); n != 3 {
+		t.Errorf("%d fills kept, want the 3 within the last minute", n)
+	}
+}
@@ -0,0 +1,189 @@
+// ==============================================================================
+// VWAP - slice a large order along the market's volume curve
+// ==============================================================================
+// POST /algos/vwap takes the same parent order as a TWAP (see twap.go) with
+// a duration and a volume curve: the relative volume the market is expected
+// to trade in each of a run of equal slices of the horizon, e.g.
+// [30,20,20,30] for a busy open and close. The curve comes with the request
+// or, if it has none, from VWAP_VOLUME_CURVE; a historical profile is as good
+// as any. The interval is the duration over the number of slices.
+//
+// Each slice brings the quantity worked up to the parent's share of the
+// volume expected by the end of it. A request may also give expected_volume,
+// the shares the market is expected to trade over the whole horizon, which
+// puts the curve in shares so that it can be checked against the market. The
+// market data the engine sees - book fills, simulated fills and replayed
+// ticks - is counted per symbol (see marketdata.go), and once the symbol has
+// traded since the start, what actually traded stands in for the curve's
+// slices that have passed, less the algo's own fills. A busier market than
+// expected so pulls quantity forward and a quieter one holds it back. Without
+// expected_volume, or without any volume, the slices follow the curve as
+// given. The last slice takes the remainder.
+//
+// VWAPs otherwise run as TWAPs do: the same sweeper sends their slices, the
+// children are named and rolled up onto the parent the same way, and they
+// are stopped by DELETE /algos/vwap/{parent} or a cancel of the parent.
+// ==============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/shopspring/decimal"
+)
+
+// VWAPRequest starts a VWAP algo. IntervalMs is derived from the curve.
+type VWAPRequest struct {
+	TWAPRequest
+	VolumeCurve    []float64       `json:"volume_curve,omitempty"`
+	ExpectedVolume decimal.Decimal `json:"expected_volume"` // shares over the horizon; zero if unknown
+}
+
+// parseVolumeCurve decodes the VWAP_VOLUME_CURVE config
+func parseVolumeCurve(raw string) ([]float64, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var curve []float64
+	if err := json.Unmarshal([]byte(raw), &curve); err != nil {
+		return nil, err
+	}
+	if err := validateVolumeCurve(curve); err != nil {
+		return nil, err
+	}
+	return curve, nil
+}
+
+// validateVolumeCurve checks that a curve has slices and expects some volume
+func validateVolumeCurve(curve []float64) error {
+	if len(curve) == 0 {
+		return fmt.Errorf("volume_curve is required")
+	}
+	if len(curve) > maxTWAPSlices {
+		return fmt.Errorf("%d slices, at most %d allowed", len(curve), maxTWAPSlices)
+	}
+	total := 0.0
+	for i, v := range curve {
+		if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("invalid volume %v in slice %d", v, i+1)
+		}
+		total += v
+	}
+	if total <= 0 {
+		return fmt.Errorf("volume_curve expects no volume")
+	}
+	return nil
+}
+
+// StartVWAP starts working a parent order as a VWAP and returns the parent's
+// response
+func (e *ExecutionEngine) StartVWAP(spec VWAPRequest) (*OrderResponse, error) {
+	if e.Draining() {
+		return nil, errDraining
+	}
+	spec.Symbol = e.canonicalSymbol(spec.Symbol)
+	curve := spec.VolumeCurve
+	if len(curve) == 0 {
+		curve = e.vwapCurve
+	}
+	if err := validateVolumeCurve(curve); err != nil {
+		return nil, err
+	}
+	if spec.DurationMs > 0 {
+		spec.IntervalMs = spec.DurationMs / int64(len(curve))
+		if spec.IntervalMs == 0 {
+			return nil, fmt.Errorf("duration_ms is too short for %d slices", len(curve))
+		}
+	}
+	if spec.ExpectedVolume.IsNegative() {
+		return nil, fmt.Errorf("expected_volume must not be negative")
+	}
+	if err := validateTWAP(&spec.TWAPRequest); err != nil {
+		return nil, err
+	}
+
+	// Scale the curve to the expected volume, so it is in shares
+	curve = append([]float64(nil), curve...)
+	inShares := spec.ExpectedVolume.IsPositive()
+	if inShares {
+		total := 0.0
+		for _, v := range curve {
+			total += v
+		}
+		expected := spec.ExpectedVolume.InexactFloat64()
+		for i := range curve {
+			curve[i] = curve[i] / total * expected
+		}
+	}
+	return e.startAlgo(spec.TWAPRequest, curve, inShares)
+}
+
+// vwapSliceQuantity sizes a VWAP's slice so that the quantity worked reaches
+// the parent's share of the volume expected by the end of it. remaining is
+// the parent's quantity not yet filled or working. Callers must hold a.mu.
+func (e *ExecutionEngine) vwapSliceQuantity(a *twapAlgo, slice int, remaining decimal.Decimal) decimal.Decimal {
+	var past, ahead float64
+	for i, v := range a.curve {
+		if i < slice {
+			past += v
+		} else {
+			ahead += v
+		}
+	}
+
+	// Realized volume is in shares, so it can only replace a curve in shares.
+	// The symbol's volume since the start includes the children's fills.
+	if a.inShares {
+		filled := decimal.Zero
+		for childID := range a.children {
+			if child, ok := e.GetOrder(childID); ok {
+				filled = filled.Add(child.FilledQuantity)
+			}
+		}
+		if realized := e.prices.tradedVolume(a.spec.Symbol).Sub(a.volumeAtStart).Sub(filled); realized.IsPositive() {
+			past = realized.InexactFloat64()
+		}
+	}
+
+	share := decimal.NewFromFloat((past + a.curve[slice]) / (past + ahead))
+	quantity := a.spec.Quantity.Mul(share).Sub(a.spec.Quantity.Sub(remaining))
+	if quantity.GreaterThan(remaining) {
+		return remaining
+	}
+	return quantity
+}
+
+// handleStartVWAP serves POST /algos/vwap
+func (e *ExecutionEngine) handleStartVWAP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	var spec VWAPRequest
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		if payloadTooLarge(err) {
+			writePayloadTooLarge(w, e.config.MaxPayloadBytes)
+			return
+		}
+		writeError(w, errCodeInvalidRequest, "Invalid request")
+		return
+	}
+	if account, ok := accountFromContext(r.Context()); ok {
+		spec.AccountID = account
+	}
+	response, err := e.StartVWAP(spec)
+	if errors.Is(err, errDraining) {
+		writeError(w, errCodeUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, errCodeInvalidRequest, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func startVWAP(t *testing.T, engine *ExecutionEngine, id string, quantity float64, duration time.Duration, curve ...float64) {
+	t.Helper()
+	startVWAPExpecting(t, engine, id, quantity, duration, 0, curve...)
+}
+
+// startVWAPExpecting starts a VWAP whose curve is scaled to expected shares
+func startVWAPExpecting(t *testing.T, engine *ExecutionEngine, id string, quantity float64, duration time.Duration, expected float64, curve ...float64) {
+	t.Helper()
+	if _, err := engine.StartVWAP(VWAPRequest{
+		TWAPRequest:    TWAPRequest{OrderID: id, Symbol: "AAPL", Side: "buy", Quantity: dec(quantity), DurationMs: duration.Milliseconds()},
+		VolumeCurve:    curve,
+		ExpectedVolume: dec(expected),
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tradeOutside crosses quantity AAPL between two other accounts
+func tradeOutside(t *testing.T, engine *ExecutionEngine, quantity float64) {
+	t.Helper()
+	seller := limitOrder("seller", "AAPL", "sell", 100, quantity)
+	seller.AccountID = "acct-2"
+	buyer := limitOrder("buyer", "AAPL", "buy", 100, quantity)
+	buyer.AccountID = "acct-3"
+	submitToEngine(t, engine, seller)
+	submitToEngine(t, engine, buyer)
+}
+
+func TestVWAPSlicesFollowTheVolumeCurve(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+	engine.instruments = map[string]InstrumentSpec{"AAPL": {LotSize: decimal.NewFromInt(1)}}
+
+	startVWAP(t, engine, "vwap-1", 100, time.Second, 1, 2, 3, 4)
+	for i := 0; i < 4; i++ {
+		engine.runTWAPs()
+		clock.advance(250 * time.Millisecond)
+	}
+	if got := strings.Join(sliceFills(t, engine, "vwap-1"), ","); got != "10,20,30,40" {
+		t.Errorf("slices filled %s, want 10,20,30,40", got)
+	}
+	parent, _ := engine.GetOrder("vwap-1")
+	if parent.Status != statusFilled || !parent.FilledQuantity.Equal(dec(100)) {
+		t.Errorf("parent %s with %s filled, want filled with 100", parent.Status, parent.FilledQuantity)
+	}
+}
+
+func TestVWAPTracksRealizedVolume(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+
+	startVWAPExpecting(t, engine, "vwap-2", 100, time.Second, 400, 1, 1, 1, 1)
+	engine.runTWAPs()
+
+	// The curve expects 100 shares a slice. The market trades 200 in the
+	// first, so the next slice catches up to 100 of the 500 now expected in
+	// total: 60 worked, 35 more than the first slice's 25
+	tradeOutside(t, engine, 200)
+
+	for i := 0; i < 4; i++ {
+		clock.advance(250 * time.Millisecond)
+		engine.runTWAPs()
+	}
+	if got := strings.Join(sliceFills(t, engine, "vwap-2"), ","); got != "25,35,15,25" {
+		t.Errorf("slices filled %s, want 25,35,15,25", got)
+	}
+	if parent, _ := engine.GetOrder("vwap-2"); parent.Status != statusFilled || !parent.FilledQuantity.Equal(dec(100)) {
+		t.Errorf("parent %s with %s filled, want filled with 100", parent.Status, parent.FilledQuantity)
+	}
+}
+
+func TestVWAPWeightCurveIgnoresVolumeItCannotCompare(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+
+	// Weights say nothing of how many shares the market will trade, so the
+	// 200 traded outside can't run it ahead of the curve
+	startVWAP(t, engine, "vwap-4", 100, time.Second, 1, 2, 3, 4)
+	engine.runTWAPs()
+	tradeOutside(t, engine, 200)
+	for i := 0; i < 3; i++ {
+		clock.advance(250 * time.Millisecond)
+		engine.runTWAPs()
+	}
+	if got := strings.Join(sliceFills(t, engine, "vwap-4"), ","); got != "10,20,30,40" {
+		t.Errorf("slices filled %s, want 10,20,30,40", got)
+	}
+}
+
+func TestVWAPWeightCurveScaledToExpectedVolume(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+
+	// [1,2,3,4] of 1000 shares expects 100 in the first slice. The market
+	// trades 400 there, so the second catches up to (400+200)/1300 of the
+	// parent: 46 worked, 36 more than the first slice's 10
+	startVWAPExpecting(t, engine, "vwap-5", 100, time.Second, 1000, 1, 2, 3, 4)
+	engine.runTWAPs()
+	tradeOutside(t, engine, 400)
+	clock.advance(250 * time.Millisecond)
+	engine.runTWAPs()
+	if got := strings.Join(sliceFills(t, engine, "vwap-5"), ","); !strings.HasPrefix(got, "10,36") {
+		t.Errorf("slices filled %s, want 10,36 first", got)
+	}
+}
+
+func TestCancellingVWAPThroughItsParent(t *testing.T) {
+	engine, _ := newTestEngine(t)
+	clock := newFakeClock()
+	engine.clock = clock
+	engine.vwapCurve = []float64{1, 1}
+	handler := engine.routes()
+
+	// Without a curve of its own, a VWAP follows the configured one
+	startVWAP(t, engine, "vwap-3", 40, time.Second)
+	engine.runTWAPs()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/algos/twap/vwap-3", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("cancelling a VWAP as a TWAP: got %d, want 404", rec.Code)
+	}
+	if _, err := engine.CancelOrder("vwap-3", ""); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(time.Second)
+	engine.runTWAPs()
+	if got := strings.Join(sliceFills(t, engine, "vwap-3"), ","); got != "20" {
+		t.Errorf("slices filled %s, want the 20 sent before the cancel", got)
+	}
+	if parent, _ := engine.GetOrder("vwap-3"); parent.Status != statusCancelled {
+		t.Errorf("parent after cancel: %s, want cancelled", parent.Status)
+	}
+}